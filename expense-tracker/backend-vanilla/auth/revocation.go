@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// RevocationList remembers revoked sessions for as long as their access
+// tokens could still be presented.
+type RevocationList interface {
+	Revoke(sessionID string, until time.Time)
+	IsRevoked(sessionID string) bool
+}
+
+// MemoryRevocationList keeps revoked sessions in process memory. With
+// several API instances behind a load balancer, use a shared implementation.
+type MemoryRevocationList struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+	now     func() time.Time
+}
+
+func NewMemoryRevocationList() *MemoryRevocationList {
+	return &MemoryRevocationList{revoked: make(map[string]time.Time), now: time.Now}
+}
+
+func (l *MemoryRevocationList) Revoke(sessionID string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for id, exp := range l.revoked {
+		if now.After(exp) {
+			delete(l.revoked, id)
+		}
+	}
+	if until.After(l.revoked[sessionID]) {
+		l.revoked[sessionID] = until
+	}
+}
+
+func (l *MemoryRevocationList) IsRevoked(sessionID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until, ok := l.revoked[sessionID]
+	return ok && !l.now().After(until)
+}
@@ -0,0 +1,211 @@
+// Package auth issues and verifies the tokens of a login session: a
+// short-lived JWT access token plus a rotating refresh token stored server
+// side, so sessions can be ended before their tokens expire.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"expense-tracker/backend-vanilla/models"
+	"expense-tracker/backend-vanilla/repository"
+)
+
+const (
+	// AccessTTL is how long an access token is accepted. It also bounds how
+	// long a revoked session has to stay on the revocation list.
+	AccessTTL = 15 * time.Minute
+	// RefreshTTL is the lifetime of a "remember me" session.
+	RefreshTTL = 30 * 24 * time.Hour
+	// ShortRefreshTTL is the lifetime of any other session.
+	ShortRefreshTTL = 24 * time.Hour
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrTokenReused means an already rotated refresh token was presented
+	// again, so it has probably been stolen. The whole session is revoked.
+	ErrTokenReused = errors.New("refresh token was already used; the session has been revoked")
+)
+
+// Tokens is what a client receives when signing in or refreshing.
+type Tokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	// ExpiresIn is the access token's lifetime in seconds.
+	ExpiresIn int `json:"expires_in"`
+}
+
+// Claims identify the user and session behind an access token.
+type Claims struct {
+	UserID    string
+	SessionID string
+}
+
+// Manager issues, rotates and revokes session tokens. A session is a family
+// of refresh tokens; its ID is carried by every access token issued for it.
+type Manager struct {
+	secret  []byte
+	tokens  repository.TokenRepository
+	revoked RevocationList
+	now     func() time.Time
+}
+
+// NewManager returns a Manager signing access tokens with secret.
+func NewManager(secret []byte, tokens repository.TokenRepository, revoked RevocationList) *Manager {
+	return &Manager{secret: secret, tokens: tokens, revoked: revoked, now: time.Now}
+}
+
+// Issue starts a new session for userID whose refresh tokens live for ttl.
+func (m *Manager) Issue(ctx context.Context, userID string, ttl time.Duration) (Tokens, error) {
+	family, err := randomToken(16)
+	if err != nil {
+		return Tokens{}, err
+	}
+	return m.issue(ctx, userID, family, ttl)
+}
+
+func (m *Manager) issue(ctx context.Context, userID, family string, ttl time.Duration) (Tokens, error) {
+	now := m.now()
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"sid":     family,
+		"iat":     now.Unix(),
+		"exp":     now.Add(AccessTTL).Unix(),
+	}).SignedString(m.secret)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	refresh, err := randomToken(32)
+	if err != nil {
+		return Tokens{}, err
+	}
+	err = m.tokens.Create(ctx, &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  family,
+		TokenHash: hashToken(refresh),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return Tokens{}, fmt.Errorf("store refresh token: %w", err)
+	}
+
+	return Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int(AccessTTL / time.Second),
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new pair. Each refresh token works
+// once; presenting a used one revokes the session it belongs to.
+func (m *Manager) Refresh(ctx context.Context, refresh string) (Tokens, error) {
+	token, err := m.tokens.GetByHash(ctx, hashToken(refresh))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return Tokens{}, ErrInvalidToken
+		}
+		return Tokens{}, err
+	}
+
+	now := m.now()
+	if token.RevokedAt != nil || !now.Before(token.ExpiresAt) {
+		return Tokens{}, ErrInvalidToken
+	}
+	if token.UsedAt != nil {
+		return Tokens{}, m.reused(ctx, token.FamilyID)
+	}
+	if err := m.tokens.MarkUsed(ctx, token.ID, now); err != nil {
+		// Someone else exchanged it between our read and write
+		if errors.Is(err, repository.ErrNotFound) {
+			return Tokens{}, m.reused(ctx, token.FamilyID)
+		}
+		return Tokens{}, err
+	}
+
+	// The successor keeps the session's original lifetime
+	return m.issue(ctx, token.UserID, token.FamilyID, token.ExpiresAt.Sub(token.CreatedAt))
+}
+
+func (m *Manager) reused(ctx context.Context, family string) error {
+	if err := m.RevokeSession(ctx, family); err != nil {
+		return err
+	}
+	return ErrTokenReused
+}
+
+// RevokeSession ends one session: its refresh tokens stop working and its
+// access tokens are rejected until they expire.
+func (m *Manager) RevokeSession(ctx context.Context, sessionID string) error {
+	now := m.now()
+	if err := m.tokens.RevokeFamily(ctx, sessionID, now); err != nil {
+		return err
+	}
+	m.revoked.Revoke(sessionID, now.Add(AccessTTL))
+	return nil
+}
+
+// RevokeUser ends every session of a user.
+func (m *Manager) RevokeUser(ctx context.Context, userID string) error {
+	now := m.now()
+	families, err := m.tokens.RevokeUser(ctx, userID, now)
+	if err != nil {
+		return err
+	}
+	for _, family := range families {
+		m.revoked.Revoke(family, now.Add(AccessTTL))
+	}
+	return nil
+}
+
+// Verify checks an access token's signature and expiry and that its session
+// has not been revoked.
+func (m *Manager) Verify(access string) (Claims, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(access, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+		}
+		return m.secret, nil
+	})
+	if err != nil || !token.Valid {
+		return Claims{}, ErrInvalidToken
+	}
+
+	userID, _ := claims["user_id"].(string)
+	sessionID, _ := claims["sid"].(string)
+	// Tokens from before sessions existed cannot be revoked, so they are
+	// not accepted either
+	if userID == "" || sessionID == "" {
+		return Claims{}, ErrInvalidToken
+	}
+	if m.revoked.IsRevoked(sessionID) {
+		return Claims{}, ErrInvalidToken
+	}
+	return Claims{UserID: userID, SessionID: sessionID}, nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how refresh tokens are stored, so a leaked table does not
+// contain usable tokens. They are random, so a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
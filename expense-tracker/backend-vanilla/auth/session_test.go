@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"expense-tracker/backend-vanilla/repository"
+)
+
+var secret = []byte("test-secret")
+
+func newManager() *Manager {
+	return NewManager(secret, repository.NewMemory().Tokens, NewMemoryRevocationList())
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	m := newManager()
+	tokens, err := m.Issue(context.Background(), "user-1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.ExpiresIn != int(AccessTTL/time.Second) {
+		t.Errorf("ExpiresIn = %d", tokens.ExpiresIn)
+	}
+
+	claims, err := m.Verify(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != "user-1" || claims.SessionID == "" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	if _, err := m.Verify(tokens.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("refresh token accepted as access token: %v", err)
+	}
+}
+
+func TestVerifyRejectsForeignTokens(t *testing.T) {
+	m := newManager()
+	sign := func(method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+		s, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := map[string]string{
+		// The 30-day tokens issued before sessions existed
+		"no session":  sign(jwt.SigningMethodHS256, secret, jwt.MapClaims{"user_id": "user-1", "exp": exp}),
+		"wrong key":   sign(jwt.SigningMethodHS256, []byte("other"), jwt.MapClaims{"user_id": "user-1", "sid": "s", "exp": exp}),
+		"wrong alg":   sign(jwt.SigningMethodHS512, secret, jwt.MapClaims{"user_id": "user-1", "sid": "s", "exp": exp}),
+		"expired":     sign(jwt.SigningMethodHS256, secret, jwt.MapClaims{"user_id": "user-1", "sid": "s", "exp": time.Now().Add(-time.Minute).Unix()}),
+		"no user":     sign(jwt.SigningMethodHS256, secret, jwt.MapClaims{"sid": "s", "exp": exp}),
+		"not a token": "garbage",
+	}
+	for name, token := range tests {
+		if _, err := m.Verify(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: got %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestRefreshRotates(t *testing.T) {
+	ctx := context.Background()
+	m := newManager()
+	first, err := m.Issue(ctx, "user-1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := m.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	c1, _ := m.Verify(first.AccessToken)
+	c2, err := m.Verify(second.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c2.UserID != "user-1" || c2.SessionID != c1.SessionID {
+		t.Errorf("rotated claims = %+v, want session %s", c2, c1.SessionID)
+	}
+
+	if _, err := m.Refresh(ctx, second.RefreshToken); err != nil {
+		t.Errorf("refreshing the new token: %v", err)
+	}
+	if _, err := m.Refresh(ctx, "unknown"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("unknown token: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	ctx := context.Background()
+	m := newManager()
+	first, err := m.Issue(ctx, "user-1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := m.Issue(ctx, "user-1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := m.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An attacker replays the stolen first token
+	if _, err := m.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("reuse: got %v, want ErrTokenReused", err)
+	}
+	if _, err := m.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("successor still works after reuse: %v", err)
+	}
+	if _, err := m.Verify(second.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("access token still works after reuse: %v", err)
+	}
+
+	// Other sessions of the same user are unaffected
+	if _, err := m.Verify(other.AccessToken); err != nil {
+		t.Errorf("other session revoked: %v", err)
+	}
+	if _, err := m.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("other session revoked: %v", err)
+	}
+}
+
+func TestRefreshExpired(t *testing.T) {
+	ctx := context.Background()
+	m := newManager()
+	start := time.Now()
+	m.now = func() time.Time { return start }
+	tokens, err := m.Issue(ctx, "user-1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.now = func() time.Time { return start.Add(time.Hour) }
+	if _, err := m.Refresh(ctx, tokens.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expired token: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRevokeSessionAndUser(t *testing.T) {
+	ctx := context.Background()
+	m := newManager()
+	var tokens []Tokens
+	for _, user := range []string{"user-1", "user-1", "user-1", "user-2"} {
+		tt, err := m.Issue(ctx, user, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, tt)
+	}
+
+	claims, err := m.Verify(tokens[0].AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RevokeSession(ctx, claims.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Verify(tokens[0].AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("logged out access token: got %v", err)
+	}
+	if _, err := m.Refresh(ctx, tokens[0].RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("logged out refresh token: got %v", err)
+	}
+	if _, err := m.Verify(tokens[1].AccessToken); err != nil {
+		t.Errorf("logout ended another session: %v", err)
+	}
+
+	if err := m.RevokeUser(ctx, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tokens[1:3] {
+		if _, err := m.Verify(tt.AccessToken); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("access token survived logout-all: %v", err)
+		}
+		if _, err := m.Refresh(ctx, tt.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("refresh token survived logout-all: %v", err)
+		}
+	}
+	if _, err := m.Verify(tokens[3].AccessToken); err != nil {
+		t.Errorf("logout-all ended another user's session: %v", err)
+	}
+}
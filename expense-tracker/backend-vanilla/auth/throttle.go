@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttle limits failed attempts per key, such as an email address or a
+// client IP, within a sliding window.
+type Throttle struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	failures  map[string][]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewThrottle allows limit failures per key in any window.
+func NewThrottle(limit int, window time.Duration) *Throttle {
+	return &Throttle{
+		limit:    limit,
+		window:   window,
+		failures: make(map[string][]time.Time),
+		now:      time.Now,
+	}
+}
+
+// Attempt reserves one of key's failures before an attempt is checked, so
+// that parallel attempts cannot all pass on the same remaining slot. It
+// reports whether the attempt may go ahead, and if not, how long until it
+// may. A successful attempt gives the slot back with Succeed; a failed one
+// leaves it counted.
+func (t *Throttle) Attempt(key string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	// Keys that stop failing are never looked up again, so sweep them out
+	// once per window
+	if now.Sub(t.lastSweep) > t.window {
+		for k := range t.failures {
+			t.recent(k)
+		}
+		t.lastSweep = now
+	}
+	recent := t.recent(key)
+	if len(recent) >= t.limit {
+		return recent[len(recent)-t.limit].Add(t.window).Sub(now), false
+	}
+	t.failures[key] = append(recent, now)
+	return 0, true
+}
+
+// Succeed gives back the slot reserved by the latest Attempt of key.
+func (t *Throttle) Succeed(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if recent := t.recent(key); len(recent) > 0 {
+		t.failures[key] = recent[:len(recent)-1]
+	}
+}
+
+// Reset forgets the failures of key, e.g. after a successful login.
+func (t *Throttle) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.failures, key)
+}
+
+// recent drops failures that left the window. t.mu must be held.
+func (t *Throttle) recent(key string) []time.Time {
+	cutoff := t.now().Add(-t.window)
+	times := t.failures[key]
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	times = times[i:]
+	if len(times) == 0 {
+		delete(t.failures, key)
+		return nil
+	}
+	t.failures[key] = times
+	return times
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	start := time.Now()
+	now := start
+	th := NewThrottle(3, time.Minute)
+	th.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, ok := th.Attempt("ada"); !ok {
+			t.Fatalf("attempt %d blocked", i+1)
+		}
+		now = now.Add(10 * time.Second)
+	}
+
+	wait, ok := th.Attempt("ada")
+	if ok {
+		t.Fatal("fourth attempt allowed")
+	}
+	// The first failure leaves the window 60s after it happened
+	if want := 30 * time.Second; wait != want {
+		t.Errorf("wait = %v, want %v", wait, want)
+	}
+	if _, ok := th.Attempt("bob"); !ok {
+		t.Error("other keys are throttled too")
+	}
+
+	now = now.Add(wait + time.Second)
+	if _, ok := th.Attempt("ada"); !ok {
+		t.Error("still blocked after the oldest failure expired")
+	}
+	// ada now has three failures in the window again; a success gives its
+	// slot back
+	th.Succeed("ada")
+	if _, ok := th.Attempt("ada"); !ok {
+		t.Error("blocked after Succeed")
+	}
+
+	th.Reset("ada")
+	for i := 0; i < 3; i++ {
+		if _, ok := th.Attempt("ada"); !ok {
+			t.Errorf("attempt %d after Reset blocked", i+1)
+		}
+	}
+}
+
+func TestThrottleAttemptIsAtomic(t *testing.T) {
+	th := NewThrottle(5, time.Minute)
+
+	var wg sync.WaitGroup
+	var allowed int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := th.Attempt("ada"); ok {
+				atomic.AddInt32(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 5 {
+		t.Errorf("%d parallel attempts allowed, want 5", allowed)
+	}
+}
+
+func TestRevocationListExpires(t *testing.T) {
+	now := time.Now()
+	l := NewMemoryRevocationList()
+	l.now = func() time.Time { return now }
+
+	l.Revoke("s1", now.Add(time.Minute))
+	if !l.IsRevoked("s1") || l.IsRevoked("s2") {
+		t.Fatal("revocation not recorded")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if l.IsRevoked("s1") {
+		t.Error("revocation outlived the access tokens")
+	}
+	l.Revoke("s2", now.Add(time.Minute))
+	if _, ok := l.revoked["s1"]; ok {
+		t.Error("expired entry not pruned")
+	}
+}
@@ -2,18 +2,15 @@ package config
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-
-	_ "github.com/lib/pq"
-	_ "github.com/go-sql-driver/mysql"
 )
 
 // DatabaseType represents the type of database being used
@@ -23,6 +20,8 @@ const (
 	MongoDB    DatabaseType = "mongodb"
 	PostgreSQL DatabaseType = "postgresql"
 	MySQL      DatabaseType = "mysql"
+	// Memory keeps everything in process; for tests and local demos only
+	Memory DatabaseType = "memory"
 )
 
 // Database interface defines common methods that all database implementations must support
@@ -38,32 +37,51 @@ type MongoDatabase struct {
 	DB     *mongo.Database
 }
 
-// PostgresDB wraps PostgreSQL connection
-type PostgresDB struct {
-	DB *sql.DB
-}
-
-// MySQLDB wraps MySQL connection
-type MySQLDB struct {
-	DB *sql.DB
-}
-
 var (
 	activeDB Database
 	dbType   DatabaseType
 )
 
 // ConnectDB establishes a connection to the specified database type
-func ConnectDB(dbType DatabaseType) (Database, error) {
-	switch dbType {
+func ConnectDB(t DatabaseType) (Database, error) {
+	var (
+		db  Database
+		err error
+	)
+	switch t {
 	case MongoDB:
-		return connectMongoDB()
+		db, err = connectMongoDB()
 	case PostgreSQL:
-		return NewPostgresDB()
+		var pdb *PostgresDB
+		if pdb, err = NewPostgresDB(); err == nil {
+			db, err = pdb, pdb.CreateTables()
+		}
 	case MySQL:
-		return NewMySQLDB()
+		var mdb *MySQLDB
+		if mdb, err = NewMySQLDB(); err == nil {
+			db, err = mdb, mdb.CreateTables()
+		}
 	default:
-		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+		return nil, fmt.Errorf("unsupported database type: %s", t)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	activeDB, dbType = db, t
+	return db, nil
+}
+
+// DatabaseTypeFromEnv reads DB_TYPE, defaulting to MongoDB. "postgres" is
+// accepted as an alias for "postgresql".
+func DatabaseTypeFromEnv() DatabaseType {
+	switch t := DatabaseType(strings.ToLower(os.Getenv("DB_TYPE"))); t {
+	case "":
+		return MongoDB
+	case "postgres":
+		return PostgreSQL
+	default:
+		return t
 	}
 }
 
@@ -111,9 +129,17 @@ func connectMongoDB() (*MongoDatabase, error) {
 func createMongoIndexes(ctx context.Context, db *mongo.Database) error {
 	// User indexes
 	userColl := db.Collection("users")
-	_, err := userColl.Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys:    bson.D{{Key: "email", Value: 1}},
-		Options: options.Index().SetUnique(true),
+	_, err := userColl.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// Social accounts without an email address must not collide
+			Keys: bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"email": bson.M{"$gt": ""}}),
+		},
+		{
+			Keys:    bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
 	})
 	if err != nil {
 		return err
@@ -124,7 +150,7 @@ func createMongoIndexes(ctx context.Context, db *mongo.Database) error {
 	_, err = expenseColl.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{
 			Keys: bson.D{
-				{Key: "userId", Value: 1},
+				{Key: "user_id", Value: 1},
 				{Key: "date", Value: -1},
 			},
 		},
@@ -132,6 +158,28 @@ func createMongoIndexes(ctx context.Context, db *mongo.Database) error {
 			Keys: bson.D{{Key: "category", Value: 1}},
 		},
 	})
+	if err != nil {
+		return err
+	}
+
+	// Refresh token indexes; expired tokens are removed by MongoDB
+	tokenColl := db.Collection("refresh_tokens")
+	_, err = tokenColl.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "family_id", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
 	return err
 }
 
@@ -152,76 +200,12 @@ func (mdb *MongoDatabase) GetType() DatabaseType {
 	return MongoDB
 }
 
-// NewPostgresDB establishes a connection to PostgreSQL
-func NewPostgresDB() (*PostgresDB, error) {
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		os.Getenv("POSTGRES_HOST"),
-		os.Getenv("POSTGRES_PORT"),
-		os.Getenv("POSTGRES_USER"),
-		os.Getenv("POSTGRES_PASSWORD"),
-		os.Getenv("POSTGRES_DB"),
-	)
-
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to PostgreSQL: %v", err)
-	}
-
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping PostgreSQL: %v", err)
-	}
-
-	log.Println("Connected to PostgreSQL")
-
-	return &PostgresDB{DB: db}, nil
-}
-
-// NewMySQLDB establishes a connection to MySQL
-func NewMySQLDB() (*MySQLDB, error) {
-	connStr := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s",
-		os.Getenv("MYSQL_USER"),
-		os.Getenv("MYSQL_PASSWORD"),
-		os.Getenv("MYSQL_HOST"),
-		os.Getenv("MYSQL_PORT"),
-		os.Getenv("MYSQL_DB"),
-	)
-
-	db, err := sql.Open("mysql", connStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MySQL: %v", err)
-	}
-
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping MySQL: %v", err)
-	}
-
-	log.Println("Connected to MySQL")
-
-	return &MySQLDB{DB: db}, nil
-}
-
-// PostgresDB methods
-func (pdb *PostgresDB) Close() error {
-	return pdb.DB.Close()
-}
-
-func (pdb *PostgresDB) Ping() error {
-	return pdb.DB.Ping()
-}
-
+// PostgresDB methods; Close and Ping come from the embedded *sql.DB
 func (pdb *PostgresDB) GetType() DatabaseType {
 	return PostgreSQL
 }
 
-// MySQLDB methods
-func (mdb *MySQLDB) Close() error {
-	return mdb.DB.Close()
-}
-
-func (mdb *MySQLDB) Ping() error {
-	return mdb.DB.Ping()
-}
-
+// MySQLDB methods; Close and Ping come from the embedded *sql.DB
 func (mdb *MySQLDB) GetType() DatabaseType {
 	return MySQL
 }
@@ -55,9 +55,10 @@ func (db *MySQLDB) CreateTables() error {
 	_, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS users (
 			id INT AUTO_INCREMENT PRIMARY KEY,
-			email VARCHAR(255) UNIQUE NOT NULL,
-			password_hash VARCHAR(255) NOT NULL,
+			email VARCHAR(255) UNIQUE,
+			password_hash VARCHAR(255) NOT NULL DEFAULT '',
 			name VARCHAR(255),
+			spending_limit DECIMAL(12,2) NOT NULL DEFAULT 0,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 			INDEX idx_email (email)
@@ -67,6 +68,44 @@ func (db *MySQLDB) CreateTables() error {
 		return fmt.Errorf("error creating users table: %v", err)
 	}
 
+	// Create user_identities table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS user_identities (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			user_id INT NOT NULL,
+			provider VARCHAR(50) NOT NULL,
+			subject VARCHAR(255) NOT NULL,
+			email VARCHAR(255),
+			linked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			UNIQUE KEY uq_provider_subject (provider, subject)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating user_identities table: %v", err)
+	}
+
+	// Create refresh_tokens table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			user_id INT NOT NULL,
+			family_id VARCHAR(64) NOT NULL,
+			token_hash CHAR(64) NOT NULL,
+			expires_at DATETIME(3) NOT NULL,
+			created_at DATETIME(3) NOT NULL,
+			used_at DATETIME(3) NULL,
+			revoked_at DATETIME(3) NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			UNIQUE KEY uq_token_hash (token_hash),
+			INDEX idx_family_id (family_id),
+			INDEX idx_user_id (user_id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating refresh_tokens table: %v", err)
+	}
+
 	// Create categories table
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS categories (
@@ -93,16 +132,17 @@ func (db *MySQLDB) CreateTables() error {
 			id INT AUTO_INCREMENT PRIMARY KEY,
 			user_id INT,
 			category_id INT,
+			category VARCHAR(255) NOT NULL DEFAULT '',
 			amount DECIMAL(10,2) NOT NULL,
 			description TEXT,
-			date DATE NOT NULL,
+			date DATETIME(3) NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
 			FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL,
 			INDEX idx_user_id (user_id),
 			INDEX idx_category_id (category_id),
-			INDEX idx_date (date)
+			INDEX idx_user_date (user_id, date)
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
 	`)
 	if err != nil {
@@ -149,7 +189,3 @@ func (db *MySQLDB) CreateTables() error {
 
 	return nil
 }
-
-func (mdb *MySQLDB) Exec(s string) (any, any) {
-	panic("unimplemented")
-}
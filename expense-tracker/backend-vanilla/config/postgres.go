@@ -53,9 +53,10 @@ func (db *PostgresDB) CreateTables() error {
 	_, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS users (
 			id SERIAL PRIMARY KEY,
-			email VARCHAR(255) UNIQUE NOT NULL,
-			password_hash VARCHAR(255) NOT NULL,
+			email VARCHAR(255) UNIQUE,
+			password_hash VARCHAR(255) NOT NULL DEFAULT '',
 			name VARCHAR(255),
+			spending_limit DECIMAL(12,2) NOT NULL DEFAULT 0,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)
@@ -64,6 +65,49 @@ func (db *PostgresDB) CreateTables() error {
 		return fmt.Errorf("error creating users table: %v", err)
 	}
 
+	// Create user_identities table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS user_identities (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			provider VARCHAR(50) NOT NULL,
+			subject VARCHAR(255) NOT NULL,
+			email VARCHAR(255),
+			linked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (provider, subject)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating user_identities table: %v", err)
+	}
+
+	// Create refresh_tokens table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			family_id VARCHAR(64) NOT NULL,
+			token_hash CHAR(64) NOT NULL UNIQUE,
+			expires_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			used_at TIMESTAMP,
+			revoked_at TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating refresh_tokens table: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens (family_id)`)
+	if err != nil {
+		return fmt.Errorf("error creating refresh_tokens index: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens (user_id)`)
+	if err != nil {
+		return fmt.Errorf("error creating refresh_tokens index: %v", err)
+	}
+
 	// Create categories table
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS categories (
@@ -88,9 +132,10 @@ func (db *PostgresDB) CreateTables() error {
 			id SERIAL PRIMARY KEY,
 			user_id INTEGER REFERENCES users(id),
 			category_id INTEGER REFERENCES categories(id),
+			category VARCHAR(255) NOT NULL DEFAULT '',
 			amount DECIMAL(10,2) NOT NULL,
 			description TEXT,
-			date DATE NOT NULL,
+			date TIMESTAMP NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)
@@ -99,6 +144,11 @@ func (db *PostgresDB) CreateTables() error {
 		return fmt.Errorf("error creating expenses table: %v", err)
 	}
 
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_expenses_user_date ON expenses (user_id, date DESC)`)
+	if err != nil {
+		return fmt.Errorf("error creating expenses index: %v", err)
+	}
+
 	// Create budget_alerts table
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS budget_alerts (
@@ -1,23 +1,34 @@
 package controllers
 
 import (
-	"context"
-	"expense-tracker/backend-vanilla/config"
+	"errors"
+	"expense-tracker/backend-vanilla/auth"
 	"expense-tracker/backend-vanilla/models"
+	"expense-tracker/backend-vanilla/repository"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 	"unicode"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// repos is the storage used by every controller.
+var repos repository.Repositories
+
+// SetRepositories selects the storage backend. It must be called before the
+// router starts.
+func SetRepositories(r repository.Repositories) {
+	repos = r
+}
+
+// normalizeEmail makes email lookups case-insensitive on every backend.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 // Password validation rules
 var passwordRules = struct {
 	MinLength      int
@@ -98,10 +109,10 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	input.Email = normalizeEmail(input.Email)
+
 	// Check if user exists
-	var existingUser models.User
-	err := config.DB.Collection("users").FindOne(context.Background(), bson.M{"email": input.Email}).Decode(&existingUser)
-	if err == nil {
+	if _, err := repos.Users.GetByEmail(c.Request.Context(), input.Email); err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already registered"})
 		return
 	}
@@ -123,16 +134,17 @@ func Register(c *gin.Context) {
 		UpdatedAt:     time.Now(),
 	}
 
-	result, err := config.DB.Collection("users").InsertOne(context.Background(), user)
-	if err != nil {
+	if err := repos.Users.Create(c.Request.Context(), &user); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Email already registered"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
-	user.ID = result.InsertedID.(primitive.ObjectID)
-
 	c.JSON(http.StatusCreated, models.UserResponse{
-		ID:            user.ID.Hex(),
+		ID:            user.ID,
 		Name:          user.Name,
 		Email:         user.Email,
 		SpendingLimit: user.SpendingLimit,
@@ -152,197 +164,27 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	err := config.DB.Collection("users").FindOne(context.Background(), bson.M{"email": input.Email}).Decode(&user)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
-		return
-	}
-
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
-		return
-	}
-
-	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID.Hex(),
-		"exp":     time.Now().Add(time.Hour * 24 * 30).Unix(), // 30 days
-	})
-
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"token": tokenString,
-		"user": models.UserResponse{
-			ID:            user.ID.Hex(),
-			Name:          user.Name,
-			Email:         user.Email,
-			SpendingLimit: user.SpendingLimit,
-			CreatedAt:     user.CreatedAt,
-		},
-	})
-}
-
-func SocialCallback(c *gin.Context) {
-	provider := c.Param("provider")
-	code := c.Query("code")
-	if code == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code required"})
-		return
-	}
-
-	// Handle OAuth token exchange and user info fetching based on provider
-	var userInfo struct {
-		ID    string `json:"id"`
-		Name  string `json:"name"`
-		Email string `json:"email"`
-	}
-
-	// Exchange the authorization code for access token
-	var accessToken string
-	var err error
-
-	switch provider {
-	case "google":
-		accessToken, err = exchangeGoogleCode(code)
-	case "github":
-		accessToken, err = exchangeGithubCode(code)
-	case "twitter":
-		accessToken, err = exchangeTwitterCode(code)
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider"})
+	email := normalizeEmail(input.Email)
+	ip := c.ClientIP()
+	// Each attempt counts as a failure until the password checks out
+	if throttled(c, map[string]*auth.Throttle{email: loginByEmail, ip: loginByIP}) {
 		return
 	}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to exchange authorization code"})
-		return
-	}
-
-	// Fetch user info using the access token
-	switch provider {
-	case "google":
-		userInfo, err = fetchGoogleUserInfo(accessToken)
-	case "github":
-		userInfo, err = fetchGithubUserInfo(accessToken)
-	case "twitter":
-		userInfo, err = fetchTwitterUserInfo(accessToken)
+	user, err := repos.Users.GetByEmail(c.Request.Context(), email)
+	if err == nil {
+		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
 	}
-
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user info"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
+	loginByEmail.Reset(email)
+	loginByIP.Succeed(ip)
 
-	// Find or create user
-	var user models.User
-	err = config.DB.Collection("users").FindOne(context.Background(), bson.M{
-		"provider":    provider,
-		"provider_id": userInfo.ID,
-	}).Decode(&user)
-
-	if err != nil {
-		// Create new user
-		user = models.User{
-			Name:       userInfo.Name,
-			Email:      userInfo.Email,
-			Provider:   provider,
-			ProviderID: userInfo.ID,
-			CreatedAt:  time.Now(),
-			UpdatedAt:  time.Now(),
-		}
-
-		result, err := config.DB.Collection("users").InsertOne(context.Background(), user)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-			return
-		}
-
-		user.ID = result.InsertedID.(primitive.ObjectID)
-	}
-
-	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID.Hex(),
-		"exp":     time.Now().Add(time.Hour * 24 * 30).Unix(), // 30 days
-	})
-
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
+	ttl := auth.ShortRefreshTTL
+	if input.RememberMe {
+		ttl = auth.RefreshTTL
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"token": tokenString,
-		"user": models.UserResponse{
-			ID:            user.ID.Hex(),
-			Name:          user.Name,
-			Email:         user.Email,
-			SpendingLimit: user.SpendingLimit,
-			Provider:      user.Provider,
-			CreatedAt:     user.CreatedAt,
-		},
-	})
-}
-
-// Helper functions for OAuth token exchange
-func exchangeGoogleCode(code string) (string, error) {
-	// TODO: Implement Google OAuth token exchange
-	return "", nil
-}
-
-func exchangeGithubCode(code string) (string, error) {
-	// TODO: Implement GitHub OAuth token exchange
-	return "", nil
-}
-
-func exchangeTwitterCode(code string) (string, error) {
-	// TODO: Implement Twitter OAuth token exchange
-	return "", nil
-}
-
-// Helper functions for fetching user info
-func fetchGoogleUserInfo(accessToken string) (struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
-}, error) {
-	// TODO: Implement Google user info fetching
-	return struct {
-		ID    string `json:"id"`
-		Name  string `json:"name"`
-		Email string `json:"email"`
-	}{}, nil
-}
-
-func fetchGithubUserInfo(accessToken string) (struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
-}, error) {
-	// TODO: Implement GitHub user info fetching
-	return struct {
-		ID    string `json:"id"`
-		Name  string `json:"name"`
-		Email string `json:"email"`
-	}{}, nil
-}
-
-func fetchTwitterUserInfo(accessToken string) (struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
-}, error) {
-	// TODO: Implement Twitter user info fetching
-	return struct {
-		ID    string `json:"id"`
-		Name  string `json:"name"`
-		Email string `json:"email"`
-	}{}, nil
+	startSession(c, *user, ttl, "")
 }
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"expense-tracker/backend-vanilla/auth"
+	"expense-tracker/backend-vanilla/models"
+	"expense-tracker/backend-vanilla/repository"
+)
+
+func TestParallelLoginsAreThrottled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repos := repository.NewMemory()
+	SetRepositories(repos)
+	SetupSessions(auth.NewManager([]byte("test-secret"), repos.Tokens, auth.NewMemoryRevocationList()))
+	loginByEmail = auth.NewThrottle(5, 15*time.Minute)
+	loginByIP = auth.NewThrottle(20, 15*time.Minute)
+
+	// A realistic cost keeps the guesses in the compare at the same time
+	hash, _ := bcrypt.GenerateFromPassword([]byte("Correct-horse1"), bcrypt.DefaultCost)
+	if err := repos.Users.Create(context.Background(), &models.User{Name: "Ada", Email: "ada@example.com", Password: string(hash)}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.POST("/api/auth/login", Login)
+	login := func(password string) int {
+		req := httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(`{"email":"ada@example.com","password":"`+password+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Every guess that gets past the throttle is checked against the
+	// password and answered with 401
+	var mu sync.Mutex
+	codes := map[int]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 15; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code := login("wrong")
+			mu.Lock()
+			codes[code]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if codes[http.StatusUnauthorized] != 5 || codes[http.StatusTooManyRequests] != 10 {
+		t.Errorf("responses = %v, want 5 checked and 10 throttled", codes)
+	}
+
+	// Even the right password waits out the lockout
+	if code := login("Correct-horse1"); code != http.StatusTooManyRequests {
+		t.Errorf("login while locked out = %d", code)
+	}
+	loginByEmail.Reset("ada@example.com")
+	if code := login("Correct-horse1"); code != http.StatusOK {
+		t.Errorf("login = %d", code)
+	}
+}
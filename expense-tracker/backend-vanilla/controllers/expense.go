@@ -1,20 +1,28 @@
 package controllers
 
 import (
-	"context"
-	"expense-tracker/backend-vanilla/config"
+	"errors"
 	"expense-tracker/backend-vanilla/models"
+	"expense-tracker/backend-vanilla/repository"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+func expenseResponse(expense models.Expense) models.ExpenseResponse {
+	return models.ExpenseResponse{
+		ID:          expense.ID,
+		Amount:      expense.Amount,
+		Category:    expense.Category,
+		Description: expense.Description,
+		Date:        expense.Date,
+		CreatedAt:   expense.CreatedAt,
+	}
+}
+
 func CreateExpense(c *gin.Context) {
-	userID := c.MustGet("user_id").(primitive.ObjectID)
+	userID := c.MustGet("user_id").(string)
 
 	var input struct {
 		Amount      float64   `json:"amount" binding:"required"`
@@ -38,26 +46,16 @@ func CreateExpense(c *gin.Context) {
 		UpdatedAt:   time.Now(),
 	}
 
-	result, err := config.DB.Collection("expenses").InsertOne(context.Background(), expense)
-	if err != nil {
+	if err := repos.Expenses.Create(c.Request.Context(), &expense); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create expense"})
 		return
 	}
 
-	expense.ID = result.InsertedID.(primitive.ObjectID)
-
-	c.JSON(http.StatusCreated, models.ExpenseResponse{
-		ID:          expense.ID.Hex(),
-		Amount:      expense.Amount,
-		Category:    expense.Category,
-		Description: expense.Description,
-		Date:        expense.Date,
-		CreatedAt:   expense.CreatedAt,
-	})
+	c.JSON(http.StatusCreated, expenseResponse(expense))
 }
 
 func GetExpenses(c *gin.Context) {
-	userID := c.MustGet("user_id").(primitive.ObjectID)
+	userID := c.MustGet("user_id").(string)
 
 	var query struct {
 		StartDate string `form:"start_date"`
@@ -70,58 +68,29 @@ func GetExpenses(c *gin.Context) {
 		return
 	}
 
-	filter := bson.M{"user_id": userID}
+	filter := repository.ExpenseFilter{UserID: userID, Category: query.Category}
 
 	if query.StartDate != "" && query.EndDate != "" {
-		startDate, _ := time.Parse(time.RFC3339, query.StartDate)
-		endDate, _ := time.Parse(time.RFC3339, query.EndDate)
-		filter["date"] = bson.M{
-			"$gte": startDate,
-			"$lte": endDate,
-		}
-	}
-
-	if query.Category != "" {
-		filter["category"] = query.Category
+		filter.StartDate, _ = time.Parse(time.RFC3339, query.StartDate)
+		filter.EndDate, _ = time.Parse(time.RFC3339, query.EndDate)
 	}
 
-	opts := options.Find().SetSort(bson.D{{Key: "date", Value: -1}})
-
-	cursor, err := config.DB.Collection("expenses").Find(context.Background(), filter, opts)
+	expenses, err := repos.Expenses.List(c.Request.Context(), filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch expenses"})
 		return
 	}
-	defer cursor.Close(context.Background())
-
-	var expenses []models.Expense
-	if err := cursor.All(context.Background(), &expenses); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode expenses"})
-		return
-	}
 
 	response := make([]models.ExpenseResponse, len(expenses))
 	for i, expense := range expenses {
-		response[i] = models.ExpenseResponse{
-			ID:          expense.ID.Hex(),
-			Amount:      expense.Amount,
-			Category:    expense.Category,
-			Description: expense.Description,
-			Date:        expense.Date,
-			CreatedAt:   expense.CreatedAt,
-		}
+		response[i] = expenseResponse(expense)
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
 func UpdateExpense(c *gin.Context) {
-	userID := c.MustGet("user_id").(primitive.ObjectID)
-	expenseID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expense ID"})
-		return
-	}
+	userID := c.MustGet("user_id").(string)
 
 	var input struct {
 		Amount      float64   `json:"amount"`
@@ -135,61 +104,40 @@ func UpdateExpense(c *gin.Context) {
 		return
 	}
 
-	update := bson.M{
-		"$set": bson.M{
-			"amount":      input.Amount,
-			"category":    input.Category,
-			"description": input.Description,
-			"date":        input.Date,
-			"updated_at":  time.Now(),
-		},
+	expense := models.Expense{
+		ID:          c.Param("id"),
+		UserID:      userID,
+		Amount:      input.Amount,
+		Category:    input.Category,
+		Description: input.Description,
+		Date:        input.Date,
+		UpdatedAt:   time.Now(),
 	}
 
-	result := config.DB.Collection("expenses").FindOneAndUpdate(
-		context.Background(),
-		bson.M{"_id": expenseID, "user_id": userID},
-		update,
-		options.FindOneAndUpdate().SetReturnDocument(options.After),
-	)
-
-	var expense models.Expense
-	if err := result.Decode(&expense); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+	if err := repos.Expenses.Update(c.Request.Context(), &expense); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update expense"})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.ExpenseResponse{
-		ID:          expense.ID.Hex(),
-		Amount:      expense.Amount,
-		Category:    expense.Category,
-		Description: expense.Description,
-		Date:        expense.Date,
-		CreatedAt:   expense.CreatedAt,
-	})
+	c.JSON(http.StatusOK, expenseResponse(expense))
 }
 
 func DeleteExpense(c *gin.Context) {
-	userID := c.MustGet("user_id").(primitive.ObjectID)
-	expenseID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expense ID"})
-		return
-	}
-
-	result, err := config.DB.Collection("expenses").DeleteOne(
-		context.Background(),
-		bson.M{"_id": expenseID, "user_id": userID},
-	)
+	userID := c.MustGet("user_id").(string)
 
+	err := repos.Expenses.Delete(c.Request.Context(), userID, c.Param("id"))
 	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete expense"})
 		return
 	}
 
-	if result.DeletedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{"message": "Expense deleted successfully"})
 }
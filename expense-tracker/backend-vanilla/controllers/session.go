@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"errors"
+	"expense-tracker/backend-vanilla/auth"
+	"expense-tracker/backend-vanilla/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	sessions *auth.Manager
+
+	// Failed logins are limited per account, against guessing one user's
+	// password, and per client, against trying one password on many users.
+	loginByEmail = auth.NewThrottle(5, 15*time.Minute)
+	loginByIP    = auth.NewThrottle(20, 15*time.Minute)
+)
+
+// SetupSessions sets the manager issuing login sessions. It must be called
+// before the router starts.
+func SetupSessions(m *auth.Manager) {
+	sessions = m
+}
+
+// startSession signs user in and writes the tokens and profile.
+func startSession(c *gin.Context, user models.User, ttl time.Duration, provider string) {
+	tokens, err := sessions.Issue(c.Request.Context(), user.ID, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user": models.UserResponse{
+			ID:            user.ID,
+			Name:          user.Name,
+			Email:         user.Email,
+			SpendingLimit: user.SpendingLimit,
+			Provider:      provider,
+			CreatedAt:     user.CreatedAt,
+		},
+	})
+}
+
+// throttled reserves an attempt on each of keys. If any is locked out it
+// gives the others back and answers with 429 and Retry-After.
+func throttled(c *gin.Context, keys map[string]*auth.Throttle) bool {
+	var wait time.Duration
+	reserved := make(map[string]*auth.Throttle)
+	for key, t := range keys {
+		if d, ok := t.Attempt(key); !ok {
+			if d > wait {
+				wait = d
+			}
+		} else {
+			reserved[key] = t
+		}
+	}
+	if len(reserved) == len(keys) {
+		return false
+	}
+	for key, t := range reserved {
+		t.Succeed(key)
+	}
+	c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, try again later"})
+	return true
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token.
+func RefreshToken(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := sessions.Refresh(c.Request.Context(), input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout ends the session of the access token used for the request.
+func Logout(c *gin.Context) {
+	if err := sessions.RevokeSession(c.Request.Context(), c.MustGet("session_id").(string)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll ends every session of the signed-in user, on every device.
+func LogoutAll(c *gin.Context) {
+	if err := sessions.RevokeUser(c.Request.Context(), c.MustGet("user_id").(string)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all devices"})
+}
@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"expense-tracker/backend-vanilla/auth"
+	"expense-tracker/backend-vanilla/models"
+	"expense-tracker/backend-vanilla/oauth"
+	"expense-tracker/backend-vanilla/repository"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	oauthProviders                  = map[string]*oauth.Provider{}
+	oauthStates    oauth.StateStore = oauth.NewMemoryStateStore()
+
+	errIdentityInUse = errors.New("this social account is already linked to another user")
+	errEmailInUse    = errors.New("an account with this email already exists; sign in and link this provider from your settings")
+)
+
+// stateCookie holds a hash of the state of the sign-in this browser started.
+const stateCookie = "oauth_state"
+
+// SetupOAuth registers the social login providers. It must be called after
+// the environment is loaded and before the router starts.
+func SetupOAuth(providers map[string]*oauth.Provider, states oauth.StateStore) {
+	oauthProviders = providers
+	if states != nil {
+		oauthStates = states
+	}
+}
+
+// beginSocialAuth remembers a new state, PKCE verifier and nonce, binds the
+// state to the browser with a cookie and returns the provider URL to send the
+// browser to.
+func beginSocialAuth(c *gin.Context, p *oauth.Provider, linkUserID string) (string, error) {
+	state, err := oauth.RandomString(24)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oauth.NewVerifier()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oauth.RandomString(16)
+	if err != nil {
+		return "", err
+	}
+
+	err = oauthStates.Save(state, oauth.PendingAuth{
+		Provider:   p.Name,
+		Verifier:   verifier,
+		Nonce:      nonce,
+		LinkUserID: linkUserID,
+		ExpiresAt:  time.Now().Add(oauth.StateTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	setStateCookie(c, p, hashState(state), int(oauth.StateTTL/time.Second))
+	return p.AuthCodeURL(state, verifier, nonce), nil
+}
+
+// setStateCookie writes the state cookie, scoped to the provider's callback.
+// A negative maxAge clears it.
+func setStateCookie(c *gin.Context, p *oauth.Provider, value string, maxAge int) {
+	path := "/"
+	if u, err := url.Parse(p.RedirectURL); err == nil && u.Path != "" {
+		path = u.Path
+	}
+	// Lax still sends the cookie on the provider's top-level redirect back
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(stateCookie, value, maxAge, path, "", strings.HasPrefix(p.RedirectURL, "https://"), true)
+}
+
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+// SocialLogin redirects the browser to the provider's sign-in page.
+func SocialLogin(c *gin.Context) {
+	p, ok := oauthProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider"})
+		return
+	}
+
+	authURL, err := beginSocialAuth(c, p, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign-in"})
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// LinkSocial starts linking a provider to the signed-in user and returns the
+// URL the client should open.
+func LinkSocial(c *gin.Context) {
+	userID := c.MustGet("user_id").(string)
+	p, ok := oauthProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider"})
+		return
+	}
+
+	authURL, err := beginSocialAuth(c, p, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start linking"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"url": authURL})
+}
+
+func SocialCallback(c *gin.Context) {
+	provider := c.Param("provider")
+	p, ok := oauthProviders[provider]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider"})
+		return
+	}
+
+	if errCode := c.Query("error"); errCode != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign-in was cancelled or denied: " + errCode})
+		return
+	}
+
+	// The state must match the cookie set when this browser started the
+	// sign-in. Otherwise an attacker could send a victim to the callback with
+	// the attacker's own code and state, signing the victim in as the
+	// attacker (login CSRF). The state can only be used once.
+	state := c.Query("state")
+	cookie, err := c.Cookie(stateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(hashState(state))) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired state"})
+		return
+	}
+	setStateCookie(c, p, "", -1)
+
+	pending, ok := oauthStates.Consume(state)
+	if !ok || pending.Provider != provider {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired state"})
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	token, err := p.Exchange(ctx, code, pending.Verifier)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to exchange authorization code"})
+		return
+	}
+
+	identity, err := p.FetchIdentity(ctx, token, pending.Nonce)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to verify identity"})
+		return
+	}
+
+	user, err := resolveSocialUser(ctx, identity, pending.LinkUserID)
+	if err != nil {
+		if errors.Is(err, errIdentityInUse) || errors.Is(err, errEmailInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		return
+	}
+
+	startSession(c, *user, auth.RefreshTTL, identity.Provider)
+}
+
+// resolveSocialUser finds the user for a verified identity. In order:
+// the user who started a link flow, the user already holding the identity,
+// an email/password user with the same verified email, or a new user.
+func resolveSocialUser(ctx context.Context, id oauth.Identity, linkUserID string) (*models.User, error) {
+	email := normalizeEmail(id.Email)
+	identity := models.SocialIdentity{
+		Provider: id.Provider,
+		Subject:  id.Subject,
+		Email:    email,
+		LinkedAt: time.Now(),
+	}
+
+	owner, err := repos.Users.GetByIdentity(ctx, id.Provider, id.Subject)
+	found := err == nil
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	if linkUserID != "" {
+		if found && owner.ID != linkUserID {
+			return nil, errIdentityInUse
+		}
+		if found {
+			return owner, nil
+		}
+		return addIdentity(ctx, linkUserID, identity)
+	}
+	if found {
+		return owner, nil
+	}
+
+	// Only trust an email the provider has verified: otherwise anyone could
+	// claim a victim's address at the provider and take over their account.
+	if email != "" && id.EmailVerified {
+		owner, err := repos.Users.GetByEmail(ctx, email)
+		if err == nil {
+			return addIdentity(ctx, owner.ID, identity)
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	user := &models.User{
+		Name:       id.Name,
+		Email:      email,
+		CreatedAt:  identity.LinkedAt,
+		UpdatedAt:  identity.LinkedAt,
+		Identities: []models.SocialIdentity{identity},
+	}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, errEmailInUse
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func addIdentity(ctx context.Context, userID string, identity models.SocialIdentity) (*models.User, error) {
+	user, err := repos.Users.AddIdentity(ctx, userID, identity)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, errIdentityInUse
+	}
+	return user, err
+}
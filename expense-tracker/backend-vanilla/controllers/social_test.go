@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"expense-tracker/backend-vanilla/auth"
+	"expense-tracker/backend-vanilla/oauth"
+	"expense-tracker/backend-vanilla/oauth/oauthtest"
+	"expense-tracker/backend-vanilla/repository"
+)
+
+func TestSocialCallbackRequiresStateCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := oauthtest.NewServer(oauthtest.User{Subject: "user-1", Email: "ada@example.com", EmailVerified: true, Name: "Ada"})
+	defer srv.Close()
+
+	r := gin.New()
+	r.GET("/api/auth/:provider/login", SocialLogin)
+	r.GET("/api/auth/:provider/callback", SocialCallback)
+	app := httptest.NewServer(r)
+	defer app.Close()
+
+	repos := repository.NewMemory()
+	SetRepositories(repos)
+	SetupSessions(auth.NewManager([]byte("test-secret"), repos.Tokens, auth.NewMemoryRevocationList()))
+	SetupOAuth(map[string]*oauth.Provider{"fake": srv.Provider("fake", app.URL+"/api/auth/fake/callback")}, oauth.NewMemoryStateStore())
+
+	client := app.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	// start begins a sign-in and returns the provider's code and state with
+	// the cookie the browser was given.
+	start := func() (code, state string, cookie *http.Cookie) {
+		resp, err := client.Get(app.URL + "/api/auth/fake/login")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		for _, c := range resp.Cookies() {
+			if c.Name == stateCookie {
+				cookie = c
+			}
+		}
+		if cookie == nil || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/api/auth/fake/callback" {
+			t.Fatalf("state cookie = %+v", cookie)
+		}
+		code, state, err = srv.Authorize(resp.Header.Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code, state, cookie
+	}
+	callback := func(code, state string, cookie *http.Cookie) *http.Response {
+		req, _ := http.NewRequest("GET", app.URL+"/api/auth/fake/callback?"+url.Values{"code": {code}, "state": {state}}.Encode(), nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	code, state, cookie := start()
+	// The attacker's own sign-in, whose callback URL a victim is sent to
+	_, _, other := start()
+
+	if resp := callback(code, state, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("callback without cookie = %d", resp.StatusCode)
+	}
+	if resp := callback(code, state, other); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("callback with another sign-in's cookie = %d", resp.StatusCode)
+	}
+
+	// Rejected callbacks don't use up the state
+	resp := callback(code, state, cookie)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("callback = %d", resp.StatusCode)
+	}
+	var cleared bool
+	for _, c := range resp.Cookies() {
+		cleared = cleared || (c.Name == stateCookie && c.MaxAge < 0)
+	}
+	if !cleared {
+		t.Error("state cookie was not cleared")
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"expense-tracker/backend-vanilla/auth"
 	"expense-tracker/backend-vanilla/config"
 	"expense-tracker/backend-vanilla/controllers"
 	"expense-tracker/backend-vanilla/middleware"
+	"expense-tracker/backend-vanilla/oauth"
+	"expense-tracker/backend-vanilla/repository"
 	"log"
 	"os"
 
@@ -18,8 +21,21 @@ func main() {
 		log.Fatal("Error loading .env file")
 	}
 
-	// Connect to MongoDB
-	config.ConnectDB()
+	// Connect to the database selected by DB_TYPE (mongodb, postgresql,
+	// mysql or memory)
+	repos, err := repository.Open(config.DatabaseTypeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer repos.Close()
+	controllers.SetRepositories(repos)
+
+	// Login sessions: short-lived access tokens and rotating refresh tokens
+	sessions := auth.NewManager([]byte(os.Getenv("JWT_SECRET")), repos.Tokens, auth.NewMemoryRevocationList())
+	controllers.SetupSessions(sessions)
+
+	// Social login providers configured in the environment
+	controllers.SetupOAuth(oauth.ProvidersFromEnv(), oauth.NewMemoryStateStore())
 
 	// Initialize Gin router
 	r := gin.Default()
@@ -33,17 +49,23 @@ func main() {
 	}))
 
 	// Public routes
-	auth := r.Group("/api/auth")
+	public := r.Group("/api/auth")
 	{
-		auth.POST("/register", controllers.Register)
-		auth.POST("/login", controllers.Login)
-		auth.GET("/:provider/callback", controllers.SocialCallback)
+		public.POST("/register", controllers.Register)
+		public.POST("/login", controllers.Login)
+		public.POST("/refresh", controllers.RefreshToken)
+		public.GET("/:provider/login", controllers.SocialLogin)
+		public.GET("/:provider/callback", controllers.SocialCallback)
 	}
 
 	// Protected routes
 	api := r.Group("/api")
-	api.Use(middleware.AuthMiddleware())
+	api.Use(middleware.AuthMiddleware(sessions))
 	{
+		api.POST("/auth/logout", controllers.Logout)
+		api.POST("/auth/logout-all", controllers.LogoutAll)
+		api.POST("/auth/:provider/link", controllers.LinkSocial)
+
 		expenses := api.Group("/expenses")
 		{
 			expenses.POST("", controllers.CreateExpense)
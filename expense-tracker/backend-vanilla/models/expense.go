@@ -2,19 +2,17 @@ package models
 
 import (
 	"time"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Expense struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
-	Amount      float64           `bson:"amount" json:"amount"`
-	Category    string            `bson:"category" json:"category"`
-	Description string            `bson:"description" json:"description"`
-	Date        time.Time         `bson:"date" json:"date"`
-	CreatedAt   time.Time         `bson:"created_at" json:"created_at"`
-	UpdatedAt   time.Time         `bson:"updated_at" json:"updated_at"`
+	ID          string    `json:"id,omitempty"`
+	UserID      string    `json:"user_id"`
+	Amount      float64   `json:"amount"`
+	Category    string    `json:"category"`
+	Description string    `json:"description"`
+	Date        time.Time `json:"date"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 type ExpenseResponse struct {
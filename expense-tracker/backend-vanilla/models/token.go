@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken is one link in a chain of rotated refresh tokens. Every token
+// issued from the same login shares a FamilyID, so a stolen token can be
+// revoked together with everything issued after it. Only a hash of the
+// token itself is stored.
+type RefreshToken struct {
+	ID        string
+	UserID    string
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	// UsedAt is set once the token has been exchanged for its successor.
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
@@ -2,20 +2,26 @@ package models
 
 import (
 	"time"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type User struct {
-	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Name           string            `bson:"name" json:"name"`
-	Email          string            `bson:"email" json:"email"`
-	Password       string            `bson:"password" json:"-"`
-	SpendingLimit  float64          `bson:"spending_limit" json:"spending_limit"`
-	Provider       string            `bson:"provider,omitempty" json:"provider,omitempty"`
-	ProviderID     string            `bson:"provider_id,omitempty" json:"provider_id,omitempty"`
-	CreatedAt      time.Time         `bson:"created_at" json:"created_at"`
-	UpdatedAt      time.Time         `bson:"updated_at" json:"updated_at"`
+	ID            string           `json:"id,omitempty"`
+	Name          string           `json:"name"`
+	Email         string           `json:"email"`
+	Password      string           `json:"-"`
+	SpendingLimit float64          `json:"spending_limit"`
+	Identities    []SocialIdentity `json:"identities,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
+
+// SocialIdentity links a user to their account at an OAuth provider. A user
+// can have several, next to an optional email/password login.
+type SocialIdentity struct {
+	Provider string    `json:"provider"`
+	Subject  string    `json:"-"`
+	Email    string    `json:"email,omitempty"`
+	LinkedAt time.Time `json:"linked_at"`
 }
 
 type UserResponse struct {
@@ -0,0 +1,77 @@
+package oauth
+
+import (
+	"os"
+	"strings"
+)
+
+// defaults holds the public endpoints of the built-in providers.
+var defaults = map[string]Provider{
+	"google": {
+		Kind:        KindOIDC,
+		Scopes:      []string{"openid", "email", "profile"},
+		AuthURL:     "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL:    "https://oauth2.googleapis.com/token",
+		UserInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+		Issuer:      "https://accounts.google.com",
+		JWKSURL:     "https://www.googleapis.com/oauth2/v3/certs",
+	},
+	"github": {
+		Kind:        KindGitHub,
+		Scopes:      []string{"read:user", "user:email"},
+		AuthURL:     "https://github.com/login/oauth/authorize",
+		TokenURL:    "https://github.com/login/oauth/access_token",
+		UserInfoURL: "https://api.github.com/user",
+		EmailsURL:   "https://api.github.com/user/emails",
+	},
+	"twitter": {
+		Kind:        KindTwitter,
+		Scopes:      []string{"users.read", "tweet.read"},
+		AuthStyle:   AuthStyleInHeader,
+		AuthURL:     "https://twitter.com/i/oauth2/authorize",
+		TokenURL:    "https://api.twitter.com/2/oauth2/token",
+		UserInfoURL: "https://api.twitter.com/2/users/me",
+	},
+}
+
+// ProvidersFromEnv configures every built-in provider whose client ID is
+// set. For a provider named "google" it reads GOOGLE_CLIENT_ID and
+// GOOGLE_CLIENT_SECRET, and GOOGLE_AUTH_URL, GOOGLE_TOKEN_URL,
+// GOOGLE_USERINFO_URL, GOOGLE_EMAILS_URL, GOOGLE_ISSUER and GOOGLE_JWKS_URL
+// override the default endpoints. Callbacks go to
+// {OAUTH_REDIRECT_BASE_URL}/{provider}/callback.
+func ProvidersFromEnv() map[string]*Provider {
+	base := strings.TrimRight(os.Getenv("OAUTH_REDIRECT_BASE_URL"), "/")
+	if base == "" {
+		base = "http://localhost:8080/api/auth"
+	}
+
+	providers := make(map[string]*Provider)
+	for name, def := range defaults {
+		prefix := strings.ToUpper(name) + "_"
+		clientID := os.Getenv(prefix + "CLIENT_ID")
+		if clientID == "" {
+			continue
+		}
+
+		p := def
+		p.Name = name
+		p.ClientID = clientID
+		p.ClientSecret = os.Getenv(prefix + "CLIENT_SECRET")
+		p.RedirectURL = base + "/" + name + "/callback"
+		override(&p.AuthURL, prefix+"AUTH_URL")
+		override(&p.TokenURL, prefix+"TOKEN_URL")
+		override(&p.UserInfoURL, prefix+"USERINFO_URL")
+		override(&p.EmailsURL, prefix+"EMAILS_URL")
+		override(&p.Issuer, prefix+"ISSUER")
+		override(&p.JWKSURL, prefix+"JWKS_URL")
+		providers[name] = &p
+	}
+	return providers
+}
+
+func override(field *string, env string) {
+	if v := os.Getenv(env); v != "" {
+		*field = v
+	}
+}
@@ -0,0 +1,319 @@
+// Package oauth implements the OAuth 2.0 authorization-code flow with state
+// and PKCE for social login, including ID-token verification for OpenID
+// Connect providers.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind selects how a provider's user profile is read.
+type Kind string
+
+const (
+	// KindOIDC providers return a signed ID token with the user's claims.
+	KindOIDC Kind = "oidc"
+	// KindGitHub reads the profile and verified emails from the GitHub API.
+	KindGitHub Kind = "github"
+	// KindTwitter reads the profile from the Twitter/X v2 API, which does
+	// not expose email addresses.
+	KindTwitter Kind = "twitter"
+)
+
+// AuthStyle is how client credentials are sent to the token endpoint.
+type AuthStyle int
+
+const (
+	AuthStyleInParams AuthStyle = iota
+	AuthStyleInHeader
+)
+
+// Provider is one configured identity provider. Every endpoint is
+// configurable so tests can point a provider at a local fake server.
+type Provider struct {
+	Name         string
+	Kind         Kind
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	AuthStyle    AuthStyle
+
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	// EmailsURL lists the user's addresses with their verification status
+	// (GitHub only).
+	EmailsURL string
+	// Issuer and JWKSURL are required for OIDC providers.
+	Issuer  string
+	JWKSURL string
+
+	Client *http.Client
+	keys   *keySet
+}
+
+// Token is the token endpoint's response.
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Identity is the provider's view of the user.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// ErrProvider wraps errors returned by a provider's endpoints.
+var ErrProvider = errors.New("identity provider error")
+
+func (p *Provider) httpClient() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
+// AuthCodeURL is where the user is sent to sign in. nonce is only used by
+// OIDC providers.
+func (p *Provider) AuthCodeURL(state, verifier, nonce string) string {
+	v := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"state":                 {state},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	if len(p.Scopes) > 0 {
+		v.Set("scope", strings.Join(p.Scopes, " "))
+	}
+	if p.Kind == KindOIDC && nonce != "" {
+		v.Set("nonce", nonce)
+	}
+
+	sep := "?"
+	if strings.Contains(p.AuthURL, "?") {
+		sep = "&"
+	}
+	return p.AuthURL + sep + v.Encode()
+}
+
+// Exchange trades an authorization code and its PKCE verifier for tokens.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (*Token, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if p.AuthStyle == AuthStyleInParams {
+		form.Set("client_id", p.ClientID)
+		form.Set("client_secret", p.ClientSecret)
+	} else {
+		// Public clients still identify themselves in the body
+		form.Set("client_id", p.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.AuthStyle == AuthStyleInHeader {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	var token Token
+	if err := p.do(req, &token); err != nil {
+		return nil, fmt.Errorf("token exchange: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token exchange: %w: no access token in response", ErrProvider)
+	}
+	return &token, nil
+}
+
+// FetchIdentity returns the signed-in user. For OIDC providers the ID token
+// is verified against the provider's keys, issuer, client ID and nonce.
+func (p *Provider) FetchIdentity(ctx context.Context, token *Token, nonce string) (Identity, error) {
+	switch p.Kind {
+	case KindOIDC:
+		return p.oidcIdentity(ctx, token, nonce)
+	case KindGitHub:
+		return p.githubIdentity(ctx, token)
+	case KindTwitter:
+		return p.twitterIdentity(ctx, token)
+	}
+	return Identity{}, fmt.Errorf("unsupported provider kind %q", p.Kind)
+}
+
+func (p *Provider) oidcIdentity(ctx context.Context, token *Token, nonce string) (Identity, error) {
+	if token.IDToken == "" {
+		return Identity{}, fmt.Errorf("%w: no id_token in response", ErrProvider)
+	}
+	claims, err := p.VerifyIDToken(ctx, token.IDToken, nonce)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	id := Identity{
+		Provider:      p.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}
+	if id.Email != "" || p.UserInfoURL == "" {
+		return id, nil
+	}
+
+	// Some providers leave profile claims out of the ID token
+	var info struct {
+		Sub           string      `json:"sub"`
+		Email         string      `json:"email"`
+		EmailVerified interface{} `json:"email_verified"`
+		Name          string      `json:"name"`
+	}
+	if err := p.getJSON(ctx, p.UserInfoURL, token.AccessToken, &info); err != nil {
+		return Identity{}, fmt.Errorf("userinfo: %w", err)
+	}
+	if info.Sub != id.Subject {
+		return Identity{}, fmt.Errorf("%w: userinfo subject does not match id token", ErrProvider)
+	}
+	id.Email, id.EmailVerified = info.Email, truthy(info.EmailVerified)
+	if id.Name == "" {
+		id.Name = info.Name
+	}
+	return id, nil
+}
+
+func (p *Provider) githubIdentity(ctx context.Context, token *Token) (Identity, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.getJSON(ctx, p.UserInfoURL, token.AccessToken, &user); err != nil {
+		return Identity{}, fmt.Errorf("github user: %w", err)
+	}
+	if user.ID == 0 {
+		return Identity{}, fmt.Errorf("%w: github user has no id", ErrProvider)
+	}
+
+	id := Identity{
+		Provider: p.Name,
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Name:     user.Name,
+	}
+	if id.Name == "" {
+		id.Name = user.Login
+	}
+
+	// The profile email is whatever the user made public and says nothing
+	// about verification, so use the primary verified address instead.
+	if p.EmailsURL != "" {
+		var emails []struct {
+			Email    string `json:"email"`
+			Primary  bool   `json:"primary"`
+			Verified bool   `json:"verified"`
+		}
+		if err := p.getJSON(ctx, p.EmailsURL, token.AccessToken, &emails); err != nil {
+			return Identity{}, fmt.Errorf("github emails: %w", err)
+		}
+		for _, e := range emails {
+			if e.Primary {
+				id.Email, id.EmailVerified = e.Email, e.Verified
+				break
+			}
+		}
+	}
+	return id, nil
+}
+
+func (p *Provider) twitterIdentity(ctx context.Context, token *Token) (Identity, error) {
+	var resp struct {
+		Data struct {
+			ID       string `json:"id"`
+			Name     string `json:"name"`
+			Username string `json:"username"`
+		} `json:"data"`
+	}
+	if err := p.getJSON(ctx, p.UserInfoURL, token.AccessToken, &resp); err != nil {
+		return Identity{}, fmt.Errorf("twitter user: %w", err)
+	}
+	if resp.Data.ID == "" {
+		return Identity{}, fmt.Errorf("%w: twitter user has no id", ErrProvider)
+	}
+
+	name := resp.Data.Name
+	if name == "" {
+		name = resp.Data.Username
+	}
+	return Identity{Provider: p.Name, Subject: resp.Data.ID, Name: name}, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return p.do(req, out)
+}
+
+func (p *Provider) do(req *http.Request, out interface{}) error {
+	resp, err := p.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s: %s", ErrProvider, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	// GitHub reports token errors with a 200 and an error field
+	var oauthErr struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error != "" {
+		return fmt.Errorf("%w: %s: %s", ErrProvider, oauthErr.Error, oauthErr.Description)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// truthy reads boolean claims that some providers send as strings.
+func truthy(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		return b == "true"
+	}
+	return false
+}
@@ -0,0 +1,233 @@
+package oauth_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"expense-tracker/backend-vanilla/oauth"
+	"expense-tracker/backend-vanilla/oauth/oauthtest"
+)
+
+const redirectURL = "http://localhost:8080/api/auth/fake/callback"
+
+func TestVerifierAndChallenge(t *testing.T) {
+	verifier, err := oauth.NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// RFC 7636 requires 43-128 characters from the unreserved set
+	if len(verifier) != 43 || strings.Trim(verifier, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-._~") != "" {
+		t.Errorf("invalid verifier %q", verifier)
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	if got, want := oauth.Challenge(verifier), base64.RawURLEncoding.EncodeToString(sum[:]); got != want {
+		t.Errorf("Challenge = %s, want %s", got, want)
+	}
+}
+
+func TestAuthorizationCodeFlowWithPKCE(t *testing.T) {
+	srv := oauthtest.NewServer(oauthtest.User{Subject: "user-1", Email: "ada@example.com", EmailVerified: true, Name: "Ada"})
+	defer srv.Close()
+	p := srv.Provider("fake", redirectURL)
+	ctx := context.Background()
+
+	verifier, _ := oauth.NewVerifier()
+	code, state, err := srv.Authorize(p.AuthCodeURL("state-1", verifier, "nonce-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != "state-1" || code == "" {
+		t.Fatalf("callback got code=%q state=%q", code, state)
+	}
+
+	token, err := p.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	id, err := p.FetchIdentity(ctx, token, "nonce-1")
+	if err != nil {
+		t.Fatalf("FetchIdentity: %v", err)
+	}
+	want := oauth.Identity{Provider: "fake", Subject: "user-1", Email: "ada@example.com", EmailVerified: true, Name: "Ada"}
+	if id != want {
+		t.Errorf("identity = %+v, want %+v", id, want)
+	}
+
+	// Codes are single use
+	if _, err := p.Exchange(ctx, code, verifier); err == nil {
+		t.Error("expected a reused code to be rejected")
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	srv := oauthtest.NewServer(oauthtest.User{Subject: "user-1"})
+	defer srv.Close()
+	p := srv.Provider("fake", redirectURL)
+
+	verifier, _ := oauth.NewVerifier()
+	code, _, err := srv.Authorize(p.AuthCodeURL("s", verifier, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := oauth.NewVerifier()
+	if _, err := p.Exchange(context.Background(), code, other); err == nil {
+		t.Error("expected the token endpoint to reject a mismatched PKCE verifier")
+	}
+}
+
+func TestVerifyIDTokenRejectsBadTokens(t *testing.T) {
+	srv := oauthtest.NewServer(oauthtest.User{Subject: "user-1"})
+	defer srv.Close()
+	p := srv.Provider("fake", redirectURL)
+	ctx := context.Background()
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   srv.URL,
+			"aud":   oauthtest.ClientID,
+			"sub":   "user-1",
+			"nonce": "n",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	if _, err := p.VerifyIDToken(ctx, srv.SignIDToken(valid()), "n"); err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+
+	tests := map[string]func(jwt.MapClaims){
+		"wrong issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example" },
+		"wrong audience": func(c jwt.MapClaims) { c["aud"] = []string{"someone-else"} },
+		"wrong nonce":    func(c jwt.MapClaims) { c["nonce"] = "other" },
+		"expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"no subject":     func(c jwt.MapClaims) { delete(c, "sub") },
+	}
+	for name, mutate := range tests {
+		claims := valid()
+		mutate(claims)
+		if _, err := p.VerifyIDToken(ctx, srv.SignIDToken(claims), "n"); err == nil {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+
+	// Signed by a key the provider never published
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, valid())
+	raw, _ := forged.SignedString([]byte("secret"))
+	if _, err := p.VerifyIDToken(ctx, raw, "n"); err == nil {
+		t.Error("HS256 token accepted")
+	}
+}
+
+// Run with -race: the first verifications on a fresh provider all create
+// its key cache at once.
+func TestVerifyIDTokenConcurrently(t *testing.T) {
+	srv := oauthtest.NewServer(oauthtest.User{Subject: "user-1"})
+	defer srv.Close()
+	p := srv.Provider("fake", redirectURL)
+	raw := srv.SignIDToken(jwt.MapClaims{
+		"iss":   srv.URL,
+		"aud":   oauthtest.ClientID,
+		"sub":   "user-1",
+		"nonce": "n",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.VerifyIDToken(context.Background(), raw, "n"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestGitHubIdentityUsesPrimaryVerifiedEmail(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("client_secret") != "secret" || r.PostForm.Get("code_verifier") == "" {
+			json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "gho_x", "token_type": "bearer"})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gho_x" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"id": 42, "login": "octocat", "name": ""}`))
+	})
+	mux.HandleFunc("/emails", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"email":"old@example.com","primary":false,"verified":true},{"email":"cat@example.com","primary":true,"verified":true}]`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p := &oauth.Provider{
+		Name: "github", Kind: oauth.KindGitHub, ClientID: "id", ClientSecret: "secret",
+		TokenURL: srv.URL + "/token", UserInfoURL: srv.URL + "/user", EmailsURL: srv.URL + "/emails",
+	}
+	ctx := context.Background()
+	token, err := p.Exchange(ctx, "code", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := p.FetchIdentity(ctx, token, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Subject != "42" || id.Name != "octocat" || id.Email != "cat@example.com" || !id.EmailVerified {
+		t.Errorf("unexpected identity %+v", id)
+	}
+
+	p.ClientSecret = "wrong"
+	if _, err := p.Exchange(ctx, "code", "verifier"); err == nil || !strings.Contains(err.Error(), "bad_verification_code") {
+		t.Errorf("expected GitHub's 200-with-error response to fail, got %v", err)
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	p := &oauth.Provider{Name: "google", Kind: oauth.KindOIDC, ClientID: "cid", RedirectURL: redirectURL,
+		Scopes: []string{"openid", "email"}, AuthURL: "https://accounts.example/auth"}
+	u, err := url.Parse(p.AuthCodeURL("st", "verifier", "no"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("state") != "st" || q.Get("nonce") != "no" || q.Get("scope") != "openid email" ||
+		q.Get("code_challenge") != oauth.Challenge("verifier") || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("unexpected query %v", q)
+	}
+}
+
+func TestMemoryStateStoreIsSingleUse(t *testing.T) {
+	s := oauth.NewMemoryStateStore()
+	s.Save("a", oauth.PendingAuth{Provider: "google", ExpiresAt: time.Now().Add(time.Minute)})
+	s.Save("b", oauth.PendingAuth{Provider: "google", ExpiresAt: time.Now().Add(-time.Minute)})
+
+	if _, ok := s.Consume("a"); !ok {
+		t.Error("fresh state not found")
+	}
+	if _, ok := s.Consume("a"); ok {
+		t.Error("state consumed twice")
+	}
+	if _, ok := s.Consume("b"); ok {
+		t.Error("expired state accepted")
+	}
+}
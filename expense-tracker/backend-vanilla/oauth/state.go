@@ -0,0 +1,69 @@
+package oauth
+
+import (
+	"sync"
+	"time"
+)
+
+// StateTTL is how long a user has to complete the provider's consent screen.
+const StateTTL = 10 * time.Minute
+
+// PendingAuth is what the server remembers between redirecting a user to a
+// provider and handling the callback, keyed by the random state parameter.
+type PendingAuth struct {
+	Provider string
+	Verifier string
+	Nonce    string
+	// LinkUserID is set when a signed-in user is linking a new identity to
+	// their account rather than signing in.
+	LinkUserID string
+	ExpiresAt  time.Time
+}
+
+// StateStore keeps pending authorizations. Consume must remove the entry so
+// a state value can be used only once.
+type StateStore interface {
+	Save(state string, auth PendingAuth) error
+	Consume(state string) (PendingAuth, bool)
+}
+
+// MemoryStateStore is a StateStore for a single server instance.
+type MemoryStateStore struct {
+	mu      sync.Mutex
+	pending map[string]PendingAuth
+	now     func() time.Time
+}
+
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		pending: make(map[string]PendingAuth),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStateStore) Save(state string, auth PendingAuth) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop abandoned logins so the map cannot grow without bound
+	now := s.now()
+	for k, v := range s.pending {
+		if now.After(v.ExpiresAt) {
+			delete(s.pending, k)
+		}
+	}
+	s.pending[state] = auth
+	return nil
+}
+
+func (s *MemoryStateStore) Consume(state string) (PendingAuth, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	auth, ok := s.pending[state]
+	delete(s.pending, state)
+	if !ok || s.now().After(auth.ExpiresAt) {
+		return PendingAuth{}, false
+	}
+	return auth, true
+}
@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"expense-tracker/backend-vanilla/models"
+)
+
+// memoryStore keeps everything in maps guarded by one lock. IDs are
+// sequential numbers, like the SQL backends.
+type memoryStore struct {
+	mu       sync.RWMutex
+	nextID   int
+	users    map[string]models.User
+	expenses map[string]models.Expense
+	tokens   map[string]models.RefreshToken
+}
+
+type memoryUsers struct{ s *memoryStore }
+type memoryExpenses struct{ s *memoryStore }
+type memoryTokens struct{ s *memoryStore }
+
+// NewMemory returns repositories that keep data in process memory.
+func NewMemory() Repositories {
+	s := &memoryStore{
+		users:    make(map[string]models.User),
+		expenses: make(map[string]models.Expense),
+		tokens:   make(map[string]models.RefreshToken),
+	}
+	return Repositories{
+		Users:    memoryUsers{s},
+		Expenses: memoryExpenses{s},
+		Tokens:   memoryTokens{s},
+		Close:    func() error { return nil },
+	}
+}
+
+func (s *memoryStore) newID() string {
+	s.nextID++
+	return strconv.Itoa(s.nextID)
+}
+
+// copyUser detaches the identities slice so callers cannot mutate the store.
+func copyUser(u models.User) *models.User {
+	u.Identities = append([]models.SocialIdentity(nil), u.Identities...)
+	return &u
+}
+
+func (r memoryUsers) Create(_ context.Context, user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, u := range r.s.users {
+		if user.Email != "" && u.Email == user.Email {
+			return ErrDuplicate
+		}
+		for _, id := range user.Identities {
+			if hasIdentity(u, id.Provider, id.Subject) {
+				return ErrDuplicate
+			}
+		}
+	}
+	user.ID = r.s.newID()
+	r.s.users[user.ID] = *copyUser(*user)
+	return nil
+}
+
+func (r memoryUsers) GetByID(_ context.Context, id string) (*models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	u, ok := r.s.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyUser(u), nil
+}
+
+func (r memoryUsers) GetByEmail(_ context.Context, email string) (*models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, u := range r.s.users {
+		if email != "" && u.Email == email {
+			return copyUser(u), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryUsers) GetByIdentity(_ context.Context, provider, subject string) (*models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, u := range r.s.users {
+		if hasIdentity(u, provider, subject) {
+			return copyUser(u), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryUsers) AddIdentity(_ context.Context, userID string, identity models.SocialIdentity) (*models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	u, ok := r.s.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	for _, other := range r.s.users {
+		if hasIdentity(other, identity.Provider, identity.Subject) {
+			return nil, ErrDuplicate
+		}
+	}
+	u.Identities = append(u.Identities, identity)
+	u.UpdatedAt = identity.LinkedAt
+	r.s.users[userID] = u
+	return copyUser(u), nil
+}
+
+func hasIdentity(u models.User, provider, subject string) bool {
+	for _, id := range u.Identities {
+		if id.Provider == provider && id.Subject == subject {
+			return true
+		}
+	}
+	return false
+}
+
+func (r memoryExpenses) Create(_ context.Context, expense *models.Expense) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	expense.ID = r.s.newID()
+	r.s.expenses[expense.ID] = *expense
+	return nil
+}
+
+func (r memoryExpenses) List(_ context.Context, f ExpenseFilter) ([]models.Expense, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	expenses := []models.Expense{}
+	for _, e := range r.s.expenses {
+		if e.UserID != f.UserID ||
+			(f.Category != "" && e.Category != f.Category) ||
+			(!f.StartDate.IsZero() && e.Date.Before(f.StartDate)) ||
+			(!f.EndDate.IsZero() && e.Date.After(f.EndDate)) {
+			continue
+		}
+		expenses = append(expenses, e)
+	}
+	sort.Slice(expenses, func(i, j int) bool { return expenses[i].Date.After(expenses[j].Date) })
+	return expenses, nil
+}
+
+func (r memoryExpenses) Update(_ context.Context, expense *models.Expense) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.expenses[expense.ID]
+	if !ok || stored.UserID != expense.UserID {
+		return ErrNotFound
+	}
+	stored.Amount = expense.Amount
+	stored.Category = expense.Category
+	stored.Description = expense.Description
+	stored.Date = expense.Date
+	stored.UpdatedAt = expense.UpdatedAt
+	r.s.expenses[expense.ID] = stored
+	*expense = stored
+	return nil
+}
+
+func (r memoryExpenses) Delete(_ context.Context, userID, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.expenses[id]
+	if !ok || stored.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.s.expenses, id)
+	return nil
+}
+
+func (r memoryTokens) Create(_ context.Context, token *models.RefreshToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, t := range r.s.tokens {
+		if t.TokenHash == token.TokenHash {
+			return ErrDuplicate
+		}
+	}
+	token.ID = r.s.newID()
+	r.s.tokens[token.ID] = *token
+	return nil
+}
+
+func (r memoryTokens) GetByHash(_ context.Context, hash string) (*models.RefreshToken, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, t := range r.s.tokens {
+		if t.TokenHash == hash {
+			return &t, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryTokens) MarkUsed(_ context.Context, id string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t, ok := r.s.tokens[id]
+	if !ok || t.UsedAt != nil || t.RevokedAt != nil {
+		return ErrNotFound
+	}
+	t.UsedAt = &at
+	r.s.tokens[id] = t
+	return nil
+}
+
+func (r memoryTokens) RevokeFamily(_ context.Context, familyID string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, t := range r.s.tokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &at
+			r.s.tokens[id] = t
+		}
+	}
+	return nil
+}
+
+func (r memoryTokens) RevokeUser(_ context.Context, userID string, at time.Time) ([]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	seen := make(map[string]bool)
+	families := []string{}
+	for id, t := range r.s.tokens {
+		if t.UserID != userID || t.RevokedAt != nil {
+			continue
+		}
+		if t.ExpiresAt.After(at) && !seen[t.FamilyID] {
+			seen[t.FamilyID] = true
+			families = append(families, t.FamilyID)
+		}
+		t.RevokedAt = &at
+		r.s.tokens[id] = t
+	}
+	return families, nil
+}
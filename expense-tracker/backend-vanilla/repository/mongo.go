@@ -0,0 +1,408 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"expense-tracker/backend-vanilla/models"
+)
+
+type mongoUsers struct{ coll *mongo.Collection }
+type mongoExpenses struct{ coll *mongo.Collection }
+type mongoTokens struct{ coll *mongo.Collection }
+
+// NewMongo returns repositories backed by the users, expenses and
+// refresh_tokens collections of db.
+func NewMongo(db *mongo.Database) Repositories {
+	return Repositories{
+		Users:    mongoUsers{db.Collection("users")},
+		Expenses: mongoExpenses{db.Collection("expenses")},
+		Tokens:   mongoTokens{db.Collection("refresh_tokens")},
+		Close: func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			return db.Client().Disconnect(ctx)
+		},
+	}
+}
+
+type userDoc struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	Name          string             `bson:"name"`
+	Email         string             `bson:"email"`
+	Password      string             `bson:"password"`
+	SpendingLimit float64            `bson:"spending_limit"`
+	// Provider and ProviderID are how social users were stored before
+	// identities were introduced.
+	Provider   string        `bson:"provider,omitempty"`
+	ProviderID string        `bson:"provider_id,omitempty"`
+	Identities []identityDoc `bson:"identities,omitempty"`
+	CreatedAt  time.Time     `bson:"created_at"`
+	UpdatedAt  time.Time     `bson:"updated_at"`
+}
+
+type identityDoc struct {
+	Provider string    `bson:"provider"`
+	Subject  string    `bson:"subject"`
+	Email    string    `bson:"email,omitempty"`
+	LinkedAt time.Time `bson:"linked_at"`
+}
+
+func (d userDoc) model() *models.User {
+	u := &models.User{
+		ID:            d.ID.Hex(),
+		Name:          d.Name,
+		Email:         d.Email,
+		Password:      d.Password,
+		SpendingLimit: d.SpendingLimit,
+		CreatedAt:     d.CreatedAt,
+		UpdatedAt:     d.UpdatedAt,
+	}
+	if d.Provider != "" {
+		u.Identities = append(u.Identities, models.SocialIdentity{Provider: d.Provider, Subject: d.ProviderID, LinkedAt: d.CreatedAt})
+	}
+	for _, id := range d.Identities {
+		u.Identities = append(u.Identities, models.SocialIdentity(id))
+	}
+	return u
+}
+
+func (r mongoUsers) Create(ctx context.Context, user *models.User) error {
+	doc := userDoc{
+		Name:          user.Name,
+		Email:         user.Email,
+		Password:      user.Password,
+		SpendingLimit: user.SpendingLimit,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+	for _, id := range user.Identities {
+		// The unique index on identities only covers other documents
+		if _, err := r.GetByIdentity(ctx, id.Provider, id.Subject); err == nil {
+			return ErrDuplicate
+		}
+		doc.Identities = append(doc.Identities, identityDoc(id))
+	}
+
+	result, err := r.coll.InsertOne(ctx, doc)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID).Hex()
+	return nil
+}
+
+func (r mongoUsers) findOne(ctx context.Context, filter bson.M) (*models.User, error) {
+	var doc userDoc
+	if err := r.coll.FindOne(ctx, filter).Decode(&doc); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return doc.model(), nil
+}
+
+func (r mongoUsers) GetByID(ctx context.Context, id string) (*models.User, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return r.findOne(ctx, bson.M{"_id": oid})
+}
+
+func (r mongoUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	if email == "" {
+		return nil, ErrNotFound
+	}
+	return r.findOne(ctx, bson.M{"email": email})
+}
+
+func (r mongoUsers) GetByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"$or": []bson.M{
+		{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}},
+		{"provider": provider, "provider_id": subject},
+	}})
+}
+
+func (r mongoUsers) AddIdentity(ctx context.Context, userID string, identity models.SocialIdentity) (*models.User, error) {
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if _, err := r.GetByIdentity(ctx, identity.Provider, identity.Subject); err == nil {
+		return nil, ErrDuplicate
+	}
+
+	var doc userDoc
+	err = r.coll.FindOneAndUpdate(ctx,
+		bson.M{"_id": oid},
+		bson.M{
+			"$push": bson.M{"identities": identityDoc(identity)},
+			"$set":  bson.M{"updated_at": identity.LinkedAt},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicate
+		}
+		return nil, err
+	}
+	return doc.model(), nil
+}
+
+type expenseDoc struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	UserID      primitive.ObjectID `bson:"user_id"`
+	Amount      float64            `bson:"amount"`
+	Category    string             `bson:"category"`
+	Description string             `bson:"description"`
+	Date        time.Time          `bson:"date"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at"`
+}
+
+func (d expenseDoc) model() models.Expense {
+	return models.Expense{
+		ID:          d.ID.Hex(),
+		UserID:      d.UserID.Hex(),
+		Amount:      d.Amount,
+		Category:    d.Category,
+		Description: d.Description,
+		Date:        d.Date,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+	}
+}
+
+func (r mongoExpenses) Create(ctx context.Context, expense *models.Expense) error {
+	userID, err := primitive.ObjectIDFromHex(expense.UserID)
+	if err != nil {
+		return ErrNotFound
+	}
+	result, err := r.coll.InsertOne(ctx, expenseDoc{
+		UserID:      userID,
+		Amount:      expense.Amount,
+		Category:    expense.Category,
+		Description: expense.Description,
+		Date:        expense.Date,
+		CreatedAt:   expense.CreatedAt,
+		UpdatedAt:   expense.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	expense.ID = result.InsertedID.(primitive.ObjectID).Hex()
+	return nil
+}
+
+func (r mongoExpenses) List(ctx context.Context, f ExpenseFilter) ([]models.Expense, error) {
+	userID, err := primitive.ObjectIDFromHex(f.UserID)
+	if err != nil {
+		return []models.Expense{}, nil
+	}
+
+	filter := bson.M{"user_id": userID}
+	date := bson.M{}
+	if !f.StartDate.IsZero() {
+		date["$gte"] = f.StartDate
+	}
+	if !f.EndDate.IsZero() {
+		date["$lte"] = f.EndDate
+	}
+	if len(date) > 0 {
+		filter["date"] = date
+	}
+	if f.Category != "" {
+		filter["category"] = f.Category
+	}
+
+	cursor, err := r.coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "date", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []expenseDoc
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	expenses := make([]models.Expense, len(docs))
+	for i, d := range docs {
+		expenses[i] = d.model()
+	}
+	return expenses, nil
+}
+
+func (r mongoExpenses) scope(userID, id string) (bson.M, bool) {
+	uid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, false
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, false
+	}
+	return bson.M{"_id": oid, "user_id": uid}, true
+}
+
+func (r mongoExpenses) Update(ctx context.Context, expense *models.Expense) error {
+	filter, ok := r.scope(expense.UserID, expense.ID)
+	if !ok {
+		return ErrNotFound
+	}
+
+	var doc expenseDoc
+	err := r.coll.FindOneAndUpdate(ctx, filter,
+		bson.M{"$set": bson.M{
+			"amount":      expense.Amount,
+			"category":    expense.Category,
+			"description": expense.Description,
+			"date":        expense.Date,
+			"updated_at":  expense.UpdatedAt,
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ErrNotFound
+		}
+		return err
+	}
+	*expense = doc.model()
+	return nil
+}
+
+func (r mongoExpenses) Delete(ctx context.Context, userID, id string) error {
+	filter, ok := r.scope(userID, id)
+	if !ok {
+		return ErrNotFound
+	}
+	result, err := r.coll.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type tokenDoc struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	FamilyID  string             `bson:"family_id"`
+	TokenHash string             `bson:"token_hash"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at"`
+	UsedAt    *time.Time         `bson:"used_at"`
+	RevokedAt *time.Time         `bson:"revoked_at"`
+}
+
+func (d tokenDoc) model() *models.RefreshToken {
+	return &models.RefreshToken{
+		ID:        d.ID.Hex(),
+		UserID:    d.UserID.Hex(),
+		FamilyID:  d.FamilyID,
+		TokenHash: d.TokenHash,
+		ExpiresAt: d.ExpiresAt,
+		CreatedAt: d.CreatedAt,
+		UsedAt:    d.UsedAt,
+		RevokedAt: d.RevokedAt,
+	}
+}
+
+func (r mongoTokens) Create(ctx context.Context, token *models.RefreshToken) error {
+	uid, err := primitive.ObjectIDFromHex(token.UserID)
+	if err != nil {
+		return ErrNotFound
+	}
+	result, err := r.coll.InsertOne(ctx, tokenDoc{
+		UserID:    uid,
+		FamilyID:  token.FamilyID,
+		TokenHash: token.TokenHash,
+		ExpiresAt: token.ExpiresAt,
+		CreatedAt: token.CreatedAt,
+	})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	token.ID = result.InsertedID.(primitive.ObjectID).Hex()
+	return nil
+}
+
+func (r mongoTokens) GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var doc tokenDoc
+	if err := r.coll.FindOne(ctx, bson.M{"token_hash": hash}).Decode(&doc); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return doc.model(), nil
+}
+
+func (r mongoTokens) MarkUsed(ctx context.Context, id string, at time.Time) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	result, err := r.coll.UpdateOne(ctx,
+		bson.M{"_id": oid, "used_at": nil, "revoked_at": nil},
+		bson.M{"$set": bson.M{"used_at": at}})
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r mongoTokens) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	_, err := r.coll.UpdateMany(ctx,
+		bson.M{"family_id": familyID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": at}})
+	return err
+}
+
+func (r mongoTokens) RevokeUser(ctx context.Context, userID string, at time.Time) ([]string, error) {
+	uid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return []string{}, nil
+	}
+	active, err := r.coll.Distinct(ctx, "family_id",
+		bson.M{"user_id": uid, "revoked_at": nil, "expires_at": bson.M{"$gt": at}})
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.coll.UpdateMany(ctx,
+		bson.M{"user_id": uid, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": at}})
+	if err != nil {
+		return nil, err
+	}
+
+	families := make([]string, 0, len(active))
+	for _, f := range active {
+		if s, ok := f.(string); ok {
+			families = append(families, s)
+		}
+	}
+	return families, nil
+}
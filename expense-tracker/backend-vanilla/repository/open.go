@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"fmt"
+
+	"expense-tracker/backend-vanilla/config"
+)
+
+// Open connects to the database of type t and returns its repositories.
+func Open(t config.DatabaseType) (Repositories, error) {
+	if t == config.Memory {
+		return NewMemory(), nil
+	}
+
+	db, err := config.ConnectDB(t)
+	if err != nil {
+		return Repositories{}, err
+	}
+	switch d := db.(type) {
+	case *config.MongoDatabase:
+		return NewMongo(d.DB), nil
+	case *config.PostgresDB:
+		return NewSQL(d.DB, Postgres), nil
+	case *config.MySQLDB:
+		return NewSQL(d.DB, MySQL), nil
+	}
+	db.Close()
+	return Repositories{}, fmt.Errorf("no repositories for database type %s", t)
+}
@@ -0,0 +1,82 @@
+// Package repository is the storage layer of the expense tracker. Every
+// supported database implements the same interfaces, so controllers never
+// depend on a particular engine.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"expense-tracker/backend-vanilla/models"
+)
+
+var (
+	// ErrNotFound is returned when a record does not exist or belongs to
+	// another user.
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a unique field, such as a user's email
+	// or a social identity, is already taken.
+	ErrDuplicate = errors.New("record already exists")
+)
+
+// UserRepository stores users and their linked social identities.
+type UserRepository interface {
+	// Create stores a new user with its identities and sets user.ID.
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	// GetByIdentity finds the user a provider account is linked to.
+	GetByIdentity(ctx context.Context, provider, subject string) (*models.User, error)
+	// AddIdentity links a provider account to an existing user and returns
+	// the updated user.
+	AddIdentity(ctx context.Context, userID string, identity models.SocialIdentity) (*models.User, error)
+}
+
+// ExpenseFilter narrows ExpenseRepository.List. Zero values are ignored,
+// except UserID which is required.
+type ExpenseFilter struct {
+	UserID    string
+	StartDate time.Time
+	EndDate   time.Time
+	Category  string
+}
+
+// ExpenseRepository stores expenses. Every method is scoped to a user:
+// touching another user's expense behaves as if it did not exist.
+type ExpenseRepository interface {
+	// Create stores a new expense and sets expense.ID.
+	Create(ctx context.Context, expense *models.Expense) error
+	// List returns matching expenses, newest first.
+	List(ctx context.Context, filter ExpenseFilter) ([]models.Expense, error)
+	// Update replaces the amount, category, description and date of the
+	// expense with expense.ID owned by expense.UserID.
+	Update(ctx context.Context, expense *models.Expense) error
+	Delete(ctx context.Context, userID, id string) error
+}
+
+// TokenRepository stores refresh tokens, looked up by the hash of the
+// token.
+type TokenRepository interface {
+	// Create stores a new token and sets token.ID.
+	Create(ctx context.Context, token *models.RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error)
+	// MarkUsed marks a token as exchanged. It returns ErrNotFound if the
+	// token was already used or revoked, so of two concurrent refreshes
+	// with the same token only one succeeds.
+	MarkUsed(ctx context.Context, id string, at time.Time) error
+	// RevokeFamily revokes every token issued from the same login.
+	RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+	// RevokeUser revokes every token of a user and returns the families
+	// that still had unexpired tokens.
+	RevokeUser(ctx context.Context, userID string, at time.Time) ([]string, error)
+}
+
+// Repositories bundles the repositories of one database.
+type Repositories struct {
+	Users    UserRepository
+	Expenses ExpenseRepository
+	Tokens   TokenRepository
+	// Close releases the underlying connection.
+	Close func() error
+}
@@ -0,0 +1,465 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+
+	"expense-tracker/backend-vanilla/models"
+)
+
+// Dialect selects the SQL flavour of a database/sql connection.
+type Dialect int
+
+const (
+	Postgres Dialect = iota
+	MySQL
+)
+
+// sqlStore holds what the SQL repositories share. Queries are written with
+// ? placeholders and rebound for PostgreSQL.
+type sqlStore struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+type sqlUsers struct{ sqlStore }
+type sqlExpenses struct{ sqlStore }
+type sqlTokens struct{ sqlStore }
+
+// NewSQL returns repositories backed by the tables created by
+// config.PostgresDB.CreateTables or config.MySQLDB.CreateTables.
+func NewSQL(db *sql.DB, dialect Dialect) Repositories {
+	s := sqlStore{db: db, dialect: dialect}
+	return Repositories{
+		Users:    sqlUsers{s},
+		Expenses: sqlExpenses{s},
+		Tokens:   sqlTokens{s},
+		Close:    db.Close,
+	}
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s sqlStore) rebind(query string) string {
+	if s.dialect != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// insert runs an INSERT and returns the new row's id.
+func (s sqlStore) insert(ctx context.Context, q querier, query string, args ...interface{}) (int64, error) {
+	if s.dialect == Postgres {
+		var id int64
+		err := q.QueryRowContext(ctx, s.rebind(query+" RETURNING id"), args...).Scan(&id)
+		return id, err
+	}
+	result, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func isDuplicate(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505" // unique_violation
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1062 // ER_DUP_ENTRY
+	}
+	return false
+}
+
+func parseID(id string) (int64, bool) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	return n, err == nil && n > 0
+}
+
+// nullString stores empty strings as NULL so that unique email columns
+// allow any number of users without an email.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func (r sqlUsers) Create(ctx context.Context, user *models.User) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id, err := r.insert(ctx, tx,
+		`INSERT INTO users (email, password_hash, name, spending_limit, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		nullString(user.Email), user.Password, user.Name, user.SpendingLimit, user.CreatedAt.UTC(), user.UpdatedAt.UTC())
+	if err != nil {
+		if isDuplicate(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	for _, identity := range user.Identities {
+		if err := r.insertIdentity(ctx, tx, id, identity); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	user.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+func (r sqlUsers) insertIdentity(ctx context.Context, q querier, userID int64, identity models.SocialIdentity) error {
+	_, err := q.ExecContext(ctx, r.rebind(
+		`INSERT INTO user_identities (user_id, provider, subject, email, linked_at) VALUES (?, ?, ?, ?, ?)`),
+		userID, identity.Provider, identity.Subject, nullString(identity.Email), identity.LinkedAt.UTC())
+	if isDuplicate(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r sqlUsers) findOne(ctx context.Context, where string, args ...interface{}) (*models.User, error) {
+	var (
+		id          int64
+		email, name sql.NullString
+		user        models.User
+	)
+	err := r.db.QueryRowContext(ctx, r.rebind(
+		`SELECT id, email, password_hash, name, spending_limit, created_at, updated_at FROM users WHERE `+where), args...).
+		Scan(&id, &email, &user.Password, &name, &user.SpendingLimit, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	user.ID = strconv.FormatInt(id, 10)
+	user.Email, user.Name = email.String, name.String
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(
+		`SELECT provider, subject, email, linked_at FROM user_identities WHERE user_id = ? ORDER BY id`), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			identity models.SocialIdentity
+			email    sql.NullString
+		)
+		if err := rows.Scan(&identity.Provider, &identity.Subject, &email, &identity.LinkedAt); err != nil {
+			return nil, err
+		}
+		identity.Email = email.String
+		user.Identities = append(user.Identities, identity)
+	}
+	return &user, rows.Err()
+}
+
+func (r sqlUsers) GetByID(ctx context.Context, id string) (*models.User, error) {
+	n, ok := parseID(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return r.findOne(ctx, "id = ?", n)
+}
+
+func (r sqlUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	if email == "" {
+		return nil, ErrNotFound
+	}
+	return r.findOne(ctx, "email = ?", email)
+}
+
+func (r sqlUsers) GetByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	return r.findOne(ctx, "id = (SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?)", provider, subject)
+}
+
+func (r sqlUsers) AddIdentity(ctx context.Context, userID string, identity models.SocialIdentity) (*models.User, error) {
+	n, ok := parseID(userID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, r.rebind(`UPDATE users SET updated_at = ? WHERE id = ?`), identity.LinkedAt.UTC(), n)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if affected == 0 {
+		return nil, ErrNotFound
+	}
+	if err := r.insertIdentity(ctx, tx, n, identity); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(ctx, userID)
+}
+
+const expenseColumns = `id, user_id, amount, category, description, date, created_at, updated_at`
+
+func scanExpense(row interface{ Scan(...interface{}) error }) (models.Expense, error) {
+	var (
+		e           models.Expense
+		id, userID  int64
+		description sql.NullString
+	)
+	err := row.Scan(&id, &userID, &e.Amount, &e.Category, &description, &e.Date, &e.CreatedAt, &e.UpdatedAt)
+	e.ID, e.UserID = strconv.FormatInt(id, 10), strconv.FormatInt(userID, 10)
+	e.Description = description.String
+	return e, err
+}
+
+func (r sqlExpenses) Create(ctx context.Context, expense *models.Expense) error {
+	userID, ok := parseID(expense.UserID)
+	if !ok {
+		return ErrNotFound
+	}
+	id, err := r.insert(ctx, r.db,
+		`INSERT INTO expenses (user_id, amount, category, description, date, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userID, expense.Amount, expense.Category, expense.Description, expense.Date.UTC(), expense.CreatedAt.UTC(), expense.UpdatedAt.UTC())
+	if err != nil {
+		return err
+	}
+	expense.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+func (r sqlExpenses) List(ctx context.Context, f ExpenseFilter) ([]models.Expense, error) {
+	userID, ok := parseID(f.UserID)
+	if !ok {
+		return []models.Expense{}, nil
+	}
+
+	where := []string{"user_id = ?"}
+	args := []interface{}{userID}
+	if !f.StartDate.IsZero() {
+		where = append(where, "date >= ?")
+		args = append(args, f.StartDate.UTC())
+	}
+	if !f.EndDate.IsZero() {
+		where = append(where, "date <= ?")
+		args = append(args, f.EndDate.UTC())
+	}
+	if f.Category != "" {
+		where = append(where, "category = ?")
+		args = append(args, f.Category)
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(fmt.Sprintf(
+		`SELECT %s FROM expenses WHERE %s ORDER BY date DESC, id DESC`, expenseColumns, strings.Join(where, " AND "))), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	expenses := []models.Expense{}
+	for rows.Next() {
+		e, err := scanExpense(rows)
+		if err != nil {
+			return nil, err
+		}
+		expenses = append(expenses, e)
+	}
+	return expenses, rows.Err()
+}
+
+func (r sqlExpenses) get(ctx context.Context, userID, id int64) (models.Expense, error) {
+	e, err := scanExpense(r.db.QueryRowContext(ctx, r.rebind(
+		`SELECT `+expenseColumns+` FROM expenses WHERE id = ? AND user_id = ?`), id, userID))
+	if err == sql.ErrNoRows {
+		return e, ErrNotFound
+	}
+	return e, err
+}
+
+func (r sqlExpenses) Update(ctx context.Context, expense *models.Expense) error {
+	id, ok1 := parseID(expense.ID)
+	userID, ok2 := parseID(expense.UserID)
+	if !ok1 || !ok2 {
+		return ErrNotFound
+	}
+
+	_, err := r.db.ExecContext(ctx, r.rebind(
+		`UPDATE expenses SET amount = ?, category = ?, description = ?, date = ?, updated_at = ? WHERE id = ? AND user_id = ?`),
+		expense.Amount, expense.Category, expense.Description, expense.Date.UTC(), expense.UpdatedAt.UTC(), id, userID)
+	if err != nil {
+		return err
+	}
+
+	// MySQL reports zero affected rows when nothing changed, so read the
+	// row back instead of trusting RowsAffected
+	stored, err := r.get(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	*expense = stored
+	return nil
+}
+
+func (r sqlExpenses) Delete(ctx context.Context, userID, id string) error {
+	n, ok1 := parseID(id)
+	uid, ok2 := parseID(userID)
+	if !ok1 || !ok2 {
+		return ErrNotFound
+	}
+	result, err := r.db.ExecContext(ctx, r.rebind(`DELETE FROM expenses WHERE id = ? AND user_id = ?`), n, uid)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r sqlTokens) Create(ctx context.Context, token *models.RefreshToken) error {
+	userID, ok := parseID(token.UserID)
+	if !ok {
+		return ErrNotFound
+	}
+	id, err := r.insert(ctx, r.db,
+		`INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?)`,
+		userID, token.FamilyID, token.TokenHash, token.ExpiresAt.UTC(), token.CreatedAt.UTC())
+	if err != nil {
+		if isDuplicate(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	token.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+func (r sqlTokens) GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var (
+		t               models.RefreshToken
+		id, userID      int64
+		usedAt, revoked sql.NullTime
+	)
+	err := r.db.QueryRowContext(ctx, r.rebind(
+		`SELECT id, user_id, family_id, token_hash, expires_at, created_at, used_at, revoked_at FROM refresh_tokens WHERE token_hash = ?`), hash).
+		Scan(&id, &userID, &t.FamilyID, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt, &usedAt, &revoked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	t.ID, t.UserID = strconv.FormatInt(id, 10), strconv.FormatInt(userID, 10)
+	if usedAt.Valid {
+		t.UsedAt = &usedAt.Time
+	}
+	if revoked.Valid {
+		t.RevokedAt = &revoked.Time
+	}
+	return &t, nil
+}
+
+func (r sqlTokens) MarkUsed(ctx context.Context, id string, at time.Time) error {
+	n, ok := parseID(id)
+	if !ok {
+		return ErrNotFound
+	}
+	// used_at always changes from NULL, so RowsAffected is reliable on
+	// MySQL here
+	result, err := r.db.ExecContext(ctx, r.rebind(
+		`UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL`), at.UTC(), n)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r sqlTokens) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, r.rebind(
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL`), at.UTC(), familyID)
+	return err
+}
+
+func (r sqlTokens) RevokeUser(ctx context.Context, userID string, at time.Time) ([]string, error) {
+	n, ok := parseID(userID)
+	if !ok {
+		return []string{}, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, r.rebind(
+		`SELECT DISTINCT family_id FROM refresh_tokens WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?`), n, at.UTC())
+	if err != nil {
+		return nil, err
+	}
+	families := []string{}
+	for rows.Next() {
+		var family string
+		if err := rows.Scan(&family); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		families = append(families, family)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, r.rebind(
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`), at.UTC(), n)
+	if err != nil {
+		return nil, err
+	}
+	return families, tx.Commit()
+}
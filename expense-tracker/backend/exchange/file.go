@@ -0,0 +1,78 @@
+package exchange
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// LoadFile adds the rates in path to the store. Files ending in .json hold
+// {"2024-01-02": {"EUR": "0.91"}}; anything else is read as CSV with
+// date,currency,rate rows and an optional header.
+func (s *Store) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return s.LoadJSON(f)
+	}
+	return s.LoadCSV(f)
+}
+
+// LoadJSON adds rates encoded as a map of day to currency to rate.
+func (s *Store) LoadJSON(r io.Reader) error {
+	var days map[string]map[string]decimal.Decimal
+	if err := json.NewDecoder(r).Decode(&days); err != nil {
+		return fmt.Errorf("decode rates: %w", err)
+	}
+	for day, rates := range days {
+		d, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			return fmt.Errorf("invalid date %q: %w", day, err)
+		}
+		for currency, rate := range rates {
+			s.Set(d, currency, rate)
+		}
+	}
+	return nil
+}
+
+// LoadCSV adds rates from date,currency,rate rows.
+func (s *Store) LoadCSV(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if line == 1 && strings.EqualFold(record[0], "date") {
+			continue
+		}
+
+		day, err := time.Parse("2006-01-02", record[0])
+		if err != nil {
+			return fmt.Errorf("line %d: invalid date %q", line, record[0])
+		}
+		rate, err := decimal.NewFromString(record[2])
+		if err != nil {
+			return fmt.Errorf("line %d: invalid rate %q", line, record[2])
+		}
+		s.Set(day, record[1], rate)
+	}
+}
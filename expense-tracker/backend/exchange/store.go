@@ -0,0 +1,151 @@
+// Package exchange keeps daily currency exchange rates and converts amounts
+// between currencies as of a given date.
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ErrRateNotFound is returned when no rate is known for a currency on or
+// shortly before the requested date.
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// DefaultLookback is how many days the store walks back when a date has no
+// rates, which covers weekends and bank holidays.
+const DefaultLookback = 7
+
+// Store holds daily rates quoted against a single base currency: a rate of
+// 0.92 for "EUR" means one unit of the base buys 0.92 EUR on that day.
+// Days missing from the store are fetched from the provider, if any, and
+// cached.
+type Store struct {
+	Base     string
+	Lookback int
+
+	provider Provider
+	mu       sync.RWMutex
+	days     map[string]map[string]decimal.Decimal
+}
+
+// NewStore returns an empty store for the given base currency. provider may
+// be nil, in which case only rates added with Set or loaded from a file are
+// used.
+func NewStore(base string, provider Provider) *Store {
+	return &Store{
+		Base:     normalize(base),
+		Lookback: DefaultLookback,
+		provider: provider,
+		days:     make(map[string]map[string]decimal.Decimal),
+	}
+}
+
+// Set records the rate of currency against the base on day.
+func (s *Store) Set(day time.Time, currency string, rate decimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setLocked(dayKey(day), normalize(currency), rate)
+}
+
+func (s *Store) setLocked(key, currency string, rate decimal.Decimal) {
+	rates, ok := s.days[key]
+	if !ok {
+		rates = make(map[string]decimal.Decimal)
+		s.days[key] = rates
+	}
+	rates[currency] = rate
+}
+
+// Rate returns how many units of to one unit of from buys on day.
+func (s *Store) Rate(ctx context.Context, from, to string, day time.Time) (decimal.Decimal, error) {
+	from, to = normalize(from), normalize(to)
+	if from == to {
+		return decimal.NewFromInt(1), nil
+	}
+
+	fromRate, err := s.baseRate(ctx, from, day)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	toRate, err := s.baseRate(ctx, to, day)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return toRate.Div(fromRate), nil
+}
+
+// Convert converts amount from one currency to another at the rate of day.
+func (s *Store) Convert(ctx context.Context, amount decimal.Decimal, from, to string, day time.Time) (decimal.Decimal, error) {
+	rate, err := s.Rate(ctx, from, to, day)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return amount.Mul(rate), nil
+}
+
+// baseRate finds the rate of currency against the base on day, falling back
+// to the closest earlier day within the lookback window.
+func (s *Store) baseRate(ctx context.Context, currency string, day time.Time) (decimal.Decimal, error) {
+	if currency == s.Base {
+		return decimal.NewFromInt(1), nil
+	}
+
+	for i := 0; i <= s.Lookback; i++ {
+		d := day.AddDate(0, 0, -i)
+		rates, err := s.ratesFor(ctx, d)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		if rate, ok := rates[currency]; ok {
+			if rate.IsZero() {
+				return decimal.Zero, fmt.Errorf("zero exchange rate for %s on %s", currency, dayKey(d))
+			}
+			return rate, nil
+		}
+	}
+	return decimal.Zero, fmt.Errorf("%w: %s/%s on %s", ErrRateNotFound, s.Base, currency, dayKey(day))
+}
+
+func (s *Store) ratesFor(ctx context.Context, day time.Time) (map[string]decimal.Decimal, error) {
+	key := dayKey(day)
+
+	s.mu.RLock()
+	rates, ok := s.days[key]
+	s.mu.RUnlock()
+	if ok || s.provider == nil {
+		return rates, nil
+	}
+
+	fetched, err := s.provider.Rates(ctx, s.Base, day)
+	if err != nil {
+		return nil, fmt.Errorf("fetch rates for %s: %w", key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Cache the day even if the provider had nothing, so holidays are not
+	// fetched again on every lookup.
+	if _, ok := s.days[key]; !ok {
+		s.days[key] = make(map[string]decimal.Decimal)
+	}
+	for currency, rate := range fetched {
+		s.setLocked(key, normalize(currency), rate)
+	}
+	return s.days[key], nil
+}
+
+// dayKey is the calendar date of t in its own location, so a local
+// midnight east of UTC isn't taken for the previous day.
+func dayKey(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func normalize(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
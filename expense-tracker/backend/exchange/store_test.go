@@ -0,0 +1,171 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func TestStore_ConvertUsesRateOfTheDay(t *testing.T) {
+	store := NewStore("USD", StaticProvider{
+		"2024-01-02": {"EUR": dec("0.90"), "GBP": dec("0.80")},
+		"2024-01-03": {"EUR": dec("0.95"), "GBP": dec("0.76")},
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		amount, from, to, day, want string
+	}{
+		{"100", "USD", "EUR", "2024-01-02", "90"},
+		{"100", "USD", "EUR", "2024-01-03", "95"},
+		{"90", "EUR", "USD", "2024-01-02", "100"},
+		{"90", "eur", "gbp", "2024-01-02", "80"},
+		{"12.34", "GBP", "GBP", "2024-01-02", "12.34"},
+	}
+	for _, tt := range tests {
+		got, err := store.Convert(ctx, dec(tt.amount), tt.from, tt.to, day(tt.day))
+		if err != nil {
+			t.Fatalf("Convert(%s %s->%s): %v", tt.amount, tt.from, tt.to, err)
+		}
+		if !got.Round(8).Equal(dec(tt.want)) {
+			t.Errorf("Convert(%s %s->%s on %s) = %s, want %s", tt.amount, tt.from, tt.to, tt.day, got, tt.want)
+		}
+	}
+}
+
+func TestStore_UsesLocalCalendarDay(t *testing.T) {
+	store := NewStore("USD", StaticProvider{
+		"2024-01-02": {"EUR": dec("0.90")},
+		"2024-01-03": {"EUR": dec("0.95")},
+	})
+	tokyo := time.FixedZone("UTC+9", 9*60*60)
+
+	// Local midnight and early morning in Tokyo are still the previous day in UTC
+	for _, at := range []string{"2024-01-03 00:00", "2024-01-03 08:30"} {
+		when, err := time.ParseInLocation("2006-01-02 15:04", at, tokyo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.Convert(context.Background(), dec("100"), "USD", "EUR", when)
+		if err != nil {
+			t.Fatalf("Convert at %s: %v", at, err)
+		}
+		if !got.Equal(dec("95")) {
+			t.Errorf("Convert at %s +09:00 = %s, want the 2024-01-03 rate (95)", at, got)
+		}
+	}
+
+	// And a New York evening is already the next day in UTC
+	newYork := time.FixedZone("UTC-5", -5*60*60)
+	when := time.Date(2024, 1, 2, 20, 0, 0, 0, newYork)
+	got, err := store.Convert(context.Background(), dec("100"), "USD", "EUR", when)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(dec("90")) {
+		t.Errorf("Convert at 2024-01-02 20:00 -05:00 = %s, want the 2024-01-02 rate (90)", got)
+	}
+}
+
+func TestStore_FallsBackToEarlierDay(t *testing.T) {
+	store := NewStore("USD", nil)
+	store.Set(day("2024-01-05"), "EUR", dec("0.9")) // Friday
+
+	got, err := store.Convert(context.Background(), dec("10"), "USD", "EUR", day("2024-01-07"))
+	if err != nil {
+		t.Fatalf("Convert on a Sunday: %v", err)
+	}
+	if !got.Equal(dec("9")) {
+		t.Errorf("got %s, want 9", got)
+	}
+
+	store.Lookback = 1
+	_, err = store.Convert(context.Background(), dec("10"), "USD", "EUR", day("2024-01-07"))
+	if !errors.Is(err, ErrRateNotFound) {
+		t.Errorf("expected ErrRateNotFound outside the lookback window, got %v", err)
+	}
+}
+
+func TestStore_LoadCSVAndJSON(t *testing.T) {
+	store := NewStore("USD", nil)
+	csv := "date,currency,rate\n2024-02-01,EUR,0.92\n2024-02-01, JPY, 147.5\n"
+	if err := store.LoadCSV(strings.NewReader(csv)); err != nil {
+		t.Fatalf("LoadCSV: %v", err)
+	}
+	if err := store.LoadJSON(strings.NewReader(`{"2024-02-02": {"EUR": "0.93"}}`)); err != nil {
+		t.Fatalf("LoadJSON: %v", err)
+	}
+
+	ctx := context.Background()
+	rate, err := store.Rate(ctx, "EUR", "JPY", day("2024-02-01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := dec("147.5").Div(dec("0.92")); !rate.Equal(want) {
+		t.Errorf("EUR/JPY = %s, want %s", rate, want)
+	}
+	rate, err = store.Rate(ctx, "USD", "EUR", day("2024-02-02"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rate.Equal(dec("0.93")) {
+		t.Errorf("USD/EUR = %s, want 0.93", rate)
+	}
+
+	if err := store.LoadCSV(strings.NewReader("2024-13-01,EUR,1\n")); err == nil {
+		t.Error("expected an error for an invalid date")
+	}
+}
+
+func TestHTTPProvider_CachesDays(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		date := strings.TrimPrefix(r.URL.Path, "/")
+		if r.URL.Query().Get("from") != "USD" {
+			http.Error(w, "bad base", http.StatusBadRequest)
+			return
+		}
+		if date == "2024-03-02" {
+			// Saturday: answer with Friday's rates like the real API does
+			fmt.Fprint(w, `{"base":"USD","date":"2024-03-01","rates":{"EUR":0.9}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"base":"USD","date":%q,"rates":{"EUR":0.9}}`, date)
+	}))
+	defer srv.Close()
+
+	store := NewStore("USD", NewHTTPProvider(srv.URL))
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		got, err := store.Convert(ctx, dec("100"), "USD", "EUR", day("2024-03-02"))
+		if err != nil {
+			t.Fatalf("Convert: %v", err)
+		}
+		if !got.Equal(dec("90")) {
+			t.Errorf("got %s, want 90", got)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 provider calls (Saturday and Friday), got %d", calls)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -81,7 +82,7 @@ func (h *BudgetHandler) CreateBudget(c *gin.Context) {
 	// Set initial values
 	budget.CreatedAt = time.Now()
 	budget.UpdatedAt = time.Now()
-	budget.Spent = 0 // Initialize spent amount to 0
+	budget.Spent = decimal.Zero // Initialize spent amount to 0
 
 	// Validate budget period
 	switch budget.Period {
@@ -1,194 +1,243 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"expense-tracker/exchange"
 	"expense-tracker/models"
+	"expense-tracker/reports"
 )
 
 type ReportHandler struct {
-	db *mongo.Database
+	db       *mongo.Database
+	rates    *exchange.Store
+	currency string
 }
 
-func NewReportHandler(db *mongo.Database) *ReportHandler {
+// NewReportHandler returns a handler that converts report amounts with rates.
+// currency is the reporting currency used when a request does not pass one.
+func NewReportHandler(db *mongo.Database, rates *exchange.Store, currency string) *ReportHandler {
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
 	return &ReportHandler{
-		db: db,
+		db:       db,
+		rates:    rates,
+		currency: strings.ToUpper(currency),
 	}
 }
 
-func (h *ReportHandler) GetTransactionReport(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// reportParams are the query parameters shared by all date-range reports.
+type reportParams struct {
+	start    time.Time
+	end      time.Time
+	currency string
+	loc      *time.Location
+}
 
-	// Parse date range
-	startDate := c.Query("startDate")
-	endDate := c.Query("endDate")
+// parseParams reads startDate, endDate (RFC 3339, defaulting to the last
+// month), currency and tz (an IANA zone used to decide which day a
+// transaction falls on, defaulting to UTC).
+func (h *ReportHandler) parseParams(c *gin.Context) (reportParams, error) {
+	p := reportParams{currency: h.currency, loc: time.UTC}
 
-	start, err := time.Parse(time.RFC3339, startDate)
+	start, err := time.Parse(time.RFC3339, c.Query("startDate"))
 	if err != nil {
 		start = time.Now().AddDate(0, -1, 0) // Default to last month
 	}
-
-	end, err := time.Parse(time.RFC3339, endDate)
+	end, err := time.Parse(time.RFC3339, c.Query("endDate"))
 	if err != nil {
 		end = time.Now() // Default to now
 	}
+	p.start, p.end = start, end
 
-	// Build date filter
-	dateFilter := bson.M{
-		"date": bson.M{
-			"$gte": start,
-			"$lte": end,
-		},
+	if currency := strings.TrimSpace(c.Query("currency")); currency != "" {
+		p.currency = strings.ToUpper(currency)
 	}
-
-	// Get transactions
-	transactionsColl := h.db.Collection("transactions")
-	cursor, err := transactionsColl.Find(ctx, dateFilter, options.Find().SetSort(bson.D{{Key: "date", Value: -1}}))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if tz := c.Query("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return p, fmt.Errorf("invalid time zone %q", tz)
+		}
+		p.loc = loc
 	}
-	defer cursor.Close(ctx)
+	return p, nil
+}
 
-	var transactions []models.Transaction
-	if err = cursor.All(ctx, &transactions); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// reportError writes the response for a failed report; missing exchange
+// rates are the client's problem, everything else is ours.
+func reportError(c *gin.Context, err error) {
+	if errors.Is(err, exchange.ErrRateNotFound) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
 
-	// Calculate totals
-	var totalIncome, totalExpenses float64
-	categoryMap := make(map[string]*models.CategoryExpenseSummary)
+func transactionCurrency(t models.Transaction) string {
+	if t.Currency == "" {
+		return models.DefaultCurrency
+	}
+	return t.Currency
+}
 
-	for _, t := range transactions {
-		if t.Amount >= 0 {
-			totalIncome += t.Amount
-		} else {
-			totalExpenses += -t.Amount
-			// Track category expenses
-			if summary, exists := categoryMap[t.Category]; exists {
-				summary.Amount += -t.Amount
-				summary.Count++
-			} else {
-				categoryMap[t.Category] = &models.CategoryExpenseSummary{
-					Category: t.Category,
-					Amount:   -t.Amount,
-					Count:    1,
-				}
-			}
-		}
+// dailyTotals sums the transactions matching match per day, category,
+// currency and direction in MongoDB, then converts each row into currency at
+// that day's rate. Only one row per combination crosses the wire, however
+// many transactions there are.
+func (h *ReportHandler) dailyTotals(ctx context.Context, match bson.M, currency string, loc *time.Location) ([]reports.DailyTotal, error) {
+	pipeline := []bson.M{
+		{"$match": match},
+		{
+			"$group": bson.M{
+				"_id": bson.M{
+					"category": "$category",
+					"currency": bson.M{"$ifNull": bson.A{"$currency", models.DefaultCurrency}},
+					"day": bson.M{"$dateToString": bson.M{
+						"format":   "%Y-%m-%d",
+						"date":     "$date",
+						"timezone": loc.String(),
+					}},
+					"income": bson.M{"$gte": bson.A{"$amount", 0}},
+				},
+				"amount": bson.M{"$sum": "$amount"},
+				"count":  bson.M{"$sum": 1},
+			},
+		},
+		{"$sort": bson.D{{Key: "_id.day", Value: 1}}},
 	}
 
-	// Convert category map to slice
-	categories := make([]models.CategoryExpenseSummary, 0, len(categoryMap))
-	for _, summary := range categoryMap {
-		categories = append(categories, *summary)
+	cursor, err := h.db.Collection("transactions").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
 	}
+	defer cursor.Close(ctx)
 
-	report := models.TransactionReport{
-		TotalIncome:   totalIncome,
-		TotalExpenses: totalExpenses,
-		NetAmount:     totalIncome - totalExpenses,
-		Transactions:  transactions,
-		Categories:    categories,
+	var rows []struct {
+		ID struct {
+			Category string `bson:"category"`
+			Currency string `bson:"currency"`
+			Day      string `bson:"day"`
+		} `bson:"_id"`
+		Amount decimal.Decimal `bson:"amount"`
+		Count  int             `bson:"count"`
+	}
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, err
 	}
 
-	c.JSON(http.StatusOK, report)
+	totals := make([]reports.DailyTotal, 0, len(rows))
+	for _, row := range rows {
+		day, err := time.ParseInLocation("2006-01-02", row.ID.Day, loc)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := h.rates.Convert(ctx, row.Amount, row.ID.Currency, currency, day)
+		if err != nil {
+			return nil, err
+		}
+		totals = append(totals, reports.DailyTotal{
+			Day:      day,
+			Category: row.ID.Category,
+			Currency: currency,
+			Amount:   amount,
+			Count:    row.Count,
+		})
+	}
+	return totals, nil
 }
 
-func (h *ReportHandler) GetCategoryReport(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	startDate := c.Query("startDate")
-	endDate := c.Query("endDate")
-
-	start, err := time.Parse(time.RFC3339, startDate)
-	if err != nil {
-		start = time.Now().AddDate(0, -1, 0)
+func dateMatch(start, end time.Time) bson.M {
+	return bson.M{
+		"date": bson.M{
+			"$gte": start,
+			"$lte": end,
+		},
 	}
+}
 
-	end, err := time.Parse(time.RFC3339, endDate)
+func (h *ReportHandler) transactionReport(ctx context.Context, p reportParams) (models.TransactionReport, error) {
+	totals, err := h.dailyTotals(ctx, dateMatch(p.start, p.end), p.currency, p.loc)
 	if err != nil {
-		end = time.Now()
+		return models.TransactionReport{}, err
 	}
+	income, expenses, categories := reports.Summarize(totals)
 
-	pipeline := []bson.M{
-		{
-			"$match": bson.M{
-				"date": bson.M{
-					"$gte": start,
-					"$lte": end,
-				},
-				"amount": bson.M{"$lt": 0}, // Only expenses
-			},
-		},
-		{
-			"$group": bson.M{
-				"_id": "$category",
-				"amount": bson.M{
-					"$sum": bson.M{
-						"$abs": "$amount",
-					},
-				},
-				"count": bson.M{"$sum": 1},
-			},
-		},
-		{
-			"$project": bson.M{
-				"category": "$_id",
-				"amount":   1,
-				"count":    1,
-				"_id":      0,
-			},
-		},
-	}
-
-	cursor, err := h.db.Collection("transactions").Aggregate(ctx, pipeline)
+	// Get transactions
+	cursor, err := h.db.Collection("transactions").Find(ctx, dateMatch(p.start, p.end), options.Find().SetSort(bson.D{{Key: "date", Value: -1}}))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return models.TransactionReport{}, err
 	}
 	defer cursor.Close(ctx)
 
-	var categories []models.CategoryExpenseSummary
-	if err = cursor.All(ctx, &categories); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	var transactions []models.Transaction
+	if err = cursor.All(ctx, &transactions); err != nil {
+		return models.TransactionReport{}, err
 	}
 
-	c.JSON(http.StatusOK, categories)
+	// Show each transaction in the report currency at the rate of its own date
+	converted := make([]models.ConvertedTransaction, 0, len(transactions))
+	for _, t := range transactions {
+		rate, err := h.rates.Rate(ctx, transactionCurrency(t), p.currency, t.Date.In(p.loc))
+		if err != nil {
+			return models.TransactionReport{}, err
+		}
+		converted = append(converted, models.ConvertedTransaction{
+			Transaction:     t,
+			ConvertedAmount: t.Amount.Mul(rate).Round(reports.Precision),
+			ExchangeRate:    rate,
+		})
+	}
+
+	return models.TransactionReport{
+		Currency:      p.currency,
+		TotalIncome:   income,
+		TotalExpenses: expenses,
+		NetAmount:     income.Sub(expenses),
+		Transactions:  converted,
+		Categories:    categories,
+	}, nil
 }
 
-func (h *ReportHandler) GetBudgetReport(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+func (h *ReportHandler) categoryReport(ctx context.Context, p reportParams) ([]models.CategoryExpenseSummary, error) {
+	match := dateMatch(p.start, p.end)
+	match["amount"] = bson.M{"$lt": 0} // Only expenses
+
+	totals, err := h.dailyTotals(ctx, match, p.currency, p.loc)
+	if err != nil {
+		return nil, err
+	}
+	_, _, categories := reports.Summarize(totals)
+	return categories, nil
+}
 
+func (h *ReportHandler) budgetReport(ctx context.Context) ([]models.BudgetReport, error) {
 	// Get all budgets
-	budgetsColl := h.db.Collection("budgets")
-	cursor, err := budgetsColl.Find(ctx, bson.M{})
+	cursor, err := h.db.Collection("budgets").Find(ctx, bson.M{})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
 	defer cursor.Close(ctx)
 
 	var budgets []models.Budget
 	if err = cursor.All(ctx, &budgets); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
 
 	// Calculate current spending for each budget
-	var budgetReports []models.BudgetReport
+	budgetReports := make([]models.BudgetReport, 0, len(budgets))
 	for _, budget := range budgets {
 		// Calculate date range based on budget period
 		var startDate time.Time
@@ -203,47 +252,26 @@ func (h *ReportHandler) GetBudgetReport(c *gin.Context) {
 			startDate = time.Now().AddDate(-1, 0, 0)
 		}
 
-		// Get total expenses for this category in the period
-		pipeline := []bson.M{
-			{
-				"$match": bson.M{
-					"category": budget.Category,
-					"amount":   bson.M{"$lt": 0},
-					"date": bson.M{
-						"$gte": startDate,
-						"$lte": endDate,
-					},
-				},
-			},
-			{
-				"$group": bson.M{
-					"_id": nil,
-					"total": bson.M{
-						"$sum": bson.M{
-							"$abs": "$amount",
-						},
-					},
-				},
-			},
+		currency := budget.Currency
+		if currency == "" {
+			currency = models.DefaultCurrency
 		}
 
-		cursor, err := h.db.Collection("transactions").Aggregate(ctx, pipeline)
+		// Get total expenses for this category in the period, in the budget's currency
+		match := dateMatch(startDate, endDate)
+		match["category"] = budget.Category
+		match["amount"] = bson.M{"$lt": 0}
+		totals, err := h.dailyTotals(ctx, match, currency, time.UTC)
 		if err != nil {
 			continue
 		}
+		_, spentAmount, _ := reports.Summarize(totals)
 
-		var result []bson.M
-		if err = cursor.All(ctx, &result); err != nil {
-			continue
-		}
-
-		var spentAmount float64
-		if len(result) > 0 {
-			spentAmount = result[0]["total"].(float64)
+		var percentage float64
+		if !budget.Amount.IsZero() {
+			percentage = spentAmount.Div(budget.Amount).Mul(decimal.NewFromInt(100)).InexactFloat64()
 		}
 
-		percentage := (spentAmount / budget.Amount) * 100
-
 		budgetReports = append(budgetReports, models.BudgetReport{
 			Category:     budget.Category,
 			BudgetAmount: budget.Amount,
@@ -253,6 +281,174 @@ func (h *ReportHandler) GetBudgetReport(c *gin.Context) {
 			EndDate:      endDate,
 		})
 	}
+	return budgetReports, nil
+}
+
+func (h *ReportHandler) timeSeriesReport(ctx context.Context, p reportParams, interval string) (models.TimeSeriesReport, error) {
+	totals, err := h.dailyTotals(ctx, dateMatch(p.start, p.end), p.currency, p.loc)
+	if err != nil {
+		return models.TimeSeriesReport{}, err
+	}
+	report := reports.TimeSeries(totals, interval, p.start, p.end, p.loc)
+	report.Currency = p.currency
+	return report, nil
+}
+
+func (h *ReportHandler) GetTransactionReport(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	p, err := h.parseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.transactionReport(ctx, p)
+	if err != nil {
+		reportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *ReportHandler) GetCategoryReport(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	p, err := h.parseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	categories, err := h.categoryReport(ctx, p)
+	if err != nil {
+		reportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+func (h *ReportHandler) GetBudgetReport(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	budgetReports, err := h.budgetReport(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, budgetReports)
 }
+
+// parseInterval reads ?interval=week|month (month by default), responding
+// with 400 for anything else.
+func parseInterval(c *gin.Context) (string, bool) {
+	interval := c.DefaultQuery("interval", reports.IntervalMonth)
+	if interval != reports.IntervalWeek && interval != reports.IntervalMonth {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be 'week' or 'month'"})
+		return "", false
+	}
+	return interval, true
+}
+
+// GetTimeSeriesReport returns income and expenses per week or month
+// (?interval=week|month) with period-over-period deltas and category trends.
+func (h *ReportHandler) GetTimeSeriesReport(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	p, err := h.parseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	interval, ok := parseInterval(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.timeSeriesReport(ctx, p, interval)
+	if err != nil {
+		reportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ExportReport downloads the report named by :report (transactions,
+// categories, budgets or timeseries) as ?format=csv|xlsx|pdf. It accepts the
+// same query parameters as the JSON report.
+func (h *ReportHandler) ExportReport(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	format := strings.ToLower(c.DefaultQuery("format", reports.FormatCSV))
+	contentType := reports.ContentType(format)
+	if contentType == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, xlsx or pdf"})
+		return
+	}
+
+	p, err := h.parseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var table reports.Table
+	name := c.Param("report")
+	switch name {
+	case "transactions":
+		report, err := h.transactionReport(ctx, p)
+		if err != nil {
+			reportError(c, err)
+			return
+		}
+		table = reports.TransactionTable(report, p.start, p.end)
+	case "categories":
+		categories, err := h.categoryReport(ctx, p)
+		if err != nil {
+			reportError(c, err)
+			return
+		}
+		table = reports.CategoryTable(categories, p.currency, p.start, p.end)
+	case "budgets":
+		budgetReports, err := h.budgetReport(ctx)
+		if err != nil {
+			reportError(c, err)
+			return
+		}
+		table = reports.BudgetTable(budgetReports)
+	case "timeseries":
+		interval, ok := parseInterval(c)
+		if !ok {
+			return
+		}
+		report, err := h.timeSeriesReport(ctx, p, interval)
+		if err != nil {
+			reportError(c, err)
+			return
+		}
+		table = reports.TimeSeriesTable(report)
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	// Render into a buffer so a failure can still be reported as JSON
+	var buf bytes.Buffer
+	if err := reports.Write(&buf, table, format); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("%s-report-%s.%s", name, time.Now().Format("20060102"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
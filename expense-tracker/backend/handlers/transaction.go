@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -16,14 +17,31 @@ import (
 
 type TransactionHandler struct {
 	collection *mongo.Collection
+	accounts   *mongo.Collection
 }
 
 func NewTransactionHandler(db *mongo.Database) *TransactionHandler {
 	return &TransactionHandler{
 		collection: db.Collection("transactions"),
+		accounts:   db.Collection("accounts"),
 	}
 }
 
+// accountCurrency returns the currency of the account a transaction refers
+// to, either by ID or by name, falling back to the default currency.
+func (h *TransactionHandler) accountCurrency(ctx context.Context, account string) string {
+	filter := bson.M{"name": account}
+	if id, err := primitive.ObjectIDFromHex(account); err == nil {
+		filter = bson.M{"_id": id}
+	}
+
+	var acc models.Account
+	if err := h.accounts.FindOne(ctx, filter).Decode(&acc); err != nil || acc.Currency == "" {
+		return models.DefaultCurrency
+	}
+	return acc.Currency
+}
+
 func (h *TransactionHandler) GetTransactions(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -112,6 +130,10 @@ func (h *TransactionHandler) CreateTransaction(c *gin.Context) {
 		return
 	}
 
+	if transaction.Currency == "" {
+		transaction.Currency = h.accountCurrency(ctx, transaction.Account)
+	}
+	transaction.Currency = strings.ToUpper(transaction.Currency)
 	transaction.CreatedAt = time.Now()
 	transaction.UpdatedAt = time.Now()
 
@@ -141,6 +163,10 @@ func (h *TransactionHandler) UpdateTransaction(c *gin.Context) {
 		return
 	}
 
+	if transaction.Currency == "" {
+		transaction.Currency = h.accountCurrency(ctx, transaction.Account)
+	}
+	transaction.Currency = strings.ToUpper(transaction.Currency)
 	transaction.UpdatedAt = time.Now()
 
 	update := bson.M{
@@ -12,7 +12,9 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"expense-tracker/exchange"
 	"expense-tracker/handlers"
+	"expense-tracker/models"
 )
 
 var client *mongo.Client
@@ -34,7 +36,7 @@ func main() {
 		mongoURI = "mongodb://localhost:27017"
 	}
 
-	clientOptions := options.Client().ApplyURI(mongoURI)
+	clientOptions := options.Client().ApplyURI(mongoURI).SetRegistry(models.Registry())
 	var err error
 	client, err = mongo.Connect(ctx, clientOptions)
 	if err != nil {
@@ -57,7 +59,7 @@ func main() {
 	transactionHandler := handlers.NewTransactionHandler(db)
 	categoryHandler := handlers.NewCategoryHandler(db)
 	budgetHandler := handlers.NewBudgetHandler(db)
-	reportHandler := handlers.NewReportHandler(db)
+	reportHandler := handlers.NewReportHandler(db, newRateStore(), os.Getenv("REPORT_CURRENCY"))
 
 	// Initialize Gin router
 	router := gin.Default()
@@ -97,6 +99,8 @@ func main() {
 		api.GET("/reports/transactions", reportHandler.GetTransactionReport)
 		api.GET("/reports/categories", reportHandler.GetCategoryReport)
 		api.GET("/reports/budgets", reportHandler.GetBudgetReport)
+		api.GET("/reports/timeseries", reportHandler.GetTimeSeriesReport)
+		api.GET("/reports/:report/export", reportHandler.ExportReport)
 	}
 
 	// Start server
@@ -106,3 +110,26 @@ func main() {
 	}
 	router.Run(":" + port)
 }
+
+// newRateStore builds the exchange-rate store from EXCHANGE_BASE_CURRENCY,
+// an optional EXCHANGE_RATES_FILE (CSV or JSON) and an optional
+// EXCHANGE_RATES_URL for days missing from the file.
+func newRateStore() *exchange.Store {
+	base := os.Getenv("EXCHANGE_BASE_CURRENCY")
+	if base == "" {
+		base = models.DefaultCurrency
+	}
+
+	var provider exchange.Provider
+	if url := os.Getenv("EXCHANGE_RATES_URL"); url != "" {
+		provider = exchange.NewHTTPProvider(url)
+	}
+
+	store := exchange.NewStore(base, provider)
+	if path := os.Getenv("EXCHANGE_RATES_FILE"); path != "" {
+		if err := store.LoadFile(path); err != nil {
+			log.Fatalf("Failed to load exchange rates: %v", err)
+		}
+	}
+	return store
+}
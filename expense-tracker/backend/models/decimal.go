@@ -0,0 +1,98 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultCurrency is used for accounts and transactions created before
+// currencies were tracked.
+const DefaultCurrency = "USD"
+
+func init() {
+	// The frontend does arithmetic on amounts, so keep them as JSON numbers.
+	decimal.MarshalJSONWithoutQuotes = true
+}
+
+var decimalType = reflect.TypeOf(decimal.Decimal{})
+
+// Registry returns a BSON registry that stores decimal.Decimal values as
+// Decimal128. Older documents holding doubles, integers or strings are still
+// decoded.
+func Registry() *bsoncodec.Registry {
+	reg := bson.NewRegistry()
+	reg.RegisterTypeEncoder(decimalType, bsoncodec.ValueEncoderFunc(encodeDecimal))
+	reg.RegisterTypeDecoder(decimalType, bsoncodec.ValueDecoderFunc(decodeDecimal))
+	return reg
+}
+
+func encodeDecimal(_ bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+	if !val.IsValid() || val.Type() != decimalType {
+		return bsoncodec.ValueEncoderError{Name: "DecimalEncodeValue", Types: []reflect.Type{decimalType}, Received: val}
+	}
+	d128, err := primitive.ParseDecimal128(val.Interface().(decimal.Decimal).String())
+	if err != nil {
+		return err
+	}
+	return vw.WriteDecimal128(d128)
+}
+
+func decodeDecimal(_ bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+	if !val.CanSet() || val.Type() != decimalType {
+		return bsoncodec.ValueDecoderError{Name: "DecimalDecodeValue", Types: []reflect.Type{decimalType}, Received: val}
+	}
+
+	var d decimal.Decimal
+	switch vr.Type() {
+	case bsontype.Decimal128:
+		d128, err := vr.ReadDecimal128()
+		if err != nil {
+			return err
+		}
+		if d, err = decimal.NewFromString(d128.String()); err != nil {
+			return err
+		}
+	case bsontype.Double:
+		f, err := vr.ReadDouble()
+		if err != nil {
+			return err
+		}
+		d = decimal.NewFromFloat(f)
+	case bsontype.Int32:
+		i, err := vr.ReadInt32()
+		if err != nil {
+			return err
+		}
+		d = decimal.NewFromInt32(i)
+	case bsontype.Int64:
+		i, err := vr.ReadInt64()
+		if err != nil {
+			return err
+		}
+		d = decimal.NewFromInt(i)
+	case bsontype.String:
+		s, err := vr.ReadString()
+		if err != nil {
+			return err
+		}
+		if d, err = decimal.NewFromString(s); err != nil {
+			return err
+		}
+	case bsontype.Null:
+		if err := vr.ReadNull(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("cannot decode %v into a decimal", vr.Type())
+	}
+
+	val.Set(reflect.ValueOf(d))
+	return nil
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRegistry_RoundTripsDecimals(t *testing.T) {
+	in := Transaction{Amount: decimal.RequireFromString("-1234.5678"), Currency: "EUR"}
+
+	raw, err := bson.MarshalWithRegistry(Registry(), in)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if kind := bson.Raw(raw).Lookup("amount").Type; kind != bson.TypeDecimal128 {
+		t.Errorf("amount stored as %v, want decimal128", kind)
+	}
+
+	var out Transaction
+	if err := bson.UnmarshalWithRegistry(Registry(), raw, &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !out.Amount.Equal(in.Amount) {
+		t.Errorf("got %s, want %s", out.Amount, in.Amount)
+	}
+}
+
+func TestRegistry_DecodesLegacyDoubles(t *testing.T) {
+	raw, err := bson.Marshal(bson.M{"amount": -12.5, "balance": int32(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tx Transaction
+	if err := bson.UnmarshalWithRegistry(Registry(), raw, &tx); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !tx.Amount.Equal(decimal.RequireFromString("-12.5")) {
+		t.Errorf("amount = %s, want -12.5", tx.Amount)
+	}
+
+	var acc Account
+	if err := bson.UnmarshalWithRegistry(Registry(), raw, &acc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !acc.Balance.Equal(decimal.NewFromInt(7)) {
+		t.Errorf("balance = %s, want 7", acc.Balance)
+	}
+}
+
+func TestAmountsStayJSONNumbers(t *testing.T) {
+	out, err := json.Marshal(Budget{Amount: decimal.RequireFromString("10.10")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(out, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["amount"].(float64); !ok {
+		t.Errorf("amount encoded as %T, want a JSON number", fields["amount"])
+	}
+}
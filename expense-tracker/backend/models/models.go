@@ -3,70 +3,116 @@ package models
 import (
 	"time"
 
+	"github.com/shopspring/decimal"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Transaction struct {
 	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Amount      float64           `json:"amount" bson:"amount"`
-	Type        string            `json:"type" bson:"type"` // "income" or "expense"
-	Category    string            `json:"category" bson:"category"`
-	Subcategory string            `json:"subcategory,omitempty" bson:"subcategory,omitempty"`
-	Account     string            `json:"account" bson:"account"`
-	Description string            `json:"description" bson:"description"`
-	Date        time.Time         `json:"date" bson:"date"`
-	CreatedAt   time.Time         `json:"createdAt" bson:"createdAt"`
-	UpdatedAt   time.Time         `json:"updatedAt" bson:"updatedAt"`
+	Amount      decimal.Decimal    `json:"amount" bson:"amount"`
+	Currency    string             `json:"currency" bson:"currency"` // ISO 4217 code, e.g. "USD"
+	Type        string             `json:"type" bson:"type"`         // "income" or "expense"
+	Category    string             `json:"category" bson:"category"`
+	Subcategory string             `json:"subcategory,omitempty" bson:"subcategory,omitempty"`
+	Account     string             `json:"account" bson:"account"`
+	Description string             `json:"description" bson:"description"`
+	Date        time.Time          `json:"date" bson:"date"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
 }
 
 type Category struct {
 	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name          string            `json:"name" bson:"name"`
-	Subcategories []string          `json:"subcategories" bson:"subcategories"`
-	CreatedAt     time.Time         `json:"createdAt" bson:"createdAt"`
-	UpdatedAt     time.Time         `json:"updatedAt" bson:"updatedAt"`
+	Name          string             `json:"name" bson:"name"`
+	Subcategories []string           `json:"subcategories" bson:"subcategories"`
+	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt     time.Time          `json:"updatedAt" bson:"updatedAt"`
 }
 
 type Budget struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Category  string            `json:"category" bson:"category"`
-	Amount    float64           `json:"amount" bson:"amount"`
-	Spent     float64           `json:"spent" bson:"spent"`
-	Period    string            `json:"period" bson:"period"` // "weekly", "monthly", "yearly"
-	StartDate time.Time         `json:"startDate" bson:"startDate"`
-	EndDate   time.Time         `json:"endDate" bson:"endDate"`
-	CreatedAt time.Time         `json:"createdAt" bson:"createdAt"`
-	UpdatedAt time.Time         `json:"updatedAt" bson:"updatedAt"`
+	Category  string             `json:"category" bson:"category"`
+	Amount    decimal.Decimal    `json:"amount" bson:"amount"`
+	Spent     decimal.Decimal    `json:"spent" bson:"spent"`
+	Currency  string             `json:"currency" bson:"currency"` // ISO 4217 code, e.g. "USD"
+	Period    string             `json:"period" bson:"period"`     // "weekly", "monthly", "yearly"
+	StartDate time.Time          `json:"startDate" bson:"startDate"`
+	EndDate   time.Time          `json:"endDate" bson:"endDate"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
 }
 
 type Account struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name      string            `json:"name" bson:"name"`
-	Type      string            `json:"type" bson:"type"` // "bank", "cash", "mobile_money"
-	Balance   float64           `json:"balance" bson:"balance"`
-	CreatedAt time.Time         `json:"createdAt" bson:"createdAt"`
-	UpdatedAt time.Time         `json:"updatedAt" bson:"updatedAt"`
+	Name      string             `json:"name" bson:"name"`
+	Type      string             `json:"type" bson:"type"` // "bank", "cash", "mobile_money"
+	Balance   decimal.Decimal    `json:"balance" bson:"balance"`
+	Currency  string             `json:"currency" bson:"currency"` // ISO 4217 code, e.g. "USD"
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
 }
 
 type TransactionReport struct {
-	TotalIncome   float64                  `json:"totalIncome"`
-	TotalExpenses float64                  `json:"totalExpenses"`
-	NetAmount     float64                  `json:"netAmount"`
-	Transactions  []Transaction            `json:"transactions"`
+	Currency      string                   `json:"currency"`
+	TotalIncome   decimal.Decimal          `json:"totalIncome"`
+	TotalExpenses decimal.Decimal          `json:"totalExpenses"`
+	NetAmount     decimal.Decimal          `json:"netAmount"`
+	Transactions  []ConvertedTransaction   `json:"transactions"`
 	Categories    []CategoryExpenseSummary `json:"categories"`
 }
 
+// ConvertedTransaction is a transaction together with its amount in the
+// report currency at the exchange rate of the transaction date.
+type ConvertedTransaction struct {
+	Transaction     `bson:",inline"`
+	ConvertedAmount decimal.Decimal `json:"convertedAmount"`
+	ExchangeRate    decimal.Decimal `json:"exchangeRate"`
+}
+
 type CategoryExpenseSummary struct {
-	Category string  `json:"category"`
-	Amount   float64 `json:"amount"`
-	Count    int     `json:"count"`
+	Category string          `json:"category"`
+	Amount   decimal.Decimal `json:"amount"`
+	Count    int             `json:"count"`
 }
 
 type BudgetReport struct {
-	Category     string    `json:"category"`
-	BudgetAmount float64   `json:"budgetAmount"`
-	SpentAmount  float64   `json:"spentAmount"`
-	Percentage   float64   `json:"percentage"`
-	StartDate    time.Time `json:"startDate"`
-	EndDate      time.Time `json:"endDate"`
+	Category     string          `json:"category"`
+	BudgetAmount decimal.Decimal `json:"budgetAmount"`
+	SpentAmount  decimal.Decimal `json:"spentAmount"`
+	Percentage   float64         `json:"percentage"`
+	StartDate    time.Time       `json:"startDate"`
+	EndDate      time.Time       `json:"endDate"`
+}
+
+// PeriodSummary holds income and expenses for one week or month of a time
+// series. The deltas compare against the previous period and are nil for the
+// first one.
+type PeriodSummary struct {
+	Period           string           `json:"period"` // "2024-01" or "2024-W05"
+	Start            time.Time        `json:"start"`
+	End              time.Time        `json:"end"`
+	Income           decimal.Decimal  `json:"income"`
+	Expenses         decimal.Decimal  `json:"expenses"`
+	NetAmount        decimal.Decimal  `json:"netAmount"`
+	Count            int              `json:"count"`
+	IncomeDelta      *decimal.Decimal `json:"incomeDelta,omitempty"`
+	ExpenseDelta     *decimal.Decimal `json:"expenseDelta,omitempty"`
+	ExpenseChangePct *float64         `json:"expenseChangePct,omitempty"`
+}
+
+// CategoryTrend is a category's expenses per period, aligned with
+// TimeSeriesReport.Periods.
+type CategoryTrend struct {
+	Category string            `json:"category"`
+	Amounts  []decimal.Decimal `json:"amounts"`
+	Total    decimal.Decimal   `json:"total"`
+}
+
+type TimeSeriesReport struct {
+	Currency   string          `json:"currency"`
+	Interval   string          `json:"interval"` // "week" or "month"
+	StartDate  time.Time       `json:"startDate"`
+	EndDate    time.Time       `json:"endDate"`
+	Periods    []PeriodSummary `json:"periods"`
+	Categories []CategoryTrend `json:"categories"`
 }
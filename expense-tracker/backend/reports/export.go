@@ -0,0 +1,205 @@
+package reports
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+// Export formats.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+	FormatPDF  = "pdf"
+)
+
+// Table is the format-independent shape of an exported report: a header
+// block of label/value pairs followed by rows of cells.
+type Table struct {
+	Title   string
+	Summary [][2]string
+	Columns []string
+	Rows    [][]string
+}
+
+// ContentType returns the MIME type of format, or "" if it is not supported.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatPDF:
+		return "application/pdf"
+	}
+	return ""
+}
+
+// Write renders t to w in format.
+func Write(w io.Writer, t Table, format string) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, t)
+	case FormatXLSX:
+		return WriteXLSX(w, t)
+	case FormatPDF:
+		return WritePDF(w, t)
+	}
+	return fmt.Errorf("unsupported export format %q", format)
+}
+
+// WriteCSV writes the column header and rows; the summary block is left out
+// so the file can be imported as-is into spreadsheets and scripts.
+func WriteCSV(w io.Writer, t Table) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Columns); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(t.Rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// WriteXLSX writes a workbook with the report on one sheet. Cells that look
+// like numbers are stored as numbers so they can be summed in Excel.
+func WriteXLSX(w io.Writer, t Table) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := "Report"
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return err
+	}
+
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+
+	row := 1
+	setRow := func(values []string, style int) error {
+		for col, value := range values {
+			cell, err := excelize.CoordinatesToCellName(col+1, row)
+			if err != nil {
+				return err
+			}
+			if err := f.SetCellValue(sheet, cell, cellValue(value)); err != nil {
+				return err
+			}
+			if style != 0 {
+				if err := f.SetCellStyle(sheet, cell, cell, style); err != nil {
+					return err
+				}
+			}
+		}
+		row++
+		return nil
+	}
+
+	if err := setRow([]string{t.Title}, bold); err != nil {
+		return err
+	}
+	for _, kv := range t.Summary {
+		if err := setRow(kv[:], 0); err != nil {
+			return err
+		}
+	}
+	row++
+	if err := setRow(t.Columns, bold); err != nil {
+		return err
+	}
+	for _, r := range t.Rows {
+		if err := setRow(r, 0); err != nil {
+			return err
+		}
+	}
+
+	_, err = f.WriteTo(w)
+	return err
+}
+
+// cellValue converts numeric strings to float64 for spreadsheet cells; the
+// strings are already rounded report amounts, so nothing is lost.
+func cellValue(s string) interface{} {
+	if d, err := decimal.NewFromString(s); err == nil {
+		return d.InexactFloat64()
+	}
+	return s
+}
+
+// WritePDF renders t as a printable statement: title, summary block and a
+// table that continues across pages with a repeated header.
+func WritePDF(w io.Writer, t Table) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, tr(t.Title), "", 1, "L", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, kv := range t.Summary {
+		pdf.CellFormat(45, 6, tr(kv[0]), "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 6, tr(kv[1]), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	if len(t.Columns) == 0 {
+		return pdf.Output(w)
+	}
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	colWidth := (pageWidth - left - right) / float64(len(t.Columns))
+
+	header := func() {
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.SetFillColor(230, 230, 230)
+		for _, col := range t.Columns {
+			pdf.CellFormat(colWidth, 7, tr(col), "1", 0, "L", true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", 9)
+	}
+	header()
+
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	for _, r := range t.Rows {
+		if pdf.GetY()+6 > pageHeight-bottom {
+			pdf.AddPage()
+			header()
+		}
+		for i := range t.Columns {
+			var value string
+			if i < len(r) {
+				value = r[i]
+			}
+			align := "L"
+			if _, ok := cellValue(value).(float64); ok {
+				align = "R"
+			}
+			pdf.CellFormat(colWidth, 6, tr(truncate(pdf, value, colWidth-2)), "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	return pdf.Output(w)
+}
+
+func truncate(pdf *fpdf.Fpdf, s string, width float64) string {
+	if pdf.GetStringWidth(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
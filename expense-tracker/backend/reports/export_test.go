@@ -0,0 +1,72 @@
+package reports
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var sample = Table{
+	Title:   "Expenses by category",
+	Summary: [][2]string{{"Currency", "EUR"}},
+	Columns: []string{"Category", "Amount"},
+	Rows:    [][]string{{"Food, drinks", "12.50"}, {"Rent", "800.00"}},
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, sample, FormatCSV); err != nil {
+		t.Fatal(err)
+	}
+	want := "Category,Amount\n\"Food, drinks\",12.50\nRent,800.00\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, sample, FormatXLSX); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatalf("output is not a workbook: %v", err)
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows("Report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Title, one summary line, a blank line, the header and two rows
+	if len(rows) != 6 || rows[0][0] != sample.Title || rows[5][0] != "Rent" {
+		t.Fatalf("unexpected sheet contents %v", rows)
+	}
+	// Numeric cells lose the trailing zero of "12.50"; text cells would keep it
+	if rows[4][1] != "12.5" {
+		t.Errorf("amount cell = %q, want it stored as the number 12.5", rows[4][1])
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, sample, FormatPDF); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "%PDF-") {
+		t.Error("output is not a PDF document")
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, sample, "docx"); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+	if ContentType("docx") != "" {
+		t.Error("unsupported format should have no content type")
+	}
+}
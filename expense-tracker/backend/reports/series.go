@@ -0,0 +1,183 @@
+// Package reports turns pre-aggregated transaction totals into report
+// summaries and time series, and renders reports as CSV, XLSX or PDF.
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"expense-tracker/models"
+)
+
+// Supported time-series intervals.
+const (
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// Precision is the number of decimal places report amounts are rounded to.
+const Precision = 2
+
+// DailyTotal is the sum of one day's income or expenses for a category in a
+// single currency, as produced by the MongoDB aggregation. Amount is signed:
+// expenses are negative.
+type DailyTotal struct {
+	Day      time.Time
+	Category string
+	Currency string
+	Amount   decimal.Decimal
+	Count    int
+}
+
+// Summarize adds up converted daily totals into income, expenses and the
+// expenses per category, largest first.
+func Summarize(totals []DailyTotal) (income, expenses decimal.Decimal, categories []models.CategoryExpenseSummary) {
+	byCategory := make(map[string]*models.CategoryExpenseSummary)
+	for _, t := range totals {
+		if !t.Amount.IsNegative() {
+			income = income.Add(t.Amount)
+			continue
+		}
+		expenses = expenses.Sub(t.Amount)
+		summary, ok := byCategory[t.Category]
+		if !ok {
+			summary = &models.CategoryExpenseSummary{Category: t.Category}
+			byCategory[t.Category] = summary
+		}
+		summary.Amount = summary.Amount.Sub(t.Amount)
+		summary.Count += t.Count
+	}
+
+	categories = make([]models.CategoryExpenseSummary, 0, len(byCategory))
+	for _, summary := range byCategory {
+		summary.Amount = summary.Amount.Round(Precision)
+		categories = append(categories, *summary)
+	}
+	sortCategories(categories)
+	return income.Round(Precision), expenses.Round(Precision), categories
+}
+
+func sortCategories(categories []models.CategoryExpenseSummary) {
+	sort.Slice(categories, func(i, j int) bool {
+		if !categories[i].Amount.Equal(categories[j].Amount) {
+			return categories[i].Amount.GreaterThan(categories[j].Amount)
+		}
+		return categories[i].Category < categories[j].Category
+	})
+}
+
+// PeriodStart truncates t to the start of its week (Monday) or month in t's
+// location.
+func PeriodStart(t time.Time, interval string) time.Time {
+	y, m, d := t.Date()
+	if interval == IntervalWeek {
+		offset := (int(t.Weekday()) + 6) % 7 // days since Monday
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+}
+
+func nextPeriod(start time.Time, interval string) time.Time {
+	if interval == IntervalWeek {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// PeriodLabel names the period starting at start, e.g. "2024-01" or "2024-W05".
+func PeriodLabel(start time.Time, interval string) string {
+	if interval == IntervalWeek {
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return start.Format("2006-01")
+}
+
+// TimeSeries buckets converted daily totals into consecutive periods between
+// start and end, including empty ones, and computes period-over-period
+// deltas and per-category trends. Days are interpreted in loc.
+func TimeSeries(totals []DailyTotal, interval string, start, end time.Time, loc *time.Location) models.TimeSeriesReport {
+	if interval != IntervalWeek {
+		interval = IntervalMonth
+	}
+
+	var periods []models.PeriodSummary
+	index := make(map[string]int)
+	for p := PeriodStart(start.In(loc), interval); !p.After(end.In(loc)); p = nextPeriod(p, interval) {
+		label := PeriodLabel(p, interval)
+		index[label] = len(periods)
+		periods = append(periods, models.PeriodSummary{
+			Period: label,
+			Start:  p,
+			End:    nextPeriod(p, interval).Add(-time.Nanosecond),
+		})
+	}
+
+	trends := make(map[string]*models.CategoryTrend)
+	for _, t := range totals {
+		i, ok := index[PeriodLabel(PeriodStart(t.Day.In(loc), interval), interval)]
+		if !ok {
+			continue
+		}
+		p := &periods[i]
+		p.Count += t.Count
+		if !t.Amount.IsNegative() {
+			p.Income = p.Income.Add(t.Amount)
+			continue
+		}
+		p.Expenses = p.Expenses.Sub(t.Amount)
+
+		trend, ok := trends[t.Category]
+		if !ok {
+			trend = &models.CategoryTrend{Category: t.Category, Amounts: make([]decimal.Decimal, len(periods))}
+			trends[t.Category] = trend
+		}
+		trend.Amounts[i] = trend.Amounts[i].Sub(t.Amount)
+		trend.Total = trend.Total.Sub(t.Amount)
+	}
+
+	for i := range periods {
+		p := &periods[i]
+		p.Income = p.Income.Round(Precision)
+		p.Expenses = p.Expenses.Round(Precision)
+		p.NetAmount = p.Income.Sub(p.Expenses)
+		if i == 0 {
+			continue
+		}
+		prev := periods[i-1]
+		incomeDelta := p.Income.Sub(prev.Income)
+		expenseDelta := p.Expenses.Sub(prev.Expenses)
+		p.IncomeDelta = &incomeDelta
+		p.ExpenseDelta = &expenseDelta
+		if !prev.Expenses.IsZero() {
+			pct := expenseDelta.Div(prev.Expenses).Mul(decimal.NewFromInt(100)).Round(Precision).InexactFloat64()
+			p.ExpenseChangePct = &pct
+		}
+	}
+
+	categories := make([]models.CategoryTrend, 0, len(trends))
+	for _, trend := range trends {
+		for i := range trend.Amounts {
+			trend.Amounts[i] = trend.Amounts[i].Round(Precision)
+		}
+		trend.Total = trend.Total.Round(Precision)
+		categories = append(categories, *trend)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if !categories[i].Total.Equal(categories[j].Total) {
+			return categories[i].Total.GreaterThan(categories[j].Total)
+		}
+		return categories[i].Category < categories[j].Category
+	})
+
+	return models.TimeSeriesReport{
+		Interval:   interval,
+		StartDate:  start,
+		EndDate:    end,
+		Periods:    periods,
+		Categories: categories,
+	}
+}
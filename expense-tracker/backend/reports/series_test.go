@@ -0,0 +1,96 @@
+package reports
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func total(day, category, amount string) DailyTotal {
+	d, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		panic(err)
+	}
+	return DailyTotal{Day: d, Category: category, Currency: "USD", Amount: decimal.RequireFromString(amount), Count: 1}
+}
+
+func TestSummarize(t *testing.T) {
+	income, expenses, categories := Summarize([]DailyTotal{
+		total("2024-01-01", "Salary", "1000"),
+		total("2024-01-02", "Food", "-20.105"),
+		total("2024-01-03", "Rent", "-500"),
+		total("2024-01-04", "Food", "-30"),
+	})
+
+	if !income.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("income = %s, want 1000", income)
+	}
+	if !expenses.Equal(decimal.RequireFromString("550.11")) {
+		t.Errorf("expenses = %s, want 550.11", expenses)
+	}
+	if len(categories) != 2 || categories[0].Category != "Rent" || categories[1].Category != "Food" {
+		t.Fatalf("categories not sorted by amount: %+v", categories)
+	}
+	if categories[1].Count != 2 || !categories[1].Amount.Equal(decimal.RequireFromString("50.11")) {
+		t.Errorf("Food = %+v, want 50.11 over 2 transactions", categories[1])
+	}
+}
+
+func TestTimeSeries_Monthly(t *testing.T) {
+	start := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	report := TimeSeries([]DailyTotal{
+		total("2024-01-20", "Salary", "1000"),
+		total("2024-01-21", "Food", "-100"),
+		total("2024-03-01", "Food", "-150"),
+		total("2024-03-02", "Rent", "-400"),
+	}, IntervalMonth, start, end, time.UTC)
+
+	if len(report.Periods) != 3 {
+		t.Fatalf("expected 3 periods including empty February, got %d", len(report.Periods))
+	}
+	jan, feb, mar := report.Periods[0], report.Periods[1], report.Periods[2]
+	if jan.Period != "2024-01" || feb.Period != "2024-02" || mar.Period != "2024-03" {
+		t.Errorf("unexpected labels %s %s %s", jan.Period, feb.Period, mar.Period)
+	}
+	if !jan.NetAmount.Equal(decimal.NewFromInt(900)) {
+		t.Errorf("January net = %s, want 900", jan.NetAmount)
+	}
+	if jan.ExpenseDelta != nil {
+		t.Error("first period should have no delta")
+	}
+	if !feb.ExpenseDelta.Equal(decimal.NewFromInt(-100)) || *feb.ExpenseChangePct != -100 {
+		t.Errorf("February delta = %s (%v%%), want -100 (-100%%)", feb.ExpenseDelta, *feb.ExpenseChangePct)
+	}
+	if mar.ExpenseChangePct != nil {
+		t.Error("change from an empty period should be undefined")
+	}
+
+	if len(report.Categories) != 2 || report.Categories[0].Category != "Rent" {
+		t.Fatalf("unexpected categories %+v", report.Categories)
+	}
+	food := report.Categories[1]
+	if !food.Amounts[0].Equal(decimal.NewFromInt(100)) || !food.Amounts[1].IsZero() || !food.Amounts[2].Equal(decimal.NewFromInt(150)) {
+		t.Errorf("Food trend = %v, want [100 0 150]", food.Amounts)
+	}
+}
+
+func TestTimeSeries_WeeklyUsesISOWeeks(t *testing.T) {
+	start := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC) // Wednesday
+	end := time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)  // Sunday
+	report := TimeSeries([]DailyTotal{
+		total("2024-01-07", "Food", "-10"), // Sunday, still week 1
+		total("2024-01-08", "Food", "-20"), // Monday, week 2
+	}, IntervalWeek, start, end, time.UTC)
+
+	if len(report.Periods) != 2 {
+		t.Fatalf("expected 2 weeks, got %d", len(report.Periods))
+	}
+	if report.Periods[0].Period != "2024-W01" || !report.Periods[0].Start.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("first week = %s starting %s", report.Periods[0].Period, report.Periods[0].Start)
+	}
+	if !report.Periods[0].Expenses.Equal(decimal.NewFromInt(10)) || !report.Periods[1].Expenses.Equal(decimal.NewFromInt(20)) {
+		t.Errorf("weekly expenses = %s, %s", report.Periods[0].Expenses, report.Periods[1].Expenses)
+	}
+}
@@ -0,0 +1,112 @@
+package reports
+
+import (
+	"fmt"
+	"time"
+
+	"expense-tracker/models"
+)
+
+const dateFormat = "2006-01-02"
+
+func dateRange(start, end time.Time) string {
+	return fmt.Sprintf("%s to %s", start.Format(dateFormat), end.Format(dateFormat))
+}
+
+// TransactionTable lists every transaction of a transaction report.
+func TransactionTable(r models.TransactionReport, start, end time.Time) Table {
+	t := Table{
+		Title: "Transaction statement",
+		Summary: [][2]string{
+			{"Period", dateRange(start, end)},
+			{"Currency", r.Currency},
+			{"Total income", r.TotalIncome.StringFixed(Precision)},
+			{"Total expenses", r.TotalExpenses.StringFixed(Precision)},
+			{"Net amount", r.NetAmount.StringFixed(Precision)},
+		},
+		Columns: []string{"Date", "Description", "Category", "Account", "Amount", "Currency", "Rate", "Amount (" + r.Currency + ")"},
+	}
+	for _, tx := range r.Transactions {
+		t.Rows = append(t.Rows, []string{
+			tx.Date.Format(dateFormat),
+			tx.Description,
+			tx.Category,
+			tx.Account,
+			tx.Amount.String(),
+			tx.Currency,
+			tx.ExchangeRate.String(),
+			tx.ConvertedAmount.StringFixed(Precision),
+		})
+	}
+	return t
+}
+
+// CategoryTable lists expenses per category.
+func CategoryTable(categories []models.CategoryExpenseSummary, currency string, start, end time.Time) Table {
+	t := Table{
+		Title: "Expenses by category",
+		Summary: [][2]string{
+			{"Period", dateRange(start, end)},
+			{"Currency", currency},
+		},
+		Columns: []string{"Category", "Amount", "Transactions"},
+	}
+	for _, c := range categories {
+		t.Rows = append(t.Rows, []string{c.Category, c.Amount.StringFixed(Precision), fmt.Sprint(c.Count)})
+	}
+	return t
+}
+
+// BudgetTable lists spending against each budget.
+func BudgetTable(budgets []models.BudgetReport) Table {
+	t := Table{
+		Title:   "Budget report",
+		Summary: [][2]string{{"Generated", time.Now().Format(dateFormat)}},
+		Columns: []string{"Category", "Start", "End", "Budget", "Spent", "Used %"},
+	}
+	for _, b := range budgets {
+		t.Rows = append(t.Rows, []string{
+			b.Category,
+			b.StartDate.Format(dateFormat),
+			b.EndDate.Format(dateFormat),
+			b.BudgetAmount.StringFixed(Precision),
+			b.SpentAmount.StringFixed(Precision),
+			fmt.Sprintf("%.1f", b.Percentage),
+		})
+	}
+	return t
+}
+
+// TimeSeriesTable lists one row per period followed by the expenses of each
+// category in that period.
+func TimeSeriesTable(r models.TimeSeriesReport) Table {
+	t := Table{
+		Title: "Income and expenses per " + r.Interval,
+		Summary: [][2]string{
+			{"Period", dateRange(r.StartDate, r.EndDate)},
+			{"Currency", r.Currency},
+		},
+		Columns: []string{"Period", "Income", "Expenses", "Net", "Expense change %"},
+	}
+	for _, c := range r.Categories {
+		t.Columns = append(t.Columns, c.Category)
+	}
+	for i, p := range r.Periods {
+		change := ""
+		if p.ExpenseChangePct != nil {
+			change = fmt.Sprintf("%.2f", *p.ExpenseChangePct)
+		}
+		row := []string{
+			p.Period,
+			p.Income.StringFixed(Precision),
+			p.Expenses.StringFixed(Precision),
+			p.NetAmount.StringFixed(Precision),
+			change,
+		}
+		for _, c := range r.Categories {
+			row = append(row, c.Amounts[i].StringFixed(Precision))
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
@@ -1,16 +1,84 @@
 package main
 
 import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
-// AuthMiddleware is a placeholder for JWT authentication middleware
+// tokenTTL is how long a login lasts
+const tokenTTL = 7 * 24 * time.Hour
+
+var jwtSecret = loadSecret()
+
+func loadSecret() []byte {
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	log.Println("JWT_SECRET is not set; using an insecure development secret")
+	return []byte("habit-tracker-dev-secret")
+}
+
+// IssueToken signs a token identifying the user
+func IssueToken(userID int) (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   strconv.Itoa(userID),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(tokenTTL)),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+}
+
+// ParseToken checks a token and returns the user it identifies
+func ParseToken(token string) (int, error) {
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return 0, errors.New("token has no user")
+	}
+	return id, nil
+}
+
+// AuthMiddleware requires a bearer token and puts the user it identifies
+// in the context, for currentUser
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// TODO: Implement JWT authentication
-		// On failure:
-		// c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-		// return
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		userID, err := ParseToken(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		user, err := getUser(userID)
+		if err != nil {
+			// The user has been deleted since the token was issued
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Set("user", user)
 		c.Next()
 	}
 }
+
+// currentUser is the user AuthMiddleware authenticated
+func currentUser(c *gin.Context) User {
+	return c.MustGet("user").(User)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
 	_ "github.com/mattn/go-sqlite3"
 	"log"
 )
@@ -10,13 +11,19 @@ var db *sql.DB
 
 func InitDB() {
 	var err error
-	db, err = sql.Open("sqlite3", "habit_tracker.db")
+	db, err = sql.Open("sqlite3", "habit_tracker.db?_foreign_keys=on")
 	if err != nil {
 		log.Fatal("failed to open database:", err)
 	}
+	if err := migrate(db); err != nil {
+		log.Fatal("failed to create tables:", err)
+	}
+}
 
-	// Create tables if they don't exist
-	_, err = db.Exec(`
+// migrate creates the tables, and adds the columns later versions need to
+// databases made by earlier ones
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`
 	CREATE TABLE IF NOT EXISTS users (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		username TEXT UNIQUE NOT NULL,
@@ -35,8 +42,43 @@ func InitDB() {
 		date DATE NOT NULL,
 		FOREIGN KEY(habit_id) REFERENCES habits(id)
 	);
+	CREATE TABLE IF NOT EXISTS habit_freezes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		habit_id INTEGER NOT NULL,
+		date DATE NOT NULL,
+		FOREIGN KEY(habit_id) REFERENCES habits(id)
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_habit_marks_habit_date ON habit_marks(habit_id, date);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_habit_freezes_habit_date ON habit_freezes(habit_id, date);
+	CREATE INDEX IF NOT EXISTS idx_habits_user ON habits(user_id);
 	`)
 	if err != nil {
-		log.Fatal("failed to create tables:", err)
+		return err
+	}
+
+	columns := []struct{ table, name, def string }{
+		{"users", "timezone", "TEXT NOT NULL DEFAULT 'UTC'"},
+		{"habits", "schedule", "TEXT NOT NULL DEFAULT 'daily'"},
+		{"habits", "times_per_week", "INTEGER NOT NULL DEFAULT 0"},
+		{"habits", "weekdays", "INTEGER NOT NULL DEFAULT 0"},
+		{"habits", "grace_days", "INTEGER NOT NULL DEFAULT 0"},
+		{"habits", "freezes_per_month", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, col := range columns {
+		if err := addColumn(db, col.table, col.name, col.def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumn adds a column to a table unless it is already there
+func addColumn(db *sql.DB, table, column, def string) error {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n)
+	if err != nil || n > 0 {
+		return err
 	}
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, def))
+	return err
 }
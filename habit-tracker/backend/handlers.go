@@ -1,56 +1,480 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
 )
 
+// maxBackfill is how far back marks and freezes can be added
+const maxBackfill = 366
+
+type RegisterRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=32"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+	Timezone string `json:"timezone"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type TimezoneRequest struct {
+	Timezone string `json:"timezone" binding:"required"`
+}
+
+type HabitRequest struct {
+	Name            string    `json:"name" binding:"required,max=100"`
+	Schedule        *Schedule `json:"schedule"`
+	GraceDays       int       `json:"grace_days" binding:"min=0,max=7"`
+	FreezesPerMonth int       `json:"freezes_per_month" binding:"min=0,max=10"`
+}
+
+// DatesRequest names the dates to mark or freeze. With neither field the
+// date is today in the user's timezone.
+type DatesRequest struct {
+	Date  string   `json:"date"`
+	Dates []string `json:"dates" binding:"max=366"`
+}
+
+// validTimezone checks tz is an IANA timezone; "" means UTC
+func validTimezone(tz string) (string, error) {
+	if tz == "" {
+		return "UTC", nil
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return "", fmt.Errorf("unknown timezone %q", tz)
+	}
+	return tz, nil
+}
+
+// parseDate reads a YYYY-MM-DD date that is today or up to maxBackfill
+// days before it
+func parseDate(s string, today time.Time) (time.Time, error) {
+	date, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return date, fmt.Errorf("date %q is not YYYY-MM-DD", s)
+	}
+	if date.After(today) {
+		return date, fmt.Errorf("date %s is in the future", s)
+	}
+	if date.Before(today.AddDate(0, 0, -maxBackfill)) {
+		return date, fmt.Errorf("date %s is more than %d days ago", s, maxBackfill)
+	}
+	return date, nil
+}
+
+// bindDates reads the dates in an optional DatesRequest body
+func bindDates(c *gin.Context, today time.Time) ([]time.Time, bool) {
+	var req DatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if req.Date != "" {
+		req.Dates = append(req.Dates, req.Date)
+	}
+	if len(req.Dates) == 0 {
+		return []time.Time{today}, true
+	}
+	dates := make([]time.Time, 0, len(req.Dates))
+	for _, s := range req.Dates {
+		date, err := parseDate(s, today)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		dates = append(dates, date)
+	}
+	return dates, true
+}
+
+// loadHabit reads the habit in the URL for the current user, writing the
+// error response if it can't
+func loadHabit(c *gin.Context) (Habit, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid habit id"})
+		return Habit{}, false
+	}
+	habit, err := getHabit(currentUser(c).ID, id)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "habit not found"})
+		return habit, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load habit"})
+		return habit, false
+	}
+	return habit, true
+}
+
+// respondHabit sends the habit with its streak
+func respondHabit(c *gin.Context, status int, habit Habit, extra gin.H) {
+	if err := withStreak(&habit, currentUser(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute streak"})
+		return
+	}
+	body := gin.H{"habit": habit}
+	for k, v := range extra {
+		body[k] = v
+	}
+	c.JSON(status, body)
+}
+
 // User registration
 func registerHandler(c *gin.Context) {
-	// TODO: Implement registration logic
-	c.JSON(http.StatusOK, gin.H{"message": "register endpoint"})
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tz, err := validTimezone(req.Timezone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hash, err := HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register"})
+		return
+	}
+	user := User{Username: req.Username, Password: hash, Timezone: tz}
+	if err := createUser(&user); err != nil {
+		if errors.Is(err, ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "username is taken"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"user": user})
 }
 
 // User login
 func loginHandler(c *gin.Context) {
-	// TODO: Implement login logic
-	c.JSON(http.StatusOK, gin.H{"message": "login endpoint"})
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := getUserByUsername(req.Username)
+	if err != nil || !CheckPasswordHash(req.Password, user.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
+		return
+	}
+	token, err := IssueToken(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log in"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": token, "user": user})
+}
+
+// Get the logged in user
+func getMeHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"user": currentUser(c)})
+}
+
+// Change the logged in user's timezone
+func updateMeHandler(c *gin.Context) {
+	var req TimezoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tz, err := validTimezone(req.Timezone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user := currentUser(c)
+	if err := updateUserTimezone(user.ID, tz); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user"})
+		return
+	}
+	user.Timezone = tz
+	c.JSON(http.StatusOK, gin.H{"user": user})
 }
 
 // Get all habits for the user
 func getHabitsHandler(c *gin.Context) {
-	// TODO: Implement fetching habits
-	c.JSON(http.StatusOK, gin.H{"habits": []string{}})
+	user := currentUser(c)
+	habits, err := listHabits(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load habits"})
+		return
+	}
+	for i := range habits {
+		if err := withStreak(&habits[i], user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute streak"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"habits": habits})
+}
+
+// bindHabit reads a HabitRequest into habit, writing the error response
+// if it is invalid
+func bindHabit(c *gin.Context, habit *Habit) bool {
+	var req HabitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	schedule := Schedule{Kind: ScheduleDaily}
+	if req.Schedule != nil {
+		schedule = *req.Schedule
+	}
+	if err := schedule.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	habit.Name = req.Name
+	habit.Schedule = schedule
+	habit.GraceDays = req.GraceDays
+	habit.FreezesPerMonth = req.FreezesPerMonth
+	return true
 }
 
 // Create a new habit
 func createHabitHandler(c *gin.Context) {
-	// TODO: Implement habit creation
-	c.JSON(http.StatusOK, gin.H{"message": "habit created"})
+	habit := Habit{UserID: currentUser(c).ID, CreatedAt: time.Now().UTC()}
+	if !bindHabit(c, &habit) {
+		return
+	}
+	if err := createHabit(&habit); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create habit"})
+		return
+	}
+	respondHabit(c, http.StatusCreated, habit, nil)
 }
 
 // Update a habit
 func updateHabitHandler(c *gin.Context) {
-	// TODO: Implement habit update
-	c.JSON(http.StatusOK, gin.H{"message": "habit updated"})
+	habit, ok := loadHabit(c)
+	if !ok || !bindHabit(c, &habit) {
+		return
+	}
+	if err := updateHabit(habit); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update habit"})
+		return
+	}
+	respondHabit(c, http.StatusOK, habit, nil)
 }
 
 // Delete a habit
 func deleteHabitHandler(c *gin.Context) {
-	// TODO: Implement habit deletion
-	c.JSON(http.StatusOK, gin.H{"message": "habit deleted"})
+	habit, ok := loadHabit(c)
+	if !ok {
+		return
+	}
+	if err := deleteHabit(habit); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete habit"})
+		return
+	}
+	c.Status(http.StatusNoContent)
 }
 
-// Mark a habit as completed for today
+// Mark a habit as completed for today, or for the past dates in the body
 func markHabitHandler(c *gin.Context) {
-	// TODO: Implement marking habit as done
-	c.JSON(http.StatusOK, gin.H{"message": "habit marked as done"})
+	habit, ok := loadHabit(c)
+	if !ok {
+		return
+	}
+	dates, ok := bindDates(c, Today(currentUser(c).Location()))
+	if !ok {
+		return
+	}
+	added, err := addMarks(habit.ID, dates)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to mark habit"})
+		return
+	}
+	respondHabit(c, http.StatusOK, habit, gin.H{"added": added})
+}
+
+// Unmark a habit for today, or the date in ?date=
+func unmarkHabitHandler(c *gin.Context) {
+	habit, ok := loadHabit(c)
+	if !ok {
+		return
+	}
+	date := Today(currentUser(c).Location())
+	if s := c.Query("date"); s != "" {
+		var err error
+		if date, err = parseDate(s, date); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := deleteMark(habit.ID, date); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "habit is not marked on " + date.Format(DateLayout)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unmark habit"})
+		return
+	}
+	respondHabit(c, http.StatusOK, habit, nil)
+}
+
+// List the dates a habit was marked, from ?from= to ?to=; the last 30
+// days by default
+func getMarksHandler(c *gin.Context) {
+	habit, ok := loadHabit(c)
+	if !ok {
+		return
+	}
+	to := Today(currentUser(c).Location())
+	from := to.AddDate(0, 0, -29)
+	for param, date := range map[string]*time.Time{"from": &from, "to": &to} {
+		if s := c.Query(param); s != "" {
+			d, err := time.Parse(DateLayout, s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be YYYY-MM-DD"})
+				return
+			}
+			*date = d
+		}
+	}
+	dates, err := habitDates("habit_marks", habit.ID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load marks"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"marks": dates})
+}
+
+// Spend a freeze on a day the habit was missed, so the streak survives it.
+// For a weekly habit a freeze covers the whole week.
+func freezeHabitHandler(c *gin.Context) {
+	habit, ok := loadHabit(c)
+	if !ok {
+		return
+	}
+	dates, ok := bindDates(c, Today(currentUser(c).Location()))
+	if !ok {
+		return
+	}
+	if len(dates) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "freeze one date at a time"})
+		return
+	}
+	date := dates[0]
+	marked, err := habitDates("habit_marks", habit.ID, date, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to freeze habit"})
+		return
+	}
+	if len(marked) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "habit is already marked on " + date.Format(DateLayout)})
+		return
+	}
+	used, err := freezesIn(habit.ID, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to freeze habit"})
+		return
+	}
+	if used >= habit.FreezesPerMonth {
+		c.JSON(http.StatusConflict, gin.H{"error": "no freezes left for " + date.Format("January 2006")})
+		return
+	}
+	if err := addFreeze(habit.ID, date); err != nil {
+		if errors.Is(err, ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "habit is already frozen on " + date.Format(DateLayout)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to freeze habit"})
+		return
+	}
+	respondHabit(c, http.StatusCreated, habit, gin.H{"freezes_left": habit.FreezesPerMonth - used - 1})
 }
 
 // Get a single habit by ID
 func getHabitByIDHandler(c *gin.Context) {
-	// TODO: Implement fetching a single habit by ID
-	id := c.Param("id")
-	// Example: fetch habit from DB using id
-	c.JSON(http.StatusOK, gin.H{"habit_id": id, "habit": nil})
+	habit, ok := loadHabit(c)
+	if !ok {
+		return
+	}
+	respondHabit(c, http.StatusOK, habit, nil)
+}
+
+// queryInt reads an optional integer query parameter between lo and hi,
+// writing the error response if it is out of range
+func queryInt(c *gin.Context, param string, def, lo, hi int) (int, bool) {
+	s := c.Query(param)
+	if s == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < lo || n > hi {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be a number from %d to %d", param, lo, hi)})
+		return 0, false
+	}
+	return n, true
+}
+
+// Get a habit's completion rates over ?windows= (days, comma separated;
+// 7, 30, 90 and 365 by default), its best and worst weekdays, and a
+// heatmap of the last year or of ?year=
+func getHabitStatsHandler(c *gin.Context) {
+	habit, ok := loadHabit(c)
+	if !ok {
+		return
+	}
+	user := currentUser(c)
+	windows := []int{7, 30, 90, 365}
+	if s := c.Query("windows"); s != "" {
+		windows = windows[:0]
+		for _, part := range strings.Split(s, ",") {
+			days, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || days < 1 || days > maxBackfill {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("windows must be numbers of days from 1 to %d", maxBackfill)})
+				return
+			}
+			windows = append(windows, days)
+		}
+	}
+	heatmapTo := Today(user.Location())
+	if s := c.Query("year"); s != "" {
+		year, err := strconv.Atoi(s)
+		if err != nil || year < 1970 || year > heatmapTo.Year() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+			return
+		}
+		heatmapTo = time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	}
+	stats, err := HabitStatsFor(user, habit, windows, heatmapTo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute stats"})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// Get insights across all the user's habits over the last ?days= (90 by
+// default), with digests of the last ?weeks= weeks (4 by default)
+func getInsightsHandler(c *gin.Context) {
+	days, ok := queryInt(c, "days", 90, 1, maxBackfill)
+	if !ok {
+		return
+	}
+	weeks, ok := queryInt(c, "weeks", 4, 1, 52)
+	if !ok {
+		return
+	}
+	insights, err := InsightsFor(currentUser(c), days, weeks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute insights"})
+		return
+	}
+	c.JSON(http.StatusOK, insights)
 }
@@ -1,12 +1,12 @@
 package main
 
 import (
+	_ "time/tzdata" // users' timezones must load even where the OS has no zoneinfo
+
 	"github.com/gin-gonic/gin"
 )
 
-func main() {
-	InitDB()
-
+func newRouter() *gin.Engine {
 	r := gin.Default()
 
 	// Public routes
@@ -17,12 +17,26 @@ func main() {
 	auth := r.Group("/api")
 	auth.Use(AuthMiddleware())
 	{
+		auth.GET("/me", getMeHandler)
+		auth.PUT("/me", updateMeHandler)
+
 		auth.GET("/habits", getHabitsHandler)
 		auth.POST("/habits", createHabitHandler)
+		auth.GET("/habits/:id", getHabitByIDHandler)
 		auth.PUT("/habits/:id", updateHabitHandler)
 		auth.DELETE("/habits/:id", deleteHabitHandler)
 		auth.POST("/habits/:id/mark", markHabitHandler)
+		auth.DELETE("/habits/:id/mark", unmarkHabitHandler)
+		auth.GET("/habits/:id/marks", getMarksHandler)
+		auth.POST("/habits/:id/freeze", freezeHabitHandler)
+		auth.GET("/habits/:id/stats", getHabitStatsHandler)
+
+		auth.GET("/insights", getInsightsHandler)
 	}
+	return r
+}
 
-	r.Run(":8080")
+func main() {
+	InitDB()
+	newRouter().Run(":8080")
 }
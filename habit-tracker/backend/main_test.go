@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	var err error
+	db, err = sql.Open("sqlite3", ":memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a new database, so keep to one
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+}
+
+type client struct {
+	t      *testing.T
+	router *gin.Engine
+	token  string
+}
+
+// do sends body as JSON and decodes a successful response into out
+func (c *client) do(method, path string, body, out interface{}) int {
+	c.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	w := httptest.NewRecorder()
+	c.router.ServeHTTP(w, req)
+	if out != nil && w.Code < 300 {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			c.t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return w.Code
+}
+
+func signup(t *testing.T, router *gin.Engine, username, timezone string) *client {
+	c := &client{t: t, router: router}
+	body := map[string]string{"username": username, "password": "password1", "timezone": timezone}
+	if code := c.do("POST", "/register", body, nil); code != http.StatusCreated {
+		t.Fatalf("registering %s: %d", username, code)
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if code := c.do("POST", "/login", body, &resp); code != http.StatusOK {
+		t.Fatalf("logging in %s: %d", username, code)
+	}
+	c.token = resp.Token
+	return c
+}
+
+type habitResponse struct {
+	Habit       Habit `json:"habit"`
+	Added       int   `json:"added"`
+	FreezesLeft int   `json:"freezes_left"`
+}
+
+func TestHabits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+	router := newRouter()
+
+	anon := &client{t: t, router: router}
+	if code := anon.do("GET", "/api/habits", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("no token: got %d, want 401", code)
+	}
+	anon.token = "forged"
+	if code := anon.do("GET", "/api/habits", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("bad token: got %d, want 401", code)
+	}
+	if code := anon.do("POST", "/register", map[string]string{"username": "zed", "password": "password1", "timezone": "Mars/Olympus"}, nil); code != http.StatusBadRequest {
+		t.Errorf("bad timezone: got %d, want 400", code)
+	}
+
+	ann := signup(t, router, "ann", "Pacific/Kiritimati")
+	bob := signup(t, router, "bob", "")
+	if code := ann.do("POST", "/register", map[string]string{"username": "ann", "password": "password1"}, nil); code != http.StatusConflict {
+		t.Errorf("duplicate username: got %d, want 409", code)
+	}
+
+	var created habitResponse
+	body := map[string]interface{}{"name": "Run", "grace_days": 0, "freezes_per_month": 1}
+	if code := ann.do("POST", "/api/habits", body, &created); code != http.StatusCreated {
+		t.Fatalf("creating habit: %d", code)
+	}
+	habit := created.Habit
+	if habit.Schedule.Kind != ScheduleDaily || habit.Streak == nil || habit.Streak.Current != 0 {
+		t.Errorf("created = %+v", habit)
+	}
+	path := "/api/habits/" + strconv.Itoa(habit.ID)
+	if code := bob.do("GET", path, nil, nil); code != http.StatusNotFound {
+		t.Errorf("bob reading ann's habit: got %d, want 404", code)
+	}
+
+	// Today and the two days before, in ann's timezone (UTC+14)
+	today := DateOf(time.Now().In(time.FixedZone("", 14*3600)))
+	days := []string{today.AddDate(0, 0, -2).Format(DateLayout), today.AddDate(0, 0, -1).Format(DateLayout)}
+	var marked habitResponse
+	if code := ann.do("POST", path+"/mark", map[string]interface{}{"dates": days}, &marked); code != http.StatusOK || marked.Added != 2 {
+		t.Fatalf("backfilling: %d %+v", code, marked)
+	}
+	if code := ann.do("POST", path+"/mark", nil, &marked); code != http.StatusOK {
+		t.Fatalf("marking today: %d", code)
+	}
+	if s := marked.Habit.Streak; s.Current != 3 || !s.DoneToday {
+		t.Errorf("streak after marking = %+v", s)
+	}
+	if code := ann.do("POST", path+"/mark", map[string]string{"date": today.AddDate(0, 0, 1).Format(DateLayout)}, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("marking tomorrow: got %d, want 422", code)
+	}
+
+	var marks struct {
+		Marks []string `json:"marks"`
+	}
+	if code := ann.do("GET", path+"/marks", nil, &marks); code != http.StatusOK || len(marks.Marks) != 3 || marks.Marks[2] != today.Format(DateLayout) {
+		t.Errorf("marks: %d %v", code, marks.Marks)
+	}
+
+	// Unmark the middle day, then freeze it instead
+	middle := days[1]
+	if code := ann.do("DELETE", path+"/mark?date="+middle, nil, &marked); code != http.StatusOK || marked.Habit.Streak.Current != 1 {
+		t.Errorf("unmarking: %d %+v", code, marked.Habit.Streak)
+	}
+	var frozen habitResponse
+	if code := ann.do("POST", path+"/freeze", map[string]string{"date": middle}, &frozen); code != http.StatusCreated {
+		t.Fatalf("freezing: %d", code)
+	}
+	if frozen.FreezesLeft != 0 || frozen.Habit.Streak.Current != 2 {
+		t.Errorf("after freezing: %+v", frozen)
+	}
+	if code := ann.do("POST", path+"/freeze", map[string]string{"date": middle}, nil); code != http.StatusConflict {
+		t.Errorf("freezing again: got %d, want 409", code)
+	}
+
+	update := map[string]interface{}{"name": "Run", "schedule": map[string]interface{}{"kind": "weekly", "times_per_week": 3}}
+	var updated habitResponse
+	if code := ann.do("PUT", path, update, &updated); code != http.StatusOK || updated.Habit.Streak.Unit != "weeks" || updated.Habit.Streak.PeriodTarget != 3 {
+		t.Errorf("switching to weekly: %+v", updated.Habit)
+	}
+	bad := map[string]interface{}{"name": "Run", "schedule": map[string]interface{}{"kind": "weekdays"}}
+	if code := ann.do("PUT", path, bad, nil); code != http.StatusBadRequest {
+		t.Errorf("weekdays with no days: got %d, want 400", code)
+	}
+
+	if code := bob.do("PUT", "/api/me", map[string]string{"timezone": "Europe/Paris"}, nil); code != http.StatusOK {
+		t.Errorf("changing timezone: %d", code)
+	}
+	if code := ann.do("DELETE", path, nil, nil); code != http.StatusNoContent {
+		t.Errorf("deleting: %d", code)
+	}
+	var list struct {
+		Habits []Habit `json:"habits"`
+	}
+	if code := ann.do("GET", "/api/habits", nil, &list); code != http.StatusOK || len(list.Habits) != 0 {
+		t.Errorf("habits after delete: %d %+v", code, list.Habits)
+	}
+}
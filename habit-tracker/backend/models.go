@@ -7,6 +7,9 @@ type User struct {
 	ID       int    `json:"id"`
 	Username string `json:"username"`
 	Password string `json:"-"` // Hashed password
+	// Timezone is an IANA name such as "Europe/Paris". A user's days start
+	// and end at their own midnight.
+	Timezone string `json:"timezone"`
 }
 
 // Habit represents a habit tracked by a user
@@ -15,11 +18,28 @@ type Habit struct {
 	UserID    int       `json:"user_id"`
 	Name      string    `json:"name"`
 	CreatedAt time.Time `json:"created_at"`
+	Schedule  Schedule  `json:"schedule"`
+	// GraceDays is how many scheduled days, or weeks for weekly habits, can
+	// be missed in a row without breaking the streak
+	GraceDays int `json:"grace_days"`
+	// FreezesPerMonth is how many freezes the user may spend on this habit
+	// each calendar month
+	FreezesPerMonth int     `json:"freezes_per_month"`
+	Streak          *Streak `json:"streak,omitempty"`
 }
 
 // HabitMark represents a day a habit was completed
 type HabitMark struct {
-	ID      int       `json:"id"`
-	HabitID int       `json:"habit_id"`
-	Date    time.Time `json:"date"`
+	ID      int    `json:"id"`
+	HabitID int    `json:"habit_id"`
+	Date    string `json:"date"` // YYYY-MM-DD, in the user's timezone
+}
+
+// Location is the user's timezone, or UTC if it isn't one Go knows
+func (u User) Location() *time.Location {
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
 }
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// minCorrelationDays is how many days a habit needs marks on before
+// insights say what else tends to happen on those days
+const minCorrelationDays = 5
+
+// everyDay is the weekday mask of a habit done on any day
+const everyDay = 1<<7 - 1
+
+// WindowRate is how much of what was scheduled in the last Days days got
+// done. Days before the habit was created don't count.
+type WindowRate struct {
+	Days     int     `json:"days"`
+	Done     int     `json:"done"`
+	Expected int     `json:"expected"`
+	Rate     float64 `json:"rate"`
+}
+
+// WeekdayRate is how often a habit, or all of them, got done on one day of
+// the week
+type WeekdayRate struct {
+	Weekday   string  `json:"weekday"`
+	Done      int     `json:"done"`
+	Scheduled int     `json:"scheduled"`
+	Rate      float64 `json:"rate"`
+}
+
+type HeatmapDay struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// Heatmap is a calendar of marks per day. Days is sparse: days with no
+// marks are left out.
+type Heatmap struct {
+	From string       `json:"from"`
+	To   string       `json:"to"`
+	Max  int          `json:"max"`
+	Days []HeatmapDay `json:"days"`
+}
+
+type HabitStats struct {
+	HabitID      int           `json:"habit_id"`
+	Start        string        `json:"start"`
+	Windows      []WindowRate  `json:"windows"`
+	Weekdays     []WeekdayRate `json:"weekdays"`
+	BestWeekday  string        `json:"best_weekday,omitempty"`
+	WorstWeekday string        `json:"worst_weekday,omitempty"`
+	Heatmap      Heatmap       `json:"heatmap"`
+	Streak       *Streak       `json:"streak"`
+}
+
+// Correlation is how often With gets done on days Habit does, against
+// how often it gets done at all
+type Correlation struct {
+	HabitID  int     `json:"habit_id"`
+	Habit    string  `json:"habit"`
+	WithID   int     `json:"with_id"`
+	With     string  `json:"with"`
+	Days     int     `json:"days"`     // days Habit was done
+	Together int     `json:"together"` // of those, days With was done too
+	Rate     float64 `json:"rate"`
+	Baseline float64 `json:"baseline"`
+	Summary  string  `json:"summary"`
+}
+
+type WeeklyHabit struct {
+	HabitID int    `json:"habit_id"`
+	Name    string `json:"name"`
+	Done    int    `json:"done"`
+	Target  int    `json:"target"`
+}
+
+// WeeklyDigest sums up one Monday-to-Sunday week. The current week only
+// counts up to today.
+type WeeklyDigest struct {
+	WeekStart string        `json:"week_start"`
+	WeekEnd   string        `json:"week_end"`
+	Done      int           `json:"done"`
+	Target    int           `json:"target"`
+	Rate      float64       `json:"rate"`
+	Habits    []WeeklyHabit `json:"habits"`
+	Summary   string        `json:"summary"`
+}
+
+type Insights struct {
+	Days         int            `json:"days"`
+	Heatmap      Heatmap        `json:"heatmap"`
+	Weekdays     []WeekdayRate  `json:"weekdays"`
+	BestWeekday  string         `json:"best_weekday,omitempty"`
+	WorstWeekday string         `json:"worst_weekday,omitempty"`
+	Correlations []Correlation  `json:"correlations"`
+	Weekly       []WeeklyDigest `json:"weekly"`
+}
+
+func ratio(done, expected int) float64 {
+	if expected == 0 {
+		return 0
+	}
+	return float64(done) / float64(expected)
+}
+
+// habitMask is the weekday mask of the days a habit counts on
+func habitMask(h Habit) int {
+	if h.Schedule.Kind == ScheduleWeekdays {
+		mask, _ := weekdayMask(h.Schedule.Weekdays)
+		return mask
+	}
+	return everyDay
+}
+
+// weekdayCounts counts the days from from to to inclusive that fall on
+// each weekday, indexed by time.Weekday
+func weekdayCounts(from, to time.Time) [7]int {
+	var counts [7]int
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		counts[day.Weekday()]++
+	}
+	return counts
+}
+
+// habitStart is the first day a habit counts from in the user's timezone:
+// the day it was created, or its first mark if that was backfilled
+// earlier
+func habitStart(h Habit, loc *time.Location) (time.Time, error) {
+	start := DateOf(h.CreatedAt.In(loc))
+	// MIN loses the column's DATE type, so this comes back as text
+	var first sql.NullString
+	if err := db.QueryRow(`SELECT MIN(date) FROM habit_marks WHERE habit_id = ?`, h.ID).Scan(&first); err != nil {
+		return start, err
+	}
+	if !first.Valid {
+		return start, nil
+	}
+	date, err := time.Parse(DateLayout, first.String[:min(len(first.String), len(DateLayout))])
+	if err != nil {
+		return start, err
+	}
+	if date.Before(start) {
+		start = date
+	}
+	return start, nil
+}
+
+// windowRate works out a daily or weekdays habit's rate over from..today,
+// leaving today out of what was expected until it is marked
+func windowRate(h Habit, from, today time.Time) (WindowRate, error) {
+	rate := WindowRate{Days: int(today.Sub(from).Hours()/24) + 1}
+	if h.Schedule.Kind == ScheduleWeekly {
+		return weeklyWindowRate(h, from, today, rate)
+	}
+	mask := habitMask(h)
+	var doneToday int
+	err := db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(date = ?), 0) FROM habit_marks
+		WHERE habit_id = ? AND date >= ? AND date <= ? AND (? >> CAST(strftime('%w', date) AS INTEGER)) & 1`,
+		today.Format(DateLayout), h.ID, from.Format(DateLayout), today.Format(DateLayout), mask).Scan(&rate.Done, &doneToday)
+	if err != nil {
+		return rate, err
+	}
+	counts := weekdayCounts(from, today)
+	for wd, n := range counts {
+		if mask&(1<<wd) != 0 {
+			rate.Expected += n
+		}
+	}
+	if doneToday == 0 && mask&(1<<today.Weekday()) != 0 {
+		rate.Expected--
+	}
+	rate.Rate = ratio(rate.Done, rate.Expected)
+	return rate, nil
+}
+
+// weekCounts counts a habit's marks in each Monday-to-Sunday week from
+// from to to, keyed by the Monday
+func weekCounts(habitID int, from, to time.Time) (map[string]int, error) {
+	rows, err := db.Query(`SELECT date(date, '-' || ((CAST(strftime('%w', date) AS INTEGER) + 6) % 7) || ' days') AS week, COUNT(*)
+		FROM habit_marks WHERE habit_id = ? AND date >= ? AND date <= ? GROUP BY week`,
+		habitID, from.Format(DateLayout), to.Format(DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var week string
+		var n int
+		if err := rows.Scan(&week, &n); err != nil {
+			return nil, err
+		}
+		counts[week] = n
+	}
+	return counts, rows.Err()
+}
+
+// weeklyWindowRate counts each week up to its target. The week in
+// progress only counts once it is done, so it doesn't drag the rate down.
+func weeklyWindowRate(h Habit, from, today time.Time, rate WindowRate) (WindowRate, error) {
+	counts, err := weekCounts(h.ID, weekStart(from), today)
+	if err != nil {
+		return rate, err
+	}
+	target := h.Schedule.TimesPerWeek
+	for week := weekStart(from); !week.After(today); week = week.AddDate(0, 0, 7) {
+		done := min(counts[week.Format(DateLayout)], target)
+		if week.Equal(weekStart(today)) && done < target {
+			continue
+		}
+		rate.Done += done
+		rate.Expected += target
+	}
+	rate.Rate = ratio(rate.Done, rate.Expected)
+	return rate, nil
+}
+
+// weekdayRates counts the habits' marks by weekday from from to to,
+// against how many of those days each was due since its start
+func weekdayRates(userID int, habits []Habit, from, to time.Time, starts map[int]time.Time) ([]WeekdayRate, error) {
+	ids := make([]interface{}, 0, len(habits)+3)
+	ids = append(ids, userID, from.Format(DateLayout), to.Format(DateLayout))
+	placeholders := make([]string, len(habits))
+	for i, h := range habits {
+		ids = append(ids, h.ID)
+		placeholders[i] = "?"
+	}
+	rates := make([]WeekdayRate, 7)
+	for wd := range rates {
+		rates[wd].Weekday = weekdayNames[wd]
+	}
+	if len(habits) == 0 {
+		return rates, nil
+	}
+	rows, err := db.Query(`SELECT CAST(strftime('%w', m.date) AS INTEGER) AS wd, COUNT(*)
+		FROM habit_marks m JOIN habits h ON h.id = m.habit_id
+		WHERE h.user_id = ? AND m.date >= ? AND m.date <= ? AND h.id IN (`+strings.Join(placeholders, ",")+`)
+		AND (CASE h.schedule WHEN 'weekdays' THEN (h.weekdays >> CAST(strftime('%w', m.date) AS INTEGER)) & 1 ELSE 1 END)
+		GROUP BY wd`, ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var wd, n int
+		if err := rows.Scan(&wd, &n); err != nil {
+			return nil, err
+		}
+		rates[wd].Done = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, h := range habits {
+		first := from
+		if starts[h.ID].After(first) {
+			first = starts[h.ID]
+		}
+		mask := habitMask(h)
+		for wd, n := range weekdayCounts(first, to) {
+			if mask&(1<<wd) != 0 {
+				rates[wd].Scheduled += n
+			}
+		}
+	}
+	for wd := range rates {
+		rates[wd].Rate = ratio(rates[wd].Done, rates[wd].Scheduled)
+	}
+	// Monday first
+	return append(rates[1:], rates[0]), nil
+}
+
+// bestAndWorst picks the weekdays with the highest and lowest rates among
+// those something was scheduled on
+func bestAndWorst(rates []WeekdayRate) (string, string) {
+	best, worst := -1, -1
+	for i, r := range rates {
+		if r.Scheduled == 0 {
+			continue
+		}
+		if best < 0 || r.Rate > rates[best].Rate {
+			best = i
+		}
+		if worst < 0 || r.Rate < rates[worst].Rate {
+			worst = i
+		}
+	}
+	if best < 0 {
+		return "", ""
+	}
+	return rates[best].Weekday, rates[worst].Weekday
+}
+
+// heatmap counts marks per day from from to to, for one habit or, with
+// habitID 0, all the user's habits
+func heatmap(userID, habitID int, from, to time.Time) (Heatmap, error) {
+	hm := Heatmap{From: from.Format(DateLayout), To: to.Format(DateLayout), Days: []HeatmapDay{}}
+	rows, err := db.Query(`SELECT m.date, COUNT(*) FROM habit_marks m JOIN habits h ON h.id = m.habit_id
+		WHERE h.user_id = ? AND (? = 0 OR h.id = ?) AND m.date >= ? AND m.date <= ?
+		GROUP BY m.date ORDER BY m.date`,
+		userID, habitID, habitID, hm.From, hm.To)
+	if err != nil {
+		return hm, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var date time.Time
+		var day HeatmapDay
+		if err := rows.Scan(&date, &day.Count); err != nil {
+			return hm, err
+		}
+		day.Date = date.Format(DateLayout)
+		hm.Max = max(hm.Max, day.Count)
+		hm.Days = append(hm.Days, day)
+	}
+	return hm, rows.Err()
+}
+
+// HabitStatsFor works out a habit's stats as of today in the user's
+// timezone, with a completion rate for each of windows and a heatmap of
+// the year ending on heatmapTo
+func HabitStatsFor(user User, h Habit, windows []int, heatmapTo time.Time) (HabitStats, error) {
+	loc := user.Location()
+	today := Today(loc)
+	stats := HabitStats{HabitID: h.ID, Windows: []WindowRate{}}
+	start, err := habitStart(h, loc)
+	if err != nil {
+		return stats, err
+	}
+	stats.Start = start.Format(DateLayout)
+
+	for _, days := range windows {
+		from := today.AddDate(0, 0, 1-days)
+		if from.Before(start) {
+			from = start
+		}
+		rate, err := windowRate(h, from, today)
+		if err != nil {
+			return stats, err
+		}
+		rate.Days = days
+		stats.Windows = append(stats.Windows, rate)
+	}
+
+	yearAgo := today.AddDate(-1, 0, 1)
+	if yearAgo.Before(start) {
+		yearAgo = start
+	}
+	if stats.Weekdays, err = weekdayRates(user.ID, []Habit{h}, yearAgo, today, map[int]time.Time{h.ID: start}); err != nil {
+		return stats, err
+	}
+	stats.BestWeekday, stats.WorstWeekday = bestAndWorst(stats.Weekdays)
+
+	if stats.Heatmap, err = heatmap(user.ID, h.ID, heatmapTo.AddDate(-1, 0, 1), heatmapTo); err != nil {
+		return stats, err
+	}
+	if err := withStreak(&h, user); err != nil {
+		return stats, err
+	}
+	stats.Streak = h.Streak
+	return stats, nil
+}
+
+// correlations finds, for each pair of habits, how often the second was
+// done on days the first was, from from to to
+func correlations(userID int, habits []Habit, from, to time.Time, starts map[int]time.Time) ([]Correlation, error) {
+	names := make(map[int]string, len(habits))
+	for _, h := range habits {
+		names[h.ID] = h.Name
+	}
+	markDays := make(map[int]int)
+	rows, err := db.Query(`SELECT m.habit_id, COUNT(*) FROM habit_marks m JOIN habits h ON h.id = m.habit_id
+		WHERE h.user_id = ? AND m.date >= ? AND m.date <= ? GROUP BY m.habit_id`,
+		userID, from.Format(DateLayout), to.Format(DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id, n int
+		if err := rows.Scan(&id, &n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		markDays[id] = n
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT a.habit_id, b.habit_id, COUNT(*)
+		FROM habit_marks a
+		JOIN habit_marks b ON b.date = a.date AND b.habit_id <> a.habit_id
+		JOIN habits ha ON ha.id = a.habit_id
+		JOIN habits hb ON hb.id = b.habit_id
+		WHERE ha.user_id = ? AND hb.user_id = ? AND a.date >= ? AND a.date <= ?
+		GROUP BY a.habit_id, b.habit_id`,
+		userID, userID, from.Format(DateLayout), to.Format(DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	found := []Correlation{}
+	for rows.Next() {
+		var c Correlation
+		if err := rows.Scan(&c.HabitID, &c.WithID, &c.Together); err != nil {
+			return nil, err
+		}
+		c.Days = markDays[c.HabitID]
+		if c.Days < minCorrelationDays {
+			continue
+		}
+		c.Habit, c.With = names[c.HabitID], names[c.WithID]
+		c.Rate = ratio(c.Together, c.Days)
+		first := from
+		if starts[c.WithID].After(first) {
+			first = starts[c.WithID]
+		}
+		c.Baseline = ratio(markDays[c.WithID], int(to.Sub(first).Hours()/24)+1)
+		c.Summary = fmt.Sprintf("On days you do %q you also do %q %.0f%% of the time, against %.0f%% overall",
+			c.Habit, c.With, c.Rate*100, c.Baseline*100)
+		found = append(found, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// The strongest links first: how much more likely, then how often seen
+	sort.Slice(found, func(i, j int) bool {
+		li, lj := found[i].Rate-found[i].Baseline, found[j].Rate-found[j].Baseline
+		if li != lj {
+			return li > lj
+		}
+		return found[i].Together > found[j].Together
+	})
+	return found, nil
+}
+
+// weeklyDigests sums up the last weeks weeks, newest first
+func weeklyDigests(habits []Habit, weeks int, today time.Time, starts map[int]time.Time) ([]WeeklyDigest, error) {
+	thisWeek := weekStart(today)
+	first := thisWeek.AddDate(0, 0, -7*(weeks-1))
+	counts := make(map[int]map[string]int, len(habits))
+	for _, h := range habits {
+		var err error
+		if h.Schedule.Kind == ScheduleWeekdays {
+			counts[h.ID], err = scheduledWeekCounts(h, first, today)
+		} else {
+			counts[h.ID], err = weekCounts(h.ID, first, today)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	digests := []WeeklyDigest{}
+	for week := thisWeek; !week.Before(first); week = week.AddDate(0, 0, -7) {
+		end := week.AddDate(0, 0, 6)
+		last := end
+		if last.After(today) {
+			last = today
+		}
+		d := WeeklyDigest{WeekStart: week.Format(DateLayout), WeekEnd: end.Format(DateLayout), Habits: []WeeklyHabit{}}
+		for _, h := range habits {
+			from := week
+			if starts[h.ID].After(from) {
+				from = starts[h.ID]
+			}
+			if from.After(last) {
+				continue
+			}
+			wh := WeeklyHabit{HabitID: h.ID, Name: h.Name, Done: counts[h.ID][d.WeekStart]}
+			switch h.Schedule.Kind {
+			case ScheduleWeekly:
+				wh.Target = h.Schedule.TimesPerWeek
+			default:
+				mask := habitMask(h)
+				for wd, n := range weekdayCounts(from, last) {
+					if mask&(1<<wd) != 0 {
+						wh.Target += n
+					}
+				}
+			}
+			wh.Done = min(wh.Done, wh.Target)
+			d.Done += wh.Done
+			d.Target += wh.Target
+			d.Habits = append(d.Habits, wh)
+		}
+		d.Rate = ratio(d.Done, d.Target)
+		d.Summary = digestSummary(d)
+		digests = append(digests, d)
+	}
+	return digests, nil
+}
+
+// scheduledWeekCounts is weekCounts counting only the habit's weekdays
+func scheduledWeekCounts(h Habit, from, to time.Time) (map[string]int, error) {
+	rows, err := db.Query(`SELECT date(date, '-' || ((CAST(strftime('%w', date) AS INTEGER) + 6) % 7) || ' days') AS week, COUNT(*)
+		FROM habit_marks WHERE habit_id = ? AND date >= ? AND date <= ? AND (? >> CAST(strftime('%w', date) AS INTEGER)) & 1
+		GROUP BY week`,
+		h.ID, from.Format(DateLayout), to.Format(DateLayout), habitMask(h))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var week string
+		var n int
+		if err := rows.Scan(&week, &n); err != nil {
+			return nil, err
+		}
+		counts[week] = n
+	}
+	return counts, rows.Err()
+}
+
+// digestSummary writes a week up in a sentence or three
+func digestSummary(d WeeklyDigest) string {
+	if d.Target == 0 {
+		return "Nothing was scheduled this week."
+	}
+	summary := fmt.Sprintf("You completed %d of %d check-ins (%.0f%%).", d.Done, d.Target, d.Rate*100)
+	var best, worst *WeeklyHabit
+	for i := range d.Habits {
+		h := &d.Habits[i]
+		if h.Target == 0 {
+			continue
+		}
+		if best == nil || ratio(h.Done, h.Target) > ratio(best.Done, best.Target) {
+			best = h
+		}
+		if worst == nil || ratio(h.Done, h.Target) < ratio(worst.Done, worst.Target) {
+			worst = h
+		}
+	}
+	if best != nil && best.Done > 0 {
+		summary += fmt.Sprintf(" Best: %s (%d/%d).", best.Name, best.Done, best.Target)
+	}
+	if worst != nil && worst != best && worst.Done < worst.Target {
+		summary += fmt.Sprintf(" Needs attention: %s (%d/%d).", worst.Name, worst.Done, worst.Target)
+	}
+	return summary
+}
+
+// InsightsFor works out insights across all the user's habits over the
+// last days days, with digests for the last weeks weeks
+func InsightsFor(user User, days, weeks int) (Insights, error) {
+	today := Today(user.Location())
+	from := today.AddDate(0, 0, 1-days)
+	insights := Insights{Days: days}
+	habits, err := listHabits(user.ID)
+	if err != nil {
+		return insights, err
+	}
+	starts := make(map[int]time.Time, len(habits))
+	for _, h := range habits {
+		if starts[h.ID], err = habitStart(h, user.Location()); err != nil {
+			return insights, err
+		}
+	}
+
+	if insights.Heatmap, err = heatmap(user.ID, 0, today.AddDate(-1, 0, 1), today); err != nil {
+		return insights, err
+	}
+	if insights.Weekdays, err = weekdayRates(user.ID, habits, from, today, starts); err != nil {
+		return insights, err
+	}
+	insights.BestWeekday, insights.WorstWeekday = bestAndWorst(insights.Weekdays)
+	if insights.Correlations, err = correlations(user.ID, habits, from, today, starts); err != nil {
+		return insights, err
+	}
+	if len(insights.Correlations) > 10 {
+		insights.Correlations = insights.Correlations[:10]
+	}
+	insights.Weekly, err = weeklyDigests(habits, weeks, today, starts)
+	return insights, err
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStatsAndInsights(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+	router := newRouter()
+	ann := signup(t, router, "ann", "")
+	bob := signup(t, router, "bob", "")
+
+	create := func(body map[string]interface{}) string {
+		var resp habitResponse
+		if code := ann.do("POST", "/api/habits", body, &resp); code != http.StatusCreated {
+			t.Fatalf("creating %v: %d", body["name"], code)
+		}
+		return "/api/habits/" + strconv.Itoa(resp.Habit.ID)
+	}
+	run := create(map[string]interface{}{"name": "Run"})
+	read := create(map[string]interface{}{"name": "Read"})
+	gym := create(map[string]interface{}{"name": "Gym", "schedule": map[string]interface{}{"kind": "weekdays", "weekdays": []string{"mon", "wed", "fri"}}})
+
+	// Run every day for the last ten days, Read every other day of them
+	today := Today(time.UTC)
+	var runDays, readDays []string
+	for i := 9; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i).Format(DateLayout)
+		runDays = append(runDays, day)
+		if i%2 == 0 {
+			readDays = append(readDays, day)
+		}
+	}
+	for path, days := range map[string][]string{run: runDays, read: readDays} {
+		if code := ann.do("POST", path+"/mark", map[string]interface{}{"dates": days}, nil); code != http.StatusOK {
+			t.Fatalf("marking %s: %d", path, code)
+		}
+	}
+
+	var stats HabitStats
+	if code := ann.do("GET", run+"/stats?windows=7,30", nil, &stats); code != http.StatusOK {
+		t.Fatalf("run stats: %d", code)
+	}
+	if stats.Start != runDays[0] {
+		t.Errorf("start = %s, want the first mark %s", stats.Start, runDays[0])
+	}
+	if len(stats.Windows) != 2 || stats.Windows[0] != (WindowRate{Days: 7, Done: 7, Expected: 7, Rate: 1}) ||
+		stats.Windows[1] != (WindowRate{Days: 30, Done: 10, Expected: 10, Rate: 1}) {
+		t.Errorf("windows = %+v", stats.Windows)
+	}
+	if len(stats.Heatmap.Days) != 10 || stats.Heatmap.Max != 1 || stats.Heatmap.To != today.Format(DateLayout) {
+		t.Errorf("heatmap = %+v", stats.Heatmap)
+	}
+	if len(stats.Weekdays) != 7 || stats.Weekdays[0].Weekday != "mon" || stats.Streak == nil || stats.Streak.Current != 10 {
+		t.Errorf("weekdays = %+v, streak = %+v", stats.Weekdays, stats.Streak)
+	}
+
+	// Gym was only created today and is due three days a week; today
+	// only counts once it's marked
+	if code := ann.do("GET", gym+"/stats?windows=7", nil, &stats); code != http.StatusOK {
+		t.Fatalf("gym stats: %d", code)
+	}
+	if w := stats.Windows[0]; w.Done != 0 || w.Expected != 0 {
+		t.Errorf("gym window = %+v", w)
+	}
+	if stats.BestWeekday != stats.WorstWeekday {
+		t.Errorf("gym best %q and worst %q weekdays differ at 0%%", stats.BestWeekday, stats.WorstWeekday)
+	}
+	for _, wd := range stats.Weekdays {
+		if wd.Scheduled > 0 && wd.Weekday != "mon" && wd.Weekday != "wed" && wd.Weekday != "fri" {
+			t.Errorf("gym scheduled on %s", wd.Weekday)
+		}
+	}
+
+	if code := ann.do("GET", run+"/stats?year=2020", nil, &stats); code != http.StatusOK || stats.Heatmap.From != "2020-01-01" || stats.Heatmap.To != "2020-12-31" || len(stats.Heatmap.Days) != 0 {
+		t.Errorf("2020 heatmap: %d %+v", code, stats.Heatmap)
+	}
+	for _, query := range []string{"?windows=0", "?windows=7,x", "?year=3000"} {
+		if code := ann.do("GET", run+"/stats"+query, nil, nil); code != http.StatusBadRequest {
+			t.Errorf("stats%s: got %d, want 400", query, code)
+		}
+	}
+	if code := bob.do("GET", run+"/stats", nil, nil); code != http.StatusNotFound {
+		t.Errorf("bob reading ann's stats: got %d, want 404", code)
+	}
+
+	var insights Insights
+	if code := ann.do("GET", "/api/insights?days=30&weeks=2", nil, &insights); code != http.StatusOK {
+		t.Fatalf("insights: %d", code)
+	}
+	if insights.Days != 30 || insights.Heatmap.Max != 2 || len(insights.Heatmap.Days) != 10 {
+		t.Errorf("insights heatmap = %+v", insights.Heatmap)
+	}
+	rates := make(map[string]Correlation)
+	for _, c := range insights.Correlations {
+		rates[c.Habit+">"+c.With] = c
+	}
+	if c := rates["Run>Read"]; c.Days != 10 || c.Together != 5 || c.Rate != 0.5 {
+		t.Errorf("Run>Read = %+v", c)
+	}
+	// Read only has five marks, the fewest that count
+	if c := rates["Read>Run"]; c.Days != 5 || c.Together != 5 || c.Rate != 1 || c.Baseline != 1 || c.Summary == "" {
+		t.Errorf("Read>Run = %+v", c)
+	}
+	if len(insights.Weekly) != 2 || insights.Weekly[0].WeekStart != weekStart(today).Format(DateLayout) {
+		t.Fatalf("weekly = %+v", insights.Weekly)
+	}
+	for _, h := range insights.Weekly[0].Habits {
+		if h.Name == "Run" && (h.Done != h.Target || h.Done == 0) {
+			t.Errorf("this week's Run = %+v", h)
+		}
+	}
+	if insights.Weekly[0].Summary == "" {
+		t.Error("weekly digest has no summary")
+	}
+	if code := ann.do("GET", "/api/insights?days=400", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("days=400: got %d, want 400", code)
+	}
+
+	var empty Insights
+	if code := bob.do("GET", "/api/insights", nil, &empty); code != http.StatusOK || len(empty.Correlations) != 0 || len(empty.Heatmap.Days) != 0 {
+		t.Errorf("bob's insights: %d %+v", code, empty)
+	}
+}
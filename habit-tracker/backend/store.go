@@ -0,0 +1,235 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrDuplicate = errors.New("already exists")
+)
+
+// isUnique reports whether err is a unique constraint failing
+func isUnique(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+func createUser(user *User) error {
+	res, err := db.Exec(`INSERT INTO users (username, password, timezone) VALUES (?, ?, ?)`,
+		user.Username, user.Password, user.Timezone)
+	if isUnique(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	user.ID = int(id)
+	return err
+}
+
+func scanUser(row *sql.Row) (User, error) {
+	var user User
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.Timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrNotFound
+	}
+	return user, err
+}
+
+func getUser(id int) (User, error) {
+	return scanUser(db.QueryRow(`SELECT id, username, password, timezone FROM users WHERE id = ?`, id))
+}
+
+func getUserByUsername(username string) (User, error) {
+	return scanUser(db.QueryRow(`SELECT id, username, password, timezone FROM users WHERE username = ?`, username))
+}
+
+func updateUserTimezone(id int, timezone string) error {
+	_, err := db.Exec(`UPDATE users SET timezone = ? WHERE id = ?`, timezone, id)
+	return err
+}
+
+const habitColumns = `id, user_id, name, created_at, schedule, times_per_week, weekdays, grace_days, freezes_per_month`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanHabit(row scanner) (Habit, error) {
+	var h Habit
+	var mask int
+	err := row.Scan(&h.ID, &h.UserID, &h.Name, &h.CreatedAt, &h.Schedule.Kind,
+		&h.Schedule.TimesPerWeek, &mask, &h.GraceDays, &h.FreezesPerMonth)
+	if errors.Is(err, sql.ErrNoRows) {
+		return h, ErrNotFound
+	}
+	h.Schedule.Weekdays = maskWeekdays(mask)
+	return h, err
+}
+
+func listHabits(userID int) ([]Habit, error) {
+	rows, err := db.Query(`SELECT `+habitColumns+` FROM habits WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	habits := []Habit{}
+	for rows.Next() {
+		h, err := scanHabit(rows)
+		if err != nil {
+			return nil, err
+		}
+		habits = append(habits, h)
+	}
+	return habits, rows.Err()
+}
+
+// getHabit reads one of the user's habits; other people's are not found
+func getHabit(userID, id int) (Habit, error) {
+	return scanHabit(db.QueryRow(`SELECT `+habitColumns+` FROM habits WHERE id = ? AND user_id = ?`, id, userID))
+}
+
+func createHabit(h *Habit) error {
+	mask, _ := weekdayMask(h.Schedule.Weekdays)
+	res, err := db.Exec(`INSERT INTO habits (user_id, name, created_at, schedule, times_per_week, weekdays, grace_days, freezes_per_month)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		h.UserID, h.Name, h.CreatedAt, h.Schedule.Kind, h.Schedule.TimesPerWeek, mask, h.GraceDays, h.FreezesPerMonth)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	h.ID = int(id)
+	return err
+}
+
+func updateHabit(h Habit) error {
+	mask, _ := weekdayMask(h.Schedule.Weekdays)
+	_, err := db.Exec(`UPDATE habits SET name = ?, schedule = ?, times_per_week = ?, weekdays = ?, grace_days = ?, freezes_per_month = ?
+		WHERE id = ? AND user_id = ?`,
+		h.Name, h.Schedule.Kind, h.Schedule.TimesPerWeek, mask, h.GraceDays, h.FreezesPerMonth, h.ID, h.UserID)
+	return err
+}
+
+func deleteHabit(h Habit) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, query := range []string{
+		`DELETE FROM habit_marks WHERE habit_id = ?`,
+		`DELETE FROM habit_freezes WHERE habit_id = ?`,
+		`DELETE FROM habits WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(query, h.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// habitDates reads the dates in habit_marks or habit_freezes for a habit
+// from from to to inclusive, in order
+func habitDates(table string, habitID int, from, to time.Time) ([]string, error) {
+	rows, err := db.Query(`SELECT date FROM `+table+` WHERE habit_id = ? AND date >= ? AND date <= ? ORDER BY date`,
+		habitID, from.Format(DateLayout), to.Format(DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	dates := []string{}
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			return nil, err
+		}
+		dates = append(dates, date.Format(DateLayout))
+	}
+	return dates, rows.Err()
+}
+
+// addMarks marks the habit done on each date, skipping ones already
+// marked, and returns how many were new
+func addMarks(habitID int, dates []time.Time) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	added := 0
+	for _, date := range dates {
+		res, err := tx.Exec(`INSERT OR IGNORE INTO habit_marks (habit_id, date) VALUES (?, ?)`, habitID, date.Format(DateLayout))
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		added += int(n)
+	}
+	return added, tx.Commit()
+}
+
+func deleteMark(habitID int, date time.Time) error {
+	res, err := db.Exec(`DELETE FROM habit_marks WHERE habit_id = ? AND date = ?`, habitID, date.Format(DateLayout))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// freezesIn counts the freezes used on the habit in date's month
+func freezesIn(habitID int, date time.Time) (int, error) {
+	first := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM habit_freezes WHERE habit_id = ? AND date >= ? AND date < ?`,
+		habitID, first.Format(DateLayout), first.AddDate(0, 1, 0).Format(DateLayout)).Scan(&n)
+	return n, err
+}
+
+func addFreeze(habitID int, date time.Time) error {
+	_, err := db.Exec(`INSERT INTO habit_freezes (habit_id, date) VALUES (?, ?)`, habitID, date.Format(DateLayout))
+	if isUnique(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// withStreak works out the habit's streak as of today in the user's
+// timezone
+func withStreak(h *Habit, user User) error {
+	loc := user.Location()
+	today := Today(loc)
+	history := HabitHistory{
+		Schedule:  h.Schedule,
+		GraceDays: h.GraceDays,
+		Start:     DateOf(h.CreatedAt.In(loc)),
+		Today:     today,
+		Marks:     make(map[string]bool),
+		Freezes:   make(map[string]bool),
+	}
+	// Everything up to today; a streak can run back to the first mark
+	epoch := time.Time{}
+	for _, set := range []struct {
+		table string
+		dates map[string]bool
+	}{{"habit_marks", history.Marks}, {"habit_freezes", history.Freezes}} {
+		dates, err := habitDates(set.table, h.ID, epoch, today)
+		if err != nil {
+			return err
+		}
+		for _, date := range dates {
+			set.dates[date] = true
+		}
+	}
+	streak := ComputeStreak(history)
+	h.Streak = &streak
+	return nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// DateLayout is how dates are written in the API and stored in the
+// database: calendar days with no time or zone
+const DateLayout = "2006-01-02"
+
+// Schedule kinds
+const (
+	ScheduleDaily    = "daily"    // every day
+	ScheduleWeekly   = "weekly"   // TimesPerWeek days in each Monday-to-Sunday week
+	ScheduleWeekdays = "weekdays" // on each of Weekdays
+)
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Schedule says how often a habit should be done
+type Schedule struct {
+	Kind         string   `json:"kind"`
+	TimesPerWeek int      `json:"times_per_week,omitempty"`
+	Weekdays     []string `json:"weekdays,omitempty"`
+}
+
+// Validate checks the schedule and puts Weekdays in order
+func (s *Schedule) Validate() error {
+	switch s.Kind {
+	case ScheduleDaily:
+		s.TimesPerWeek, s.Weekdays = 0, nil
+	case ScheduleWeekly:
+		if s.TimesPerWeek < 1 || s.TimesPerWeek > 7 {
+			return errors.New("times_per_week must be from 1 to 7")
+		}
+		s.Weekdays = nil
+	case ScheduleWeekdays:
+		mask, err := weekdayMask(s.Weekdays)
+		if err != nil {
+			return err
+		}
+		if mask == 0 {
+			return errors.New("weekdays must name at least one day")
+		}
+		s.TimesPerWeek, s.Weekdays = 0, maskWeekdays(mask)
+	default:
+		return errors.New("schedule kind must be daily, weekly or weekdays")
+	}
+	return nil
+}
+
+// weekdayMask turns day names into a bitmask with bit time.Weekday set
+// for each day
+func weekdayMask(days []string) (int, error) {
+	mask := 0
+	for _, day := range days {
+		i := indexOf(weekdayNames, strings.ToLower(day))
+		if i < 0 {
+			return 0, errors.New("weekdays must be sun, mon, tue, wed, thu, fri or sat")
+		}
+		mask |= 1 << i
+	}
+	return mask, nil
+}
+
+func maskWeekdays(mask int) []string {
+	var days []string
+	// Monday first, as people write weeks
+	for _, i := range []int{1, 2, 3, 4, 5, 6, 0} {
+		if mask&(1<<i) != 0 {
+			days = append(days, weekdayNames[i])
+		}
+	}
+	return days
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// scheduled reports whether day is one the habit should be done on.
+// Every day is, for daily and weekly habits.
+func (s Schedule) scheduled(day time.Time) bool {
+	if s.Kind != ScheduleWeekdays {
+		return true
+	}
+	mask, _ := weekdayMask(s.Weekdays)
+	return mask&(1<<day.Weekday()) != 0
+}
+
+// Streak is how well a habit is being kept up. Streaks count scheduled
+// days kept, or weeks for weekly habits.
+type Streak struct {
+	Current int    `json:"current"`
+	Longest int    `json:"longest"`
+	Unit    string `json:"unit"` // "days" or "weeks"
+	// DoneToday is whether today is marked
+	DoneToday bool `json:"done_today"`
+	// PeriodDone of PeriodTarget marks are in for today, or for this week
+	// on a weekly habit
+	PeriodDone   int `json:"period_done"`
+	PeriodTarget int `json:"period_target"`
+}
+
+// period outcomes
+const (
+	periodDone = iota
+	periodFrozen
+	periodMissed
+	periodPending // the current day or week, not done yet
+)
+
+// HabitHistory is what a streak is worked out from. Dates are calendar
+// days as midnight UTC, keyed by DateLayout.
+type HabitHistory struct {
+	Schedule  Schedule
+	GraceDays int
+	Start     time.Time // the day the habit was created, in the user's timezone
+	Today     time.Time // today in the user's timezone
+	Marks     map[string]bool
+	Freezes   map[string]bool
+}
+
+// ComputeStreak walks the habit's days, or weeks, from Start to Today. A
+// done period adds one to the streak. A frozen one keeps it without adding
+// to it. Up to GraceDays missed periods in a row are forgiven; one more
+// ends the streak. The current period doesn't count against the streak
+// until it is over. Marks backfilled from before Start move Start back.
+func ComputeStreak(h HabitHistory) Streak {
+	start := h.Start
+	for date := range h.Marks {
+		if day, err := time.Parse(DateLayout, date); err == nil && day.Before(start) {
+			start = day
+		}
+	}
+	// A habit made "tomorrow" by a user west of the server's clock
+	if start.After(h.Today) {
+		start = h.Today
+	}
+
+	var outcomes []int
+	streak := Streak{Unit: "days", PeriodTarget: 1, DoneToday: h.Marks[h.Today.Format(DateLayout)]}
+	if h.Schedule.Kind == ScheduleWeekly {
+		streak.Unit = "weeks"
+		streak.PeriodTarget = h.Schedule.TimesPerWeek
+		outcomes, streak.PeriodDone = weekOutcomes(h, start)
+	} else {
+		outcomes = dayOutcomes(h, start)
+		if streak.DoneToday {
+			streak.PeriodDone = 1
+		}
+	}
+
+	run, misses := 0, 0
+	for _, outcome := range outcomes {
+		switch outcome {
+		case periodDone:
+			run++
+			misses = 0
+		case periodFrozen:
+			misses = 0
+		case periodMissed:
+			misses++
+			if misses > h.GraceDays {
+				run, misses = 0, 0
+			}
+		}
+		streak.Longest = max(streak.Longest, run)
+	}
+	streak.Current = run
+	return streak
+}
+
+func dayOutcomes(h HabitHistory, start time.Time) []int {
+	var outcomes []int
+	for day := start; !day.After(h.Today); day = day.AddDate(0, 0, 1) {
+		if !h.Schedule.scheduled(day) {
+			continue
+		}
+		date := day.Format(DateLayout)
+		switch {
+		case h.Marks[date]:
+			outcomes = append(outcomes, periodDone)
+		case h.Freezes[date]:
+			outcomes = append(outcomes, periodFrozen)
+		case day.Equal(h.Today):
+			outcomes = append(outcomes, periodPending)
+		default:
+			outcomes = append(outcomes, periodMissed)
+		}
+	}
+	return outcomes
+}
+
+// weekOutcomes also returns how many marks this week has. A week the
+// habit started partway through only counts if it was done anyway.
+func weekOutcomes(h HabitHistory, start time.Time) ([]int, int) {
+	var outcomes []int
+	thisWeek := weekStart(h.Today)
+	for week := weekStart(start); ; week = week.AddDate(0, 0, 7) {
+		done, frozen := 0, false
+		for day := week; day.Before(week.AddDate(0, 0, 7)) && !day.After(h.Today); day = day.AddDate(0, 0, 1) {
+			date := day.Format(DateLayout)
+			if h.Marks[date] {
+				done++
+			}
+			frozen = frozen || h.Freezes[date]
+		}
+		switch {
+		case done >= h.Schedule.TimesPerWeek:
+			outcomes = append(outcomes, periodDone)
+		case frozen:
+			outcomes = append(outcomes, periodFrozen)
+		case week.Equal(thisWeek):
+			outcomes = append(outcomes, periodPending)
+		case week.Before(start):
+			// started partway through
+		default:
+			outcomes = append(outcomes, periodMissed)
+		}
+		if week.Equal(thisWeek) {
+			return outcomes, done
+		}
+	}
+}
+
+// weekStart is the Monday on or before day
+func weekStart(day time.Time) time.Time {
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	d, err := time.Parse(DateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func dateSet(dates ...string) map[string]bool {
+	set := make(map[string]bool)
+	for _, d := range dates {
+		set[d] = true
+	}
+	return set
+}
+
+func TestComputeStreak(t *testing.T) {
+	daily := Schedule{Kind: ScheduleDaily}
+	tests := []struct {
+		name    string
+		history HabitHistory
+		want    Streak
+	}{
+		{
+			name: "today not done yet keeps yesterday's streak",
+			history: HabitHistory{Schedule: daily, Start: date("2025-03-01"), Today: date("2025-03-12"),
+				Marks: dateSet("2025-03-01", "2025-03-02", "2025-03-09", "2025-03-10", "2025-03-11")},
+			want: Streak{Current: 3, Longest: 3, Unit: "days", PeriodTarget: 1},
+		},
+		{
+			name: "a missed day ends the streak",
+			history: HabitHistory{Schedule: daily, Start: date("2025-03-01"), Today: date("2025-03-12"),
+				Marks: dateSet("2025-03-01", "2025-03-02", "2025-03-03", "2025-03-04", "2025-03-10", "2025-03-12")},
+			want: Streak{Current: 1, Longest: 4, Unit: "days", DoneToday: true, PeriodDone: 1, PeriodTarget: 1},
+		},
+		{
+			name: "grace days forgive short gaps",
+			history: HabitHistory{Schedule: daily, GraceDays: 2, Start: date("2025-03-01"), Today: date("2025-03-12"),
+				Marks: dateSet("2025-03-05", "2025-03-06", "2025-03-09", "2025-03-11", "2025-03-12")},
+			want: Streak{Current: 5, Longest: 5, Unit: "days", DoneToday: true, PeriodDone: 1, PeriodTarget: 1},
+		},
+		{
+			name: "a freeze bridges a gap without counting",
+			history: HabitHistory{Schedule: daily, Start: date("2025-03-08"), Today: date("2025-03-12"),
+				Marks: dateSet("2025-03-08", "2025-03-09", "2025-03-11"), Freezes: dateSet("2025-03-10")},
+			want: Streak{Current: 3, Longest: 3, Unit: "days", PeriodTarget: 1},
+		},
+		{
+			name: "only scheduled weekdays count",
+			history: HabitHistory{Schedule: Schedule{Kind: ScheduleWeekdays, Weekdays: []string{"mon", "wed", "fri"}},
+				Start: date("2025-03-01"), Today: date("2025-03-12"),
+				// Mon 3, Wed 5, Fri 7, Mon 10, Wed 12; the weekend is skipped
+				Marks: dateSet("2025-03-03", "2025-03-05", "2025-03-07", "2025-03-10", "2025-03-12")},
+			want: Streak{Current: 5, Longest: 5, Unit: "days", DoneToday: true, PeriodDone: 1, PeriodTarget: 1},
+		},
+		{
+			name: "weekly habits count weeks",
+			history: HabitHistory{Schedule: Schedule{Kind: ScheduleWeekly, TimesPerWeek: 2},
+				Start: date("2025-02-19"), Today: date("2025-03-12"),
+				// Week of Feb 17 (started partway, not done), Feb 24 done,
+				// Mar 3 done, Mar 10 one of two so far
+				Marks: dateSet("2025-02-24", "2025-02-28", "2025-03-03", "2025-03-08", "2025-03-11")},
+			want: Streak{Current: 2, Longest: 2, Unit: "weeks", PeriodDone: 1, PeriodTarget: 2},
+		},
+		{
+			name: "a missed week ends a weekly streak",
+			history: HabitHistory{Schedule: Schedule{Kind: ScheduleWeekly, TimesPerWeek: 1},
+				Start: date("2025-02-17"), Today: date("2025-03-12"),
+				Marks: dateSet("2025-02-17", "2025-02-24", "2025-03-10")},
+			want: Streak{Current: 1, Longest: 2, Unit: "weeks", PeriodDone: 1, PeriodTarget: 1},
+		},
+		{
+			name: "backfilled marks before the start count",
+			history: HabitHistory{Schedule: daily, Start: date("2025-03-12"), Today: date("2025-03-12"),
+				Marks: dateSet("2025-03-09", "2025-03-10", "2025-03-11")},
+			want: Streak{Current: 3, Longest: 3, Unit: "days", PeriodTarget: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComputeStreak(tt.history); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	s := Schedule{Kind: ScheduleWeekdays, Weekdays: []string{"Sun", "wed", "mon", "wed"}}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Weekdays) != 3 || s.Weekdays[0] != "mon" || s.Weekdays[2] != "sun" {
+		t.Errorf("weekdays = %v, want [mon wed sun]", s.Weekdays)
+	}
+	for _, bad := range []Schedule{
+		{Kind: "hourly"},
+		{Kind: ScheduleWeekly, TimesPerWeek: 8},
+		{Kind: ScheduleWeekdays},
+		{Kind: ScheduleWeekdays, Weekdays: []string{"funday"}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v is valid", bad)
+		}
+	}
+}
+
+func TestToday(t *testing.T) {
+	// 23:30 UTC on the 11th is already the 12th in Tokyo
+	instant := time.Date(2025, 3, 11, 23, 30, 0, 0, time.UTC)
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	if got := DateOf(instant.In(tokyo)); !got.Equal(date("2025-03-12")) {
+		t.Errorf("DateOf in Tokyo = %v", got)
+	}
+	if got := DateOf(instant); !got.Equal(date("2025-03-11")) {
+		t.Errorf("DateOf in UTC = %v", got)
+	}
+}
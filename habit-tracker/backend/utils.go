@@ -14,7 +14,13 @@ func CheckPasswordHash(password, hash string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
 }
 
-func Today() time.Time {
-	now := time.Now()
-	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+// Today is the date it is now in loc, as midnight UTC on that date so that
+// dates from different zones compare and format alike
+func Today(loc *time.Location) time.Time {
+	return DateOf(time.Now().In(loc))
+}
+
+// DateOf is the calendar date of t in its own location, as midnight UTC
+func DateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
 }
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBulkLinks bounds how many links one bulk request can create or delete
+const maxBulkLinks = 100
+
+// maxRequestBody bounds the size of JSON request bodies
+const maxRequestBody = 1 << 20
+
+var errInvalidExpiry = errors.New("expiresAt must be in the future, and expiresIn a positive duration such as \"72h\"; give at most one")
+
+// LinkRequest is the JSON body that creates a link
+type LinkRequest struct {
+	URL       string     `json:"url"`
+	Alias     string     `json:"alias,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	ExpiresIn string     `json:"expiresIn,omitempty"` // a duration such as "72h"
+	Password  string     `json:"password,omitempty"`
+	Redirect  int        `json:"redirect,omitempty"` // 301, 302 (the default) or 307
+}
+
+// LinkResponse is a link as the API shows it to its owner
+type LinkResponse struct {
+	ShortCode string     `json:"shortCode"`
+	ShortURL  string     `json:"shortUrl"`
+	LongURL   string     `json:"longUrl"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Protected bool       `json:"protected"`
+	Redirect  int        `json:"redirect"`
+	Clicks    int        `json:"clicks"`
+	QRCode    string     `json:"qrCode"`
+}
+
+// BulkResult is the outcome of one link of a bulk request
+type BulkResult struct {
+	ShortCode string        `json:"shortCode,omitempty"`
+	Status    int           `json:"status"`
+	Link      *LinkResponse `json:"link,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+type apiKeyContextKey struct{}
+
+// baseURL is where short links are served from, for the links the API
+// returns. Without BASE_URL it comes from each request.
+var baseURL string
+
+func requestBaseURL(r *http.Request) string {
+	if baseURL != "" {
+		return baseURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); trustProxy && proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// linkErrorStatus is the status that fits an error creating, finding or
+// deleting a link
+func linkErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidURL), errors.Is(err, errInvalidAlias), errors.Is(err, errReservedName),
+		errors.Is(err, errInvalidRedirect), errors.Is(err, errBlockedURL), errors.Is(err, errInvalidExpiry):
+		return http.StatusBadRequest
+	case errors.Is(err, errAliasTaken):
+		return http.StatusConflict
+	case errors.Is(err, errQuotaExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeLinkError(w http.ResponseWriter, err error) {
+	status := linkErrorStatus(err)
+	message := err.Error()
+	if status == http.StatusNotFound {
+		message = "link not found"
+	}
+	writeJSONError(w, status, message)
+}
+
+// decodeJSON reads a JSON request body into v
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// requireAPIKey lets through requests with a valid API key, given as
+// "Authorization: Bearer <key>" or "X-API-Key: <key>"
+func requireAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			key = r.Header.Get("X-API-Key")
+		}
+		if key == "" {
+			writeJSONError(w, http.StatusUnauthorized, "an API key is required")
+			return
+		}
+		apiKey, err := findAPIKey(strings.TrimSpace(key))
+		if errors.Is(err, errUnknownKey) {
+			writeJSONError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, apiKey)))
+	}
+}
+
+func apiKeyFrom(r *http.Request) *APIKey {
+	return r.Context().Value(apiKeyContextKey{}).(*APIKey)
+}
+
+// setQuotaHeaders tells the client how much of its daily quota is left
+func setQuotaHeaders(w http.ResponseWriter, key *APIKey, remaining int) {
+	if key.DailyQuota == 0 {
+		return
+	}
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(key.DailyQuota))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+}
+
+func (req LinkRequest) options(ownerID int64, now time.Time) (LinkOptions, error) {
+	opts := LinkOptions{
+		LongURL:  strings.TrimSpace(req.URL),
+		Alias:    strings.TrimSpace(req.Alias),
+		Password: req.Password,
+		Redirect: req.Redirect,
+		OwnerID:  ownerID,
+	}
+	switch {
+	case req.ExpiresAt != nil && req.ExpiresIn != "":
+		return opts, errInvalidExpiry
+	case req.ExpiresAt != nil:
+		if !req.ExpiresAt.After(now) {
+			return opts, errInvalidExpiry
+		}
+		expiresAt := req.ExpiresAt.UTC()
+		opts.ExpiresAt = &expiresAt
+	case req.ExpiresIn != "":
+		lifetime, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || lifetime <= 0 {
+			return opts, errInvalidExpiry
+		}
+		expiresAt := now.Add(lifetime).UTC()
+		opts.ExpiresAt = &expiresAt
+	}
+	return opts, nil
+}
+
+func linkResponse(r *http.Request, entry *URLEntry) LinkResponse {
+	base := requestBaseURL(r)
+	return LinkResponse{
+		ShortCode: entry.ShortCode,
+		ShortURL:  base + "/" + entry.ShortCode,
+		LongURL:   entry.LongURL,
+		CreatedAt: entry.CreatedAt,
+		ExpiresAt: entry.ExpiresAt,
+		Protected: entry.Protected(),
+		Redirect:  entry.Redirect,
+		Clicks:    entry.Clicks,
+		QRCode:    base + "/qr/" + entry.ShortCode,
+	}
+}
+
+// ownedLink looks up one of the API key's links; other links are not
+// found
+func ownedLink(r *http.Request, code string) (*URLEntry, error) {
+	entry, err := getURL(code)
+	if err != nil {
+		return nil, err
+	}
+	if entry.OwnerID != apiKeyFrom(r).ID {
+		return nil, sql.ErrNoRows
+	}
+	return entry, nil
+}
+
+// createLink creates a link for the API key, its quota already reserved,
+// reporting whether it is new rather than one of the key's links reused
+func createLink(r *http.Request, req LinkRequest) (*URLEntry, bool, error) {
+	opts, err := req.options(apiKeyFrom(r).ID, time.Now())
+	if err != nil {
+		return nil, false, err
+	}
+	return createURL(opts)
+}
+
+func handleAPICreate(w http.ResponseWriter, r *http.Request) {
+	var req LinkRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	key := apiKeyFrom(r)
+	remaining, err := reserveLinks(key, 1, time.Now())
+	if err != nil {
+		writeLinkError(w, err)
+		return
+	}
+	entry, created, err := createLink(r, req)
+	if !created {
+		// Only new links use up quota
+		remaining, _ = reserveLinks(key, -1, time.Now())
+	}
+	setQuotaHeaders(w, key, remaining)
+	if err != nil {
+		writeLinkError(w, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if !created {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, linkResponse(r, entry))
+}
+
+// handleAPIBulkCreate creates up to maxBulkLinks links, each succeeding
+// or failing on its own. The quota must cover them all.
+func handleAPIBulkCreate(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Links []LinkRequest `json:"links"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	if len(body.Links) == 0 || len(body.Links) > maxBulkLinks {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("send 1 to %d links", maxBulkLinks))
+		return
+	}
+
+	key := apiKeyFrom(r)
+	remaining, err := reserveLinks(key, len(body.Links), time.Now())
+	if err != nil {
+		writeLinkError(w, err)
+		return
+	}
+	results := make([]BulkResult, len(body.Links))
+	unused := 0
+	for i, req := range body.Links {
+		entry, created, err := createLink(r, req)
+		if !created {
+			unused++
+		}
+		if err != nil {
+			results[i] = BulkResult{ShortCode: req.Alias, Status: linkErrorStatus(err), Error: err.Error()}
+			continue
+		}
+		status := http.StatusCreated
+		if !created {
+			status = http.StatusOK
+		}
+		link := linkResponse(r, entry)
+		results[i] = BulkResult{ShortCode: entry.ShortCode, Status: status, Link: &link}
+	}
+	if unused > 0 {
+		remaining, _ = reserveLinks(key, -unused, time.Now())
+	}
+
+	setQuotaHeaders(w, key, remaining)
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+func handleAPIList(w http.ResponseWriter, r *http.Request) {
+	entries, err := getOwnedURLs(apiKeyFrom(r).ID)
+	if err != nil {
+		writeLinkError(w, err)
+		return
+	}
+
+	links := make([]LinkResponse, len(entries))
+	for i := range entries {
+		links[i] = linkResponse(r, &entries[i])
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"links": links})
+}
+
+func handleAPIGet(w http.ResponseWriter, r *http.Request) {
+	entry, err := ownedLink(r, r.PathValue("code"))
+	if err != nil {
+		writeLinkError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, linkResponse(r, entry))
+}
+
+func handleAPIDelete(w http.ResponseWriter, r *http.Request) {
+	if err := deleteURL(r.PathValue("code"), apiKeyFrom(r).ID); err != nil {
+		writeLinkError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIBulkDelete deletes up to maxBulkLinks of the API key's links
+func handleAPIBulkDelete(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Codes []string `json:"codes"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	if len(body.Codes) == 0 || len(body.Codes) > maxBulkLinks {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("send 1 to %d codes", maxBulkLinks))
+		return
+	}
+
+	ownerID := apiKeyFrom(r).ID
+	results := make([]BulkResult, len(body.Codes))
+	for i, code := range body.Codes {
+		results[i] = BulkResult{ShortCode: code, Status: http.StatusNoContent}
+		if err := deleteURL(code, ownerID); err != nil {
+			results[i].Status = linkErrorStatus(err)
+			results[i].Error = err.Error()
+			if results[i].Status == http.StatusNotFound {
+				results[i].Error = "link not found"
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+func handleAPIStats(w http.ResponseWriter, r *http.Request) {
+	entry, err := ownedLink(r, r.PathValue("code"))
+	if err != nil {
+		writeLinkError(w, err)
+		return
+	}
+	days, err := statsDays(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stats, err := linkStats(entry, days, time.Now())
+	if err != nil {
+		writeLinkError(w, err)
+		return
+	}
+	stats.LongURL = entry.LongURL
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleAPIUsage reports the API key's quota for the day
+func handleAPIUsage(w http.ResponseWriter, r *http.Request) {
+	key := apiKeyFrom(r)
+	remaining, err := remainingLinks(key, time.Now())
+	if err != nil {
+		writeLinkError(w, err)
+		return
+	}
+
+	usage := map[string]any{"name": key.Name, "dailyQuota": key.DailyQuota}
+	if remaining >= 0 {
+		usage["remaining"] = remaining
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBlocklist(t *testing.T) {
+	b, err := loadBlocklist(strings.NewReader("# comment\n\nEvil.example.\nhttps://phish.example/login\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for u, want := range map[string]bool{
+		"https://evil.example/x":           true,
+		"http://a.b.EVIL.example:8080/":    true,
+		"https://phish.example/":           true,
+		"https://notevil.example/":         false,
+		"https://example.com/evil.example": false,
+	} {
+		if got := b.Blocks(u); got != want {
+			t.Errorf("Blocks(%q) = %v, want %v", u, got, want)
+		}
+	}
+}
+
+func TestAPI(t *testing.T) {
+	if err := initDB(t.TempDir(), 2); err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB()
+	blocklist = Blocklist{"evil.example": true}
+	defer func() { blocklist = Blocklist{} }()
+
+	_, alice, err := createAPIKey("alice", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, bob, _ := createAPIKey("bob", 0)
+
+	server := httptest.NewServer(newMux())
+	defer server.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	call := func(method, path, key string, body any, out any) *http.Response {
+		var reader io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			reader = bytes.NewReader(b)
+		}
+		req, _ := http.NewRequest(method, server.URL+path, reader)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+		}
+		return resp
+	}
+
+	if resp := call("GET", "/api/v1/links", "", nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("no key = %d", resp.StatusCode)
+	}
+	if resp := call("GET", "/api/v1/links", "sk_nope", nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("bad key = %d", resp.StatusCode)
+	}
+
+	var link LinkResponse
+	resp := call("POST", "/api/v1/links", alice, LinkRequest{URL: "https://example.com/a", Redirect: 307, ExpiresIn: "1h"}, &link)
+	if resp.StatusCode != http.StatusCreated || link.Redirect != 307 || link.ExpiresAt == nil || link.ShortURL != server.URL+"/"+link.ShortCode {
+		t.Fatalf("create = %d %+v", resp.StatusCode, link)
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "2" {
+		t.Errorf("remaining = %q", resp.Header.Get("X-RateLimit-Remaining"))
+	}
+	if resp := call("GET", "/"+link.ShortCode, "", nil, nil); resp.StatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("redirect = %d", resp.StatusCode)
+	}
+
+	for _, req := range []LinkRequest{
+		{URL: "https://evil.example/x"},
+		{URL: "https://example.com", Redirect: 308},
+		{URL: "https://example.com", ExpiresIn: "-1h"},
+	} {
+		if resp := call("POST", "/api/v1/links", alice, req, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("create %+v = %d", req, resp.StatusCode)
+		}
+	}
+
+	// Two of these fit the quota; the failure is given back
+	var bulk struct{ Results []BulkResult }
+	resp = call("POST", "/api/v1/links/bulk", alice, map[string]any{"links": []LinkRequest{
+		{URL: "https://example.com/b", Alias: "bee"},
+		{URL: "ftp://example.com"},
+		{URL: "https://example.com/c"},
+	}}, &bulk)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("bulk over quota = %d", resp.StatusCode)
+	}
+	resp = call("POST", "/api/v1/links/bulk", alice, map[string]any{"links": []LinkRequest{
+		{URL: "https://example.com/b", Alias: "bee"},
+		{URL: "ftp://example.com"},
+	}}, &bulk)
+	if resp.StatusCode != http.StatusOK || len(bulk.Results) != 2 || bulk.Results[0].Status != http.StatusCreated || bulk.Results[1].Status != http.StatusBadRequest {
+		t.Fatalf("bulk = %d %+v", resp.StatusCode, bulk.Results)
+	}
+	var usage struct{ Remaining int }
+	if call("GET", "/api/v1/usage", alice, nil, &usage); usage.Remaining != 1 {
+		t.Errorf("remaining after bulk = %d", usage.Remaining)
+	}
+
+	// Bob can't see or delete Alice's links
+	if resp := call("GET", "/api/v1/links/bee", bob, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("bob's get = %d", resp.StatusCode)
+	}
+	if resp := call("DELETE", "/api/v1/links/bee", bob, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("bob's delete = %d", resp.StatusCode)
+	}
+	var list struct{ Links []LinkResponse }
+	if call("GET", "/api/v1/links", alice, nil, &list); len(list.Links) != 2 {
+		t.Errorf("alice's links = %+v", list.Links)
+	}
+	if call("GET", "/api/v1/links", bob, nil, &list); len(list.Links) != 0 {
+		t.Errorf("bob's links = %+v", list.Links)
+	}
+	var stats LinkStats
+	if call("GET", "/api/v1/links/"+link.ShortCode+"/stats", alice, nil, &stats); stats.TotalClicks != 1 || stats.LongURL != "https://example.com/a" {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// Nor can anyone without the key, through the public pages
+	if resp := call("GET", "/stats/"+link.ShortCode, "", nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("public stats of alice's link = %d", resp.StatusCode)
+	}
+	if urls, err := getPublicURLs(); err != nil || len(urls) != 0 {
+		t.Errorf("public links = %+v, %v", urls, err)
+	}
+	home, err := http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(home.Body)
+	home.Body.Close()
+	if strings.Contains(string(page), "example.com/a") || strings.Contains(string(page), "bee") {
+		t.Errorf("home page shows alice's links:\n%s", page)
+	}
+
+	for format, contentType := range map[string]string{"png": "image/png", "svg": "image/svg+xml"} {
+		resp := call("GET", "/qr/bee?format="+format, "", nil, nil)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != contentType {
+			t.Errorf("%s QR = %d %s", format, resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+	}
+
+	// Links stop working once their destination is blocked
+	blocklist["example.com"] = true
+	if resp := call("GET", "/bee", "", nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("blocked = %d", resp.StatusCode)
+	}
+	delete(blocklist, "example.com")
+
+	resp = call("POST", "/api/v1/links/bulk-delete", alice, map[string]any{"codes": []string{"bee", "bee"}}, &bulk)
+	if resp.StatusCode != http.StatusOK || bulk.Results[0].Status != http.StatusNoContent || bulk.Results[1].Status != http.StatusNotFound {
+		t.Errorf("bulk delete = %d %+v", resp.StatusCode, bulk.Results)
+	}
+	if resp := call("DELETE", "/api/v1/links/"+link.ShortCode, alice, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete = %d", resp.StatusCode)
+	}
+	if resp := call("GET", "/"+link.ShortCode, "", nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted link = %d", resp.StatusCode)
+	}
+
+	// Reusing one of the key's plain links doesn't use up quota
+	_, carol, _ := createAPIKey("carol", 2)
+	var first, again LinkResponse
+	call("POST", "/api/v1/links", carol, LinkRequest{URL: "https://example.com/x"}, &first)
+	resp = call("POST", "/api/v1/links", carol, LinkRequest{URL: "https://example.com/x"}, &again)
+	if resp.StatusCode != http.StatusOK || again.ShortCode != first.ShortCode || resp.Header.Get("X-RateLimit-Remaining") != "1" {
+		t.Errorf("reuse = %d %s, remaining %q", resp.StatusCode, again.ShortCode, resp.Header.Get("X-RateLimit-Remaining"))
+	}
+	resp = call("POST", "/api/v1/links/bulk", carol, map[string]any{"links": []LinkRequest{{URL: "https://example.com/x"}}}, &bulk)
+	if resp.StatusCode != http.StatusOK || bulk.Results[0].Status != http.StatusOK {
+		t.Errorf("bulk with a reused link = %d %+v", resp.StatusCode, bulk.Results)
+	}
+	if call("GET", "/api/v1/usage", carol, nil, &usage); usage.Remaining != 1 {
+		t.Errorf("carol's remaining = %d", usage.Remaining)
+	}
+
+	if err := revokeAPIKey(2); err != nil {
+		t.Fatal(err)
+	}
+	if resp := call("GET", "/api/v1/links", bob, nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("revoked key = %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// APIKey lets a client use the JSON API. Only a hash of the key itself is
+// stored.
+type APIKey struct {
+	ID         int64
+	Name       string
+	DailyQuota int // links it may create per UTC day; 0 is unlimited
+	CreatedAt  time.Time
+}
+
+var (
+	errQuotaExceeded = errors.New("daily link quota exceeded")
+	errUnknownKey    = errors.New("invalid API key")
+)
+
+// apiKeyPrefix marks API keys so they are easy to spot, in logs say
+const apiKeyPrefix = "sk_"
+
+// migrateAPIKeys creates the API key tables, kept in the first shard
+func migrateAPIKeys() error {
+	_, err := shards[0].Exec(`
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		key_hash TEXT UNIQUE NOT NULL,
+		daily_quota INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		revoked INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE IF NOT EXISTS api_usage (
+		key_id INTEGER NOT NULL REFERENCES api_keys(id),
+		day TEXT NOT NULL,
+		links INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (key_id, day)
+	);`)
+	return err
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// createAPIKey makes a new key, returning the key itself, which can't be
+// recovered later
+func createAPIKey(name string, dailyQuota int) (*APIKey, string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(b)
+
+	result, err := shards[0].Exec("INSERT INTO api_keys (name, key_hash, daily_quota) VALUES (?, ?, ?)",
+		name, hashAPIKey(key), dailyQuota)
+	if err != nil {
+		return nil, "", err
+	}
+	id, _ := result.LastInsertId()
+	return &APIKey{ID: id, Name: name, DailyQuota: dailyQuota, CreatedAt: time.Now().UTC()}, key, nil
+}
+
+// revokeAPIKey stops a key working. Its links keep working.
+func revokeAPIKey(id int64) error {
+	result, err := shards[0].Exec("UPDATE api_keys SET revoked = 1 WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// findAPIKey looks up the key a client presented
+func findAPIKey(key string) (*APIKey, error) {
+	var k APIKey
+	err := shards[0].QueryRow("SELECT id, name, daily_quota, created_at FROM api_keys WHERE key_hash = ? AND revoked = 0",
+		hashAPIKey(key)).Scan(&k.ID, &k.Name, &k.DailyQuota, &k.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errUnknownKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// reserveLinks takes n links from the key's quota for the day, failing
+// without taking any if fewer than n are left. It returns how many remain,
+// or -1 for unlimited keys. A negative n gives links back.
+func reserveLinks(key *APIKey, n int, now time.Time) (int, error) {
+	day := now.UTC().Format(time.DateOnly)
+	if _, err := shards[0].Exec("INSERT OR IGNORE INTO api_usage (key_id, day) VALUES (?, ?)", key.ID, day); err != nil {
+		return 0, err
+	}
+
+	quota := key.DailyQuota
+	if quota == 0 {
+		quota = -1
+	}
+	var used int
+	err := shards[0].QueryRow(`UPDATE api_usage SET links = MAX(links + ?, 0)
+		WHERE key_id = ? AND day = ? AND (? < 0 OR links + ? <= ?)
+		RETURNING links`, n, key.ID, day, quota, n, quota).Scan(&used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, errQuotaExceeded
+	}
+	if err != nil {
+		return 0, err
+	}
+	if quota < 0 {
+		return -1, nil
+	}
+	return quota - used, nil
+}
+
+// remainingLinks is how many links the key can still create today, or -1
+// for unlimited keys
+func remainingLinks(key *APIKey, now time.Time) (int, error) {
+	if key.DailyQuota == 0 {
+		return -1, nil
+	}
+	var used int
+	err := shards[0].QueryRow("SELECT links FROM api_usage WHERE key_id = ? AND day = ?",
+		key.ID, now.UTC().Format(time.DateOnly)).Scan(&used)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	return max(key.DailyQuota-used, 0), nil
+}
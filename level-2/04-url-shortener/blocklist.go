@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var errBlockedURL = errors.New("links to that site are not allowed")
+
+// Blocklist is a set of domains links may not point to. Blocking a domain
+// blocks its subdomains too.
+type Blocklist map[string]bool
+
+// blocklist is checked when links are created and followed. Set
+// BLOCKLIST to load it from a file other than blocklist.txt.
+var blocklist = Blocklist{}
+
+// loadBlocklist reads a domain per line; blank lines and lines starting
+// with # are skipped. Lines may be URLs, whose host is blocked.
+func loadBlocklist(r io.Reader) (Blocklist, error) {
+	blocked := Blocklist{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.Contains(line, "://") {
+			if u, err := url.Parse(line); err == nil {
+				line = u.Hostname()
+			}
+		}
+		blocked[normalizeHost(line)] = true
+	}
+	return blocked, scanner.Err()
+}
+
+// loadBlocklistFile reads a blocklist, which is empty if the file doesn't
+// exist
+func loadBlocklistFile(path string) (Blocklist, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Blocklist{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return loadBlocklist(f)
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// Blocks reports whether a URL points to a blocked domain
+func (b Blocklist) Blocks(longURL string) bool {
+	u, err := url.Parse(longURL)
+	if err != nil {
+		return true
+	}
+	host := normalizeHost(u.Hostname())
+	for host != "" {
+		if b[host] {
+			return true
+		}
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok {
+			break
+		}
+		host = parent
+	}
+	return false
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GeoIP looks up the country an IP address is in. Lookups that fail or
+// find nothing give "".
+type GeoIP interface {
+	Country(addr netip.Addr) string
+}
+
+// geoip is the lookup clicks are recorded with. Set GEOIP_CSV to use a
+// file of address ranges, or plug in another GeoIP.
+var geoip GeoIP = noGeoIP{}
+
+// trustProxy makes the client address come from X-Forwarded-For, for
+// running behind a reverse proxy
+var trustProxy bool
+
+type noGeoIP struct{}
+
+func (noGeoIP) Country(netip.Addr) string { return "" }
+
+type ipRange struct {
+	first, last netip.Addr
+	country     string
+}
+
+// rangeGeoIP finds countries in a sorted list of address ranges
+type rangeGeoIP []ipRange
+
+// loadRangeGeoIP reads "first,last,country" lines, such as the free
+// IP-to-country CSV databases
+func loadRangeGeoIP(r io.Reader) (rangeGeoIP, error) {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	var ranges rangeGeoIP
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("line %d: want first,last,country", line)
+		}
+		first, err1 := netip.ParseAddr(strings.TrimSpace(record[0]))
+		last, err2 := netip.ParseAddr(strings.TrimSpace(record[1]))
+		if err1 != nil || err2 != nil || first.BitLen() != last.BitLen() || last.Less(first) {
+			return nil, fmt.Errorf("line %d: invalid range %s-%s", line, record[0], record[1])
+		}
+		ranges = append(ranges, ipRange{first.Unmap(), last.Unmap(), strings.ToUpper(strings.TrimSpace(record[2]))})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].first.Less(ranges[j].first) })
+	return ranges, nil
+}
+
+func (g rangeGeoIP) Country(addr netip.Addr) string {
+	addr = addr.Unmap()
+	// The last range starting at or before addr is the only one it can be in
+	i := sort.Search(len(g), func(i int) bool { return addr.Less(g[i].first) }) - 1
+	if i < 0 || g[i].last.Less(addr) || g[i].first.BitLen() != addr.BitLen() {
+		return ""
+	}
+	return g[i].country
+}
+
+func loadGeoIPFile(path string) (GeoIP, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return loadRangeGeoIP(f)
+}
+
+// clientAddr is the address a request came from
+func clientAddr(r *http.Request) netip.Addr {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if addr, err := netip.ParseAddr(strings.TrimSpace(first)); err == nil {
+				return addr
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, _ := netip.ParseAddr(host)
+	return addr
+}
+
+// referrerHost is the site a click came from, or "" for direct visits
+func referrerHost(r *http.Request) string {
+	u, err := url.Parse(r.Referer())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// uaFamilies are checked in order, since most browsers' user agents also
+// name the browsers they descend from
+var uaFamilies = []struct{ marker, family string }{
+	{"bot", "Bot"}, {"crawler", "Bot"}, {"spider", "Bot"},
+	{"curl/", "curl"}, {"wget/", "Wget"},
+	{"edg/", "Edge"}, {"opr/", "Opera"}, {"samsungbrowser/", "Samsung Internet"},
+	{"firefox/", "Firefox"}, {"fxios/", "Firefox"},
+	{"chrome/", "Chrome"}, {"crios/", "Chrome"},
+	{"safari/", "Safari"},
+}
+
+// uaFamily is the browser family of a user agent
+func uaFamily(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	for _, f := range uaFamilies {
+		if strings.Contains(ua, f.marker) {
+			return f.family
+		}
+	}
+	return "Other"
+}
+
+// recordClick stores a click on a link and counts it
+func recordClick(entry *URLEntry, r *http.Request, now time.Time) error {
+	country := ""
+	if addr := clientAddr(r); addr.IsValid() {
+		country = geoip.Country(addr)
+	}
+
+	shard := shardFor(entry.ShortCode)
+	tx, err := shard.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`INSERT INTO clicks (url_id, clicked_at, referrer, ua_family, country) VALUES (?, ?, ?, ?, ?)`,
+		entry.ID, now.UTC().Format(time.DateTime), referrerHost(r), uaFamily(r.UserAgent()), country); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE urls SET clicks = clicks + 1 WHERE id = ?", entry.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Count is how many clicks share a day, referrer, browser or country
+type Count struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// LinkStats sums up a link's clicks since a day
+type LinkStats struct {
+	ShortCode   string     `json:"shortCode"`
+	LongURL     string     `json:"longUrl,omitempty"` // hidden for password-protected links
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	Protected   bool       `json:"protected"`
+	TotalClicks int        `json:"totalClicks"`
+	Since       string     `json:"since"`
+	Clicks      int        `json:"clicks"` // since Since
+	LastClick   *time.Time `json:"lastClick,omitempty"`
+	Daily       []Count    `json:"daily"`
+	Referrers   []Count    `json:"referrers"` // "" is direct visits
+	Browsers    []Count    `json:"browsers"`
+	Countries   []Count    `json:"countries"` // "" is unknown
+}
+
+// countBy groups a link's clicks since a time by one of the click columns
+func countBy(entry *URLEntry, column, since string) ([]Count, error) {
+	rows, err := shardFor(entry.ShortCode).Query(`SELECT `+column+`, COUNT(*) FROM clicks
+		WHERE url_id = ? AND clicked_at >= ?
+		GROUP BY 1 ORDER BY 2 DESC, 1`, entry.ID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []Count{}
+	for rows.Next() {
+		var c Count
+		if err := rows.Scan(&c.Key, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// linkStats sums up a link's clicks over the days up to today (UTC)
+func linkStats(entry *URLEntry, days int, now time.Time) (*LinkStats, error) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, 1-days)
+	since := from.Format(time.DateTime)
+
+	stats := &LinkStats{
+		ShortCode:   entry.ShortCode,
+		CreatedAt:   entry.CreatedAt,
+		ExpiresAt:   entry.ExpiresAt,
+		Protected:   entry.Protected(),
+		TotalClicks: entry.Clicks,
+		Since:       from.Format(time.DateOnly),
+	}
+	if !stats.Protected {
+		stats.LongURL = entry.LongURL
+	}
+
+	var err error
+	if stats.Referrers, err = countBy(entry, "referrer", since); err != nil {
+		return nil, err
+	}
+	if stats.Browsers, err = countBy(entry, "ua_family", since); err != nil {
+		return nil, err
+	}
+	if stats.Countries, err = countBy(entry, "country", since); err != nil {
+		return nil, err
+	}
+	daily, err := countBy(entry, "substr(clicked_at, 1, 10)", since)
+	if err != nil {
+		return nil, err
+	}
+
+	// One entry per day, including days without clicks
+	perDay := make(map[string]int, len(daily))
+	for _, d := range daily {
+		perDay[d.Key] = d.Count
+		stats.Clicks += d.Count
+	}
+	stats.Daily = make([]Count, 0, days)
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		key := day.Format(time.DateOnly)
+		stats.Daily = append(stats.Daily, Count{Key: key, Count: perDay[key]})
+	}
+
+	var last *string
+	if err := shardFor(entry.ShortCode).QueryRow("SELECT MAX(clicked_at) FROM clicks WHERE url_id = ?", entry.ID).Scan(&last); err != nil {
+		return nil, err
+	}
+	if last != nil {
+		if t, err := time.Parse(time.DateTime, *last); err == nil {
+			stats.LastClick = &t
+		}
+	}
+	return stats, nil
+}
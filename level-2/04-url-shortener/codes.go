@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"math/bits"
+	"regexp"
+	"strings"
+)
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// minCodeLength is the length of generated codes until the counter runs
+// past 62^6 (about 57 billion links)
+const minCodeLength = 6
+
+// codeMultiplier shuffles counter values so consecutive links don't get
+// consecutive codes. It shares no factor with 62, so multiplying by it
+// modulo a power of 62 never maps two counter values to the same code.
+const codeMultiplier = 1580030173
+
+var (
+	errInvalidAlias = errors.New("aliases are 3 to 32 letters, digits, '-' or '_'")
+	errReservedName = errors.New("that alias is reserved")
+
+	aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,32}$`)
+
+	// reservedNames are paths the server uses itself
+	reservedNames = map[string]bool{
+		"api": true, "qr": true, "shorten": true, "static": true, "stats": true,
+	}
+)
+
+// shortCodeFor turns the nth link into its code. Every n gets a different
+// code: codes of the same length come from a permutation of the numbers
+// below 62^length, and codes of different lengths can't be equal.
+func shortCodeFor(n uint64) string {
+	length, space := minCodeLength, uint64(1)
+	for i := 0; i < length; i++ {
+		space *= 62
+	}
+	for n >= space {
+		length++
+		space *= 62
+	}
+
+	hi, lo := bits.Mul64(n, codeMultiplier)
+	v := bits.Rem64(hi, lo, space)
+	code := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		code[i] = base62Alphabet[v%62]
+		v /= 62
+	}
+	return string(code)
+}
+
+// validateAlias checks a custom alias can be used as a short code
+func validateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return errInvalidAlias
+	}
+	if reservedNames[strings.ToLower(alias)] {
+		return errReservedName
+	}
+	return nil
+}
@@ -2,4 +2,8 @@ module url-shortener
 
 go 1.23.4
 
-require github.com/mattn/go-sqlite3 v1.14.24 // indirect
+require (
+	github.com/mattn/go-sqlite3 v1.14.24
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	golang.org/x/crypto v0.32.0
+)
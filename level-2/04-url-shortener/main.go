@@ -1,192 +1,322 @@
 package main
 
 import (
-	"crypto/rand"
 	"database/sql"
-	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-type URLEntry struct {
-	ID        int
-	LongURL   string
-	ShortCode string
-	CreatedAt time.Time
-	Clicks    int
+// expiryChoices are the lifetimes offered by the form
+var expiryChoices = map[string]time.Duration{
+	"1h":  time.Hour,
+	"1d":  24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
 }
 
-var db *sql.DB
+// maxStatsDays bounds how far back /stats looks
+const maxStatsDays = 365
 
-func initDB() error {
-	var err error
-	db, err = sql.Open("sqlite3", "urls.db")
+func handleHome(w http.ResponseWriter, r *http.Request) {
+	urls, err := getPublicURLs()
 	if err != nil {
-		return err
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	createTable := `
-	CREATE TABLE IF NOT EXISTS urls (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		long_url TEXT NOT NULL,
-		short_code TEXT UNIQUE NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		clicks INTEGER DEFAULT 0
-	);`
-
-	_, err = db.Exec(createTable)
-	return err
+	tmpl := template.Must(template.ParseFiles("templates/index.html"))
+	tmpl.Execute(w, urls)
 }
 
-func generateShortCode() (string, error) {
-	b := make([]byte, 6)
-	_, err := rand.Read(b)
-	if err != nil {
-		return "", err
+func handleShorten(w http.ResponseWriter, r *http.Request) {
+	longURL := strings.TrimSpace(r.FormValue("url"))
+	if longURL == "" {
+		http.Error(w, "URL is required", http.StatusBadRequest)
+		return
 	}
-	return base64.URLEncoding.EncodeToString(b)[:6], nil
-}
 
-func createURL(longURL string) (*URLEntry, error) {
-	// Check if URL already exists
-	var entry URLEntry
-	err := db.QueryRow("SELECT id, long_url, short_code, created_at, clicks FROM urls WHERE long_url = ?",
-		longURL).Scan(&entry.ID, &entry.LongURL, &entry.ShortCode, &entry.CreatedAt, &entry.Clicks)
-	if err == nil {
-		return &entry, nil
+	opts := LinkOptions{
+		LongURL:  longURL,
+		Alias:    strings.TrimSpace(r.FormValue("alias")),
+		Password: r.FormValue("password"),
 	}
-
-	// Generate new short code
-	shortCode, err := generateShortCode()
-	if err != nil {
-		return nil, err
+	if redirect := r.FormValue("redirect"); redirect != "" {
+		status, err := strconv.Atoi(redirect)
+		if err != nil {
+			http.Error(w, errInvalidRedirect.Error(), http.StatusBadRequest)
+			return
+		}
+		opts.Redirect = status
+	}
+	if expires := r.FormValue("expires"); expires != "" {
+		lifetime, ok := expiryChoices[expires]
+		if !ok {
+			http.Error(w, "Unknown expiry", http.StatusBadRequest)
+			return
+		}
+		expiresAt := time.Now().Add(lifetime).UTC()
+		opts.ExpiresAt = &expiresAt
 	}
 
-	// Insert new URL
-	result, err := db.Exec("INSERT INTO urls (long_url, short_code) VALUES (?, ?)",
-		longURL, shortCode)
-	if err != nil {
-		return nil, err
+	if _, _, err := createURL(opts); err != nil {
+		http.Error(w, err.Error(), linkErrorStatus(err))
+		return
 	}
 
-	id, _ := result.LastInsertId()
-	return &URLEntry{
-		ID:        int(id),
-		LongURL:   longURL,
-		ShortCode: shortCode,
-		CreatedAt: time.Now(),
-		Clicks:    0,
-	}, nil
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-func getURL(shortCode string) (*URLEntry, error) {
-	var entry URLEntry
-	err := db.QueryRow("SELECT id, long_url, short_code, created_at, clicks FROM urls WHERE short_code = ?",
-		shortCode).Scan(&entry.ID, &entry.LongURL, &entry.ShortCode, &entry.CreatedAt, &entry.Clicks)
+// findLink looks up the link in the path, responding if it can't be
+// followed
+func findLink(w http.ResponseWriter, r *http.Request) (*URLEntry, bool) {
+	entry, err := getURL(r.PathValue("code"))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return nil, false
+	}
 	if err != nil {
-		return nil, err
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
 	}
-	return &entry, nil
+	if entry.Expired(time.Now()) {
+		http.Error(w, "This link has expired", http.StatusGone)
+		return nil, false
+	}
+	if blocklist.Blocks(entry.LongURL) {
+		http.Error(w, "This link has been disabled", http.StatusForbidden)
+		return nil, false
+	}
+	return entry, true
 }
 
-func incrementClicks(shortCode string) error {
-	_, err := db.Exec("UPDATE urls SET clicks = clicks + 1 WHERE short_code = ?", shortCode)
-	return err
+func follow(w http.ResponseWriter, r *http.Request, entry *URLEntry, status int) {
+	if err := recordClick(entry, r, time.Now()); err != nil {
+		log.Printf("Error recording click: %v", err)
+	}
+
+	http.Redirect(w, r, entry.LongURL, status)
 }
 
-func getAllURLs() ([]URLEntry, error) {
-	rows, err := db.Query("SELECT id, long_url, short_code, created_at, clicks FROM urls ORDER BY created_at DESC")
-	if err != nil {
-		return nil, err
+func renderPassword(w http.ResponseWriter, entry *URLEntry, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	tmpl := template.Must(template.ParseFiles("templates/password.html"))
+	tmpl.Execute(w, map[string]string{"ShortCode": entry.ShortCode, "Error": message})
+}
+
+func handleRedirect(w http.ResponseWriter, r *http.Request) {
+	entry, ok := findLink(w, r)
+	if !ok {
+		return
+	}
+	if entry.Protected() {
+		renderPassword(w, entry, http.StatusOK, "")
+		return
 	}
-	defer rows.Close()
 
-	var urls []URLEntry
-	for rows.Next() {
-		var entry URLEntry
-		err := rows.Scan(&entry.ID, &entry.LongURL, &entry.ShortCode, &entry.CreatedAt, &entry.Clicks)
-		if err != nil {
-			return nil, err
-		}
-		urls = append(urls, entry)
+	follow(w, r, entry, entry.Redirect)
+}
+
+// handleUnlock follows a password-protected link once the password form
+// is submitted
+func handleUnlock(w http.ResponseWriter, r *http.Request) {
+	entry, ok := findLink(w, r)
+	if !ok {
+		return
+	}
+	if !entry.Protected() {
+		http.Redirect(w, r, "/"+entry.ShortCode, http.StatusSeeOther)
+		return
 	}
-	return urls, nil
+	if !entry.CheckPassword(r.FormValue("password")) {
+		renderPassword(w, entry, http.StatusUnauthorized, "Wrong password")
+		return
+	}
+
+	follow(w, r, entry, http.StatusSeeOther)
 }
 
-func handleHome(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
+// statsDays is how many days of statistics are asked for with ?days=
+// (30 by default)
+func statsDays(r *http.Request) (int, error) {
+	s := r.URL.Query().Get("days")
+	if s == "" {
+		return 30, nil
+	}
+	days, err := strconv.Atoi(s)
+	if err != nil || days < 1 || days > maxStatsDays {
+		return 0, fmt.Errorf("days must be between 1 and %d", maxStatsDays)
+	}
+	return days, nil
+}
+
+// handleStats responds with a link's click statistics for the last
+// ?days= days
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	entry, err := getURL(r.PathValue("code"))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && entry.OwnerID != 0) {
+		// An API key's links have their stats under /api/v1
 		http.NotFound(w, r)
 		return
 	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	days, err := statsDays(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	urls, err := getAllURLs()
+	stats, err := linkStats(entry, days, time.Now())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	tmpl := template.Must(template.ParseFiles("templates/index.html"))
-	tmpl.Execute(w, urls)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
 }
 
-func handleShorten(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// handleQR responds with a QR code of a short link, as a PNG or, with
+// ?format=svg, an SVG. ?size= sets its width in pixels.
+func handleQR(w http.ResponseWriter, r *http.Request) {
+	entry, ok := findLink(w, r)
+	if !ok {
 		return
 	}
+	size := defaultQRSize
+	if s := r.URL.Query().Get("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < minQRSize || n > maxQRSize {
+			http.Error(w, fmt.Sprintf("size must be between %d and %d", minQRSize, maxQRSize), http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
 
-	longURL := r.FormValue("url")
-	if longURL == "" {
-		http.Error(w, "URL is required", http.StatusBadRequest)
+	content := requestBaseURL(r) + "/" + entry.ShortCode
+	var image []byte
+	var err error
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "png":
+		w.Header().Set("Content-Type", "image/png")
+		image, err = qrPNG(content, size)
+	case "svg":
+		w.Header().Set("Content-Type", "image/svg+xml")
+		image, err = qrSVG(content, size)
+	default:
+		http.Error(w, "format must be png or svg", http.StatusBadRequest)
 		return
 	}
-
-	entry, err := createURL(longURL)
 	if err != nil {
+		w.Header().Del("Content-Type")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(image)
 }
 
-func handleRedirect(w http.ResponseWriter, r *http.Request) {
-	shortCode := r.URL.Path[1:]
-	entry, err := getURL(shortCode)
-	if err != nil {
-		http.NotFound(w, r)
-		return
-	}
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", handleHome)
+	mux.HandleFunc("POST /shorten", handleShorten)
+	mux.HandleFunc("GET /stats/{code}", handleStats)
+	mux.HandleFunc("GET /qr/{code}", handleQR)
 
-	err = incrementClicks(shortCode)
-	if err != nil {
-		log.Printf("Error incrementing clicks: %v", err)
-	}
+	mux.HandleFunc("POST /api/v1/links", requireAPIKey(handleAPICreate))
+	mux.HandleFunc("GET /api/v1/links", requireAPIKey(handleAPIList))
+	mux.HandleFunc("POST /api/v1/links/bulk", requireAPIKey(handleAPIBulkCreate))
+	mux.HandleFunc("POST /api/v1/links/bulk-delete", requireAPIKey(handleAPIBulkDelete))
+	mux.HandleFunc("GET /api/v1/links/{code}", requireAPIKey(handleAPIGet))
+	mux.HandleFunc("DELETE /api/v1/links/{code}", requireAPIKey(handleAPIDelete))
+	mux.HandleFunc("GET /api/v1/links/{code}/stats", requireAPIKey(handleAPIStats))
+	mux.HandleFunc("GET /api/v1/usage", requireAPIKey(handleAPIUsage))
 
-	http.Redirect(w, r, entry.LongURL, http.StatusMovedPermanently)
+	mux.HandleFunc("GET /{code}", handleRedirect)
+	mux.HandleFunc("POST /{code}", handleUnlock)
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	return mux
 }
 
 func main() {
-	if err := initDB(); err != nil {
+	newKey := flag.String("new-key", "", "create an API key with this name, print it and exit")
+	quota := flag.Int("quota", 1000, "links per day the new API key may create; 0 is unlimited")
+	revokeKey := flag.Int64("revoke-key", 0, "revoke the API key with this ID and exit")
+	flag.Parse()
+
+	shardCount := 1
+	if s := os.Getenv("SHARDS"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			log.Fatalf("SHARDS: %v", err)
+		}
+		shardCount = n
+	}
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "."
+	}
+	if err := initDB(dataDir, shardCount); err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close()
+	defer closeDB()
+
+	if *newKey != "" {
+		key, secret, err := createAPIKey(*newKey, *quota)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("API key %d (%s): %s\n", key.ID, key.Name, secret)
+		return
+	}
+	if *revokeKey != 0 {
+		if err := revokeAPIKey(*revokeKey); err != nil {
+			log.Fatalf("revoking API key %d: %v", *revokeKey, err)
+		}
+		fmt.Printf("API key %d revoked\n", *revokeKey)
+		return
+	}
+
+	blocklistPath := os.Getenv("BLOCKLIST")
+	if blocklistPath == "" {
+		blocklistPath = "blocklist.txt"
+	}
+	loaded, err := loadBlocklistFile(blocklistPath)
+	if err != nil {
+		log.Fatalf("BLOCKLIST: %v", err)
+	}
+	blocklist = loaded
+	baseURL = strings.TrimSuffix(os.Getenv("BASE_URL"), "/")
+
+	if path := os.Getenv("GEOIP_CSV"); path != "" {
+		lookup, err := loadGeoIPFile(path)
+		if err != nil {
+			log.Fatalf("GEOIP_CSV: %v", err)
+		}
+		geoip = lookup
+	}
+	trustProxy = os.Getenv("TRUST_PROXY") != ""
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	http.HandleFunc("/", handleHome)
-	http.HandleFunc("/shorten", handleShorten)
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
-
 	fmt.Printf("Server starting on port %s...\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	log.Fatal(http.ListenAndServe(":"+port, newMux()))
 }
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShortCodes(t *testing.T) {
+	seen := map[string]uint64{}
+	for _, start := range []uint64{0, 62*62*62*62*62*62 - 5000} {
+		for n := start; n < start+10000; n++ {
+			code := shortCodeFor(n)
+			if prev, ok := seen[code]; ok {
+				t.Fatalf("%d and %d both got %q", prev, n, code)
+			}
+			seen[code] = n
+		}
+	}
+	if len(shortCodeFor(0)) != 6 || len(shortCodeFor(62*62*62*62*62*62)) != 7 {
+		t.Errorf("codes = %q, %q", shortCodeFor(0), shortCodeFor(62*62*62*62*62*62))
+	}
+
+	for alias, want := range map[string]error{
+		"my-link": nil, "ab": errInvalidAlias, "no spaces": errInvalidAlias, "Stats": errReservedName,
+	} {
+		if err := validateAlias(alias); err != want {
+			t.Errorf("validateAlias(%q) = %v, want %v", alias, err, want)
+		}
+	}
+}
+
+func TestClickDetails(t *testing.T) {
+	g, err := loadRangeGeoIP(strings.NewReader("# first,last,country\n10.0.0.0,10.0.0.255,nz\n1.0.0.0,1.0.0.255,AU\n2001:db8::,2001:db8::ffff,JP\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]string{
+		"1.0.0.7": "AU", "10.0.0.255": "NZ", "10.0.1.0": "", "0.0.0.1": "",
+		"::ffff:1.0.0.7": "AU", "2001:db8::1": "JP",
+	} {
+		if got := g.Country(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Country(%s) = %q, want %q", addr, got, want)
+		}
+	}
+
+	for ua, want := range map[string]string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36 Edg/120.0": "Edge",
+		"Mozilla/5.0 (Macintosh) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15":                         "Safari",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0":                                                "Firefox",
+		"Mozilla/5.0 (compatible; Googlebot/2.1)":                                                                               "Bot",
+		"curl/8.4.0": "curl",
+		"":           "Other",
+	} {
+		if got := uaFamily(ua); got != want {
+			t.Errorf("uaFamily(%q) = %q, want %q", ua, got, want)
+		}
+	}
+}
+
+func TestShortener(t *testing.T) {
+	dir := t.TempDir()
+	if err := initDB(dir, 3); err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB()
+	geoip, _ = loadRangeGeoIP(strings.NewReader("192.0.2.0,192.0.2.255,FR\n"))
+	defer func() { geoip = noGeoIP{} }()
+
+	server := httptest.NewServer(newMux())
+	defer server.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	shorten := func(form url.Values) int {
+		resp, err := client.PostForm(server.URL+"/shorten", form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := shorten(url.Values{"url": {"https://example.com/a"}, "alias": {"docs"}}); code != http.StatusSeeOther {
+		t.Fatalf("alias = %d", code)
+	}
+	if code := shorten(url.Values{"url": {"https://example.com/b"}, "alias": {"docs"}}); code != http.StatusConflict {
+		t.Errorf("taken alias = %d", code)
+	}
+	if code := shorten(url.Values{"url": {"javascript:alert(1)"}}); code != http.StatusBadRequest {
+		t.Errorf("javascript URL = %d", code)
+	}
+	shorten(url.Values{"url": {"https://example.com/secret"}, "alias": {"secret"}, "password": {"hunter22"}})
+	for i := 0; i < 20; i++ {
+		shorten(url.Values{"url": {"https://example.com/" + string(rune('c'+i))}})
+	}
+	shorten(url.Values{"url": {"https://example.com/c"}}) // reuses the first plain link
+
+	urls, err := getPublicURLs()
+	if err != nil || len(urls) != 22 {
+		t.Fatalf("links = %d, %v", len(urls), err)
+	}
+	shardsUsed := map[*sql.DB]bool{}
+	for _, u := range urls {
+		if _, err := getURL(u.ShortCode); err != nil {
+			t.Errorf("getURL(%q): %v", u.ShortCode, err)
+		}
+		if !u.Custom {
+			shardsUsed[shardFor(u.ShortCode)] = true
+		}
+	}
+	if len(shardsUsed) < 2 {
+		t.Errorf("links all in %v", shardsUsed)
+	}
+
+	follow := func(method, path, referer string, form url.Values) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(form.Encode()))
+		req.Header.Set("Referer", referer)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0")
+		req.Header.Set("X-Forwarded-For", "192.0.2.10")
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	trustProxy = true
+	defer func() { trustProxy = false }()
+	for _, referer := range []string{"https://www.news.example/item", "https://news.example/", ""} {
+		if resp := follow("GET", "/docs", referer, nil); resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "https://example.com/a" {
+			t.Errorf("follow = %d %s", resp.StatusCode, resp.Header.Get("Location"))
+		}
+	}
+
+	if resp := follow("GET", "/secret", "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("protected = %d", resp.StatusCode)
+	}
+	if resp := follow("POST", "/secret", "", url.Values{"password": {"wrong"}}); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong password = %d", resp.StatusCode)
+	}
+	if resp := follow("POST", "/secret", "", url.Values{"password": {"hunter22"}}); resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "https://example.com/secret" {
+		t.Errorf("unlocked = %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	past := time.Now().Add(-time.Minute)
+	if _, _, err := createURL(LinkOptions{LongURL: "https://example.com/old", Alias: "old", ExpiresAt: &past}); err != nil {
+		t.Fatal(err)
+	}
+	if resp := follow("GET", "/old", "", nil); resp.StatusCode != http.StatusGone {
+		t.Errorf("expired = %d", resp.StatusCode)
+	}
+	if resp := follow("GET", "/nope", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing = %d", resp.StatusCode)
+	}
+
+	resp, err := client.Get(server.URL + "/stats/docs?days=7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var stats LinkStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalClicks != 3 || stats.Clicks != 3 || len(stats.Daily) != 7 || stats.Daily[6].Count != 3 || stats.LastClick == nil {
+		t.Errorf("stats = %+v", stats)
+	}
+	if len(stats.Referrers) != 2 || stats.Referrers[0] != (Count{"news.example", 2}) || stats.Referrers[1] != (Count{"", 1}) {
+		t.Errorf("referrers = %+v", stats.Referrers)
+	}
+	if len(stats.Browsers) != 1 || stats.Browsers[0] != (Count{"Firefox", 3}) || len(stats.Countries) != 1 || stats.Countries[0] != (Count{"FR", 3}) {
+		t.Errorf("browsers = %+v, countries = %+v", stats.Browsers, stats.Countries)
+	}
+
+	resp, err = client.Get(server.URL + "/stats/secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var secret LinkStats
+	json.NewDecoder(resp.Body).Decode(&secret)
+	if !secret.Protected || secret.LongURL != "" || secret.TotalClicks != 1 {
+		t.Errorf("protected stats = %+v", secret)
+	}
+
+	// The shard count is fixed once links exist
+	closeDB()
+	if err := initDB(dir, 2); err == nil {
+		t.Error("changing the shard count worked")
+	}
+}
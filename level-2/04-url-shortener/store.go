@@ -0,0 +1,455 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type URLEntry struct {
+	ID        int
+	LongURL   string
+	ShortCode string
+	CreatedAt time.Time
+	Clicks    int
+	ExpiresAt *time.Time
+	Custom    bool  // the code is an alias someone chose
+	Redirect  int   // the status code links redirect with
+	OwnerID   int64 // the API key that created it, or 0
+
+	passwordHash string
+}
+
+// Protected reports whether the link needs a password to follow
+func (e *URLEntry) Protected() bool {
+	return e.passwordHash != ""
+}
+
+// Expired reports whether the link has stopped working
+func (e *URLEntry) Expired(now time.Time) bool {
+	return e.ExpiresAt != nil && !now.Before(*e.ExpiresAt)
+}
+
+// CheckPassword reports whether password unlocks the link
+func (e *URLEntry) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(e.passwordHash), []byte(password)) == nil
+}
+
+// LinkOptions describes a link to create. Only LongURL is required.
+type LinkOptions struct {
+	LongURL   string
+	Alias     string
+	ExpiresAt *time.Time
+	Password  string
+	Redirect  int   // 301, 302 or 307; 0 for the default
+	OwnerID   int64 // the API key creating it, if any
+}
+
+// defaultRedirect is a temporary redirect, so browsers come back through
+// the shortener and every click is counted
+const defaultRedirect = http.StatusFound
+
+// redirectStatuses are the statuses links can redirect with
+var redirectStatuses = map[int]bool{
+	http.StatusMovedPermanently:  true,
+	http.StatusFound:             true,
+	http.StatusTemporaryRedirect: true,
+}
+
+var (
+	errInvalidURL      = errors.New("only http and https URLs can be shortened")
+	errAliasTaken      = errors.New("that alias is already taken")
+	errInvalidRedirect = errors.New("redirect must be 301, 302 or 307")
+)
+
+// shards holds one SQLite database per shard. A link lives in the shard
+// its short code hashes to, along with its clicks.
+var shards []*sql.DB
+
+// maxCodeAttempts bounds how many generated codes are tried when they
+// clash with custom aliases
+const maxCodeAttempts = 10
+
+const urlColumns = "id, long_url, short_code, created_at, clicks, expires_at, password_hash, custom, redirect_status, owner_key_id"
+
+// shardPath is the file of a shard; the first is the original urls.db
+func shardPath(dir string, i int) string {
+	if i == 0 {
+		return filepath.Join(dir, "urls.db")
+	}
+	return filepath.Join(dir, fmt.Sprintf("urls-%d.db", i))
+}
+
+func initDB(dir string, count int) error {
+	if count < 1 {
+		return fmt.Errorf("need at least one shard, got %d", count)
+	}
+	for i := 0; i < count; i++ {
+		shard, err := sql.Open("sqlite3", "file:"+shardPath(dir, i)+"?_busy_timeout=5000&_foreign_keys=on")
+		if err != nil {
+			closeDB()
+			return err
+		}
+		shards = append(shards, shard)
+		if err := migrateShard(shard); err != nil {
+			closeDB()
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	if err := initCounters(count); err != nil {
+		closeDB()
+		return err
+	}
+	if err := migrateAPIKeys(); err != nil {
+		closeDB()
+		return err
+	}
+	return nil
+}
+
+func closeDB() {
+	for _, shard := range shards {
+		shard.Close()
+	}
+	shards = nil
+}
+
+// migrateShard creates a shard's tables, bringing databases from before
+// aliases, expiry, passwords, redirect types and owners up to date
+func migrateShard(shard *sql.DB) error {
+	createTable := `
+	CREATE TABLE IF NOT EXISTS urls (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		long_url TEXT NOT NULL,
+		short_code TEXT UNIQUE NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		clicks INTEGER DEFAULT 0
+	);`
+	if _, err := shard.Exec(createTable); err != nil {
+		return err
+	}
+
+	columns := map[string]bool{}
+	rows, err := shard.Query("SELECT name FROM pragma_table_info('urls')")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		columns[name] = true
+	}
+	rows.Close()
+	for _, column := range []struct{ name, definition string }{
+		{"expires_at", "DATETIME"},
+		{"password_hash", "TEXT"},
+		{"custom", "INTEGER NOT NULL DEFAULT 0"},
+		// Older links redirected permanently, which browsers cache, so
+		// repeat clicks were never counted
+		{"redirect_status", fmt.Sprintf("INTEGER NOT NULL DEFAULT %d", defaultRedirect)},
+		{"owner_key_id", "INTEGER"},
+	} {
+		if columns[column.name] {
+			continue
+		}
+		if _, err := shard.Exec("ALTER TABLE urls ADD COLUMN " + column.name + " " + column.definition); err != nil {
+			return err
+		}
+	}
+
+	_, err = shard.Exec(`
+	CREATE INDEX IF NOT EXISTS idx_urls_long_url ON urls(long_url);
+	CREATE INDEX IF NOT EXISTS idx_urls_owner ON urls(owner_key_id, created_at);
+
+	CREATE TABLE IF NOT EXISTS clicks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+		clicked_at DATETIME NOT NULL,
+		referrer TEXT NOT NULL DEFAULT '',
+		ua_family TEXT NOT NULL DEFAULT '',
+		country TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_clicks_url_time ON clicks(url_id, clicked_at);`)
+	return err
+}
+
+// initCounters sets up the link counter, kept in the first shard, and
+// checks the shard count hasn't changed: links can't be found once they
+// hash to a different shard.
+func initCounters(count int) error {
+	_, err := shards[0].Exec(`
+	CREATE TABLE IF NOT EXISTS meta (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS counters (
+		name TEXT PRIMARY KEY,
+		value INTEGER NOT NULL
+	);
+	INSERT OR IGNORE INTO counters (name, value) VALUES ('links', 0);`)
+	if err != nil {
+		return err
+	}
+
+	var recorded string
+	err = shards[0].QueryRow("SELECT value FROM meta WHERE key = 'shards'").Scan(&recorded)
+	if errors.Is(err, sql.ErrNoRows) {
+		// A database from before sharding holds all its links in urls.db
+		var links int
+		if err := shards[0].QueryRow("SELECT COUNT(*) FROM urls").Scan(&links); err != nil {
+			return err
+		}
+		recorded = strconv.Itoa(count)
+		if links > 0 {
+			recorded = "1"
+		}
+		if _, err := shards[0].Exec("INSERT INTO meta (key, value) VALUES ('shards', ?)", recorded); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	if recorded != strconv.Itoa(count) {
+		return fmt.Errorf("the links are spread over %s shards, not %d; set SHARDS=%s", recorded, count, recorded)
+	}
+	return nil
+}
+
+// shardFor is the shard a short code lives in
+func shardFor(code string) *sql.DB {
+	h := fnv.New32a()
+	h.Write([]byte(code))
+	return shards[h.Sum32()%uint32(len(shards))]
+}
+
+// nextCounter takes the next value of the link counter
+func nextCounter() (uint64, error) {
+	var n uint64
+	err := shards[0].QueryRow("UPDATE counters SET value = value + 1 WHERE name = 'links' RETURNING value").Scan(&n)
+	return n, err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanURL(row scanner) (*URLEntry, error) {
+	var entry URLEntry
+	var expiresAt sql.NullTime
+	var passwordHash sql.NullString
+	var ownerID sql.NullInt64
+	err := row.Scan(&entry.ID, &entry.LongURL, &entry.ShortCode, &entry.CreatedAt, &entry.Clicks,
+		&expiresAt, &passwordHash, &entry.Custom, &entry.Redirect, &ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		entry.ExpiresAt = &expiresAt.Time
+	}
+	entry.passwordHash = passwordHash.String
+	entry.OwnerID = ownerID.Int64
+	return &entry, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// validateURL checks a URL is safe to redirect to
+func validateURL(longURL string) error {
+	u, err := url.Parse(longURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errInvalidURL
+	}
+	if blocklist.Blocks(longURL) {
+		return errBlockedURL
+	}
+	return nil
+}
+
+// nullOwner stores links made without an API key with no owner
+func nullOwner(ownerID int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: ownerID, Valid: ownerID != 0}
+}
+
+// findPlainURL looks in every shard for a link of the owner's to longURL
+// with no alias, expiry or password and the same redirect, which a new
+// plain link can reuse
+func findPlainURL(longURL string, redirect int, ownerID int64) (*URLEntry, error) {
+	for _, shard := range shards {
+		entry, err := scanURL(shard.QueryRow("SELECT "+urlColumns+` FROM urls
+			WHERE long_url = ? AND custom = 0 AND expires_at IS NULL AND password_hash IS NULL
+				AND redirect_status = ? AND owner_key_id IS ?
+			LIMIT 1`, longURL, redirect, nullOwner(ownerID)))
+		if err == nil {
+			return entry, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// createURL makes a link, reusing an existing one for a plain link. It
+// reports whether it created a new one.
+func createURL(opts LinkOptions) (*URLEntry, bool, error) {
+	if err := validateURL(opts.LongURL); err != nil {
+		return nil, false, err
+	}
+	if opts.Alias != "" {
+		if err := validateAlias(opts.Alias); err != nil {
+			return nil, false, err
+		}
+	}
+	if opts.Redirect == 0 {
+		opts.Redirect = defaultRedirect
+	}
+	if !redirectStatuses[opts.Redirect] {
+		return nil, false, errInvalidRedirect
+	}
+
+	plain := opts.Alias == "" && opts.ExpiresAt == nil && opts.Password == ""
+	if plain {
+		// Check if URL already exists
+		entry, err := findPlainURL(opts.LongURL, opts.Redirect, opts.OwnerID)
+		if err != nil || entry != nil {
+			return entry, false, err
+		}
+	}
+
+	entry := &URLEntry{
+		LongURL:   opts.LongURL,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		ExpiresAt: opts.ExpiresAt,
+		Custom:    opts.Alias != "",
+		Redirect:  opts.Redirect,
+		OwnerID:   opts.OwnerID,
+	}
+	var passwordHash sql.NullString
+	if opts.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, false, err
+		}
+		entry.passwordHash = string(hash)
+		passwordHash = sql.NullString{String: entry.passwordHash, Valid: true}
+	}
+	var expiresAt sql.NullTime
+	if opts.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: opts.ExpiresAt.UTC(), Valid: true}
+	}
+
+	insert := func(code string) error {
+		result, err := shardFor(code).Exec(`INSERT INTO urls
+			(long_url, short_code, created_at, expires_at, password_hash, custom, redirect_status, owner_key_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			entry.LongURL, code, entry.CreatedAt.Format(time.DateTime), expiresAt, passwordHash, entry.Custom,
+			entry.Redirect, nullOwner(entry.OwnerID))
+		if err != nil {
+			return err
+		}
+		id, _ := result.LastInsertId()
+		entry.ID = int(id)
+		entry.ShortCode = code
+		return nil
+	}
+
+	if entry.Custom {
+		err := insert(opts.Alias)
+		if isUniqueViolation(err) {
+			return nil, false, errAliasTaken
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return entry, true, nil
+	}
+
+	// Generated codes never repeat, but one may already be someone's alias
+	for attempt := 0; attempt < maxCodeAttempts; attempt++ {
+		n, err := nextCounter()
+		if err != nil {
+			return nil, false, err
+		}
+		err = insert(shortCodeFor(n))
+		if isUniqueViolation(err) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return entry, true, nil
+	}
+	return nil, false, errors.New("could not find a free short code")
+}
+
+func getURL(shortCode string) (*URLEntry, error) {
+	return scanURL(shardFor(shortCode).QueryRow("SELECT "+urlColumns+" FROM urls WHERE short_code = ?", shortCode))
+}
+
+// deleteURL deletes one of the owner's links and its clicks
+func deleteURL(shortCode string, ownerID int64) error {
+	result, err := shardFor(shortCode).Exec("DELETE FROM urls WHERE short_code = ? AND owner_key_id = ?", shortCode, ownerID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// getPublicURLs gets the links made without an API key, newest first.
+// Those an API key created are only shown to that key.
+func getPublicURLs() ([]URLEntry, error) {
+	return queryAllShards("SELECT " + urlColumns + " FROM urls WHERE owner_key_id IS NULL ORDER BY created_at DESC")
+}
+
+// getOwnedURLs gets the links an API key created, newest first
+func getOwnedURLs(ownerID int64) ([]URLEntry, error) {
+	return queryAllShards("SELECT "+urlColumns+" FROM urls WHERE owner_key_id = ? ORDER BY created_at DESC", ownerID)
+}
+
+// queryAllShards runs a query for links on every shard, merging the
+// results newest first
+func queryAllShards(query string, args ...any) ([]URLEntry, error) {
+	var urls []URLEntry
+	for _, shard := range shards {
+		rows, err := shard.Query(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			entry, err := scanURL(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			urls = append(urls, *entry)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(urls, func(i, j int) bool {
+		return urls[i].CreatedAt.After(urls[j].CreatedAt)
+	})
+	return urls, nil
+}
@@ -1,27 +1,41 @@
 package config
 
 import (
+	"log"
 	"os"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	MongoURI      string
-	DatabaseName  string
-	ServerAddress string
+	MongoURI       string
+	DatabaseName   string
+	ServerAddress  string
 	AllowedOrigins []string
+	JWTSecret      string
 }
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() *Config {
 	return &Config{
-		MongoURI:      getEnv("MONGO_URI", "mongodb://localhost:27017"),
-		DatabaseName:  getEnv("DB_NAME", "student_tracker"),
-		ServerAddress: getEnv("SERVER_ADDRESS", ":8080"),
+		MongoURI:       getEnv("MONGO_URI", "mongodb://localhost:27017"),
+		DatabaseName:   getEnv("DB_NAME", "student_tracker"),
+		ServerAddress:  getEnv("SERVER_ADDRESS", ":8080"),
 		AllowedOrigins: []string{getEnv("ALLOWED_ORIGIN", "http://localhost:4321")},
+		JWTSecret:      getJWTSecret(),
 	}
 }
 
+// getJWTSecret gets the secret tokens are signed with, falling back to an
+// insecure one for development
+func getJWTSecret() string {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		log.Println("JWT_SECRET is not set; using an insecure development secret")
+		return "student-activity-tracker-dev-secret"
+	}
+	return secret
+}
+
 // getEnv gets an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
@@ -1,39 +1,112 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pac-cee/student-activity-tracker/handlers/middleware"
 	"github.com/pac-cee/student-activity-tracker/models"
 	"github.com/pac-cee/student-activity-tracker/services"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// ActivityHandler handles HTTP requests for activities
+// ActivityHandler handles HTTP requests for activities, and for the
+// classes and assignments that hand them out
 type ActivityHandler struct {
 	service *services.ActivityService
+	classes *services.ClassService
+	auth    *services.AuthService
 }
 
 // NewActivityHandler creates a new ActivityHandler
-func NewActivityHandler(service *services.ActivityService) *ActivityHandler {
+func NewActivityHandler(service *services.ActivityService, classes *services.ClassService, auth *services.AuthService) *ActivityHandler {
 	return &ActivityHandler{
 		service: service,
+		classes: classes,
+		auth:    auth,
 	}
 }
 
-// RegisterRoutes registers the routes for activities
+// RegisterRoutes registers the routes for activities, classes and
+// assignments. They all need a logged-in user and only reach that user's
+// activities; managing classes and assignments is for teachers, joining
+// them for students. Anyone not yet in an organisation can create one.
 func (h *ActivityHandler) RegisterRoutes(router *gin.Engine) {
 	api := router.Group("/api")
+	api.Use(middleware.Auth(h.auth))
 	{
+		api.GET("/organisation", h.GetOrganisation)
+		api.POST("/organisation", h.CreateOrganisation)
+		api.POST("/organisation/join", h.JoinOrganisation)
+		api.GET("/classes", h.GetClasses)
+
 		api.POST("/activities", h.CreateActivity)
 		api.GET("/activities", h.GetActivities)
+		api.GET("/activities/:id", h.GetActivity)
 		api.PUT("/activities/:id", h.UpdateActivity)
 		api.DELETE("/activities/:id", h.DeleteActivity)
 		api.PUT("/activities/:id/start", h.StartActivity)
+		api.PUT("/activities/:id/pause", h.PauseActivity)
+		api.PUT("/activities/:id/resume", h.ResumeActivity)
 		api.PUT("/activities/:id/complete", h.CompleteActivity)
+
+		api.GET("/activities/:id/pomodoro", h.GetPomodoro)
+		api.PUT("/activities/:id/pomodoro", h.SetPomodoro)
+		api.DELETE("/activities/:id/pomodoro", h.StopPomodoro)
+		api.PUT("/activities/:id/pomodoro/next", h.NextPhase)
+	}
+
+	teacher := api.Group("")
+	teacher.Use(middleware.RequireRole(h.auth, models.RoleTeacher))
+	{
+		teacher.GET("/organisation/members", h.GetMembers)
+		teacher.PUT("/organisation/members/:userId/role", h.SetMemberRole)
+		teacher.POST("/classes", h.CreateClass)
+		teacher.DELETE("/classes/:id/students/:studentId", h.RemoveStudent)
+		teacher.GET("/classes/:id/assignments", h.GetAssignments)
+		teacher.POST("/classes/:id/assignments", h.CreateAssignment)
+		teacher.GET("/classes/:id/assignments/:assignmentId", h.GetAssignmentProgress)
+		teacher.GET("/classes/:id/dashboard", h.GetDashboard)
+	}
+
+	student := api.Group("")
+	student.Use(middleware.RequireRole(h.auth, models.RoleStudent))
+	{
+		student.POST("/classes/join", h.JoinClass)
 	}
 }
 
+// checkConflicts refuses a planned window that overlaps the student's
+// other activities, listing them, unless the request has ?force=true
+func (h *ActivityHandler) checkConflicts(c *gin.Context, id primitive.ObjectID, activity *models.Activity) bool {
+	if activity.StartTime == nil || c.Query("force") == "true" {
+		return true
+	}
+	end := activity.EndTime
+	if end == nil {
+		e := activity.StartTime.Add(time.Duration(activity.Duration) * time.Minute)
+		end = &e
+	}
+
+	conflicts, err := h.service.Conflicts(c.Request.Context(), middleware.UserID(c), id, *activity.StartTime, *end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if len(conflicts) > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "Activity overlaps other activities; pass ?force=true to plan it anyway",
+			"conflicts": conflicts,
+		})
+		return false
+	}
+	return true
+}
+
 // CreateActivity handles the creation of a new activity
 func (h *ActivityHandler) CreateActivity(c *gin.Context) {
 	var activity models.Activity
@@ -42,8 +115,11 @@ func (h *ActivityHandler) CreateActivity(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.CreateActivity(c.Request.Context(), &activity); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if !h.checkConflicts(c, primitive.NilObjectID, &activity) {
+		return
+	}
+	if err := h.service.CreateActivity(c.Request.Context(), middleware.UserID(c), &activity); err != nil {
+		writeError(c, err, "Activity not found")
 		return
 	}
 
@@ -52,7 +128,7 @@ func (h *ActivityHandler) CreateActivity(c *gin.Context) {
 
 // GetActivities handles retrieving all activities
 func (h *ActivityHandler) GetActivities(c *gin.Context) {
-	activities, err := h.service.GetActivities(c.Request.Context())
+	activities, err := h.service.GetActivities(c.Request.Context(), middleware.UserID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -61,6 +137,23 @@ func (h *ActivityHandler) GetActivities(c *gin.Context) {
 	c.JSON(http.StatusOK, activities)
 }
 
+// GetActivity handles retrieving a single activity
+func (h *ActivityHandler) GetActivity(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	activity, err := h.service.GetActivity(c.Request.Context(), middleware.UserID(c), id)
+	if err != nil {
+		writeError(c, err, "Activity not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, activity)
+}
+
 // UpdateActivity handles updating an existing activity
 func (h *ActivityHandler) UpdateActivity(c *gin.Context) {
 	id, err := primitive.ObjectIDFromHex(c.Param("id"))
@@ -75,12 +168,16 @@ func (h *ActivityHandler) UpdateActivity(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.UpdateActivity(c.Request.Context(), id, &activity); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if !h.checkConflicts(c, id, &activity) {
+		return
+	}
+	ownerID := middleware.UserID(c)
+	if err := h.service.UpdateActivity(c.Request.Context(), ownerID, id, &activity); err != nil {
+		writeError(c, err, "Activity not found")
 		return
 	}
 
-	c.JSON(http.StatusOK, activity)
+	h.respondActivity(c, ownerID, id)
 }
 
 // DeleteActivity handles deleting an activity
@@ -91,52 +188,106 @@ func (h *ActivityHandler) DeleteActivity(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeleteActivity(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.DeleteActivity(c.Request.Context(), middleware.UserID(c), id); err != nil {
+		writeError(c, err, "Activity not found")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Activity deleted"})
 }
 
-// StartActivity handles starting an activity
-func (h *ActivityHandler) StartActivity(c *gin.Context) {
+// track handles a change to an activity's time tracking, responding with
+// the activity as it then is
+func (h *ActivityHandler) track(c *gin.Context, change func(ctx context.Context, ownerID, id primitive.ObjectID) error) {
 	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
 
-	if err := h.service.StartActivity(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	ownerID := middleware.UserID(c)
+	if err := change(c.Request.Context(), ownerID, id); err != nil {
+		writeError(c, err, "Activity not found")
 		return
 	}
 
-	activity, err := h.service.GetActivity(c.Request.Context(), id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+	h.respondActivity(c, ownerID, id)
+}
 
-	c.JSON(http.StatusOK, activity)
+// StartActivity handles starting an activity
+func (h *ActivityHandler) StartActivity(c *gin.Context) {
+	h.track(c, h.service.StartActivity)
+}
+
+// PauseActivity handles pausing an activity in progress
+func (h *ActivityHandler) PauseActivity(c *gin.Context) {
+	h.track(c, h.service.PauseActivity)
+}
+
+// ResumeActivity handles resuming a paused activity
+func (h *ActivityHandler) ResumeActivity(c *gin.Context) {
+	h.track(c, h.service.ResumeActivity)
 }
 
 // CompleteActivity handles completing an activity
 func (h *ActivityHandler) CompleteActivity(c *gin.Context) {
+	h.track(c, h.service.CompleteActivity)
+}
+
+// GetPomodoro handles retrieving where an activity is in its Pomodoro
+// cycle
+func (h *ActivityHandler) GetPomodoro(c *gin.Context) {
 	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
 
-	if err := h.service.CompleteActivity(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	activity, err := h.service.GetActivity(c.Request.Context(), middleware.UserID(c), id)
+	if err != nil {
+		writeError(c, err, "Activity not found")
+		return
+	}
+	status, err := services.Pomodoro(*activity, time.Now())
+	if err != nil {
+		writeError(c, err, "Activity not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// SetPomodoro handles turning Pomodoro mode on, or changing its cycle.
+// Settings left out of the body take the defaults.
+func (h *ActivityHandler) SetPomodoro(c *gin.Context) {
+	var settings models.PomodoroSettings
+	if err := c.ShouldBindJSON(&settings); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	activity, err := h.service.GetActivity(c.Request.Context(), id)
+	h.track(c, func(ctx context.Context, ownerID, id primitive.ObjectID) error {
+		return h.service.SetPomodoro(ctx, ownerID, id, &settings)
+	})
+}
+
+// StopPomodoro handles turning Pomodoro mode off
+func (h *ActivityHandler) StopPomodoro(c *gin.Context) {
+	h.track(c, func(ctx context.Context, ownerID, id primitive.ObjectID) error {
+		return h.service.SetPomodoro(ctx, ownerID, id, nil)
+	})
+}
+
+// NextPhase handles moving an activity on to its next Pomodoro phase
+func (h *ActivityHandler) NextPhase(c *gin.Context) {
+	h.track(c, h.service.NextPhase)
+}
+
+// respondActivity sends the activity as it now is
+func (h *ActivityHandler) respondActivity(c *gin.Context, ownerID, id primitive.ObjectID) {
+	activity, err := h.service.GetActivity(c.Request.Context(), ownerID, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err, "Activity not found")
 		return
 	}
 
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -10,36 +11,72 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pac-cee/student-activity-tracker/models"
 	"github.com/pac-cee/student-activity-tracker/services"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func setupTestHandler(t *testing.T) (*ActivityHandler, *gin.Engine, func()) {
 	// Connect to test database
-	client, err := mongo.Connect(nil, options.Client().ApplyURI("mongodb://localhost:27017"))
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:27017"))
 	if err != nil {
 		t.Fatalf("Failed to connect to MongoDB: %v", err)
 	}
 
 	collection := client.Database("test_db").Collection("test_activities")
+	users := client.Database("test_db").Collection("test_users")
+	organisations := client.Database("test_db").Collection("test_organisations")
+	classes := client.Database("test_db").Collection("test_classes")
+	assignments := client.Database("test_db").Collection("test_assignments")
+	authService := services.NewAuthService(users, "test-secret")
 	service := services.NewActivityService(collection)
-	handler := NewActivityHandler(service)
+	classService := services.NewClassService(organisations, classes, assignments, authService, service)
+	handler := NewActivityHandler(service, classService, authService)
 
 	// Set up Gin router
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
+	NewAuthHandler(authService).RegisterRoutes(router)
 	handler.RegisterRoutes(router)
 
 	return handler, router, func() {
-		collection.Drop(nil)
-		client.Disconnect(nil)
+		collection.Drop(context.Background())
+		users.Drop(context.Background())
+		organisations.Drop(context.Background())
+		classes.Drop(context.Background())
+		assignments.Drop(context.Background())
+		client.Disconnect(context.Background())
 	}
 }
 
+// login signs up a student and returns their bearer token
+func login(t *testing.T, router *gin.Engine, email string) string {
+	body, _ := json.Marshal(RegisterRequest{Name: "Test Student", Email: email, Password: "password1"})
+	req := httptest.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to register: %d %s", w.Code, w.Body)
+	}
+
+	req = httptest.NewRequest("POST", "/api/auth/login", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Token == "" {
+		t.Fatalf("Failed to log in: %d %s", w.Code, w.Body)
+	}
+	return "Bearer " + response.Token
+}
+
 func TestActivityHandler_CreateActivity(t *testing.T) {
 	_, router, cleanup := setupTestHandler(t)
 	defer cleanup()
+	token := login(t, router, "student@example.com")
 
 	// Create test activity
 	activity := models.Activity{
@@ -51,6 +88,7 @@ func TestActivityHandler_CreateActivity(t *testing.T) {
 	body, _ := json.Marshal(activity)
 	req := httptest.NewRequest("POST", "/api/activities", bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
 
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -71,8 +109,9 @@ func TestActivityHandler_CreateActivity(t *testing.T) {
 }
 
 func TestActivityHandler_GetActivities(t *testing.T) {
-	handler, router, cleanup := setupTestHandler(t)
+	_, router, cleanup := setupTestHandler(t)
 	defer cleanup()
+	token := login(t, router, "student@example.com")
 
 	// Create test activities
 	activities := []models.Activity{
@@ -92,6 +131,7 @@ func TestActivityHandler_GetActivities(t *testing.T) {
 		body, _ := json.Marshal(activity)
 		req := httptest.NewRequest("POST", "/api/activities", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
 
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
@@ -99,6 +139,7 @@ func TestActivityHandler_GetActivities(t *testing.T) {
 
 	// Test getting activities
 	req := httptest.NewRequest("GET", "/api/activities", nil)
+	req.Header.Set("Authorization", token)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
@@ -118,8 +159,9 @@ func TestActivityHandler_GetActivities(t *testing.T) {
 }
 
 func TestActivityHandler_StartActivity(t *testing.T) {
-	handler, router, cleanup := setupTestHandler(t)
+	_, router, cleanup := setupTestHandler(t)
 	defer cleanup()
+	token := login(t, router, "student@example.com")
 
 	// Create test activity
 	activity := models.Activity{
@@ -131,6 +173,7 @@ func TestActivityHandler_StartActivity(t *testing.T) {
 	body, _ := json.Marshal(activity)
 	req := httptest.NewRequest("POST", "/api/activities", bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
 
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -140,6 +183,7 @@ func TestActivityHandler_StartActivity(t *testing.T) {
 
 	// Test starting activity
 	req = httptest.NewRequest("PUT", "/api/activities/"+createdActivity.ID.Hex()+"/start", nil)
+	req.Header.Set("Authorization", token)
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
@@ -157,3 +201,72 @@ func TestActivityHandler_StartActivity(t *testing.T) {
 		t.Errorf("Expected status 'in-progress', got %s", response.Status)
 	}
 }
+
+func TestActivityHandler_Roles(t *testing.T) {
+	_, router, cleanup := setupTestHandler(t)
+	defer cleanup()
+	send := func(method, path, token string, body any) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(b))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Asking to sign up as a teacher still makes a student
+	w := send("POST", "/api/auth/register", "", map[string]string{
+		"name": "Mallory", "email": "mallory@example.com", "password": "password1", "role": "teacher",
+	})
+	var registered models.User
+	if json.Unmarshal(w.Body.Bytes(), &registered); w.Code != http.StatusCreated || registered.Role != models.RoleStudent {
+		t.Errorf("Expected a student, got %d %s", w.Code, w.Body)
+	}
+
+	admin := login(t, router, "admin@example.com")
+	w = send("POST", "/api/organisation", admin, NameRequest{Name: "School"})
+	var organisation models.Organisation
+	if err := json.Unmarshal(w.Body.Bytes(), &organisation); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("Failed to create organisation: %d %s", w.Code, w.Body)
+	}
+	// Creating it made the admin a teacher, without logging in again
+	if w := send("POST", "/api/classes", admin, NameRequest{Name: "Maths"}); w.Code != http.StatusCreated {
+		t.Errorf("Expected the admin to create a class, got %d %s", w.Code, w.Body)
+	}
+
+	member := login(t, router, "member@example.com")
+	if w := send("POST", "/api/organisation/join", member, JoinRequest{Code: organisation.JoinCode}); w.Code != http.StatusOK {
+		t.Fatalf("Failed to join organisation: %d %s", w.Code, w.Body)
+	}
+	if w := send("POST", "/api/classes", member, NameRequest{Name: "Art"}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected a student not to create a class, got %d", w.Code)
+	}
+
+	var members []models.User
+	w = send("GET", "/api/organisation/members", admin, nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &members); err != nil || len(members) != 2 {
+		t.Fatalf("Expected 2 members, got %d %s", w.Code, w.Body)
+	}
+	var memberID string
+	for _, m := range members {
+		if m.Email == "member@example.com" {
+			memberID = m.ID.Hex()
+		}
+	}
+	if w := send("PUT", "/api/organisation/members/"+memberID+"/role", member, RoleRequest{Role: models.RoleTeacher}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected a student not to promote themselves, got %d", w.Code)
+	}
+	if w := send("PUT", "/api/organisation/members/"+registered.ID.Hex()+"/role", admin, RoleRequest{Role: models.RoleTeacher}); w.Code != http.StatusNotFound {
+		t.Errorf("Expected someone outside the organisation not to be found, got %d", w.Code)
+	}
+	if w := send("PUT", "/api/organisation/members/"+memberID+"/role", admin, RoleRequest{Role: models.RoleTeacher}); w.Code != http.StatusOK {
+		t.Fatalf("Failed to promote member: %d %s", w.Code, w.Body)
+	}
+	if w := send("POST", "/api/classes", member, NameRequest{Name: "Art"}); w.Code != http.StatusCreated {
+		t.Errorf("Expected the new teacher to create a class, got %d %s", w.Code, w.Body)
+	}
+	if w := send("GET", "/api/organisation/members", member, nil); w.Code != http.StatusForbidden {
+		t.Errorf("Expected a teacher who isn't an admin not to list members, got %d", w.Code)
+	}
+}
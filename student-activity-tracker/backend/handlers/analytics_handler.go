@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pac-cee/student-activity-tracker/handlers/middleware"
+	"github.com/pac-cee/student-activity-tracker/services"
+)
+
+// maxAnalyticsDays is the longest range analytics cover
+const maxAnalyticsDays = 366
+
+// AnalyticsHandler handles HTTP requests for productivity analytics
+type AnalyticsHandler struct {
+	activities *services.ActivityService
+	planner    *services.PlannerService
+	auth       *services.AuthService
+}
+
+// NewAnalyticsHandler creates a new AnalyticsHandler
+func NewAnalyticsHandler(activities *services.ActivityService, planner *services.PlannerService, auth *services.AuthService) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		activities: activities,
+		planner:    planner,
+		auth:       auth,
+	}
+}
+
+// RegisterRoutes registers the routes for analytics
+func (h *AnalyticsHandler) RegisterRoutes(router *gin.Engine) {
+	api := router.Group("/api")
+	api.Use(middleware.Auth(h.auth))
+	{
+		api.GET("/analytics", h.GetAnalytics)
+	}
+}
+
+// GetAnalytics handles reporting planned against actual time, completion
+// rates and focus time by ?period=day (the default) or week, from ?from=
+// to ?to= (YYYY-MM-DD, inclusive). By default that is the last 7 days, or
+// the last 4 weeks.
+func (h *AnalyticsHandler) GetAnalytics(c *gin.Context) {
+	ctx, ownerID := c.Request.Context(), middleware.UserID(c)
+	period := c.DefaultQuery("period", services.PeriodDay)
+	if period != services.PeriodDay && period != services.PeriodWeek {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be day or week"})
+		return
+	}
+	loc, err := h.planner.Location(ctx, ownerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	y, m, d := now.In(loc).Date()
+	to := time.Date(y, m, d, 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -7)
+	if period == services.PeriodWeek {
+		from = services.WeekStart(to.AddDate(0, 0, -1)).AddDate(0, 0, -21)
+	}
+	for param, date := range map[string]*time.Time{"from": &from, "to": &to} {
+		s := c.Query(param)
+		if s == "" {
+			continue
+		}
+		day, err := time.ParseInLocation("2006-01-02", s, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be YYYY-MM-DD"})
+			return
+		}
+		if param == "to" {
+			day = day.AddDate(0, 0, 1)
+		}
+		*date = day
+	}
+	if period == services.PeriodWeek {
+		from = services.WeekStart(from)
+	}
+	if !to.After(from) || to.Sub(from) > maxAnalyticsDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to, and at most a year before"})
+		return
+	}
+
+	activities, err := h.activities.ActivitiesInRange(ctx, ownerID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, services.Analyze(activities, from, to, period, now))
+}
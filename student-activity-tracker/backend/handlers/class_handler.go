@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pac-cee/student-activity-tracker/handlers/middleware"
+	"github.com/pac-cee/student-activity-tracker/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NameRequest is the body that names a new organisation or class
+type NameRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// JoinRequest is the body of joining an organisation or class
+type JoinRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// RoleRequest is the body of changing a member's role
+type RoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=student teacher"`
+}
+
+// classID parses the class in the path
+func classID(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
+
+// GetOrganisation handles retrieving the user's organisation
+func (h *ActivityHandler) GetOrganisation(c *gin.Context) {
+	organisation, err := h.classes.GetOrganisation(c.Request.Context(), middleware.UserID(c))
+	if err != nil {
+		writeError(c, err, "Not in an organisation")
+		return
+	}
+
+	c.JSON(http.StatusOK, organisation)
+}
+
+// CreateOrganisation handles a user creating an organisation, which makes
+// them its admin and a teacher
+func (h *ActivityHandler) CreateOrganisation(c *gin.Context) {
+	var req NameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organisation, err := h.classes.CreateOrganisation(c.Request.Context(), middleware.UserID(c), req.Name)
+	if err != nil {
+		writeError(c, err, "User not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, organisation)
+}
+
+// GetMembers handles an organisation admin listing its members
+func (h *ActivityHandler) GetMembers(c *gin.Context) {
+	members, err := h.classes.GetMembers(c.Request.Context(), middleware.UserID(c))
+	if err != nil {
+		writeError(c, err, "Not in an organisation")
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// SetMemberRole handles an organisation admin making a member a student
+// or a teacher
+func (h *ActivityHandler) SetMemberRole(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.classes.SetMemberRole(c.Request.Context(), middleware.UserID(c), userID, req.Role)
+	if err != nil {
+		writeError(c, err, "Member not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// JoinOrganisation handles a user joining an organisation with its code
+func (h *ActivityHandler) JoinOrganisation(c *gin.Context) {
+	var req JoinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organisation, err := h.classes.JoinOrganisation(c.Request.Context(), middleware.UserID(c), req.Code)
+	if err != nil {
+		writeError(c, err, "No organisation has that code")
+		return
+	}
+
+	c.JSON(http.StatusOK, organisation)
+}
+
+// GetClasses handles retrieving the classes the user teaches or is in
+func (h *ActivityHandler) GetClasses(c *gin.Context) {
+	classes, err := h.classes.GetClasses(c.Request.Context(), middleware.UserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, classes)
+}
+
+// CreateClass handles a teacher creating a class in their organisation
+func (h *ActivityHandler) CreateClass(c *gin.Context) {
+	var req NameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	class, err := h.classes.CreateClass(c.Request.Context(), middleware.UserID(c), req.Name)
+	if err != nil {
+		writeError(c, err, "User not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, class)
+}
+
+// JoinClass handles a student joining a class with its code
+func (h *ActivityHandler) JoinClass(c *gin.Context) {
+	var req JoinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	class, err := h.classes.JoinClass(c.Request.Context(), middleware.UserID(c), req.Code)
+	if err != nil {
+		writeError(c, err, "No class has that code")
+		return
+	}
+
+	c.JSON(http.StatusOK, class)
+}
+
+// RemoveStudent handles a teacher taking a student out of a class
+func (h *ActivityHandler) RemoveStudent(c *gin.Context) {
+	id, ok := classID(c)
+	if !ok {
+		return
+	}
+	studentID, err := primitive.ObjectIDFromHex(c.Param("studentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if err := h.classes.RemoveStudent(c.Request.Context(), middleware.UserID(c), id, studentID); err != nil {
+		writeError(c, err, "Student not found in class")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Student removed"})
+}
+
+// GetAssignments handles retrieving a class's assignments
+func (h *ActivityHandler) GetAssignments(c *gin.Context) {
+	id, ok := classID(c)
+	if !ok {
+		return
+	}
+
+	assignments, err := h.classes.GetAssignments(c.Request.Context(), middleware.UserID(c), id)
+	if err != nil {
+		writeError(c, err, "Class not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, assignments)
+}
+
+// CreateAssignment handles a teacher setting a class an assignment, which
+// every student in it gets a copy of
+func (h *ActivityHandler) CreateAssignment(c *gin.Context) {
+	id, ok := classID(c)
+	if !ok {
+		return
+	}
+	var assignment models.Assignment
+	if err := c.ShouldBindJSON(&assignment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.classes.CreateAssignment(c.Request.Context(), middleware.UserID(c), id, &assignment); err != nil {
+		writeError(c, err, "Class not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, assignment)
+}
+
+// GetAssignmentProgress handles retrieving each student's status on an
+// assignment
+func (h *ActivityHandler) GetAssignmentProgress(c *gin.Context) {
+	id, ok := classID(c)
+	if !ok {
+		return
+	}
+	assignmentID, err := primitive.ObjectIDFromHex(c.Param("assignmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	assignment, statuses, err := h.classes.AssignmentProgress(c.Request.Context(), middleware.UserID(c), id, assignmentID, time.Now())
+	if err != nil {
+		writeError(c, err, "Assignment not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assignment": assignment, "students": statuses})
+}
+
+// GetDashboard handles retrieving a class's progress on its assignments
+func (h *ActivityHandler) GetDashboard(c *gin.Context) {
+	id, ok := classID(c)
+	if !ok {
+		return
+	}
+
+	dashboard, err := h.classes.Dashboard(c.Request.Context(), middleware.UserID(c), id, time.Now())
+	if err != nil {
+		writeError(c, err, "Class not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, dashboard)
+}
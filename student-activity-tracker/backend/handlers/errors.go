@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pac-cee/student-activity-tracker/services"
+)
+
+// writeError responds with the status that fits a service error
+func writeError(c *gin.Context, err error, notFound string) {
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+	case errors.Is(err, services.ErrInvalidActivity),
+		errors.Is(err, services.ErrInvalidTemplate),
+		errors.Is(err, services.ErrInvalidAvailability),
+		errors.Is(err, services.ErrInvalidPomodoro),
+		errors.Is(err, services.ErrInvalidAssignment),
+		errors.Is(err, services.ErrInvalidRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTransition),
+		errors.Is(err, services.ErrAlreadyInOrganisation),
+		errors.Is(err, services.ErrNoOrganisation):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pac-cee/student-activity-tracker/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	userIDKey = "userID"
+	roleKey   = "role"
+)
+
+// Auth requires a bearer token and puts the user it identifies in the
+// context, for UserID
+func Auth(auth *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization required"})
+			return
+		}
+		userID, role, err := auth.ParseToken(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+		c.Set(userIDKey, userID)
+		c.Set(roleKey, role)
+		c.Next()
+	}
+}
+
+// RequireRole lets through only users with one of the roles. It goes after
+// Auth, and checks the role the user has now rather than the one in their
+// token, which an organisation admin may since have changed.
+func RequireRole(auth *services.AuthService, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := auth.GetUser(c.Request.Context(), UserID(c))
+		if errors.Is(err, services.ErrNotFound) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		role := user.Role
+		c.Set(roleKey, role)
+		for _, r := range roles {
+			if r == role {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Not allowed for a " + role})
+	}
+}
+
+// UserID is the user Auth authenticated
+func UserID(c *gin.Context) primitive.ObjectID {
+	return c.MustGet(userIDKey).(primitive.ObjectID)
+}
+
+// Role is the role of the user Auth authenticated
+func Role(c *gin.Context) string {
+	return c.GetString(roleKey)
+}
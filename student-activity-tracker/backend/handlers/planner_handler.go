@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pac-cee/student-activity-tracker/handlers/middleware"
+	"github.com/pac-cee/student-activity-tracker/models"
+	"github.com/pac-cee/student-activity-tracker/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PlannerHandler handles HTTP requests for the weekly planner
+type PlannerHandler struct {
+	service    *services.PlannerService
+	activities *services.ActivityService
+	auth       *services.AuthService
+}
+
+// NewPlannerHandler creates a new PlannerHandler
+func NewPlannerHandler(service *services.PlannerService, activities *services.ActivityService, auth *services.AuthService) *PlannerHandler {
+	return &PlannerHandler{
+		service:    service,
+		activities: activities,
+		auth:       auth,
+	}
+}
+
+// RegisterRoutes registers the routes for templates, availability and
+// the planner. Weeks are given by ?week= as the YYYY-MM-DD date of any day
+// in them, this week by default.
+func (h *PlannerHandler) RegisterRoutes(router *gin.Engine) {
+	api := router.Group("/api")
+	api.Use(middleware.Auth(h.auth))
+	{
+		api.GET("/templates", h.GetTemplates)
+		api.POST("/templates", h.CreateTemplate)
+		api.PUT("/templates/:id", h.UpdateTemplate)
+		api.DELETE("/templates/:id", h.DeleteTemplate)
+
+		api.GET("/availability", h.GetAvailability)
+		api.PUT("/availability", h.SetAvailability)
+
+		api.GET("/planner", h.GetWeek)
+		api.GET("/planner/conflicts", h.GetConflicts)
+		api.POST("/planner/generate", h.GenerateWeek)
+		api.POST("/planner/schedule", h.ScheduleWeek)
+	}
+}
+
+// week reads ?week= in the student's timezone, writing the error response
+// if it can't
+func (h *PlannerHandler) week(c *gin.Context) (time.Time, bool) {
+	loc, err := h.service.Location(c.Request.Context(), middleware.UserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return time.Time{}, false
+	}
+	weekStart, err := services.ParseWeek(c.Query("week"), time.Now(), loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return time.Time{}, false
+	}
+	return weekStart, true
+}
+
+// GetTemplates handles retrieving all recurring templates
+func (h *PlannerHandler) GetTemplates(c *gin.Context) {
+	templates, err := h.service.GetTemplates(c.Request.Context(), middleware.UserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// CreateTemplate handles the creation of a new recurring template
+func (h *PlannerHandler) CreateTemplate(c *gin.Context) {
+	var template models.ActivityTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.CreateTemplate(c.Request.Context(), middleware.UserID(c), &template); err != nil {
+		writeError(c, err, "Template not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// UpdateTemplate handles updating an existing recurring template
+func (h *PlannerHandler) UpdateTemplate(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var template models.ActivityTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.UpdateTemplate(c.Request.Context(), middleware.UserID(c), id, &template); err != nil {
+		writeError(c, err, "Template not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteTemplate handles deleting a recurring template
+func (h *PlannerHandler) DeleteTemplate(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if err := h.service.DeleteTemplate(c.Request.Context(), middleware.UserID(c), id); err != nil {
+		writeError(c, err, "Template not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted"})
+}
+
+// GetAvailability handles retrieving the availability calendar
+func (h *PlannerHandler) GetAvailability(c *gin.Context) {
+	availability, err := h.service.GetAvailability(c.Request.Context(), middleware.UserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, availability)
+}
+
+// SetAvailability handles replacing the availability calendar
+func (h *PlannerHandler) SetAvailability(c *gin.Context) {
+	var availability models.Availability
+	if err := c.ShouldBindJSON(&availability); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.SetAvailability(c.Request.Context(), middleware.UserID(c), &availability); err != nil {
+		writeError(c, err, "Availability not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, availability)
+}
+
+// GetWeek handles retrieving a week's planned activities, the conflicts
+// between them and the free time left
+func (h *PlannerHandler) GetWeek(c *gin.Context) {
+	weekStart, ok := h.week(c)
+	if !ok {
+		return
+	}
+	ctx, ownerID := c.Request.Context(), middleware.UserID(c)
+
+	activities, err := h.activities.ActivitiesBetween(ctx, ownerID, weekStart, weekStart.AddDate(0, 0, 7))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	availability, err := h.service.GetAvailability(ctx, ownerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	free := services.FreeSlots(*availability, weekStart, activities, time.Now())
+	if free == nil {
+		free = []services.Slot{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"weekStart":  weekStart,
+		"activities": activities,
+		"conflicts":  services.FindConflicts(activities),
+		"free":       free,
+	})
+}
+
+// GetConflicts handles listing the overlapping activities in a week
+func (h *PlannerHandler) GetConflicts(c *gin.Context) {
+	weekStart, ok := h.week(c)
+	if !ok {
+		return
+	}
+
+	conflicts, err := h.service.WeekConflicts(c.Request.Context(), middleware.UserID(c), weekStart)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, conflicts)
+}
+
+// GenerateWeek handles planning a week's activities from the recurring
+// templates
+func (h *PlannerHandler) GenerateWeek(c *gin.Context) {
+	weekStart, ok := h.week(c)
+	if !ok {
+		return
+	}
+	ctx, ownerID := c.Request.Context(), middleware.UserID(c)
+
+	created, err := h.service.GenerateWeek(ctx, ownerID, weekStart, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	conflicts, err := h.service.WeekConflicts(ctx, ownerID, weekStart)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"created": created, "conflicts": conflicts})
+}
+
+// ScheduleWeek handles fitting unscheduled activities into a week's free
+// time
+func (h *PlannerHandler) ScheduleWeek(c *gin.Context) {
+	weekStart, ok := h.week(c)
+	if !ok {
+		return
+	}
+
+	scheduled, unscheduled, err := h.service.ScheduleWeek(c.Request.Context(), middleware.UserID(c), weekStart, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"scheduled": scheduled, "unscheduled": unscheduled})
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"log"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -23,10 +25,29 @@ func main() {
 	defer db.Close()
 
 	// Initialize services
+	authService := services.NewAuthService(db.Collection("users"), cfg.JWTSecret)
 	activityService := services.NewActivityService(db.Collection("activities"))
+	classService := services.NewClassService(db.Collection("organisations"), db.Collection("classes"), db.Collection("assignments"), authService, activityService)
+	plannerService := services.NewPlannerService(db.Collection("templates"), db.Collection("availability"), activityService)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	for _, ensure := range []func(context.Context) error{
+		authService.EnsureIndexes,
+		activityService.EnsureIndexes,
+		classService.EnsureIndexes,
+		plannerService.EnsureIndexes,
+	} {
+		if err := ensure(ctx); err != nil {
+			log.Fatal(err)
+		}
+	}
+	cancel()
 
 	// Initialize handlers
-	activityHandler := handlers.NewActivityHandler(activityService)
+	authHandler := handlers.NewAuthHandler(authService)
+	activityHandler := handlers.NewActivityHandler(activityService, classService, authService)
+	plannerHandler := handlers.NewPlannerHandler(plannerService, activityService, authService)
+	analyticsHandler := handlers.NewAnalyticsHandler(activityService, plannerService, authService)
 
 	// Set up Gin router
 	router := gin.Default()
@@ -34,10 +55,14 @@ func main() {
 	// Configure CORS
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowOrigins = cfg.AllowedOrigins
+	corsConfig.AddAllowHeaders("Authorization")
 	router.Use(cors.New(corsConfig))
 
 	// Register routes
+	authHandler.RegisterRoutes(router)
 	activityHandler.RegisterRoutes(router)
+	plannerHandler.RegisterRoutes(router)
+	analyticsHandler.RegisterRoutes(router)
 
 	// Start server
 	log.Printf("Server starting on %s", cfg.ServerAddress)
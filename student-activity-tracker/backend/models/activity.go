@@ -6,15 +6,110 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Activity statuses
+const (
+	StatusPlanned    = "planned"
+	StatusInProgress = "in-progress"
+	StatusPaused     = "paused"
+	StatusCompleted  = "completed"
+)
+
+// Kinds of time segment
+const (
+	SegmentWork  = "work"
+	SegmentBreak = "break"
+)
+
+// Activity priorities, highest first
+const (
+	PriorityHigh   = "high"
+	PriorityMedium = "medium"
+	PriorityLow    = "low"
+)
+
 // Activity represents a student's planned activity
 type Activity struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Title       string            `bson:"title" json:"title"`
-	Description string            `bson:"description" json:"description"`
-	Duration    int               `bson:"duration" json:"duration"` // in minutes
-	Status      string            `bson:"status" json:"status"`    // planned, in-progress, completed
-	StartTime   *time.Time        `bson:"startTime" json:"startTime,omitempty"`
-	EndTime     *time.Time        `bson:"endTime" json:"endTime,omitempty"`
-	CreatedAt   time.Time         `bson:"createdAt" json:"createdAt"`
-	UpdatedAt   time.Time         `bson:"updatedAt" json:"updatedAt"`
+	ID           primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	OwnerID      primitive.ObjectID  `bson:"ownerId" json:"ownerId"`
+	Title        string              `bson:"title" json:"title"`
+	Description  string              `bson:"description" json:"description"`
+	Duration     int                 `bson:"duration" json:"duration"` // in minutes
+	Status       string              `bson:"status" json:"status"`     // planned, in-progress, paused, completed
+	Priority     string              `bson:"priority" json:"priority"` // high, medium, low
+	StartTime    *time.Time          `bson:"startTime" json:"startTime,omitempty"`
+	EndTime      *time.Time          `bson:"endTime" json:"endTime,omitempty"`
+	StartedAt    *time.Time          `bson:"startedAt,omitempty" json:"startedAt,omitempty"` // when work on it actually began
+	CompletedAt  *time.Time          `bson:"completedAt,omitempty" json:"completedAt,omitempty"`
+	TemplateID   *primitive.ObjectID `bson:"templateId,omitempty" json:"templateId,omitempty"`
+	PlannedFor   *time.Time          `bson:"plannedFor,omitempty" json:"plannedFor,omitempty"`     // the day the scheduler must fit it into
+	AssignmentID *primitive.ObjectID `bson:"assignmentId,omitempty" json:"assignmentId,omitempty"` // set when a teacher assigned it
+	ClassID      *primitive.ObjectID `bson:"classId,omitempty" json:"classId,omitempty"`
+	DueAt        *time.Time          `bson:"dueAt,omitempty" json:"dueAt,omitempty"`
+	Segments     []TimeSegment       `bson:"segments,omitempty" json:"segments,omitempty"`
+	Pomodoro     *PomodoroSettings   `bson:"pomodoro,omitempty" json:"pomodoro,omitempty"`
+	CreatedAt    time.Time           `bson:"createdAt" json:"createdAt"`
+	UpdatedAt    time.Time           `bson:"updatedAt" json:"updatedAt"`
+}
+
+// TimeSegment is a stretch of time spent on an activity, working or, in
+// Pomodoro mode, on a break. The segment being worked has no End.
+type TimeSegment struct {
+	Kind  string     `bson:"kind" json:"kind"`
+	Start time.Time  `bson:"start" json:"start"`
+	End   *time.Time `bson:"end,omitempty" json:"end,omitempty"`
+	Cycle int        `bson:"cycle,omitempty" json:"cycle,omitempty"` // the Pomodoro cycle, from 1
+}
+
+// PomodoroSettings are the work/break cycle of an activity in Pomodoro
+// mode, in minutes
+type PomodoroSettings struct {
+	Work           int `bson:"work" json:"work"`
+	ShortBreak     int `bson:"shortBreak" json:"shortBreak"`
+	LongBreak      int `bson:"longBreak" json:"longBreak"`
+	LongBreakEvery int `bson:"longBreakEvery" json:"longBreakEvery"` // cycles
+}
+
+// Length is how long the segment has lasted by now
+func (s TimeSegment) Length(now time.Time) time.Duration {
+	if s.End != nil {
+		return s.End.Sub(s.Start)
+	}
+	return now.Sub(s.Start)
+}
+
+// WorkSegments are the segments spent working on the activity. One
+// completed before segments were recorded counts as a single segment
+// from its start to its end.
+func (a Activity) WorkSegments() []TimeSegment {
+	if len(a.Segments) == 0 && a.Status == StatusCompleted && a.CompletedAt == nil && a.StartTime != nil && a.EndTime != nil {
+		return []TimeSegment{{Kind: SegmentWork, Start: *a.StartTime, End: a.EndTime}}
+	}
+	var work []TimeSegment
+	for _, s := range a.Segments {
+		if s.Kind == SegmentWork {
+			work = append(work, s)
+		}
+	}
+	return work
+}
+
+// Completion is when the activity was completed, or nil. One completed
+// before segments were recorded kept that in EndTime.
+func (a Activity) Completion() *time.Time {
+	if a.CompletedAt != nil {
+		return a.CompletedAt
+	}
+	if len(a.Segments) == 0 && a.Status == StatusCompleted {
+		return a.EndTime
+	}
+	return nil
+}
+
+// Worked is the time spent working on the activity by now
+func (a Activity) Worked(now time.Time) time.Duration {
+	var total time.Duration
+	for _, s := range a.WorkSegments() {
+		total += s.Length(now)
+	}
+	return total
 }
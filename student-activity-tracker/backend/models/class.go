@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Organisation is a school or other group that classes belong to. Users
+// join one with its JoinCode, as students; its admins, starting with
+// whoever created it, decide who teaches.
+type Organisation struct {
+	ID        primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Name      string               `bson:"name" json:"name"`
+	JoinCode  string               `bson:"joinCode" json:"joinCode,omitempty"`
+	AdminIDs  []primitive.ObjectID `bson:"adminIds" json:"adminIds"`
+	CreatedBy primitive.ObjectID   `bson:"createdBy" json:"createdBy"`
+	CreatedAt time.Time            `bson:"createdAt" json:"createdAt"`
+}
+
+// Class is a group of students taught by one or more teachers. Students
+// join one with its JoinCode.
+type Class struct {
+	ID             primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	OrganisationID primitive.ObjectID   `bson:"organisationId" json:"organisationId"`
+	Name           string               `bson:"name" json:"name"`
+	JoinCode       string               `bson:"joinCode" json:"joinCode,omitempty"`
+	TeacherIDs     []primitive.ObjectID `bson:"teacherIds" json:"teacherIds"`
+	StudentIDs     []primitive.ObjectID `bson:"studentIds" json:"studentIds,omitempty"`
+	CreatedAt      time.Time            `bson:"createdAt" json:"createdAt"`
+}
+
+// Assignment is an activity a teacher sets a class. Every student in the
+// class gets their own copy of it to work on.
+type Assignment struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ClassID     primitive.ObjectID `bson:"classId" json:"classId"`
+	TeacherID   primitive.ObjectID `bson:"teacherId" json:"teacherId"`
+	Title       string             `bson:"title" json:"title"`
+	Description string             `bson:"description" json:"description"`
+	Duration    int                `bson:"duration" json:"duration"` // in minutes
+	Priority    string             `bson:"priority" json:"priority"`
+	DueAt       *time.Time         `bson:"dueAt,omitempty" json:"dueAt,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ActivityTemplate is an activity that recurs on the same weekdays every
+// week. With a StartAt it is planned at that time; without, the scheduler
+// finds it a slot on the day.
+type ActivityTemplate struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OwnerID     primitive.ObjectID `bson:"ownerId" json:"ownerId"`
+	Title       string             `bson:"title" json:"title"`
+	Description string             `bson:"description" json:"description"`
+	Duration    int                `bson:"duration" json:"duration"` // in minutes
+	Priority    string             `bson:"priority" json:"priority"`
+	Weekdays    []int              `bson:"weekdays" json:"weekdays"`                   // 0 is Sunday
+	StartAt     string             `bson:"startAt,omitempty" json:"startAt,omitempty"` // HH:MM
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// AvailabilitySlot is a window on a weekday when the student is free to
+// do planned activities
+type AvailabilitySlot struct {
+	Weekday int    `bson:"weekday" json:"weekday"` // 0 is Sunday
+	Start   string `bson:"start" json:"start"`     // HH:MM
+	End     string `bson:"end" json:"end"`         // HH:MM
+}
+
+// Availability is a student's weekly calendar of free time
+type Availability struct {
+	OwnerID   primitive.ObjectID `bson:"_id" json:"ownerId"`
+	Timezone  string             `bson:"timezone" json:"timezone"`
+	Slots     []AvailabilitySlot `bson:"slots" json:"slots"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// User roles
+const (
+	RoleStudent = "student"
+	RoleTeacher = "teacher"
+)
+
+// User represents a student or teacher account
+type User struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Name           string              `bson:"name" json:"name"`
+	Email          string              `bson:"email" json:"email"`
+	PasswordHash   string              `bson:"passwordHash" json:"-"`
+	Role           string              `bson:"role" json:"role"`
+	OrganisationID *primitive.ObjectID `bson:"organisationId,omitempty" json:"organisationId,omitempty"`
+	CreatedAt      time.Time           `bson:"createdAt" json:"createdAt"`
+}
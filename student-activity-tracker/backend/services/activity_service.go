@@ -2,15 +2,25 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/pac-cee/student-activity-tracker/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// ActivityService handles business logic for activities
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrForbidden       = errors.New("forbidden")
+	ErrInvalidActivity = errors.New("invalid activity")
+)
+
+// ActivityService handles business logic for activities. Every activity
+// belongs to a student, and each method only sees the owner's.
 type ActivityService struct {
 	collection *mongo.Collection
 }
@@ -22,10 +32,69 @@ func NewActivityService(collection *mongo.Collection) *ActivityService {
 	}
 }
 
-// CreateActivity creates a new activity
-func (s *ActivityService) CreateActivity(ctx context.Context, activity *models.Activity) error {
+// EnsureIndexes creates the indexes owner-scoped queries rely on
+func (s *ActivityService) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "startTime", Value: 1}}},
+		{Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "templateId", Value: 1}, {Key: "plannedFor", Value: 1}}},
+		{Keys: bson.D{{Key: "assignmentId", Value: 1}, {Key: "ownerId", Value: 1}}},
+	})
+	return err
+}
+
+// validPriority reports whether p is a known priority
+func validPriority(p string) bool {
+	return p == models.PriorityHigh || p == models.PriorityMedium || p == models.PriorityLow
+}
+
+// normalizeActivity checks an activity's fields, defaulting its priority
+// and, for a planned start, its end
+func normalizeActivity(activity *models.Activity) error {
+	if activity.Duration < 0 {
+		return fmt.Errorf("%w: duration can't be negative", ErrInvalidActivity)
+	}
+	if activity.Priority == "" {
+		activity.Priority = models.PriorityMedium
+	}
+	if !validPriority(activity.Priority) {
+		return fmt.Errorf("%w: priority must be high, medium or low", ErrInvalidActivity)
+	}
+	if activity.StartTime != nil && activity.EndTime == nil {
+		end := activity.StartTime.Add(time.Duration(activity.Duration) * time.Minute)
+		activity.EndTime = &end
+	}
+	if activity.StartTime != nil && !activity.EndTime.After(*activity.StartTime) {
+		return fmt.Errorf("%w: endTime must be after startTime", ErrInvalidActivity)
+	}
+	return nil
+}
+
+// CreateActivity creates a new activity for the owner
+func (s *ActivityService) CreateActivity(ctx context.Context, ownerID primitive.ObjectID, activity *models.Activity) error {
+	// Only a teacher's assignment can make an assigned activity
+	activity.AssignmentID, activity.ClassID = nil, nil
+	return s.create(ctx, ownerID, activity)
+}
+
+// AssignActivity creates an activity a teacher assigned to the owner
+func (s *ActivityService) AssignActivity(ctx context.Context, ownerID primitive.ObjectID, activity *models.Activity) error {
+	return s.create(ctx, ownerID, activity)
+}
+
+func (s *ActivityService) create(ctx context.Context, ownerID primitive.ObjectID, activity *models.Activity) error {
+	if err := normalizeActivity(activity); err != nil {
+		return err
+	}
+	if activity.Pomodoro != nil {
+		if err := validatePomodoro(activity.Pomodoro); err != nil {
+			return err
+		}
+	}
 	activity.ID = primitive.NewObjectID()
-	activity.Status = "planned"
+	activity.OwnerID = ownerID
+	activity.Status = models.StatusPlanned
+	activity.Segments = nil
+	activity.StartedAt, activity.CompletedAt = nil, nil
 	activity.CreatedAt = time.Now()
 	activity.UpdatedAt = time.Now()
 
@@ -33,80 +102,244 @@ func (s *ActivityService) CreateActivity(ctx context.Context, activity *models.A
 	return err
 }
 
-// GetActivities retrieves all activities
-func (s *ActivityService) GetActivities(ctx context.Context) ([]models.Activity, error) {
-	cursor, err := s.collection.Find(ctx, bson.M{})
+func (s *ActivityService) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.Activity, error) {
+	cursor, err := s.collection.Find(ctx, filter, opts...)
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var activities []models.Activity
+	activities := []models.Activity{}
 	if err := cursor.All(ctx, &activities); err != nil {
 		return nil, err
 	}
-
 	return activities, nil
 }
 
+// GetActivities retrieves all the owner's activities
+func (s *ActivityService) GetActivities(ctx context.Context, ownerID primitive.ObjectID) ([]models.Activity, error) {
+	return s.find(ctx, bson.M{"ownerId": ownerID})
+}
+
 // GetActivity retrieves a single activity by ID
-func (s *ActivityService) GetActivity(ctx context.Context, id primitive.ObjectID) (*models.Activity, error) {
+func (s *ActivityService) GetActivity(ctx context.Context, ownerID, id primitive.ObjectID) (*models.Activity, error) {
 	var activity models.Activity
-	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&activity)
+	err := s.collection.FindOne(ctx, bson.M{"_id": id, "ownerId": ownerID}).Decode(&activity)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &activity, nil
 }
 
-// UpdateActivity updates an existing activity
-func (s *ActivityService) UpdateActivity(ctx context.Context, id primitive.ObjectID, activity *models.Activity) error {
-	activity.UpdatedAt = time.Now()
-	update := bson.M{
-		"$set": bson.M{
-			"title":       activity.Title,
-			"description": activity.Description,
-			"duration":    activity.Duration,
-			"updatedAt":   activity.UpdatedAt,
-		},
+// update applies update to one of the owner's activities
+func (s *ActivityService) update(ctx context.Context, ownerID, id primitive.ObjectID, update bson.M) error {
+	result, err := s.collection.UpdateOne(ctx, bson.M{"_id": id, "ownerId": ownerID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
 	}
+	return nil
+}
 
-	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
-	return err
+// UpdateActivity updates an existing activity. Its planned window only
+// changes when the update has a startTime.
+func (s *ActivityService) UpdateActivity(ctx context.Context, ownerID, id primitive.ObjectID, activity *models.Activity) error {
+	if err := normalizeActivity(activity); err != nil {
+		return err
+	}
+	activity.UpdatedAt = time.Now()
+	set := bson.M{
+		"title":       activity.Title,
+		"description": activity.Description,
+		"duration":    activity.Duration,
+		"priority":    activity.Priority,
+		"updatedAt":   activity.UpdatedAt,
+	}
+	if activity.StartTime != nil {
+		set["startTime"] = activity.StartTime
+		set["endTime"] = activity.EndTime
+	}
+	return s.update(ctx, ownerID, id, bson.M{"$set": set})
 }
 
-// DeleteActivity deletes an activity
-func (s *ActivityService) DeleteActivity(ctx context.Context, id primitive.ObjectID) error {
-	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
-	return err
+// DeleteActivity deletes an activity. Assigned activities can't be
+// deleted; the teacher is tracking them.
+func (s *ActivityService) DeleteActivity(ctx context.Context, ownerID, id primitive.ObjectID) error {
+	result, err := s.collection.DeleteOne(ctx, bson.M{"_id": id, "ownerId": ownerID, "assignmentId": nil})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		if _, err := s.GetActivity(ctx, ownerID, id); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: assigned activities can't be deleted", ErrForbidden)
+	}
+	return nil
 }
 
-// StartActivity starts an activity
-func (s *ActivityService) StartActivity(ctx context.Context, id primitive.ObjectID) error {
+// track applies a change to an activity's time tracking, saving it only
+// if nothing else has changed the activity's status or segments since it
+// was read
+func (s *ActivityService) track(ctx context.Context, ownerID, id primitive.ObjectID, change func(*models.Activity, time.Time) error) error {
+	activity, err := s.GetActivity(ctx, ownerID, id)
+	if err != nil {
+		return err
+	}
+	filter := bson.M{"_id": id, "ownerId": ownerID, "status": activity.Status}
+	if n := len(activity.Segments); n > 0 {
+		filter["segments"] = bson.M{"$size": n}
+	} else {
+		filter["$or"] = bson.A{bson.M{"segments": nil}, bson.M{"segments": bson.M{"$size": 0}}}
+	}
+
 	now := time.Now()
-	update := bson.M{
+	if err := change(activity, now); err != nil {
+		return err
+	}
+	result, err := s.collection.UpdateOne(ctx, filter, bson.M{
 		"$set": bson.M{
-			"status":    "in-progress",
-			"startTime": now,
-			"updatedAt": now,
+			"status":      activity.Status,
+			"startedAt":   activity.StartedAt,
+			"completedAt": activity.CompletedAt,
+			"segments":    activity.Segments,
+			"pomodoro":    activity.Pomodoro,
+			"updatedAt":   now,
 		},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: activity changed while it was being updated", ErrInvalidTransition)
 	}
+	return nil
+}
 
-	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
-	return err
+// StartActivity starts an activity, or resumes it if it is paused
+func (s *ActivityService) StartActivity(ctx context.Context, ownerID, id primitive.ObjectID) error {
+	return s.track(ctx, ownerID, id, startWork)
+}
+
+// PauseActivity pauses an activity in progress
+func (s *ActivityService) PauseActivity(ctx context.Context, ownerID, id primitive.ObjectID) error {
+	return s.track(ctx, ownerID, id, pauseWork)
+}
+
+// ResumeActivity resumes a paused activity
+func (s *ActivityService) ResumeActivity(ctx context.Context, ownerID, id primitive.ObjectID) error {
+	return s.track(ctx, ownerID, id, func(a *models.Activity, now time.Time) error {
+		if a.Status != models.StatusPaused {
+			return fmt.Errorf("%w: only a paused activity can be resumed", ErrInvalidTransition)
+		}
+		return startWork(a, now)
+	})
 }
 
 // CompleteActivity completes an activity
-func (s *ActivityService) CompleteActivity(ctx context.Context, id primitive.ObjectID) error {
-	now := time.Now()
-	update := bson.M{
+func (s *ActivityService) CompleteActivity(ctx context.Context, ownerID, id primitive.ObjectID) error {
+	return s.track(ctx, ownerID, id, completeWork)
+}
+
+// SetPomodoro turns Pomodoro mode on for an activity with settings, or
+// off with nil
+func (s *ActivityService) SetPomodoro(ctx context.Context, ownerID, id primitive.ObjectID, settings *models.PomodoroSettings) error {
+	return s.track(ctx, ownerID, id, func(a *models.Activity, now time.Time) error {
+		return setPomodoro(a, settings, now)
+	})
+}
+
+// NextPhase moves an activity in Pomodoro mode on to its next work or
+// break phase
+func (s *ActivityService) NextPhase(ctx context.Context, ownerID, id primitive.ObjectID) error {
+	return s.track(ctx, ownerID, id, nextPhase)
+}
+
+// ActivitiesInRange retrieves the owner's activities that were planned
+// for, or worked on, between from and to
+func (s *ActivityService) ActivitiesInRange(ctx context.Context, ownerID primitive.ObjectID, from, to time.Time) ([]models.Activity, error) {
+	during := bson.M{"$gte": from, "$lt": to}
+	return s.find(ctx, bson.M{
+		"ownerId": ownerID,
+		"$or": bson.A{
+			bson.M{"plannedFor": during},
+			bson.M{"startTime": during},
+			bson.M{"createdAt": during},
+			bson.M{"segments": bson.M{"$elemMatch": bson.M{
+				"start": bson.M{"$lt": to},
+				"$or":   bson.A{bson.M{"end": nil}, bson.M{"end": bson.M{"$gte": from}}},
+			}}},
+		},
+	})
+}
+
+// ScheduleActivity sets the window an activity is planned for
+func (s *ActivityService) ScheduleActivity(ctx context.Context, ownerID, id primitive.ObjectID, start, end time.Time) error {
+	return s.update(ctx, ownerID, id, bson.M{
 		"$set": bson.M{
-			"status":    "completed",
-			"endTime":   now,
-			"updatedAt": now,
+			"startTime": start,
+			"endTime":   end,
+			"updatedAt": time.Now(),
 		},
-	}
+	})
+}
 
-	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
-	return err
+// ActivitiesBetween retrieves the owner's activities whose windows overlap
+// from to to, in start order
+func (s *ActivityService) ActivitiesBetween(ctx context.Context, ownerID primitive.ObjectID, from, to time.Time) ([]models.Activity, error) {
+	return s.find(ctx, bson.M{
+		"ownerId":   ownerID,
+		"startTime": bson.M{"$lt": to},
+		"endTime":   bson.M{"$gt": from},
+	}, options.Find().SetSort(bson.D{{Key: "startTime", Value: 1}}))
+}
+
+// Conflicts retrieves the owner's activities, other than exclude, whose
+// windows overlap start to end
+func (s *ActivityService) Conflicts(ctx context.Context, ownerID, exclude primitive.ObjectID, start, end time.Time) ([]models.Activity, error) {
+	return s.find(ctx, bson.M{
+		"ownerId":   ownerID,
+		"_id":       bson.M{"$ne": exclude},
+		"startTime": bson.M{"$lt": end},
+		"endTime":   bson.M{"$gt": start},
+	})
+}
+
+// Unscheduled retrieves the owner's planned activities that have no
+// window yet and are either for any day or for a day from from to to
+func (s *ActivityService) Unscheduled(ctx context.Context, ownerID primitive.ObjectID, from, to time.Time) ([]models.Activity, error) {
+	return s.find(ctx, bson.M{
+		"ownerId":   ownerID,
+		"status":    models.StatusPlanned,
+		"startTime": nil,
+		"$or": bson.A{
+			bson.M{"plannedFor": nil},
+			bson.M{"plannedFor": bson.M{"$gte": from, "$lt": to}},
+		},
+	})
+}
+
+// HasTemplateInstance reports whether the template already has an
+// activity planned for day
+func (s *ActivityService) HasTemplateInstance(ctx context.Context, ownerID, templateID primitive.ObjectID, day time.Time) (bool, error) {
+	n, err := s.collection.CountDocuments(ctx, bson.M{"ownerId": ownerID, "templateId": templateID, "plannedFor": day})
+	return n > 0, err
+}
+
+// AssignedActivities retrieves every student's copy of the assignments,
+// for their teacher
+func (s *ActivityService) AssignedActivities(ctx context.Context, assignmentIDs []primitive.ObjectID) ([]models.Activity, error) {
+	return s.find(ctx, bson.M{"assignmentId": bson.M{"$in": assignmentIDs}})
+}
+
+// HasAssignment reports whether the owner already has a copy of the
+// assignment
+func (s *ActivityService) HasAssignment(ctx context.Context, ownerID, assignmentID primitive.ObjectID) (bool, error) {
+	n, err := s.collection.CountDocuments(ctx, bson.M{"ownerId": ownerID, "assignmentId": assignmentID})
+	return n > 0, err
 }
@@ -3,7 +3,6 @@ package services
 import (
 	"context"
 	"testing"
-	"time"
 
 	"github.com/pac-cee/student-activity-tracker/models"
 	"go.mongodb.org/mongo-driver/bson"
@@ -32,13 +31,14 @@ func TestActivityService_CreateActivity(t *testing.T) {
 	defer cleanup()
 
 	service := NewActivityService(collection)
+	owner := primitive.NewObjectID()
 	activity := &models.Activity{
 		Title:       "Test Activity",
 		Description: "Test Description",
 		Duration:    30,
 	}
 
-	err := service.CreateActivity(context.Background(), activity)
+	err := service.CreateActivity(context.Background(), owner, activity)
 	if err != nil {
 		t.Errorf("Failed to create activity: %v", err)
 	}
@@ -56,7 +56,8 @@ func TestActivityService_GetActivities(t *testing.T) {
 	defer cleanup()
 
 	service := NewActivityService(collection)
-	
+	owner := primitive.NewObjectID()
+
 	// Create test activities
 	activities := []models.Activity{
 		{
@@ -72,14 +73,14 @@ func TestActivityService_GetActivities(t *testing.T) {
 	}
 
 	for _, a := range activities {
-		err := service.CreateActivity(context.Background(), &a)
+		err := service.CreateActivity(context.Background(), owner, &a)
 		if err != nil {
 			t.Fatalf("Failed to create test activity: %v", err)
 		}
 	}
 
 	// Test getting activities
-	result, err := service.GetActivities(context.Background())
+	result, err := service.GetActivities(context.Background(), owner)
 	if err != nil {
 		t.Errorf("Failed to get activities: %v", err)
 	}
@@ -94,18 +95,19 @@ func TestActivityService_StartActivity(t *testing.T) {
 	defer cleanup()
 
 	service := NewActivityService(collection)
+	owner := primitive.NewObjectID()
 	activity := &models.Activity{
 		Title:       "Test Activity",
 		Description: "Test Description",
 		Duration:    30,
 	}
 
-	err := service.CreateActivity(context.Background(), activity)
+	err := service.CreateActivity(context.Background(), owner, activity)
 	if err != nil {
 		t.Fatalf("Failed to create activity: %v", err)
 	}
 
-	err = service.StartActivity(context.Background(), activity.ID)
+	err = service.StartActivity(context.Background(), owner, activity.ID)
 	if err != nil {
 		t.Errorf("Failed to start activity: %v", err)
 	}
@@ -120,7 +122,7 @@ func TestActivityService_StartActivity(t *testing.T) {
 	if updatedActivity.Status != "in-progress" {
 		t.Errorf("Expected status 'in-progress', got %s", updatedActivity.Status)
 	}
-	if updatedActivity.StartTime == nil {
+	if updatedActivity.StartedAt == nil {
 		t.Error("Start time should not be nil")
 	}
 }
@@ -130,6 +132,7 @@ func TestActivityService_CompleteActivity(t *testing.T) {
 	defer cleanup()
 
 	service := NewActivityService(collection)
+	owner := primitive.NewObjectID()
 	activity := &models.Activity{
 		Title:       "Test Activity",
 		Description: "Test Description",
@@ -137,18 +140,18 @@ func TestActivityService_CompleteActivity(t *testing.T) {
 	}
 
 	// Create and start activity
-	err := service.CreateActivity(context.Background(), activity)
+	err := service.CreateActivity(context.Background(), owner, activity)
 	if err != nil {
 		t.Fatalf("Failed to create activity: %v", err)
 	}
 
-	err = service.StartActivity(context.Background(), activity.ID)
+	err = service.StartActivity(context.Background(), owner, activity.ID)
 	if err != nil {
 		t.Fatalf("Failed to start activity: %v", err)
 	}
 
 	// Complete activity
-	err = service.CompleteActivity(context.Background(), activity.ID)
+	err = service.CompleteActivity(context.Background(), owner, activity.ID)
 	if err != nil {
 		t.Errorf("Failed to complete activity: %v", err)
 	}
@@ -163,7 +166,42 @@ func TestActivityService_CompleteActivity(t *testing.T) {
 	if updatedActivity.Status != "completed" {
 		t.Errorf("Expected status 'completed', got %s", updatedActivity.Status)
 	}
-	if updatedActivity.EndTime == nil {
+	if updatedActivity.CompletedAt == nil {
 		t.Error("End time should not be nil")
 	}
 }
+
+func TestActivityService_OwnerScoping(t *testing.T) {
+	collection, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	service := NewActivityService(collection)
+	owner, other := primitive.NewObjectID(), primitive.NewObjectID()
+	activity := &models.Activity{
+		Title:    "Test Activity",
+		Duration: 30,
+	}
+
+	err := service.CreateActivity(context.Background(), owner, activity)
+	if err != nil {
+		t.Fatalf("Failed to create activity: %v", err)
+	}
+
+	result, err := service.GetActivities(context.Background(), other)
+	if err != nil {
+		t.Fatalf("Failed to get activities: %v", err)
+	}
+	if len(result) != 0 {
+		t.Errorf("Expected no activities for another user, got %d", len(result))
+	}
+
+	if _, err := service.GetActivity(context.Background(), other, activity.ID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound getting another user's activity, got %v", err)
+	}
+	if err := service.StartActivity(context.Background(), other, activity.ID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound starting another user's activity, got %v", err)
+	}
+	if err := service.DeleteActivity(context.Background(), other, activity.ID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound deleting another user's activity, got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/pac-cee/student-activity-tracker/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Analytics periods
+const (
+	PeriodDay  = "day"
+	PeriodWeek = "week"
+)
+
+// PeriodStats sums up the activities planned for a day or week and the
+// time spent working in it. Minutes are rounded to a tenth.
+type PeriodStats struct {
+	Start          time.Time `json:"start"`
+	Planned        int       `json:"planned"`        // activities planned for the period
+	Completed      int       `json:"completed"`      // of those, how many are completed
+	CompletionRate float64   `json:"completionRate"` // completed / planned
+	PlannedMinutes int       `json:"plannedMinutes"` // their planned durations
+	ActualMinutes  float64   `json:"actualMinutes"`  // the time worked on them, whenever it was
+	FocusMinutes   float64   `json:"focusMinutes"`   // time worked during the period, on anything
+	BreakMinutes   float64   `json:"breakMinutes"`   // Pomodoro breaks taken during the period
+	Pomodoros      int       `json:"pomodoros"`      // Pomodoro work phases finished during the period
+}
+
+// ActivityTime compares the time planned for a completed activity with the
+// time spent on it
+type ActivityTime struct {
+	ID             primitive.ObjectID `json:"id"`
+	Title          string             `json:"title"`
+	PlannedMinutes int                `json:"plannedMinutes"`
+	ActualMinutes  float64            `json:"actualMinutes"`
+	OverMinutes    float64            `json:"overMinutes"` // negative when it took less than planned
+}
+
+// Analytics is a student's productivity from From up to To, by Period
+type Analytics struct {
+	From       time.Time      `json:"from"`
+	To         time.Time      `json:"to"`
+	Period     string         `json:"period"`
+	Periods    []PeriodStats  `json:"periods"`
+	Totals     PeriodStats    `json:"totals"`
+	Activities []ActivityTime `json:"activities"`
+}
+
+func minutes(d time.Duration) float64 {
+	return math.Round(d.Minutes()*10) / 10
+}
+
+// plannedDay is when an activity counts as planned for: the day it was
+// planned for, else its start, else when it was created
+func plannedDay(a models.Activity) time.Time {
+	switch {
+	case a.PlannedFor != nil:
+		return *a.PlannedFor
+	case a.StartTime != nil:
+		return *a.StartTime
+	default:
+		return a.CreatedAt
+	}
+}
+
+// overlap is how much of the segment fell between from and to by now
+func overlap(s models.TimeSegment, from, to, now time.Time) time.Duration {
+	start, end := s.Start, now
+	if s.End != nil {
+		end = *s.End
+	}
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// Analyze works out analytics for the activities from from up to to, by
+// day or by week starting from, as of now
+func Analyze(activities []models.Activity, from, to time.Time, period string, now time.Time) Analytics {
+	result := Analytics{From: from, To: to, Period: period, Periods: []PeriodStats{}, Activities: []ActivityTime{}}
+	step := func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	if period == PeriodWeek {
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	}
+
+	var focus, breaks []time.Duration
+	for start := from; start.Before(to); start = step(start) {
+		result.Periods = append(result.Periods, PeriodStats{Start: start})
+		focus, breaks = append(focus, 0), append(breaks, 0)
+	}
+	// periodOf finds the period t falls in, or -1
+	periodOf := func(t time.Time) int {
+		for i := len(result.Periods) - 1; i >= 0; i-- {
+			if !t.Before(result.Periods[i].Start) {
+				if t.Before(to) {
+					return i
+				}
+				break
+			}
+		}
+		return -1
+	}
+
+	var totalActual, totalFocus, totalBreaks time.Duration
+	for _, a := range activities {
+		worked := a.Worked(now)
+		if i := periodOf(plannedDay(a)); i >= 0 {
+			p := &result.Periods[i]
+			p.Planned++
+			p.PlannedMinutes += a.Duration
+			p.ActualMinutes += worked.Minutes()
+			totalActual += worked
+			if a.Status == models.StatusCompleted {
+				p.Completed++
+			}
+		}
+		if completed := a.Completion(); completed != nil {
+			if i := periodOf(*completed); i >= 0 {
+				result.Activities = append(result.Activities, ActivityTime{
+					ID:             a.ID,
+					Title:          a.Title,
+					PlannedMinutes: a.Duration,
+					ActualMinutes:  minutes(worked),
+					OverMinutes:    minutes(worked - time.Duration(a.Duration)*time.Minute),
+				})
+			}
+		}
+
+		segments := a.WorkSegments()
+		for _, s := range a.Segments {
+			if s.Kind == models.SegmentBreak {
+				segments = append(segments, s)
+			}
+		}
+		finished := make(map[int]bool)
+		for _, s := range segments {
+			for i, p := range result.Periods {
+				end := to
+				if i+1 < len(result.Periods) {
+					end = result.Periods[i+1].Start
+				}
+				d := overlap(s, p.Start, end, now)
+				if s.Kind == models.SegmentWork {
+					focus[i] += d
+					totalFocus += d
+				} else {
+					breaks[i] += d
+					totalBreaks += d
+				}
+			}
+			// A Pomodoro's work phase is finished once its break begins
+			if s.Kind == models.SegmentBreak && !finished[s.Cycle] {
+				finished[s.Cycle] = true
+				if i := periodOf(s.Start); i >= 0 {
+					result.Periods[i].Pomodoros++
+					result.Totals.Pomodoros++
+				}
+			}
+		}
+	}
+
+	for i := range result.Periods {
+		p := &result.Periods[i]
+		p.ActualMinutes = math.Round(p.ActualMinutes*10) / 10
+		p.FocusMinutes = minutes(focus[i])
+		p.BreakMinutes = minutes(breaks[i])
+		if p.Planned > 0 {
+			p.CompletionRate = float64(p.Completed) / float64(p.Planned)
+		}
+		result.Totals.Planned += p.Planned
+		result.Totals.Completed += p.Completed
+		result.Totals.PlannedMinutes += p.PlannedMinutes
+	}
+	result.Totals.Start = from
+	result.Totals.ActualMinutes = minutes(totalActual)
+	result.Totals.FocusMinutes = minutes(totalFocus)
+	result.Totals.BreakMinutes = minutes(totalBreaks)
+	if result.Totals.Planned > 0 {
+		result.Totals.CompletionRate = float64(result.Totals.Completed) / float64(result.Totals.Planned)
+	}
+	return result
+}
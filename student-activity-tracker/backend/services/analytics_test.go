@@ -0,0 +1,58 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pac-cee/student-activity-tracker/models"
+)
+
+func TestAnalyze(t *testing.T) {
+	monday := clock(t, time.UTC, "2025-03-03 00:00")
+	tuesday := monday.AddDate(0, 0, 1)
+	segment := func(kind, from, to string, cycle int) models.TimeSegment {
+		end := clock(t, time.UTC, to)
+		return models.TimeSegment{Kind: kind, Start: clock(t, time.UTC, from), End: &end, Cycle: cycle}
+	}
+	done := clock(t, time.UTC, "2025-03-03 23:40")
+
+	activities := []models.Activity{
+		{
+			// Planned for Monday, worked across midnight in Pomodoro mode
+			Title: "Essay", Duration: 60, Status: models.StatusCompleted, PlannedFor: &monday, CompletedAt: &done,
+			Segments: []models.TimeSegment{
+				segment(models.SegmentWork, "2025-03-03 22:00", "2025-03-03 22:25", 1),
+				segment(models.SegmentBreak, "2025-03-03 22:25", "2025-03-03 22:30", 1),
+				segment(models.SegmentWork, "2025-03-03 23:50", "2025-03-04 00:30", 2),
+			},
+		},
+		{Title: "Reading", Duration: 30, Status: models.StatusPlanned, PlannedFor: &tuesday},
+		{Title: "Next week", Duration: 30, Status: models.StatusPlanned, CreatedAt: monday.AddDate(0, 0, 8)},
+	}
+
+	got := Analyze(activities, monday, monday.AddDate(0, 0, 7), PeriodDay, monday.AddDate(0, 0, 7))
+	if len(got.Periods) != 7 {
+		t.Fatalf("periods = %d, want 7", len(got.Periods))
+	}
+	mon, tue := got.Periods[0], got.Periods[1]
+	if mon.Planned != 1 || mon.Completed != 1 || mon.CompletionRate != 1 || mon.PlannedMinutes != 60 || mon.ActualMinutes != 65 {
+		t.Errorf("Monday = %+v", mon)
+	}
+	if mon.FocusMinutes != 35 || mon.BreakMinutes != 5 || mon.Pomodoros != 1 {
+		t.Errorf("Monday focus = %+v", mon)
+	}
+	if tue.Planned != 1 || tue.Completed != 0 || tue.FocusMinutes != 30 {
+		t.Errorf("Tuesday = %+v", tue)
+	}
+	if got.Totals.Planned != 2 || got.Totals.CompletionRate != 0.5 || got.Totals.FocusMinutes != 65 || got.Totals.Pomodoros != 1 {
+		t.Errorf("totals = %+v", got.Totals)
+	}
+	if len(got.Activities) != 1 || got.Activities[0].OverMinutes != 5 {
+		t.Errorf("planned vs actual = %+v", got.Activities)
+	}
+
+	weekly := Analyze(activities, monday, monday.AddDate(0, 0, 14), PeriodWeek, monday.AddDate(0, 0, 14))
+	if len(weekly.Periods) != 2 || weekly.Periods[0].Planned != 2 || weekly.Periods[1].Planned != 1 || weekly.Periods[0].FocusMinutes != 65 {
+		t.Errorf("weekly = %+v", weekly.Periods)
+	}
+}
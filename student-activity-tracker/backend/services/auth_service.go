@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pac-cee/student-activity-tracker/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// tokenTTL is how long a login lasts
+const tokenTTL = 7 * 24 * time.Hour
+
+var (
+	ErrEmailTaken         = errors.New("email is already registered")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidToken       = errors.New("invalid token")
+)
+
+// Claims identify a user and their role
+type Claims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// AuthService handles student and teacher accounts and the tokens that
+// identify them
+type AuthService struct {
+	collection *mongo.Collection
+	secret     []byte
+}
+
+// NewAuthService creates a new AuthService signing tokens with secret
+func NewAuthService(collection *mongo.Collection, secret string) *AuthService {
+	return &AuthService{
+		collection: collection,
+		secret:     []byte(secret),
+	}
+}
+
+// EnsureIndexes makes emails unique
+func (s *AuthService) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// Register creates a new student account. Only an organisation's admins
+// make teachers.
+func (s *AuthService) Register(ctx context.Context, name, email, password string) (*models.User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		ID:           primitive.NewObjectID(),
+		Name:         name,
+		Email:        strings.ToLower(strings.TrimSpace(email)),
+		PasswordHash: string(hash),
+		Role:         models.RoleStudent,
+		CreatedAt:    time.Now(),
+	}
+	if _, err := s.collection.InsertOne(ctx, user); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrEmailTaken
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// Login checks a student's password and returns a token for them
+func (s *AuthService) Login(ctx context.Context, email, password string) (string, *models.User, error) {
+	var user models.User
+	err := s.collection.FindOne(ctx, bson.M{"email": strings.ToLower(strings.TrimSpace(email))}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return "", nil, ErrInvalidCredentials
+	}
+
+	token, err := s.IssueToken(&user)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &user, nil
+}
+
+// GetUser retrieves a user by ID
+func (s *AuthService) GetUser(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return s.findUser(ctx, bson.M{"_id": id})
+}
+
+func (s *AuthService) findUser(ctx context.Context, filter bson.M) (*models.User, error) {
+	var user models.User
+	err := s.collection.FindOne(ctx, filter).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == "" {
+		user.Role = models.RoleStudent
+	}
+	return &user, nil
+}
+
+// GetUsers retrieves the users with the IDs, by ID
+func (s *AuthService) GetUsers(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]models.User, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]models.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+	return byID, nil
+}
+
+// SetOrganisation makes the user a member of the organisation, unless
+// they already belong to one
+func (s *AuthService) SetOrganisation(ctx context.Context, userID, organisationID primitive.ObjectID) error {
+	result, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "organisationId": nil},
+		bson.M{"$set": bson.M{"organisationId": organisationID}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrAlreadyInOrganisation
+	}
+	return nil
+}
+
+// SetRole changes the role of a member of the organisation; other users
+// are not found
+func (s *AuthService) SetRole(ctx context.Context, userID, organisationID primitive.ObjectID, role string) error {
+	result, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "organisationId": organisationID},
+		bson.M{"$set": bson.M{"role": role}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetMembers retrieves the members of the organisation, in name order
+func (s *AuthService) GetMembers(ctx context.Context, organisationID primitive.ObjectID) ([]models.User, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{"organisationId": organisationID},
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "email", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	members := []models.User{}
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+	for i := range members {
+		if members[i].Role == "" {
+			members[i].Role = models.RoleStudent
+		}
+	}
+	return members, nil
+}
+
+// IssueToken signs a token identifying the user and their role
+func (s *AuthService) IssueToken(user *models.User) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		Role: user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.Hex(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(tokenTTL)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+}
+
+// ParseToken checks a token and returns the user it identifies and their
+// role
+func (s *AuthService) ParseToken(token string) (primitive.ObjectID, string, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return s.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	if err != nil {
+		return primitive.NilObjectID, "", ErrInvalidToken
+	}
+	id, err := primitive.ObjectIDFromHex(claims.Subject)
+	if err != nil {
+		return primitive.NilObjectID, "", ErrInvalidToken
+	}
+	if claims.Role == "" {
+		// Tokens from before roles were students'
+		claims.Role = models.RoleStudent
+	}
+	return id, claims.Role, nil
+}
@@ -0,0 +1,514 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pac-cee/student-activity-tracker/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrAlreadyInOrganisation = errors.New("already a member of an organisation")
+	ErrNoOrganisation        = errors.New("create or join an organisation first")
+	ErrInvalidAssignment     = errors.New("invalid assignment")
+	ErrInvalidRole           = errors.New("role must be student or teacher")
+)
+
+// ClassService handles organisations, classes and the assignments
+// teachers set them
+type ClassService struct {
+	organisations *mongo.Collection
+	classes       *mongo.Collection
+	assignments   *mongo.Collection
+	users         *AuthService
+	activities    *ActivityService
+}
+
+// NewClassService creates a new ClassService
+func NewClassService(organisations, classes, assignments *mongo.Collection, users *AuthService, activities *ActivityService) *ClassService {
+	return &ClassService{
+		organisations: organisations,
+		classes:       classes,
+		assignments:   assignments,
+		users:         users,
+		activities:    activities,
+	}
+}
+
+// EnsureIndexes makes join codes unique and creates the indexes
+// membership queries rely on
+func (s *ClassService) EnsureIndexes(ctx context.Context) error {
+	unique := options.Index().SetUnique(true)
+	if _, err := s.organisations.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "joinCode", Value: 1}}, Options: unique,
+	}); err != nil {
+		return err
+	}
+	if _, err := s.classes.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "joinCode", Value: 1}}, Options: unique},
+		{Keys: bson.D{{Key: "teacherIds", Value: 1}}},
+		{Keys: bson.D{{Key: "studentIds", Value: 1}}},
+	}); err != nil {
+		return err
+	}
+	_, err := s.assignments.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "classId", Value: 1}, {Key: "createdAt", Value: 1}},
+	})
+	return err
+}
+
+// newJoinCode makes a random code to join an organisation or class with
+func newJoinCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(b), nil
+}
+
+// CreateOrganisation creates an organisation with the user as its first
+// member and admin, making them a teacher
+func (s *ClassService) CreateOrganisation(ctx context.Context, userID primitive.ObjectID, name string) (*models.Organisation, error) {
+	user, err := s.users.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.OrganisationID != nil {
+		return nil, ErrAlreadyInOrganisation
+	}
+	code, err := newJoinCode()
+	if err != nil {
+		return nil, err
+	}
+
+	organisation := &models.Organisation{
+		ID:        primitive.NewObjectID(),
+		Name:      name,
+		JoinCode:  code,
+		AdminIDs:  []primitive.ObjectID{userID},
+		CreatedBy: userID,
+		CreatedAt: time.Now(),
+	}
+	if _, err := s.organisations.InsertOne(ctx, organisation); err != nil {
+		return nil, err
+	}
+	if err := s.users.SetOrganisation(ctx, userID, organisation.ID); err != nil {
+		s.organisations.DeleteOne(ctx, bson.M{"_id": organisation.ID})
+		return nil, err
+	}
+	if err := s.users.SetRole(ctx, userID, organisation.ID, models.RoleTeacher); err != nil {
+		return nil, err
+	}
+	return organisation, nil
+}
+
+// JoinOrganisation makes the user a member of the organisation with the
+// join code. Its join code is left out, as only teachers hand it out.
+func (s *ClassService) JoinOrganisation(ctx context.Context, userID primitive.ObjectID, code string) (*models.Organisation, error) {
+	var organisation models.Organisation
+	err := s.organisations.FindOne(ctx, bson.M{"joinCode": strings.ToUpper(strings.TrimSpace(code))}).Decode(&organisation)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.SetOrganisation(ctx, userID, organisation.ID); err != nil {
+		return nil, err
+	}
+	organisation.JoinCode = ""
+	return &organisation, nil
+}
+
+// memberOrganisation retrieves the user and their organisation
+func (s *ClassService) memberOrganisation(ctx context.Context, userID primitive.ObjectID) (*models.User, *models.Organisation, error) {
+	user, err := s.users.GetUser(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.OrganisationID == nil {
+		return nil, nil, ErrNotFound
+	}
+	var organisation models.Organisation
+	err = s.organisations.FindOne(ctx, bson.M{"_id": *user.OrganisationID}).Decode(&organisation)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, &organisation, nil
+}
+
+// GetOrganisation retrieves the user's organisation
+func (s *ClassService) GetOrganisation(ctx context.Context, userID primitive.ObjectID) (*models.Organisation, error) {
+	user, organisation, err := s.memberOrganisation(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role != models.RoleTeacher {
+		// Students can't hand out the code
+		organisation.JoinCode = ""
+	}
+	return organisation, nil
+}
+
+// adminOrganisation retrieves the organisation the user is an admin of
+func (s *ClassService) adminOrganisation(ctx context.Context, adminID primitive.ObjectID) (*models.Organisation, error) {
+	_, organisation, err := s.memberOrganisation(ctx, adminID)
+	if err != nil {
+		return nil, err
+	}
+	if !containsID(organisation.AdminIDs, adminID) {
+		return nil, fmt.Errorf("%w: only the organisation's admins can do that", ErrForbidden)
+	}
+	return organisation, nil
+}
+
+// GetMembers retrieves the members of the admin's organisation
+func (s *ClassService) GetMembers(ctx context.Context, adminID primitive.ObjectID) ([]models.User, error) {
+	organisation, err := s.adminOrganisation(ctx, adminID)
+	if err != nil {
+		return nil, err
+	}
+	return s.users.GetMembers(ctx, organisation.ID)
+}
+
+// SetMemberRole makes a member of the admin's organisation a student or a
+// teacher. Admins stay teachers.
+func (s *ClassService) SetMemberRole(ctx context.Context, adminID, userID primitive.ObjectID, role string) (*models.User, error) {
+	if role != models.RoleStudent && role != models.RoleTeacher {
+		return nil, ErrInvalidRole
+	}
+	organisation, err := s.adminOrganisation(ctx, adminID)
+	if err != nil {
+		return nil, err
+	}
+	if containsID(organisation.AdminIDs, userID) {
+		return nil, fmt.Errorf("%w: an admin's role can't be changed", ErrForbidden)
+	}
+	if err := s.users.SetRole(ctx, userID, organisation.ID, role); err != nil {
+		return nil, err
+	}
+	return s.users.GetUser(ctx, userID)
+}
+
+// CreateClass creates a class in the teacher's organisation
+func (s *ClassService) CreateClass(ctx context.Context, teacherID primitive.ObjectID, name string) (*models.Class, error) {
+	teacher, err := s.users.GetUser(ctx, teacherID)
+	if err != nil {
+		return nil, err
+	}
+	if teacher.OrganisationID == nil {
+		return nil, ErrNoOrganisation
+	}
+	code, err := newJoinCode()
+	if err != nil {
+		return nil, err
+	}
+
+	class := &models.Class{
+		ID:             primitive.NewObjectID(),
+		OrganisationID: *teacher.OrganisationID,
+		Name:           name,
+		JoinCode:       code,
+		TeacherIDs:     []primitive.ObjectID{teacherID},
+		StudentIDs:     []primitive.ObjectID{},
+		CreatedAt:      time.Now(),
+	}
+	if _, err := s.classes.InsertOne(ctx, class); err != nil {
+		return nil, err
+	}
+	return class, nil
+}
+
+// GetClasses retrieves the classes the user teaches or is in
+func (s *ClassService) GetClasses(ctx context.Context, userID primitive.ObjectID) ([]models.Class, error) {
+	cursor, err := s.classes.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"teacherIds": userID},
+		bson.M{"studentIds": userID},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	classes := []models.Class{}
+	if err := cursor.All(ctx, &classes); err != nil {
+		return nil, err
+	}
+	for i := range classes {
+		if !containsID(classes[i].TeacherIDs, userID) {
+			// Students don't see who else is in the class, or its code
+			classes[i].StudentIDs = nil
+			classes[i].JoinCode = ""
+		}
+	}
+	return classes, nil
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// taughtClass retrieves a class the teacher teaches; other classes are not
+// found
+func (s *ClassService) taughtClass(ctx context.Context, teacherID, classID primitive.ObjectID) (*models.Class, error) {
+	var class models.Class
+	err := s.classes.FindOne(ctx, bson.M{"_id": classID, "teacherIds": teacherID}).Decode(&class)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &class, nil
+}
+
+// JoinClass adds the student to the class with the join code, bringing
+// them into its organisation if they aren't in one yet
+func (s *ClassService) JoinClass(ctx context.Context, studentID primitive.ObjectID, code string) (*models.Class, error) {
+	var class models.Class
+	err := s.classes.FindOne(ctx, bson.M{"joinCode": strings.ToUpper(strings.TrimSpace(code))}).Decode(&class)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	student, err := s.users.GetUser(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	if student.Role == models.RoleStudent && student.OrganisationID == nil {
+		if err := s.users.SetOrganisation(ctx, studentID, class.OrganisationID); err != nil && !errors.Is(err, ErrAlreadyInOrganisation) {
+			return nil, err
+		}
+		if student, err = s.users.GetUser(ctx, studentID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.enrol(ctx, &class, student); err != nil {
+		return nil, err
+	}
+	class.StudentIDs, class.JoinCode = nil, ""
+	return &class, nil
+}
+
+// enrol adds a student of the class's organisation to it and gives them
+// the class's open assignments
+func (s *ClassService) enrol(ctx context.Context, class *models.Class, student *models.User) error {
+	if student.Role != models.RoleStudent {
+		return fmt.Errorf("%w: only students can be in a class", ErrForbidden)
+	}
+	if student.OrganisationID == nil || *student.OrganisationID != class.OrganisationID {
+		return fmt.Errorf("%w: the class belongs to another organisation", ErrForbidden)
+	}
+
+	if _, err := s.classes.UpdateOne(ctx, bson.M{"_id": class.ID}, bson.M{"$addToSet": bson.M{"studentIds": student.ID}}); err != nil {
+		return err
+	}
+	assignments, err := s.classAssignments(ctx, class.ID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, assignment := range assignments {
+		if assignment.DueAt != nil && assignment.DueAt.Before(now) {
+			continue
+		}
+		if err := s.assign(ctx, assignment, student.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveStudent takes a student out of one of the teacher's classes. The
+// activities they were assigned are kept.
+func (s *ClassService) RemoveStudent(ctx context.Context, teacherID, classID, studentID primitive.ObjectID) error {
+	if _, err := s.taughtClass(ctx, teacherID, classID); err != nil {
+		return err
+	}
+	result, err := s.classes.UpdateOne(ctx, bson.M{"_id": classID, "studentIds": studentID}, bson.M{"$pull": bson.M{"studentIds": studentID}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// validateAssignment checks an assignment's fields, defaulting its
+// priority
+func validateAssignment(assignment *models.Assignment) error {
+	if assignment.Title == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalidAssignment)
+	}
+	if assignment.Duration < 0 {
+		return fmt.Errorf("%w: duration can't be negative", ErrInvalidAssignment)
+	}
+	if assignment.Priority == "" {
+		assignment.Priority = models.PriorityMedium
+	}
+	if !validPriority(assignment.Priority) {
+		return fmt.Errorf("%w: priority must be high, medium or low", ErrInvalidAssignment)
+	}
+	return nil
+}
+
+// assign gives the student their own copy of the assignment, unless they
+// already have one
+func (s *ClassService) assign(ctx context.Context, assignment models.Assignment, studentID primitive.ObjectID) error {
+	has, err := s.activities.HasAssignment(ctx, studentID, assignment.ID)
+	if err != nil || has {
+		return err
+	}
+	assignmentID, classID := assignment.ID, assignment.ClassID
+	return s.activities.AssignActivity(ctx, studentID, &models.Activity{
+		Title:        assignment.Title,
+		Description:  assignment.Description,
+		Duration:     assignment.Duration,
+		Priority:     assignment.Priority,
+		AssignmentID: &assignmentID,
+		ClassID:      &classID,
+		DueAt:        assignment.DueAt,
+	})
+}
+
+// CreateAssignment sets one of the teacher's classes an assignment,
+// pushing a copy of it to every student in the class
+func (s *ClassService) CreateAssignment(ctx context.Context, teacherID, classID primitive.ObjectID, assignment *models.Assignment) error {
+	class, err := s.taughtClass(ctx, teacherID, classID)
+	if err != nil {
+		return err
+	}
+	if err := validateAssignment(assignment); err != nil {
+		return err
+	}
+	assignment.ID = primitive.NewObjectID()
+	assignment.ClassID = classID
+	assignment.TeacherID = teacherID
+	assignment.CreatedAt = time.Now()
+	if _, err := s.assignments.InsertOne(ctx, assignment); err != nil {
+		return err
+	}
+
+	for _, studentID := range class.StudentIDs {
+		if err := s.assign(ctx, *assignment, studentID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ClassService) classAssignments(ctx context.Context, classID primitive.ObjectID) ([]models.Assignment, error) {
+	cursor, err := s.assignments.Find(ctx, bson.M{"classId": classID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	assignments := []models.Assignment{}
+	if err := cursor.All(ctx, &assignments); err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+// GetAssignments retrieves the assignments of one of the teacher's
+// classes
+func (s *ClassService) GetAssignments(ctx context.Context, teacherID, classID primitive.ObjectID) ([]models.Assignment, error) {
+	if _, err := s.taughtClass(ctx, teacherID, classID); err != nil {
+		return nil, err
+	}
+	return s.classAssignments(ctx, classID)
+}
+
+// students retrieves a class's students, in name order
+func (s *ClassService) students(ctx context.Context, class *models.Class) ([]models.User, error) {
+	byID, err := s.users.GetUsers(ctx, class.StudentIDs)
+	if err != nil {
+		return nil, err
+	}
+	students := make([]models.User, 0, len(byID))
+	for _, u := range byID {
+		students = append(students, u)
+	}
+	sort.Slice(students, func(i, j int) bool {
+		if students[i].Name != students[j].Name {
+			return students[i].Name < students[j].Name
+		}
+		return students[i].Email < students[j].Email
+	})
+	return students, nil
+}
+
+// AssignmentProgress works out how far each student in one of the
+// teacher's classes has got with one of its assignments
+func (s *ClassService) AssignmentProgress(ctx context.Context, teacherID, classID, assignmentID primitive.ObjectID, now time.Time) (*models.Assignment, []StudentStatus, error) {
+	class, err := s.taughtClass(ctx, teacherID, classID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var assignment models.Assignment
+	err = s.assignments.FindOne(ctx, bson.M{"_id": assignmentID, "classId": classID}).Decode(&assignment)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	students, err := s.students(ctx, class)
+	if err != nil {
+		return nil, nil, err
+	}
+	activities, err := s.activities.AssignedActivities(ctx, []primitive.ObjectID{assignmentID})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &assignment, AssignmentStatuses(assignment, students, activities, now), nil
+}
+
+// Dashboard works out the progress of one of the teacher's classes on its
+// assignments
+func (s *ClassService) Dashboard(ctx context.Context, teacherID, classID primitive.ObjectID, now time.Time) (*Dashboard, error) {
+	class, err := s.taughtClass(ctx, teacherID, classID)
+	if err != nil {
+		return nil, err
+	}
+	students, err := s.students(ctx, class)
+	if err != nil {
+		return nil, err
+	}
+	assignments, err := s.classAssignments(ctx, classID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, len(assignments))
+	for i, a := range assignments {
+		ids[i] = a.ID
+	}
+	activities, err := s.activities.AssignedActivities(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	dashboard := ClassDashboard(*class, students, assignments, activities, now)
+	return &dashboard, nil
+}
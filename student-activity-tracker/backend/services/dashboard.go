@@ -0,0 +1,168 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/pac-cee/student-activity-tracker/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StudentSummary is what a teacher sees of a student
+type StudentSummary struct {
+	ID    primitive.ObjectID `json:"id"`
+	Name  string             `json:"name"`
+	Email string             `json:"email"`
+}
+
+// StudentStatus is how far a student has got with an assignment
+type StudentStatus struct {
+	Student       StudentSummary      `json:"student"`
+	ActivityID    *primitive.ObjectID `json:"activityId,omitempty"`
+	Status        string              `json:"status"` // the activity's, or "missing" if the student has no copy
+	StartedAt     *time.Time          `json:"startedAt,omitempty"`
+	CompletedAt   *time.Time          `json:"completedAt,omitempty"`
+	WorkedMinutes float64             `json:"workedMinutes"`
+	Overdue       bool                `json:"overdue"`
+}
+
+// AssignmentProgress sums up a class's progress on an assignment
+type AssignmentProgress struct {
+	Assignment models.Assignment `json:"assignment"`
+	Students   int               `json:"students"`
+	NotStarted int               `json:"notStarted"`
+	InProgress int               `json:"inProgress"` // including paused
+	Completed  int               `json:"completed"`
+	Overdue    int               `json:"overdue"`
+	// AverageCompletionMinutes is the mean time worked by the students
+	// who completed it
+	AverageCompletionMinutes float64 `json:"averageCompletionMinutes"`
+}
+
+// StudentProgress sums up a student's progress across a class's
+// assignments
+type StudentProgress struct {
+	Student                  StudentSummary `json:"student"`
+	Assigned                 int            `json:"assigned"`
+	Completed                int            `json:"completed"`
+	Overdue                  int            `json:"overdue"`
+	AverageCompletionMinutes float64        `json:"averageCompletionMinutes"`
+}
+
+// OverdueItem is an assignment a student hasn't completed by its due date
+type OverdueItem struct {
+	Student      StudentSummary     `json:"student"`
+	AssignmentID primitive.ObjectID `json:"assignmentId"`
+	Title        string             `json:"title"`
+	DueAt        time.Time          `json:"dueAt"`
+	Status       string             `json:"status"`
+}
+
+// Dashboard is a class's progress on its assignments
+type Dashboard struct {
+	Class       models.Class         `json:"class"`
+	Assignments []AssignmentProgress `json:"assignments"`
+	Students    []StudentProgress    `json:"students"`
+	Overdue     []OverdueItem        `json:"overdue"`
+}
+
+// StatusMissing is the status of an assignment a student has no copy of,
+// having been removed from the class and added back, say
+const StatusMissing = "missing"
+
+func summarize(u models.User) StudentSummary {
+	return StudentSummary{ID: u.ID, Name: u.Name, Email: u.Email}
+}
+
+// studentStatus works out how far a student has got with an assignment
+// from their copy of it, which may be nil
+func studentStatus(student models.User, assignment models.Assignment, activity *models.Activity, now time.Time) StudentStatus {
+	status := StudentStatus{Student: summarize(student), Status: StatusMissing}
+	if activity != nil {
+		id := activity.ID
+		status.ActivityID = &id
+		status.Status = activity.Status
+		if work := activity.WorkSegments(); len(work) > 0 {
+			status.StartedAt = &work[0].Start
+		}
+		status.CompletedAt = activity.Completion()
+		status.WorkedMinutes = minutes(activity.Worked(now))
+	}
+	status.Overdue = assignment.DueAt != nil && now.After(*assignment.DueAt) && status.Status != models.StatusCompleted
+	return status
+}
+
+// AssignmentStatuses works out how far each student has got with an
+// assignment
+func AssignmentStatuses(assignment models.Assignment, students []models.User, activities []models.Activity, now time.Time) []StudentStatus {
+	copies := make(map[primitive.ObjectID]*models.Activity)
+	for i := range activities {
+		if a := &activities[i]; a.AssignmentID != nil && *a.AssignmentID == assignment.ID {
+			copies[a.OwnerID] = a
+		}
+	}
+	statuses := make([]StudentStatus, 0, len(students))
+	for _, student := range students {
+		statuses = append(statuses, studentStatus(student, assignment, copies[student.ID], now))
+	}
+	return statuses
+}
+
+func average(total float64, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	return math.Round(total/float64(n)*10) / 10
+}
+
+// ClassDashboard works out a class's progress on its assignments from its
+// students' copies of them
+func ClassDashboard(class models.Class, students []models.User, assignments []models.Assignment, activities []models.Activity, now time.Time) Dashboard {
+	dashboard := Dashboard{
+		Class:       class,
+		Assignments: []AssignmentProgress{},
+		Students:    make([]StudentProgress, len(students)),
+		Overdue:     []OverdueItem{},
+	}
+	studentTotals := make([]float64, len(students))
+	for i, student := range students {
+		dashboard.Students[i].Student = summarize(student)
+	}
+
+	for _, assignment := range assignments {
+		progress := AssignmentProgress{Assignment: assignment, Students: len(students)}
+		var total float64
+		for i, status := range AssignmentStatuses(assignment, students, activities, now) {
+			sp := &dashboard.Students[i]
+			sp.Assigned++
+			switch status.Status {
+			case models.StatusCompleted:
+				progress.Completed++
+				sp.Completed++
+				total += status.WorkedMinutes
+				studentTotals[i] += status.WorkedMinutes
+			case models.StatusInProgress, models.StatusPaused:
+				progress.InProgress++
+			default:
+				progress.NotStarted++
+			}
+			if status.Overdue {
+				progress.Overdue++
+				sp.Overdue++
+				dashboard.Overdue = append(dashboard.Overdue, OverdueItem{
+					Student:      status.Student,
+					AssignmentID: assignment.ID,
+					Title:        assignment.Title,
+					DueAt:        *assignment.DueAt,
+					Status:       status.Status,
+				})
+			}
+		}
+		progress.AverageCompletionMinutes = average(total, progress.Completed)
+		dashboard.Assignments = append(dashboard.Assignments, progress)
+	}
+	for i := range dashboard.Students {
+		dashboard.Students[i].AverageCompletionMinutes = average(studentTotals[i], dashboard.Students[i].Completed)
+	}
+	return dashboard
+}
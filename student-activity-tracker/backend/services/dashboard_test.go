@@ -0,0 +1,70 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pac-cee/student-activity-tracker/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestClassDashboard(t *testing.T) {
+	now := clock(t, time.UTC, "2025-03-10 12:00")
+	due := clock(t, time.UTC, "2025-03-09 23:59")
+	worked := func(studentID, assignmentID primitive.ObjectID, status, from, to string) models.Activity {
+		start, end := clock(t, time.UTC, from), clock(t, time.UTC, to)
+		a := models.Activity{
+			ID: primitive.NewObjectID(), OwnerID: studentID, AssignmentID: &assignmentID, Status: status,
+			Segments: []models.TimeSegment{{Kind: models.SegmentWork, Start: start, End: &end}},
+		}
+		if status == models.StatusCompleted {
+			a.CompletedAt = &end
+		}
+		return a
+	}
+
+	ada := models.User{ID: primitive.NewObjectID(), Name: "Ada"}
+	bob := models.User{ID: primitive.NewObjectID(), Name: "Bob"}
+	cy := models.User{ID: primitive.NewObjectID(), Name: "Cy"}
+	essay := models.Assignment{ID: primitive.NewObjectID(), Title: "Essay", DueAt: &due}
+	reading := models.Assignment{ID: primitive.NewObjectID(), Title: "Reading"}
+
+	activities := []models.Activity{
+		worked(ada.ID, essay.ID, models.StatusCompleted, "2025-03-08 10:00", "2025-03-08 11:00"),
+		worked(bob.ID, essay.ID, models.StatusPaused, "2025-03-09 10:00", "2025-03-09 10:20"),
+		worked(ada.ID, reading.ID, models.StatusCompleted, "2025-03-10 09:00", "2025-03-10 09:30"),
+		worked(bob.ID, reading.ID, models.StatusCompleted, "2025-03-10 09:00", "2025-03-10 09:45"),
+		// Cy has no copy of the essay, and hasn't started the reading
+		{ID: primitive.NewObjectID(), OwnerID: cy.ID, AssignmentID: &reading.ID, Status: models.StatusPlanned},
+	}
+	students := []models.User{ada, bob, cy}
+
+	statuses := AssignmentStatuses(essay, students, activities, now)
+	if statuses[0].Status != models.StatusCompleted || statuses[0].Overdue || statuses[0].WorkedMinutes != 60 {
+		t.Errorf("Ada's essay = %+v", statuses[0])
+	}
+	if statuses[1].Status != models.StatusPaused || !statuses[1].Overdue || statuses[1].StartedAt == nil {
+		t.Errorf("Bob's essay = %+v", statuses[1])
+	}
+	if statuses[2].Status != StatusMissing || !statuses[2].Overdue || statuses[2].ActivityID != nil {
+		t.Errorf("Cy's essay = %+v", statuses[2])
+	}
+
+	got := ClassDashboard(models.Class{Name: "Year 9"}, students, []models.Assignment{essay, reading}, activities, now)
+	e, r := got.Assignments[0], got.Assignments[1]
+	if e.Students != 3 || e.Completed != 1 || e.InProgress != 1 || e.NotStarted != 1 || e.Overdue != 2 || e.AverageCompletionMinutes != 60 {
+		t.Errorf("essay = %+v", e)
+	}
+	if r.Completed != 2 || r.NotStarted != 1 || r.Overdue != 0 || r.AverageCompletionMinutes != 37.5 {
+		t.Errorf("reading = %+v", r)
+	}
+	if s := got.Students[0]; s.Assigned != 2 || s.Completed != 2 || s.Overdue != 0 || s.AverageCompletionMinutes != 45 {
+		t.Errorf("Ada = %+v", s)
+	}
+	if s := got.Students[2]; s.Completed != 0 || s.Overdue != 1 || s.AverageCompletionMinutes != 0 {
+		t.Errorf("Cy = %+v", s)
+	}
+	if len(got.Overdue) != 2 || got.Overdue[0].Student.Name != "Bob" || got.Overdue[1].Status != StatusMissing {
+		t.Errorf("overdue = %+v", got.Overdue)
+	}
+}
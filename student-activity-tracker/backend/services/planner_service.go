@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pac-cee/student-activity-tracker/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidTemplate     = errors.New("invalid template")
+	ErrInvalidAvailability = errors.New("invalid availability")
+)
+
+// PlannerService handles the weekly planner: recurring activity templates,
+// the availability calendar and fitting activities into it
+type PlannerService struct {
+	templates    *mongo.Collection
+	availability *mongo.Collection
+	activities   *ActivityService
+}
+
+// NewPlannerService creates a new PlannerService
+func NewPlannerService(templates, availability *mongo.Collection, activities *ActivityService) *PlannerService {
+	return &PlannerService{
+		templates:    templates,
+		availability: availability,
+		activities:   activities,
+	}
+}
+
+// EnsureIndexes creates the indexes owner-scoped queries rely on
+func (s *PlannerService) EnsureIndexes(ctx context.Context) error {
+	_, err := s.templates.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "ownerId", Value: 1}},
+	})
+	return err
+}
+
+// validateTemplate checks a template's fields, defaulting its priority and
+// sorting its weekdays
+func validateTemplate(template *models.ActivityTemplate) error {
+	if template.Title == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalidTemplate)
+	}
+	if template.Duration <= 0 {
+		return fmt.Errorf("%w: duration must be at least a minute", ErrInvalidTemplate)
+	}
+	if template.Priority == "" {
+		template.Priority = models.PriorityMedium
+	}
+	if !validPriority(template.Priority) {
+		return fmt.Errorf("%w: priority must be high, medium or low", ErrInvalidTemplate)
+	}
+	if len(template.Weekdays) == 0 {
+		return fmt.Errorf("%w: weekdays are required", ErrInvalidTemplate)
+	}
+	seen := make(map[int]bool)
+	weekdays := []int{}
+	for _, wd := range template.Weekdays {
+		if wd < 0 || wd > 6 {
+			return fmt.Errorf("%w: weekday must be 0 (Sunday) to 6", ErrInvalidTemplate)
+		}
+		if !seen[wd] {
+			seen[wd] = true
+			weekdays = append(weekdays, wd)
+		}
+	}
+	sort.Ints(weekdays)
+	template.Weekdays = weekdays
+	if template.StartAt != "" {
+		start, err := ParseClock(template.StartAt)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+		if start+template.Duration > 24*60 {
+			return fmt.Errorf("%w: activity runs past midnight", ErrInvalidTemplate)
+		}
+	}
+	return nil
+}
+
+// CreateTemplate creates a new recurring template for the owner
+func (s *PlannerService) CreateTemplate(ctx context.Context, ownerID primitive.ObjectID, template *models.ActivityTemplate) error {
+	if err := validateTemplate(template); err != nil {
+		return err
+	}
+	template.ID = primitive.NewObjectID()
+	template.OwnerID = ownerID
+	template.CreatedAt = time.Now()
+	template.UpdatedAt = time.Now()
+
+	_, err := s.templates.InsertOne(ctx, template)
+	return err
+}
+
+// GetTemplates retrieves all the owner's templates
+func (s *PlannerService) GetTemplates(ctx context.Context, ownerID primitive.ObjectID) ([]models.ActivityTemplate, error) {
+	cursor, err := s.templates.Find(ctx, bson.M{"ownerId": ownerID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	templates := []models.ActivityTemplate{}
+	if err := cursor.All(ctx, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// UpdateTemplate updates an existing template. Activities already planned
+// from it keep their old details.
+func (s *PlannerService) UpdateTemplate(ctx context.Context, ownerID, id primitive.ObjectID, template *models.ActivityTemplate) error {
+	if err := validateTemplate(template); err != nil {
+		return err
+	}
+	template.ID = id
+	template.OwnerID = ownerID
+	template.UpdatedAt = time.Now()
+	result, err := s.templates.UpdateOne(ctx, bson.M{"_id": id, "ownerId": ownerID}, bson.M{
+		"$set": bson.M{
+			"title":       template.Title,
+			"description": template.Description,
+			"duration":    template.Duration,
+			"priority":    template.Priority,
+			"weekdays":    template.Weekdays,
+			"startAt":     template.StartAt,
+			"updatedAt":   template.UpdatedAt,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteTemplate deletes a template. Activities already planned from it
+// are kept.
+func (s *PlannerService) DeleteTemplate(ctx context.Context, ownerID, id primitive.ObjectID) error {
+	result, err := s.templates.DeleteOne(ctx, bson.M{"_id": id, "ownerId": ownerID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetAvailability retrieves the owner's availability calendar; until they
+// set one it is empty, in UTC
+func (s *PlannerService) GetAvailability(ctx context.Context, ownerID primitive.ObjectID) (*models.Availability, error) {
+	availability := models.Availability{OwnerID: ownerID, Timezone: "UTC", Slots: []models.AvailabilitySlot{}}
+	err := s.availability.FindOne(ctx, bson.M{"_id": ownerID}).Decode(&availability)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	return &availability, nil
+}
+
+// SetAvailability replaces the owner's availability calendar
+func (s *PlannerService) SetAvailability(ctx context.Context, ownerID primitive.ObjectID, availability *models.Availability) error {
+	if err := ValidateAvailability(availability); err != nil {
+		return err
+	}
+	if availability.Slots == nil {
+		availability.Slots = []models.AvailabilitySlot{}
+	}
+	availability.OwnerID = ownerID
+	availability.UpdatedAt = time.Now()
+	_, err := s.availability.ReplaceOne(ctx, bson.M{"_id": ownerID}, availability, options.Replace().SetUpsert(true))
+	return err
+}
+
+// Location is the timezone of the owner's availability calendar, which
+// their weeks are planned in
+func (s *PlannerService) Location(ctx context.Context, ownerID primitive.ObjectID) (*time.Location, error) {
+	availability, err := s.GetAvailability(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(availability.Timezone)
+	if err != nil {
+		return time.UTC, nil
+	}
+	return loc, nil
+}
+
+// WeekConflicts finds the owner's activities that overlap in the week
+// starting weekStart
+func (s *PlannerService) WeekConflicts(ctx context.Context, ownerID primitive.ObjectID, weekStart time.Time) ([]Conflict, error) {
+	activities, err := s.activities.ActivitiesBetween(ctx, ownerID, weekStart, weekStart.AddDate(0, 0, 7))
+	if err != nil {
+		return nil, err
+	}
+	return FindConflicts(activities), nil
+}
+
+// GenerateWeek plans an activity from each of the owner's templates on
+// each of its weekdays in the week starting weekStart, from today on.
+// Days it has already been planned for are skipped, so generating a week
+// twice is harmless.
+func (s *PlannerService) GenerateWeek(ctx context.Context, ownerID primitive.ObjectID, weekStart, now time.Time) ([]models.Activity, error) {
+	templates, err := s.GetTemplates(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	loc := weekStart.Location()
+	today := at(now.In(loc), 0, loc)
+
+	created := []models.Activity{}
+	for i := 0; i < 7; i++ {
+		day := weekStart.AddDate(0, 0, i)
+		if day.Before(today) {
+			continue
+		}
+		for _, t := range templates {
+			if !containsWeekday(t.Weekdays, day.Weekday()) {
+				continue
+			}
+			exists, err := s.activities.HasTemplateInstance(ctx, ownerID, t.ID, day)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				continue
+			}
+			templateID, plannedFor := t.ID, day
+			activity := models.Activity{
+				Title:       t.Title,
+				Description: t.Description,
+				Duration:    t.Duration,
+				Priority:    t.Priority,
+				TemplateID:  &templateID,
+				PlannedFor:  &plannedFor,
+			}
+			if t.StartAt != "" {
+				minutes, _ := ParseClock(t.StartAt)
+				start := at(day, minutes, loc)
+				activity.StartTime = &start
+			}
+			if err := s.activities.CreateActivity(ctx, ownerID, &activity); err != nil {
+				return nil, err
+			}
+			created = append(created, activity)
+		}
+	}
+	return created, nil
+}
+
+func containsWeekday(weekdays []int, wd time.Weekday) bool {
+	for _, d := range weekdays {
+		if time.Weekday(d) == wd {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduleWeek fits the owner's unscheduled planned activities into the
+// free time in their availability calendar for the week starting
+// weekStart, saving the windows of those that fit
+func (s *PlannerService) ScheduleWeek(ctx context.Context, ownerID primitive.ObjectID, weekStart, now time.Time) (scheduled, unscheduled []models.Activity, err error) {
+	availability, err := s.GetAvailability(ctx, ownerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	weekEnd := weekStart.AddDate(0, 0, 7)
+	busy, err := s.activities.ActivitiesBetween(ctx, ownerID, weekStart, weekEnd)
+	if err != nil {
+		return nil, nil, err
+	}
+	pending, err := s.activities.Unscheduled(ctx, ownerID, weekStart, weekEnd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	free := FreeSlots(*availability, weekStart, busy, now)
+	scheduled, unscheduled = FitActivities(free, pending, weekStart.Location())
+	for _, a := range scheduled {
+		if err := s.activities.ScheduleActivity(ctx, ownerID, a.ID, *a.StartTime, *a.EndTime); err != nil {
+			return nil, nil, err
+		}
+	}
+	return scheduled, unscheduled, nil
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pac-cee/student-activity-tracker/models"
+)
+
+// Slot is a window of time
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Conflict is two activities whose windows overlap
+type Conflict struct {
+	First  models.Activity `json:"first"`
+	Second models.Activity `json:"second"`
+}
+
+// ParseClock reads an HH:MM time of day as minutes after midnight. 24:00
+// is the end of the day.
+func ParseClock(s string) (int, error) {
+	var h, m int
+	if n, err := fmt.Sscanf(s, "%2d:%2d", &h, &m); err != nil || n != 2 || len(s) != 5 {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	if h < 0 || m < 0 || m > 59 || h > 24 || h == 24 && m != 0 {
+		return 0, fmt.Errorf("%q is not a time of day", s)
+	}
+	return h*60 + m, nil
+}
+
+// ValidateAvailability checks an availability calendar, defaulting its
+// timezone to UTC
+func ValidateAvailability(availability *models.Availability) error {
+	if availability.Timezone == "" {
+		availability.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(availability.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidAvailability, availability.Timezone)
+	}
+	for _, slot := range availability.Slots {
+		if slot.Weekday < 0 || slot.Weekday > 6 {
+			return fmt.Errorf("%w: weekday must be 0 (Sunday) to 6", ErrInvalidAvailability)
+		}
+		start, err := ParseClock(slot.Start)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAvailability, err)
+		}
+		end, err := ParseClock(slot.End)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAvailability, err)
+		}
+		if end <= start {
+			return fmt.Errorf("%w: slot %s-%s ends before it starts", ErrInvalidAvailability, slot.Start, slot.End)
+		}
+	}
+	return nil
+}
+
+// ParseWeek reads the YYYY-MM-DD date of any day in a week, in loc, and
+// returns the start of that week. An empty string is this week.
+func ParseWeek(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	if s == "" {
+		return WeekStart(now.In(loc)), nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", s, loc)
+	if err != nil {
+		return day, fmt.Errorf("week %q is not YYYY-MM-DD", s)
+	}
+	return WeekStart(day), nil
+}
+
+// WeekStart is midnight on the Monday of day's week, in day's location
+func WeekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	y, m, d := day.AddDate(0, 0, -offset).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, day.Location())
+}
+
+// at is minutes after midnight on day, in loc
+func at(day time.Time, minutes int, loc *time.Location) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d, 0, minutes, 0, 0, loc)
+}
+
+// FreeSlots works out the windows in the week starting weekStart that the
+// availability calendar has free, after now and around the busy
+// activities, in order
+func FreeSlots(availability models.Availability, weekStart time.Time, busy []models.Activity, now time.Time) []Slot {
+	loc, err := time.LoadLocation(availability.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	var free []Slot
+	for i := 0; i < 7; i++ {
+		day := weekStart.AddDate(0, 0, i)
+		for _, slot := range availability.Slots {
+			if time.Weekday(slot.Weekday) != day.Weekday() {
+				continue
+			}
+			start, _ := ParseClock(slot.Start)
+			end, _ := ParseClock(slot.End)
+			s := Slot{Start: at(day, start, loc), End: at(day, end, loc)}
+			if s.Start.Before(now) {
+				s.Start = now
+			}
+			if s.End.After(s.Start) {
+				free = append(free, s)
+			}
+		}
+	}
+	sort.Slice(free, func(i, j int) bool { return free[i].Start.Before(free[j].Start) })
+
+	// Merge overlapping slots, then cut out the busy windows
+	var merged []Slot
+	for _, s := range free {
+		if n := len(merged); n > 0 && !s.Start.After(merged[n-1].End) {
+			if s.End.After(merged[n-1].End) {
+				merged[n-1].End = s.End
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	for _, a := range busy {
+		if a.StartTime == nil || a.EndTime == nil {
+			continue
+		}
+		merged = cut(merged, Slot{Start: *a.StartTime, End: *a.EndTime})
+	}
+	return merged
+}
+
+// cut removes the window w from the slots
+func cut(slots []Slot, w Slot) []Slot {
+	var out []Slot
+	for _, s := range slots {
+		if !w.Start.Before(s.End) || !w.End.After(s.Start) {
+			out = append(out, s)
+			continue
+		}
+		if w.Start.After(s.Start) {
+			out = append(out, Slot{Start: s.Start, End: w.Start})
+		}
+		if w.End.Before(s.End) {
+			out = append(out, Slot{Start: w.End, End: s.End})
+		}
+	}
+	return out
+}
+
+// priorityRank orders priorities, highest first
+func priorityRank(p string) int {
+	switch p {
+	case models.PriorityHigh:
+		return 0
+	case models.PriorityLow:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// FitActivities places activities into the free slots, highest priority
+// and then longest first, each at the earliest time it fits. An activity
+// planned for a day only goes on that day, in loc. Those that don't fit
+// come back unscheduled.
+func FitActivities(free []Slot, activities []models.Activity, loc *time.Location) (scheduled, unscheduled []models.Activity) {
+	queue := append([]models.Activity(nil), activities...)
+	sort.SliceStable(queue, func(i, j int) bool {
+		a, b := queue[i], queue[j]
+		if priorityRank(a.Priority) != priorityRank(b.Priority) {
+			return priorityRank(a.Priority) < priorityRank(b.Priority)
+		}
+		if a.Duration != b.Duration {
+			return a.Duration > b.Duration
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+
+	slots := append([]Slot(nil), free...)
+	scheduled, unscheduled = []models.Activity{}, []models.Activity{}
+	for _, a := range queue {
+		need := time.Duration(a.Duration) * time.Minute
+		placed := false
+		for _, s := range slots {
+			if a.Duration <= 0 {
+				break
+			}
+			if a.PlannedFor != nil {
+				day := at(a.PlannedFor.In(loc), 0, loc)
+				s = intersect(s, Slot{Start: day, End: day.AddDate(0, 0, 1)})
+			}
+			if s.End.Sub(s.Start) < need {
+				continue
+			}
+			start, end := s.Start, s.Start.Add(need)
+			a.StartTime, a.EndTime = &start, &end
+			slots = cut(slots, Slot{Start: start, End: end})
+			placed = true
+			break
+		}
+		if placed {
+			scheduled = append(scheduled, a)
+		} else {
+			unscheduled = append(unscheduled, a)
+		}
+	}
+	return scheduled, unscheduled
+}
+
+// intersect is the overlap of two slots, empty if they don't
+func intersect(a, b Slot) Slot {
+	if b.Start.After(a.Start) {
+		a.Start = b.Start
+	}
+	if b.End.Before(a.End) {
+		a.End = b.End
+	}
+	if a.End.Before(a.Start) {
+		a.End = a.Start
+	}
+	return a
+}
+
+// FindConflicts finds every pair of activities whose windows overlap
+func FindConflicts(activities []models.Activity) []Conflict {
+	var timed []models.Activity
+	for _, a := range activities {
+		if a.StartTime != nil && a.EndTime != nil {
+			timed = append(timed, a)
+		}
+	}
+	sort.Slice(timed, func(i, j int) bool { return timed[i].StartTime.Before(*timed[j].StartTime) })
+
+	conflicts := []Conflict{}
+	for i := range timed {
+		for j := i + 1; j < len(timed) && timed[j].StartTime.Before(*timed[i].EndTime); j++ {
+			conflicts = append(conflicts, Conflict{First: timed[i], Second: timed[j]})
+		}
+	}
+	return conflicts
+}
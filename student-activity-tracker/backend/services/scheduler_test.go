@@ -0,0 +1,182 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pac-cee/student-activity-tracker/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func clock(t *testing.T, loc *time.Location, s string) time.Time {
+	t.Helper()
+	v, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func timed(t *testing.T, title, start, end string) models.Activity {
+	s, e := clock(t, time.UTC, start), clock(t, time.UTC, end)
+	return models.Activity{ID: primitive.NewObjectID(), Title: title, StartTime: &s, EndTime: &e}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"00:00", 0, false},
+		{"09:30", 570, false},
+		{"24:00", 1440, false},
+		{"24:01", 0, true},
+		{"9:30", 0, true},
+		{"12:60", 0, true},
+		{"noon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseClock(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseClock(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	// 2025-03-09 is a Sunday, so its week started on Monday the 3rd
+	if got := WeekStart(clock(t, time.UTC, "2025-03-09 18:00")); !got.Equal(clock(t, time.UTC, "2025-03-03 00:00")) {
+		t.Errorf("WeekStart(Sunday) = %v", got)
+	}
+	if got := WeekStart(clock(t, time.UTC, "2025-03-03 00:00")); !got.Equal(clock(t, time.UTC, "2025-03-03 00:00")) {
+		t.Errorf("WeekStart(Monday) = %v", got)
+	}
+}
+
+func TestFreeSlots(t *testing.T) {
+	availability := models.Availability{
+		Timezone: "Europe/Paris",
+		Slots: []models.AvailabilitySlot{
+			{Weekday: 1, Start: "09:00", End: "12:00"},
+			{Weekday: 1, Start: "11:00", End: "13:00"}, // overlaps the first
+			{Weekday: 2, Start: "18:00", End: "20:00"},
+		},
+	}
+	paris, _ := time.LoadLocation("Europe/Paris")
+	week := clock(t, paris, "2025-03-03 00:00")
+	busy := []models.Activity{timed(t, "Lecture", "2025-03-03 09:00", "2025-03-03 10:00")} // 10:00-11:00 in Paris
+
+	free := FreeSlots(availability, week, busy, clock(t, paris, "2025-03-01 00:00"))
+	want := []Slot{
+		{clock(t, paris, "2025-03-03 09:00"), clock(t, paris, "2025-03-03 10:00")},
+		{clock(t, paris, "2025-03-03 11:00"), clock(t, paris, "2025-03-03 13:00")},
+		{clock(t, paris, "2025-03-04 18:00"), clock(t, paris, "2025-03-04 20:00")},
+	}
+	if len(free) != len(want) {
+		t.Fatalf("FreeSlots = %v, want %v", free, want)
+	}
+	for i := range want {
+		if !free[i].Start.Equal(want[i].Start) || !free[i].End.Equal(want[i].End) {
+			t.Errorf("slot %d = %v-%v, want %v-%v", i, free[i].Start, free[i].End, want[i].Start, want[i].End)
+		}
+	}
+
+	// Time that has already gone isn't free
+	free = FreeSlots(availability, week, nil, clock(t, paris, "2025-03-03 12:30"))
+	if len(free) != 2 || !free[0].Start.Equal(clock(t, paris, "2025-03-03 12:30")) {
+		t.Errorf("FreeSlots after 12:30 = %v", free)
+	}
+}
+
+func TestFitActivities(t *testing.T) {
+	monday := clock(t, time.UTC, "2025-03-03 00:00")
+	tuesday := monday.AddDate(0, 0, 1)
+	free := []Slot{
+		{clock(t, time.UTC, "2025-03-03 09:00"), clock(t, time.UTC, "2025-03-03 10:00")},
+		{clock(t, time.UTC, "2025-03-03 14:00"), clock(t, time.UTC, "2025-03-03 16:00")},
+		{clock(t, time.UTC, "2025-03-04 09:00"), clock(t, time.UTC, "2025-03-04 10:00")},
+	}
+	activities := []models.Activity{
+		{Title: "Essay", Duration: 90, Priority: models.PriorityLow},
+		{Title: "Revision", Duration: 60, Priority: models.PriorityHigh},
+		{Title: "Reading", Duration: 30, Priority: models.PriorityMedium, PlannedFor: &tuesday},
+		{Title: "Project", Duration: 120, Priority: models.PriorityHigh},
+		{Title: "Thesis", Duration: 300, Priority: models.PriorityHigh},
+		{Title: "Someday", Priority: models.PriorityHigh},
+	}
+
+	scheduled, unscheduled := FitActivities(free, activities, time.UTC)
+	starts := make(map[string]time.Time)
+	for _, a := range scheduled {
+		starts[a.Title] = *a.StartTime
+		if !a.EndTime.Equal(a.StartTime.Add(time.Duration(a.Duration) * time.Minute)) {
+			t.Errorf("%s ends at %v", a.Title, a.EndTime)
+		}
+	}
+	want := map[string]string{
+		// High priority first, longest first: Project takes the afternoon,
+		// then Revision the morning; Essay no longer fits anywhere
+		"Project":  "2025-03-03 14:00",
+		"Revision": "2025-03-03 09:00",
+		"Reading":  "2025-03-04 09:00",
+	}
+	for title, start := range want {
+		if got, ok := starts[title]; !ok || !got.Equal(clock(t, time.UTC, start)) {
+			t.Errorf("%s starts at %v, want %s", title, got, start)
+		}
+	}
+	if len(scheduled) != len(want) || len(unscheduled) != 3 {
+		t.Errorf("scheduled %d, unscheduled %d", len(scheduled), len(unscheduled))
+	}
+	if activities[1].StartTime != nil {
+		t.Error("FitActivities changed its input")
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	activities := []models.Activity{
+		timed(t, "Lecture", "2025-03-03 09:00", "2025-03-03 11:00"),
+		timed(t, "Lab", "2025-03-03 10:30", "2025-03-03 12:00"),
+		timed(t, "Lunch", "2025-03-03 12:00", "2025-03-03 13:00"), // touches Lab but doesn't overlap
+		timed(t, "Seminar", "2025-03-03 09:30", "2025-03-03 10:00"),
+		{Title: "Unscheduled", Duration: 60},
+	}
+
+	conflicts := FindConflicts(activities)
+	got := make(map[string]bool)
+	for _, c := range conflicts {
+		got[c.First.Title+"/"+c.Second.Title] = true
+	}
+	for _, pair := range []string{"Lecture/Seminar", "Lecture/Lab"} {
+		if !got[pair] {
+			t.Errorf("missing conflict %s", pair)
+		}
+	}
+	if len(conflicts) != 2 {
+		t.Errorf("conflicts = %v", got)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	template := models.ActivityTemplate{Title: "Gym", Duration: 60, Weekdays: []int{5, 1, 5}, StartAt: "07:00"}
+	if err := validateTemplate(&template); err != nil {
+		t.Fatalf("validateTemplate: %v", err)
+	}
+	if template.Priority != models.PriorityMedium || len(template.Weekdays) != 2 || template.Weekdays[0] != 1 {
+		t.Errorf("normalized template = %+v", template)
+	}
+
+	for _, bad := range []models.ActivityTemplate{
+		{Duration: 60, Weekdays: []int{1}},
+		{Title: "Gym", Weekdays: []int{1}},
+		{Title: "Gym", Duration: 60},
+		{Title: "Gym", Duration: 60, Weekdays: []int{7}},
+		{Title: "Gym", Duration: 60, Weekdays: []int{1}, Priority: "urgent"},
+		{Title: "Gym", Duration: 120, Weekdays: []int{1}, StartAt: "23:00"},
+	} {
+		if err := validateTemplate(&bad); err == nil {
+			t.Errorf("validateTemplate(%+v) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pac-cee/student-activity-tracker/models"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid status change")
+	ErrInvalidPomodoro   = errors.New("invalid pomodoro settings")
+)
+
+// DefaultPomodoro is the classic cycle: 25 minutes of work and 5 of
+// break, with 15 after every fourth
+var DefaultPomodoro = models.PomodoroSettings{Work: 25, ShortBreak: 5, LongBreak: 15, LongBreakEvery: 4}
+
+// Pomodoro phases
+const (
+	PhaseWork       = "work"
+	PhaseShortBreak = "short-break"
+	PhaseLongBreak  = "long-break"
+)
+
+// PomodoroStatus is where an activity in Pomodoro mode is in its cycle
+type PomodoroStatus struct {
+	Settings  models.PomodoroSettings `json:"settings"`
+	Running   bool                    `json:"running"`
+	Phase     string                  `json:"phase"`
+	Cycle     int                     `json:"cycle"`
+	Completed int                     `json:"completed"` // work phases finished
+	Elapsed   int                     `json:"elapsed"`   // seconds into the phase
+	Remaining int                     `json:"remaining"` // seconds left in the phase, negative once it has run over
+	EndsAt    *time.Time              `json:"endsAt,omitempty"`
+}
+
+// openSegment is the segment being worked, if there is one
+func openSegment(a *models.Activity) *models.TimeSegment {
+	if n := len(a.Segments); n > 0 && a.Segments[n-1].End == nil {
+		return &a.Segments[n-1]
+	}
+	return nil
+}
+
+// closeSegment ends the segment being worked, if there is one
+func closeSegment(a *models.Activity, now time.Time) {
+	if s := openSegment(a); s != nil {
+		s.End = &now
+	}
+}
+
+// startWork starts or resumes an activity, opening a segment that carries
+// on from where it was paused
+func startWork(a *models.Activity, now time.Time) error {
+	switch a.Status {
+	case models.StatusPlanned, models.StatusPaused, "":
+	default:
+		return fmt.Errorf("%w: a %s activity can't be started", ErrInvalidTransition, a.Status)
+	}
+	next := models.TimeSegment{Kind: models.SegmentWork, Start: now}
+	if n := len(a.Segments); n > 0 {
+		next.Kind, next.Cycle = a.Segments[n-1].Kind, a.Segments[n-1].Cycle
+	} else if a.Pomodoro != nil {
+		next.Cycle = 1
+	}
+	// StartTime and EndTime are the planned window, which the scheduler
+	// and conflict checks rely on, so the actual times are kept apart
+	if a.StartedAt == nil {
+		a.StartedAt = &now
+	}
+	a.Status = models.StatusInProgress
+	a.Segments = append(a.Segments, next)
+	return nil
+}
+
+// pauseWork pauses an activity in progress
+func pauseWork(a *models.Activity, now time.Time) error {
+	if a.Status != models.StatusInProgress {
+		return fmt.Errorf("%w: only an activity in progress can be paused", ErrInvalidTransition)
+	}
+	closeSegment(a, now)
+	a.Status = models.StatusPaused
+	return nil
+}
+
+// completeWork completes an activity
+func completeWork(a *models.Activity, now time.Time) error {
+	if a.Status == models.StatusCompleted {
+		return fmt.Errorf("%w: activity is already completed", ErrInvalidTransition)
+	}
+	closeSegment(a, now)
+	a.Status = models.StatusCompleted
+	a.CompletedAt = &now
+	return nil
+}
+
+// nextPhase moves an activity in Pomodoro mode on from work to a break or
+// from a break to the next cycle's work
+func nextPhase(a *models.Activity, now time.Time) error {
+	if a.Pomodoro == nil {
+		return fmt.Errorf("%w: activity is not in Pomodoro mode", ErrInvalidTransition)
+	}
+	if a.Status != models.StatusInProgress {
+		return fmt.Errorf("%w: only an activity in progress can move to its next phase", ErrInvalidTransition)
+	}
+	last := a.Segments[len(a.Segments)-1]
+	closeSegment(a, now)
+	next := models.TimeSegment{Kind: models.SegmentBreak, Start: now, Cycle: last.Cycle}
+	if last.Kind == models.SegmentBreak {
+		next.Kind, next.Cycle = models.SegmentWork, last.Cycle+1
+	}
+	a.Segments = append(a.Segments, next)
+	return nil
+}
+
+// validatePomodoro fills in the defaults for settings left out and checks
+// the rest
+func validatePomodoro(settings *models.PomodoroSettings) error {
+	for _, field := range []struct {
+		value *int
+		def   int
+		name  string
+	}{
+		{&settings.Work, DefaultPomodoro.Work, "work"},
+		{&settings.ShortBreak, DefaultPomodoro.ShortBreak, "shortBreak"},
+		{&settings.LongBreak, DefaultPomodoro.LongBreak, "longBreak"},
+		{&settings.LongBreakEvery, DefaultPomodoro.LongBreakEvery, "longBreakEvery"},
+	} {
+		if *field.value == 0 {
+			*field.value = field.def
+		}
+		if *field.value < 0 || *field.value > 240 {
+			return fmt.Errorf("%w: %s must be from 1 to 240", ErrInvalidPomodoro, field.name)
+		}
+	}
+	return nil
+}
+
+// setPomodoro turns Pomodoro mode on with settings, or off with nil. Work
+// already under way becomes the first cycle; a break under way ends.
+func setPomodoro(a *models.Activity, settings *models.PomodoroSettings, now time.Time) error {
+	if a.Status == models.StatusCompleted {
+		return fmt.Errorf("%w: activity is already completed", ErrInvalidTransition)
+	}
+	if settings == nil {
+		a.Pomodoro = nil
+		if s := openSegment(a); s != nil && s.Kind == models.SegmentBreak {
+			closeSegment(a, now)
+			a.Segments = append(a.Segments, models.TimeSegment{Kind: models.SegmentWork, Start: now})
+		}
+		return nil
+	}
+	if err := validatePomodoro(settings); err != nil {
+		return err
+	}
+	if a.Pomodoro == nil {
+		if s := openSegment(a); s != nil {
+			closeSegment(a, now)
+			a.Segments = append(a.Segments, models.TimeSegment{Kind: models.SegmentWork, Start: now, Cycle: 1})
+		}
+	}
+	a.Pomodoro = settings
+	return nil
+}
+
+// Pomodoro works out where an activity in Pomodoro mode is in its cycle
+func Pomodoro(a models.Activity, now time.Time) (*PomodoroStatus, error) {
+	if a.Pomodoro == nil {
+		return nil, fmt.Errorf("%w: activity is not in Pomodoro mode", ErrInvalidTransition)
+	}
+	settings := *a.Pomodoro
+	status := &PomodoroStatus{Settings: settings, Phase: PhaseWork, Cycle: 1, Remaining: settings.Work * 60}
+	n := len(a.Segments)
+	if n == 0 || a.Segments[n-1].Cycle == 0 {
+		return status, nil
+	}
+
+	last := a.Segments[n-1]
+	status.Cycle = last.Cycle
+	status.Running = a.Status == models.StatusInProgress
+	length := settings.Work
+	status.Completed = last.Cycle - 1
+	if last.Kind == models.SegmentBreak {
+		status.Phase, length = PhaseShortBreak, settings.ShortBreak
+		if last.Cycle%settings.LongBreakEvery == 0 {
+			status.Phase, length = PhaseLongBreak, settings.LongBreak
+		}
+		status.Completed = last.Cycle
+	}
+
+	var elapsed time.Duration
+	for _, s := range a.Segments {
+		if s.Kind == last.Kind && s.Cycle == last.Cycle {
+			elapsed += s.Length(now)
+		}
+	}
+	status.Elapsed = int(elapsed / time.Second)
+	status.Remaining = length*60 - status.Elapsed
+	if status.Running {
+		endsAt := now.Add(time.Duration(status.Remaining) * time.Second)
+		status.EndsAt = &endsAt
+	}
+	return status, nil
+}
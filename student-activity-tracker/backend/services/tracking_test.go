@@ -0,0 +1,124 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pac-cee/student-activity-tracker/models"
+)
+
+func TestTracking(t *testing.T) {
+	start := clock(t, time.UTC, "2025-03-03 09:00")
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	// Planned for 08:00-09:00 but started late; the planned window stays
+	plannedStart, plannedEnd := at(-60), start
+	activity := models.Activity{Status: models.StatusPlanned, Duration: 60, StartTime: &plannedStart, EndTime: &plannedEnd}
+
+	steps := []struct {
+		name   string
+		change func(*models.Activity, time.Time) error
+		at     int
+		status string
+	}{
+		{"start", startWork, 0, models.StatusInProgress},
+		{"pause", pauseWork, 20, models.StatusPaused},
+		{"resume", startWork, 50, models.StatusInProgress},
+		{"pause", pauseWork, 75, models.StatusPaused},
+		{"resume", startWork, 80, models.StatusInProgress},
+		{"complete", completeWork, 100, models.StatusCompleted},
+	}
+	for _, step := range steps {
+		if err := step.change(&activity, at(step.at)); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if activity.Status != step.status {
+			t.Errorf("after %s status = %s, want %s", step.name, activity.Status, step.status)
+		}
+	}
+
+	if len(activity.Segments) != 3 {
+		t.Fatalf("segments = %+v", activity.Segments)
+	}
+	if got := activity.Worked(at(200)); got != 65*time.Minute {
+		t.Errorf("worked %v, want 65m", got)
+	}
+	if !activity.StartedAt.Equal(start) || !activity.CompletedAt.Equal(at(100)) {
+		t.Errorf("started %v, completed %v", activity.StartedAt, activity.CompletedAt)
+	}
+	if !activity.StartTime.Equal(plannedStart) || !activity.EndTime.Equal(plannedEnd) {
+		t.Errorf("planned window moved to %v-%v", activity.StartTime, activity.EndTime)
+	}
+
+	for name, change := range map[string]func(*models.Activity, time.Time) error{
+		"start": startWork, "pause": pauseWork, "complete": completeWork, "next": nextPhase,
+	} {
+		if err := change(&activity, at(120)); !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("%s a completed activity: %v", name, err)
+		}
+	}
+
+	planned := models.Activity{Status: models.StatusPlanned}
+	if err := pauseWork(&planned, start); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("pausing a planned activity: %v", err)
+	}
+}
+
+func TestPomodoro(t *testing.T) {
+	start := clock(t, time.UTC, "2025-03-03 09:00")
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	activity := models.Activity{Status: models.StatusPlanned, Duration: 120}
+
+	if err := setPomodoro(&activity, &models.PomodoroSettings{Work: 20, LongBreakEvery: 2}, start); err != nil {
+		t.Fatal(err)
+	}
+	if s := *activity.Pomodoro; s.ShortBreak != 5 || s.LongBreak != 15 {
+		t.Errorf("settings = %+v, want the default breaks", s)
+	}
+	if err := startWork(&activity, start); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := Pomodoro(activity, at(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Phase != PhaseWork || status.Cycle != 1 || status.Remaining != 15*60 || !status.EndsAt.Equal(at(20)) {
+		t.Errorf("five minutes in: %+v", status)
+	}
+
+	// Work for 20, a short break, work for 20 with a pause, then the long
+	// break after the second cycle
+	for _, step := range []struct {
+		change func(*models.Activity, time.Time) error
+		at     int
+	}{
+		{nextPhase, 20}, {nextPhase, 25}, {pauseWork, 35}, {startWork, 40}, {nextPhase, 50},
+	} {
+		if err := step.change(&activity, at(step.at)); err != nil {
+			t.Fatalf("at %d: %v", step.at, err)
+		}
+	}
+	status, _ = Pomodoro(activity, at(52))
+	if status.Phase != PhaseLongBreak || status.Cycle != 2 || status.Completed != 2 || status.Remaining != 13*60 {
+		t.Errorf("on the long break: %+v", status)
+	}
+	if got := activity.Worked(at(52)); got != 40*time.Minute {
+		t.Errorf("worked %v, want 40m of focus", got)
+	}
+
+	// Turning Pomodoro off ends the break and goes back to work
+	if err := setPomodoro(&activity, nil, at(55)); err != nil {
+		t.Fatal(err)
+	}
+	if last := activity.Segments[len(activity.Segments)-1]; last.Kind != models.SegmentWork || last.End != nil {
+		t.Errorf("after turning Pomodoro off the last segment is %+v", last)
+	}
+	if _, err := Pomodoro(activity, at(56)); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Pomodoro status without Pomodoro mode: %v", err)
+	}
+
+	if err := setPomodoro(&activity, &models.PomodoroSettings{Work: -1}, at(60)); !errors.Is(err, ErrInvalidPomodoro) {
+		t.Errorf("negative work length: %v", err)
+	}
+}
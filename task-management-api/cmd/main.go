@@ -1,12 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/task-management-api/internal/auth"
+	"github.com/task-management-api/internal/handlers"
+	"github.com/task-management-api/internal/middleware"
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/notify"
+	"github.com/task-management-api/internal/repository"
+	"github.com/task-management-api/internal/service"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -15,15 +29,44 @@ func main() {
 		log.Printf("Warning: .env file not found")
 	}
 
-	// Initialize Gin router
-	router := gin.Default()
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		log.Fatal("JWT_SECRET must be set")
+	}
+	tokenTTL := 24 * time.Hour
+	if v := os.Getenv("JWT_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid JWT_TTL: %v", err)
+		}
+		tokenTTL = ttl
+	}
 
-	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status": "ok",
-		})
-	})
+	// Connect to the database
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		dsn = "host=localhost user=postgres dbname=tasks sslmode=disable"
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	repo := repository.NewPostgresRepository(db)
+	router := newRouter(repo, auth.NewTokenManager(secret, tokenTTL))
+
+	// Send reminders in the background, to REMINDER_WEBHOOK_URL if set and
+	// to the log otherwise
+	var sender notify.Sender = notify.LogSender{}
+	if url := os.Getenv("REMINDER_WEBHOOK_URL"); url != "" {
+		sender = notify.WebhookSender{URL: url}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go notify.NewWorker(repo, sender).Run(ctx)
 
 	// Get port from environment variable or use default
 	port := os.Getenv("PORT")
@@ -31,9 +74,82 @@ func main() {
 		port = "8080"
 	}
 
-	// Start server
+	// Start server, and stop it and the reminders on SIGINT or SIGTERM
+	server := &http.Server{Addr: ":" + port, Handler: router}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to shut down cleanly: %v", err)
+		}
+	}()
 	log.Printf("Server starting on port %s", port)
-	if err := router.Run(":" + port); err != nil {
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// newRouter wires the handlers to their routes
+func newRouter(repo repository.Repository, tokens *auth.TokenManager) *gin.Engine {
+	// Initialize Gin router
+	router := gin.Default()
+
+	// Health check endpoint
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status": "ok",
+		})
+	})
+
+	svc := service.New(repo)
+	authHandler := handlers.NewAuthHandler(repo, tokens)
+	taskHandler := handlers.NewTaskHandler(svc)
+	projectHandler := handlers.NewProjectHandler(svc)
+	commentHandler := handlers.NewCommentHandler(svc)
+	calendarHandler := handlers.NewCalendarHandler(svc)
+
+	// Calendar apps fetch the feed without a login; the token in the URL
+	// is the credential
+	router.GET("/calendar/:token", calendarHandler.Feed)
+
+	api := router.Group("/api/v1")
+	api.POST("/auth/register", authHandler.Register)
+	api.POST("/auth/login", authHandler.Login)
+
+	authed := api.Group("", middleware.Auth(tokens, repo))
+	authed.GET("/me", authHandler.Me)
+	authed.POST("/me/calendar", calendarHandler.IssueToken)
+	authed.DELETE("/me/calendar", calendarHandler.RevokeToken)
+	authed.GET("/tasks", taskHandler.ListTasks)
+	authed.POST("/tasks", taskHandler.CreateTask)
+	authed.GET("/tasks/:id", taskHandler.GetTask)
+	authed.PUT("/tasks/:id", taskHandler.UpdateTask)
+	authed.PATCH("/tasks/:id", taskHandler.UpdateTask)
+	authed.DELETE("/tasks/:id", taskHandler.DeleteTask)
+	authed.PUT("/tasks/:id/labels", taskHandler.SetLabels)
+	authed.POST("/tasks/:id/move", taskHandler.MoveTask)
+	authed.GET("/tasks/:id/reminders", taskHandler.ListReminders)
+	authed.PUT("/tasks/:id/reminders", taskHandler.SetReminders)
+	authed.GET("/tasks/:id/dependencies", taskHandler.ListDependencies)
+	authed.POST("/tasks/:id/dependencies", taskHandler.AddDependency)
+	authed.DELETE("/tasks/:id/dependencies/:blockerID", taskHandler.RemoveDependency)
+	authed.GET("/tasks/:id/comments", commentHandler.ListComments)
+	authed.POST("/tasks/:id/comments", commentHandler.AddComment)
+
+	authed.GET("/projects", projectHandler.ListProjects)
+	authed.POST("/projects", projectHandler.CreateProject)
+	authed.GET("/projects/:id", projectHandler.GetProject)
+	authed.PATCH("/projects/:id", projectHandler.UpdateProject)
+	authed.DELETE("/projects/:id", projectHandler.DeleteProject)
+	authed.GET("/projects/:id/members", projectHandler.ListMembers)
+	authed.POST("/projects/:id/members", projectHandler.AddMember)
+	authed.PATCH("/projects/:id/members/:userID", projectHandler.UpdateMember)
+	authed.DELETE("/projects/:id/members/:userID", projectHandler.RemoveMember)
+	authed.GET("/projects/:id/labels", projectHandler.ListLabels)
+	authed.POST("/projects/:id/labels", projectHandler.CreateLabel)
+	authed.DELETE("/projects/:id/labels/:labelID", projectHandler.DeleteLabel)
+	authed.GET("/projects/:id/activity", projectHandler.ListActivity)
+
+	return router
+}
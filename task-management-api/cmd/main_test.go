@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/task-management-api/internal/auth"
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/repository"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+	tokens *auth.TokenManager
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+
+	tokens := auth.NewTokenManager("test-secret", time.Hour)
+	return &testServer{t: t, router: newRouter(repository.NewPostgresRepository(db), tokens), tokens: tokens}
+}
+
+// do sends body as JSON with the bearer token, if any, and decodes a
+// successful response into out
+func (s *testServer) do(method, path, token string, body, out interface{}) int {
+	s.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if out != nil && rec.Code < 300 {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return rec.Code
+}
+
+// register signs a user up and returns their token
+func (s *testServer) register(name string) string {
+	s.t.Helper()
+	var resp struct {
+		Token string `json:"token"`
+	}
+	body := map[string]string{"username": name, "email": name + "@example.com", "password": "correct horse"}
+	if code := s.do("POST", "/api/v1/auth/register", "", body, &resp); code != http.StatusCreated {
+		s.t.Fatalf("registering %s: %d", name, code)
+	}
+	return resp.Token
+}
+
+func TestAuth(t *testing.T) {
+	s := newTestServer(t)
+	s.register("ada")
+
+	body := map[string]string{"username": "ada2", "email": "ADA@example.com", "password": "correct horse"}
+	if code := s.do("POST", "/api/v1/auth/register", "", body, nil); code != http.StatusConflict {
+		t.Errorf("registering a taken email: got %d, want 409", code)
+	}
+	if code := s.do("POST", "/api/v1/auth/register", "", map[string]string{"username": "bob", "email": "bob@example.com", "password": "short"}, nil); code != http.StatusBadRequest {
+		t.Errorf("registering with a short password: got %d, want 400", code)
+	}
+
+	var login struct {
+		Token string      `json:"token"`
+		User  models.User `json:"user"`
+	}
+	if code := s.do("POST", "/api/v1/auth/login", "", map[string]string{"email": "ada@example.com", "password": "correct horse"}, &login); code != http.StatusOK {
+		t.Fatalf("logging in: %d", code)
+	}
+	var me models.User
+	if code := s.do("GET", "/api/v1/me", login.Token, nil, &me); code != http.StatusOK || me.Username != "ada" {
+		t.Errorf("me: %d, %+v", code, me)
+	}
+
+	for _, password := range []string{"wrong password", ""} {
+		if code := s.do("POST", "/api/v1/auth/login", "", map[string]string{"email": "ada@example.com", "password": password}, nil); code == http.StatusOK {
+			t.Errorf("logging in with %q succeeded", password)
+		}
+	}
+	if code := s.do("POST", "/api/v1/auth/login", "", map[string]string{"email": "nobody@example.com", "password": "correct horse"}, nil); code != http.StatusUnauthorized {
+		t.Errorf("logging in as nobody: got %d, want 401", code)
+	}
+
+	// A token for a user that does not exist is rejected
+	ghost, _, _ := s.tokens.Issue(999)
+	for _, token := range []string{"", "garbage", ghost} {
+		if code := s.do("GET", "/api/v1/tasks", token, nil, nil); code != http.StatusUnauthorized {
+			t.Errorf("token %q: got %d, want 401", token, code)
+		}
+	}
+}
+
+func TestTaskCRUD(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob := s.register("ada"), s.register("bob")
+
+	var task models.Task
+	create := map[string]interface{}{"title": "Write report", "priority": "high", "due_date": "2025-03-10T17:00:00+02:00"}
+	if code := s.do("POST", "/api/v1/tasks", ada, create, &task); code != http.StatusCreated {
+		t.Fatalf("creating: %d", code)
+	}
+	if task.Status != models.StatusTodo || task.DueDate == nil || !task.DueDate.Equal(time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("created %+v", task)
+	}
+	path := fmt.Sprintf("/api/v1/tasks/%d", task.ID)
+
+	// PATCH changes only what is sent; null clears the due date
+	task = models.Task{}
+	if code := s.do("PATCH", path, ada, map[string]interface{}{"status": "in_progress", "due_date": nil}, &task); code != http.StatusOK {
+		t.Fatalf("patching: %d", code)
+	}
+	if task.Title != "Write report" || task.Priority != "high" || task.Status != "in_progress" || task.DueDate != nil {
+		t.Errorf("patched %+v", task)
+	}
+	// PUT replaces the task, so omitted fields fall back to the defaults
+	if code := s.do("PUT", path, ada, map[string]interface{}{"title": "Write summary"}, &task); code != http.StatusOK {
+		t.Fatalf("replacing: %d", code)
+	}
+	if task.Title != "Write summary" || task.Priority != "medium" || task.Status != "todo" {
+		t.Errorf("replaced %+v", task)
+	}
+	if code := s.do("PATCH", path, ada, map[string]interface{}{"status": "blocked"}, nil); code != http.StatusBadRequest {
+		t.Errorf("invalid status: got %d, want 400", code)
+	}
+
+	// Bob can neither see nor change Ada's task
+	for _, method := range []string{"GET", "PATCH", "PUT", "DELETE"} {
+		if code := s.do(method, path, bob, map[string]string{"title": "mine now"}, nil); code != http.StatusNotFound {
+			t.Errorf("bob %s: got %d, want 404", method, code)
+		}
+	}
+	var list struct {
+		Tasks []models.Task `json:"tasks"`
+		Total int64         `json:"total"`
+	}
+	if code := s.do("GET", "/api/v1/tasks", bob, nil, &list); code != http.StatusOK || list.Total != 0 {
+		t.Errorf("bob's list: %d, %+v", code, list)
+	}
+
+	if code := s.do("DELETE", path, ada, nil, nil); code != http.StatusNoContent {
+		t.Errorf("deleting: %d", code)
+	}
+	if code := s.do("GET", path, ada, nil, nil); code != http.StatusNotFound {
+		t.Errorf("getting a deleted task: got %d, want 404", code)
+	}
+}
+
+func TestListTasksQuery(t *testing.T) {
+	s := newTestServer(t)
+	ada := s.register("ada")
+	for i, task := range []map[string]interface{}{
+		{"title": "a", "priority": "low", "due_date": "2025-03-01T09:00:00Z"},
+		{"title": "b", "priority": "high", "status": "done", "due_date": "2025-03-02T23:30:00Z"},
+		{"title": "c", "priority": "high"},
+	} {
+		if code := s.do("POST", "/api/v1/tasks", ada, task, nil); code != http.StatusCreated {
+			t.Fatalf("creating task %d: %d", i, code)
+		}
+	}
+
+	tests := map[string]string{
+		"?sort=title":              "[a b c]",
+		"?status=todo&sort=-title": "[c a]",
+		"?priority=high,low&status=todo,done&sort=title":   "[a b c]",
+		"?priority=high&priority=low&sort=-priority,title": "[b c a]",
+		// A date as the upper bound includes the whole day
+		"?due_from=2025-03-02&due_to=2025-03-02": "[b]",
+		"?due_to=2025-03-01T12:00:00%2B02:00":    "[a]",
+		"?sort=title&page=2&page_size=2":         "[c]",
+	}
+	for query, want := range tests {
+		var list struct {
+			Tasks []models.Task `json:"tasks"`
+		}
+		if code := s.do("GET", "/api/v1/tasks"+query, ada, nil, &list); code != http.StatusOK {
+			t.Errorf("%s: got %d", query, code)
+			continue
+		}
+		var got []string
+		for _, task := range list.Tasks {
+			got = append(got, task.Title)
+		}
+		if fmt.Sprint(got) != want {
+			t.Errorf("%s: got %v, want %s", query, got, want)
+		}
+	}
+
+	for _, query := range []string{"?status=blocked", "?sort=password", "?due_from=yesterday", "?page=0", "?page_size=1000", "?due_from=2025-03-02&due_to=2025-03-01"} {
+		if code := s.do("GET", "/api/v1/tasks"+query, ada, nil, nil); code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", query, code)
+		}
+	}
+}
+
+func TestProjects(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob, cy := s.register("ada"), s.register("bob"), s.register("cyd")
+	var bobUser models.User
+	s.do("GET", "/api/v1/me", bob, nil, &bobUser)
+
+	var project models.Project
+	if code := s.do("POST", "/api/v1/projects", ada, map[string]string{"name": "Launch"}, &project); code != http.StatusCreated {
+		t.Fatalf("creating project: %d", code)
+	}
+	base := fmt.Sprintf("/api/v1/projects/%d", project.ID)
+	if code := s.do("POST", base+"/members", ada, map[string]interface{}{"user_id": bobUser.ID, "role": "viewer"}, nil); code != http.StatusCreated {
+		t.Fatalf("adding bob: %d", code)
+	}
+	if code := s.do("POST", base+"/members", ada, map[string]interface{}{"user_id": bobUser.ID, "role": "king"}, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("unknown role: got %d, want 422", code)
+	}
+
+	var task models.Task
+	if code := s.do("POST", "/api/v1/tasks", ada, map[string]interface{}{"title": "Plan", "project_id": project.ID, "assignee_id": bobUser.ID}, &task); code != http.StatusCreated {
+		t.Fatalf("creating project task: %d", code)
+	}
+	taskPath := fmt.Sprintf("/api/v1/tasks/%d", task.ID)
+
+	// Bob can read as a viewer but not change anything; Cy cannot see it
+	if code := s.do("GET", taskPath, bob, nil, nil); code != http.StatusOK {
+		t.Errorf("viewer reading: %d", code)
+	}
+	if code := s.do("PATCH", taskPath, bob, map[string]string{"status": "done"}, nil); code != http.StatusForbidden {
+		t.Errorf("viewer patching: got %d, want 403", code)
+	}
+	if code := s.do("POST", "/api/v1/tasks", cy, map[string]interface{}{"title": "Sneak", "project_id": project.ID}, nil); code != http.StatusNotFound {
+		t.Errorf("outsider creating in project: got %d, want 404", code)
+	}
+	for _, path := range []string{taskPath, taskPath + "/comments", base, base + "/activity"} {
+		if code := s.do("GET", path, cy, nil, nil); code != http.StatusNotFound {
+			t.Errorf("outsider GET %s: got %d, want 404", path, code)
+		}
+	}
+
+	var label models.Label
+	if code := s.do("POST", base+"/labels", ada, map[string]string{"name": "urgent", "color": "#ff0000"}, &label); code != http.StatusCreated {
+		t.Fatalf("creating label: %d", code)
+	}
+	if code := s.do("PUT", taskPath+"/labels", ada, map[string]interface{}{"label_ids": []uint{label.ID}}, nil); code != http.StatusOK {
+		t.Errorf("labelling: %d", code)
+	}
+
+	var comment models.Comment
+	if code := s.do("POST", taskPath+"/comments", ada, map[string]string{"body": "@bob can you check?"}, &comment); code != http.StatusCreated {
+		t.Fatalf("commenting: %d", code)
+	}
+	if len(comment.Mentions) != 1 || comment.Mentions[0].ID != bobUser.ID {
+		t.Errorf("mentions = %+v", comment.Mentions)
+	}
+
+	var list struct {
+		Tasks []models.Task `json:"tasks"`
+	}
+	query := fmt.Sprintf("?project_id=%d&assignee_id=%d&label_id=%d", project.ID, bobUser.ID, label.ID)
+	if code := s.do("GET", "/api/v1/tasks"+query, bob, nil, &list); code != http.StatusOK || len(list.Tasks) != 1 {
+		t.Errorf("filtering: %d, %+v", code, list)
+	}
+
+	var feed []models.Activity
+	if code := s.do("GET", base+"/activity", bob, nil, &feed); code != http.StatusOK || len(feed) == 0 || feed[0].Action != models.ActivityCommentAdded {
+		t.Errorf("activity: %d, %+v", code, feed)
+	}
+
+	var adaUser models.User
+	s.do("GET", "/api/v1/me", ada, nil, &adaUser)
+	if code := s.do("DELETE", fmt.Sprintf("%s/members/%d", base, adaUser.ID), ada, nil, nil); code != http.StatusConflict {
+		t.Errorf("last owner leaving: got %d, want 409", code)
+	}
+	if code := s.do("DELETE", base, bob, nil, nil); code != http.StatusForbidden {
+		t.Errorf("viewer deleting project: got %d, want 403", code)
+	}
+	if code := s.do("DELETE", base, ada, nil, nil); code != http.StatusNoContent {
+		t.Errorf("deleting project: %d", code)
+	}
+	if code := s.do("GET", taskPath, ada, nil, nil); code != http.StatusNotFound {
+		t.Errorf("task outlived its project: %d", code)
+	}
+}
+
+func TestDependenciesAndBoard(t *testing.T) {
+	s := newTestServer(t)
+	ada := s.register("ada")
+	create := func(title string, extra map[string]interface{}) models.Task {
+		t.Helper()
+		body := map[string]interface{}{"title": title}
+		for k, v := range extra {
+			body[k] = v
+		}
+		var task models.Task
+		if code := s.do("POST", "/api/v1/tasks", ada, body, &task); code != http.StatusCreated {
+			t.Fatalf("creating %s: %d", title, code)
+		}
+		return task
+	}
+	design, build := create("Design", nil), create("Build", nil)
+	sub := create("Sketch", map[string]interface{}{"parent_id": design.ID})
+	path := func(task models.Task, suffix string) string {
+		return fmt.Sprintf("/api/v1/tasks/%d%s", task.ID, suffix)
+	}
+
+	if code := s.do("POST", path(build, "/dependencies"), ada, map[string]uint{"blocked_by_id": design.ID}, nil); code != http.StatusCreated {
+		t.Fatalf("adding dependency: %d", code)
+	}
+	if code := s.do("POST", path(design, "/dependencies"), ada, map[string]uint{"blocked_by_id": build.ID}, nil); code != http.StatusConflict {
+		t.Errorf("cycle: got %d, want 409", code)
+	}
+	if code := s.do("PATCH", path(build, ""), ada, map[string]string{"status": "done"}, nil); code != http.StatusConflict {
+		t.Errorf("finishing a blocked task: got %d, want 409", code)
+	}
+
+	var list struct {
+		Tasks []models.Task `json:"tasks"`
+	}
+	if code := s.do("GET", "/api/v1/tasks?ready=true&sort=title", ada, nil, &list); code != http.StatusOK || len(list.Tasks) != 2 || list.Tasks[0].Title != "Design" {
+		t.Errorf("ready: %d, %+v", code, list.Tasks)
+	}
+
+	var moved models.Task
+	if code := s.do("POST", path(sub, "/move"), ada, map[string]interface{}{"status": "done"}, &moved); code != http.StatusOK || moved.Status != "done" {
+		t.Errorf("moving: %d, %+v", code, moved)
+	}
+	var parent models.Task
+	if code := s.do("GET", path(design, ""), ada, nil, &parent); code != http.StatusOK || parent.Progress == nil || parent.Progress.Percent != 100 {
+		t.Errorf("progress: %d, %+v", code, parent.Progress)
+	}
+	if code := s.do("POST", path(build, "/move"), ada, map[string]interface{}{"after_id": sub.ID}, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("moving next to a card in another column: got %d, want 422", code)
+	}
+
+	if code := s.do("DELETE", path(build, fmt.Sprintf("/dependencies/%d", design.ID)), ada, nil, nil); code != http.StatusNoContent {
+		t.Errorf("removing dependency: %d", code)
+	}
+}
+
+func TestRemindersAndCalendar(t *testing.T) {
+	s := newTestServer(t)
+	ada := s.register("ada")
+	due := time.Now().Add(72 * time.Hour).UTC().Truncate(time.Second)
+
+	var task models.Task
+	body := map[string]interface{}{"title": "Renew passport", "due_date": due, "recurrence": "FREQ=YEARLY"}
+	if code := s.do("POST", "/api/v1/tasks", ada, body, &task); code != http.StatusCreated {
+		t.Fatalf("creating task: %d", code)
+	}
+	path := fmt.Sprintf("/api/v1/tasks/%d/reminders", task.ID)
+	if code := s.do("PUT", path, ada, map[string][]string{"before": {"soon"}}, nil); code != http.StatusBadRequest {
+		t.Errorf("bad reminder: got %d, want 400", code)
+	}
+	var reminders []models.Reminder
+	if code := s.do("PUT", path, ada, map[string][]string{"before": {"1d", "30m"}}, &reminders); code != http.StatusOK || len(reminders) != 2 {
+		t.Errorf("setting reminders: %d, %+v", code, reminders)
+	}
+	if code := s.do("POST", "/api/v1/tasks", ada, map[string]string{"title": "x", "recurrence": "FREQ=DAILY"}, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("recurring without due date: got %d, want 422", code)
+	}
+
+	var feed struct {
+		Token string `json:"token"`
+		URL   string `json:"url"`
+	}
+	if code := s.do("POST", "/api/v1/me/calendar", ada, nil, &feed); code != http.StatusCreated || feed.Token == "" {
+		t.Fatalf("issuing calendar token: %d", code)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest("GET", "/calendar/"+feed.Token+".ics", nil))
+	ics := rec.Body.String()
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/calendar; charset=utf-8" {
+		t.Fatalf("feed: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{"BEGIN:VCALENDAR", "SUMMARY:Renew passport", "DTSTART:" + due.Format("20060102T150405Z"), "TRIGGER:-P1D", "TRIGGER:-PT30M"} {
+		if !bytes.Contains(rec.Body.Bytes(), []byte(want)) {
+			t.Errorf("feed is missing %q:\n%s", want, ics)
+		}
+	}
+
+	if code := s.do("DELETE", "/api/v1/me/calendar", ada, nil, nil); code != http.StatusNoContent {
+		t.Errorf("revoking: %d", code)
+	}
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest("GET", "/calendar/"+feed.Token+".ics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("revoked feed: got %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/task-management-api/internal/auth"
+	"github.com/task-management-api/internal/middleware"
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/repository"
+)
+
+type AuthHandler struct {
+	repo   repository.Repository
+	tokens *auth.TokenManager
+}
+
+func NewAuthHandler(repo repository.Repository, tokens *auth.TokenManager) *AuthHandler {
+	return &AuthHandler{
+		repo:   repo,
+		tokens: tokens,
+	}
+}
+
+type RegisterRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+// TokenResponse is returned on registration and login
+type TokenResponse struct {
+	Token     string       `json:"token"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	User      *models.User `json:"user"`
+}
+
+// Register handles creating an account; the new user is logged in
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := models.User{
+		Username: strings.TrimSpace(req.Username),
+		Email:    req.Email,
+		Password: req.Password,
+	}
+	if err := user.HashPassword(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+	err := h.repo.CreateUser(&user)
+	if errors.Is(err, repository.ErrDuplicate) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username or email already registered"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	h.respondWithToken(c, http.StatusCreated, &user)
+}
+
+// Login handles exchanging an email and password for a token
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.repo.GetUserByEmail(req.Email)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		return
+	}
+	// The same answer for an unknown email and a wrong password, so that
+	// accounts cannot be discovered
+	if user == nil || user.CheckPassword(req.Password) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		return
+	}
+
+	h.respondWithToken(c, http.StatusOK, user)
+}
+
+// Me handles getting the logged-in user
+func (h *AuthHandler) Me(c *gin.Context) {
+	c.JSON(http.StatusOK, middleware.CurrentUser(c))
+}
+
+func (h *AuthHandler) respondWithToken(c *gin.Context, status int, user *models.User) {
+	token, expires, err := h.tokens.Issue(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue token"})
+		return
+	}
+	c.JSON(status, TokenResponse{Token: token, ExpiresAt: expires, User: user})
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/task-management-api/internal/ical"
+	"github.com/task-management-api/internal/middleware"
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/service"
+)
+
+type CalendarHandler struct {
+	service *service.Service
+}
+
+func NewCalendarHandler(service *service.Service) *CalendarHandler {
+	return &CalendarHandler{
+		service: service,
+	}
+}
+
+// CalendarToken is returned when a calendar feed is turned on. The token
+// is shown only this once.
+type CalendarToken struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// IssueToken handles turning the user's calendar feed on, or giving it a
+// new URL; the old URL stops working
+func (h *CalendarHandler) IssueToken(c *gin.Context) {
+	token, err := h.service.IssueCalendarToken(middleware.CurrentUser(c).ID)
+	if err != nil {
+		serviceError(c, err, "User", "Failed to create calendar feed")
+		return
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	url := fmt.Sprintf("%s://%s/calendar/%s.ics", scheme, c.Request.Host, token)
+	c.JSON(http.StatusCreated, CalendarToken{Token: token, URL: url})
+}
+
+// RevokeToken handles turning the user's calendar feed off
+func (h *CalendarHandler) RevokeToken(c *gin.Context) {
+	if err := h.service.RevokeCalendarToken(middleware.CurrentUser(c).ID); err != nil {
+		serviceError(c, err, "User", "Failed to remove calendar feed")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Feed handles GET /calendar/:token.ics, which calendar apps poll without
+// logging in; the token in the URL is the credential. Each unfinished task
+// the user has with a due date becomes an event, with its reminders as
+// alarms.
+func (h *CalendarHandler) Feed(c *gin.Context) {
+	token, ok := strings.CutSuffix(c.Param("token"), ".ics")
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+
+	user, tasks, err := h.service.CalendarFeed(token)
+	if err != nil {
+		serviceError(c, err, "Calendar", "Failed to build calendar")
+		return
+	}
+
+	cal := ical.Calendar{
+		ProdID: "-//task-management-api//tasks//EN",
+		Name:   user.Username + "'s tasks",
+	}
+	for _, task := range tasks {
+		cal.Events = append(cal.Events, taskEvent(c.Request.Host, task))
+	}
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", cal.Marshal())
+}
+
+// taskEvent turns a task with a due date into a calendar event
+func taskEvent(host string, task models.Task) ical.Event {
+	event := ical.Event{
+		UID:         fmt.Sprintf("task-%d@%s", task.ID, host),
+		Summary:     task.Title,
+		Description: task.Description,
+		Start:       *task.DueDate,
+		Stamp:       task.UpdatedAt,
+	}
+	for _, reminder := range task.Reminders {
+		event.Alarms = append(event.Alarms, time.Duration(reminder.BeforeMinutes)*time.Minute)
+	}
+	return event
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/task-management-api/internal/middleware"
+	"github.com/task-management-api/internal/service"
+)
+
+type CommentHandler struct {
+	service *service.Service
+}
+
+func NewCommentHandler(service *service.Service) *CommentHandler {
+	return &CommentHandler{
+		service: service,
+	}
+}
+
+// CommentRequest is the body for commenting on a task. ParentID makes the
+// comment a reply.
+type CommentRequest struct {
+	Body     string `json:"body" binding:"required,max=10000"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+// ListComments handles listing a task's comment threads
+func (h *CommentHandler) ListComments(c *gin.Context) {
+	id, ok := idParam(c, "id", "task")
+	if !ok {
+		return
+	}
+
+	comments, err := h.service.ListComments(middleware.CurrentUser(c).ID, id)
+	if err != nil {
+		serviceError(c, err, "Task", "Failed to fetch comments")
+		return
+	}
+
+	c.JSON(http.StatusOK, comments)
+}
+
+// AddComment handles commenting on a task or replying to a comment.
+// @username mentions are resolved to users who can see the task.
+func (h *CommentHandler) AddComment(c *gin.Context) {
+	id, ok := idParam(c, "id", "task")
+	if !ok {
+		return
+	}
+	var req CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "body must not be blank"})
+		return
+	}
+
+	comment, err := h.service.AddComment(middleware.CurrentUser(c).ID, id, req.ParentID, body)
+	if err != nil {
+		serviceError(c, err, "Task", "Failed to add comment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
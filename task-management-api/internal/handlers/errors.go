@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/task-management-api/internal/recurrence"
+	"github.com/task-management-api/internal/repository"
+	"github.com/task-management-api/internal/service"
+)
+
+// serviceError responds to an error from the service. Missing records are
+// reported as "<resource> not found" and unexpected errors as failure.
+func serviceError(c *gin.Context, err error, resource, failure string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": resource + " not found"})
+	case errors.Is(err, repository.ErrDuplicate):
+		c.JSON(http.StatusConflict, gin.H{"error": resource + " already exists"})
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrLastOwner),
+		errors.Is(err, service.ErrDependencyCycle),
+		errors.Is(err, service.ErrBlocked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNotMember),
+		errors.Is(err, service.ErrInvalidRole),
+		errors.Is(err, service.ErrInvalidLabel),
+		errors.Is(err, service.ErrInvalidParent),
+		errors.Is(err, service.ErrInvalidSubtask),
+		errors.Is(err, service.ErrInvalidDependency),
+		errors.Is(err, service.ErrInvalidPosition),
+		errors.Is(err, service.ErrNoDueDate),
+		errors.Is(err, recurrence.ErrInvalidRule):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+	}
+}
+
+// idParam reads a numeric ID from the URL
+func idParam(c *gin.Context, name, what string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + what + " ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/task-management-api/internal/middleware"
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/service"
+)
+
+type ProjectHandler struct {
+	service *service.Service
+}
+
+func NewProjectHandler(service *service.Service) *ProjectHandler {
+	return &ProjectHandler{
+		service: service,
+	}
+}
+
+// ProjectRequest is the body for creating or updating a project
+type ProjectRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description"`
+}
+
+// MemberRequest is the body for adding a member or changing their role
+type MemberRequest struct {
+	UserID uint        `json:"user_id"`
+	Role   models.Role `json:"role" binding:"required"`
+}
+
+// LabelRequest is the body for creating a label
+type LabelRequest struct {
+	Name  string `json:"name" binding:"required,max=50"`
+	Color string `json:"color" binding:"omitempty,hexcolor"`
+}
+
+// CreateProject handles creating a project owned by the user
+func (h *ProjectHandler) CreateProject(c *gin.Context) {
+	var req ProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project := models.Project{Name: strings.TrimSpace(req.Name), Description: req.Description}
+	if err := h.service.CreateProject(middleware.CurrentUser(c).ID, &project); err != nil {
+		serviceError(c, err, "Project", "Failed to create project")
+		return
+	}
+
+	c.JSON(http.StatusCreated, project)
+}
+
+// ListProjects handles listing the projects the user is a member of
+func (h *ProjectHandler) ListProjects(c *gin.Context) {
+	projects, err := h.service.ListProjects(middleware.CurrentUser(c).ID)
+	if err != nil {
+		serviceError(c, err, "Project", "Failed to fetch projects")
+		return
+	}
+
+	c.JSON(http.StatusOK, projects)
+}
+
+// GetProject handles getting a single project
+func (h *ProjectHandler) GetProject(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+// UpdateProject handles renaming a project or changing its description
+func (h *ProjectHandler) UpdateProject(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	req := ProjectRequest{Name: project.Name, Description: project.Description}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	project.Name = strings.TrimSpace(req.Name)
+	project.Description = req.Description
+
+	if err := h.service.UpdateProject(middleware.CurrentUser(c).ID, project); err != nil {
+		serviceError(c, err, "Project", "Failed to update project")
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+// DeleteProject handles deleting a project and everything in it
+func (h *ProjectHandler) DeleteProject(c *gin.Context) {
+	id, ok := idParam(c, "id", "project")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteProject(middleware.CurrentUser(c).ID, id); err != nil {
+		serviceError(c, err, "Project", "Failed to delete project")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListMembers handles listing a project's members
+func (h *ProjectHandler) ListMembers(c *gin.Context) {
+	id, ok := idParam(c, "id", "project")
+	if !ok {
+		return
+	}
+
+	members, err := h.service.ListMembers(middleware.CurrentUser(c).ID, id)
+	if err != nil {
+		serviceError(c, err, "Project", "Failed to fetch members")
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// AddMember handles adding a user to a project
+func (h *ProjectHandler) AddMember(c *gin.Context) {
+	id, ok := idParam(c, "id", "project")
+	if !ok {
+		return
+	}
+	var req MemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.UserID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+		return
+	}
+
+	member, err := h.service.AddMember(middleware.CurrentUser(c).ID, id, req.UserID, req.Role)
+	if err != nil {
+		serviceError(c, err, "Member", "Failed to add member")
+		return
+	}
+
+	c.JSON(http.StatusCreated, member)
+}
+
+// UpdateMember handles changing a member's role
+func (h *ProjectHandler) UpdateMember(c *gin.Context) {
+	id, ok := idParam(c, "id", "project")
+	if !ok {
+		return
+	}
+	userID, ok := idParam(c, "userID", "user")
+	if !ok {
+		return
+	}
+	var req MemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member, err := h.service.UpdateMember(middleware.CurrentUser(c).ID, id, userID, req.Role)
+	if err != nil {
+		serviceError(c, err, "Member", "Failed to update member")
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveMember handles removing a member, or a member leaving
+func (h *ProjectHandler) RemoveMember(c *gin.Context) {
+	id, ok := idParam(c, "id", "project")
+	if !ok {
+		return
+	}
+	userID, ok := idParam(c, "userID", "user")
+	if !ok {
+		return
+	}
+
+	if err := h.service.RemoveMember(middleware.CurrentUser(c).ID, id, userID); err != nil {
+		serviceError(c, err, "Member", "Failed to remove member")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListLabels handles listing a project's labels
+func (h *ProjectHandler) ListLabels(c *gin.Context) {
+	id, ok := idParam(c, "id", "project")
+	if !ok {
+		return
+	}
+
+	labels, err := h.service.ListLabels(middleware.CurrentUser(c).ID, id)
+	if err != nil {
+		serviceError(c, err, "Project", "Failed to fetch labels")
+		return
+	}
+
+	c.JSON(http.StatusOK, labels)
+}
+
+// CreateLabel handles adding a label to a project
+func (h *ProjectHandler) CreateLabel(c *gin.Context) {
+	id, ok := idParam(c, "id", "project")
+	if !ok {
+		return
+	}
+	var req LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	label := models.Label{ProjectID: id, Name: strings.TrimSpace(req.Name), Color: req.Color}
+	if err := h.service.CreateLabel(middleware.CurrentUser(c).ID, &label); err != nil {
+		serviceError(c, err, "Label", "Failed to create label")
+		return
+	}
+
+	c.JSON(http.StatusCreated, label)
+}
+
+// DeleteLabel handles deleting a label
+func (h *ProjectHandler) DeleteLabel(c *gin.Context) {
+	id, ok := idParam(c, "id", "project")
+	if !ok {
+		return
+	}
+	labelID, ok := idParam(c, "labelID", "label")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteLabel(middleware.CurrentUser(c).ID, id, labelID); err != nil {
+		serviceError(c, err, "Label", "Failed to delete label")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListActivity handles a project's activity feed, newest first, paged
+// with ?limit= and ?offset=
+func (h *ProjectHandler) ListActivity(c *gin.Context) {
+	id, ok := idParam(c, "id", "project")
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must not be negative"})
+		return
+	}
+
+	activity, err := h.service.ListActivity(middleware.CurrentUser(c).ID, id, limit, offset)
+	if err != nil {
+		serviceError(c, err, "Project", "Failed to fetch activity")
+		return
+	}
+
+	c.JSON(http.StatusOK, activity)
+}
+
+// loadProject loads the project named in the URL. Projects the user is
+// not a member of are reported as not found.
+func (h *ProjectHandler) loadProject(c *gin.Context) (*models.Project, bool) {
+	id, ok := idParam(c, "id", "project")
+	if !ok {
+		return nil, false
+	}
+
+	project, err := h.service.GetProject(middleware.CurrentUser(c).ID, id)
+	if err != nil {
+		serviceError(c, err, "Project", "Failed to fetch project")
+		return nil, false
+	}
+	return project, true
+}
@@ -1,37 +1,123 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/task-management-api/internal/middleware"
 	"github.com/task-management-api/internal/models"
 	"github.com/task-management-api/internal/repository"
+	"github.com/task-management-api/internal/service"
 )
 
 type TaskHandler struct {
-	repo repository.Repository
+	service *service.Service
 }
 
-func NewTaskHandler(repo repository.Repository) *TaskHandler {
+func NewTaskHandler(service *service.Service) *TaskHandler {
 	return &TaskHandler{
-		repo: repo,
+		service: service,
 	}
 }
 
+// TaskRequest is the body for creating or updating a task. Status
+// defaults to todo and priority to medium. ProjectID is only read on
+// creation; a task stays in the project it was created in. ParentID makes
+// the task a subtask. Recurrence is an iCalendar RRULE such as
+// "FREQ=WEEKLY;BYDAY=MO"; weekly and monthly rules keep to the weekday or
+// day of the month of the due date they are set with.
+type TaskRequest struct {
+	Title       string     `json:"title" binding:"required,max=200"`
+	Description string     `json:"description"`
+	Status      string     `json:"status" binding:"omitempty,oneof=todo in_progress done"`
+	Priority    string     `json:"priority" binding:"omitempty,oneof=low medium high"`
+	DueDate     *time.Time `json:"due_date"`
+	ProjectID   *uint      `json:"project_id"`
+	AssigneeID  *uint      `json:"assignee_id"`
+	ParentID    *uint      `json:"parent_id"`
+	Recurrence  string     `json:"recurrence" binding:"max=200"`
+}
+
+// MoveRequest is the body for moving a task on the Kanban board. The task
+// goes between AfterID and BeforeID, next to whichever is given, or at
+// the bottom of the column without either.
+type MoveRequest struct {
+	Status   string `json:"status" binding:"omitempty,oneof=todo in_progress done"`
+	AfterID  *uint  `json:"after_id"`
+	BeforeID *uint  `json:"before_id"`
+}
+
+// RemindersRequest is the body for setting a task's reminders, each a
+// time before it is due such as "1d", "2h" or "30m"
+type RemindersRequest struct {
+	Before []string `json:"before" binding:"max=10"`
+}
+
+// DependencyRequest is the body for making a task wait on another
+type DependencyRequest struct {
+	BlockedByID uint `json:"blocked_by_id" binding:"required"`
+}
+
+// Dependencies lists the tasks a task waits on and those waiting on it
+type Dependencies struct {
+	BlockedBy []models.Task `json:"blocked_by"`
+	Blocking  []models.Task `json:"blocking"`
+}
+
+// LabelsRequest is the body for setting a task's labels
+type LabelsRequest struct {
+	LabelIDs []uint `json:"label_ids" binding:"required"`
+}
+
+// TaskList is a page of tasks
+type TaskList struct {
+	Tasks    []models.Task `json:"tasks"`
+	Total    int64         `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+}
+
+// apply copies the request onto task
+func (r *TaskRequest) apply(task *models.Task) {
+	task.Title = strings.TrimSpace(r.Title)
+	task.Description = r.Description
+	task.Status = r.Status
+	if task.Status == "" {
+		task.Status = models.StatusTodo
+	}
+	task.Priority = r.Priority
+	if task.Priority == "" {
+		task.Priority = models.PriorityMedium
+	}
+	task.DueDate = nil
+	if r.DueDate != nil {
+		due := r.DueDate.UTC()
+		task.DueDate = &due
+	}
+	task.AssigneeID = r.AssigneeID
+	task.ParentID = r.ParentID
+	task.Recurrence = strings.TrimSpace(r.Recurrence)
+}
+
 // CreateTask handles task creation
 func (h *TaskHandler) CreateTask(c *gin.Context) {
-	var task models.Task
-	if err := c.ShouldBindJSON(&task); err != nil {
+	var req TaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// TODO: Get userID from JWT token
-	task.UserID = 1 // Temporary
+	task := models.Task{ProjectID: req.ProjectID}
+	req.apply(&task)
 
-	if err := h.repo.CreateTask(&task); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task"})
+	if err := h.service.CreateTask(middleware.CurrentUser(c).ID, &task); err != nil {
+		serviceError(c, err, "Project", "Failed to create task")
 		return
 	}
 
@@ -40,31 +126,382 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 
 // GetTask handles getting a single task
 func (h *TaskHandler) GetTask(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	task, ok := h.loadTask(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// UpdateTask handles PUT, which replaces a task, and PATCH, which only
+// changes the fields sent; a null due_date, assignee_id or parent_id
+// clears it
+func (h *TaskHandler) UpdateTask(c *gin.Context) {
+	task, ok := h.loadTask(c)
+	if !ok {
+		return
+	}
+
+	var req TaskRequest
+	if c.Request.Method == http.MethodPatch {
+		req = TaskRequest{
+			Title:       task.Title,
+			Description: task.Description,
+			Status:      task.Status,
+			Priority:    task.Priority,
+			DueDate:     task.DueDate,
+			AssigneeID:  task.AssigneeID,
+			ParentID:    task.ParentID,
+			Recurrence:  task.Recurrence,
+		}
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.apply(task)
+
+	if err := h.service.UpdateTask(middleware.CurrentUser(c).ID, task); err != nil {
+		serviceError(c, err, "Task", "Failed to update task")
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// DeleteTask handles deleting a task
+func (h *TaskHandler) DeleteTask(c *gin.Context) {
+	id, ok := idParam(c, "id", "task")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteTask(middleware.CurrentUser(c).ID, id); err != nil {
+		serviceError(c, err, "Task", "Failed to delete task")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// MoveTask handles moving a task within or between Kanban columns
+func (h *TaskHandler) MoveTask(c *gin.Context) {
+	id, ok := idParam(c, "id", "task")
+	if !ok {
+		return
+	}
+	var req MoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.service.MoveTask(middleware.CurrentUser(c).ID, id, req.Status, req.AfterID, req.BeforeID)
+	if err != nil {
+		serviceError(c, err, "Task", "Failed to move task")
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// ListReminders handles listing a task's reminders
+func (h *TaskHandler) ListReminders(c *gin.Context) {
+	id, ok := idParam(c, "id", "task")
+	if !ok {
+		return
+	}
+
+	reminders, err := h.service.ListReminders(middleware.CurrentUser(c).ID, id)
+	if err != nil {
+		serviceError(c, err, "Task", "Failed to fetch reminders")
+		return
+	}
+
+	c.JSON(http.StatusOK, reminders)
+}
+
+// SetReminders handles replacing a task's reminders
+func (h *TaskHandler) SetReminders(c *gin.Context) {
+	id, ok := idParam(c, "id", "task")
+	if !ok {
+		return
+	}
+	var req RemindersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	before := make([]time.Duration, len(req.Before))
+	for i, v := range req.Before {
+		d, err := parseBefore(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		before[i] = d
+	}
+
+	reminders, err := h.service.SetReminders(middleware.CurrentUser(c).ID, id, before)
+	if err != nil {
+		serviceError(c, err, "Task", "Failed to set reminders")
+		return
+	}
+
+	c.JSON(http.StatusOK, reminders)
+}
+
+// ListDependencies handles listing what a task waits on and what waits on
+// it
+func (h *TaskHandler) ListDependencies(c *gin.Context) {
+	id, ok := idParam(c, "id", "task")
+	if !ok {
+		return
+	}
+
+	blockedBy, blocking, err := h.service.Dependencies(middleware.CurrentUser(c).ID, id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		serviceError(c, err, "Task", "Failed to fetch dependencies")
 		return
 	}
 
-	task, err := h.repo.GetTaskByID(uint(id))
+	c.JSON(http.StatusOK, Dependencies{BlockedBy: blockedBy, Blocking: blocking})
+}
+
+// AddDependency handles making a task wait on another
+func (h *TaskHandler) AddDependency(c *gin.Context) {
+	id, ok := idParam(c, "id", "task")
+	if !ok {
+		return
+	}
+	var req DependencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dep, err := h.service.AddDependency(middleware.CurrentUser(c).ID, id, req.BlockedByID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		serviceError(c, err, "Dependency", "Failed to add dependency")
+		return
+	}
+
+	c.JSON(http.StatusCreated, dep)
+}
+
+// RemoveDependency handles a task no longer waiting on another
+func (h *TaskHandler) RemoveDependency(c *gin.Context) {
+	id, ok := idParam(c, "id", "task")
+	if !ok {
+		return
+	}
+	blockerID, ok := idParam(c, "blockerID", "task")
+	if !ok {
+		return
+	}
+
+	if err := h.service.RemoveDependency(middleware.CurrentUser(c).ID, id, blockerID); err != nil {
+		serviceError(c, err, "Dependency", "Failed to remove dependency")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SetLabels handles replacing a task's labels
+func (h *TaskHandler) SetLabels(c *gin.Context) {
+	id, ok := idParam(c, "id", "task")
+	if !ok {
+		return
+	}
+	var req LabelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.service.SetTaskLabels(middleware.CurrentUser(c).ID, id, req.LabelIDs)
+	if err != nil {
+		serviceError(c, err, "Task", "Failed to set labels")
 		return
 	}
 
 	c.JSON(http.StatusOK, task)
 }
 
-// ListTasks handles listing all tasks for a user
+// ListTasks handles listing the tasks the user can see. Query parameters:
+//
+//	project_id           only tasks in this project
+//	assignee_id          only tasks assigned to this user
+//	label_id             only tasks with this label
+//	parent_id            only subtasks of this task
+//	ready                "true" for tasks to do whose blockers are all done
+//	status, priority     comma-separated values to match, e.g. status=todo,in_progress
+//	due_from, due_to     due date bounds, inclusive, as RFC 3339 or YYYY-MM-DD
+//	sort                 comma-separated fields, "-" for descending, e.g. sort=-priority,due_date;
+//	                     sort=rank gives the Kanban board order
+//	page, page_size      1-based page number and page size (default 20, at most 100)
 func (h *TaskHandler) ListTasks(c *gin.Context) {
-	// TODO: Get userID from JWT token
-	userID := uint(1) // Temporary
+	filter, err := parseTaskFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	tasks, err := h.repo.ListTasks(userID)
+	tasks, total, err := h.service.ListTasks(middleware.CurrentUser(c).ID, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
+		serviceError(c, err, "Project", "Failed to fetch tasks")
 		return
 	}
 
-	c.JSON(http.StatusOK, tasks)
+	c.JSON(http.StatusOK, TaskList{Tasks: tasks, Total: total, Page: filter.Page, PageSize: filter.PageSize})
+}
+
+// loadTask loads the task named in the URL. Tasks the user cannot see are
+// reported as not found.
+func (h *TaskHandler) loadTask(c *gin.Context) (*models.Task, bool) {
+	id, ok := idParam(c, "id", "task")
+	if !ok {
+		return nil, false
+	}
+
+	task, err := h.service.GetTask(middleware.CurrentUser(c).ID, id)
+	if err != nil {
+		serviceError(c, err, "Task", "Failed to fetch task")
+		return nil, false
+	}
+	return task, true
+}
+
+func parseTaskFilter(c *gin.Context) (repository.TaskFilter, error) {
+	filter := repository.TaskFilter{Page: 1, PageSize: repository.DefaultPageSize}
+
+	var err error
+	if filter.ProjectID, err = parseID(c, "project_id"); err != nil {
+		return filter, err
+	}
+	if filter.AssigneeID, err = parseID(c, "assignee_id"); err != nil {
+		return filter, err
+	}
+	if filter.LabelID, err = parseID(c, "label_id"); err != nil {
+		return filter, err
+	}
+	if filter.ParentID, err = parseID(c, "parent_id"); err != nil {
+		return filter, err
+	}
+	if v := c.Query("ready"); v != "" {
+		if filter.Ready, err = strconv.ParseBool(v); err != nil {
+			return filter, errors.New("ready must be true or false")
+		}
+	}
+	if filter.Statuses, err = parseList(c, "status", models.StatusTodo, models.StatusInProgress, models.StatusDone); err != nil {
+		return filter, err
+	}
+	if filter.Priorities, err = parseList(c, "priority", models.PriorityLow, models.PriorityMedium, models.PriorityHigh); err != nil {
+		return filter, err
+	}
+	if filter.DueFrom, err = parseDue(c.Query("due_from"), false); err != nil {
+		return filter, fmt.Errorf("due_from: %w", err)
+	}
+	if filter.DueTo, err = parseDue(c.Query("due_to"), true); err != nil {
+		return filter, fmt.Errorf("due_to: %w", err)
+	}
+	if filter.DueFrom != nil && filter.DueTo != nil && filter.DueTo.Before(*filter.DueFrom) {
+		return filter, errors.New("due_to is before due_from")
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		for _, field := range strings.Split(sort, ",") {
+			if !repository.ValidSortField(field) {
+				return filter, fmt.Errorf("cannot sort by %q", field)
+			}
+			filter.Sort = append(filter.Sort, field)
+		}
+	}
+
+	if v := c.Query("page"); v != "" {
+		if filter.Page, err = strconv.Atoi(v); err != nil || filter.Page < 1 {
+			return filter, errors.New("page must be a positive number")
+		}
+	}
+	if v := c.Query("page_size"); v != "" {
+		if filter.PageSize, err = strconv.Atoi(v); err != nil || filter.PageSize < 1 || filter.PageSize > repository.MaxPageSize {
+			return filter, fmt.Errorf("page_size must be between 1 and %d", repository.MaxPageSize)
+		}
+	}
+	return filter, nil
+}
+
+// parseID reads an optional numeric ID query parameter
+func parseID(c *gin.Context, name string) (*uint, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an ID", name)
+	}
+	n := uint(id)
+	return &n, nil
+}
+
+// parseList reads a comma-separated or repeated query parameter whose
+// values must be among allowed
+func parseList(c *gin.Context, name string, allowed ...string) ([]string, error) {
+	var values []string
+	for _, param := range c.QueryArray(name) {
+		for _, v := range strings.Split(param, ",") {
+			if !slices.Contains(allowed, v) {
+				return nil, fmt.Errorf("%s must be one of %s", name, strings.Join(allowed, ", "))
+			}
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// parseBefore reads how long before a due date to remind, as a Go
+// duration or a number of days such as "2d", in whole minutes up to a
+// year
+func parseBefore(v string) (time.Duration, error) {
+	invalid := fmt.Errorf("reminder %q must be a whole number of minutes up to 365d, e.g. 1d, 2h or 30m", v)
+	var d time.Duration
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, invalid
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(v); err != nil {
+			return 0, invalid
+		}
+	}
+	if d < 0 || d > 365*24*time.Hour || d%time.Minute != 0 {
+		return 0, invalid
+	}
+	return d, nil
+}
+
+// parseDue reads an RFC 3339 time or a date. A date as an upper bound
+// covers the whole day.
+func parseDue(v string, endOfDay bool) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		t = t.UTC()
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return nil, errors.New("must be RFC 3339 or YYYY-MM-DD")
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
 }
@@ -0,0 +1,130 @@
+// Package ical writes iCalendar (RFC 5545) feeds that calendar apps can
+// subscribe to.
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Calendar is a named list of events
+type Calendar struct {
+	// ProdID identifies the program that made the feed
+	ProdID string
+	Name   string
+	Events []Event
+}
+
+// Event is a moment in a calendar, such as a task falling due
+type Event struct {
+	// UID identifies the event across updates of the feed
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	// Stamp is when the event last changed
+	Stamp time.Time
+	// Alarms remind the user this long before Start
+	Alarms []time.Duration
+	// Categories tag the event, e.g. with a task's labels
+	Categories []string
+}
+
+// Marshal encodes the calendar with CRLF line endings and long lines
+// folded, as the format requires
+func (c *Calendar) Marshal() []byte {
+	var b bytes.Buffer
+	line := func(name, value string) {
+		writeFolded(&b, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", text(c.ProdID))
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME", text(c.Name))
+	}
+	for _, e := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", text(e.UID))
+		line("DTSTAMP", utc(e.Stamp))
+		line("DTSTART", utc(e.Start))
+		line("SUMMARY", text(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION", text(e.Description))
+		}
+		if len(e.Categories) > 0 {
+			escaped := make([]string, len(e.Categories))
+			for i, category := range e.Categories {
+				escaped[i] = text(category)
+			}
+			line("CATEGORIES", strings.Join(escaped, ","))
+		}
+		for _, before := range e.Alarms {
+			line("BEGIN", "VALARM")
+			line("ACTION", "DISPLAY")
+			line("DESCRIPTION", text(e.Summary))
+			line("TRIGGER", "-"+duration(before))
+			line("END", "VALARM")
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return b.Bytes()
+}
+
+func utc(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// text escapes a TEXT value
+func text(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// duration formats a non-negative duration as e.g. P1DT2H or PT15M
+func duration(d time.Duration) string {
+	if d < time.Minute {
+		return "PT0S"
+	}
+	minutes := int(d / time.Minute)
+	days, hours, minutes := minutes/(24*60), minutes/60%24, minutes%60
+	s := "P"
+	if days > 0 {
+		s += fmt.Sprintf("%dD", days)
+	}
+	if hours > 0 || minutes > 0 {
+		s += "T"
+		if hours > 0 {
+			s += fmt.Sprintf("%dH", hours)
+		}
+		if minutes > 0 {
+			s += fmt.Sprintf("%dM", minutes)
+		}
+	}
+	return s
+}
+
+// writeFolded writes a content line, folding it into lines of at most 75
+// octets without splitting a UTF-8 character
+func writeFolded(b *bytes.Buffer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts
+		limit = 74
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
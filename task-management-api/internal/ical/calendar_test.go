@@ -0,0 +1,87 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshal(t *testing.T) {
+	due := time.Date(2025, 3, 10, 17, 0, 0, 0, time.FixedZone("CET", 3600))
+	cal := Calendar{
+		ProdID: "-//tasks//EN",
+		Name:   "Ada's tasks",
+		Events: []Event{{
+			UID:         "task-1@tasks",
+			Summary:     "Pay rent; call landlord, then relax",
+			Description: "Line one\nLine two \\ done",
+			Start:       due,
+			Stamp:       due.Add(-time.Hour),
+			Alarms:      []time.Duration{24 * time.Hour, 90 * time.Minute},
+			Categories:  []string{"home", "a,b"},
+		}},
+	}
+	got := string(cal.Marshal())
+	want := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//tasks//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:Ada's tasks",
+		"BEGIN:VEVENT",
+		"UID:task-1@tasks",
+		"DTSTAMP:20250310T150000Z",
+		"DTSTART:20250310T160000Z",
+		`SUMMARY:Pay rent\; call landlord\, then relax`,
+		`DESCRIPTION:Line one\nLine two \\ done`,
+		`CATEGORIES:home,a\,b`,
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		`DESCRIPTION:Pay rent\; call landlord\, then relax`,
+		"TRIGGER:-P1D",
+		"END:VALARM",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		`DESCRIPTION:Pay rent\; call landlord\, then relax`,
+		"TRIGGER:-PT1H30M",
+		"END:VALARM",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFolding(t *testing.T) {
+	cal := Calendar{Events: []Event{{Summary: strings.Repeat("é", 100)}}}
+	for _, line := range strings.Split(strings.TrimSuffix(string(cal.Marshal()), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+		if !strings.HasPrefix(line, " ") && strings.Contains(line, "é") && !strings.HasPrefix(line, "SUMMARY:") {
+			t.Errorf("unfolded continuation: %q", line)
+		}
+	}
+	unfolded := strings.ReplaceAll(string(cal.Marshal()), "\r\n ", "")
+	if !strings.Contains(unfolded, "SUMMARY:"+strings.Repeat("é", 100)+"\r\n") {
+		t.Error("folding changed the summary")
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                          "PT0S",
+		15 * time.Minute:           "PT15M",
+		time.Hour:                  "PT1H",
+		26*time.Hour + time.Minute: "P1DT2H1M",
+		7 * 24 * time.Hour:         "P7D",
+	}
+	for d, want := range tests {
+		if got := duration(d); got != want {
+			t.Errorf("duration(%v) = %s, want %s", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/task-management-api/internal/auth"
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/repository"
+)
+
+const userKey = "user"
+
+// Auth requires a valid bearer token and puts its user into the context,
+// where handlers read it with CurrentUser. Tokens of deleted users are
+// rejected.
+func Auth(tokens *auth.TokenManager, repo repository.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			return
+		}
+
+		userID, err := tokens.Parse(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+		user, err := repo.GetUserByID(userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate"})
+			return
+		}
+
+		c.Set(userKey, user)
+		c.Next()
+	}
+}
+
+// CurrentUser returns the user Auth authenticated
+func CurrentUser(c *gin.Context) *models.User {
+	user, _ := c.MustGet(userKey).(*models.User)
+	return user
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// Activity actions
+const (
+	ActivityProjectCreated    = "project.created"
+	ActivityProjectUpdated    = "project.updated"
+	ActivityMemberAdded       = "member.added"
+	ActivityMemberRoleChanged = "member.role_changed"
+	ActivityMemberRemoved     = "member.removed"
+	ActivityTaskCreated       = "task.created"
+	ActivityTaskUpdated       = "task.updated"
+	ActivityTaskAssigned      = "task.assigned"
+	ActivityTaskLabeled       = "task.labeled"
+	ActivityTaskMoved         = "task.moved"
+	ActivityTaskDeleted       = "task.deleted"
+	ActivityTaskRecurred      = "task.recurred"
+	ActivityDependencyAdded   = "dependency.added"
+	ActivityDependencyRemoved = "dependency.removed"
+	ActivityCommentAdded      = "comment.added"
+	ActivityLabelCreated      = "label.created"
+	ActivityLabelDeleted      = "label.deleted"
+)
+
+// Activity is an entry in a project's feed. TaskID outlives the task so
+// the feed keeps deleted tasks' history.
+type Activity struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ProjectID uint      `json:"project_id" gorm:"not null;index"`
+	TaskID    *uint     `json:"task_id,omitempty"`
+	UserID    uint      `json:"user_id" gorm:"not null"`
+	Action    string    `json:"action" gorm:"not null"`
+	Summary   string    `json:"summary"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// Comment is a message on a task. A reply points at the comment it
+// answers; replies are only ever one level deep.
+type Comment struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	TaskID   uint   `json:"task_id" gorm:"not null;index"`
+	UserID   uint   `json:"user_id" gorm:"not null"`
+	ParentID *uint  `json:"parent_id,omitempty" gorm:"index"`
+	Body     string `json:"body" gorm:"not null"`
+	// Mentions are the users named with @username in the body who can see
+	// the task
+	Mentions  []User    `json:"mentions,omitempty" gorm:"many2many:comment_mentions"`
+	Replies   []Comment `json:"replies,omitempty" gorm:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
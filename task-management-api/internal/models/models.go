@@ -0,0 +1,8 @@
+package models
+
+// All lists every model, in an order AutoMigrate can create them in
+func All() []interface{} {
+	return []interface{}{
+		&User{}, &Project{}, &ProjectMember{}, &Label{}, &Task{}, &TaskDependency{}, &Reminder{}, &Comment{}, &Activity{},
+	}
+}
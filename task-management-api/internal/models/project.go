@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+)
+
+// Role is what a member may do in a project
+type Role string
+
+const (
+	// RoleViewer can read the project's tasks, comments and activity
+	RoleViewer Role = "viewer"
+	// RoleEditor can also create, change and comment on tasks, and
+	// manage labels
+	RoleEditor Role = "editor"
+	// RoleOwner can also manage the project and its members
+	RoleOwner Role = "owner"
+)
+
+var roleRank = map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	return roleRank[r] > 0
+}
+
+// Allows reports whether r grants everything need does
+func (r Role) Allows(need Role) bool {
+	return r.Valid() && roleRank[r] >= roleRank[need]
+}
+
+// Project groups tasks that its members share
+type Project struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"not null"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ProjectMember gives a user a role in a project
+type ProjectMember struct {
+	ProjectID uint      `json:"project_id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"primaryKey;index"`
+	Role      Role      `json:"role" gorm:"not null"`
+	User      *User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Label tags tasks within one project
+type Label struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ProjectID uint      `json:"project_id" gorm:"not null;uniqueIndex:idx_labels_project_name"`
+	Name      string    `json:"name" gorm:"not null;uniqueIndex:idx_labels_project_name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+}
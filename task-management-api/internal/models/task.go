@@ -4,15 +4,83 @@ import (
 	"time"
 )
 
+// Task statuses
+const (
+	StatusTodo       = "todo"
+	StatusInProgress = "in_progress"
+	StatusDone       = "done"
+)
+
+// Task priorities
+const (
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+)
+
 // Task represents a task in the system
 type Task struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	Title       string    `json:"title" binding:"required"`
-	Description string    `json:"description"`
-	Status      string    `json:"status" binding:"required,oneof=todo in_progress done"`
-	Priority    string    `json:"priority" binding:"required,oneof=low medium high"`
-	DueDate     time.Time `json:"due_date,omitempty"`
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Title       string     `json:"title" binding:"required" gorm:"not null"`
+	Description string     `json:"description"`
+	Status      string     `json:"status" binding:"required,oneof=todo in_progress done" gorm:"not null;index;uniqueIndex:idx_tasks_project_rank,priority:2;uniqueIndex:idx_tasks_personal_rank,priority:2"`
+	Priority    string     `json:"priority" binding:"required,oneof=low medium high" gorm:"not null;index"`
+	DueDate     *time.Time `json:"due_date,omitempty" gorm:"index"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	// UserID is the task's creator. A task without a project is private to
+	// them; a project task is shared with the project's members.
+	UserID     uint    `json:"user_id" gorm:"not null;index;uniqueIndex:idx_tasks_personal_rank,priority:1"`
+	ProjectID  *uint   `json:"project_id,omitempty" gorm:"index;uniqueIndex:idx_tasks_project_rank,priority:1"`
+	AssigneeID *uint   `json:"assignee_id,omitempty" gorm:"index"`
+	Labels     []Label `json:"labels,omitempty" gorm:"many2many:task_labels"`
+	// ParentID makes the task a subtask. Subtasks share their parent's
+	// project.
+	ParentID *uint `json:"parent_id,omitempty" gorm:"index"`
+	// Rank orders the task within its Kanban column, the tasks with the
+	// same status in its project or among its creator's personal tasks.
+	// Ranks are unique within a column; tasks from before ranking have
+	// none.
+	Rank string `json:"rank" gorm:"index;uniqueIndex:idx_tasks_project_rank,priority:3,where:project_id IS NOT NULL AND rank <> '';uniqueIndex:idx_tasks_personal_rank,priority:3,where:project_id IS NULL AND rank <> ''"`
+	// Progress rolls up the task's subtasks; it is nil for a task without
+	// any
+	Progress *Progress `json:"progress,omitempty" gorm:"-"`
+	// Recurrence is an iCalendar RRULE. Completing a recurring task
+	// creates its next occurrence, which NextOccurrenceID then points at.
+	Recurrence       string     `json:"recurrence,omitempty"`
+	NextOccurrenceID *uint      `json:"next_occurrence_id,omitempty"`
+	Reminders        []Reminder `json:"reminders,omitempty" gorm:"foreignKey:TaskID"`
+}
+
+// Reminder notifies a task's assignee, or its creator if it has none,
+// some time before the task is due
+type Reminder struct {
+	ID     uint `json:"id" gorm:"primaryKey"`
+	TaskID uint `json:"task_id" gorm:"not null;index"`
+	// BeforeMinutes is how long before the due date the reminder is sent
+	BeforeMinutes int `json:"before_minutes" gorm:"not null"`
+	// RemindAt is when the reminder is due to be sent; it is nil while the
+	// task has no due date
+	RemindAt  *time.Time `json:"remind_at,omitempty" gorm:"index"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
+	Attempts  int        `json:"attempts"`
+	LastError string     `json:"last_error,omitempty"`
+	Task      *Task      `json:"-" gorm:"foreignKey:TaskID"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Progress counts how many of a task's subtasks are done. Only subtasks
+// without subtasks of their own are counted, so nested work is weighed
+// evenly.
+type Progress struct {
+	Done    int `json:"done"`
+	Total   int `json:"total"`
+	Percent int `json:"percent"`
+}
+
+// TaskDependency records that a task cannot start until another is done
+type TaskDependency struct {
+	TaskID      uint      `json:"task_id" gorm:"primaryKey"`
+	BlockedByID uint      `json:"blocked_by_id" gorm:"primaryKey;index"`
 	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	UserID      uint      `json:"user_id"`
 }
@@ -15,6 +15,10 @@ type User struct {
 	Tasks     []Task    `json:"tasks,omitempty" gorm:"foreignKey:UserID"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// CalendarTokenHash is the SHA-256 of the token in the user's calendar
+	// feed URL, or nil if they have no feed
+	CalendarTokenHash *string `json:"-" gorm:"uniqueIndex"`
 }
 
 // HashPassword hashes the user's password using bcrypt
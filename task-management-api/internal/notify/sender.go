@@ -0,0 +1,86 @@
+// Package notify sends task reminders. A Worker finds the reminders that
+// are due and hands each to a Sender, which decides how the user hears
+// about it.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Notification tells a user that a task is coming up
+type Notification struct {
+	UserID   uint      `json:"user_id"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	TaskID   uint      `json:"task_id"`
+	Title    string    `json:"title"`
+	DueDate  time.Time `json:"due_date"`
+	Message  string    `json:"message"`
+}
+
+// Sender delivers notifications. An error leaves the reminder to be
+// retried.
+type Sender interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// SenderFunc adapts a function to Sender
+type SenderFunc func(ctx context.Context, n Notification) error
+
+func (f SenderFunc) Send(ctx context.Context, n Notification) error {
+	return f(ctx, n)
+}
+
+// LogSender writes notifications to a log; it suits development and
+// deployments that have nowhere else to send them
+type LogSender struct {
+	Logger *log.Logger
+}
+
+func (s LogSender) Send(_ context.Context, n Notification) error {
+	logger := s.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Printf("reminder for %s <%s>: %s", n.Username, n.Email, n.Message)
+	return nil
+}
+
+// WebhookSender posts each notification as JSON to a URL, e.g. a chat or
+// email relay. Any status other than 2xx counts as a failure.
+type WebhookSender struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s WebhookSender) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notify: webhook responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/task-management-api/internal/repository"
+)
+
+// Worker sends reminders as they fall due. Reminders are marked sent only
+// after the sender accepts them, so a crash may repeat one but never
+// drops one; run a single worker per database.
+type Worker struct {
+	repo   repository.Repository
+	sender Sender
+
+	// Interval is how often to look for due reminders
+	Interval time.Duration
+	// BatchSize caps the reminders sent per look
+	BatchSize int
+	// MaxAttempts is how many times a failing reminder is tried
+	MaxAttempts int
+
+	now func() time.Time
+}
+
+// NewWorker creates a worker with default settings
+func NewWorker(repo repository.Repository, sender Sender) *Worker {
+	return &Worker{
+		repo:        repo,
+		sender:      sender,
+		Interval:    time.Minute,
+		BatchSize:   100,
+		MaxAttempts: 5,
+		now:         time.Now,
+	}
+}
+
+// Run sends due reminders every Interval until ctx is done
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.SendDue(ctx); err != nil {
+			log.Printf("Failed to send reminders: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDue sends one batch of due reminders and returns how many were sent
+func (w *Worker) SendDue(ctx context.Context) (int, error) {
+	now := w.now()
+	reminders, err := w.repo.DueReminders(now, w.MaxAttempts, w.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, reminder := range reminders {
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+		task := reminder.Task
+		recipient := task.UserID
+		if task.AssigneeID != nil {
+			recipient = *task.AssigneeID
+		}
+		user, err := w.repo.GetUserByID(recipient)
+		if err != nil {
+			return sent, err
+		}
+
+		n := Notification{
+			UserID:   user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+			TaskID:   task.ID,
+			Title:    task.Title,
+			DueDate:  *task.DueDate,
+			Message:  fmt.Sprintf("%q is due %s", task.Title, until(task.DueDate.Sub(now))),
+		}
+		if err := w.sender.Send(ctx, n); err != nil {
+			if err := w.repo.MarkReminderFailed(reminder.ID, err.Error()); err != nil {
+				return sent, err
+			}
+			continue
+		}
+		if err := w.repo.MarkReminderSent(reminder.ID, now); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// until describes a time to go in words, rounded to the largest whole
+// unit, e.g. "in 2 days"
+func until(d time.Duration) string {
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+	for _, unit := range units {
+		if n := int((d + unit.size/2) / unit.size); n >= 1 && d >= unit.size*9/10 {
+			if n == 1 {
+				return "in 1 " + unit.name
+			}
+			return fmt.Sprintf("in %d %ss", n, unit.name)
+		}
+	}
+	return "now"
+}
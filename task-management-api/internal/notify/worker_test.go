@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/repository"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestRepository(t *testing.T) repository.Repository {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	return repository.NewPostgresRepository(db)
+}
+
+func TestSendDue(t *testing.T) {
+	repo := newTestRepository(t)
+	var users []models.User
+	for _, name := range []string{"ada", "bob"} {
+		user := models.User{Username: name, Email: name + "@example.com", Password: "x"}
+		if err := repo.CreateUser(&user); err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, user)
+	}
+
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	task := func(title, status string, due time.Time, assignee *uint, before ...int) {
+		t.Helper()
+		task := models.Task{Title: title, Status: status, Priority: models.PriorityMedium, DueDate: &due, UserID: users[0].ID, AssigneeID: assignee}
+		if err := repo.CreateTask(&task); err != nil {
+			t.Fatal(err)
+		}
+		var reminders []models.Reminder
+		for _, minutes := range before {
+			at := due.Add(-time.Duration(minutes) * time.Minute)
+			reminders = append(reminders, models.Reminder{BeforeMinutes: minutes, RemindAt: &at})
+		}
+		if err := repo.SetReminders(task.ID, reminders); err != nil {
+			t.Fatal(err)
+		}
+	}
+	task("Tomorrow", models.StatusTodo, now.Add(24*time.Hour), nil, 24*60, 60)
+	task("Assigned", models.StatusInProgress, now.Add(30*time.Minute), &users[1].ID, 60)
+	task("Finished", models.StatusDone, now.Add(30*time.Minute), nil, 60)
+	task("Overdue", models.StatusTodo, now.Add(-time.Hour), nil, 60)
+
+	var sent []Notification
+	fail := false
+	sender := SenderFunc(func(_ context.Context, n Notification) error {
+		if fail {
+			return errors.New("relay down")
+		}
+		sent = append(sent, n)
+		return nil
+	})
+	w := NewWorker(repo, sender)
+	w.now = func() time.Time { return now }
+
+	n, err := w.SendDue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The day-before reminder for Tomorrow and the hour-before one for
+	// Assigned; not the done or overdue tasks, nor Tomorrow's hour-before
+	if n != 2 || len(sent) != 2 {
+		t.Fatalf("sent %d: %+v", n, sent)
+	}
+	if sent[0].Title != "Assigned" || sent[0].UserID != users[1].ID || sent[0].Message != `"Assigned" is due in 30 minutes` {
+		t.Errorf("first notification = %+v", sent[0])
+	}
+	if sent[1].Title != "Tomorrow" || sent[1].Username != "ada" || sent[1].Message != `"Tomorrow" is due in 1 day` {
+		t.Errorf("second notification = %+v", sent[1])
+	}
+	if n, _ := w.SendDue(context.Background()); n != 0 {
+		t.Errorf("resent %d reminders", n)
+	}
+
+	// Failures are retried up to MaxAttempts
+	now = now.Add(23 * time.Hour)
+	fail = true
+	for i := 0; i < w.MaxAttempts+2; i++ {
+		if _, err := w.SendDue(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reminders, err := repo.DueReminders(now, 100, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reminders) != 1 || reminders[0].Attempts != w.MaxAttempts || reminders[0].LastError != "relay down" {
+		t.Errorf("failed reminders = %+v", reminders)
+	}
+}
+
+func TestUntil(t *testing.T) {
+	tests := map[time.Duration]string{
+		24 * time.Hour:               "in 1 day",
+		23*time.Hour + time.Minute:   "in 1 day",
+		60 * time.Hour:               "in 3 days",
+		59 * time.Minute:             "in 1 hour",
+		2*time.Hour + 10*time.Minute: "in 2 hours",
+		15 * time.Minute:             "in 15 minutes",
+		20 * time.Second:             "now",
+	}
+	for d, want := range tests {
+		if got := until(d); got != want {
+			t.Errorf("until(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
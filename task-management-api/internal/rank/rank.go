@@ -0,0 +1,85 @@
+// Package rank generates keys that order items by plain string
+// comparison. A key can always be found between any two others, so moving
+// an item only ever rewrites that item's key.
+package rank
+
+import (
+	"errors"
+	"strings"
+)
+
+// digits are the characters keys are made of, in sort order. They are
+// limited to digits and lowercase letters, which sort the same way in
+// byte order and in the usual database collations.
+const digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// ErrInvalid is returned for a malformed key or keys out of order
+var ErrInvalid = errors.New("rank: invalid key")
+
+// Between returns a key that sorts strictly after a and before b. An
+// empty a means before every key and an empty b after every key, so
+// Between("", "") gives a first key.
+func Between(a, b string) (string, error) {
+	if !valid(a) || !valid(b) || (a != "" && b != "" && a >= b) {
+		return "", ErrInvalid
+	}
+	return midpoint(a, b), nil
+}
+
+// valid reports whether key is empty or usable as a bound. Keys never end
+// in the lowest digit, which leaves room before every key.
+func valid(key string) bool {
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return false
+		}
+	}
+	return !strings.HasSuffix(key, digits[:1])
+}
+
+// midpoint finds a key between a and b, which are valid and in order
+func midpoint(a, b string) string {
+	if b != "" {
+		// Keep the prefix the two share; a is padded with the lowest digit
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+
+	lo := 0
+	if a != "" {
+		lo = strings.IndexByte(digits, a[0])
+	}
+	hi := len(digits)
+	if b != "" {
+		hi = strings.IndexByte(digits, b[0])
+	}
+	if hi-lo > 1 {
+		return digits[(lo+hi+1)/2 : (lo+hi+1)/2+1]
+	}
+	// The first digits are adjacent: b's first digit alone sorts before b
+	// if b is longer, otherwise extend a
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return digits[lo:lo+1] + midpoint(rest, "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return digits[0]
+}
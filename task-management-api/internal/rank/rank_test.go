@@ -0,0 +1,78 @@
+package rank
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		a, b, want string
+	}{
+		{"", "", "i"},
+		{"i", "", "r"},
+		{"", "i", "9"},
+		{"a", "b", "ai"},
+		{"az", "b", "azi"},
+		{"a", "a1", "a0i"},
+		{"1", "2", "1i"},
+		{"zz", "", "zzi"},
+		{"", "01", "00i"},
+	}
+	for _, tt := range tests {
+		got, err := Between(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("Between(%q, %q): %v", tt.a, tt.b, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Between(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"b", "a"}, {"a", "a"}, {"A", ""}, {"", "a0"}, {"a-", ""}} {
+		if _, err := Between(bad[0], bad[1]); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Between(%q, %q): got %v, want ErrInvalid", bad[0], bad[1], err)
+		}
+	}
+}
+
+func TestBetweenKeepsOrder(t *testing.T) {
+	// Insert at random places in a list and check the keys always sort
+	// into the list's order
+	r := rand.New(rand.NewSource(1))
+	var keys []string
+	for i := 0; i < 2000; i++ {
+		at := r.Intn(len(keys) + 1)
+		var before, after string
+		if at > 0 {
+			before = keys[at-1]
+		}
+		if at < len(keys) {
+			after = keys[at]
+		}
+		key, err := Between(before, after)
+		if err != nil {
+			t.Fatalf("Between(%q, %q): %v", before, after, err)
+		}
+		if (before != "" && key <= before) || (after != "" && key >= after) || !valid(key) {
+			t.Fatalf("Between(%q, %q) = %q", before, after, key)
+		}
+		keys = append(keys[:at], append([]string{key}, keys[at:]...)...)
+	}
+	if !sort.StringsAreSorted(keys) {
+		t.Error("keys are out of order")
+	}
+
+	// Repeatedly inserting at the front still works
+	key := "i"
+	for i := 0; i < 200; i++ {
+		next, err := Between("", key)
+		if err != nil || next >= key {
+			t.Fatalf("Between(\"\", %q) = %q, %v", key, next, err)
+		}
+		key = next
+	}
+}
@@ -0,0 +1,218 @@
+// Package recurrence implements the subset of iCalendar (RFC 5545) RRULEs
+// that recurring tasks need: daily, weekly, monthly and yearly repeats at
+// an interval, on chosen weekdays or a day of the month, until a date.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRule is returned for a rule Parse cannot read
+var ErrInvalidRule = errors.New("recurrence: invalid rule")
+
+// Frequency is how often a rule repeats
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+var weekdays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Rule is a parsed RRULE
+type Rule struct {
+	Freq Frequency
+	// Interval repeats every Interval days, weeks, months or years
+	Interval int
+	// ByDay lists the weekdays a weekly rule falls on
+	ByDay []time.Weekday
+	// ByMonthDay is the day a monthly or yearly rule falls on. Months
+	// without that day use their last day instead.
+	ByMonthDay int
+	// Until is the last moment an occurrence may fall on
+	Until *time.Time
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", with or
+// without a leading "RRULE:"
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 6 && strings.EqualFold(s[:6], "RRULE:") {
+		s = s[6:]
+	}
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidRule, fmt.Sprintf(format, args...))
+	}
+
+	rule := &Rule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(key)
+		value = strings.ToUpper(value)
+		if !ok || value == "" {
+			return nil, invalid("%q is not KEY=VALUE", part)
+		}
+		if seen[key] {
+			return nil, invalid("%s is repeated", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			rule.Freq = Frequency(value)
+			if !slices.Contains([]Frequency{Daily, Weekly, Monthly, Yearly}, rule.Freq) {
+				return nil, invalid("FREQ must be DAILY, WEEKLY, MONTHLY or YEARLY")
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 1000 {
+				return nil, invalid("INTERVAL must be between 1 and 1000")
+			}
+			rule.Interval = n
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				i := slices.Index(weekdays, day)
+				if i < 0 {
+					return nil, invalid("BYDAY must list days such as MO,WE")
+				}
+				if !slices.Contains(rule.ByDay, time.Weekday(i)) {
+					rule.ByDay = append(rule.ByDay, time.Weekday(i))
+				}
+			}
+			slices.Sort(rule.ByDay)
+		case "BYMONTHDAY":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 31 {
+				return nil, invalid("BYMONTHDAY must be between 1 and 31")
+			}
+			rule.ByMonthDay = n
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, invalid("UNTIL must be YYYYMMDD or YYYYMMDDTHHMMSSZ")
+			}
+			rule.Until = &until
+		default:
+			return nil, invalid("%s is not supported", key)
+		}
+	}
+
+	switch {
+	case rule.Freq == "":
+		return nil, invalid("FREQ is required")
+	case rule.ByDay != nil && rule.Freq != Weekly:
+		return nil, invalid("BYDAY needs FREQ=WEEKLY")
+	case rule.ByMonthDay != 0 && rule.Freq != Monthly && rule.Freq != Yearly:
+		return nil, invalid("BYMONTHDAY needs FREQ=MONTHLY or YEARLY")
+	}
+	return rule, nil
+}
+
+// parseUntil reads a UTC date-time, or a date meaning the end of that day
+func parseUntil(v string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("20060102", v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.Add(24*time.Hour - time.Second), nil
+}
+
+// String formats the rule as an RRULE value
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = weekdays[day]
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.ByMonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.ByMonthDay))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Anchor fills in what the rule leaves to its first occurrence: the
+// weekday of a weekly rule and the day of the month of a monthly or
+// yearly one. Anchoring keeps a task due on the 31st coming back on the
+// 31st after a shorter month.
+func (r *Rule) Anchor(first time.Time) *Rule {
+	anchored := *r
+	switch {
+	case r.Freq == Weekly && len(r.ByDay) == 0:
+		anchored.ByDay = []time.Weekday{first.Weekday()}
+	case (r.Freq == Monthly || r.Freq == Yearly) && r.ByMonthDay == 0:
+		anchored.ByMonthDay = first.Day()
+	}
+	return &anchored
+}
+
+// Next returns the first occurrence after prev, at the same time of day,
+// and false once the rule has run out
+func (r *Rule) Next(prev time.Time) (time.Time, bool) {
+	interval := max(r.Interval, 1)
+	var next time.Time
+	switch r.Freq {
+	case Daily:
+		next = prev.AddDate(0, 0, interval)
+	case Weekly:
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{prev.Weekday()}
+		}
+		start := weekStart(prev)
+		for i := 1; ; i++ {
+			next = prev.AddDate(0, 0, i)
+			weeks := int(weekStart(next).Sub(start).Hours()+12) / (24 * 7)
+			if weeks%interval == 0 && slices.Contains(days, next.Weekday()) {
+				break
+			}
+		}
+	case Monthly:
+		next = onDay(prev, 0, interval, r.ByMonthDay)
+	case Yearly:
+		next = onDay(prev, interval, 0, r.ByMonthDay)
+	default:
+		return time.Time{}, false
+	}
+	if r.Until != nil && next.After(*r.Until) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// weekStart returns midnight on the Monday of t's week
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
+
+// onDay moves t on by years and months and onto day, or the month's last
+// day if it is shorter; day 0 keeps t's day
+func onDay(t time.Time, years, months, day int) time.Time {
+	if day == 0 {
+		day = t.Day()
+	}
+	first := time.Date(t.Year()+years, t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, last)-1)
+}
@@ -0,0 +1,111 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"RRULE:freq=weekly;byday=we,mo,we", "FREQ=WEEKLY;BYDAY=MO,WE"},
+		{"FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=31", "FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=31"},
+		{"FREQ=YEARLY;INTERVAL=1;UNTIL=20300101", "FREQ=YEARLY;UNTIL=20300101T235959Z"},
+		{"FREQ=DAILY;UNTIL=20300101T090000Z", "FREQ=DAILY;UNTIL=20300101T090000Z"},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{
+		"", "FREQ=HOURLY", "INTERVAL=2", "FREQ=DAILY;INTERVAL=0", "FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY=XX", "FREQ=WEEKLY;BYMONTHDAY=1", "FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;COUNT=3", "FREQ=DAILY;FREQ=DAILY", "FREQ=DAILY;UNTIL=tomorrow", "FREQ",
+	} {
+		if _, err := Parse(in); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Parse(%q): got %v, want ErrInvalidRule", in, err)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	at := func(date string) time.Time {
+		d, err := time.Parse("2006-01-02 15:04", date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		rule       string
+		prev, want string
+	}{
+		{"FREQ=DAILY", "2025-03-10 09:00", "2025-03-11 09:00"},
+		{"FREQ=DAILY;INTERVAL=3", "2025-03-30 09:00", "2025-04-02 09:00"},
+		// 2025-03-12 is a Wednesday
+		{"FREQ=WEEKLY", "2025-03-12 09:00", "2025-03-19 09:00"},
+		{"FREQ=WEEKLY;BYDAY=MO,WE", "2025-03-12 09:00", "2025-03-17 09:00"},
+		{"FREQ=WEEKLY;BYDAY=MO,WE", "2025-03-10 09:00", "2025-03-12 09:00"},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", "2025-03-14 09:00", "2025-03-24 09:00"},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=SU", "2025-03-16 09:00", "2025-03-30 09:00"},
+		{"FREQ=MONTHLY", "2025-01-15 17:30", "2025-02-15 17:30"},
+		{"FREQ=MONTHLY;BYMONTHDAY=31", "2025-01-31 17:30", "2025-02-28 17:30"},
+		{"FREQ=MONTHLY;BYMONTHDAY=31", "2025-02-28 17:30", "2025-03-31 17:30"},
+		{"FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=30", "2024-12-30 08:00", "2025-02-28 08:00"},
+		{"FREQ=YEARLY;BYMONTHDAY=29", "2024-02-29 08:00", "2025-02-28 08:00"},
+		{"FREQ=YEARLY;BYMONTHDAY=29", "2027-02-28 08:00", "2028-02-29 08:00"},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := rule.Next(at(tt.prev))
+		if !ok || !got.Equal(at(tt.want)) {
+			t.Errorf("%s after %s = %v, %v, want %s", tt.rule, tt.prev, got, ok, tt.want)
+		}
+	}
+
+	rule, _ := Parse("FREQ=DAILY;UNTIL=20250311")
+	if _, ok := rule.Next(at("2025-03-10 09:00")); !ok {
+		t.Error("occurrence on the UNTIL date was dropped")
+	}
+	if next, ok := rule.Next(at("2025-03-11 09:00")); ok {
+		t.Errorf("occurrence after UNTIL: %v", next)
+	}
+}
+
+func TestAnchor(t *testing.T) {
+	first := time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC)
+	monthly, _ := Parse("FREQ=MONTHLY")
+	if got := monthly.Anchor(first).String(); got != "FREQ=MONTHLY;BYMONTHDAY=31" {
+		t.Errorf("anchored monthly = %s", got)
+	}
+	weekly, _ := Parse("FREQ=WEEKLY")
+	if got := weekly.Anchor(first).String(); got != "FREQ=WEEKLY;BYDAY=FR" {
+		t.Errorf("anchored weekly = %s", got)
+	}
+	if monthly.ByMonthDay != 0 {
+		t.Error("Anchor changed the rule it was called on")
+	}
+
+	// Anchored, a month-end rule comes back to the 31st after February
+	rule := monthly.Anchor(first)
+	due := first
+	for _, want := range []string{"2025-02-28", "2025-03-31", "2025-04-30", "2025-05-31"} {
+		due, _ = rule.Next(due)
+		if got := due.Format(time.DateOnly); got != want {
+			t.Errorf("next = %s, want %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"github.com/task-management-api/internal/models"
+)
+
+// CreateComment creates a comment and links its mentions, which must be
+// existing users
+func (r *PostgresRepository) CreateComment(comment *models.Comment) error {
+	return r.db.Omit("Mentions.*").Create(comment).Error
+}
+
+func (r *PostgresRepository) GetCommentByID(id uint) (*models.Comment, error) {
+	var comment models.Comment
+	if err := r.db.Preload("Mentions").First(&comment, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &comment, nil
+}
+
+func (r *PostgresRepository) ListComments(taskID uint) ([]models.Comment, error) {
+	var comments []models.Comment
+	err := r.db.Preload("Mentions").Where("task_id = ?", taskID).Order("created_at").Order("id").Find(&comments).Error
+	return comments, err
+}
+
+func (r *PostgresRepository) CreateActivity(activity *models.Activity) error {
+	return r.db.Create(activity).Error
+}
+
+func (r *PostgresRepository) ListActivity(projectID uint, limit, offset int) ([]models.Activity, error) {
+	var activity []models.Activity
+	err := r.db.Where("project_id = ?", projectID).
+		Order("created_at DESC").Order("id DESC").
+		Limit(limit).Offset(offset).
+		Find(&activity).Error
+	return activity, err
+}
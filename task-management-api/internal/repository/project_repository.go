@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"github.com/task-management-api/internal/models"
+	"gorm.io/gorm"
+)
+
+func (r *PostgresRepository) CreateProject(project *models.Project) error {
+	return r.db.Create(project).Error
+}
+
+func (r *PostgresRepository) GetProjectByID(id uint) (*models.Project, error) {
+	var project models.Project
+	if err := r.db.First(&project, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &project, nil
+}
+
+func (r *PostgresRepository) ListProjects(userID uint) ([]models.Project, error) {
+	var projects []models.Project
+	memberOf := r.db.Model(&models.ProjectMember{}).Select("project_id").Where("user_id = ?", userID)
+	err := r.db.Where("id IN (?)", memberOf).Order("name").Order("id").Find(&projects).Error
+	return projects, err
+}
+
+func (r *PostgresRepository) UpdateProject(project *models.Project) error {
+	return r.db.Save(project).Error
+}
+
+func (r *PostgresRepository) DeleteProject(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		tasks := tx.Model(&models.Task{}).Select("id").Where("project_id = ?", id)
+		comments := tx.Model(&models.Comment{}).Select("id").Where("task_id IN (?)", tasks)
+		labels := tx.Model(&models.Label{}).Select("id").Where("project_id = ?", id)
+		steps := []*gorm.DB{
+			tx.Exec("DELETE FROM comment_mentions WHERE comment_id IN (?)", comments),
+			tx.Where("task_id IN (?)", tasks).Delete(&models.Comment{}),
+			tx.Exec("DELETE FROM task_labels WHERE label_id IN (?)", labels),
+			tx.Where("task_id IN (?)", tasks).Delete(&models.TaskDependency{}),
+			tx.Where("task_id IN (?)", tasks).Delete(&models.Reminder{}),
+			tx.Where("project_id = ?", id).Delete(&models.Task{}),
+			tx.Where("project_id = ?", id).Delete(&models.Label{}),
+			tx.Where("project_id = ?", id).Delete(&models.ProjectMember{}),
+			tx.Where("project_id = ?", id).Delete(&models.Activity{}),
+		}
+		for _, step := range steps {
+			if step.Error != nil {
+				return step.Error
+			}
+		}
+		result := tx.Delete(&models.Project{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (r *PostgresRepository) AddMember(member *models.ProjectMember) error {
+	return translate(r.db.Omit("User").Create(member).Error)
+}
+
+func (r *PostgresRepository) GetMember(projectID, userID uint) (*models.ProjectMember, error) {
+	var member models.ProjectMember
+	err := r.db.Where("project_id = ? AND user_id = ?", projectID, userID).First(&member).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &member, nil
+}
+
+func (r *PostgresRepository) ListMembers(projectID uint) ([]models.ProjectMember, error) {
+	var members []models.ProjectMember
+	err := r.db.Preload("User").Where("project_id = ?", projectID).Order("created_at").Order("user_id").Find(&members).Error
+	return members, err
+}
+
+func (r *PostgresRepository) UpdateMember(member *models.ProjectMember) error {
+	return r.db.Model(&models.ProjectMember{}).
+		Where("project_id = ? AND user_id = ?", member.ProjectID, member.UserID).
+		Update("role", member.Role).Error
+}
+
+func (r *PostgresRepository) RemoveMember(projectID, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("project_id = ? AND user_id = ?", projectID, userID).Delete(&models.ProjectMember{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Model(&models.Task{}).
+			Where("project_id = ? AND assignee_id = ?", projectID, userID).
+			Update("assignee_id", nil).Error
+	})
+}
+
+func (r *PostgresRepository) CountOwners(projectID uint) (int64, error) {
+	var n int64
+	err := r.db.Model(&models.ProjectMember{}).
+		Where("project_id = ? AND role = ?", projectID, models.RoleOwner).
+		Count(&n).Error
+	return n, err
+}
+
+func (r *PostgresRepository) CreateLabel(label *models.Label) error {
+	return translate(r.db.Create(label).Error)
+}
+
+func (r *PostgresRepository) GetLabelByID(id uint) (*models.Label, error) {
+	var label models.Label
+	if err := r.db.First(&label, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &label, nil
+}
+
+func (r *PostgresRepository) GetLabelsByIDs(ids []uint) ([]models.Label, error) {
+	var labels []models.Label
+	if len(ids) == 0 {
+		return labels, nil
+	}
+	err := r.db.Where("id IN ?", ids).Order("name").Find(&labels).Error
+	return labels, err
+}
+
+func (r *PostgresRepository) ListLabels(projectID uint) ([]models.Label, error) {
+	var labels []models.Label
+	err := r.db.Where("project_id = ?", projectID).Order("name").Find(&labels).Error
+	return labels, err
+}
+
+func (r *PostgresRepository) DeleteLabel(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM task_labels WHERE label_id = ?", id).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.Label{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/task-management-api/internal/models"
+	"gorm.io/gorm"
+)
+
+func (r *PostgresRepository) SetReminders(taskID uint, reminders []models.Reminder) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id = ?", taskID).Delete(&models.Reminder{}).Error; err != nil {
+			return err
+		}
+		if len(reminders) == 0 {
+			return nil
+		}
+		for i := range reminders {
+			reminders[i].TaskID = taskID
+		}
+		return tx.Omit("Task").Create(&reminders).Error
+	})
+}
+
+func (r *PostgresRepository) RescheduleReminders(taskID uint, due *time.Time) error {
+	var reminders []models.Reminder
+	if err := r.db.Where("task_id = ?", taskID).Find(&reminders).Error; err != nil {
+		return err
+	}
+	for _, reminder := range reminders {
+		var at *time.Time
+		if due != nil {
+			t := due.Add(-time.Duration(reminder.BeforeMinutes) * time.Minute)
+			at = &t
+		}
+		err := r.db.Model(&reminder).Updates(map[string]interface{}{
+			"remind_at": at, "sent_at": nil, "attempts": 0, "last_error": "",
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) DueReminders(now time.Time, maxAttempts, limit int) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := r.db.Preload("Task").
+		Joins("JOIN tasks ON tasks.id = reminders.task_id").
+		Where("reminders.sent_at IS NULL AND reminders.remind_at <= ? AND reminders.attempts < ?", now, maxAttempts).
+		Where("tasks.status <> ? AND tasks.due_date > ?", models.StatusDone, now).
+		Order("reminders.remind_at").Order("reminders.id").
+		Limit(limit).
+		Find(&reminders).Error
+	return reminders, err
+}
+
+func (r *PostgresRepository) MarkReminderSent(id uint, at time.Time) error {
+	return r.db.Model(&models.Reminder{ID: id}).Update("sent_at", at).Error
+}
+
+func (r *PostgresRepository) MarkReminderFailed(id uint, reason string) error {
+	return r.db.Model(&models.Reminder{ID: id}).Updates(map[string]interface{}{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": reason,
+	}).Error
+}
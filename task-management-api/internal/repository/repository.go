@@ -1,33 +1,193 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"github.com/task-management-api/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound is returned when a record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a unique field, such as a user's email,
+	// is already taken
+	ErrDuplicate = errors.New("record already exists")
 )
 
+// TaskFilter selects and orders a page of the tasks a user can see: their
+// own tasks outside projects and every task in their projects
+type TaskFilter struct {
+	UserID     uint
+	ProjectID  *uint
+	AssigneeID *uint
+	LabelID    *uint
+	ParentID   *uint
+	// Ready keeps only tasks still to do whose blockers are all done
+	Ready      bool
+	Statuses   []string
+	Priorities []string
+	// DueFrom and DueTo bound the due date, both inclusive. Tasks without
+	// a due date are left out when either is set.
+	DueFrom *time.Time
+	DueTo   *time.Time
+	// Sort lists fields to order by, each optionally prefixed with "-" for
+	// descending, e.g. []string{"-priority", "due_date"}
+	Sort     []string
+	Page     int
+	PageSize int
+}
+
+// Column identifies a Kanban column: the tasks with one status in a
+// project, or among a user's personal tasks when ProjectID is nil
+type Column struct {
+	ProjectID *uint
+	UserID    uint
+	Status    string
+}
+
 // Repository interface defines all database operations
 type Repository interface {
+	// Transaction runs fn with a repository whose operations all commit or
+	// roll back together
+	Transaction(fn func(repo Repository) error) error
+
 	// Task operations
+	// CreateTask, UpdateTask and MoveTask return ErrDuplicate when the
+	// task's rank is already taken in its column
 	CreateTask(task *models.Task) error
+	// GetTaskByID returns the task with its labels and reminders
 	GetTaskByID(id uint) (*models.Task, error)
 	UpdateTask(task *models.Task) error
+	// DeleteTask deletes the task and its subtasks with their comments,
+	// labelling, dependencies and reminders
 	DeleteTask(id uint) error
-	ListTasks(userID uint) ([]models.Task, error)
+	// ListTasks returns a page of tasks and the number of tasks matching
+	// the filter across all pages
+	ListTasks(filter TaskFilter) ([]models.Task, int64, error)
+	SetTaskLabels(task *models.Task, labels []models.Label) error
+	// MoveTask sets a task's status and rank, touching no other row
+	MoveTask(id uint, status, rank string) error
+	// LastRank returns the highest rank in a Kanban column, or "" if it
+	// is empty
+	LastRank(column Column) (string, error)
+	// NextRank and PrevRank return the rank just after or before rank in
+	// a column, ignoring task excludeID, or "" if there is none
+	NextRank(column Column, rank string, excludeID uint) (string, error)
+	PrevRank(column Column, rank string, excludeID uint) (string, error)
+	// HasAncestor reports whether ancestorID is taskID's parent, or its
+	// parent's parent and so on
+	HasAncestor(taskID, ancestorID uint) (bool, error)
+	// Progress rolls up the subtasks of each of ids; tasks without
+	// subtasks are left out
+	Progress(ids []uint) (map[uint]models.Progress, error)
+
+	// Dependency operations
+	AddDependency(dep *models.TaskDependency) error
+	RemoveDependency(taskID, blockedByID uint) error
+	// ListBlockers returns the tasks taskID waits on
+	ListBlockers(taskID uint) ([]models.Task, error)
+	// ListBlocking returns the tasks waiting on taskID
+	ListBlocking(taskID uint) ([]models.Task, error)
+	// DependsOn reports whether taskID waits on otherID, directly or
+	// through other tasks
+	DependsOn(taskID, otherID uint) (bool, error)
+	// CountOpenBlockers counts the tasks taskID waits on that are not done
+	CountOpenBlockers(taskID uint) (int64, error)
 
 	// User operations
 	CreateUser(user *models.User) error
 	GetUserByID(id uint) (*models.User, error)
 	GetUserByEmail(email string) (*models.User, error)
+	GetUsersByUsernames(usernames []string) ([]models.User, error)
 	UpdateUser(user *models.User) error
+	// GetUserByCalendarToken finds the user whose calendar token hashes
+	// to hash
+	GetUserByCalendarToken(hash string) (*models.User, error)
+	// CalendarTasks returns the unfinished tasks with a due date that are
+	// userID's: their personal tasks and the project tasks assigned to
+	// them
+	CalendarTasks(userID uint) ([]models.Task, error)
+
+	// Reminder operations
+	// SetReminders replaces a task's reminders
+	SetReminders(taskID uint, reminders []models.Reminder) error
+	// RescheduleReminders moves a task's reminders to a new due date and
+	// sends them again
+	RescheduleReminders(taskID uint, due *time.Time) error
+	// DueReminders returns up to limit unsent reminders whose time has come
+	// for unfinished tasks not yet due, with their tasks. Reminders that
+	// failed maxAttempts times are left out.
+	DueReminders(now time.Time, maxAttempts, limit int) ([]models.Reminder, error)
+	MarkReminderSent(id uint, at time.Time) error
+	MarkReminderFailed(id uint, reason string) error
+
+	// Project operations
+	CreateProject(project *models.Project) error
+	GetProjectByID(id uint) (*models.Project, error)
+	// ListProjects returns the projects userID is a member of
+	ListProjects(userID uint) ([]models.Project, error)
+	UpdateProject(project *models.Project) error
+	// DeleteProject deletes the project with everything in it
+	DeleteProject(id uint) error
+
+	// Member operations
+	AddMember(member *models.ProjectMember) error
+	GetMember(projectID, userID uint) (*models.ProjectMember, error)
+	ListMembers(projectID uint) ([]models.ProjectMember, error)
+	UpdateMember(member *models.ProjectMember) error
+	// RemoveMember removes a member and unassigns their tasks in the
+	// project
+	RemoveMember(projectID, userID uint) error
+	CountOwners(projectID uint) (int64, error)
+
+	// Label operations
+	CreateLabel(label *models.Label) error
+	GetLabelByID(id uint) (*models.Label, error)
+	GetLabelsByIDs(ids []uint) ([]models.Label, error)
+	ListLabels(projectID uint) ([]models.Label, error)
+	DeleteLabel(id uint) error
+
+	// Comment operations
+	CreateComment(comment *models.Comment) error
+	GetCommentByID(id uint) (*models.Comment, error)
+	// ListComments returns a task's comments with their mentions, oldest
+	// first
+	ListComments(taskID uint) ([]models.Comment, error)
+
+	// Activity operations
+	CreateActivity(activity *models.Activity) error
+	// ListActivity returns a page of a project's feed, newest first
+	ListActivity(projectID uint, limit, offset int) ([]models.Activity, error)
 }
 
-// PostgresRepository implements Repository interface
+// PostgresRepository implements Repository interface on GORM. Open the
+// database with TranslateError set so duplicates surface as ErrDuplicate.
 type PostgresRepository struct {
-	db interface{} // This will be replaced with *gorm.DB
+	db *gorm.DB
 }
 
 // NewPostgresRepository creates a new postgres repository
-func NewPostgresRepository(db interface{}) Repository {
+func NewPostgresRepository(db *gorm.DB) Repository {
 	return &PostgresRepository{
 		db: db,
 	}
 }
+
+func (r *PostgresRepository) Transaction(fn func(repo Repository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&PostgresRepository{db: tx})
+	})
+}
+
+// translate maps GORM errors to the repository's
+func translate(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	}
+	return err
+}
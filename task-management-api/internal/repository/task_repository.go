@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/task-management-api/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Default and largest page sizes for ListTasks
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// sortColumns maps the fields tasks can be sorted by to SQL. Priorities
+// sort by rank rather than alphabetically, and tasks without a due date
+// come last either way.
+var sortColumns = map[string][]string{
+	"title":      {"title"},
+	"status":     {"CASE status WHEN 'todo' THEN 1 WHEN 'in_progress' THEN 2 ELSE 3 END"},
+	"priority":   {"CASE priority WHEN 'low' THEN 1 WHEN 'medium' THEN 2 ELSE 3 END"},
+	"due_date":   {"due_date IS NULL", "due_date"},
+	"created_at": {"created_at"},
+	"updated_at": {"updated_at"},
+	"rank":       {"rank"},
+}
+
+// ValidSortField reports whether tasks can be sorted by field, with or
+// without a leading "-"
+func ValidSortField(field string) bool {
+	_, ok := sortColumns[strings.TrimPrefix(field, "-")]
+	return ok
+}
+
+// CreateTask creates a task; labels and reminders are set separately
+func (r *PostgresRepository) CreateTask(task *models.Task) error {
+	return translate(r.db.Omit(clause.Associations).Create(task).Error)
+}
+
+func (r *PostgresRepository) GetTaskByID(id uint) (*models.Task, error) {
+	var task models.Task
+	err := r.db.Preload("Labels").Preload("Reminders", func(db *gorm.DB) *gorm.DB {
+		return db.Order("before_minutes DESC")
+	}).First(&task, id).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &task, nil
+}
+
+// UpdateTask saves a task's own fields; labels and reminders are set
+// separately
+func (r *PostgresRepository) UpdateTask(task *models.Task) error {
+	return translate(r.db.Omit(clause.Associations).Save(task).Error)
+}
+
+func (r *PostgresRepository) DeleteTask(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		err := tx.Raw(`WITH RECURSIVE tree(id) AS (
+				SELECT id FROM tasks WHERE id = ?
+				UNION ALL
+				SELECT tasks.id FROM tasks JOIN tree ON tasks.parent_id = tree.id
+			) SELECT id FROM tree`, id).Scan(&ids).Error
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return ErrNotFound
+		}
+
+		comments := tx.Model(&models.Comment{}).Select("id").Where("task_id IN ?", ids)
+		steps := []*gorm.DB{
+			tx.Exec("DELETE FROM comment_mentions WHERE comment_id IN (?)", comments),
+			tx.Where("task_id IN ?", ids).Delete(&models.Comment{}),
+			tx.Exec("DELETE FROM task_labels WHERE task_id IN ?", ids),
+			tx.Where("task_id IN ? OR blocked_by_id IN ?", ids, ids).Delete(&models.TaskDependency{}),
+			tx.Where("task_id IN ?", ids).Delete(&models.Reminder{}),
+			tx.Delete(&models.Task{}, ids),
+		}
+		for _, step := range steps {
+			if step.Error != nil {
+				return step.Error
+			}
+		}
+		return nil
+	})
+}
+
+// SetTaskLabels replaces a task's labels
+func (r *PostgresRepository) SetTaskLabels(task *models.Task, labels []models.Label) error {
+	if err := r.db.Model(task).Association("Labels").Replace(labels); err != nil {
+		return err
+	}
+	task.Labels = labels
+	return nil
+}
+
+func (r *PostgresRepository) ListTasks(filter TaskFilter) ([]models.Task, int64, error) {
+	memberOf := r.db.Model(&models.ProjectMember{}).Select("project_id").Where("user_id = ?", filter.UserID)
+	query := r.db.Model(&models.Task{}).
+		Where("((project_id IS NULL AND user_id = ?) OR project_id IN (?))", filter.UserID, memberOf)
+	if filter.ProjectID != nil {
+		query = query.Where("project_id = ?", *filter.ProjectID)
+	}
+	if filter.AssigneeID != nil {
+		query = query.Where("assignee_id = ?", *filter.AssigneeID)
+	}
+	if filter.LabelID != nil {
+		labelled := r.db.Table("task_labels").Select("task_id").Where("label_id = ?", *filter.LabelID)
+		query = query.Where("id IN (?)", labelled)
+	}
+	if filter.ParentID != nil {
+		query = query.Where("parent_id = ?", *filter.ParentID)
+	}
+	if filter.Ready {
+		blocked := r.db.Table("task_dependencies AS d").Select("1").
+			Joins("JOIN tasks AS blocker ON blocker.id = d.blocked_by_id").
+			Where("d.task_id = tasks.id AND blocker.status <> ?", models.StatusDone)
+		query = query.Where("tasks.status = ? AND NOT EXISTS (?)", models.StatusTodo, blocked)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if len(filter.Priorities) > 0 {
+		query = query.Where("priority IN ?", filter.Priorities)
+	}
+	if filter.DueFrom != nil {
+		query = query.Where("due_date >= ?", *filter.DueFrom)
+	}
+	if filter.DueTo != nil {
+		query = query.Where("due_date <= ?", *filter.DueTo)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	for _, field := range filter.Sort {
+		desc := strings.HasPrefix(field, "-")
+		columns, ok := sortColumns[strings.TrimPrefix(field, "-")]
+		if !ok {
+			return nil, 0, fmt.Errorf("cannot sort tasks by %q", field)
+		}
+		for _, column := range columns {
+			if desc && column != "due_date IS NULL" {
+				column += " DESC"
+			}
+			query = query.Order(column)
+		}
+	}
+	// Newest first by default, and a stable order between pages
+	if len(filter.Sort) == 0 {
+		query = query.Order("created_at DESC")
+	}
+	query = query.Order("id")
+
+	pageSize := filter.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+	page := filter.Page
+	if page < 1 {
+		page = 1
+	}
+
+	var tasks []models.Task
+	err := query.Preload("Labels").Limit(pageSize).Offset((page - 1) * pageSize).Find(&tasks).Error
+	return tasks, total, err
+}
+
+func (r *PostgresRepository) MoveTask(id uint, status, rank string) error {
+	result := r.db.Model(&models.Task{ID: id}).Updates(map[string]interface{}{"status": status, "rank": rank})
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) LastRank(column Column) (string, error) {
+	return r.rankIn(column, "MAX(rank)")
+}
+
+func (r *PostgresRepository) NextRank(column Column, rank string, excludeID uint) (string, error) {
+	return r.rankIn(column, "MIN(rank)", "rank > ? AND id <> ?", rank, excludeID)
+}
+
+func (r *PostgresRepository) PrevRank(column Column, rank string, excludeID uint) (string, error) {
+	return r.rankIn(column, "MAX(rank)", "rank < ? AND id <> ?", rank, excludeID)
+}
+
+// rankIn selects an aggregate of the ranks in a column, narrowed by an
+// optional condition
+func (r *PostgresRepository) rankIn(column Column, aggregate string, conds ...interface{}) (string, error) {
+	query := r.db.Model(&models.Task{}).Where("status = ?", column.Status)
+	if column.ProjectID != nil {
+		query = query.Where("project_id = ?", *column.ProjectID)
+	} else {
+		query = query.Where("project_id IS NULL AND user_id = ?", column.UserID)
+	}
+	if len(conds) > 0 {
+		query = query.Where(conds[0], conds[1:]...)
+	}
+	var rank *string
+	if err := query.Select(aggregate).Scan(&rank).Error; err != nil {
+		return "", err
+	}
+	if rank == nil {
+		return "", nil
+	}
+	return *rank, nil
+}
+
+func (r *PostgresRepository) HasAncestor(taskID, ancestorID uint) (bool, error) {
+	var n int64
+	err := r.db.Raw(`WITH RECURSIVE up(id) AS (
+			SELECT parent_id FROM tasks WHERE id = ?
+			UNION
+			SELECT tasks.parent_id FROM tasks JOIN up ON tasks.id = up.id
+		) SELECT COUNT(*) FROM up WHERE id = ?`, taskID, ancestorID).Scan(&n).Error
+	return n > 0, err
+}
+
+func (r *PostgresRepository) Progress(ids []uint) (map[uint]models.Progress, error) {
+	progress := map[uint]models.Progress{}
+	if len(ids) == 0 {
+		return progress, nil
+	}
+	var rows []struct {
+		RootID uint
+		Total  int
+		Done   int
+	}
+	// Walk down from each task, then count the leaves under it
+	err := r.db.Raw(`WITH RECURSIVE tree(root_id, id) AS (
+			SELECT parent_id, id FROM tasks WHERE parent_id IN ?
+			UNION ALL
+			SELECT tree.root_id, tasks.id FROM tasks JOIN tree ON tasks.parent_id = tree.id
+		)
+		SELECT tree.root_id AS root_id, COUNT(*) AS total,
+			SUM(CASE WHEN tasks.status = ? THEN 1 ELSE 0 END) AS done
+		FROM tree JOIN tasks ON tasks.id = tree.id
+		WHERE NOT EXISTS (SELECT 1 FROM tasks AS child WHERE child.parent_id = tasks.id)
+		GROUP BY tree.root_id`, ids, models.StatusDone).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		progress[row.RootID] = models.Progress{Done: row.Done, Total: row.Total, Percent: row.Done * 100 / row.Total}
+	}
+	return progress, nil
+}
+
+func (r *PostgresRepository) AddDependency(dep *models.TaskDependency) error {
+	return translate(r.db.Create(dep).Error)
+}
+
+func (r *PostgresRepository) RemoveDependency(taskID, blockedByID uint) error {
+	result := r.db.Where("task_id = ? AND blocked_by_id = ?", taskID, blockedByID).Delete(&models.TaskDependency{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ListBlockers(taskID uint) ([]models.Task, error) {
+	var tasks []models.Task
+	blockers := r.db.Model(&models.TaskDependency{}).Select("blocked_by_id").Where("task_id = ?", taskID)
+	err := r.db.Where("id IN (?)", blockers).Order("id").Find(&tasks).Error
+	return tasks, err
+}
+
+func (r *PostgresRepository) ListBlocking(taskID uint) ([]models.Task, error) {
+	var tasks []models.Task
+	blocked := r.db.Model(&models.TaskDependency{}).Select("task_id").Where("blocked_by_id = ?", taskID)
+	err := r.db.Where("id IN (?)", blocked).Order("id").Find(&tasks).Error
+	return tasks, err
+}
+
+func (r *PostgresRepository) DependsOn(taskID, otherID uint) (bool, error) {
+	var n int64
+	// UNION rather than UNION ALL stops the walk at tasks already seen
+	err := r.db.Raw(`WITH RECURSIVE deps(id) AS (
+			SELECT blocked_by_id FROM task_dependencies WHERE task_id = ?
+			UNION
+			SELECT task_dependencies.blocked_by_id FROM task_dependencies JOIN deps ON task_dependencies.task_id = deps.id
+		) SELECT COUNT(*) FROM deps WHERE id = ?`, taskID, otherID).Scan(&n).Error
+	return n > 0, err
+}
+
+func (r *PostgresRepository) CountOpenBlockers(taskID uint) (int64, error) {
+	var n int64
+	err := r.db.Table("task_dependencies AS d").
+		Joins("JOIN tasks AS blocker ON blocker.id = d.blocked_by_id").
+		Where("d.task_id = ? AND blocker.status <> ?", taskID, models.StatusDone).
+		Count(&n).Error
+	return n, err
+}
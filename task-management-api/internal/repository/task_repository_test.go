@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/task-management-api/internal/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestRepository(t *testing.T) Repository {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	return NewPostgresRepository(db)
+}
+
+func day(d int) *time.Time {
+	t := time.Date(2025, 3, d, 12, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func titles(tasks []models.Task) []string {
+	var out []string
+	for _, task := range tasks {
+		out = append(out, task.Title)
+	}
+	return out
+}
+
+func TestListTasks(t *testing.T) {
+	repo := newTestRepository(t)
+	owner := &models.User{Username: "owner", Email: "owner@example.com", Password: "x"}
+	other := &models.User{Username: "other", Email: "other@example.com", Password: "x"}
+	for _, u := range []*models.User{owner, other} {
+		if err := repo.CreateUser(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, task := range []models.Task{
+		{Title: "a", Status: models.StatusTodo, Priority: models.PriorityLow, DueDate: day(3), UserID: owner.ID},
+		{Title: "b", Status: models.StatusDone, Priority: models.PriorityHigh, DueDate: day(1), UserID: owner.ID},
+		{Title: "c", Status: models.StatusInProgress, Priority: models.PriorityMedium, UserID: owner.ID},
+		{Title: "d", Status: models.StatusTodo, Priority: models.PriorityHigh, DueDate: day(5), UserID: owner.ID},
+		{Title: "theirs", Status: models.StatusTodo, Priority: models.PriorityHigh, DueDate: day(2), UserID: other.ID},
+	} {
+		task := task
+		if err := repo.CreateTask(&task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter TaskFilter
+		want   string
+		total  int64
+	}{
+		{"by title", TaskFilter{Sort: []string{"title"}}, "[a b c d]", 4},
+		{"status", TaskFilter{Statuses: []string{models.StatusTodo}, Sort: []string{"title"}}, "[a d]", 2},
+		{"priority", TaskFilter{Priorities: []string{models.PriorityHigh, models.PriorityMedium}, Sort: []string{"title"}}, "[b c d]", 3},
+		{"due range", TaskFilter{DueFrom: day(2), DueTo: day(5), Sort: []string{"title"}}, "[a d]", 2},
+		// Priority sorts by rank, not alphabetically
+		{"priority rank", TaskFilter{Sort: []string{"-priority", "title"}}, "[b d c a]", 4},
+		// Tasks without a due date come last in either direction
+		{"due date", TaskFilter{Sort: []string{"due_date"}}, "[b a d c]", 4},
+		{"due date descending", TaskFilter{Sort: []string{"-due_date"}}, "[d a b c]", 4},
+		{"second page", TaskFilter{Sort: []string{"title"}, Page: 2, PageSize: 3}, "[d]", 4},
+	}
+	for _, tt := range tests {
+		tt.filter.UserID = owner.ID
+		tasks, total, err := repo.ListTasks(tt.filter)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := fmt.Sprint(titles(tasks)); got != tt.want || total != tt.total {
+			t.Errorf("%s: got %s of %d, want %s of %d", tt.name, got, total, tt.want, tt.total)
+		}
+	}
+
+	if _, _, err := repo.ListTasks(TaskFilter{UserID: owner.ID, Sort: []string{"password"}}); err == nil {
+		t.Error("sorting by an unknown field succeeded")
+	}
+}
+
+func TestUsers(t *testing.T) {
+	repo := newTestRepository(t)
+	user := &models.User{Username: "ada", Email: "Ada@Example.com", Password: "x"}
+	if err := repo.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := repo.GetUserByEmail("ADA@example.COM")
+	if err != nil || found.ID != user.ID {
+		t.Errorf("lookup by email ignoring case: %v, %v", found, err)
+	}
+	if _, err := repo.GetUserByEmail("nobody@example.com"); err != ErrNotFound {
+		t.Errorf("unknown email: got %v, want ErrNotFound", err)
+	}
+	dup := &models.User{Username: "ada2", Email: "ada@example.com", Password: "x"}
+	if err := repo.CreateUser(dup); err != ErrDuplicate {
+		t.Errorf("duplicate email: got %v, want ErrDuplicate", err)
+	}
+}
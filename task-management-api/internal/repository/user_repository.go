@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/task-management-api/internal/models"
+)
+
+func (r *PostgresRepository) CreateUser(user *models.User) error {
+	user.Email = strings.ToLower(user.Email)
+	return translate(r.db.Create(user).Error)
+}
+
+func (r *PostgresRepository) GetUserByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+// GetUserByEmail looks a user up by email, ignoring case
+func (r *PostgresRepository) GetUserByEmail(email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("email = ?", strings.ToLower(email)).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *PostgresRepository) GetUsersByUsernames(usernames []string) ([]models.User, error) {
+	var users []models.User
+	if len(usernames) == 0 {
+		return users, nil
+	}
+	err := r.db.Where("username IN ?", usernames).Find(&users).Error
+	return users, err
+}
+
+func (r *PostgresRepository) UpdateUser(user *models.User) error {
+	user.Email = strings.ToLower(user.Email)
+	return translate(r.db.Save(user).Error)
+}
+
+func (r *PostgresRepository) GetUserByCalendarToken(hash string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("calendar_token_hash = ?", hash).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *PostgresRepository) CalendarTasks(userID uint) ([]models.Task, error) {
+	var tasks []models.Task
+	memberOf := r.db.Model(&models.ProjectMember{}).Select("project_id").Where("user_id = ?", userID)
+	err := r.db.Preload("Reminders").
+		Where("due_date IS NOT NULL AND status <> ?", models.StatusDone).
+		Where("((project_id IS NULL AND user_id = ?) OR (project_id IN (?) AND assignee_id = ?))", userID, memberOf, userID).
+		Order("due_date").Order("id").
+		Find(&tasks).Error
+	return tasks, err
+}
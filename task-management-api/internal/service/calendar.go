@@ -0,0 +1,59 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+
+	"github.com/task-management-api/internal/models"
+)
+
+// IssueCalendarToken gives userID a new calendar feed token, replacing any
+// old one. Only its hash is stored, so the token is returned this once.
+func (s *Service) IssueCalendarToken(userID uint) (string, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	hash := hashToken(token)
+	user.CalendarTokenHash = &hash
+	if err := s.repo.UpdateUser(user); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RevokeCalendarToken turns userID's calendar feed off
+func (s *Service) RevokeCalendarToken(userID uint) error {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	user.CalendarTokenHash = nil
+	return s.repo.UpdateUser(user)
+}
+
+// CalendarFeed returns the owner of a calendar token and the tasks in
+// their feed. An unknown token is reported as not found.
+func (s *Service) CalendarFeed(token string) (*models.User, []models.Task, error) {
+	user, err := s.repo.GetUserByCalendarToken(hashToken(token))
+	if err != nil {
+		return nil, nil, err
+	}
+	tasks, err := s.repo.CalendarTasks(user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tasks, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/repository"
+)
+
+// mentionPattern matches @username; a trailing full stop ends the sentence
+// rather than the username
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.@])@([A-Za-z0-9_.-]+)`)
+
+// ListComments returns a task's comments as threads, oldest first, with
+// each thread's replies under it
+func (s *Service) ListComments(userID, taskID uint) ([]models.Comment, error) {
+	if _, err := loadTask(s.repo, userID, taskID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	comments, err := s.repo.ListComments(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	threads := []models.Comment{}
+	index := map[uint]int{}
+	for _, comment := range comments {
+		if comment.ParentID == nil {
+			index[comment.ID] = len(threads)
+			threads = append(threads, comment)
+		}
+	}
+	for _, comment := range comments {
+		if comment.ParentID == nil {
+			continue
+		}
+		if i, ok := index[*comment.ParentID]; ok {
+			threads[i].Replies = append(threads[i].Replies, comment)
+		}
+	}
+	return threads, nil
+}
+
+// AddComment comments on a task, or replies to parentID when it is set.
+// Replying to a reply joins its thread. Users mentioned with @username
+// who can see the task are linked to the comment. Editors and owners may
+// comment on project tasks.
+func (s *Service) AddComment(userID, taskID uint, parentID *uint, body string) (*models.Comment, error) {
+	comment := &models.Comment{TaskID: taskID, UserID: userID, Body: body}
+	err := s.repo.Transaction(func(repo repository.Repository) error {
+		task, err := loadTask(repo, userID, taskID, models.RoleEditor)
+		if err != nil {
+			return err
+		}
+		if parentID != nil {
+			parent, err := repo.GetCommentByID(*parentID)
+			if isNotFound(err) || (err == nil && parent.TaskID != taskID) {
+				return ErrInvalidParent
+			}
+			if err != nil {
+				return err
+			}
+			comment.ParentID = &parent.ID
+			if parent.ParentID != nil {
+				comment.ParentID = parent.ParentID
+			}
+		}
+		if comment.Mentions, err = mentions(repo, task, body); err != nil {
+			return err
+		}
+		if err := repo.CreateComment(comment); err != nil {
+			return err
+		}
+		if task.ProjectID == nil {
+			return nil
+		}
+		return record(repo, userID, *task.ProjectID, &task.ID, models.ActivityCommentAdded, "commented on task %q", task.Title)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// mentions resolves the @usernames in body to the users who can see task
+func mentions(repo repository.Repository, task *models.Task, body string) ([]models.User, error) {
+	var usernames []string
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		name := strings.TrimRight(match[1], ".")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			usernames = append(usernames, name)
+		}
+	}
+	users, err := repo.GetUsersByUsernames(usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	var visible []models.User
+	for _, user := range users {
+		err := authorizeTask(repo, user.ID, task, models.RoleViewer)
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		visible = append(visible, user)
+	}
+	return visible, nil
+}
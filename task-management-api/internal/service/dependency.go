@@ -0,0 +1,86 @@
+package service
+
+import (
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/repository"
+)
+
+// AddDependency makes taskID wait on blockedByID, which must be in the
+// same project. Editors and owners may, as long as no task ends up
+// waiting on itself.
+func (s *Service) AddDependency(userID, taskID, blockedByID uint) (*models.TaskDependency, error) {
+	dep := &models.TaskDependency{TaskID: taskID, BlockedByID: blockedByID}
+	err := s.repo.Transaction(func(repo repository.Repository) error {
+		task, err := loadTask(repo, userID, taskID, models.RoleEditor)
+		if err != nil {
+			return err
+		}
+		blocker, err := loadTask(repo, userID, blockedByID, models.RoleViewer)
+		if isNotFound(err) {
+			return ErrInvalidDependency
+		}
+		if err != nil {
+			return err
+		}
+		// Personal tasks loaded for userID are both theirs
+		if !equalID(task.ProjectID, blocker.ProjectID) {
+			return ErrInvalidDependency
+		}
+
+		if blocker.ID == task.ID {
+			return ErrDependencyCycle
+		}
+		cycle, err := repo.DependsOn(blocker.ID, task.ID)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrDependencyCycle
+		}
+
+		if err := repo.AddDependency(dep); err != nil {
+			return err
+		}
+		if task.ProjectID == nil {
+			return nil
+		}
+		return record(repo, userID, *task.ProjectID, &task.ID, models.ActivityDependencyAdded, "made task %q wait on %q", task.Title, blocker.Title)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dep, nil
+}
+
+// RemoveDependency stops taskID waiting on blockedByID; editors and owners
+// may
+func (s *Service) RemoveDependency(userID, taskID, blockedByID uint) error {
+	return s.repo.Transaction(func(repo repository.Repository) error {
+		task, err := loadTask(repo, userID, taskID, models.RoleEditor)
+		if err != nil {
+			return err
+		}
+		if err := repo.RemoveDependency(taskID, blockedByID); err != nil {
+			return err
+		}
+		if task.ProjectID == nil {
+			return nil
+		}
+		return record(repo, userID, *task.ProjectID, &task.ID, models.ActivityDependencyRemoved, "task %q no longer waits on task %d", task.Title, blockedByID)
+	})
+}
+
+// Dependencies returns the tasks taskID waits on and the tasks waiting on
+// it
+func (s *Service) Dependencies(userID, taskID uint) (blockedBy, blocking []models.Task, err error) {
+	if _, err := loadTask(s.repo, userID, taskID, models.RoleViewer); err != nil {
+		return nil, nil, err
+	}
+	if blockedBy, err = s.repo.ListBlockers(taskID); err != nil {
+		return nil, nil, err
+	}
+	if blocking, err = s.repo.ListBlocking(taskID); err != nil {
+		return nil, nil, err
+	}
+	return blockedBy, blocking, nil
+}
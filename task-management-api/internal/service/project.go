@@ -0,0 +1,222 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/repository"
+)
+
+// CreateProject creates a project owned by userID
+func (s *Service) CreateProject(userID uint, project *models.Project) error {
+	return s.repo.Transaction(func(repo repository.Repository) error {
+		if err := repo.CreateProject(project); err != nil {
+			return err
+		}
+		owner := models.ProjectMember{ProjectID: project.ID, UserID: userID, Role: models.RoleOwner}
+		if err := repo.AddMember(&owner); err != nil {
+			return err
+		}
+		return record(repo, userID, project.ID, nil, models.ActivityProjectCreated, "created project %q", project.Name)
+	})
+}
+
+func (s *Service) GetProject(userID, projectID uint) (*models.Project, error) {
+	if _, err := authorize(s.repo, userID, projectID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.GetProjectByID(projectID)
+}
+
+// ListProjects returns the projects userID is a member of
+func (s *Service) ListProjects(userID uint) ([]models.Project, error) {
+	return s.repo.ListProjects(userID)
+}
+
+// UpdateProject saves a project's name and description; only owners may
+func (s *Service) UpdateProject(userID uint, project *models.Project) error {
+	return s.repo.Transaction(func(repo repository.Repository) error {
+		if _, err := authorize(repo, userID, project.ID, models.RoleOwner); err != nil {
+			return err
+		}
+		if err := repo.UpdateProject(project); err != nil {
+			return err
+		}
+		return record(repo, userID, project.ID, nil, models.ActivityProjectUpdated, "updated project %q", project.Name)
+	})
+}
+
+// DeleteProject deletes a project with its tasks, labels and feed; only
+// owners may
+func (s *Service) DeleteProject(userID, projectID uint) error {
+	return s.repo.Transaction(func(repo repository.Repository) error {
+		if _, err := authorize(repo, userID, projectID, models.RoleOwner); err != nil {
+			return err
+		}
+		return repo.DeleteProject(projectID)
+	})
+}
+
+func (s *Service) ListMembers(userID, projectID uint) ([]models.ProjectMember, error) {
+	if _, err := authorize(s.repo, userID, projectID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.ListMembers(projectID)
+}
+
+// AddMember gives memberID a role in the project; only owners may
+func (s *Service) AddMember(userID, projectID, memberID uint, role models.Role) (*models.ProjectMember, error) {
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+	var member *models.ProjectMember
+	err := s.repo.Transaction(func(repo repository.Repository) error {
+		if _, err := authorize(repo, userID, projectID, models.RoleOwner); err != nil {
+			return err
+		}
+		user, err := repo.GetUserByID(memberID)
+		if err != nil {
+			return err
+		}
+		member = &models.ProjectMember{ProjectID: projectID, UserID: memberID, Role: role}
+		if err := repo.AddMember(member); err != nil {
+			return err
+		}
+		member.User = user
+		return record(repo, userID, projectID, nil, models.ActivityMemberAdded, "added %s as %s", user.Username, role)
+	})
+	return member, err
+}
+
+// UpdateMember changes a member's role; only owners may, and the last
+// owner cannot be demoted
+func (s *Service) UpdateMember(userID, projectID, memberID uint, role models.Role) (*models.ProjectMember, error) {
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+	var member *models.ProjectMember
+	err := s.repo.Transaction(func(repo repository.Repository) error {
+		if _, err := authorize(repo, userID, projectID, models.RoleOwner); err != nil {
+			return err
+		}
+		var err error
+		if member, err = repo.GetMember(projectID, memberID); err != nil {
+			return err
+		}
+		if member.Role == role {
+			return nil
+		}
+		if member.Role == models.RoleOwner {
+			if err := keepOwner(repo, projectID); err != nil {
+				return err
+			}
+		}
+		member.Role = role
+		if err := repo.UpdateMember(member); err != nil {
+			return err
+		}
+		if member.User, err = repo.GetUserByID(memberID); err != nil {
+			return err
+		}
+		return record(repo, userID, projectID, nil, models.ActivityMemberRoleChanged, "made %s %s", member.User.Username, role)
+	})
+	return member, err
+}
+
+// RemoveMember takes memberID out of the project and unassigns their
+// tasks. Owners may remove anyone and members may remove themselves, but
+// the last owner cannot leave.
+func (s *Service) RemoveMember(userID, projectID, memberID uint) error {
+	return s.repo.Transaction(func(repo repository.Repository) error {
+		need := models.RoleOwner
+		if memberID == userID {
+			need = models.RoleViewer
+		}
+		if _, err := authorize(repo, userID, projectID, need); err != nil {
+			return err
+		}
+		member, err := repo.GetMember(projectID, memberID)
+		if err != nil {
+			return err
+		}
+		if member.Role == models.RoleOwner {
+			if err := keepOwner(repo, projectID); err != nil {
+				return err
+			}
+		}
+		user, err := repo.GetUserByID(memberID)
+		if err != nil {
+			return err
+		}
+		if err := repo.RemoveMember(projectID, memberID); err != nil {
+			return err
+		}
+		return record(repo, userID, projectID, nil, models.ActivityMemberRemoved, "removed %s", user.Username)
+	})
+}
+
+// keepOwner fails if the project has only one owner left
+func keepOwner(repo repository.Repository, projectID uint) error {
+	owners, err := repo.CountOwners(projectID)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func (s *Service) ListLabels(userID, projectID uint) ([]models.Label, error) {
+	if _, err := authorize(s.repo, userID, projectID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.ListLabels(projectID)
+}
+
+// CreateLabel adds a label to a project; editors and owners may
+func (s *Service) CreateLabel(userID uint, label *models.Label) error {
+	return s.repo.Transaction(func(repo repository.Repository) error {
+		if _, err := authorize(repo, userID, label.ProjectID, models.RoleEditor); err != nil {
+			return err
+		}
+		if err := repo.CreateLabel(label); err != nil {
+			return err
+		}
+		return record(repo, userID, label.ProjectID, nil, models.ActivityLabelCreated, "created label %q", label.Name)
+	})
+}
+
+// DeleteLabel removes a label from a project and its tasks; editors and
+// owners may
+func (s *Service) DeleteLabel(userID, projectID, labelID uint) error {
+	return s.repo.Transaction(func(repo repository.Repository) error {
+		if _, err := authorize(repo, userID, projectID, models.RoleEditor); err != nil {
+			return err
+		}
+		label, err := repo.GetLabelByID(labelID)
+		if err != nil {
+			return err
+		}
+		if label.ProjectID != projectID {
+			return repository.ErrNotFound
+		}
+		if err := repo.DeleteLabel(labelID); err != nil {
+			return err
+		}
+		return record(repo, userID, projectID, nil, models.ActivityLabelDeleted, "deleted label %q", label.Name)
+	})
+}
+
+// ListActivity returns a page of a project's feed, newest first
+func (s *Service) ListActivity(userID, projectID uint, limit, offset int) ([]models.Activity, error) {
+	if _, err := authorize(s.repo, userID, projectID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.ListActivity(projectID, limit, offset)
+}
+
+// isNotFound reports whether err means a record is missing
+func isNotFound(err error) bool {
+	return errors.Is(err, repository.ErrNotFound)
+}
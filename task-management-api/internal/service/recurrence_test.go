@@ -0,0 +1,188 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/recurrence"
+	"github.com/task-management-api/internal/repository"
+)
+
+func TestRecurringTasks(t *testing.T) {
+	f := newFixture(t)
+	f.svc.now = func() time.Time { return time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC) }
+	due := time.Date(2025, 3, 31, 9, 0, 0, 0, time.UTC)
+
+	label := models.Label{ProjectID: f.project.ID, Name: "bills"}
+	if err := f.svc.CreateLabel(f.editor, &label); err != nil {
+		t.Fatal(err)
+	}
+	task := &models.Task{Title: "Pay rent", Status: models.StatusTodo, DueDate: &due, ProjectID: &f.project.ID, AssigneeID: &f.viewer, Recurrence: "FREQ=MONTHLY"}
+	if err := f.svc.CreateTask(f.editor, task); err != nil {
+		t.Fatal(err)
+	}
+	if task.Recurrence != "FREQ=MONTHLY;BYMONTHDAY=31" {
+		t.Errorf("recurrence = %s, want it anchored to the 31st", task.Recurrence)
+	}
+	if _, err := f.svc.SetTaskLabels(f.editor, task.ID, []uint{label.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.SetReminders(f.editor, task.ID, []time.Duration{time.Hour, 24 * time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	task, _ = f.svc.GetTask(f.editor, task.ID)
+	f.setStatus(t, task, models.StatusDone)
+	if task.NextOccurrenceID == nil {
+		t.Fatal("completing a recurring task created no next occurrence")
+	}
+	next, err := f.svc.GetTask(f.editor, *task.NextOccurrenceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Status != models.StatusTodo || !next.DueDate.Equal(time.Date(2025, 4, 30, 9, 0, 0, 0, time.UTC)) ||
+		next.AssigneeID == nil || *next.AssigneeID != f.viewer || next.Recurrence != task.Recurrence {
+		t.Errorf("next occurrence = %+v", next)
+	}
+	if len(next.Labels) != 1 || len(next.Reminders) != 2 || next.Reminders[0].BeforeMinutes != 24*60 {
+		t.Errorf("next occurrence labels %+v, reminders %+v", next.Labels, next.Reminders)
+	}
+
+	// Reopening and completing again does not make a second occurrence
+	f.setStatus(t, task, models.StatusTodo)
+	f.setStatus(t, task, models.StatusDone)
+	if *task.NextOccurrenceID != next.ID {
+		t.Errorf("completing again made occurrence %d", *task.NextOccurrenceID)
+	}
+
+	// Finishing late skips the occurrences that were missed, and moving a
+	// card to done recurs too
+	late := time.Date(2025, 2, 20, 8, 0, 0, 0, time.UTC)
+	daily := &models.Task{Title: "Stand-up", Status: models.StatusTodo, DueDate: &late, Recurrence: "FREQ=DAILY"}
+	if err := f.svc.CreateTask(f.editor, daily); err != nil {
+		t.Fatal(err)
+	}
+	moved, err := f.svc.MoveTask(f.editor, daily.ID, models.StatusDone, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.NextOccurrenceID == nil {
+		t.Fatal("moving to done created no next occurrence")
+	}
+	next, _ = f.svc.GetTask(f.editor, *moved.NextOccurrenceID)
+	if !next.DueDate.Equal(time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("late daily task next due %v, want 2025-03-01 08:00", next.DueDate)
+	}
+
+	// A rule that has run out ends the series
+	last := &models.Task{Title: "Course", Status: models.StatusTodo, DueDate: &due, Recurrence: "FREQ=WEEKLY;UNTIL=20250401"}
+	if err := f.svc.CreateTask(f.editor, last); err != nil {
+		t.Fatal(err)
+	}
+	f.setStatus(t, last, models.StatusDone)
+	if last.NextOccurrenceID != nil {
+		t.Errorf("series continued past UNTIL")
+	}
+
+	if err := f.svc.CreateTask(f.editor, &models.Task{Title: "x", Status: models.StatusTodo, Recurrence: "FREQ=DAILY"}); !errors.Is(err, ErrNoDueDate) {
+		t.Errorf("recurring without a due date: got %v, want ErrNoDueDate", err)
+	}
+	if err := f.svc.CreateTask(f.editor, &models.Task{Title: "x", Status: models.StatusTodo, DueDate: &due, Recurrence: "FREQ=HOURLY"}); !errors.Is(err, recurrence.ErrInvalidRule) {
+		t.Errorf("invalid rule: got %v, want ErrInvalidRule", err)
+	}
+}
+
+func TestReminders(t *testing.T) {
+	f := newFixture(t)
+	task := f.task(t, f.editor, "Demo")
+	if _, err := f.svc.SetReminders(f.editor, task.ID, []time.Duration{time.Hour}); !errors.Is(err, ErrNoDueDate) {
+		t.Errorf("reminders without a due date: got %v, want ErrNoDueDate", err)
+	}
+
+	due := time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+	task.DueDate = &due
+	if err := f.svc.UpdateTask(f.editor, task); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.SetReminders(f.viewer, task.ID, []time.Duration{time.Hour}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer setting reminders: got %v, want ErrForbidden", err)
+	}
+	reminders, err := f.svc.SetReminders(f.editor, task.ID, []time.Duration{time.Hour, 24 * time.Hour, time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reminders) != 2 || !reminders[0].RemindAt.Equal(due.Add(-24*time.Hour)) || !reminders[1].RemindAt.Equal(due.Add(-time.Hour)) {
+		t.Errorf("reminders = %+v", reminders)
+	}
+
+	// Moving the due date moves the reminders
+	later := due.Add(48 * time.Hour)
+	task, _ = f.svc.GetTask(f.editor, task.ID)
+	task.DueDate = &later
+	if err := f.svc.UpdateTask(f.editor, task); err != nil {
+		t.Fatal(err)
+	}
+	reminders, err = f.svc.ListReminders(f.viewer, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reminders) != 2 || !reminders[1].RemindAt.Equal(later.Add(-time.Hour)) {
+		t.Errorf("rescheduled reminders = %+v", reminders)
+	}
+
+	if _, err := f.svc.ListReminders(f.outsider, task.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("outsider listing reminders: got %v, want ErrNotFound", err)
+	}
+	if _, err := f.svc.SetReminders(f.editor, task.ID, nil); err != nil {
+		t.Errorf("clearing reminders: %v", err)
+	}
+}
+
+func TestCalendarFeed(t *testing.T) {
+	f := newFixture(t)
+	due := time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+	for _, task := range []*models.Task{
+		{Title: "Assigned to vic", Status: models.StatusTodo, DueDate: &due, ProjectID: &f.project.ID, AssigneeID: &f.viewer},
+		{Title: "Unassigned", Status: models.StatusTodo, DueDate: &due, ProjectID: &f.project.ID},
+		{Title: "Done", Status: models.StatusDone, DueDate: &due, ProjectID: &f.project.ID, AssigneeID: &f.viewer},
+	} {
+		if err := f.svc.CreateTask(f.owner, task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.svc.CreateTask(f.viewer, &models.Task{Title: "Personal", Status: models.StatusTodo, DueDate: &due}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.svc.CreateTask(f.viewer, &models.Task{Title: "Someday", Status: models.StatusTodo}); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := f.svc.IssueCalendarToken(f.viewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, tasks, err := f.svc.CalendarFeed(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != f.viewer || len(tasks) != 2 || tasks[0].Title != "Assigned to vic" || tasks[1].Title != "Personal" {
+		t.Errorf("feed for %d: %+v", user.ID, tasks)
+	}
+
+	// A new token replaces the old one, and revoking turns the feed off
+	newToken, err := f.svc.IssueCalendarToken(f.viewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.svc.CalendarFeed(token); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("old token: got %v, want ErrNotFound", err)
+	}
+	if err := f.svc.RevokeCalendarToken(f.viewer); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.svc.CalendarFeed(newToken); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("revoked token: got %v, want ErrNotFound", err)
+	}
+}
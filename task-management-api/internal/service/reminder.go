@@ -0,0 +1,61 @@
+package service
+
+import (
+	"slices"
+	"time"
+
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/repository"
+)
+
+// ListReminders returns a task's reminders, earliest first
+func (s *Service) ListReminders(userID, taskID uint) ([]models.Reminder, error) {
+	task, err := loadTask(s.repo, userID, taskID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	return task.Reminders, nil
+}
+
+// SetReminders replaces a task's reminders with ones sent each of before
+// ahead of its due date; editors and owners may set them on project tasks
+func (s *Service) SetReminders(userID, taskID uint, before []time.Duration) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := s.repo.Transaction(func(repo repository.Repository) error {
+		task, err := loadTask(repo, userID, taskID, models.RoleEditor)
+		if err != nil {
+			return err
+		}
+		if len(before) > 0 && task.DueDate == nil {
+			return ErrNoDueDate
+		}
+		minutes := make([]int, len(before))
+		for i, d := range before {
+			minutes[i] = int(d / time.Minute)
+		}
+		reminders = schedule(task.DueDate, minutes)
+		return repo.SetReminders(task.ID, reminders)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+// schedule builds reminders the given minutes before due, earliest first
+// and without repeats
+func schedule(due *time.Time, before []int) []models.Reminder {
+	before = slices.Clone(before)
+	slices.Sort(before)
+	before = slices.Compact(before)
+	reminders := []models.Reminder{}
+	for i := len(before) - 1; i >= 0; i-- {
+		reminder := models.Reminder{BeforeMinutes: before[i]}
+		if due != nil {
+			at := due.Add(-time.Duration(before[i]) * time.Minute)
+			reminder.RemindAt = &at
+		}
+		reminders = append(reminders, reminder)
+	}
+	return reminders
+}
@@ -0,0 +1,113 @@
+// Package service holds the rules for who may do what. Every read and
+// change of projects, tasks, labels and comments goes through it, so
+// handlers never check permissions themselves.
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/repository"
+)
+
+var (
+	// ErrForbidden is returned when a user can see a project but their role
+	// does not allow the change
+	ErrForbidden = errors.New("your role in the project does not allow this")
+	// ErrNotMember is returned when a task is assigned to someone who
+	// cannot see it
+	ErrNotMember = errors.New("assignee must be a member of the task's project")
+	// ErrLastOwner is returned when a change would leave a project without
+	// an owner
+	ErrLastOwner = errors.New("a project must keep at least one owner")
+	// ErrInvalidRole is returned for a role other than owner, editor or
+	// viewer
+	ErrInvalidRole = errors.New("role must be owner, editor or viewer")
+	// ErrInvalidLabel is returned when a label is not from the task's
+	// project
+	ErrInvalidLabel = errors.New("labels must belong to the task's project")
+	// ErrInvalidParent is returned when a reply names a comment on another
+	// task
+	ErrInvalidParent = errors.New("parent comment is not on this task")
+	// ErrInvalidSubtask is returned when a subtask's parent is in another
+	// project or is one of the subtask's own subtasks
+	ErrInvalidSubtask = errors.New("parent task must be in the same project and not one of the task's subtasks")
+	// ErrInvalidDependency is returned when a task would wait on a task
+	// from another project
+	ErrInvalidDependency = errors.New("a task can only depend on tasks in the same project")
+	// ErrDependencyCycle is returned when a new dependency would make a
+	// task wait on itself
+	ErrDependencyCycle = errors.New("dependency would create a cycle")
+	// ErrBlocked is returned when a task is started while tasks it depends
+	// on are not done
+	ErrBlocked = errors.New("task is waiting on tasks that are not done")
+	// ErrInvalidPosition is returned when a task is moved next to a task
+	// outside the target column
+	ErrInvalidPosition = errors.New("neighbouring tasks must be in the target column")
+	// ErrNoDueDate is returned when a task without a due date is given a
+	// recurrence rule or reminders
+	ErrNoDueDate = errors.New("recurring tasks and reminders need a due date")
+)
+
+// Service enforces project roles on top of a repository. Projects, tasks
+// and the like that a user cannot see are reported as
+// repository.ErrNotFound, so their existence does not leak; ErrForbidden
+// means the user can see the thing but not change it.
+type Service struct {
+	repo repository.Repository
+	now  func() time.Time
+}
+
+func New(repo repository.Repository) *Service {
+	return &Service{repo: repo, now: time.Now}
+}
+
+// authorize checks that userID has at least need in the project
+func authorize(repo repository.Repository, userID, projectID uint, need models.Role) (*models.ProjectMember, error) {
+	member, err := repo.GetMember(projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !member.Role.Allows(need) {
+		return nil, ErrForbidden
+	}
+	return member, nil
+}
+
+// authorizeTask checks that userID has at least need on the task. A task
+// outside any project belongs to its creator alone.
+func authorizeTask(repo repository.Repository, userID uint, task *models.Task, need models.Role) error {
+	if task.ProjectID == nil {
+		if task.UserID != userID {
+			return repository.ErrNotFound
+		}
+		return nil
+	}
+	_, err := authorize(repo, userID, *task.ProjectID, need)
+	return err
+}
+
+// loadTask fetches a task userID has at least need on
+func loadTask(repo repository.Repository, userID, taskID uint, need models.Role) (*models.Task, error) {
+	task, err := repo.GetTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeTask(repo, userID, task, need); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// record adds an entry to a project's activity feed
+func record(repo repository.Repository, userID, projectID uint, taskID *uint, action, format string, args ...interface{}) error {
+	return repo.CreateActivity(&models.Activity{
+		ProjectID: projectID,
+		TaskID:    taskID,
+		UserID:    userID,
+		Action:    action,
+		Summary:   fmt.Sprintf(format, args...),
+	})
+}
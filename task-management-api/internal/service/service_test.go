@@ -0,0 +1,324 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/repository"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type fixture struct {
+	svc  *Service
+	repo repository.Repository
+	// owner, editor and viewer are members of project; outsider is not
+	owner, editor, viewer, outsider uint
+	project                         models.Project
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+
+	repo := repository.NewPostgresRepository(db)
+	f := &fixture{svc: New(repo), repo: repo}
+	for _, u := range []struct {
+		id   *uint
+		name string
+	}{{&f.owner, "olive"}, {&f.editor, "ed"}, {&f.viewer, "vic"}, {&f.outsider, "otto"}} {
+		user := models.User{Username: u.name, Email: u.name + "@example.com", Password: "x"}
+		if err := repo.CreateUser(&user); err != nil {
+			t.Fatal(err)
+		}
+		*u.id = user.ID
+	}
+
+	f.project = models.Project{Name: "Launch"}
+	if err := f.svc.CreateProject(f.owner, &f.project); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.AddMember(f.owner, f.project.ID, f.editor, models.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.AddMember(f.owner, f.project.ID, f.viewer, models.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *fixture) task(t *testing.T, userID uint, title string) *models.Task {
+	t.Helper()
+	task := &models.Task{Title: title, Status: models.StatusTodo, Priority: models.PriorityMedium, ProjectID: &f.project.ID}
+	if err := f.svc.CreateTask(userID, task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func TestRoles(t *testing.T) {
+	f := newFixture(t)
+	task := f.task(t, f.editor, "Write copy")
+
+	// Everyone in the project can read; outsiders cannot tell it exists
+	for _, user := range []uint{f.owner, f.editor, f.viewer} {
+		if _, err := f.svc.GetTask(user, task.ID); err != nil {
+			t.Errorf("user %d reading: %v", user, err)
+		}
+	}
+	if _, err := f.svc.GetTask(f.outsider, task.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("outsider reading: got %v, want ErrNotFound", err)
+	}
+	if _, err := f.svc.GetProject(f.outsider, f.project.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("outsider reading project: got %v, want ErrNotFound", err)
+	}
+
+	// Viewers cannot change tasks
+	task.Title = "Rewritten"
+	if err := f.svc.UpdateTask(f.viewer, task); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer updating: got %v, want ErrForbidden", err)
+	}
+	if err := f.svc.CreateTask(f.viewer, &models.Task{Title: "x", ProjectID: &f.project.ID}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer creating: got %v, want ErrForbidden", err)
+	}
+	if _, err := f.svc.AddComment(f.viewer, task.ID, nil, "hi"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer commenting: got %v, want ErrForbidden", err)
+	}
+	if err := f.svc.UpdateTask(f.editor, task); err != nil {
+		t.Errorf("editor updating: %v", err)
+	}
+
+	// Only owners manage the project and its members
+	if _, err := f.svc.AddMember(f.editor, f.project.ID, f.outsider, models.RoleViewer); !errors.Is(err, ErrForbidden) {
+		t.Errorf("editor adding member: got %v, want ErrForbidden", err)
+	}
+	if err := f.svc.DeleteProject(f.editor, f.project.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("editor deleting project: got %v, want ErrForbidden", err)
+	}
+	if _, err := f.svc.AddMember(f.owner, f.project.ID, f.outsider, "admin"); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("adding with unknown role: got %v, want ErrInvalidRole", err)
+	}
+	if _, err := f.svc.AddMember(f.owner, f.project.ID, f.editor, models.RoleViewer); !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("adding twice: got %v, want ErrDuplicate", err)
+	}
+
+	// Personal tasks stay private to their creator
+	personal := &models.Task{Title: "Dentist"}
+	if err := f.svc.CreateTask(f.viewer, personal); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.GetTask(f.owner, personal.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("reading someone's personal task: got %v, want ErrNotFound", err)
+	}
+	tasks, total, err := f.svc.ListTasks(f.viewer, repository.TaskFilter{})
+	if err != nil || total != 2 || len(tasks) != 2 {
+		t.Errorf("viewer lists %d of %d tasks (%v), want both", len(tasks), total, err)
+	}
+	if _, total, _ := f.svc.ListTasks(f.outsider, repository.TaskFilter{}); total != 0 {
+		t.Errorf("outsider lists %d tasks", total)
+	}
+}
+
+func TestMembers(t *testing.T) {
+	f := newFixture(t)
+	task := f.task(t, f.owner, "Ship it")
+
+	if _, err := f.svc.UpdateMember(f.owner, f.project.ID, f.owner, models.RoleEditor); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("demoting the last owner: got %v, want ErrLastOwner", err)
+	}
+	if err := f.svc.RemoveMember(f.owner, f.project.ID, f.owner); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("last owner leaving: got %v, want ErrLastOwner", err)
+	}
+
+	// Tasks can only be assigned to members
+	task.AssigneeID = &f.outsider
+	if err := f.svc.UpdateTask(f.owner, task); !errors.Is(err, ErrNotMember) {
+		t.Errorf("assigning an outsider: got %v, want ErrNotMember", err)
+	}
+	task.AssigneeID = &f.editor
+	if err := f.svc.UpdateTask(f.owner, task); err != nil {
+		t.Fatal(err)
+	}
+
+	// Members may leave, and their tasks are unassigned
+	if err := f.svc.RemoveMember(f.editor, f.project.ID, f.editor); err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.svc.GetTask(f.owner, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AssigneeID != nil {
+		t.Errorf("removed member is still assigned")
+	}
+	if err := f.svc.RemoveMember(f.viewer, f.project.ID, f.owner); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer removing owner: got %v, want ErrForbidden", err)
+	}
+
+	// With a second owner the first may step down
+	if _, err := f.svc.UpdateMember(f.owner, f.project.ID, f.viewer, models.RoleOwner); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.UpdateMember(f.owner, f.project.ID, f.owner, models.RoleEditor); err != nil {
+		t.Errorf("demoting one of two owners: %v", err)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	f := newFixture(t)
+	task := f.task(t, f.editor, "Fix login")
+
+	bug := models.Label{ProjectID: f.project.ID, Name: "bug", Color: "#ff0000"}
+	if err := f.svc.CreateLabel(f.editor, &bug); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.svc.CreateLabel(f.editor, &models.Label{ProjectID: f.project.ID, Name: "bug"}); !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("duplicate label: got %v, want ErrDuplicate", err)
+	}
+
+	other := models.Project{Name: "Other"}
+	if err := f.svc.CreateProject(f.outsider, &other); err != nil {
+		t.Fatal(err)
+	}
+	foreign := models.Label{ProjectID: other.ID, Name: "bug"}
+	if err := f.svc.CreateLabel(f.outsider, &foreign); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.SetTaskLabels(f.editor, task.ID, []uint{bug.ID, foreign.ID}); !errors.Is(err, ErrInvalidLabel) {
+		t.Errorf("label from another project: got %v, want ErrInvalidLabel", err)
+	}
+
+	labelled, err := f.svc.SetTaskLabels(f.editor, task.ID, []uint{bug.ID, bug.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labelled.Labels) != 1 || labelled.Labels[0].Name != "bug" {
+		t.Errorf("labels = %+v", labelled.Labels)
+	}
+	tasks, _, err := f.svc.ListTasks(f.viewer, repository.TaskFilter{LabelID: &bug.ID})
+	if err != nil || len(tasks) != 1 || len(tasks[0].Labels) != 1 {
+		t.Errorf("filtering by label: %+v, %v", tasks, err)
+	}
+
+	if err := f.svc.DeleteLabel(f.editor, f.project.ID, bug.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := f.svc.GetTask(f.editor, task.ID)
+	if len(got.Labels) != 0 {
+		t.Errorf("deleted label still on task: %+v", got.Labels)
+	}
+}
+
+func TestComments(t *testing.T) {
+	f := newFixture(t)
+	task := f.task(t, f.editor, "Review design")
+
+	root, err := f.svc.AddComment(f.editor, task.ID, nil, "@vic, @otto and @olive. please look (cc @nobody, mail@ed)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// otto is not in the project and nobody does not exist
+	var names []string
+	for _, u := range root.Mentions {
+		names = append(names, u.Username)
+	}
+	if fmt.Sprint(names) != "[olive vic]" && fmt.Sprint(names) != "[vic olive]" {
+		t.Errorf("mentions = %v, want olive and vic", names)
+	}
+
+	reply, err := f.svc.AddComment(f.owner, task.ID, &root.ID, "Done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Replying to a reply joins the thread
+	nested, err := f.svc.AddComment(f.editor, task.ID, &reply.ID, "Thanks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nested.ParentID == nil || *nested.ParentID != root.ID {
+		t.Errorf("reply to a reply has parent %v, want %d", nested.ParentID, root.ID)
+	}
+
+	other := f.task(t, f.editor, "Other")
+	if _, err := f.svc.AddComment(f.editor, other.ID, &root.ID, "wrong task"); !errors.Is(err, ErrInvalidParent) {
+		t.Errorf("parent on another task: got %v, want ErrInvalidParent", err)
+	}
+
+	threads, err := f.svc.ListComments(f.viewer, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(threads) != 1 || len(threads[0].Replies) != 2 || len(threads[0].Mentions) != 2 {
+		t.Errorf("threads = %+v", threads)
+	}
+	if _, err := f.svc.ListComments(f.outsider, task.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("outsider reading comments: got %v, want ErrNotFound", err)
+	}
+
+	if err := f.svc.DeleteTask(f.editor, task.ID); err != nil {
+		t.Fatalf("deleting a task with comments: %v", err)
+	}
+}
+
+func TestActivity(t *testing.T) {
+	f := newFixture(t)
+	task := f.task(t, f.editor, "Plan")
+	task.AssigneeID = &f.viewer
+	if err := f.svc.UpdateTask(f.editor, task); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.AddComment(f.owner, task.ID, nil, "ok"); err != nil {
+		t.Fatal(err)
+	}
+	// A failed change leaves no trace in the feed
+	if err := f.svc.UpdateTask(f.viewer, task); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("got %v, want ErrForbidden", err)
+	}
+	if err := f.svc.DeleteTask(f.editor, task.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	feed, err := f.svc.ListActivity(f.viewer, f.project.ID, 50, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for i := len(feed) - 1; i >= 0; i-- {
+		actions = append(actions, feed[i].Action)
+	}
+	want := []string{
+		models.ActivityProjectCreated, models.ActivityMemberAdded, models.ActivityMemberAdded,
+		models.ActivityTaskCreated, models.ActivityTaskAssigned, models.ActivityTaskUpdated,
+		models.ActivityCommentAdded, models.ActivityTaskDeleted,
+	}
+	if fmt.Sprint(actions) != fmt.Sprint(want) {
+		t.Errorf("feed = %v, want %v", actions, want)
+	}
+	if feed[0].TaskID == nil || *feed[0].TaskID != task.ID {
+		t.Errorf("deleted task's activity lost its task ID")
+	}
+	if _, err := f.svc.ListActivity(f.outsider, f.project.ID, 50, 0); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("outsider reading feed: got %v, want ErrNotFound", err)
+	}
+
+	if err := f.svc.DeleteProject(f.owner, f.project.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.repo.GetProjectByID(f.project.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("project survived deletion: %v", err)
+	}
+}
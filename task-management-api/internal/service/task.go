@@ -0,0 +1,506 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/rank"
+	"github.com/task-management-api/internal/recurrence"
+	"github.com/task-management-api/internal/repository"
+)
+
+// CreateTask creates a task for userID, in a project if task.ProjectID is
+// set; editors and owners may add tasks to a project. A subtask joins its
+// parent's project. The task goes to the bottom of its Kanban column.
+func (s *Service) CreateTask(userID uint, task *models.Task) error {
+	task.UserID = userID
+	return s.rankedTransaction(func(repo repository.Repository) error {
+		if task.ParentID != nil {
+			parent, err := loadTask(repo, userID, *task.ParentID, models.RoleEditor)
+			if isNotFound(err) || (err == nil && task.ProjectID != nil && !equalID(task.ProjectID, parent.ProjectID)) {
+				return ErrInvalidSubtask
+			}
+			if err != nil {
+				return err
+			}
+			task.ProjectID = parent.ProjectID
+		}
+		if err := authorizeTask(repo, userID, task, models.RoleEditor); err != nil {
+			return err
+		}
+		if err := checkAssignee(repo, task); err != nil {
+			return err
+		}
+		if err := prepareRecurrence(task); err != nil {
+			return err
+		}
+		if err := placeLast(repo, task); err != nil {
+			return err
+		}
+		if err := repo.CreateTask(task); err != nil {
+			return err
+		}
+		if task.ProjectID == nil {
+			return nil
+		}
+		return record(repo, userID, *task.ProjectID, &task.ID, models.ActivityTaskCreated, "created task %q", task.Title)
+	})
+}
+
+// GetTask returns a task userID can see, with its subtasks' progress
+func (s *Service) GetTask(userID, taskID uint) (*models.Task, error) {
+	task, err := loadTask(s.repo, userID, taskID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	if err := withProgress(s.repo, []*models.Task{task}); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// ListTasks returns a page of the tasks userID can see. Filtering by a
+// project userID is not in reports it as not found.
+func (s *Service) ListTasks(userID uint, filter repository.TaskFilter) ([]models.Task, int64, error) {
+	if filter.ProjectID != nil {
+		if _, err := authorize(s.repo, userID, *filter.ProjectID, models.RoleViewer); err != nil {
+			return nil, 0, err
+		}
+	}
+	filter.UserID = userID
+	tasks, total, err := s.repo.ListTasks(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	page := make([]*models.Task, len(tasks))
+	for i := range tasks {
+		page[i] = &tasks[i]
+	}
+	if err := withProgress(s.repo, page); err != nil {
+		return nil, 0, err
+	}
+	return tasks, total, nil
+}
+
+// UpdateTask saves changes to a task; editors and owners may change
+// project tasks. A task stays in the project it was created in, cannot
+// start while it is blocked, and goes to the bottom of its new column
+// when its status changes. Completing a recurring task creates its next
+// occurrence, and moving the due date reschedules its reminders.
+func (s *Service) UpdateTask(userID uint, task *models.Task) error {
+	return s.rankedTransaction(func(repo repository.Repository) error {
+		stored, err := loadTask(repo, userID, task.ID, models.RoleEditor)
+		if err != nil {
+			return err
+		}
+		task.UserID = stored.UserID
+		task.ProjectID = stored.ProjectID
+		task.CreatedAt = stored.CreatedAt
+		task.Rank = stored.Rank
+		task.NextOccurrenceID = stored.NextOccurrenceID
+		task.Labels, task.Reminders = stored.Labels, stored.Reminders
+		if err := checkAssignee(repo, task); err != nil {
+			return err
+		}
+		if err := prepareRecurrence(task); err != nil {
+			return err
+		}
+		if !equalID(stored.ParentID, task.ParentID) {
+			if err := checkParent(repo, userID, task); err != nil {
+				return err
+			}
+		}
+		if task.Status != stored.Status {
+			if err := checkUnblocked(repo, task.ID, task.Status); err != nil {
+				return err
+			}
+			if err := placeLast(repo, task); err != nil {
+				return err
+			}
+			if task.Status == models.StatusDone {
+				if err := s.recur(repo, userID, task); err != nil {
+					return err
+				}
+			}
+		}
+		if err := repo.UpdateTask(task); err != nil {
+			return err
+		}
+		if !equalTime(stored.DueDate, task.DueDate) {
+			if err := repo.RescheduleReminders(task.ID, task.DueDate); err != nil {
+				return err
+			}
+		}
+		if task.ProjectID == nil {
+			return nil
+		}
+		projectID := *task.ProjectID
+		if !equalID(stored.AssigneeID, task.AssigneeID) {
+			summary := "unassigned task %q"
+			args := []interface{}{task.Title}
+			if task.AssigneeID != nil {
+				summary = "assigned task %q to user %d"
+				args = append(args, *task.AssigneeID)
+			}
+			if err := record(repo, userID, projectID, &task.ID, models.ActivityTaskAssigned, summary, args...); err != nil {
+				return err
+			}
+		}
+		return record(repo, userID, projectID, &task.ID, models.ActivityTaskUpdated, "updated task %q", task.Title)
+	})
+}
+
+// MoveTask moves a task on the Kanban board: into the status column, or
+// its current one if status is empty, between afterID and beforeID. With
+// only one neighbour the task goes right next to it, and with neither at
+// the bottom. Only the moved task's row changes.
+func (s *Service) MoveTask(userID, taskID uint, status string, afterID, beforeID *uint) (*models.Task, error) {
+	var task *models.Task
+	err := s.rankedTransaction(func(repo repository.Repository) error {
+		var err error
+		if task, err = loadTask(repo, userID, taskID, models.RoleEditor); err != nil {
+			return err
+		}
+		if status == "" {
+			status = task.Status
+		}
+		if status != task.Status {
+			if err := checkUnblocked(repo, task.ID, status); err != nil {
+				return err
+			}
+		}
+		column := repository.Column{ProjectID: task.ProjectID, UserID: task.UserID, Status: status}
+
+		neighbour := func(id uint) (string, error) {
+			other, err := repo.GetTaskByID(id)
+			if isNotFound(err) {
+				return "", ErrInvalidPosition
+			}
+			if err != nil {
+				return "", err
+			}
+			if other.ID == task.ID || other.Status != status || !equalID(other.ProjectID, task.ProjectID) ||
+				(task.ProjectID == nil && other.UserID != task.UserID) {
+				return "", ErrInvalidPosition
+			}
+			return other.Rank, nil
+		}
+		var lo, hi string
+		switch {
+		case afterID != nil && beforeID != nil:
+			if lo, err = neighbour(*afterID); err != nil {
+				return err
+			}
+			hi, err = neighbour(*beforeID)
+		case afterID != nil:
+			if lo, err = neighbour(*afterID); err != nil {
+				return err
+			}
+			hi, err = repo.NextRank(column, lo, task.ID)
+		case beforeID != nil:
+			if hi, err = neighbour(*beforeID); err != nil {
+				return err
+			}
+			lo, err = repo.PrevRank(column, hi, task.ID)
+		default:
+			lo, err = repo.LastRank(column)
+			if lo == task.Rank && status == task.Status {
+				// Already at the bottom
+				return nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		key, err := rank.Between(lo, hi)
+		if errors.Is(err, rank.ErrInvalid) {
+			return ErrInvalidPosition
+		}
+		if err != nil {
+			return err
+		}
+		completed := status == models.StatusDone && task.Status != models.StatusDone
+		if err := repo.MoveTask(task.ID, status, key); err != nil {
+			return err
+		}
+		task.Status, task.Rank = status, key
+		if completed && task.Recurrence != "" {
+			if err := s.recur(repo, userID, task); err != nil {
+				return err
+			}
+			if err := repo.UpdateTask(task); err != nil {
+				return err
+			}
+		}
+		if task.ProjectID == nil {
+			return nil
+		}
+		return record(repo, userID, *task.ProjectID, &task.ID, models.ActivityTaskMoved, "moved task %q to %s", task.Title, status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// DeleteTask deletes a task and its subtasks; editors and owners may
+// delete project tasks
+func (s *Service) DeleteTask(userID, taskID uint) error {
+	return s.repo.Transaction(func(repo repository.Repository) error {
+		task, err := loadTask(repo, userID, taskID, models.RoleEditor)
+		if err != nil {
+			return err
+		}
+		if err := repo.DeleteTask(taskID); err != nil {
+			return err
+		}
+		if task.ProjectID == nil {
+			return nil
+		}
+		return record(repo, userID, *task.ProjectID, &task.ID, models.ActivityTaskDeleted, "deleted task %q", task.Title)
+	})
+}
+
+// SetTaskLabels replaces a project task's labels with labelIDs, which must
+// all be from its project
+func (s *Service) SetTaskLabels(userID, taskID uint, labelIDs []uint) (*models.Task, error) {
+	var task *models.Task
+	err := s.rankedTransaction(func(repo repository.Repository) error {
+		var err error
+		if task, err = loadTask(repo, userID, taskID, models.RoleEditor); err != nil {
+			return err
+		}
+		if task.ProjectID == nil && len(labelIDs) > 0 {
+			return ErrInvalidLabel
+		}
+		slices.Sort(labelIDs)
+		labelIDs = slices.Compact(labelIDs)
+		labels, err := repo.GetLabelsByIDs(labelIDs)
+		if err != nil {
+			return err
+		}
+		if len(labels) != len(labelIDs) {
+			return ErrInvalidLabel
+		}
+		names := make([]string, len(labels))
+		for i, label := range labels {
+			if label.ProjectID != *task.ProjectID {
+				return ErrInvalidLabel
+			}
+			names[i] = label.Name
+		}
+		if err := repo.SetTaskLabels(task, labels); err != nil {
+			return err
+		}
+		if task.ProjectID == nil {
+			return nil
+		}
+		return record(repo, userID, *task.ProjectID, &task.ID, models.ActivityTaskLabeled, "labelled task %q with %v", task.Title, names)
+	})
+	return task, err
+}
+
+// prepareRecurrence checks a task's recurrence rule and anchors it to the
+// due date, so a rule such as FREQ=MONTHLY keeps to the day of the month
+// it was set on
+func prepareRecurrence(task *models.Task) error {
+	if task.Recurrence == "" {
+		return nil
+	}
+	if task.DueDate == nil {
+		return ErrNoDueDate
+	}
+	rule, err := recurrence.Parse(task.Recurrence)
+	if err != nil {
+		return err
+	}
+	task.Recurrence = rule.Anchor(*task.DueDate).String()
+	return nil
+}
+
+// recur creates the next occurrence of a recurring task that has just been
+// completed, with the same labels and reminders. Occurrences missed while
+// the task was late are skipped, so the new task is never overdue.
+func (s *Service) recur(repo repository.Repository, userID uint, task *models.Task) error {
+	if task.Recurrence == "" || task.DueDate == nil || task.NextOccurrenceID != nil {
+		return nil
+	}
+	rule, err := recurrence.Parse(task.Recurrence)
+	if err != nil {
+		return err
+	}
+	due, now := *task.DueDate, s.now()
+	for {
+		next, ok := rule.Next(due)
+		if !ok {
+			return nil
+		}
+		due = next
+		if due.After(now) {
+			break
+		}
+	}
+
+	next := &models.Task{
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      models.StatusTodo,
+		Priority:    task.Priority,
+		DueDate:     &due,
+		UserID:      task.UserID,
+		ProjectID:   task.ProjectID,
+		AssigneeID:  task.AssigneeID,
+		ParentID:    task.ParentID,
+		Recurrence:  task.Recurrence,
+	}
+	if err := placeLast(repo, next); err != nil {
+		return err
+	}
+	if err := repo.CreateTask(next); err != nil {
+		return err
+	}
+	if len(task.Labels) > 0 {
+		if err := repo.SetTaskLabels(next, task.Labels); err != nil {
+			return err
+		}
+	}
+	if len(task.Reminders) > 0 {
+		before := make([]int, len(task.Reminders))
+		for i, reminder := range task.Reminders {
+			before[i] = reminder.BeforeMinutes
+		}
+		if err := repo.SetReminders(next.ID, schedule(next.DueDate, before)); err != nil {
+			return err
+		}
+	}
+	task.NextOccurrenceID = &next.ID
+
+	if task.ProjectID == nil {
+		return nil
+	}
+	return record(repo, userID, *task.ProjectID, &next.ID, models.ActivityTaskRecurred, "task %q recurs on %s", task.Title, due.Format(time.DateOnly))
+}
+
+// checkParent ensures a subtask's new parent is in the same project and is
+// not the subtask itself or one of its subtasks
+func checkParent(repo repository.Repository, userID uint, task *models.Task) error {
+	if task.ParentID == nil {
+		return nil
+	}
+	parent, err := loadTask(repo, userID, *task.ParentID, models.RoleEditor)
+	if isNotFound(err) {
+		return ErrInvalidSubtask
+	}
+	if err != nil {
+		return err
+	}
+	if parent.ID == task.ID || !equalID(parent.ProjectID, task.ProjectID) {
+		return ErrInvalidSubtask
+	}
+	cycle, err := repo.HasAncestor(parent.ID, task.ID)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return ErrInvalidSubtask
+	}
+	return nil
+}
+
+// checkUnblocked ensures a task whose blockers are not all done stays to
+// do
+func checkUnblocked(repo repository.Repository, taskID uint, status string) error {
+	if status == models.StatusTodo {
+		return nil
+	}
+	open, err := repo.CountOpenBlockers(taskID)
+	if err != nil {
+		return err
+	}
+	if open > 0 {
+		return ErrBlocked
+	}
+	return nil
+}
+
+// rankAttempts is how many times a change that ranks a task is tried
+const rankAttempts = 3
+
+// rankedTransaction runs fn, a change that ranks a task, in a transaction.
+// Concurrent changes to the same column can pick the same rank; the
+// column's unique index lets only one of them commit, and the others are
+// run again against the ranks it left.
+func (s *Service) rankedTransaction(fn func(repo repository.Repository) error) error {
+	var err error
+	for i := 0; i < rankAttempts; i++ {
+		err = s.repo.Transaction(fn)
+		if !errors.Is(err, repository.ErrDuplicate) {
+			return err
+		}
+	}
+	return err
+}
+
+// placeLast ranks a task at the bottom of its column
+func placeLast(repo repository.Repository, task *models.Task) error {
+	last, err := repo.LastRank(repository.Column{ProjectID: task.ProjectID, UserID: task.UserID, Status: task.Status})
+	if err != nil {
+		return err
+	}
+	task.Rank, err = rank.Between(last, "")
+	return err
+}
+
+// withProgress fills in the progress of tasks with subtasks
+func withProgress(repo repository.Repository, tasks []*models.Task) error {
+	ids := make([]uint, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	progress, err := repo.Progress(ids)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if p, ok := progress[task.ID]; ok {
+			task.Progress = &p
+		}
+	}
+	return nil
+}
+
+// checkAssignee ensures a task's assignee can see it: a member of its
+// project, or for a personal task its creator
+func checkAssignee(repo repository.Repository, task *models.Task) error {
+	if task.AssigneeID == nil {
+		return nil
+	}
+	if task.ProjectID == nil {
+		if *task.AssigneeID != task.UserID {
+			return ErrNotMember
+		}
+		return nil
+	}
+	_, err := repo.GetMember(*task.ProjectID, *task.AssigneeID)
+	if isNotFound(err) {
+		return ErrNotMember
+	}
+	return err
+}
+
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func equalID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
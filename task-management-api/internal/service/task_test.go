@@ -0,0 +1,330 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/task-management-api/internal/models"
+	"github.com/task-management-api/internal/repository"
+)
+
+func (f *fixture) subtask(t *testing.T, parent *models.Task, title string) *models.Task {
+	t.Helper()
+	task := &models.Task{Title: title, Status: models.StatusTodo, Priority: models.PriorityMedium, ParentID: &parent.ID}
+	if err := f.svc.CreateTask(f.editor, task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func (f *fixture) setStatus(t *testing.T, task *models.Task, status string) {
+	t.Helper()
+	task.Status = status
+	if err := f.svc.UpdateTask(f.editor, task); err != nil {
+		t.Fatalf("setting %q to %s: %v", task.Title, status, err)
+	}
+}
+
+func TestSubtasks(t *testing.T) {
+	f := newFixture(t)
+	epic := f.task(t, f.editor, "Epic")
+	story := f.subtask(t, epic, "Story")
+	a := f.subtask(t, story, "a")
+	f.subtask(t, story, "b")
+	c := f.subtask(t, epic, "c")
+
+	if story.ProjectID == nil || *story.ProjectID != f.project.ID {
+		t.Errorf("subtask did not join its parent's project")
+	}
+
+	f.setStatus(t, a, models.StatusDone)
+	f.setStatus(t, c, models.StatusDone)
+	got, err := f.svc.GetTask(f.viewer, epic.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The story's two leaves and c count; the story itself does not
+	if got.Progress == nil || *got.Progress != (models.Progress{Done: 2, Total: 3, Percent: 66}) {
+		t.Errorf("epic progress = %+v", got.Progress)
+	}
+	tasks, _, err := f.svc.ListTasks(f.viewer, repository.TaskFilter{ParentID: &story.ID})
+	if err != nil || len(tasks) != 2 {
+		t.Fatalf("listing story's subtasks: %d, %v", len(tasks), err)
+	}
+	if tasks[0].Progress != nil {
+		t.Errorf("leaf has progress %+v", tasks[0].Progress)
+	}
+
+	// A task cannot sit under itself or its own subtasks
+	epic.ParentID = &a.ID
+	if err := f.svc.UpdateTask(f.editor, epic); !errors.Is(err, ErrInvalidSubtask) {
+		t.Errorf("parenting under a descendant: got %v, want ErrInvalidSubtask", err)
+	}
+	epic.ParentID = &epic.ID
+	if err := f.svc.UpdateTask(f.editor, epic); !errors.Is(err, ErrInvalidSubtask) {
+		t.Errorf("parenting under itself: got %v, want ErrInvalidSubtask", err)
+	}
+	epic.ParentID = nil
+
+	// Subtasks stay in their parent's project
+	personal := &models.Task{Title: "Mine", Status: models.StatusTodo}
+	if err := f.svc.CreateTask(f.editor, personal); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.svc.CreateTask(f.editor, &models.Task{Title: "x", Status: models.StatusTodo, ParentID: &personal.ID, ProjectID: &f.project.ID}); !errors.Is(err, ErrInvalidSubtask) {
+		t.Errorf("subtask in another project: got %v, want ErrInvalidSubtask", err)
+	}
+	if err := f.svc.CreateTask(f.outsider, &models.Task{Title: "x", Status: models.StatusTodo, ParentID: &epic.ID}); !errors.Is(err, ErrInvalidSubtask) {
+		t.Errorf("outsider adding a subtask: got %v, want ErrInvalidSubtask", err)
+	}
+
+	// Deleting a task takes its subtasks with it
+	if err := f.svc.DeleteTask(f.editor, epic.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.GetTask(f.editor, a.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("subtask outlived its parent: %v", err)
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	f := newFixture(t)
+	design := f.task(t, f.editor, "Design")
+	build := f.task(t, f.editor, "Build")
+	ship := f.task(t, f.editor, "Ship")
+	f.task(t, f.editor, "Docs")
+
+	if _, err := f.svc.AddDependency(f.editor, build.ID, design.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.AddDependency(f.editor, ship.ID, build.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.AddDependency(f.editor, ship.ID, build.ID); !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("adding twice: got %v, want ErrDuplicate", err)
+	}
+	for _, pair := range [][2]*models.Task{{design, ship}, {design, build}, {design, design}} {
+		if _, err := f.svc.AddDependency(f.editor, pair[0].ID, pair[1].ID); !errors.Is(err, ErrDependencyCycle) {
+			t.Errorf("%s waiting on %s: got %v, want ErrDependencyCycle", pair[0].Title, pair[1].Title, err)
+		}
+	}
+	if _, err := f.svc.AddDependency(f.viewer, design.ID, build.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer adding dependency: got %v, want ErrForbidden", err)
+	}
+	personal := &models.Task{Title: "Mine", Status: models.StatusTodo}
+	if err := f.svc.CreateTask(f.editor, personal); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.AddDependency(f.editor, ship.ID, personal.ID); !errors.Is(err, ErrInvalidDependency) {
+		t.Errorf("waiting on a personal task: got %v, want ErrInvalidDependency", err)
+	}
+
+	ready := func() string {
+		t.Helper()
+		tasks, _, err := f.svc.ListTasks(f.viewer, repository.TaskFilter{ProjectID: &f.project.ID, Ready: true, Sort: []string{"title"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, task := range tasks {
+			names = append(names, task.Title)
+		}
+		return fmt.Sprint(names)
+	}
+	if got := ready(); got != "[Design Docs]" {
+		t.Errorf("ready = %s, want [Design Docs]", got)
+	}
+
+	// Blocked tasks cannot start
+	build.Status = models.StatusInProgress
+	if err := f.svc.UpdateTask(f.editor, build); !errors.Is(err, ErrBlocked) {
+		t.Errorf("starting a blocked task: got %v, want ErrBlocked", err)
+	}
+	if _, err := f.svc.MoveTask(f.editor, build.ID, models.StatusInProgress, nil, nil); !errors.Is(err, ErrBlocked) {
+		t.Errorf("moving a blocked task: got %v, want ErrBlocked", err)
+	}
+	f.setStatus(t, design, models.StatusDone)
+	if got := ready(); got != "[Build Docs]" {
+		t.Errorf("ready = %s, want [Build Docs]", got)
+	}
+	f.setStatus(t, build, models.StatusInProgress)
+
+	blockedBy, blocking, err := f.svc.Dependencies(f.viewer, build.ID)
+	if err != nil || len(blockedBy) != 1 || blockedBy[0].ID != design.ID || len(blocking) != 1 || blocking[0].ID != ship.ID {
+		t.Errorf("dependencies of build: %v, %v, %v", blockedBy, blocking, err)
+	}
+
+	if err := f.svc.RemoveDependency(f.editor, ship.ID, build.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := ready(); got != "[Docs Ship]" {
+		t.Errorf("ready = %s, want [Docs Ship]", got)
+	}
+	if err := f.svc.RemoveDependency(f.editor, ship.ID, build.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("removing twice: got %v, want ErrNotFound", err)
+	}
+
+	// Deleting a task drops its dependencies
+	if err := f.svc.DeleteTask(f.editor, design.ID); err != nil {
+		t.Fatal(err)
+	}
+	if blockedBy, _, _ := f.svc.Dependencies(f.viewer, build.ID); len(blockedBy) != 0 {
+		t.Errorf("deleted blocker still listed: %v", blockedBy)
+	}
+}
+
+func TestKanban(t *testing.T) {
+	f := newFixture(t)
+	a := f.task(t, f.editor, "a")
+	b := f.task(t, f.editor, "b")
+	c := f.task(t, f.editor, "c")
+
+	column := func(status string) string {
+		t.Helper()
+		tasks, _, err := f.svc.ListTasks(f.viewer, repository.TaskFilter{ProjectID: &f.project.ID, Statuses: []string{status}, Sort: []string{"rank"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, task := range tasks {
+			names = append(names, task.Title)
+		}
+		return fmt.Sprint(names)
+	}
+	if got := column(models.StatusTodo); got != "[a b c]" {
+		t.Fatalf("new tasks = %s, want [a b c]", got)
+	}
+
+	move := func(task *models.Task, status string, after, before *models.Task) error {
+		var afterID, beforeID *uint
+		if after != nil {
+			afterID = &after.ID
+		}
+		if before != nil {
+			beforeID = &before.ID
+		}
+		moved, err := f.svc.MoveTask(f.editor, task.ID, status, afterID, beforeID)
+		if err == nil {
+			*task = *moved
+		}
+		return err
+	}
+
+	// Moving changes only the moved task's rank
+	bRank := b.Rank
+	if err := move(c, "", nil, b); err != nil {
+		t.Fatal(err)
+	}
+	if got := column(models.StatusTodo); got != "[a c b]" {
+		t.Errorf("after moving c before b: %s", got)
+	}
+	stored, _ := f.svc.GetTask(f.editor, b.ID)
+	if stored.Rank != bRank {
+		t.Errorf("b's rank changed from %q to %q", bRank, stored.Rank)
+	}
+
+	if err := move(a, "", b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := column(models.StatusTodo); got != "[c b a]" {
+		t.Errorf("after moving a after b: %s", got)
+	}
+	if err := move(b, "", c, a); err != nil {
+		t.Fatal(err)
+	}
+	if got := column(models.StatusTodo); got != "[c b a]" {
+		t.Errorf("after moving b between c and a: %s", got)
+	}
+
+	// Between columns
+	if err := move(b, models.StatusInProgress, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := move(a, models.StatusInProgress, nil, b); err != nil {
+		t.Fatal(err)
+	}
+	if got := column(models.StatusInProgress); got != "[a b]" {
+		t.Errorf("in progress = %s, want [a b]", got)
+	}
+	if got := column(models.StatusTodo); got != "[c]" {
+		t.Errorf("todo = %s, want [c]", got)
+	}
+
+	// Neighbours must be in the target column, and in order
+	if err := move(c, "", b, nil); !errors.Is(err, ErrInvalidPosition) {
+		t.Errorf("neighbour in another column: got %v, want ErrInvalidPosition", err)
+	}
+	if err := move(c, models.StatusInProgress, b, a); !errors.Is(err, ErrInvalidPosition) {
+		t.Errorf("neighbours out of order: got %v, want ErrInvalidPosition", err)
+	}
+	if err := move(c, "", nil, nil); err != nil {
+		t.Errorf("moving the only card to the bottom: %v", err)
+	}
+	if _, err := f.svc.MoveTask(f.viewer, c.ID, "", nil, nil); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer moving: got %v, want ErrForbidden", err)
+	}
+
+	// Changing status by update sends the task to the bottom of its new
+	// column
+	f.setStatus(t, c, models.StatusInProgress)
+	if got := column(models.StatusInProgress); got != "[a b c]" {
+		t.Errorf("in progress = %s, want [a b c]", got)
+	}
+}
+
+// staleRanks answers the first few LastRank calls with an empty column, as
+// if another change had ranked a task there since they were read
+type staleRanks struct {
+	repository.Repository
+	stale *int
+}
+
+func (r staleRanks) Transaction(fn func(repo repository.Repository) error) error {
+	return r.Repository.Transaction(func(repo repository.Repository) error {
+		return fn(staleRanks{repo, r.stale})
+	})
+}
+
+func (r staleRanks) LastRank(column repository.Column) (string, error) {
+	if *r.stale > 0 {
+		*r.stale--
+		return "", nil
+	}
+	return r.Repository.LastRank(column)
+}
+
+func TestRankConflictsAreRetried(t *testing.T) {
+	f := newFixture(t)
+	a := f.task(t, f.editor, "a")
+	stale := 0
+	f.svc.repo = staleRanks{f.repo, &stale}
+
+	// The stale rank is a's, which the column's unique index turns away
+	stale = 1
+	b := f.task(t, f.editor, "b")
+	if b.Rank <= a.Rank {
+		t.Errorf("b ranked %q, not after a's %q", b.Rank, a.Rank)
+	}
+	stale = 1
+	moved, err := f.svc.MoveTask(f.editor, b.ID, "", nil, nil)
+	if err != nil {
+		t.Fatalf("moving b to the bottom again: %v", err)
+	}
+	if moved.Rank != b.Rank {
+		t.Errorf("b moved from %q to %q", b.Rank, moved.Rank)
+	}
+
+	// Personal columns are unique too
+	personal := func() (*models.Task, error) {
+		task := &models.Task{Title: "Dentist", Status: models.StatusTodo, Priority: models.PriorityLow}
+		return task, f.svc.CreateTask(f.editor, task)
+	}
+	if _, err := personal(); err != nil {
+		t.Fatal(err)
+	}
+	stale = rankAttempts
+	if _, err := personal(); !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("conflicting every time: got %v, want ErrDuplicate", err)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// heartbeat is how often an idle stream gets a comment line, so proxies
+// don't close it
+const heartbeat = 25 * time.Second
+
+var errStaleVersion = errors.New("todo was changed by someone else")
+
+// changeFeed fans changes out to each user's open streams. Writes go
+// through commit, which saves them and their Changes in one transaction
+// and only then tells subscribers, so anything a stream has been sent is
+// also in the database for a reconnecting client to catch up from.
+type changeFeed struct {
+	// writeMu serialises writes, so changes commit in Seq order and a
+	// client resuming after Seq N can't miss a smaller one committed late
+	writeMu sync.Mutex
+
+	mu   sync.Mutex
+	subs map[uint]map[chan Change]struct{}
+}
+
+var feed = newChangeFeed()
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{subs: make(map[uint]map[chan Change]struct{})}
+}
+
+// subscribe returns a channel of the user's changes. A subscriber that
+// falls too far behind has its channel closed and is expected to
+// reconnect and catch up from the database.
+func (f *changeFeed) subscribe(userID uint) chan Change {
+	ch := make(chan Change, 64)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.subs[userID] == nil {
+		f.subs[userID] = make(map[chan Change]struct{})
+	}
+	f.subs[userID][ch] = struct{}{}
+	return ch
+}
+
+func (f *changeFeed) unsubscribe(userID uint, ch chan Change) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.subs[userID][ch]; !ok {
+		return
+	}
+	delete(f.subs[userID], ch)
+	if len(f.subs[userID]) == 0 {
+		delete(f.subs, userID)
+	}
+	close(ch)
+}
+
+func (f *changeFeed) publish(change Change) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subs[change.UserID] {
+		select {
+		case ch <- change:
+		default:
+			delete(f.subs[change.UserID], ch)
+			close(ch)
+		}
+	}
+}
+
+// commit runs write in a transaction and saves the changes it returns,
+// then tells subscribers
+func (f *changeFeed) commit(db *gorm.DB, write func(tx *gorm.DB) ([]Change, error)) error {
+	f.writeMu.Lock()
+	defer f.writeMu.Unlock()
+
+	var changes []Change
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if changes, err = write(tx); err != nil || len(changes) == 0 {
+			return err
+		}
+		return tx.Create(&changes).Error
+	})
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		f.publish(change)
+	}
+	return nil
+}
+
+// record runs write in a transaction and adds a change of the kind it
+// returns for everyone who can see the todo: its owner, or the members
+// of its list. Members of a list the todo was moved off get a delete.
+// Deletes carry no snapshot.
+func (f *changeFeed) record(db *gorm.DB, write func(tx *gorm.DB) (Todo, string, error)) (Todo, error) {
+	var todo Todo
+	err := f.commit(db, func(tx *gorm.DB) ([]Change, error) {
+		var kind string
+		var err error
+		if todo, kind, err = write(tx); err != nil {
+			return nil, err
+		}
+		users, err := audience(tx, todo.UserID, todo.ListID)
+		if err != nil {
+			return nil, err
+		}
+		change, err := todoChange(todo, kind)
+		if err != nil {
+			return nil, err
+		}
+		var changes []Change
+		sees := make(map[uint]bool, len(users))
+		for _, userID := range users {
+			sees[userID] = true
+			change.UserID = userID
+			changes = append(changes, change)
+		}
+		if todo.movedFrom != nil {
+			left, err := audience(tx, todo.UserID, todo.movedFrom)
+			if err != nil {
+				return nil, err
+			}
+			for _, userID := range left {
+				if !sees[userID] {
+					changes = append(changes, Change{UserID: userID, Type: ChangeDeleted, TodoID: todo.ID})
+				}
+			}
+		}
+		return changes, nil
+	})
+	return todo, err
+}
+
+// listChanges is a change of kind to every todo on listID, for userID
+// alone: creates when the list is shared with them, deletes when they
+// leave it
+func listChanges(tx *gorm.DB, listID, userID uint, kind string) ([]Change, error) {
+	var todos []Todo
+	if err := tx.Preload("Tags").Where("list_id = ?", listID).Order("id").Find(&todos).Error; err != nil {
+		return nil, err
+	}
+	changes := make([]Change, len(todos))
+	for i, todo := range todos {
+		change, err := todoChange(todo, kind)
+		if err != nil {
+			return nil, err
+		}
+		change.UserID = userID
+		changes[i] = change
+	}
+	return changes, nil
+}
+
+// todoChange is a change of kind to todo, with a snapshot of it unless it
+// was deleted
+func todoChange(todo Todo, kind string) (Change, error) {
+	change := Change{Type: kind, TodoID: todo.ID}
+	if kind == ChangeDeleted {
+		return change, nil
+	}
+	b, err := json.Marshal(todo)
+	if err != nil {
+		return change, err
+	}
+	change.Data, change.Todo = string(b), &todo
+	return change, nil
+}
+
+// audience is who sees a todo owned by userID on listID
+func audience(tx *gorm.DB, userID uint, listID *uint) ([]uint, error) {
+	if listID == nil {
+		return []uint{userID}, nil
+	}
+	var users []uint
+	err := tx.Model(&ListMember{}).Where("list_id = ?", *listID).Order("user_id").Pluck("user_id", &users).Error
+	return users, err
+}
+
+// latestSeq is the newest change the user has, or 0
+func latestSeq(db *gorm.DB, userID uint) (uint, error) {
+	var seq uint
+	err := db.Model(&Change{}).Where("user_id = ?", userID).Select("COALESCE(MAX(seq), 0)").Scan(&seq).Error
+	return seq, err
+}
+
+// streamChanges serves the user's change feed as Server-Sent Events. Each
+// event's id is its Seq, so a browser EventSource resumes where it left
+// off by itself; other clients pass ?since=<seq>, which GET /todos
+// returns in X-Change-Seq.
+func streamChanges(w http.ResponseWriter, r *http.Request) {
+	userID := currentUser(r)
+	since := r.URL.Query().Get("since")
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		since = id
+	}
+	var last uint64
+	if since != "" {
+		var err error
+		if last, err = strconv.ParseUint(since, 10, 64); err != nil {
+			http.Error(w, "Invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before reading the backlog so nothing committed in
+	// between is lost; anything seen twice is skipped by Seq
+	ch := feed.subscribe(userID)
+	defer feed.unsubscribe(userID, ch)
+
+	var missed []Change
+	if since != "" {
+		if err := db.Where("user_id = ? AND seq > ?", userID, last).Order("seq").Find(&missed).Error; err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(change Change) error {
+		if uint64(change.Seq) <= last {
+			return nil
+		}
+		data, err := json.Marshal(change)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", change.Seq, data); err != nil {
+			return err
+		}
+		last = uint64(change.Seq)
+		return nil
+	}
+	for _, change := range missed {
+		if err := send(change); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case change, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := send(change); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errInvalidTimestamp = errors.New("invalid timestamp")
+
+// Timestamp is a hybrid logical clock reading: wall-clock milliseconds, a
+// counter for events within the same millisecond, and the node that made
+// it to break ties. Timestamps from different devices can be compared
+// even when their clocks disagree a little.
+type Timestamp struct {
+	Wall    int64
+	Logical uint32
+	Node    string
+}
+
+// String formats t as "<wall>-<logical>-<node>", e.g.
+// "1718000000000-00000000-phone". The zero Timestamp formats as "".
+func (t Timestamp) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%013d-%08x-%s", t.Wall, t.Logical, t.Node)
+}
+
+func (t Timestamp) IsZero() bool {
+	return t == Timestamp{}
+}
+
+// After reports whether t is later than u
+func (t Timestamp) After(u Timestamp) bool {
+	if t.Wall != u.Wall {
+		return t.Wall > u.Wall
+	}
+	if t.Logical != u.Logical {
+		return t.Logical > u.Logical
+	}
+	return t.Node > u.Node
+}
+
+// ParseTimestamp reads a Timestamp written by String. "" is the zero
+// Timestamp, which every other one is after.
+func ParseTimestamp(s string) (Timestamp, error) {
+	if s == "" {
+		return Timestamp{}, nil
+	}
+	parts := strings.SplitN(s, "-", 3)
+	if len(parts) != 3 || parts[2] == "" || len(parts[2]) > 64 {
+		return Timestamp{}, errInvalidTimestamp
+	}
+	wall, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || wall < 0 {
+		return Timestamp{}, errInvalidTimestamp
+	}
+	logical, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return Timestamp{}, errInvalidTimestamp
+	}
+	return Timestamp{Wall: wall, Logical: uint32(logical), Node: parts[2]}, nil
+}
+
+// hybridClock hands out Timestamps that always go forward, even if the
+// wall clock steps back, and that come after any Timestamp it has been
+// shown with Observe
+type hybridClock struct {
+	mu   sync.Mutex
+	last Timestamp
+	node string
+	now  func() time.Time
+}
+
+var clock = newHybridClock("server")
+
+func newHybridClock(node string) *hybridClock {
+	return &hybridClock{node: node, now: time.Now}
+}
+
+func (c *hybridClock) Now() Timestamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance(c.now().UnixMilli(), 0)
+	return c.last
+}
+
+// Observe moves the clock past remote, so the next Now is after it
+func (c *hybridClock) Observe(remote Timestamp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance(c.now().UnixMilli(), 0)
+	if remote.Wall > c.last.Wall || remote.Wall == c.last.Wall && remote.Logical >= c.last.Logical {
+		c.advance(remote.Wall, remote.Logical+1)
+	}
+}
+
+func (c *hybridClock) advance(wall int64, logical uint32) {
+	switch {
+	case wall > c.last.Wall:
+		c.last = Timestamp{Wall: wall, Logical: logical, Node: c.node}
+	case logical > c.last.Logical:
+		c.last.Logical = logical
+	default:
+		c.last.Logical++
+	}
+}
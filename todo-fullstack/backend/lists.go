@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+var permissionRank = map[string]int{
+	PermissionView:  1,
+	PermissionEdit:  2,
+	PermissionOwner: 3,
+}
+
+// can reports whether having permission allows what need does
+func can(permission, need string) bool {
+	return permission != "" && permissionRank[permission] >= permissionRank[need]
+}
+
+// visibleTo limits a todos query to the user's own personal todos and the
+// todos on lists shared with them
+func visibleTo(userID uint) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("((todos.list_id IS NULL AND todos.user_id = ?) OR todos.list_id IN (SELECT list_id FROM list_members WHERE user_id = ?))", userID, userID)
+	}
+}
+
+// listPermission is the user's permission on the list, or "" if it isn't
+// shared with them
+func listPermission(tx *gorm.DB, userID, listID uint) (string, error) {
+	var member ListMember
+	err := tx.Where("list_id = ? AND user_id = ?", listID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	return member.Permission, err
+}
+
+// todoPermission is the user's permission on the todo: owner of their
+// personal todos, and whatever they have on the list otherwise
+func todoPermission(tx *gorm.DB, userID uint, todo Todo) (string, error) {
+	if todo.ListID == nil {
+		if todo.UserID == userID {
+			return PermissionOwner, nil
+		}
+		return "", nil
+	}
+	return listPermission(tx, userID, *todo.ListID)
+}
+
+// checkListEdit writes an error and returns false unless the user may add
+// todos to the list
+func checkListEdit(w http.ResponseWriter, userID, listID uint) bool {
+	permission, err := listPermission(db, userID, listID)
+	switch {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	case permission == "":
+		http.Error(w, "List not found", http.StatusNotFound)
+		return false
+	case !can(permission, PermissionEdit):
+		http.Error(w, "You can only view this list", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// loadList reads the list in the URL for the current user, writing an
+// error and returning false unless they have at least need on it
+func loadList(w http.ResponseWriter, r *http.Request, need string) (List, bool) {
+	var list List
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	err := db.Model(&List{}).Select("lists.*, list_members.permission").
+		Joins("JOIN list_members ON list_members.list_id = lists.id AND list_members.user_id = ?", currentUser(r)).
+		Where("lists.id = ?", id).First(&list).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.NotFound(w, r)
+		return list, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return list, false
+	}
+	if !can(list.Permission, need) {
+		http.Error(w, "You need "+need+" permission on this list", http.StatusForbidden)
+		return list, false
+	}
+	return list, true
+}
+
+type ListRequest struct {
+	Name string `json:"name"`
+}
+
+func (req *ListRequest) validate() string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		return "Name is required and at most 100 characters"
+	}
+	return ""
+}
+
+func getLists(w http.ResponseWriter, r *http.Request) {
+	lists := []List{}
+	err := db.Model(&List{}).Select("lists.*, list_members.permission").
+		Joins("JOIN list_members ON list_members.list_id = lists.id AND list_members.user_id = ?", currentUser(r)).
+		Order("lists.name").Find(&lists).Error
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(lists)
+}
+
+func createList(w http.ResponseWriter, r *http.Request) {
+	var req ListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	list := List{Name: req.Name, OwnerID: currentUser(r), Permission: PermissionOwner}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&list).Error; err != nil {
+			return err
+		}
+		return tx.Create(&ListMember{ListID: list.ID, UserID: list.OwnerID, Permission: PermissionOwner}).Error
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(list)
+}
+
+func updateList(w http.ResponseWriter, r *http.Request) {
+	list, ok := loadList(w, r, PermissionOwner)
+	if !ok {
+		return
+	}
+	var req ListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	list.Name = req.Name
+	if err := db.Model(&List{ID: list.ID}).Update("name", list.Name).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// deleteList deletes the list and its todos. The todos go through the
+// change feed one by one so everyone's devices hear about them.
+func deleteList(w http.ResponseWriter, r *http.Request) {
+	list, ok := loadList(w, r, PermissionOwner)
+	if !ok {
+		return
+	}
+	var ids []uint
+	if err := db.Model(&Todo{}).Where("list_id = ?", list.ID).Pluck("id", &ids).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, id := range ids {
+		_, err := feed.record(db, func(tx *gorm.DB) (Todo, string, error) {
+			var todo Todo
+			if err := tx.First(&todo, id).Error; err != nil {
+				return todo, "", err
+			}
+			return todo, ChangeDeleted, softDelete(tx, &todo)
+		})
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("list_id = ?", list.ID).Delete(&ListMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&List{}, list.ID).Error
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getMembers(w http.ResponseWriter, r *http.Request) {
+	list, ok := loadList(w, r, PermissionView)
+	if !ok {
+		return
+	}
+	members := []ListMember{}
+	if err := db.Preload("User").Where("list_id = ?", list.ID).Order("user_id").Find(&members).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(members)
+}
+
+type MemberRequest struct {
+	Username   string `json:"username"`
+	Permission string `json:"permission"`
+}
+
+// shareList invites a user to the list by username, or changes the
+// permission of someone already on it
+func shareList(w http.ResponseWriter, r *http.Request) {
+	list, ok := loadList(w, r, PermissionOwner)
+	if !ok {
+		return
+	}
+	var req MemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Permission != PermissionView && req.Permission != PermissionEdit {
+		http.Error(w, "Permission must be view or edit", http.StatusBadRequest)
+		return
+	}
+	var user User
+	if err := db.Where("username = ?", req.Username).First(&user).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if user.ID == list.OwnerID {
+		http.Error(w, "The owner's permission can't be changed", http.StatusConflict)
+		return
+	}
+	// Someone new to the list gets its todos on their change feed; a
+	// permission change alone shows them nothing new
+	member := ListMember{ListID: list.ID, UserID: user.ID, Permission: req.Permission}
+	err := feed.commit(db, func(tx *gorm.DB) ([]Change, error) {
+		permission, err := listPermission(tx, user.ID, list.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Save(&member).Error; err != nil {
+			return nil, err
+		}
+		if permission != "" {
+			return nil, nil
+		}
+		return listChanges(tx, list.ID, user.ID, ChangeCreated)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	member.User = user
+	json.NewEncoder(w).Encode(member)
+}
+
+// unshareList takes a user off the list. Owners can remove anyone but
+// themselves; everyone else can only leave.
+func unshareList(w http.ResponseWriter, r *http.Request) {
+	list, ok := loadList(w, r, PermissionView)
+	if !ok {
+		return
+	}
+	userID, _ := strconv.Atoi(mux.Vars(r)["userID"])
+	switch {
+	case uint(userID) == list.OwnerID:
+		http.Error(w, "The owner can't leave their list", http.StatusConflict)
+		return
+	case uint(userID) != currentUser(r) && list.Permission != PermissionOwner:
+		http.Error(w, "Only the owner can remove other people", http.StatusForbidden)
+		return
+	}
+	// The todos on the list go from their change feed as they leave
+	err := feed.commit(db, func(tx *gorm.DB) ([]Change, error) {
+		result := tx.Where("list_id = ? AND user_id = ?", list.ID, userID).Delete(&ListMember{})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return listChanges(tx, list.ID, uint(userID), ChangeDeleted)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,13 +2,16 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
+	"gorm.io/gorm"
 )
 
 // Set DATABASE_URL env var to use Postgres, e.g.
@@ -16,74 +19,315 @@ import (
 
 var db *gorm.DB
 
+// getTodos lists the user's todos and those on lists shared with them.
+// Filters: list_id (0 for todos on no list), tag (repeat to require
+// several), done, priority and due_before; sort=due or sort=priority.
 func getTodos(w http.ResponseWriter, r *http.Request) {
-	userID, _ := strconv.Atoi(r.Header.Get("X-User-ID"))
+	userID := currentUser(r)
+	query := r.URL.Query()
+	q := db.Scopes(visibleTo(userID)).Preload("Tags")
+	if listID := query.Get("list_id"); listID == "0" {
+		q = q.Where("list_id IS NULL")
+	} else if listID != "" {
+		q = q.Where("list_id = ?", listID)
+	}
+	for _, tag := range query["tag"] {
+		q = q.Where("id IN (SELECT todo_id FROM tags WHERE name = ?)", strings.ToLower(strings.TrimSpace(tag)))
+	}
+	if done := query.Get("done"); done != "" {
+		d, err := strconv.ParseBool(done)
+		if err != nil {
+			http.Error(w, "done must be true or false", http.StatusBadRequest)
+			return
+		}
+		q = q.Where("done = ?", d)
+	}
+	if priority := query.Get("priority"); priority != "" {
+		q = q.Where("priority = ?", priority)
+	}
+	if before := query.Get("due_before"); before != "" {
+		t, err := time.Parse(time.RFC3339, before)
+		if err != nil {
+			http.Error(w, "due_before must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		q = q.Where("due_date IS NOT NULL AND due_date < ?", t)
+	}
+	switch query.Get("sort") {
+	case "":
+		q = q.Order("id")
+	case "due":
+		q = q.Order("due_date IS NULL, due_date, id")
+	case "priority":
+		q = q.Order("CASE priority WHEN 'high' THEN 0 WHEN 'medium' THEN 1 ELSE 2 END, id")
+	default:
+		http.Error(w, "sort must be due or priority", http.StatusBadRequest)
+		return
+	}
+
+	// Read the sequence first: a change landing between the two queries is
+	// then replayed by the feed rather than missed
+	seq, err := latestSeq(db, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	var todos []Todo
-	db.Where("user_id = ?", userID).Find(&todos)
+	if err := q.Find(&todos).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-Change-Seq", strconv.FormatUint(uint64(seq), 10))
 	json.NewEncoder(w).Encode(todos)
 }
 
+// validateTodo fills in defaults and tidies tags, returning a message
+// for the client if anything is wrong
+func validateTodo(todo *Todo) string {
+	switch todo.Priority {
+	case "":
+		todo.Priority = PriorityMedium
+	case PriorityLow, PriorityMedium, PriorityHigh:
+	default:
+		return "Priority must be low, medium or high"
+	}
+	if len(todo.Notes) > 10000 {
+		return "Notes are at most 10000 characters"
+	}
+	seen := make(map[string]bool)
+	tags := todo.Tags[:0]
+	for _, tag := range todo.Tags {
+		name := strings.ToLower(strings.TrimSpace(tag.Name))
+		if name == "" || seen[name] {
+			continue
+		}
+		if len(name) > 32 {
+			return "Tags are at most 32 characters"
+		}
+		seen[name] = true
+		tags = append(tags, Tag{TodoID: todo.ID, Name: name})
+	}
+	if len(tags) > 20 {
+		return "A todo can have at most 20 tags"
+	}
+	todo.Tags = tags
+	return ""
+}
+
 func createTodo(w http.ResponseWriter, r *http.Request) {
-	userID, _ := strconv.Atoi(r.Header.Get("X-User-ID"))
+	userID := currentUser(r)
 	var todo Todo
 	if err := json.NewDecoder(r.Body).Decode(&todo); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	todo.UserID = uint(userID)
-	if err := db.Create(&todo).Error; err != nil {
+	todo.ID = 0
+	if msg := validateTodo(&todo); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if todo.ListID != nil && !checkListEdit(w, userID, *todo.ListID) {
+		return
+	}
+	now := clock.Now().String()
+	uid := newUID()
+	todo.UserID = userID
+	todo.Version = 1
+	todo.UID = &uid
+	todo.TaskClock, todo.DoneClock = now, now
+	todo, err := feed.record(db, func(tx *gorm.DB) (Todo, string, error) {
+		return todo, ChangeCreated, tx.Create(&todo).Error
+	})
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	json.NewEncoder(w).Encode(todo)
 }
 
-func updateTodo(w http.ResponseWriter, r *http.Request) {
-	userID, _ := strconv.Atoi(r.Header.Get("X-User-ID"))
-	vars := mux.Vars(r)
-	id, _ := strconv.Atoi(vars["id"])
+// loadTodo reads the todo in the URL for the current user, writing an
+// error and returning false unless they may edit it
+func loadTodo(w http.ResponseWriter, r *http.Request) (Todo, bool) {
 	var todo Todo
-	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&todo).Error; err != nil {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := db.Scopes(visibleTo(currentUser(r))).Preload("Tags").First(&todo, id).Error; err != nil {
 		http.NotFound(w, r)
+		return todo, false
+	}
+	permission, err := todoPermission(db, currentUser(r), todo)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return todo, false
+	}
+	if !can(permission, PermissionEdit) {
+		http.Error(w, "You can only view this list", http.StatusForbidden)
+		return todo, false
+	}
+	return todo, true
+}
+
+// updateTodo saves the todo if the version in the body, or the current
+// one if it is left out, is still the latest. Otherwise it responds 409
+// with the todo as it now is.
+func updateTodo(w http.ResponseWriter, r *http.Request) {
+	userID := currentUser(r)
+	todo, ok := loadTodo(w, r)
+	if !ok {
 		return
 	}
+	before := todo
+	before.Tags = append([]Tag(nil), todo.Tags...)
 	if err := json.NewDecoder(r.Body).Decode(&todo); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	db.Save(&todo)
+	todo.ID, todo.UserID, todo.UID = before.ID, before.UserID, before.UID
+	if msg := validateTodo(&todo); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if !sameList(todo.ListID, before.ListID) {
+		if todo.ListID != nil && !checkListEdit(w, userID, *todo.ListID) {
+			return
+		}
+		// Taking a todo off its list makes it the mover's own
+		if todo.ListID == nil {
+			todo.UserID = userID
+		}
+		todo.movedFrom = before.ListID
+	}
+	fields := map[string]interface{}{
+		"task":     todo.Task,
+		"done":     todo.Done,
+		"user_id":  todo.UserID,
+		"list_id":  todo.ListID,
+		"due_date": todo.DueDate,
+		"priority": todo.Priority,
+		"notes":    todo.Notes,
+		"version":  todo.Version + 1,
+	}
+	now := clock.Now().String()
+	if todo.Task != before.Task {
+		fields["task_clock"], todo.TaskClock = now, now
+	}
+	if todo.Done != before.Done {
+		fields["done_clock"], todo.DoneClock = now, now
+	}
+	todo, err := feed.record(db, func(tx *gorm.DB) (Todo, string, error) {
+		result := tx.Model(&Todo{}).
+			Where("id = ? AND version = ?", todo.ID, todo.Version).
+			Updates(fields)
+		if result.Error != nil {
+			return todo, "", result.Error
+		}
+		if result.RowsAffected == 0 {
+			return todo, "", errStaleVersion
+		}
+		if err := tx.Where("todo_id = ?", todo.ID).Delete(&Tag{}).Error; err != nil {
+			return todo, "", err
+		}
+		if len(todo.Tags) > 0 {
+			if err := tx.Create(&todo.Tags).Error; err != nil {
+				return todo, "", err
+			}
+		}
+		todo.Version++
+		return todo, ChangeUpdated, nil
+	})
+	if errors.Is(err, errStaleVersion) {
+		var current Todo
+		if err := db.Preload("Tags").First(&current, before.ID).Error; err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(current)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	json.NewEncoder(w).Encode(todo)
 }
 
+func sameList(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 func deleteTodo(w http.ResponseWriter, r *http.Request) {
-	userID, _ := strconv.Atoi(r.Header.Get("X-User-ID"))
-	vars := mux.Vars(r)
-	id, _ := strconv.Atoi(vars["id"])
-	if err := db.Where("id = ? AND user_id = ?", id, userID).Delete(&Todo{}).Error; err != nil {
+	todo, ok := loadTodo(w, r)
+	if !ok {
+		return
+	}
+	_, err := feed.record(db, func(tx *gorm.DB) (Todo, string, error) {
+		// Reread it inside the transaction in case it has just gone
+		if err := tx.First(&todo, todo.ID).Error; err != nil {
+			return todo, "", err
+		}
+		return todo, ChangeDeleted, softDelete(tx, &todo)
+	})
+	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func main() {
-	var err error
-	db = connectDB()
-	if err != nil {
-		log.Fatal("failed to connect database")
+// softDelete leaves a tombstone with the time of the delete, for /sync
+func softDelete(tx *gorm.DB, todo *Todo) error {
+	todo.DeleteClock = clock.Now().String()
+	todo.Version++
+	if err := tx.Model(todo).Updates(map[string]interface{}{"delete_clock": todo.DeleteClock, "version": todo.Version}).Error; err != nil {
+		return err
 	}
-	db.AutoMigrate(&User{}, &Todo{})
+	return tx.Delete(todo).Error
+}
 
+func newRouter() http.Handler {
 	r := mux.NewRouter()
 	r.HandleFunc("/register", register(db)).Methods("POST")
 	r.HandleFunc("/login", login(db)).Methods("POST")
 
 	r.HandleFunc("/todos", jwtMiddleware(getTodos)).Methods("GET")
 	r.HandleFunc("/todos", jwtMiddleware(createTodo)).Methods("POST")
+	r.HandleFunc("/todos/events", jwtMiddleware(streamChanges)).Methods("GET")
 	r.HandleFunc("/todos/{id}", jwtMiddleware(updateTodo)).Methods("PUT")
 	r.HandleFunc("/todos/{id}", jwtMiddleware(deleteTodo)).Methods("DELETE")
+	r.HandleFunc("/sync", jwtMiddleware(syncTodos)).Methods("POST")
+
+	r.HandleFunc("/lists", jwtMiddleware(getLists)).Methods("GET")
+	r.HandleFunc("/lists", jwtMiddleware(createList)).Methods("POST")
+	r.HandleFunc("/lists/{id}", jwtMiddleware(updateList)).Methods("PUT")
+	r.HandleFunc("/lists/{id}", jwtMiddleware(deleteList)).Methods("DELETE")
+	r.HandleFunc("/lists/{id}/members", jwtMiddleware(getMembers)).Methods("GET")
+	r.HandleFunc("/lists/{id}/members", jwtMiddleware(shareList)).Methods("POST")
+	r.HandleFunc("/lists/{id}/members/{userID}", jwtMiddleware(unshareList)).Methods("DELETE")
+
+	return cors.New(cors.Options{
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		ExposedHeaders: []string{"X-Change-Seq"},
+	}).Handler(r)
+}
+
+func main() {
+	db = connectDB()
+	if err := db.AutoMigrate(&User{}, &Todo{}, &Tag{}, &List{}, &ListMember{}, &Change{}); err != nil {
+		log.Fatal("failed to migrate database: ", err)
+	}
+	// Todos from before /sync have no UID yet
+	var missing []Todo
+	db.Unscoped().Where("uid IS NULL").Find(&missing)
+	for _, todo := range missing {
+		if err := db.Unscoped().Model(&todo).Update("uid", newUID()).Error; err != nil {
+			log.Fatal("failed to give todo a uid: ", err)
+		}
+	}
 
-	handler := cors.Default().Handler(r)
 	log.Println("Backend running on :8080")
-	log.Fatal(http.ListenAndServe(":8080", handler))
+	log.Fatal(http.ListenAndServe(":8080", newRouter()))
 }
@@ -1,23 +1,30 @@
 package main
 
 import (
+	"bufio"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 	"encoding/json"
+	"github.com/gorilla/mux"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 func setupTestDB() *gorm.DB {
 	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	db.AutoMigrate(&User{}, &Todo{})
+	// Every connection to :memory: is a new database, so keep to one
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	db.AutoMigrate(&User{}, &Todo{}, &Tag{}, &List{}, &ListMember{}, &Change{})
 	return db
 }
 
 func TestRegisterLoginAndTodoCRUD(t *testing.T) {
-	db := setupTestDB()
+	db = setupTestDB()
 	// Register
 	r := httptest.NewRequest("POST", "/register", strings.NewReader(`{"username":"testuser","password":"testpass"}`))
 	w := httptest.NewRecorder()
@@ -69,6 +76,7 @@ func TestRegisterLoginAndTodoCRUD(t *testing.T) {
 	// Update Todo
 	updateBody := `{"task":"Updated Todo","done":true}`
 	r = httptest.NewRequest("PUT", "/todos/1", strings.NewReader(updateBody))
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
 	r.Header.Set("Authorization", "Bearer "+resp.Token)
 	w = httptest.NewRecorder()
 	updateTodo := jwtMiddleware(updateTodo)
@@ -83,6 +91,7 @@ func TestRegisterLoginAndTodoCRUD(t *testing.T) {
 	}
 	// Delete Todo
 	r = httptest.NewRequest("DELETE", "/todos/1", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
 	r.Header.Set("Authorization", "Bearer "+resp.Token)
 	w = httptest.NewRecorder()
 	deleteTodo := jwtMiddleware(deleteTodo)
@@ -91,3 +100,412 @@ func TestRegisterLoginAndTodoCRUD(t *testing.T) {
 		t.Fatalf("expected 204, got %d", w.Code)
 	}
 }
+
+// signup registers a user through the server and returns their token
+func signup(t *testing.T, srv *httptest.Server, name string) string {
+	body := `{"username":"` + name + `","password":"testpass"}`
+	res, err := http.Post(srv.URL+"/register", "application/json", strings.NewReader(body))
+	if err != nil || res.StatusCode != http.StatusCreated {
+		t.Fatalf("register %s: %v %v", name, err, res)
+	}
+	res, err = http.Post(srv.URL+"/login", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var resp AuthResponse
+	json.NewDecoder(res.Body).Decode(&resp)
+	return resp.Token
+}
+
+func send(t *testing.T, srv *httptest.Server, method, path, token, body string) *http.Response {
+	req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// nextEvent reads one Server-Sent Event's data line, skipping heartbeats
+func nextEvent(t *testing.T, events *bufio.Reader) Change {
+	for {
+		line, err := events.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event: %v", err)
+		}
+		if strings.HasPrefix(line, "data: ") {
+			var change Change
+			if err := json.Unmarshal([]byte(line[len("data: "):]), &change); err != nil {
+				t.Fatal(err)
+			}
+			return change
+		}
+	}
+}
+
+func TestChangeFeed(t *testing.T) {
+	db = setupTestDB()
+	srv := httptest.NewServer(newRouter())
+	defer srv.Close()
+	ann, bob := signup(t, srv, "ann"), signup(t, srv, "bob")
+
+	res := send(t, srv, "GET", "/todos", ann, "")
+	res.Body.Close()
+	if seq := res.Header.Get("X-Change-Seq"); seq != "0" {
+		t.Fatalf("X-Change-Seq = %q, want 0", seq)
+	}
+
+	stream, err := http.Get(srv.URL + "/todos/events?since=0&access_token=" + ann)
+	if err != nil || stream.StatusCode != http.StatusOK {
+		t.Fatalf("opening stream: %v %v", err, stream)
+	}
+	defer stream.Body.Close()
+	events := bufio.NewReader(stream.Body)
+
+	var todo Todo
+	res = send(t, srv, "POST", "/todos", ann, `{"task":"Buy milk"}`)
+	json.NewDecoder(res.Body).Decode(&todo)
+	res.Body.Close()
+	send(t, srv, "POST", "/todos", bob, `{"task":"Not for ann"}`).Body.Close()
+	send(t, srv, "PUT", "/todos/1", ann, `{"task":"Buy oat milk","version":1}`).Body.Close()
+	send(t, srv, "DELETE", "/todos/1", ann, "").Body.Close()
+
+	created, updated, deleted := nextEvent(t, events), nextEvent(t, events), nextEvent(t, events)
+	if created.Type != ChangeCreated || created.Todo == nil || created.Todo.Task != "Buy milk" {
+		t.Errorf("first event = %+v", created)
+	}
+	if updated.Type != ChangeUpdated || updated.Todo.Task != "Buy oat milk" || updated.Todo.Version != 2 || updated.Seq <= created.Seq {
+		t.Errorf("second event = %+v", updated)
+	}
+	if deleted.Type != ChangeDeleted || deleted.TodoID != todo.ID || deleted.Todo != nil || deleted.Seq <= updated.Seq {
+		t.Errorf("third event = %+v", deleted)
+	}
+
+	// A client reconnecting after the first change gets the rest
+	req, _ := http.NewRequest("GET", srv.URL+"/todos/events", nil)
+	req.Header.Set("Authorization", "Bearer "+ann)
+	req.Header.Set("Last-Event-ID", strconv.FormatUint(uint64(created.Seq), 10))
+	resumed, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Body.Close()
+	events = bufio.NewReader(resumed.Body)
+	if change := nextEvent(t, events); change.Seq != updated.Seq {
+		t.Errorf("resumed at %d, want %d", change.Seq, updated.Seq)
+	}
+	if change := nextEvent(t, events); change.Seq != deleted.Seq {
+		t.Errorf("resumed then %d, want %d", change.Seq, deleted.Seq)
+	}
+
+	res = send(t, srv, "GET", "/todos", ann, "")
+	res.Body.Close()
+	if seq := res.Header.Get("X-Change-Seq"); seq != strconv.FormatUint(uint64(deleted.Seq), 10) {
+		t.Errorf("X-Change-Seq = %q, want %d", seq, deleted.Seq)
+	}
+}
+
+func TestStaleUpdate(t *testing.T) {
+	db = setupTestDB()
+	srv := httptest.NewServer(newRouter())
+	defer srv.Close()
+	ann := signup(t, srv, "ann")
+
+	send(t, srv, "POST", "/todos", ann, `{"task":"Call mum"}`).Body.Close()
+	if res := send(t, srv, "PUT", "/todos/1", ann, `{"task":"Call mum","done":true,"version":1}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("first update: %d", res.StatusCode)
+	}
+	res := send(t, srv, "PUT", "/todos/1", ann, `{"task":"Call dad","version":1}`)
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusConflict {
+		t.Fatalf("stale update: got %d, want 409", res.StatusCode)
+	}
+	var current Todo
+	json.NewDecoder(res.Body).Decode(&current)
+	if current.Task != "Call mum" || !current.Done || current.Version != 2 {
+		t.Errorf("409 body = %+v", current)
+	}
+	if res := send(t, srv, "DELETE", "/todos/1", ann, ""); res.StatusCode != http.StatusNoContent {
+		t.Errorf("delete: %d", res.StatusCode)
+	}
+	if res := send(t, srv, "DELETE", "/todos/1", ann, ""); res.StatusCode != http.StatusNotFound {
+		t.Errorf("deleting again: got %d, want 404", res.StatusCode)
+	}
+}
+
+func TestHybridClock(t *testing.T) {
+	wall := time.UnixMilli(1700000000000)
+	c := newHybridClock("server")
+	c.now = func() time.Time { return wall }
+
+	first := c.Now()
+	if first.String() != "1700000000000-00000000-server" {
+		t.Errorf("first = %s", first)
+	}
+	// The wall clock stepping back doesn't take the clock with it
+	wall = wall.Add(-time.Second)
+	if second := c.Now(); !second.After(first) || second.Wall != first.Wall {
+		t.Errorf("second = %s, want after %s", second, first)
+	}
+	// Nor does a remote clock that is ahead
+	remote := Timestamp{Wall: 1700000060000, Logical: 3, Node: "phone"}
+	c.Observe(remote)
+	if next := c.Now(); !next.After(remote) {
+		t.Errorf("after observing %s got %s", remote, next)
+	}
+
+	parsed, err := ParseTimestamp(remote.String())
+	if err != nil || parsed != remote {
+		t.Errorf("ParseTimestamp(%q) = %+v, %v", remote.String(), parsed, err)
+	}
+	for _, bad := range []string{"soon", "1700000000000-zz-phone", "1700000000000-00000000-", "-1-0-x"} {
+		if _, err := ParseTimestamp(bad); err == nil {
+			t.Errorf("ParseTimestamp(%q) succeeded", bad)
+		}
+	}
+}
+
+// syncOps posts ops to /sync and returns the response
+func syncOps(t *testing.T, srv *httptest.Server, token string, since uint, ops ...SyncOp) SyncResponse {
+	body, _ := json.Marshal(SyncRequest{Since: since, Ops: ops})
+	res := send(t, srv, "POST", "/sync", token, string(body))
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("sync: %d", res.StatusCode)
+	}
+	var resp SyncResponse
+	json.NewDecoder(res.Body).Decode(&resp)
+	return resp
+}
+
+func TestSync(t *testing.T) {
+	db = setupTestDB()
+	srv := httptest.NewServer(newRouter())
+	defer srv.Close()
+	ann, bob := signup(t, srv, "ann"), signup(t, srv, "bob")
+
+	now := time.Now().UnixMilli()
+	ts := func(offset int64, node string) string {
+		return Timestamp{Wall: now + offset, Node: node}.String()
+	}
+	str := func(s string) *string { return &s }
+	yes, no := true, false
+
+	// The phone made two todos offline and the laptop renamed one of them
+	// before finishing it; the laptop's rename happened first
+	phone := syncOps(t, srv, ann, 0,
+		SyncOp{UID: "a", TS: ts(-3000, "phone"), Task: str("Buy milk"), Done: &no},
+		SyncOp{UID: "b", TS: ts(-3000, "phone"), Task: str("Walk dog")},
+	)
+	if len(phone.Todos) != 2 || phone.Token == 0 || len(phone.Rejected) != 0 {
+		t.Fatalf("first sync = %+v", phone)
+	}
+	laptop := syncOps(t, srv, ann, phone.Token,
+		SyncOp{UID: "a", TS: ts(-4000, "laptop"), Task: str("Buy oat milk")},
+		SyncOp{UID: "a", TS: ts(-1000, "laptop"), Done: &yes},
+	)
+	if len(laptop.Todos) != 1 {
+		t.Fatalf("laptop delta = %+v", laptop.Todos)
+	}
+	if got := laptop.Todos[0]; got.Task != "Buy milk" || !got.Done || got.Version != 2 {
+		t.Errorf("merged todo = %+v, want the phone's task and the laptop's done", got)
+	}
+
+	// Deleting leaves a tombstone that an older edit doesn't bring back
+	phone = syncOps(t, srv, ann, phone.Token,
+		SyncOp{UID: "b", TS: ts(-500, "phone"), Deleted: &yes},
+		SyncOp{UID: "b", TS: ts(-2000, "laptop"), Task: str("Walk cat")},
+	)
+	if len(phone.Todos) != 2 {
+		t.Fatalf("phone delta = %+v", phone.Todos)
+	}
+	if got := phone.Todos[1]; got.UID != "b" || !got.Deleted || got.Task != "Walk cat" || got.DeletedTS != ts(-500, "phone") {
+		t.Errorf("tombstone = %+v", got)
+	}
+	res := send(t, srv, "GET", "/todos", ann, "")
+	var todos []Todo
+	json.NewDecoder(res.Body).Decode(&todos)
+	res.Body.Close()
+	if len(todos) != 1 || todos[0].Task != "Buy milk" {
+		t.Errorf("GET /todos after delete = %+v", todos)
+	}
+
+	// Nothing new since the last token; stale ops change nothing
+	again := syncOps(t, srv, ann, phone.Token, SyncOp{UID: "a", TS: ts(-9000, "phone"), Task: str("Old")})
+	if len(again.Todos) != 0 || again.Token != phone.Token {
+		t.Errorf("no-op sync = %+v", again)
+	}
+
+	rejected := syncOps(t, srv, bob, 0,
+		SyncOp{UID: "a", TS: ts(0, "bob"), Task: str("Mine now")},
+		SyncOp{UID: "", TS: ts(0, "bob"), Task: str("x")},
+		SyncOp{UID: "c", TS: "yesterday", Task: str("x")},
+		SyncOp{UID: "d", TS: ts(int64(time.Hour/time.Millisecond), "bob"), Task: str("x")},
+	)
+	if len(rejected.Rejected) != 4 || len(rejected.Todos) != 0 {
+		t.Errorf("bob's sync = %+v", rejected)
+	}
+
+	// REST edits take part in the merge too
+	res = send(t, srv, "POST", "/todos", ann, `{"task":"From the web"}`)
+	var created Todo
+	json.NewDecoder(res.Body).Decode(&created)
+	res.Body.Close()
+	if created.UID == nil {
+		t.Fatal("POST /todos gave no uid")
+	}
+	later := syncOps(t, srv, ann, again.Token, SyncOp{UID: *created.UID, TS: ts(-60000, "phone"), Task: str("Offline edit")})
+	if len(later.Todos) != 1 || later.Todos[0].Task != "From the web" {
+		t.Errorf("older offline edit beat a web edit: %+v", later.Todos)
+	}
+}
+
+func TestListsAndSharing(t *testing.T) {
+	db = setupTestDB()
+	srv := httptest.NewServer(newRouter())
+	defer srv.Close()
+	ann, bob, cat := signup(t, srv, "ann"), signup(t, srv, "bob"), signup(t, srv, "cat")
+	decode := func(res *http.Response, out interface{}) int {
+		defer res.Body.Close()
+		if out != nil && res.StatusCode < 300 {
+			json.NewDecoder(res.Body).Decode(out)
+		}
+		return res.StatusCode
+	}
+
+	var list List
+	if code := decode(send(t, srv, "POST", "/lists", ann, `{"name":"Groceries"}`), &list); code != http.StatusCreated || list.Permission != PermissionOwner {
+		t.Fatalf("creating list: %d %+v", code, list)
+	}
+	path := "/lists/" + strconv.Itoa(int(list.ID))
+	if code := decode(send(t, srv, "POST", path+"/members", ann, `{"username":"bob","permission":"edit"}`), nil); code != http.StatusOK {
+		t.Fatalf("sharing with bob: %d", code)
+	}
+	if code := decode(send(t, srv, "POST", path+"/members", ann, `{"username":"cat","permission":"view"}`), nil); code != http.StatusOK {
+		t.Fatalf("sharing with cat: %d", code)
+	}
+	if code := decode(send(t, srv, "POST", path+"/members", ann, `{"username":"nobody","permission":"view"}`), nil); code != http.StatusNotFound {
+		t.Errorf("sharing with an unknown user: got %d, want 404", code)
+	}
+	if code := decode(send(t, srv, "POST", path+"/members", bob, `{"username":"cat","permission":"edit"}`), nil); code != http.StatusForbidden {
+		t.Errorf("editor sharing: got %d, want 403", code)
+	}
+
+	// Cat watches the feed while bob adds to the shared list
+	stream, err := http.Get(srv.URL + "/todos/events?since=0&access_token=" + cat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	events := bufio.NewReader(stream.Body)
+
+	body := `{"task":"Eggs","list_id":` + strconv.Itoa(int(list.ID)) + `,"priority":"high","due_date":"2030-01-02T15:04:05Z","tags":["Dairy"," shop ","dairy"]}`
+	var eggs Todo
+	if code := decode(send(t, srv, "POST", "/todos", bob, body), &eggs); code != http.StatusOK {
+		t.Fatalf("bob adding to the list: %d", code)
+	}
+	if len(eggs.Tags) != 2 || eggs.Tags[0].Name != "dairy" || eggs.Tags[1].Name != "shop" {
+		t.Errorf("tags = %+v", eggs.Tags)
+	}
+	if change := nextEvent(t, events); change.TodoID != eggs.ID || change.Type != ChangeCreated {
+		t.Errorf("cat's event = %+v", change)
+	}
+	if code := decode(send(t, srv, "POST", "/todos", cat, `{"task":"Ham","list_id":`+strconv.Itoa(int(list.ID))+`}`), nil); code != http.StatusForbidden {
+		t.Errorf("viewer adding: got %d, want 403", code)
+	}
+	if code := decode(send(t, srv, "PUT", "/todos/"+strconv.Itoa(int(eggs.ID)), cat, `{"done":true}`), nil); code != http.StatusForbidden {
+		t.Errorf("viewer editing: got %d, want 403", code)
+	}
+	if code := decode(send(t, srv, "POST", "/todos", cat, `{"task":"Ham","priority":"urgent"}`), nil); code != http.StatusBadRequest {
+		t.Errorf("bad priority: got %d, want 400", code)
+	}
+	decode(send(t, srv, "POST", "/todos", ann, `{"task":"Taxes","priority":"low","tags":["admin"]}`), nil)
+
+	var todos []Todo
+	decode(send(t, srv, "GET", "/todos?sort=priority", ann, ""), &todos)
+	if len(todos) != 2 || todos[0].Task != "Eggs" || todos[1].Task != "Taxes" {
+		t.Errorf("ann's todos = %+v", todos)
+	}
+	todos = nil
+	decode(send(t, srv, "GET", "/todos?tag=dairy&tag=shop", cat, ""), &todos)
+	if len(todos) != 1 || todos[0].ID != eggs.ID {
+		t.Errorf("cat's dairy todos = %+v", todos)
+	}
+	todos = nil
+	decode(send(t, srv, "GET", "/todos?list_id=0", ann, ""), &todos)
+	if len(todos) != 1 || todos[0].Task != "Taxes" {
+		t.Errorf("ann's todos on no list = %+v", todos)
+	}
+	todos = nil
+	decode(send(t, srv, "GET", "/todos?due_before=2031-01-01T00:00:00Z", bob, ""), &todos)
+	if len(todos) != 1 || todos[0].ID != eggs.ID {
+		t.Errorf("bob's todos due before 2031 = %+v", todos)
+	}
+
+	// Taking a todo off the list tells the list's members it has gone
+	var bread Todo
+	decode(send(t, srv, "POST", "/todos", ann, `{"task":"Bread","list_id":`+strconv.Itoa(int(list.ID))+`}`), &bread)
+	if change := nextEvent(t, events); change.TodoID != bread.ID || change.Type != ChangeCreated {
+		t.Errorf("cat's event for bread = %+v", change)
+	}
+	if code := decode(send(t, srv, "PUT", "/todos/"+strconv.Itoa(int(bread.ID)), ann, `{"list_id":null}`), nil); code != http.StatusOK {
+		t.Fatalf("moving bread off the list: %d", code)
+	}
+	if change := nextEvent(t, events); change.TodoID != bread.ID || change.Type != ChangeDeleted {
+		t.Errorf("cat's event for moving bread = %+v", change)
+	}
+
+	// Cat leaves: the list's todos are no longer theirs to see
+	var members []ListMember
+	decode(send(t, srv, "GET", path+"/members", cat, ""), &members)
+	if len(members) != 3 || members[2].User.Username != "cat" || members[2].Permission != PermissionView {
+		t.Fatalf("members = %+v", members)
+	}
+	catID := strconv.Itoa(int(members[2].UserID))
+	before := syncOps(t, srv, cat, 0)
+	if code := decode(send(t, srv, "DELETE", path+"/members/"+catID, cat, ""), nil); code != http.StatusNoContent {
+		t.Fatalf("cat leaving: %d", code)
+	}
+	if change := nextEvent(t, events); change.TodoID != eggs.ID || change.Type != ChangeDeleted {
+		t.Errorf("cat's event for leaving = %+v", change)
+	}
+	if left := syncOps(t, srv, cat, before.Token); len(left.Todos) != 1 || left.Todos[0].ID != eggs.ID || !left.Todos[0].Deleted {
+		t.Errorf("cat's delta after leaving = %+v", left.Todos)
+	}
+	todos = nil
+	decode(send(t, srv, "GET", "/todos", cat, ""), &todos)
+	if len(todos) != 0 {
+		t.Errorf("cat still sees %+v", todos)
+	}
+
+	// Sharing puts the list's todos in the new member's delta; changing
+	// their permission afterwards adds nothing
+	dan := signup(t, srv, "dan")
+	decode(send(t, srv, "POST", "/todos", dan, `{"task":"Laundry"}`), nil)
+	first := syncOps(t, srv, dan, 0)
+	if code := decode(send(t, srv, "POST", path+"/members", ann, `{"username":"dan","permission":"view"}`), nil); code != http.StatusOK {
+		t.Fatalf("sharing with dan: %d", code)
+	}
+	shared := syncOps(t, srv, dan, first.Token)
+	if len(shared.Todos) != 1 || shared.Todos[0].ID != eggs.ID || shared.Todos[0].Deleted || shared.Todos[0].Task != "Eggs" {
+		t.Errorf("dan's delta after sharing = %+v", shared.Todos)
+	}
+	decode(send(t, srv, "POST", path+"/members", ann, `{"username":"dan","permission":"edit"}`), nil)
+	if again := syncOps(t, srv, dan, shared.Token); len(again.Todos) != 0 {
+		t.Errorf("dan's delta after a permission change = %+v", again.Todos)
+	}
+
+	if code := decode(send(t, srv, "DELETE", path, bob, ""), nil); code != http.StatusForbidden {
+		t.Errorf("editor deleting the list: got %d, want 403", code)
+	}
+	if code := decode(send(t, srv, "DELETE", path, ann, ""), nil); code != http.StatusNoContent {
+		t.Errorf("deleting the list: %d", code)
+	}
+	todos = nil
+	decode(send(t, srv, "GET", "/todos", bob, ""), &todos)
+	if len(todos) != 0 {
+		t.Errorf("bob still sees %+v after the list was deleted", todos)
+	}
+}
@@ -1,19 +1,38 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"strings"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+type contextKey int
+
+const userIDKey contextKey = iota
+
+// currentUser is the ID of the user jwtMiddleware authenticated
+func currentUser(r *http.Request) uint {
+	id, _ := r.Context().Value(userIDKey).(uint)
+	return id
+}
+
 func jwtMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		header := r.Header.Get("Authorization")
-		if header == "" || !strings.HasPrefix(header, "Bearer ") {
+		var tokenStr string
+		switch {
+		case strings.HasPrefix(header, "Bearer "):
+			tokenStr = strings.TrimPrefix(header, "Bearer ")
+		case header == "":
+			// EventSource can't set headers, so the change feed passes
+			// the token in the query string instead
+			tokenStr = r.URL.Query().Get("access_token")
+		}
+		if tokenStr == "" {
 			http.Error(w, "Missing or invalid token", http.StatusUnauthorized)
 			return
 		}
-		tokenStr := strings.TrimPrefix(header, "Bearer ")
 		claims := &Claims{}
 		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
 			return jwtKey, nil
@@ -22,8 +41,6 @@ func jwtMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
-		// Attach user ID to context if needed
-		r.Header.Set("X-User-ID", string(rune(claims.UserID)))
-		next(w, r)
+		next(w, r.WithContext(context.WithValue(r.Context(), userIDKey, claims.UserID)))
 	}
 }
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"encoding/json"
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -10,9 +13,111 @@ type User struct {
 	Password string `json:"-"`
 }
 
+// Todo belongs to the user who made it, and is theirs alone, unless it is
+// on a list, in which case everyone the list is shared with sees it
 type Todo struct {
-	ID     uint   `gorm:"primaryKey" json:"id"`
-	Task   string `json:"task"`
-	Done   bool   `json:"done"`
-	UserID uint   `json:"user_id"`
+	ID       uint       `gorm:"primaryKey" json:"id"`
+	Task     string     `json:"task"`
+	Done     bool       `json:"done"`
+	UserID   uint       `json:"user_id"`
+	ListID   *uint      `gorm:"index" json:"list_id"`
+	DueDate  *time.Time `json:"due_date"`
+	Priority string     `gorm:"not null;default:medium" json:"priority"`
+	Notes    string     `json:"notes"`
+	Tags     []Tag      `gorm:"foreignKey:TodoID" json:"tags"`
+	// Version goes up by one on every update. Clients send back the
+	// version they last saw and get 409 Conflict if someone else got
+	// there first.
+	Version uint `gorm:"not null;default:1" json:"version"`
+	// UID identifies the todo across devices. Offline clients make it up
+	// when they create a todo; the server makes one for POST /todos.
+	UID *string `gorm:"uniqueIndex" json:"uid"`
+	// Deleted todos are kept as tombstones so that /sync can tell other
+	// devices about the delete
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	// When each field was last written, as Timestamps. /sync merges
+	// field by field and keeps whichever write is later.
+	TaskClock   string `json:"-"`
+	DoneClock   string `json:"-"`
+	DeleteClock string `json:"-"`
+
+	// movedFrom is the list an update took the todo off, so the change
+	// feed can tell that list's members it is gone
+	movedFrom *uint
+}
+
+// Priorities a todo can have
+const (
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+)
+
+// Tag is a label on a todo. In JSON it is just its name.
+type Tag struct {
+	TodoID uint   `gorm:"primaryKey"`
+	Name   string `gorm:"primaryKey;index"`
+}
+
+func (t Tag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Name)
+}
+
+func (t *Tag) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &t.Name)
+}
+
+// List is a named group of todos that its owner can share
+type List struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	OwnerID   uint      `gorm:"index;not null" json:"owner_id"`
+	CreatedAt time.Time `json:"created_at"`
+	// Permission is the current user's, when lists are read for them
+	Permission string `gorm:"->;-:migration" json:"permission,omitempty"`
+}
+
+// Permissions on a list, each allowing everything the ones before it do
+const (
+	PermissionView  = "view"
+	PermissionEdit  = "edit"
+	PermissionOwner = "owner"
+)
+
+// ListMember gives a user a permission on a list. The owner is a member
+// too, with PermissionOwner.
+type ListMember struct {
+	ListID     uint   `gorm:"primaryKey" json:"list_id"`
+	UserID     uint   `gorm:"primaryKey;index" json:"user_id"`
+	Permission string `gorm:"not null" json:"permission"`
+	User       User   `gorm:"foreignKey:UserID" json:"user"`
+}
+
+// Change kinds pushed on the change feed
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// Change is one entry in a user's change feed. Seq increases with every
+// write, so a client that has seen up to N can ask for everything after
+// it when it reconnects.
+type Change struct {
+	Seq       uint      `gorm:"primaryKey" json:"seq"`
+	UserID    uint      `gorm:"index;not null" json:"-"`
+	Type      string    `gorm:"not null" json:"type"`
+	TodoID    uint      `gorm:"not null" json:"todo_id"`
+	Todo      *Todo     `gorm:"-" json:"todo,omitempty"`
+	Data      string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AfterFind restores the todo snapshot stored with the change
+func (c *Change) AfterFind(tx *gorm.DB) error {
+	if c.Data == "" {
+		return nil
+	}
+	c.Todo = &Todo{}
+	return json.Unmarshal([]byte(c.Data), c.Todo)
 }
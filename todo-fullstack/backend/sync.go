@@ -0,0 +1,289 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxSyncOps caps the operations in one /sync request
+	maxSyncOps = 500
+	// maxClockDrift is how far ahead of the server a client's timestamp
+	// may be. A device with its clock set to next year would otherwise win
+	// every conflict until then.
+	maxClockDrift = 5 * time.Minute
+)
+
+var (
+	errInvalidUID = errors.New("uid is required and at most 64 characters")
+	errClockDrift = errors.New("timestamp is too far in the future")
+	errForeignUID = errors.New("uid belongs to a todo you can't edit")
+	// errUnchanged means every field in the op was older than what the
+	// server has, so there is nothing to save
+	errUnchanged = errors.New("unchanged")
+)
+
+// SyncOp is one change a client made, maybe while offline. Only the fields
+// that are set are written, and each only if TS is later than the last
+// write to that field.
+type SyncOp struct {
+	UID     string  `json:"uid"`
+	TS      string  `json:"ts"`
+	Task    *string `json:"task,omitempty"`
+	Done    *bool   `json:"done,omitempty"`
+	Deleted *bool   `json:"deleted,omitempty"`
+}
+
+type SyncRequest struct {
+	// Since is the token from the last sync, or 0 the first time
+	Since uint     `json:"since"`
+	Ops   []SyncOp `json:"ops"`
+}
+
+// SyncTodo is a todo as /sync sends it back, with its field timestamps so
+// the client can merge it the same way the server does. Deleted todos come
+// back as tombstones. The list, due date, priority, notes and tags are
+// only changed through the REST API and have no timestamps.
+type SyncTodo struct {
+	ID        uint       `json:"id"`
+	UID       string     `json:"uid"`
+	Task      string     `json:"task"`
+	Done      bool       `json:"done"`
+	Version   uint       `json:"version"`
+	Deleted   bool       `json:"deleted"`
+	TaskTS    string     `json:"task_ts"`
+	DoneTS    string     `json:"done_ts"`
+	DeletedTS string     `json:"deleted_ts"`
+	ListID    *uint      `json:"list_id"`
+	DueDate   *time.Time `json:"due_date"`
+	Priority  string     `json:"priority"`
+	Notes     string     `json:"notes"`
+	Tags      []Tag      `json:"tags"`
+}
+
+type RejectedOp struct {
+	UID   string `json:"uid"`
+	Error string `json:"error"`
+}
+
+type SyncResponse struct {
+	// Token goes in the next request's since
+	Token uint `json:"token"`
+	// Clock is the server's time now, for the client's clock to observe
+	Clock    string       `json:"clock"`
+	Todos    []SyncTodo   `json:"todos"`
+	Rejected []RejectedOp `json:"rejected"`
+}
+
+// syncTodos applies a batch of client operations and returns every todo
+// that changed since the client's last sync, including the ones it just
+// sent, as merged.
+func syncTodos(w http.ResponseWriter, r *http.Request) {
+	userID := currentUser(r)
+	var req SyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Ops) > maxSyncOps {
+		http.Error(w, fmt.Sprintf("At most %d operations per sync", maxSyncOps), http.StatusBadRequest)
+		return
+	}
+
+	resp := SyncResponse{Todos: []SyncTodo{}, Rejected: []RejectedOp{}}
+	for _, op := range req.Ops {
+		err := applyOp(userID, op)
+		switch {
+		case err == nil, errors.Is(err, errUnchanged):
+		case errors.Is(err, errInvalidUID), errors.Is(err, errInvalidTimestamp),
+			errors.Is(err, errClockDrift), errors.Is(err, errForeignUID):
+			resp.Rejected = append(resp.Rejected, RejectedOp{UID: op.UID, Error: err.Error()})
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var err error
+	if resp.Token, err = latestSeq(db, userID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	todos, err := delta(userID, req.Since, resp.Token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp.Todos = append(resp.Todos, todos...)
+	resp.Clock = clock.Now().String()
+	json.NewEncoder(w).Encode(resp)
+}
+
+// applyOp merges op into the todo with its UID, creating it, or a
+// tombstone for it, if the server hasn't seen it yet
+func applyOp(userID uint, op SyncOp) error {
+	if op.UID == "" || len(op.UID) > 64 {
+		return errInvalidUID
+	}
+	ts, err := ParseTimestamp(op.TS)
+	if err != nil || ts.IsZero() {
+		return errInvalidTimestamp
+	}
+	if ts.Wall > clock.now().Add(maxClockDrift).UnixMilli() {
+		return errClockDrift
+	}
+	clock.Observe(ts)
+
+	_, err = feed.record(db, func(tx *gorm.DB) (Todo, string, error) {
+		var todo Todo
+		err := tx.Unscoped().Where("uid = ?", op.UID).First(&todo).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			uid := op.UID
+			todo = Todo{UserID: userID, UID: &uid, Version: 1, Priority: PriorityMedium}
+			if !mergeOp(&todo, op, ts) {
+				return todo, "", errUnchanged
+			}
+			if err := tx.Create(&todo).Error; err != nil {
+				return todo, "", err
+			}
+			if todo.DeletedAt.Valid {
+				return todo, ChangeDeleted, nil
+			}
+			return todo, ChangeCreated, nil
+		}
+		if err != nil {
+			return todo, "", err
+		}
+		permission, err := todoPermission(tx, userID, todo)
+		if err != nil {
+			return todo, "", err
+		}
+		if !can(permission, PermissionEdit) {
+			return todo, "", errForeignUID
+		}
+
+		wasDeleted := todo.DeletedAt.Valid
+		if !mergeOp(&todo, op, ts) {
+			return todo, "", errUnchanged
+		}
+		todo.Version++
+		if err := tx.Unscoped().Save(&todo).Error; err != nil {
+			return todo, "", err
+		}
+		switch {
+		case todo.DeletedAt.Valid:
+			return todo, ChangeDeleted, nil
+		case wasDeleted:
+			return todo, ChangeCreated, nil
+		}
+		return todo, ChangeUpdated, nil
+	})
+	return err
+}
+
+// mergeOp writes each field op sets whose last write is older than ts, and
+// reports whether it wrote any
+func mergeOp(todo *Todo, op SyncOp, ts Timestamp) bool {
+	newer := func(last string) bool {
+		// A stored timestamp that doesn't parse is as good as none
+		t, _ := ParseTimestamp(last)
+		return ts.After(t)
+	}
+	changed := false
+	if op.Task != nil && newer(todo.TaskClock) {
+		todo.Task, todo.TaskClock = *op.Task, ts.String()
+		changed = true
+	}
+	if op.Done != nil && newer(todo.DoneClock) {
+		todo.Done, todo.DoneClock = *op.Done, ts.String()
+		changed = true
+	}
+	if op.Deleted != nil && newer(todo.DeleteClock) {
+		todo.DeleteClock = ts.String()
+		if *op.Deleted {
+			todo.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		} else {
+			todo.DeletedAt = gorm.DeletedAt{}
+		}
+		changed = true
+	}
+	return changed
+}
+
+// delta is the state of every todo the user saw change after since, up
+// to and including token. A first sync gets all live todos instead.
+func delta(userID, since, token uint) ([]SyncTodo, error) {
+	var todos []Todo
+	if since == 0 || since > token {
+		err := db.Scopes(visibleTo(userID)).Preload("Tags").Order("id").Find(&todos).Error
+		return syncTodoList(todos, nil), err
+	}
+
+	var ids []uint
+	err := db.Model(&Change{}).Distinct("todo_id").
+		Where("user_id = ? AND seq > ? AND seq <= ?", userID, since, token).
+		Pluck("todo_id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	err = db.Unscoped().Scopes(visibleTo(userID)).Preload("Tags").Where("id IN ?", ids).Order("id").Find(&todos).Error
+	if err != nil {
+		return nil, err
+	}
+	return syncTodoList(todos, ids), nil
+}
+
+// syncTodoList converts todos, adding a bare tombstone for each of ids
+// that isn't among them: todos deleted before deletes were kept, and
+// todos on lists the user has since lost
+func syncTodoList(todos []Todo, ids []uint) []SyncTodo {
+	found := make(map[uint]bool, len(todos))
+	list := make([]SyncTodo, 0, len(ids))
+	for _, todo := range todos {
+		found[todo.ID] = true
+		uid := ""
+		if todo.UID != nil {
+			uid = *todo.UID
+		}
+		list = append(list, SyncTodo{
+			ID:        todo.ID,
+			UID:       uid,
+			Task:      todo.Task,
+			Done:      todo.Done,
+			Version:   todo.Version,
+			Deleted:   todo.DeletedAt.Valid,
+			TaskTS:    todo.TaskClock,
+			DoneTS:    todo.DoneClock,
+			DeletedTS: todo.DeleteClock,
+			ListID:    todo.ListID,
+			DueDate:   todo.DueDate,
+			Priority:  todo.Priority,
+			Notes:     todo.Notes,
+			Tags:      todo.Tags,
+		})
+	}
+	for _, id := range ids {
+		if !found[id] {
+			list = append(list, SyncTodo{ID: id, Deleted: true})
+		}
+	}
+	return list
+}
+
+// newUID returns a random version 4 UUID, the same kind of ID offline
+// clients make up
+func newUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
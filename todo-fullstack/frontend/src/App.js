@@ -13,15 +13,50 @@ function App() {
   const [isLoggedIn, setIsLoggedIn] = useState(!!getToken());
 
   useEffect(() => {
-    if (isLoggedIn) {
-      fetch(API, {
-        headers: { Authorization: `Bearer ${getToken()}` }
+    if (!isLoggedIn) return;
+    let events;
+    let closed = false;
+    fetch(API, {
+      headers: { Authorization: `Bearer ${getToken()}` }
+    })
+      .then(res => {
+        const since = res.headers.get("X-Change-Seq") || "0";
+        return res.json().then(data => [data, since]);
       })
-        .then(res => res.json())
-        .then(setTodos);
-    }
+      .then(([data, since]) => {
+        if (closed) return;
+        setTodos(data);
+        // Changes made in other tabs and devices. EventSource reconnects
+        // by itself and resumes from the last change it saw.
+        events = new EventSource(
+          `${API}/events?since=${since}&access_token=${encodeURIComponent(getToken())}`
+        );
+        events.onmessage = e => applyChange(JSON.parse(e.data));
+      });
+    return () => {
+      closed = true;
+      if (events) events.close();
+    };
   }, [isLoggedIn]);
 
+  const applyChange = change => {
+    if (change.type === "deleted") {
+      setTodos(todos => todos.filter(t => t.id !== change.todo_id));
+    } else {
+      upsertTodo(change.todo);
+    }
+  };
+
+  // upsertTodo adds or replaces a todo, keeping whichever version is newer
+  const upsertTodo = todo => {
+    setTodos(todos => {
+      const current = todos.find(t => t.id === todo.id);
+      if (!current) return [...todos, todo];
+      if (current.version > todo.version) return todos;
+      return todos.map(t => (t.id === todo.id ? todo : t));
+    });
+  };
+
   const handleRegister = async e => {
     e.preventDefault();
     try {
@@ -63,8 +98,7 @@ function App() {
       },
       body: JSON.stringify({ task, done: false })
     });
-    const newTodo = await res.json();
-    setTodos([...todos, newTodo]);
+    upsertTodo(await res.json());
     setTask("");
   };
 
@@ -78,8 +112,9 @@ function App() {
       },
       body: JSON.stringify({ ...todo, done: !todo.done })
     });
-    const updated = await res.json();
-    setTodos(todos.map(t => (t.id === id ? updated : t)));
+    // On 409 the body is the todo as someone else left it; show that
+    // rather than overwrite their change
+    if (res.ok || res.status === 409) upsertTodo(await res.json());
   };
 
   const deleteTodo = async id => {
@@ -87,7 +122,7 @@ function App() {
       method: "DELETE",
       headers: { Authorization: `Bearer ${getToken()}` }
     });
-    setTodos(todos.filter(t => t.id !== id));
+    setTodos(todos => todos.filter(t => t.id !== id));
   };
 
   if (!isLoggedIn) {
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wallet-app/internal/config"
+	"wallet-app/internal/middleware"
+	"wallet-app/internal/services"
+	"wallet-app/migrations"
+	"wallet-app/pkg/database"
+	"wallet-app/pkg/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const testSecret = "integration-test-secret"
+
+// api runs the whole service against a freshly migrated SQLite database.
+type api struct {
+	t      *testing.T
+	router *gin.Engine
+}
+
+func newTestAPI(t *testing.T) *api {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file:"+uuid.NewString()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	if _, err := database.Migrate(db, migrations.SQLite()); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{JWTSecret: testSecret, MaxTransactions: 100}
+	rates := services.StaticRates{"USD/EUR": money.MustParseRate("0.9")}
+	return &api{t: t, router: newRouter(cfg, db, rates)}
+}
+
+func token(t *testing.T, secret string, claims middleware.Claims) string {
+	t.Helper()
+	claims.ExpiresAt = time.Now().Add(time.Hour).Unix()
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// user is someone calling the API with their own token.
+type user struct {
+	api   *api
+	ID    uuid.UUID
+	token string
+}
+
+func (a *api) user(role string) *user {
+	id := uuid.New()
+	return &user{api: a, ID: id, token: token(a.t, testSecret, middleware.Claims{UserID: id.String(), Role: role})}
+}
+
+// do sends body as JSON and decodes the response into out, if given.
+func (u *user) do(method, path string, body, out interface{}) int {
+	u.api.t.Helper()
+	return u.api.request(method, path, "Bearer "+u.token, body, out)
+}
+
+func (a *api) request(method, path, authorization string, body, out interface{}) int {
+	a.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			a.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	if out != nil && rec.Code < 300 {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			a.t.Fatalf("%s %s: decoding %s: %v", method, path, rec.Body, err)
+		}
+	}
+	return rec.Code
+}
+
+type wallet struct {
+	ID       uuid.UUID `json:"id"`
+	UserID   uuid.UUID `json:"user_id"`
+	Balance  string    `json:"balance"`
+	Currency string    `json:"currency"`
+}
+
+func (u *user) wallet(currency, deposit string) wallet {
+	u.api.t.Helper()
+	var w wallet
+	if code := u.do("POST", "/api/v1/wallets", map[string]string{"currency": currency}, &w); code != http.StatusCreated {
+		u.api.t.Fatalf("creating wallet: %d", code)
+	}
+	if deposit != "" {
+		if code := u.do("POST", "/api/v1/wallets/"+w.ID.String()+"/deposit", map[string]string{"amount": deposit}, nil); code != http.StatusCreated {
+			u.api.t.Fatalf("depositing: %d", code)
+		}
+	}
+	return w
+}
+
+func (u *user) balance(id uuid.UUID) string {
+	u.api.t.Helper()
+	var w wallet
+	if code := u.do("GET", "/api/v1/wallets/"+id.String(), nil, &w); code != http.StatusOK {
+		u.api.t.Fatalf("getting wallet: %d", code)
+	}
+	return w.Balance
+}
+
+func TestAuthentication(t *testing.T) {
+	a := newTestAPI(t)
+	path := "/api/v1/wallets/" + uuid.NewString()
+
+	tests := map[string]string{
+		"no token":       "",
+		"garbage":        "Bearer not-a-jwt",
+		"wrong secret":   "Bearer " + token(t, "another-secret", middleware.Claims{UserID: uuid.NewString()}),
+		"no user ID":     "Bearer " + token(t, testSecret, middleware.Claims{}),
+		"non-UUID user":  "Bearer " + token(t, testSecret, middleware.Claims{UserID: "alice"}),
+		"unsigned token": "Bearer " + unsigned(t, middleware.Claims{UserID: uuid.NewString()}),
+	}
+	for name, authorization := range tests {
+		if code := a.request("GET", path, authorization, nil, nil); code != http.StatusUnauthorized {
+			t.Errorf("%s: got %d, want 401", name, code)
+		}
+	}
+
+	if code := a.request("GET", "/health", "", nil, nil); code != http.StatusOK {
+		t.Errorf("health check: got %d", code)
+	}
+}
+
+func unsigned(t *testing.T, claims middleware.Claims) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestWalletLifecycle(t *testing.T) {
+	a := newTestAPI(t)
+	alice := a.user("")
+
+	w := alice.wallet("USD", "100.50")
+	if w.UserID != alice.ID || w.Currency != "USD" {
+		t.Errorf("created %+v", w)
+	}
+	if code := alice.do("POST", "/api/v1/wallets/"+w.ID.String()+"/withdraw", map[string]string{"amount": "20.25"}, nil); code != http.StatusCreated {
+		t.Fatalf("withdrawing: %d", code)
+	}
+	if got := alice.balance(w.ID); got != "80.25" {
+		t.Errorf("balance %s, want 80.25", got)
+	}
+
+	var history []struct {
+		Type         string `json:"type"`
+		Amount       string `json:"amount"`
+		BalanceAfter string `json:"balance_after"`
+	}
+	if code := alice.do("GET", "/api/v1/wallets/"+w.ID.String()+"/transactions", nil, &history); code != http.StatusOK {
+		t.Fatalf("listing transactions: %d", code)
+	}
+	if len(history) != 2 || history[0].Type != "withdraw" || history[0].Amount != "-20.25" || history[1].BalanceAfter != "100.5" {
+		t.Errorf("history %+v", history)
+	}
+
+	rejected := map[string]int{
+		"1000":   http.StatusUnprocessableEntity,
+		"-1":     http.StatusBadRequest,
+		"0.001":  http.StatusBadRequest,
+		"lots":   http.StatusBadRequest,
+		"0.0000": http.StatusBadRequest,
+	}
+	for amount, want := range rejected {
+		if code := alice.do("POST", "/api/v1/wallets/"+w.ID.String()+"/withdraw", map[string]string{"amount": amount}, nil); code != want {
+			t.Errorf("withdrawing %s: got %d, want %d", amount, code, want)
+		}
+	}
+	if code := alice.do("POST", "/api/v1/wallets", map[string]string{"currency": "XYZ"}, nil); code != http.StatusBadRequest {
+		t.Errorf("unsupported currency: got %d", code)
+	}
+}
+
+func TestUsersOnlyTouchTheirOwnWallets(t *testing.T) {
+	a := newTestAPI(t)
+	alice, bob := a.user(""), a.user("")
+	aw := alice.wallet("USD", "100")
+	bw := bob.wallet("USD", "")
+	id := aw.ID.String()
+
+	amount := map[string]string{"amount": "10"}
+	for _, r := range []struct{ method, path string }{
+		{"GET", "/api/v1/wallets/" + id},
+		{"POST", "/api/v1/wallets/" + id + "/deposit"},
+		{"POST", "/api/v1/wallets/" + id + "/withdraw"},
+		{"GET", "/api/v1/wallets/" + id + "/transactions"},
+		{"POST", "/api/v1/wallets/" + id + "/holds"},
+	} {
+		if code := bob.do(r.method, r.path, amount, nil); code != http.StatusNotFound {
+			t.Errorf("bob %s %s: got %d, want 404", r.method, r.path, code)
+		}
+	}
+
+	// Bob cannot open a wallet in Alice's name or spend from hers
+	if code := bob.do("POST", "/api/v1/wallets", map[string]string{"user_id": alice.ID.String(), "currency": "USD"}, nil); code != http.StatusForbidden {
+		t.Errorf("opening a wallet for someone else: got %d, want 403", code)
+	}
+	steal := map[string]string{"from_wallet_id": id, "to_wallet_id": bw.ID.String(), "amount": "10"}
+	if code := bob.do("POST", "/api/v1/transfers", steal, nil); code != http.StatusNotFound {
+		t.Errorf("transferring from someone else's wallet: got %d, want 404", code)
+	}
+
+	// Alice can pay Bob
+	pay := map[string]string{"from_wallet_id": id, "to_wallet_id": bw.ID.String(), "amount": "30"}
+	if code := alice.do("POST", "/api/v1/transfers", pay, nil); code != http.StatusCreated {
+		t.Fatalf("paying bob: %d", code)
+	}
+	if got := bob.balance(bw.ID); got != "30" {
+		t.Errorf("bob's balance %s, want 30", got)
+	}
+
+	// Holds are only captured or released by the wallet's owner
+	var hold struct {
+		ID     uuid.UUID `json:"id"`
+		Status string    `json:"status"`
+	}
+	if code := alice.do("POST", "/api/v1/wallets/"+id+"/holds", map[string]string{"amount": "25"}, &hold); code != http.StatusCreated {
+		t.Fatalf("placing hold: %d", code)
+	}
+	for _, action := range []string{"capture", "release"} {
+		if code := bob.do("POST", "/api/v1/holds/"+hold.ID.String()+"/"+action, nil, nil); code != http.StatusNotFound {
+			t.Errorf("bob %s: got %d, want 404", action, code)
+		}
+	}
+	if code := alice.do("POST", "/api/v1/holds/"+hold.ID.String()+"/capture", nil, &hold); code != http.StatusOK || hold.Status != "captured" {
+		t.Errorf("capturing: %d, %+v", code, hold)
+	}
+	if got := alice.balance(aw.ID); got != "45" {
+		t.Errorf("alice's balance %s, want 45", got)
+	}
+}
+
+func TestFXTransfersUseServerQuotes(t *testing.T) {
+	a := newTestAPI(t)
+	alice, bob := a.user(""), a.user("")
+	usd := alice.wallet("USD", "100")
+	eur := bob.wallet("EUR", "")
+
+	var quote struct {
+		ID   string `json:"id"`
+		Rate string `json:"rate"`
+	}
+	pair := map[string]string{"from_currency": "USD", "to_currency": "EUR"}
+	if code := alice.do("POST", "/api/v1/fx/quotes", pair, &quote); code != http.StatusCreated || quote.Rate != "0.9" {
+		t.Fatalf("quoting: %d %+v", code, quote)
+	}
+	if code := alice.do("POST", "/api/v1/fx/quotes", map[string]string{"from_currency": "USD", "to_currency": "JPY"}, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("quoting a pair without a rate: got %d, want 422", code)
+	}
+
+	// A rate in the body is ignored; the quote's is used
+	pay := map[string]interface{}{
+		"from_wallet_id": usd.ID.String(), "to_wallet_id": eur.ID.String(), "amount": "10",
+		"fx_quote": map[string]string{"id": quote.ID, "rate": "1000", "expires_at": "2999-01-01T00:00:00Z"},
+	}
+	if code := alice.do("POST", "/api/v1/transfers", pay, nil); code != http.StatusCreated {
+		t.Fatalf("FX transfer: %d", code)
+	}
+	if got := bob.balance(eur.ID); got != "9" {
+		t.Errorf("bob's balance %s, want 9", got)
+	}
+	if code := alice.do("POST", "/api/v1/transfers", pay, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("reusing the quote: got %d, want 422", code)
+	}
+
+	pay["fx_quote"] = map[string]string{"id": uuid.NewString()}
+	if code := alice.do("POST", "/api/v1/transfers", pay, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("made-up quote: got %d, want 422", code)
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	a := newTestAPI(t)
+	alice, admin := a.user(""), a.user(middleware.RoleAdmin)
+	w := alice.wallet("USD", "")
+
+	limits := map[string]string{"daily_limit": "1000"}
+	endpoint := map[string]string{"url": "https://example.com/hooks"}
+	for _, r := range []struct {
+		method, path string
+		body         interface{}
+		want         int
+	}{
+		{"PUT", "/api/v1/wallets/" + w.ID.String() + "/limits", limits, http.StatusOK},
+		{"GET", "/api/v1/risk/decisions", nil, http.StatusOK},
+		{"POST", "/api/v1/webhooks", endpoint, http.StatusCreated},
+		{"GET", "/api/v1/webhooks", nil, http.StatusOK},
+		{"GET", "/api/v1/webhooks/deliveries?status=dead", nil, http.StatusOK},
+	} {
+		if code := alice.do(r.method, r.path, r.body, nil); code != http.StatusForbidden {
+			t.Errorf("alice %s %s: got %d, want 403", r.method, r.path, code)
+		}
+		if code := admin.do(r.method, r.path, r.body, nil); code != r.want {
+			t.Errorf("admin %s %s: got %d, want %d", r.method, r.path, code, r.want)
+		}
+	}
+}
@@ -9,8 +9,9 @@ import (
 	"syscall"
 	"time"
 	"wallet-app/internal/config"
-	"wallet-app/internal/handlers"
-	"wallet-app/internal/middleware"
+	"wallet-app/internal/domain/repository"
+	"wallet-app/internal/services"
+	"wallet-app/migrations"
 	"wallet-app/pkg/database"
 
 	"github.com/gin-gonic/gin"
@@ -25,6 +26,7 @@ func main() {
 
 	// Initialize database
 	dbConfig := database.PostgresConfig{
+		URL:      cfg.DatabaseURL,
 		Host:     cfg.DBHost,
 		Port:     cfg.DBPort,
 		User:     cfg.DBUser,
@@ -39,28 +41,34 @@ func main() {
 	}
 	defer database.CloseDB(db)
 
+	applied, err := database.Migrate(db, migrations.Postgres())
+	if err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+	if len(applied) > 0 {
+		log.Printf("Applied migrations %v", applied)
+	}
+
+	store := repository.NewStore(db)
+
+	// Verify cached balances against the journal every night
+	checkerCtx, stopChecker := context.WithCancel(context.Background())
+	defer stopChecker()
+	go services.NewConsistencyChecker(store.Ledger, nil).Run(checkerCtx)
+	go services.NewHoldExpirer(store.Holds, nil).Run(checkerCtx)
+	// Deliver outbox events to webhook endpoints
+	go services.NewWebhookDispatcher(store, nil).Run(checkerCtx)
+
 	// Set Gin mode
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// Initialize router with middleware
-	router := gin.Default()
-	router.Use(gin.Recovery())
-	router.Use(middleware.Auth())
-
-	// Initialize handlers
-	walletHandler := handlers.NewWalletHandler(db)
-
-	// Define routes
-	api := router.Group("/api/v1")
-	{
-		api.POST("/wallets", walletHandler.Create)
-		api.GET("/wallets/:id", walletHandler.Get)
-		api.POST("/wallets/:id/deposit", walletHandler.Deposit)
-		api.POST("/wallets/:id/withdraw", walletHandler.Withdraw)
-		api.GET("/wallets/:id/transactions", walletHandler.GetTransactions)
+	rates, err := services.ParseRates(cfg.FXRates)
+	if err != nil {
+		log.Fatal("Invalid FX_RATES:", err)
 	}
+	router := newRouter(cfg, db, rates)
 
 	// Server configuration
 	srv := &http.Server{
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"wallet-app/internal/config"
+	"wallet-app/internal/domain/repository"
+	"wallet-app/internal/handlers"
+	"wallet-app/internal/middleware"
+	"wallet-app/internal/risk"
+	"wallet-app/internal/services"
+	"wallet-app/pkg/database"
+	"wallet-app/pkg/money"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// newRouter assembles the services and handlers on db and routes the API.
+// FX quotes are issued at rates.
+func newRouter(cfg *config.Config, db *gorm.DB, rates services.RateSource) *gin.Engine {
+	store := repository.NewStore(db)
+
+	walletService := services.NewWalletService(store)
+	walletService.Limits = services.Limits{DailyTransactions: cfg.MaxTransactions}
+	walletService.Rates = rates
+	walletService.Risk = risk.NewEngine(
+		risk.Velocity{Max: 5, Window: 10 * time.Minute},
+		risk.AmountSpike{Factor: money.MustParseRate("5"), Lookback: 30 * 24 * time.Hour, MinHistory: 5},
+	)
+	walletHandler := handlers.NewWalletHandler(walletService)
+	transferHandler := handlers.NewTransferHandler(walletService)
+	holdHandler := handlers.NewHoldHandler(walletService)
+	riskHandler := handlers.NewRiskHandler(walletService)
+	webhookHandler := handlers.NewWebhookHandler(services.NewWebhookService(store))
+
+	router := gin.Default()
+	router.GET("/health", func(c *gin.Context) {
+		if err := database.Health(db); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	// Users may only touch their own wallets and holds; the handlers check
+	// ownership against the token's user_id
+	api := router.Group("/api/v1", middleware.Auth(cfg.JWTSecret))
+	{
+		api.POST("/wallets", walletHandler.Create)
+		api.GET("/wallets/:id", walletHandler.Get)
+		api.POST("/wallets/:id/deposit", walletHandler.Deposit)
+		api.POST("/wallets/:id/withdraw", walletHandler.Withdraw)
+		api.GET("/wallets/:id/transactions", walletHandler.GetTransactions)
+		api.POST("/wallets/:id/holds", holdHandler.Create)
+		api.POST("/holds/:id/capture", holdHandler.Capture)
+		api.POST("/holds/:id/release", holdHandler.Release)
+		api.POST("/fx/quotes", transferHandler.Quote)
+		api.POST("/transfers", transferHandler.Create)
+	}
+
+	// Limits, the risk queue and webhooks span every user's wallets
+	admin := api.Group("", middleware.RequireRole(middleware.RoleAdmin))
+	{
+		admin.PUT("/wallets/:id/limits", riskHandler.SetLimits)
+		admin.GET("/risk/decisions", riskHandler.Decisions)
+		admin.POST("/webhooks", webhookHandler.Register)
+		admin.GET("/webhooks", webhookHandler.List)
+		admin.DELETE("/webhooks/:id", webhookHandler.Remove)
+		admin.GET("/webhooks/deliveries", webhookHandler.Deliveries)
+		admin.POST("/webhooks/deliveries/:id/redeliver", webhookHandler.Redeliver)
+	}
+
+	return router
+}
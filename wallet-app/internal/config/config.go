@@ -1,46 +1,73 @@
 package config
 
 import (
-	"os"
+	"errors"
+	"io/fs"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
+	// DatabaseURL, when set, takes precedence over the DB_* settings.
 	DatabaseURL     string `mapstructure:"DATABASE_URL"`
+	DBHost          string `mapstructure:"DB_HOST"`
+	DBPort          string `mapstructure:"DB_PORT"`
+	DBUser          string `mapstructure:"DB_USER"`
+	DBPassword      string `mapstructure:"DB_PASSWORD"`
+	DBName          string `mapstructure:"DB_NAME"`
+	DBSSLMode       string `mapstructure:"DB_SSLMODE"`
 	Port            string `mapstructure:"PORT"`
 	JWTSecret       string `mapstructure:"JWT_SECRET"`
 	Environment     string `mapstructure:"ENVIRONMENT"`
 	RedisURL        string `mapstructure:"REDIS_URL"`
 	MaxTransactions int    `mapstructure:"MAX_TRANSACTIONS"`
+	// FXRates are the rates FX quotes are issued at, e.g.
+	// "USD/EUR=0.92,EUR/USD=1.087".
+	FXRates string `mapstructure:"FX_RATES"`
 }
 
-func Load() (*Config, error) {
-	viper.SetConfigFile(".env")
-	viper.AutomaticEnv()
+var ErrMissingJWTSecret = errors.New("JWT_SECRET must be set")
+
+// defaults also tells viper which keys exist: AutomaticEnv only feeds
+// Unmarshal the keys viper already knows about.
+var defaults = map[string]interface{}{
+	"DATABASE_URL":     "",
+	"DB_HOST":          "localhost",
+	"DB_PORT":          "5432",
+	"DB_USER":          "postgres",
+	"DB_PASSWORD":      "",
+	"DB_NAME":          "wallet",
+	"DB_SSLMODE":       "disable",
+	"PORT":             "8080",
+	"JWT_SECRET":       "",
+	"ENVIRONMENT":      "development",
+	"REDIS_URL":        "",
+	"MAX_TRANSACTIONS": 100,
+	"FX_RATES":         "",
+}
 
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, err
-		}
+// Load reads the configuration from the environment, falling back to a
+// .env file in the working directory and then to the defaults.
+func Load() (*Config, error) {
+	v := viper.New()
+	v.SetConfigFile(".env")
+	v.SetConfigType("env")
+	v.AutomaticEnv()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
 	}
 
-	config := &Config{
-		Port:            getEnvOrDefault("PORT", "8080"),
-		Environment:     getEnvOrDefault("ENVIRONMENT", "development"),
-		MaxTransactions: 100,
+	if err := v.ReadInConfig(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
 	}
 
-	if err := viper.Unmarshal(config); err != nil {
+	config := &Config{}
+	if err := v.Unmarshal(config); err != nil {
 		return nil, err
 	}
+	if config.JWTSecret == "" {
+		return nil, ErrMissingJWTSecret
+	}
 
 	return config, nil
 }
-
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// chdir moves into dir for the rest of the test, since Load reads .env
+// from the working directory.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestLoad(t *testing.T) {
+	chdir(t, t.TempDir())
+	t.Setenv("JWT_SECRET", "s3cret")
+	t.Setenv("DB_HOST", "db.internal")
+	t.Setenv("MAX_TRANSACTIONS", "7")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.JWTSecret != "s3cret" || cfg.DBHost != "db.internal" || cfg.MaxTransactions != 7 {
+		t.Errorf("environment not applied: %+v", cfg)
+	}
+	if cfg.Port != "8080" || cfg.DBPort != "5432" || cfg.Environment != "development" {
+		t.Errorf("defaults not applied: %+v", cfg)
+	}
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("JWT_SECRET=from-file\nPORT=9090\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PORT", "7070")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The environment wins over the file
+	if cfg.JWTSecret != "from-file" || cfg.Port != "7070" {
+		t.Errorf("got %+v", cfg)
+	}
+}
+
+func TestLoadRequiresJWTSecret(t *testing.T) {
+	chdir(t, t.TempDir())
+	t.Setenv("JWT_SECRET", "")
+	if _, err := Load(); !errors.Is(err, ErrMissingJWTSecret) {
+		t.Errorf("got %v, want ErrMissingJWTSecret", err)
+	}
+}
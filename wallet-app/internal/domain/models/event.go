@@ -0,0 +1,110 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventWalletCreated     = "wallet.created"
+	EventTransactionPosted = "transaction.posted"
+	EventTransferCompleted = "transfer.completed"
+)
+
+// EventTypes lists every event type that can be subscribed to.
+var EventTypes = []string{EventWalletCreated, EventTransactionPosted, EventTransferCompleted}
+
+// OutboxEvent is a domain event written in the same database transaction as
+// the change it describes, so an event exists exactly when the change was
+// committed. The dispatcher fans it out to webhook endpoints.
+type OutboxEvent struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	Type        string    `json:"type" gorm:"type:varchar(64);not null"`
+	AggregateID uuid.UUID `json:"aggregate_id" gorm:"type:uuid;not null;index"`
+	// Payload is the event's data as JSON.
+	Payload   []byte    `json:"data" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	// DispatchedAt is set once deliveries were created for every endpoint.
+	DispatchedAt *time.Time `json:"dispatched_at,omitempty" gorm:"index"`
+}
+
+// EventFilter is the set of event types an endpoint subscribes to; an
+// empty filter subscribes to all of them. It is stored comma-separated.
+type EventFilter []string
+
+func (f EventFilter) Matches(eventType string) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for _, t := range f {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (f EventFilter) Value() (driver.Value, error) {
+	return strings.Join(f, ","), nil
+}
+
+func (f *EventFilter) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return errors.New("models: cannot scan EventFilter")
+	}
+	*f = nil
+	if s != "" {
+		*f = strings.Split(s, ",")
+	}
+	return nil
+}
+
+// WebhookEndpoint is a URL that receives events, signed with its secret.
+type WebhookEndpoint struct {
+	ID     uuid.UUID   `json:"id" gorm:"type:uuid;primary_key"`
+	URL    string      `json:"url" gorm:"type:varchar(2048);not null"`
+	Secret string      `json:"-" gorm:"type:varchar(128);not null"`
+	Events EventFilter `json:"events" gorm:"type:text"`
+	// Active is cleared when the endpoint is removed; its past deliveries
+	// are kept.
+	Active    bool      `json:"active" gorm:"not null;default:true;index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead is a delivery that ran out of attempts. It stays dead
+	// until it is redelivered.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery is one event on its way to one endpoint.
+type WebhookDelivery struct {
+	ID         uuid.UUID      `json:"id" gorm:"type:uuid;primary_key"`
+	EventID    uuid.UUID      `json:"event_id" gorm:"type:uuid;not null;uniqueIndex:idx_deliveries_event_endpoint,priority:1"`
+	EndpointID uuid.UUID      `json:"endpoint_id" gorm:"type:uuid;not null;uniqueIndex:idx_deliveries_event_endpoint,priority:2"`
+	Status     DeliveryStatus `json:"status" gorm:"type:varchar(16);not null;index:idx_deliveries_due,priority:1"`
+	Attempts   int            `json:"attempts" gorm:"not null;default:0"`
+	// NextAttemptAt is when a pending delivery is next due.
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"not null;index:idx_deliveries_due,priority:2"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// FXQuote is an exchange rate the service offered a user for converting
+// FromCurrency into ToCurrency. One transfer of theirs may use it, before
+// it expires.
+type FXQuote struct {
+	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	UserID       uuid.UUID  `json:"-" gorm:"type:uuid;not null;index"`
+	FromCurrency string     `json:"from_currency" gorm:"type:varchar(3);not null"`
+	ToCurrency   string     `json:"to_currency" gorm:"type:varchar(3);not null"`
+	Rate         money.Rate `json:"rate" gorm:"type:numeric(20,8);not null"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	// UsedAt is set once a transfer has converted at the quote.
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
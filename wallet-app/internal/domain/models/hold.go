@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type HoldStatus string
+
+const (
+	HoldActive   HoldStatus = "active"
+	HoldCaptured HoldStatus = "captured"
+	HoldReleased HoldStatus = "released"
+	HoldExpired  HoldStatus = "expired"
+)
+
+// Hold reserves part of a wallet's balance for a payment that is authorised
+// but not yet settled. An active hold reduces the available balance until
+// it is captured, released or expires.
+type Hold struct {
+	ID       uuid.UUID    `json:"id" gorm:"type:uuid;primary_key"`
+	WalletID uuid.UUID    `json:"wallet_id" gorm:"type:uuid;not null;index:idx_holds_wallet_status,priority:1"`
+	Amount   money.Amount `json:"amount" gorm:"type:numeric(19,4);not null"`
+	Currency string       `json:"currency" gorm:"type:varchar(3);not null"`
+	Status   HoldStatus   `json:"status" gorm:"type:varchar(16);not null;index:idx_holds_wallet_status,priority:2"`
+	// CapturedAmount and TransactionID are set once the hold is captured;
+	// a capture may take less than was held.
+	CapturedAmount *money.Amount `json:"captured_amount,omitempty" gorm:"type:numeric(19,4)"`
+	TransactionID  *uuid.UUID    `json:"transaction_id,omitempty" gorm:"type:uuid"`
+	Description    string        `json:"description"`
+	ExpiresAt      time.Time     `json:"expires_at" gorm:"not null;index"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// ActiveAt reports whether the hold still reserves funds at t.
+func (h *Hold) ActiveAt(t time.Time) bool {
+	return h.Status == HoldActive && t.Before(h.ExpiresAt)
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the outcome of a request sent with an
+// Idempotency-Key header, so a retry gets the original response instead of
+// being executed again. Keys are scoped, e.g. per user.
+type IdempotencyKey struct {
+	Scope string `gorm:"type:varchar(64);primaryKey"`
+	Key   string `gorm:"type:varchar(255);primaryKey"`
+	// RequestHash detects a key being reused for a different request.
+	RequestHash string `gorm:"type:varchar(64);not null"`
+	StatusCode  int
+	Response    []byte
+	CreatedAt   time.Time
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrImmutable is returned when something tries to change or delete a
+// journal entry. Mistakes are corrected with a reversing entry instead.
+var ErrImmutable = errors.New("journal entries are immutable")
+
+type Direction string
+
+const (
+	Debit  Direction = "debit"
+	Credit Direction = "credit"
+)
+
+// Transaction is a journal entry: one movement of money, recorded as
+// postings whose debits and credits balance per currency.
+type Transaction struct {
+	ID          uuid.UUID       `json:"id" gorm:"type:uuid;primary_key"`
+	Type        TransactionType `json:"type" gorm:"type:varchar(32);not null"`
+	Description string          `json:"description"`
+	// ExchangeRate and QuoteID record the FX quote a cross-currency
+	// transfer was converted with.
+	ExchangeRate *money.Rate `json:"exchange_rate,omitempty" gorm:"type:numeric(20,8)"`
+	QuoteID      string      `json:"quote_id,omitempty" gorm:"type:varchar(64)"`
+	Postings     []Posting   `json:"postings" gorm:"foreignKey:TransactionID"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+// Posting is one side of a journal entry against a wallet. Amount is always
+// positive; a credit increases the wallet's balance and a debit decreases
+// it.
+type Posting struct {
+	ID            uuid.UUID    `json:"id" gorm:"type:uuid;primary_key"`
+	TransactionID uuid.UUID    `json:"transaction_id" gorm:"type:uuid;not null;index"`
+	WalletID      uuid.UUID    `json:"wallet_id" gorm:"type:uuid;not null;index:idx_postings_wallet_sequence,unique,priority:1"`
+	Direction     Direction    `json:"direction" gorm:"type:varchar(6);not null"`
+	Amount        money.Amount `json:"amount" gorm:"type:numeric(19,4);not null"`
+	Currency      string       `json:"currency" gorm:"type:varchar(3);not null"`
+	// Sequence is the wallet's Version after this posting.
+	Sequence     int64        `json:"sequence" gorm:"not null;index:idx_postings_wallet_sequence,unique,priority:2"`
+	BalanceAfter money.Amount `json:"balance_after" gorm:"type:numeric(19,4);not null"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+// Signed is the posting's effect on the wallet balance.
+func (p Posting) Signed() money.Amount {
+	if p.Direction == Debit {
+		return p.Amount.Neg()
+	}
+	return p.Amount
+}
+
+func (*Transaction) BeforeUpdate(*gorm.DB) error { return ErrImmutable }
+func (*Transaction) BeforeDelete(*gorm.DB) error { return ErrImmutable }
+func (*Posting) BeforeUpdate(*gorm.DB) error     { return ErrImmutable }
+func (*Posting) BeforeDelete(*gorm.DB) error     { return ErrImmutable }
+
+// WalletTransaction is one wallet's view of a journal entry.
+type WalletTransaction struct {
+	TransactionID uuid.UUID       `json:"transaction_id"`
+	WalletID      uuid.UUID       `json:"wallet_id"`
+	Type          TransactionType `json:"type"`
+	// Amount is signed: negative when money left the wallet.
+	Amount       money.Amount `json:"amount"`
+	BalanceAfter money.Amount `json:"balance_after"`
+	Description  string       `json:"description"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+// Outflow is money that left a wallet, or is held to leave it, in one
+// transaction or hold.
+type Outflow struct {
+	ID     uuid.UUID
+	Amount money.Amount
+	At     time.Time
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// RiskDecision records what the risk engine decided about one attempt to
+// move money out of a wallet, so that reviews and declines can be audited.
+type RiskDecision struct {
+	ID        uuid.UUID    `json:"id" gorm:"type:uuid;primary_key"`
+	WalletID  uuid.UUID    `json:"wallet_id" gorm:"type:uuid;not null;index"`
+	Operation string       `json:"operation" gorm:"type:varchar(16);not null"`
+	Amount    money.Amount `json:"amount" gorm:"type:numeric(19,4);not null"`
+	Currency  string       `json:"currency" gorm:"type:varchar(3);not null"`
+	Outcome   string       `json:"outcome" gorm:"type:varchar(16);not null;index"`
+	// Rule names the rule behind the outcome; empty when every rule
+	// allowed the attempt.
+	Rule      string    `json:"rule,omitempty" gorm:"type:varchar(64)"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
@@ -1,32 +1,47 @@
 package models
 
 import (
-    "time"
+	"time"
 
-    "github.com/google/uuid"
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
 )
 
 type TransactionType string
 
 const (
-    Deposit  TransactionType = "deposit"
-    Withdraw TransactionType = "withdraw"
+	Deposit  TransactionType = "deposit"
+	Withdraw TransactionType = "withdraw"
+	Transfer TransactionType = "transfer"
+	// Capture settles an authorisation hold.
+	Capture TransactionType = "capture"
+)
+
+type WalletKind string
+
+const (
+	UserWallet WalletKind = "user"
+	// SystemWallet is the ledger's counterparty for money entering or
+	// leaving the platform. There is one per currency and it may go
+	// negative.
+	SystemWallet WalletKind = "system"
 )
 
 type Wallet struct {
-    ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
-    UserID    uuid.UUID `json:"user_id" gorm:"type:uuid"`
-    Balance   float64   `json:"balance"`
-    Currency  string    `json:"currency"`
-    CreatedAt time.Time `json:"created_at"`
-    UpdatedAt time.Time `json:"updated_at"`
+	ID     uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	UserID uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	Kind   WalletKind `json:"-" gorm:"type:varchar(16);not null;default:user"`
+	// Balance caches the sum of the wallet's postings. The ledger updates
+	// it under a row lock in the same transaction as the postings.
+	Balance  money.Amount `json:"balance" gorm:"type:numeric(19,4);not null;default:0"`
+	Currency string       `json:"currency" gorm:"type:varchar(3);not null"`
+	// DailyLimit and MonthlyLimit cap the money leaving the wallet per UTC
+	// day and month. Nil falls back to the service-wide limits.
+	DailyLimit   *money.Amount `json:"daily_limit,omitempty" gorm:"type:numeric(19,4)"`
+	MonthlyLimit *money.Amount `json:"monthly_limit,omitempty" gorm:"type:numeric(19,4)"`
+	// Version counts the wallet's postings and orders its history.
+	Version   int64     `json:"-" gorm:"not null;default:0"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
-
-type Transaction struct {
-    ID          uuid.UUID       `json:"id" gorm:"type:uuid;primary_key"`
-    WalletID    uuid.UUID       `json:"wallet_id" gorm:"type:uuid"`
-    Type        TransactionType `json:"type"`
-    Amount      float64         `json:"amount"`
-    Description string         `json:"description"`
-    CreatedAt   time.Time      `json:"created_at"`
-}
\ No newline at end of file
@@ -0,0 +1,15 @@
+package repository
+
+import "errors"
+
+var (
+	ErrWalletNotFound    = errors.New("wallet not found")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrCurrencyMismatch  = errors.New("posting currency does not match the wallet")
+	ErrUnbalanced        = errors.New("journal entry debits and credits do not balance")
+	ErrInvalidPosting    = errors.New("invalid posting")
+	ErrHoldNotFound      = errors.New("hold not found")
+	ErrFXQuoteNotFound   = errors.New("FX quote not found")
+	ErrEndpointNotFound  = errors.New("webhook endpoint not found")
+	ErrDeliveryNotFound  = errors.New("webhook delivery not found")
+)
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"wallet-app/internal/domain/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FXQuoteRepository struct {
+	db *gorm.DB
+}
+
+func NewFXQuoteRepository(db *gorm.DB) *FXQuoteRepository {
+	return &FXQuoteRepository{db: db}
+}
+
+func (r *FXQuoteRepository) Create(ctx context.Context, quote *models.FXQuote) error {
+	return r.db.WithContext(ctx).Create(quote).Error
+}
+
+// Lock returns a quote locked for the rest of the database transaction, so
+// two transfers cannot both use it.
+func (r *FXQuoteRepository) Lock(ctx context.Context, id uuid.UUID) (*models.FXQuote, error) {
+	var quote models.FXQuote
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&quote, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFXQuoteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+func (r *FXQuoteRepository) Save(ctx context.Context, quote *models.FXQuote) error {
+	return r.db.WithContext(ctx).Save(quote).Error
+}
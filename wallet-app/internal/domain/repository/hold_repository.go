@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"wallet-app/internal/domain/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type HoldRepository struct {
+	db *gorm.DB
+}
+
+func NewHoldRepository(db *gorm.DB) *HoldRepository {
+	return &HoldRepository{db: db}
+}
+
+func (r *HoldRepository) Create(ctx context.Context, hold *models.Hold) error {
+	return r.db.WithContext(ctx).Create(hold).Error
+}
+
+func (r *HoldRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Hold, error) {
+	var hold models.Hold
+	err := r.db.WithContext(ctx).First(&hold, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// Lock returns a hold locked for the rest of the database transaction.
+func (r *HoldRepository) Lock(ctx context.Context, id uuid.UUID) (*models.Hold, error) {
+	var hold models.Hold
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&hold, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (r *HoldRepository) Save(ctx context.Context, hold *models.Hold) error {
+	return r.db.WithContext(ctx).Save(hold).Error
+}
+
+// Active returns a wallet's holds that still reserve funds at now.
+func (r *HoldRepository) Active(ctx context.Context, walletID uuid.UUID, now time.Time) ([]models.Hold, error) {
+	var holds []models.Hold
+	err := r.db.WithContext(ctx).
+		Where("wallet_id = ? AND status = ? AND expires_at > ?", walletID, models.HoldActive, now).
+		Order("created_at").
+		Find(&holds).Error
+	return holds, err
+}
+
+// ExpireDue marks active holds whose time has run out as expired and
+// returns how many there were.
+func (r *HoldRepository) ExpireDue(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&models.Hold{}).
+		Where("status = ? AND expires_at <= ?", models.HoldActive, now).
+		Updates(map[string]interface{}{"status": models.HoldExpired, "updated_at": now})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+
+	"wallet-app/internal/domain/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Reserve stores key unless its scope and key are taken, in which case the
+// stored record is returned. Used inside a transaction, a concurrent request
+// with the same key waits until the first one commits or rolls back.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var existing models.IdempotencyKey
+	err := r.db.WithContext(ctx).First(&existing, "scope = ? AND key = ?", key.Scope, key.Key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// Complete stores the response of a reserved key.
+func (r *IdempotencyRepository) Complete(ctx context.Context, scope, key string, status int, response []byte) error {
+	return r.db.WithContext(ctx).Model(&models.IdempotencyKey{}).
+		Where("scope = ? AND key = ?", scope, key).
+		Updates(map[string]interface{}{"status_code": status, "response": response}).Error
+}
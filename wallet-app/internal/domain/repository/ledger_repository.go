@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LedgerRepository records journal entries. It is the only code that changes
+// wallet balances.
+type LedgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+// Post records txn and updates the cached balance of every wallet it
+// touches, all in one database transaction. IDs, timestamps, sequences and
+// running balances are filled in on txn.
+func (r *LedgerRepository) Post(ctx context.Context, txn *models.Transaction) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return post(tx, txn)
+	})
+}
+
+func post(tx *gorm.DB, txn *models.Transaction) error {
+	if len(txn.Postings) < 2 {
+		return fmt.Errorf("%w: an entry needs at least two postings", ErrInvalidPosting)
+	}
+
+	ids := make([]uuid.UUID, len(txn.Postings))
+	for i, p := range txn.Postings {
+		ids[i] = p.WalletID
+	}
+	wallets, err := lockWallets(tx, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[uuid.UUID]*models.Wallet, len(wallets))
+	for i := range wallets {
+		byID[wallets[i].ID] = &wallets[i]
+	}
+
+	now := time.Now().UTC()
+	if txn.ID == uuid.Nil {
+		txn.ID = uuid.New()
+	}
+	txn.CreatedAt = now
+
+	sums := make(map[string]money.Amount)
+	for i := range txn.Postings {
+		p := &txn.Postings[i]
+		w := byID[p.WalletID]
+		if p.Amount.Sign() <= 0 || (p.Direction != models.Debit && p.Direction != models.Credit) {
+			return fmt.Errorf("%w: %s of %s", ErrInvalidPosting, p.Direction, p.Amount)
+		}
+		if p.Currency == "" {
+			p.Currency = w.Currency
+		}
+		if p.Currency != w.Currency {
+			return ErrCurrencyMismatch
+		}
+		if err := money.Check(p.Amount, p.Currency); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPosting, err)
+		}
+
+		w.Balance = w.Balance.Add(p.Signed())
+		w.Version++
+		if !w.Balance.InRange() {
+			return fmt.Errorf("%w: balance of wallet %s out of range", ErrInvalidPosting, w.ID)
+		}
+		if w.Kind != models.SystemWallet && w.Balance.Sign() < 0 {
+			return ErrInsufficientFunds
+		}
+
+		p.ID = uuid.New()
+		p.TransactionID = txn.ID
+		p.Sequence = w.Version
+		p.BalanceAfter = w.Balance
+		p.CreatedAt = now
+		sums[p.Currency] = sums[p.Currency].Add(p.Signed())
+	}
+	for currency, sum := range sums {
+		if !sum.IsZero() {
+			return fmt.Errorf("%w: %s is off by %s", ErrUnbalanced, currency, sum)
+		}
+	}
+
+	if err := tx.Omit("Postings").Create(txn).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(&txn.Postings).Error; err != nil {
+		return err
+	}
+	for _, w := range wallets {
+		err := tx.Model(&models.Wallet{}).Where("id = ?", w.ID).
+			Updates(map[string]interface{}{"balance": w.Balance, "version": w.Version, "updated_at": now}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListByWallet returns a wallet's side of its journal entries, newest
+// first.
+func (r *LedgerRepository) ListByWallet(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]models.WalletTransaction, error) {
+	var rows []struct {
+		models.Posting
+		Type        models.TransactionType
+		Description string
+	}
+	err := r.db.WithContext(ctx).Model(&models.Posting{}).
+		Select("postings.*, transactions.type, transactions.description").
+		Joins("JOIN transactions ON transactions.id = postings.transaction_id").
+		Where("postings.wallet_id = ?", walletID).
+		Order("postings.sequence DESC").
+		Limit(limit).Offset(offset).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]models.WalletTransaction, len(rows))
+	for i, row := range rows {
+		history[i] = models.WalletTransaction{
+			TransactionID: row.TransactionID,
+			WalletID:      row.WalletID,
+			Type:          row.Type,
+			Amount:        row.Signed(),
+			BalanceAfter:  row.BalanceAfter,
+			Description:   row.Description,
+			CreatedAt:     row.CreatedAt,
+		}
+	}
+	return history, nil
+}
+
+// Outflows returns the money that left a wallet since a point in time, one
+// entry per journal entry, oldest first.
+func (r *LedgerRepository) Outflows(ctx context.Context, walletID uuid.UUID, since time.Time) ([]models.Outflow, error) {
+	var rows []struct {
+		TransactionID uuid.UUID
+		CreatedAt     time.Time
+		Amount        money.Amount
+	}
+	err := r.db.WithContext(ctx).Model(&models.Posting{}).
+		Select("transaction_id, created_at, SUM(amount) AS amount").
+		Where("wallet_id = ? AND direction = ? AND created_at >= ?", walletID, models.Debit, since).
+		Group("transaction_id, created_at").
+		Order("created_at").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	outflows := make([]models.Outflow, len(rows))
+	for i, row := range rows {
+		outflows[i] = models.Outflow{ID: row.TransactionID, Amount: row.Amount, At: row.CreatedAt}
+	}
+	return outflows, nil
+}
+
+// BalanceMismatch is a wallet whose cached balance disagrees with its
+// postings.
+type BalanceMismatch struct {
+	WalletID uuid.UUID
+	Cached   money.Amount
+	Journal  money.Amount
+}
+
+// UnbalancedEntry is a journal entry whose postings do not sum to zero.
+type UnbalancedEntry struct {
+	TransactionID uuid.UUID
+	Currency      string
+	Sum           money.Amount
+}
+
+// ConsistencyReport lists everything CheckConsistency found wrong.
+type ConsistencyReport struct {
+	CheckedAt  time.Time
+	Wallets    int
+	Mismatches []BalanceMismatch
+	Unbalanced []UnbalancedEntry
+}
+
+// OK reports whether the ledger is consistent.
+func (r ConsistencyReport) OK() bool {
+	return len(r.Mismatches) == 0 && len(r.Unbalanced) == 0
+}
+
+// signedAmount sums postings the way Posting.Signed does.
+const signedAmount = "COALESCE(SUM(CASE WHEN postings.direction = 'debit' THEN -postings.amount ELSE postings.amount END), 0)"
+
+// CheckConsistency recomputes every wallet balance from the journal and
+// checks that every entry balances. It only reads, so it can run while
+// the ledger is in use.
+func (r *LedgerRepository) CheckConsistency(ctx context.Context) (ConsistencyReport, error) {
+	report := ConsistencyReport{CheckedAt: time.Now().UTC()}
+	db := r.db.WithContext(ctx)
+
+	var balances []struct {
+		ID      uuid.UUID
+		Balance money.Amount
+		Journal money.Amount
+	}
+	err := db.Model(&models.Wallet{}).
+		Select("wallets.id, wallets.balance, " + signedAmount + " AS journal").
+		Joins("LEFT JOIN postings ON postings.wallet_id = wallets.id").
+		Group("wallets.id, wallets.balance").
+		Scan(&balances).Error
+	if err != nil {
+		return report, err
+	}
+	report.Wallets = len(balances)
+	for _, b := range balances {
+		if b.Balance.Cmp(b.Journal) != 0 {
+			report.Mismatches = append(report.Mismatches, BalanceMismatch{WalletID: b.ID, Cached: b.Balance, Journal: b.Journal})
+		}
+	}
+
+	var entries []struct {
+		TransactionID uuid.UUID
+		Currency      string
+		Sum           money.Amount
+	}
+	err = db.Model(&models.Posting{}).
+		Select("postings.transaction_id, postings.currency, " + signedAmount + " AS sum").
+		Group("postings.transaction_id, postings.currency").
+		Scan(&entries).Error
+	if err != nil {
+		return report, err
+	}
+	for _, e := range entries {
+		if !e.Sum.IsZero() {
+			report.Unbalanced = append(report.Unbalanced, UnbalancedEntry(e))
+		}
+	}
+	return report, nil
+}
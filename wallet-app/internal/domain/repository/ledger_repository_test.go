@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/quick"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+uuid.NewString()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Wallet{}, &models.Transaction{}, &models.Posting{}); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func newWallet(t *testing.T, db *gorm.DB, currency string) *models.Wallet {
+	t.Helper()
+	w := &models.Wallet{ID: uuid.New(), UserID: uuid.New(), Kind: models.UserWallet, Currency: currency}
+	if err := NewWalletRepository(db).Create(context.Background(), w); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func move(from, to uuid.UUID, amount string) *models.Transaction {
+	return &models.Transaction{
+		Type: models.Deposit,
+		Postings: []models.Posting{
+			{WalletID: from, Direction: models.Debit, Amount: money.MustParse(amount)},
+			{WalletID: to, Direction: models.Credit, Amount: money.MustParse(amount)},
+		},
+	}
+}
+
+func balance(t *testing.T, db *gorm.DB, id uuid.UUID) string {
+	t.Helper()
+	w, err := NewWalletRepository(db).GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Balance.String()
+}
+
+func TestPostUpdatesBalancesAndHistory(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	ledger := NewLedgerRepository(db)
+	system, err := NewWalletRepository(db).SystemWallet(ctx, "usd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet := newWallet(t, db, "USD")
+
+	if err := ledger.Post(ctx, move(system.ID, wallet.ID, "100")); err != nil {
+		t.Fatal(err)
+	}
+	withdrawal := move(wallet.ID, system.ID, "30")
+	withdrawal.Type = models.Withdraw
+	if err := ledger.Post(ctx, withdrawal); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := balance(t, db, wallet.ID); got != "70" {
+		t.Errorf("wallet balance = %v, want 70", got)
+	}
+	// The system wallet mirrors the money that entered the platform
+	if got := balance(t, db, system.ID); got != "-70" {
+		t.Errorf("system balance = %v, want -70", got)
+	}
+
+	history, err := ledger.ListByWallet(ctx, wallet.ID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("history has %d entries, want 2", len(history))
+	}
+	if h := history[0]; h.Type != models.Withdraw || h.Amount.String() != "-30" || h.BalanceAfter.String() != "70" || h.TransactionID != withdrawal.ID {
+		t.Errorf("newest entry = %+v", h)
+	}
+	if h := history[1]; h.Type != models.Deposit || h.Amount.String() != "100" || h.BalanceAfter.String() != "100" {
+		t.Errorf("oldest entry = %+v", h)
+	}
+
+	page, err := ledger.ListByWallet(ctx, wallet.ID, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].Amount.String() != "100" {
+		t.Errorf("second page = %+v", page)
+	}
+}
+
+func TestPostRejectsInvalidEntries(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	ledger := NewLedgerRepository(db)
+	system, err := NewWalletRepository(db).SystemWallet(ctx, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet := newWallet(t, db, "USD")
+	euros := newWallet(t, db, "EUR")
+	if err := ledger.Post(ctx, move(system.ID, wallet.ID, "50")); err != nil {
+		t.Fatal(err)
+	}
+
+	unbalanced := move(system.ID, wallet.ID, "10")
+	unbalanced.Postings[1].Amount = money.MustParse("11")
+	negative := move(system.ID, wallet.ID, "10")
+	negative.Postings[0].Amount, negative.Postings[1].Amount = money.MustParse("-10"), money.MustParse("-10")
+
+	tests := []struct {
+		name string
+		txn  *models.Transaction
+		want error
+	}{
+		{"overdraft", move(wallet.ID, system.ID, "50.01"), ErrInsufficientFunds},
+		{"unbalanced", unbalanced, ErrUnbalanced},
+		{"cross currency", move(wallet.ID, euros.ID, "10"), ErrUnbalanced},
+		{"wrong currency", &models.Transaction{Type: models.Deposit, Postings: []models.Posting{
+			{WalletID: system.ID, Direction: models.Debit, Amount: money.MustParse("10"), Currency: "EUR"},
+			{WalletID: wallet.ID, Direction: models.Credit, Amount: money.MustParse("10"), Currency: "EUR"},
+		}}, ErrCurrencyMismatch},
+		{"single posting", &models.Transaction{Type: models.Deposit, Postings: move(system.ID, wallet.ID, "10").Postings[:1]}, ErrInvalidPosting},
+		{"negative amount", negative, ErrInvalidPosting},
+		{"fraction of a cent", move(system.ID, wallet.ID, "0.005"), ErrInvalidPosting},
+		{"unknown wallet", move(uuid.New(), wallet.ID, "10"), ErrWalletNotFound},
+	}
+	for _, tt := range tests {
+		if err := ledger.Post(ctx, tt.txn); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// Nothing from the rejected entries was written
+	if got := balance(t, db, wallet.ID); got != "50" {
+		t.Errorf("wallet balance = %v, want 50", got)
+	}
+	var count int64
+	db.Model(&models.Transaction{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d transactions stored, want 1", count)
+	}
+}
+
+func TestJournalIsImmutable(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	system, err := NewWalletRepository(db).SystemWallet(ctx, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet := newWallet(t, db, "USD")
+	txn := move(system.ID, wallet.ID, "10")
+	if err := NewLedgerRepository(db).Post(ctx, txn); err != nil {
+		t.Fatal(err)
+	}
+
+	txn.Description = "edited"
+	if err := db.Save(txn).Error; !errors.Is(err, models.ErrImmutable) {
+		t.Errorf("updating a transaction: got %v, want ErrImmutable", err)
+	}
+	if err := db.Delete(&txn.Postings[0]).Error; !errors.Is(err, models.ErrImmutable) {
+		t.Errorf("deleting a posting: got %v, want ErrImmutable", err)
+	}
+}
+
+func TestCheckConsistency(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	ledger := NewLedgerRepository(db)
+	system, err := NewWalletRepository(db).SystemWallet(ctx, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet := newWallet(t, db, "USD")
+	empty := newWallet(t, db, "USD")
+	for _, amount := range []string{"10.10", "20.20", "0.30"} {
+		if err := ledger.Post(ctx, move(system.ID, wallet.ID, amount)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := ledger.CheckConsistency(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.Wallets != 3 {
+		t.Fatalf("report = %+v, want a consistent ledger of 3 wallets", report)
+	}
+
+	// Someone edits a cached balance behind the ledger's back
+	db.Model(&models.Wallet{}).Where("id = ?", empty.ID).Update("balance", 5)
+	report, err = ledger.CheckConsistency(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Mismatches) != 1 || report.Mismatches[0].WalletID != empty.ID || !report.Mismatches[0].Journal.IsZero() {
+		t.Errorf("mismatches = %+v", report.Mismatches)
+	}
+
+	// and slips a posting into the journal without its other side
+	db.Exec("INSERT INTO postings (id, transaction_id, wallet_id, direction, amount, currency, sequence, balance_after, created_at) VALUES (?, ?, ?, 'credit', 1, 'USD', 99, 0, CURRENT_TIMESTAMP)",
+		uuid.New(), uuid.New(), wallet.ID)
+	report, err = ledger.CheckConsistency(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Unbalanced) != 1 || len(report.Mismatches) != 2 {
+		t.Errorf("report = %+v, want one unbalanced entry and two mismatches", report)
+	}
+}
+
+// TestLedgerNeverDrifts posts random entries of random cent amounts and
+// checks that the books still balance to the cent, which float64 balances
+// did not.
+func TestLedgerNeverDrifts(t *testing.T) {
+	ctx := context.Background()
+	property := func(cents []uint16, picks []uint8) bool {
+		db := newTestDB(t)
+		ledger := NewLedgerRepository(db)
+		system, err := NewWalletRepository(db).SystemWallet(ctx, "USD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		wallets := []uuid.UUID{newWallet(t, db, "USD").ID, newWallet(t, db, "USD").ID, newWallet(t, db, "USD").ID}
+
+		var deposited int64
+		for i, c := range cents {
+			amount := money.New(int64(c)+1, 2)
+			to := wallets[i%len(wallets)]
+			if i < len(picks) && picks[i]%2 == 0 {
+				// Move part of a deposit on to another wallet
+				if err := ledger.Post(ctx, move(system.ID, to, amount.String())); err != nil {
+					t.Fatal(err)
+				}
+				deposited += int64(c) + 1
+				next := wallets[(i+1)%len(wallets)]
+				if err := ledger.Post(ctx, move(to, next, amount.String())); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			if err := ledger.Post(ctx, move(system.ID, to, amount.String())); err != nil {
+				t.Fatal(err)
+			}
+			deposited += int64(c) + 1
+		}
+
+		var total money.Amount
+		for _, id := range wallets {
+			w, err := NewWalletRepository(db).GetByID(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			total = total.Add(w.Balance)
+		}
+		report, err := ledger.CheckConsistency(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sys, err := NewWalletRepository(db).GetByID(ctx, system.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report.OK() && total == money.New(deposited, 2) && total.Add(sys.Balance).IsZero()
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 25}); err != nil {
+		t.Error(err)
+	}
+}
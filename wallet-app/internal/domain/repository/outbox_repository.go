@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"wallet-app/internal/domain/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// Append writes an event. Use it on a Store bound to the transaction that
+// makes the change the event describes.
+func (r *OutboxRepository) Append(ctx context.Context, event *models.OutboxEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+// Undispatched locks up to limit events that have not been fanned out yet,
+// oldest first. Rows locked by another dispatcher are skipped.
+func (r *OutboxRepository) Undispatched(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("dispatched_at IS NULL").
+		Order("created_at").Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+func (r *OutboxRepository) MarkDispatched(ctx context.Context, ids []uuid.UUID, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Model(&models.OutboxEvent{}).
+		Where("id IN ?", ids).Update("dispatched_at", at).Error
+}
+
+func (r *OutboxRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.OutboxEvent, error) {
+	var event models.OutboxEvent
+	if err := r.db.WithContext(ctx).First(&event, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+
+	"wallet-app/internal/domain/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RiskRepository struct {
+	db *gorm.DB
+}
+
+func NewRiskRepository(db *gorm.DB) *RiskRepository {
+	return &RiskRepository{db: db}
+}
+
+func (r *RiskRepository) Record(ctx context.Context, decision *models.RiskDecision) error {
+	if decision.ID == uuid.Nil {
+		decision.ID = uuid.New()
+	}
+	return r.db.WithContext(ctx).Create(decision).Error
+}
+
+// List returns a page of decisions, newest first. With outcomes it only
+// returns decisions with one of them, e.g. those awaiting review.
+func (r *RiskRepository) List(ctx context.Context, outcomes []string, limit, offset int) ([]models.RiskDecision, error) {
+	query := r.db.WithContext(ctx).Order("created_at DESC").Limit(limit).Offset(offset)
+	if len(outcomes) > 0 {
+		query = query.Where("outcome IN ?", outcomes)
+	}
+	var decisions []models.RiskDecision
+	err := query.Find(&decisions).Error
+	return decisions, err
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Store bundles the repositories of one database connection, so that
+// several of them can be used in a single database transaction.
+type Store struct {
+	db          *gorm.DB
+	Wallets     *WalletRepository
+	Ledger      *LedgerRepository
+	Idempotency *IdempotencyRepository
+	Holds       *HoldRepository
+	FXQuotes    *FXQuoteRepository
+	Risk        *RiskRepository
+	Outbox      *OutboxRepository
+	Webhooks    *WebhookRepository
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{
+		db:          db,
+		Wallets:     NewWalletRepository(db),
+		Ledger:      NewLedgerRepository(db),
+		Idempotency: NewIdempotencyRepository(db),
+		Holds:       NewHoldRepository(db),
+		FXQuotes:    NewFXQuoteRepository(db),
+		Risk:        NewRiskRepository(db),
+		Outbox:      NewOutboxRepository(db),
+		Webhooks:    NewWebhookRepository(db),
+	}
+}
+
+// Transaction runs fn with a Store bound to one database transaction. It
+// commits when fn returns nil and rolls back otherwise.
+func (s *Store) Transaction(ctx context.Context, fn func(tx *Store) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewStore(tx))
+	})
+}
@@ -1,45 +1,104 @@
 package repository
 
 import (
-    "context"
-    "wallet-app/internal/domain/models"
+	"context"
+	"errors"
+	"sort"
+	"strings"
 
-    "github.com/google/uuid"
-    "gorm.io/gorm"
+	"wallet-app/internal/domain/models"
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// systemNamespace derives the fixed ID of each currency's system wallet.
+var systemNamespace = uuid.MustParse("5c1d2f3e-8a4b-4c6d-9e7f-0a1b2c3d4e5f")
+
 type WalletRepository struct {
-    db *gorm.DB
+	db *gorm.DB
 }
 
 func NewWalletRepository(db *gorm.DB) *WalletRepository {
-    return &WalletRepository{db: db}
+	return &WalletRepository{db: db}
 }
 
 func (r *WalletRepository) Create(ctx context.Context, wallet *models.Wallet) error {
-    return r.db.WithContext(ctx).Create(wallet).Error
+	return r.db.WithContext(ctx).Create(wallet).Error
 }
 
 func (r *WalletRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Wallet, error) {
-    var wallet models.Wallet
-    if err := r.db.WithContext(ctx).First(&wallet, "id = ?", id).Error; err != nil {
-        return nil, err
-    }
-    return &wallet, nil
-}
-
-func (r *WalletRepository) UpdateBalance(ctx context.Context, id uuid.UUID, amount float64) error {
-    return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-        var wallet models.Wallet
-        if err := tx.WithContext(ctx).First(&wallet, "id = ?", id).Error; err != nil {
-            return err
-        }
-
-        wallet.Balance += amount
-        if wallet.Balance < 0 {
-            return ErrInsufficientFunds
-        }
-
-        return tx.Save(&wallet).Error
-    })
-}
\ No newline at end of file
+	var wallet models.Wallet
+	if err := r.db.WithContext(ctx).First(&wallet, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWalletNotFound
+		}
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// SystemWallet returns the system wallet of currency, creating it on first
+// use. Its ID is derived from the currency, so concurrent callers agree on
+// one wallet.
+func (r *WalletRepository) SystemWallet(ctx context.Context, currency string) (*models.Wallet, error) {
+	currency = strings.ToUpper(currency)
+	wallet := models.Wallet{
+		ID:       uuid.NewSHA1(systemNamespace, []byte(currency)),
+		Kind:     models.SystemWallet,
+		Currency: currency,
+	}
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&wallet).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(ctx, wallet.ID)
+}
+
+// Lock locks the given wallets for the rest of the database transaction and
+// returns them in ID order. Callers that check balances or limits before
+// posting lock first, so that nothing changes in between.
+func (r *WalletRepository) Lock(ctx context.Context, ids ...uuid.UUID) ([]models.Wallet, error) {
+	return lockWallets(r.db.WithContext(ctx), ids)
+}
+
+// lockWallets locks wallets in ID order so that concurrent transactions
+// touching the same wallets cannot deadlock.
+func lockWallets(tx *gorm.DB, ids []uuid.UUID) ([]models.Wallet, error) {
+	unique := make([]uuid.UUID, 0, len(ids))
+	seen := make(map[uuid.UUID]bool)
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i].String() < unique[j].String() })
+
+	var wallets []models.Wallet
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", unique).Order("id").Find(&wallets).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(wallets) != len(unique) {
+		return nil, ErrWalletNotFound
+	}
+	return wallets, nil
+}
+
+// UpdateLimits sets a wallet's own spending limits; nil clears one so the
+// service-wide limit applies again.
+func (r *WalletRepository) UpdateLimits(ctx context.Context, id uuid.UUID, daily, monthly *money.Amount) error {
+	res := r.db.WithContext(ctx).Model(&models.Wallet{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"daily_limit": daily, "monthly_limit": monthly})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrWalletNotFound
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"wallet-app/internal/services"
+	"wallet-app/pkg/money"
+	"wallet-app/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type HoldHandler struct {
+	service *services.WalletService
+}
+
+func NewHoldHandler(service *services.WalletService) *HoldHandler {
+	return &HoldHandler{service: service}
+}
+
+type CreateHoldRequest struct {
+	Amount money.Amount `json:"amount"`
+	// TTLSeconds defaults to a week and may not exceed 30 days.
+	TTLSeconds  int64  `json:"ttl_seconds" binding:"gte=0"`
+	Description string `json:"description"`
+}
+
+type CaptureHoldRequest struct {
+	// Amount defaults to the whole hold.
+	Amount money.Amount `json:"amount"`
+}
+
+// Create handles POST /wallets/:id/holds.
+func (h *HoldHandler) Create(c *gin.Context) {
+	walletID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wallet ID")
+		return
+	}
+	var req CreateHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if ownedWallet(c, h.service, walletID) == nil {
+		return
+	}
+
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	hold, err := h.service.Reserve(c.Request.Context(), walletID, req.Amount, ttl, req.Description)
+	if err != nil {
+		status, message := paymentError(err, "Failed to place hold")
+		utils.ErrorResponse(c, status, message)
+		return
+	}
+	c.JSON(http.StatusCreated, hold)
+}
+
+// Capture handles POST /holds/:id/capture.
+func (h *HoldHandler) Capture(c *gin.Context) {
+	holdID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid hold ID")
+		return
+	}
+	var req CaptureHoldRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+	if !ownedHold(c, h.service, holdID) {
+		return
+	}
+
+	hold, err := h.service.Capture(c.Request.Context(), holdID, req.Amount)
+	if err != nil {
+		status, message := paymentError(err, "Failed to capture hold")
+		utils.ErrorResponse(c, status, message)
+		return
+	}
+	c.JSON(http.StatusOK, hold)
+}
+
+// Release handles POST /holds/:id/release.
+func (h *HoldHandler) Release(c *gin.Context) {
+	holdID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid hold ID")
+		return
+	}
+	if !ownedHold(c, h.service, holdID) {
+		return
+	}
+
+	hold, err := h.service.Release(c.Request.Context(), holdID)
+	if err != nil {
+		status, message := paymentError(err, "Failed to release hold")
+		utils.ErrorResponse(c, status, message)
+		return
+	}
+	c.JSON(http.StatusOK, hold)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/internal/domain/repository"
+	"wallet-app/internal/middleware"
+	"wallet-app/internal/services"
+	"wallet-app/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ownedWallet loads a wallet that must belong to the authenticated user.
+// Otherwise it writes the error response and returns nil; someone else's
+// wallet gets the same 404 as a missing one.
+func ownedWallet(c *gin.Context, service *services.WalletService, walletID uuid.UUID) *models.Wallet {
+	wallet, err := service.OwnedWallet(c.Request.Context(), walletID, middleware.UserID(c))
+	switch {
+	case errors.Is(err, repository.ErrWalletNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Wallet not found")
+		return nil
+	case err != nil:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to load wallet")
+		return nil
+	}
+	return wallet
+}
+
+// ownedHold is ownedWallet for the wallet a hold was placed on.
+func ownedHold(c *gin.Context, service *services.WalletService, holdID uuid.UUID) bool {
+	hold, err := service.GetHold(c.Request.Context(), holdID)
+	switch {
+	case errors.Is(err, repository.ErrHoldNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Hold not found")
+		return false
+	case err != nil:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to load hold")
+		return false
+	}
+	_, err = service.OwnedWallet(c.Request.Context(), hold.WalletID, middleware.UserID(c))
+	switch {
+	case errors.Is(err, repository.ErrWalletNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Hold not found")
+		return false
+	case err != nil:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to load hold")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"wallet-app/internal/domain/repository"
+	"wallet-app/internal/services"
+	"wallet-app/pkg/money"
+	"wallet-app/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RiskHandler serves spending limits and the risk review queue.
+type RiskHandler struct {
+	service *services.WalletService
+}
+
+func NewRiskHandler(service *services.WalletService) *RiskHandler {
+	return &RiskHandler{service: service}
+}
+
+// SetLimitsRequest sets a wallet's own limits; an omitted or null limit
+// falls back to the service-wide one.
+type SetLimitsRequest struct {
+	DailyLimit   *money.Amount `json:"daily_limit"`
+	MonthlyLimit *money.Amount `json:"monthly_limit"`
+}
+
+// SetLimits handles PUT /wallets/:id/limits.
+func (h *RiskHandler) SetLimits(c *gin.Context) {
+	walletID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wallet ID")
+		return
+	}
+	var req SetLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	wallet, err := h.service.SetLimits(c.Request.Context(), walletID, req.DailyLimit, req.MonthlyLimit)
+	switch {
+	case errors.Is(err, repository.ErrWalletNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Wallet not found")
+		return
+	case errors.Is(err, services.ErrInvalidAmount):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Limits must not be negative")
+		return
+	case err != nil:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to set limits")
+		return
+	}
+	c.JSON(http.StatusOK, wallet)
+}
+
+// Decisions handles GET /risk/decisions. Repeat ?outcome= to filter, e.g.
+// ?outcome=review&outcome=decline for the review queue.
+func (h *RiskHandler) Decisions(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "limit must be between 1 and 200")
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "offset must not be negative")
+		return
+	}
+
+	decisions, err := h.service.RiskDecisions(c.Request.Context(), c.QueryArray("outcome"), limit, offset)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to list risk decisions")
+		return
+	}
+	c.JSON(http.StatusOK, decisions)
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"wallet-app/internal/domain/repository"
+	"wallet-app/internal/middleware"
+	"wallet-app/internal/services"
+	"wallet-app/pkg/money"
+	"wallet-app/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxIdempotencyKeyLength matches the idempotency_keys.key column.
+const maxIdempotencyKeyLength = 255
+
+type TransferHandler struct {
+	service *services.WalletService
+}
+
+func NewTransferHandler(service *services.WalletService) *TransferHandler {
+	return &TransferHandler{service: service}
+}
+
+// FXQuoteRequest names a quote from POST /fx/quotes; the rate is the one
+// the service issued, never the client's.
+type FXQuoteRequest struct {
+	ID string `json:"id" binding:"required"`
+}
+
+// TransferRequest takes amounts as decimal strings, e.g. "12.50"; JSON
+// numbers are accepted but never pass through float64.
+type TransferRequest struct {
+	FromWalletID string          `json:"from_wallet_id" binding:"required"`
+	ToWalletID   string          `json:"to_wallet_id" binding:"required"`
+	Amount       money.Amount    `json:"amount"`
+	Description  string          `json:"description"`
+	FXQuote      *FXQuoteRequest `json:"fx_quote"`
+}
+
+// Create handles POST /transfers. Clients should send an Idempotency-Key
+// header so a retried request never moves money twice; a replayed response
+// carries Idempotent-Replayed: true.
+func (h *TransferHandler) Create(c *gin.Context) {
+	var req TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	from, err := uuid.Parse(req.FromWalletID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid source wallet ID")
+		return
+	}
+	to, err := uuid.Parse(req.ToWalletID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid destination wallet ID")
+		return
+	}
+
+	key := c.GetHeader("Idempotency-Key")
+	if len(key) > maxIdempotencyKeyLength {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Idempotency-Key is too long")
+		return
+	}
+	// Money can be sent to anyone's wallet, but only from the caller's own
+	if ownedWallet(c, h.service, from) == nil {
+		return
+	}
+
+	transfer := services.TransferRequest{
+		FromWalletID: from,
+		ToWalletID:   to,
+		Amount:       req.Amount,
+		Description:  req.Description,
+	}
+	if q := req.FXQuote; q != nil {
+		id, err := uuid.Parse(q.ID)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid FX quote ID")
+			return
+		}
+		transfer.FXQuoteID = &id
+	}
+
+	txn, replayed, err := h.service.Transfer(c.Request.Context(), transfer, services.Idempotency{
+		Scope: middleware.UserID(c).String(),
+		Key:   key,
+	})
+	if err != nil {
+		status, message := paymentError(err, "Failed to transfer funds")
+		utils.ErrorResponse(c, status, message)
+		return
+	}
+
+	if replayed {
+		c.Header("Idempotent-Replayed", "true")
+	}
+	c.JSON(http.StatusCreated, txn)
+}
+
+type QuoteRequest struct {
+	FromCurrency string `json:"from_currency" binding:"required"`
+	ToCurrency   string `json:"to_currency" binding:"required"`
+}
+
+// Quote handles POST /fx/quotes: it issues the caller a short-lived rate
+// that one of their transfers can convert at.
+func (h *TransferHandler) Quote(c *gin.Context) {
+	var req QuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	quote, err := h.service.QuoteFX(c.Request.Context(), middleware.UserID(c), req.FromCurrency, req.ToCurrency)
+	switch {
+	case errors.Is(err, services.ErrUnsupportedCurrency), errors.Is(err, services.ErrFXQuoteNotNeeded):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, services.ErrFXRateUnavailable):
+		utils.ErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	case err != nil:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to quote exchange rate")
+		return
+	}
+
+	c.JSON(http.StatusCreated, quote)
+}
+
+// paymentError maps the errors of moving money out of a wallet to a
+// status and message; fallback is the message for unexpected errors.
+func paymentError(err error, fallback string) (int, string) {
+	switch {
+	case errors.Is(err, repository.ErrWalletNotFound):
+		return http.StatusNotFound, "Wallet not found"
+	case errors.Is(err, repository.ErrHoldNotFound):
+		return http.StatusNotFound, "Hold not found"
+	case errors.Is(err, services.ErrSameWallet),
+		errors.Is(err, services.ErrInvalidAmount),
+		errors.Is(err, services.ErrAmountPrecision),
+		errors.Is(err, services.ErrFXQuoteNotNeeded),
+		errors.Is(err, services.ErrInvalidHoldTTL):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, services.ErrRiskDeclined):
+		return http.StatusForbidden, services.ErrRiskDeclined.Error()
+	case errors.Is(err, repository.ErrInsufficientFunds),
+		errors.Is(err, services.ErrFXQuoteRequired),
+		errors.Is(err, services.ErrFXQuoteInvalid),
+		errors.Is(err, services.ErrIdempotencyKeyReused),
+		errors.Is(err, services.ErrDailyLimitExceeded),
+		errors.Is(err, services.ErrMonthlyLimitExceeded),
+		errors.Is(err, services.ErrTransactionLimitExceeded),
+		errors.Is(err, services.ErrCaptureExceedsHold):
+		return http.StatusUnprocessableEntity, err.Error()
+	case errors.Is(err, services.ErrRequestInProgress),
+		errors.Is(err, services.ErrHoldNotActive),
+		errors.Is(err, services.ErrHoldExpired):
+		return http.StatusConflict, err.Error()
+	}
+	return http.StatusInternalServerError, fallback
+}
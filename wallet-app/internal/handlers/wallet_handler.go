@@ -1,8 +1,13 @@
 package handlers
 
 import (
+    "errors"
     "net/http"
+    "strconv"
+    "wallet-app/internal/domain/models"
+    "wallet-app/internal/middleware"
     "wallet-app/internal/services"
+    "wallet-app/pkg/money"
     "wallet-app/pkg/utils"
 
     "github.com/gin-gonic/gin"
@@ -17,11 +22,18 @@ func NewWalletHandler(service *services.WalletService) *WalletHandler {
     return &WalletHandler{service: service}
 }
 
+// CreateWalletRequest opens a wallet for the authenticated user. UserID is
+// optional and, when given, must be that user.
 type CreateWalletRequest struct {
-    UserID   string `json:"user_id" binding:"required"`
+    UserID   string `json:"user_id"`
     Currency string `json:"currency" binding:"required"`
 }
 
+// AmountRequest is the body of a deposit or withdrawal.
+type AmountRequest struct {
+    Amount money.Amount `json:"amount"`
+}
+
 func (h *WalletHandler) Create(c *gin.Context) {
     var req CreateWalletRequest
     if err := c.ShouldBindJSON(&req); err != nil {
@@ -29,17 +41,107 @@ func (h *WalletHandler) Create(c *gin.Context) {
         return
     }
 
-    userID, err := uuid.Parse(req.UserID)
-    if err != nil {
-        utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
-        return
+    userID := middleware.UserID(c)
+    if req.UserID != "" {
+        requested, err := uuid.Parse(req.UserID)
+        if err != nil {
+            utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+            return
+        }
+        if requested != userID {
+            utils.ErrorResponse(c, http.StatusForbidden, "Wallets can only be opened for yourself")
+            return
+        }
     }
 
     wallet, err := h.service.CreateWallet(c.Request.Context(), userID, req.Currency)
+    if errors.Is(err, services.ErrUnsupportedCurrency) {
+        utils.ErrorResponse(c, http.StatusBadRequest, "Unsupported currency")
+        return
+    }
     if err != nil {
         utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create wallet")
         return
     }
 
     c.JSON(http.StatusCreated, wallet)
-}
\ No newline at end of file
+}
+
+// Get handles GET /wallets/:id.
+func (h *WalletHandler) Get(c *gin.Context) {
+    walletID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wallet ID")
+        return
+    }
+
+    wallet := ownedWallet(c, h.service, walletID)
+    if wallet == nil {
+        return
+    }
+    c.JSON(http.StatusOK, wallet)
+}
+
+// Deposit handles POST /wallets/:id/deposit.
+func (h *WalletHandler) Deposit(c *gin.Context) {
+    h.process(c, models.Deposit, "Failed to deposit funds")
+}
+
+// Withdraw handles POST /wallets/:id/withdraw.
+func (h *WalletHandler) Withdraw(c *gin.Context) {
+    h.process(c, models.Withdraw, "Failed to withdraw funds")
+}
+
+func (h *WalletHandler) process(c *gin.Context, txType models.TransactionType, failure string) {
+    walletID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wallet ID")
+        return
+    }
+    var req AmountRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+        return
+    }
+    if ownedWallet(c, h.service, walletID) == nil {
+        return
+    }
+
+    txn, err := h.service.ProcessTransaction(c.Request.Context(), walletID, req.Amount, txType)
+    if err != nil {
+        status, message := paymentError(err, failure)
+        utils.ErrorResponse(c, status, message)
+        return
+    }
+    c.JSON(http.StatusCreated, txn)
+}
+
+// GetTransactions handles GET /wallets/:id/transactions, newest first,
+// paged with ?limit= and ?offset=.
+func (h *WalletHandler) GetTransactions(c *gin.Context) {
+    walletID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wallet ID")
+        return
+    }
+    limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+    if err != nil || limit < 1 || limit > 200 {
+        utils.ErrorResponse(c, http.StatusBadRequest, "limit must be between 1 and 200")
+        return
+    }
+    offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+    if err != nil || offset < 0 {
+        utils.ErrorResponse(c, http.StatusBadRequest, "offset must not be negative")
+        return
+    }
+    if ownedWallet(c, h.service, walletID) == nil {
+        return
+    }
+
+    history, err := h.service.GetTransactions(c.Request.Context(), walletID, limit, offset)
+    if err != nil {
+        utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to list transactions")
+        return
+    }
+    c.JSON(http.StatusOK, history)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/internal/domain/repository"
+	"wallet-app/internal/services"
+	"wallet-app/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// WebhookHandler serves webhook endpoint registration and the delivery log.
+type WebhookHandler struct {
+	service *services.WebhookService
+}
+
+func NewWebhookHandler(service *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+// RegisterWebhookRequest subscribes URL to the listed events, or to every
+// event when Events is empty.
+type RegisterWebhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events"`
+	// Secret is generated when omitted.
+	Secret string `json:"secret"`
+}
+
+// RegisterWebhookResponse is the only place the signing secret is shown.
+type RegisterWebhookResponse struct {
+	*models.WebhookEndpoint
+	Secret string `json:"secret"`
+}
+
+// Register handles POST /webhooks.
+func (h *WebhookHandler) Register(c *gin.Context) {
+	var req RegisterWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	endpoint, err := h.service.RegisterEndpoint(c.Request.Context(), req.URL, req.Secret, req.Events)
+	switch {
+	case errors.Is(err, services.ErrInvalidWebhookURL), errors.Is(err, services.ErrUnknownEventType):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to register webhook")
+		return
+	}
+	c.JSON(http.StatusCreated, RegisterWebhookResponse{WebhookEndpoint: endpoint, Secret: endpoint.Secret})
+}
+
+// List handles GET /webhooks.
+func (h *WebhookHandler) List(c *gin.Context) {
+	endpoints, err := h.service.ListEndpoints(c.Request.Context())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to list webhooks")
+		return
+	}
+	c.JSON(http.StatusOK, endpoints)
+}
+
+// Remove handles DELETE /webhooks/:id.
+func (h *WebhookHandler) Remove(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+	err = h.service.RemoveEndpoint(c.Request.Context(), id)
+	switch {
+	case errors.Is(err, repository.ErrEndpointNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Webhook not found")
+		return
+	case err != nil:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to remove webhook")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Deliveries handles GET /webhooks/deliveries, e.g. ?status=dead for the
+// dead-letter queue.
+func (h *WebhookHandler) Deliveries(c *gin.Context) {
+	status := models.DeliveryStatus(c.Query("status"))
+	switch status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, "status must be pending, delivered or dead")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "limit must be between 1 and 200")
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "offset must not be negative")
+		return
+	}
+
+	deliveries, err := h.service.ListDeliveries(c.Request.Context(), status, limit, offset)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to list deliveries")
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// Redeliver handles POST /webhooks/deliveries/:id/redeliver.
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+	delivery, err := h.service.Redeliver(c.Request.Context(), id)
+	switch {
+	case errors.Is(err, repository.ErrDeliveryNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Delivery not found")
+		return
+	case err != nil:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to redeliver")
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
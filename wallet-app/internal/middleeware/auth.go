@@ -1,39 +0,0 @@
-package middleware
-
-import (
-    "net/http"
-    "strings"
-
-    "github.com/gin-gonic/gin"
-    "github.com/golang-jwt/jwt/v4"
-)
-
-type Claims struct {
-    UserID string `json:"user_id"`
-    jwt.StandardClaims
-}
-
-func Auth() gin.HandlerFunc {
-    return func(c *gin.Context) {
-        authHeader := c.GetHeader("Authorization")
-        if authHeader == "" {
-            c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-            return
-        }
-
-        tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-        claims := &Claims{}
-
-        token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-            return []byte(config.Get().JWTSecret), nil
-        })
-
-        if err != nil || !token.Valid {
-            c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-            return
-        }
-
-        c.Set("userID", claims.UserID)
-        c.Next()
-    }
-}
\ No newline at end of file
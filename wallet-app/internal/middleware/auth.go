@@ -0,0 +1,75 @@
+package middleware
+
+import (
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "github.com/golang-jwt/jwt/v4"
+    "github.com/google/uuid"
+)
+
+// RoleAdmin may manage limits, the risk review queue and webhooks.
+const RoleAdmin = "admin"
+
+// userIDKey is the context key the authenticated user's ID is stored under.
+const userIDKey = "userID"
+
+type Claims struct {
+    UserID string `json:"user_id"`
+    Role   string `json:"role,omitempty"`
+    jwt.StandardClaims
+}
+
+// Auth accepts requests carrying a valid HS256 bearer token signed with
+// secret whose user_id is a UUID.
+func Auth(secret string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        authHeader := c.GetHeader("Authorization")
+        if authHeader == "" {
+            c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+            return
+        }
+
+        tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+        claims := &Claims{}
+
+        token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+            return []byte(secret), nil
+        }, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+
+        if err != nil || !token.Valid {
+            c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+            return
+        }
+
+        userID, err := uuid.Parse(claims.UserID)
+        if err != nil || userID == uuid.Nil {
+            c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+            return
+        }
+
+        c.Set(userIDKey, userID)
+        c.Set("role", claims.Role)
+        c.Next()
+    }
+}
+
+// RequireRole lets through only users whose token carries role. It must run
+// after Auth.
+func RequireRole(role string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if c.GetString("role") != role {
+            c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+            return
+        }
+        c.Next()
+    }
+}
+
+// UserID returns the authenticated user's ID, or uuid.Nil outside Auth.
+func UserID(c *gin.Context) uuid.UUID {
+    id, _ := c.Get(userIDKey)
+    userID, _ := id.(uuid.UUID)
+    return userID
+}
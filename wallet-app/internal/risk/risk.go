@@ -0,0 +1,87 @@
+// Package risk decides whether money may leave a wallet. An Engine runs a
+// set of pluggable rules over the wallet's recent outflows and returns the
+// strictest decision, with the rule and reason behind it.
+package risk
+
+import (
+	"context"
+	"time"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type Outcome string
+
+const (
+	Allow Outcome = "allow"
+	// Review lets the attempt through but flags it for a person to check.
+	Review  Outcome = "review"
+	Decline Outcome = "decline"
+)
+
+// severity orders outcomes from most to least permissive.
+var severity = map[Outcome]int{Allow: 0, Review: 1, Decline: 2}
+
+type Operation string
+
+const (
+	Withdraw Operation = "withdraw"
+	Transfer Operation = "transfer"
+	Hold     Operation = "hold"
+)
+
+// Attempt is an attempt to move money out of a wallet.
+type Attempt struct {
+	WalletID  uuid.UUID
+	Operation Operation
+	Amount    money.Amount
+	Currency  string
+	At        time.Time
+}
+
+type Decision struct {
+	Outcome Outcome
+	Rule    string
+	Reason  string
+}
+
+// History gives rules a wallet's outflows since a point in time.
+type History interface {
+	Outflows(ctx context.Context, walletID uuid.UUID, since time.Time) ([]models.Outflow, error)
+}
+
+// Rule is one check. It returns Allow when it has no objection.
+type Rule interface {
+	Name() string
+	Evaluate(ctx context.Context, history History, attempt Attempt) (Decision, error)
+}
+
+type Engine struct {
+	rules []Rule
+}
+
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// Evaluate runs every rule and returns the strictest decision. Ties go to
+// the rule listed first.
+func (e *Engine) Evaluate(ctx context.Context, history History, attempt Attempt) (Decision, error) {
+	decision := Decision{Outcome: Allow}
+	for _, rule := range e.rules {
+		d, err := rule.Evaluate(ctx, history, attempt)
+		if err != nil {
+			return Decision{}, err
+		}
+		if severity[d.Outcome] > severity[decision.Outcome] {
+			if d.Rule == "" {
+				d.Rule = rule.Name()
+			}
+			decision = d
+		}
+	}
+	return decision, nil
+}
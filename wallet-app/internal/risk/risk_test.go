@@ -0,0 +1,130 @@
+package risk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// history is a fixed list of outflows.
+type history []models.Outflow
+
+func (h history) Outflows(_ context.Context, _ uuid.UUID, since time.Time) ([]models.Outflow, error) {
+	var out []models.Outflow
+	for _, o := range h {
+		if !o.At.Before(since) {
+			out = append(out, o)
+		}
+	}
+	return out, nil
+}
+
+type failing struct{}
+
+func (failing) Outflows(context.Context, uuid.UUID, time.Time) ([]models.Outflow, error) {
+	return nil, errors.New("database is down")
+}
+
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func outflows(amounts ...string) history {
+	h := make(history, len(amounts))
+	for i, a := range amounts {
+		h[i] = models.Outflow{ID: uuid.New(), Amount: money.MustParse(a), At: now.Add(-time.Duration(len(amounts)-i) * time.Minute)}
+	}
+	return h
+}
+
+func attempt(amount string) Attempt {
+	return Attempt{WalletID: uuid.New(), Operation: Withdraw, Amount: money.MustParse(amount), Currency: "USD", At: now}
+}
+
+func TestVelocity(t *testing.T) {
+	rule := Velocity{Max: 3, Window: 10 * time.Minute}
+	tests := []struct {
+		name    string
+		history history
+		want    Outcome
+	}{
+		{"quiet wallet", nil, Allow},
+		{"below the limit", outflows("1", "1"), Allow},
+		{"at the limit", outflows("1", "1", "1"), Decline},
+		{"old outflows do not count", append(outflows("1", "1"), models.Outflow{Amount: money.MustParse("1"), At: now.Add(-time.Hour)}), Allow},
+	}
+	for _, tt := range tests {
+		d, err := rule.Evaluate(context.Background(), tt.history, attempt("5"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Outcome != tt.want {
+			t.Errorf("%s: outcome %s, want %s (%s)", tt.name, d.Outcome, tt.want, d.Reason)
+		}
+	}
+}
+
+func TestAmountSpike(t *testing.T) {
+	rule := AmountSpike{Factor: money.MustParseRate("2.5"), Lookback: 24 * time.Hour, MinHistory: 3}
+	tests := []struct {
+		name    string
+		history history
+		amount  string
+		want    Outcome
+	}{
+		{"too little history", outflows("1", "1"), "1000", Allow},
+		{"within the factor", outflows("10", "20", "30"), "49.99", Allow},
+		{"exactly the threshold", outflows("10", "20", "30"), "50", Allow},
+		{"spike", outflows("10", "20", "30"), "50.01", Review},
+	}
+	for _, tt := range tests {
+		d, err := rule.Evaluate(context.Background(), tt.history, attempt(tt.amount))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Outcome != tt.want {
+			t.Errorf("%s: outcome %s, want %s (%s)", tt.name, d.Outcome, tt.want, d.Reason)
+		}
+	}
+
+	rule.Outcome = Decline
+	d, err := rule.Evaluate(context.Background(), outflows("10", "20", "30"), attempt("100"))
+	if err != nil || d.Outcome != Decline || d.Reason == "" {
+		t.Errorf("configured outcome: %+v, %v", d, err)
+	}
+}
+
+func TestEngineReturnsStrictestDecision(t *testing.T) {
+	engine := NewEngine(
+		AmountSpike{Factor: money.MustParseRate("2"), Lookback: time.Hour, MinHistory: 1},
+		Velocity{Max: 2, Window: time.Hour},
+	)
+	d, err := engine.Evaluate(context.Background(), outflows("10"), attempt("100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Outcome != Review || d.Rule != "amount_spike" {
+		t.Errorf("one flag: %+v", d)
+	}
+
+	d, err = engine.Evaluate(context.Background(), outflows("10", "10"), attempt("100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Outcome != Decline || d.Rule != "velocity" {
+		t.Errorf("review and decline: %+v", d)
+	}
+
+	d, err = NewEngine().Evaluate(context.Background(), nil, attempt("100"))
+	if err != nil || d.Outcome != Allow || d.Rule != "" {
+		t.Errorf("no rules: %+v, %v", d, err)
+	}
+
+	if _, err := engine.Evaluate(context.Background(), failing{}, attempt("1")); err == nil {
+		t.Error("history errors are not returned")
+	}
+}
@@ -0,0 +1,77 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"wallet-app/pkg/money"
+)
+
+// Velocity declines an attempt that would make more than Max outflows from
+// the wallet within Window, e.g. a sixth withdrawal in ten minutes.
+type Velocity struct {
+	Max    int
+	Window time.Duration
+}
+
+func (Velocity) Name() string { return "velocity" }
+
+func (r Velocity) Evaluate(ctx context.Context, history History, a Attempt) (Decision, error) {
+	recent, err := history.Outflows(ctx, a.WalletID, a.At.Add(-r.Window))
+	if err != nil {
+		return Decision{}, err
+	}
+	if len(recent) < r.Max {
+		return Decision{Outcome: Allow}, nil
+	}
+	return Decision{
+		Outcome: Decline,
+		Reason:  fmt.Sprintf("%d outgoing payments in the last %s; at most %d are allowed", len(recent)+1, r.Window, r.Max),
+	}, nil
+}
+
+// AmountSpike flags an attempt larger than Factor times the wallet's average
+// outflow over Lookback. Wallets with fewer than MinHistory outflows have no
+// meaningful average and are not checked.
+type AmountSpike struct {
+	Factor     money.Rate
+	Lookback   time.Duration
+	MinHistory int
+	// Outcome is what a spike leads to; it defaults to Review.
+	Outcome Outcome
+}
+
+func (AmountSpike) Name() string { return "amount_spike" }
+
+func (r AmountSpike) Evaluate(ctx context.Context, history History, a Attempt) (Decision, error) {
+	past, err := history.Outflows(ctx, a.WalletID, a.At.Add(-r.Lookback))
+	if err != nil {
+		return Decision{}, err
+	}
+	if len(past) == 0 || len(past) < r.MinHistory {
+		return Decision{Outcome: Allow}, nil
+	}
+
+	var total money.Amount
+	for _, o := range past {
+		total = total.Add(o.Amount)
+	}
+	average := total.Div(int64(len(past)), money.HalfEven)
+	threshold, err := average.Convert(r.Factor, money.Scale, money.HalfEven)
+	if err != nil {
+		return Decision{}, err
+	}
+	if a.Amount.Cmp(threshold) <= 0 {
+		return Decision{Outcome: Allow}, nil
+	}
+
+	outcome := r.Outcome
+	if outcome == "" {
+		outcome = Review
+	}
+	return Decision{
+		Outcome: outcome,
+		Reason:  fmt.Sprintf("%s %s is more than %s times the average outflow of %s over %s", a.Amount, a.Currency, r.Factor, average, r.Lookback),
+	}, nil
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"wallet-app/internal/domain/repository"
+)
+
+// ConsistencyChecker verifies every night that cached wallet balances match
+// the journal and that every journal entry balances.
+type ConsistencyChecker struct {
+	ledger *repository.LedgerRepository
+	// At is the time of day, in UTC, at which the check runs.
+	At     time.Duration
+	logger *log.Logger
+}
+
+func NewConsistencyChecker(ledger *repository.LedgerRepository, logger *log.Logger) *ConsistencyChecker {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &ConsistencyChecker{ledger: ledger, At: 2 * time.Hour, logger: logger}
+}
+
+// Check runs one verification and logs what it found.
+func (c *ConsistencyChecker) Check(ctx context.Context) (repository.ConsistencyReport, error) {
+	report, err := c.ledger.CheckConsistency(ctx)
+	if err != nil {
+		c.logger.Printf("ledger consistency check failed: %v", err)
+		return report, err
+	}
+
+	for _, m := range report.Mismatches {
+		c.logger.Printf("ledger: wallet %s caches balance %v but its postings sum to %v", m.WalletID, m.Cached, m.Journal)
+	}
+	for _, u := range report.Unbalanced {
+		c.logger.Printf("ledger: transaction %s is unbalanced in %s by %v", u.TransactionID, u.Currency, u.Sum)
+	}
+	if report.OK() {
+		c.logger.Printf("ledger consistent: %d wallets checked", report.Wallets)
+	}
+	return report, nil
+}
+
+// Run checks the ledger every day at c.At until ctx is cancelled.
+func (c *ConsistencyChecker) Run(ctx context.Context) {
+	for {
+		timer := time.NewTimer(time.Until(c.next(time.Now())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			c.Check(ctx)
+		}
+	}
+}
+
+// next is the first run time after now.
+func (c *ConsistencyChecker) next(now time.Time) time.Time {
+	now = now.UTC()
+	run := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Add(c.At)
+	if !run.After(now) {
+		run = run.AddDate(0, 0, 1)
+	}
+	return run
+}
@@ -0,0 +1,23 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConsistencyCheckerNext(t *testing.T) {
+	c := &ConsistencyChecker{At: 2 * time.Hour}
+	tests := []struct{ now, want string }{
+		{"2024-03-10T01:00:00Z", "2024-03-10T02:00:00Z"},
+		{"2024-03-10T02:00:00Z", "2024-03-11T02:00:00Z"},
+		{"2024-03-10T23:30:00Z", "2024-03-11T02:00:00Z"},
+		{"2024-03-10T01:00:00+02:00", "2024-03-10T02:00:00Z"},
+	}
+	for _, tt := range tests {
+		now, _ := time.Parse(time.RFC3339, tt.now)
+		want, _ := time.Parse(time.RFC3339, tt.want)
+		if got := c.next(now); !got.Equal(want) {
+			t.Errorf("next(%s) = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/internal/domain/repository"
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// TransferCompleted is the data of a transfer.completed event.
+type TransferCompleted struct {
+	TransactionID uuid.UUID    `json:"transaction_id"`
+	FromWalletID  uuid.UUID    `json:"from_wallet_id"`
+	ToWalletID    uuid.UUID    `json:"to_wallet_id"`
+	Amount        money.Amount `json:"amount"`
+	Currency      string       `json:"currency"`
+	// ReceivedAmount and ReceivedCurrency differ from Amount and Currency
+	// when the transfer was converted.
+	ReceivedAmount   money.Amount `json:"received_amount"`
+	ReceivedCurrency string       `json:"received_currency"`
+	ExchangeRate     *money.Rate  `json:"exchange_rate,omitempty"`
+}
+
+// publish appends an event to the outbox of tx, so that it commits or rolls
+// back together with the change it describes.
+func publish(ctx context.Context, tx *repository.Store, eventType string, aggregateID uuid.UUID, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return tx.Outbox.Append(ctx, &models.OutboxEvent{
+		ID:          uuid.New(),
+		Type:        eventType,
+		AggregateID: aggregateID,
+		Payload:     payload,
+	})
+}
+
+// post records txn in the ledger and publishes transaction.posted.
+func post(ctx context.Context, tx *repository.Store, txn *models.Transaction) error {
+	if err := tx.Ledger.Post(ctx, txn); err != nil {
+		return err
+	}
+	return publish(ctx, tx, models.EventTransactionPosted, txn.ID, txn)
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// DefaultQuoteTTL is how long an FX quote can be used when QuoteTTL is not
+// set.
+const DefaultQuoteTTL = 30 * time.Second
+
+var ErrFXRateUnavailable = errors.New("no exchange rate for this currency pair")
+
+// RateSource gives the rate at which one unit of from buys units of to.
+type RateSource interface {
+	Rate(ctx context.Context, from, to string) (money.Rate, error)
+}
+
+// StaticRates is a fixed set of rates keyed by pair, e.g. "USD/EUR".
+type StaticRates map[string]money.Rate
+
+func (r StaticRates) Rate(_ context.Context, from, to string) (money.Rate, error) {
+	rate, ok := r[from+"/"+to]
+	if !ok || rate.Sign() <= 0 {
+		return money.Rate{}, fmt.Errorf("%w: %s/%s", ErrFXRateUnavailable, from, to)
+	}
+	return rate, nil
+}
+
+// ParseRates reads rates written as "USD/EUR=0.92,EUR/USD=1.087".
+func ParseRates(s string) (StaticRates, error) {
+	rates := StaticRates{}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		pair, value, ok := strings.Cut(field, "=")
+		from, to, okPair := strings.Cut(strings.ToUpper(strings.TrimSpace(pair)), "/")
+		if !ok || !okPair {
+			return nil, fmt.Errorf("invalid rate %q: want FROM/TO=RATE", field)
+		}
+		rate, err := money.ParseRate(strings.TrimSpace(value))
+		if err != nil || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid rate %q", field)
+		}
+		rates[from+"/"+to] = rate
+	}
+	return rates, nil
+}
+
+// QuoteFX offers userID the current rate from one currency into another.
+// The quote is stored, so a transfer can only convert at a rate the
+// service issued.
+func (s *WalletService) QuoteFX(ctx context.Context, userID uuid.UUID, from, to string) (*models.FXQuote, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	for _, currency := range []string{from, to} {
+		if _, err := money.Exponent(currency); err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrUnsupportedCurrency, currency)
+		}
+	}
+	if from == to {
+		return nil, ErrFXQuoteNotNeeded
+	}
+	if s.Rates == nil {
+		return nil, fmt.Errorf("%w: %s/%s", ErrFXRateUnavailable, from, to)
+	}
+	rate, err := s.Rates.Rate(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := s.QuoteTTL
+	if ttl <= 0 {
+		ttl = DefaultQuoteTTL
+	}
+	now := s.now()
+	quote := &models.FXQuote{
+		ID:           uuid.New(),
+		UserID:       userID,
+		FromCurrency: from,
+		ToCurrency:   to,
+		Rate:         rate,
+		ExpiresAt:    now.Add(ttl),
+		CreatedAt:    now,
+	}
+	if err := s.store.FXQuotes.Create(ctx, quote); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
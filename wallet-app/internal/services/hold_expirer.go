@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"wallet-app/internal/domain/repository"
+)
+
+// HoldExpirer marks holds whose time has run out as expired. Expired holds
+// stop reserving funds the moment they lapse whether or not this has run;
+// it keeps their status accurate for clients and reviewers.
+type HoldExpirer struct {
+	holds *repository.HoldRepository
+	// Interval is the time between sweeps.
+	Interval time.Duration
+	logger   *log.Logger
+}
+
+func NewHoldExpirer(holds *repository.HoldRepository, logger *log.Logger) *HoldExpirer {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &HoldExpirer{holds: holds, Interval: time.Minute, logger: logger}
+}
+
+// Expire runs one sweep.
+func (e *HoldExpirer) Expire(ctx context.Context) (int64, error) {
+	n, err := e.holds.ExpireDue(ctx, time.Now().UTC())
+	if err != nil {
+		e.logger.Printf("expiring holds failed: %v", err)
+		return 0, err
+	}
+	if n > 0 {
+		e.logger.Printf("expired %d holds", n)
+	}
+	return n, nil
+}
+
+// Run sweeps every e.Interval until ctx is cancelled.
+func (e *HoldExpirer) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Expire(ctx)
+		}
+	}
+}
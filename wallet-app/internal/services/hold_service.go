@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/internal/domain/repository"
+	"wallet-app/internal/risk"
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultHoldTTL is how long a hold lasts when the caller gives no TTL.
+	DefaultHoldTTL = 7 * 24 * time.Hour
+	MaxHoldTTL     = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidHoldTTL     = errors.New("hold TTL must be at most 30 days")
+	ErrHoldNotActive      = errors.New("hold was already captured or released")
+	ErrHoldExpired        = errors.New("hold has expired")
+	ErrCaptureExceedsHold = errors.New("capture amount exceeds the hold")
+)
+
+func (s *WalletService) GetHold(ctx context.Context, holdID uuid.UUID) (*models.Hold, error) {
+	return s.store.Holds.GetByID(ctx, holdID)
+}
+
+// Reserve places a hold on amount of a wallet's available balance. The hold
+// counts against the wallet's limits and is risk-checked like a
+// withdrawal; it lapses after ttl unless captured or released first.
+func (s *WalletService) Reserve(ctx context.Context, walletID uuid.UUID, amount money.Amount, ttl time.Duration, description string) (*models.Hold, error) {
+	if amount.Sign() <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if ttl == 0 {
+		ttl = DefaultHoldTTL
+	}
+	if ttl < 0 || ttl > MaxHoldTTL {
+		return nil, ErrInvalidHoldTTL
+	}
+
+	var hold *models.Hold
+	err := s.store.Transaction(ctx, func(tx *repository.Store) error {
+		wallets, err := tx.Wallets.Lock(ctx, walletID)
+		if err != nil {
+			return err
+		}
+		wallet := &wallets[0]
+		if err := checkPrecision(amount, wallet.Currency); err != nil {
+			return err
+		}
+		if err := s.authorize(ctx, tx, wallet, risk.Hold, amount); err != nil {
+			return err
+		}
+
+		now := s.now().UTC()
+		hold = &models.Hold{
+			ID:          uuid.New(),
+			WalletID:    wallet.ID,
+			Amount:      amount,
+			Currency:    wallet.Currency,
+			Status:      models.HoldActive,
+			Description: description,
+			ExpiresAt:   now.Add(ttl),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		return tx.Holds.Create(ctx, hold)
+	})
+	if err != nil {
+		s.recordDeclined(ctx, err)
+		return nil, err
+	}
+	return hold, nil
+}
+
+// Capture settles a hold by posting amount out of the wallet; a zero
+// amount captures the whole hold. Whatever is not captured is freed.
+func (s *WalletService) Capture(ctx context.Context, holdID uuid.UUID, amount money.Amount) (*models.Hold, error) {
+	if amount.Sign() < 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	var hold *models.Hold
+	err := s.store.Transaction(ctx, func(tx *repository.Store) error {
+		var err error
+		hold, err = lockActiveHold(ctx, tx, holdID, s.now())
+		if err != nil {
+			return err
+		}
+		if amount.IsZero() {
+			amount = hold.Amount
+		}
+		if amount.Cmp(hold.Amount) > 0 {
+			return ErrCaptureExceedsHold
+		}
+		if err := checkPrecision(amount, hold.Currency); err != nil {
+			return err
+		}
+		system, err := tx.Wallets.SystemWallet(ctx, hold.Currency)
+		if err != nil {
+			return err
+		}
+
+		// The funds were checked and counted against limits when they were
+		// held, so the capture is posted directly
+		description := hold.Description
+		if description == "" {
+			description = "capture"
+		}
+		txn := &models.Transaction{
+			Type:        models.Capture,
+			Description: description,
+			Postings: []models.Posting{
+				{WalletID: hold.WalletID, Direction: models.Debit, Amount: amount},
+				{WalletID: system.ID, Direction: models.Credit, Amount: amount},
+			},
+		}
+		if err := post(ctx, tx, txn); err != nil {
+			return err
+		}
+
+		hold.Status = models.HoldCaptured
+		hold.CapturedAmount = &amount
+		hold.TransactionID = &txn.ID
+		hold.UpdatedAt = txn.CreatedAt
+		return tx.Holds.Save(ctx, hold)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// Release frees a hold's funds without moving any money.
+func (s *WalletService) Release(ctx context.Context, holdID uuid.UUID) (*models.Hold, error) {
+	var hold *models.Hold
+	err := s.store.Transaction(ctx, func(tx *repository.Store) error {
+		var err error
+		hold, err = lockActiveHold(ctx, tx, holdID, s.now())
+		if err != nil {
+			return err
+		}
+		hold.Status = models.HoldReleased
+		hold.UpdatedAt = s.now().UTC()
+		return tx.Holds.Save(ctx, hold)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+func lockActiveHold(ctx context.Context, tx *repository.Store, id uuid.UUID, now time.Time) (*models.Hold, error) {
+	hold, err := tx.Holds.Lock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status == models.HoldExpired || hold.Status == models.HoldActive && !hold.ActiveAt(now) {
+		return nil, ErrHoldExpired
+	}
+	if hold.Status != models.HoldActive {
+		return nil, ErrHoldNotActive
+	}
+	return hold, nil
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/internal/domain/repository"
+	"wallet-app/pkg/money"
+)
+
+func availableOf(t *testing.T, s *WalletService, w *models.Wallet) string {
+	t.Helper()
+	free, err := s.Available(context.Background(), w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return free.String()
+}
+
+func TestHoldCaptureAndRelease(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	w := fundedWallet(t, s, "USD", "100")
+
+	hold, err := s.Reserve(ctx, w.ID, money.MustParse("60"), 0, "hotel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := availableOf(t, s, w); got != "40" {
+		t.Errorf("available = %s, want 40", got)
+	}
+	if got := balanceOf(t, s, w.ID); got != "100" {
+		t.Errorf("holding moved money: balance %s", got)
+	}
+
+	// Held funds cannot be spent twice
+	if _, err := s.ProcessTransaction(ctx, w.ID, money.MustParse("50"), models.Withdraw); !errors.Is(err, repository.ErrInsufficientFunds) {
+		t.Errorf("withdrawing held funds: got %v, want ErrInsufficientFunds", err)
+	}
+	if _, err := s.Reserve(ctx, w.ID, money.MustParse("41"), 0, ""); !errors.Is(err, repository.ErrInsufficientFunds) {
+		t.Errorf("overlapping hold: got %v, want ErrInsufficientFunds", err)
+	}
+
+	if _, err := s.Capture(ctx, hold.ID, money.MustParse("60.01")); !errors.Is(err, ErrCaptureExceedsHold) {
+		t.Errorf("over-capture: got %v, want ErrCaptureExceedsHold", err)
+	}
+	captured, err := s.Capture(ctx, hold.ID, money.MustParse("55.50"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if captured.Status != models.HoldCaptured || captured.TransactionID == nil || captured.CapturedAmount.String() != "55.5" {
+		t.Errorf("captured hold = %+v", captured)
+	}
+	if got := balanceOf(t, s, w.ID); got != "44.5" {
+		t.Errorf("balance after capture = %s, want 44.5", got)
+	}
+	if got := availableOf(t, s, w); got != "44.5" {
+		t.Errorf("available after capture = %s, want 44.5", got)
+	}
+	if _, err := s.Release(ctx, hold.ID); !errors.Is(err, ErrHoldNotActive) {
+		t.Errorf("releasing a captured hold: got %v, want ErrHoldNotActive", err)
+	}
+
+	second, err := s.Reserve(ctx, w.ID, money.MustParse("40"), time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	released, err := s.Release(ctx, second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released.Status != models.HoldReleased || availableOf(t, s, w) != "44.5" {
+		t.Errorf("released hold = %+v, available %s", released, availableOf(t, s, w))
+	}
+	if _, err := s.Capture(ctx, second.ID, money.Zero); !errors.Is(err, ErrHoldNotActive) {
+		t.Errorf("capturing a released hold: got %v, want ErrHoldNotActive", err)
+	}
+}
+
+func TestHoldExpiry(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	w := fundedWallet(t, s, "USD", "100")
+
+	if _, err := s.Reserve(ctx, w.ID, money.MustParse("10"), MaxHoldTTL+time.Second, ""); !errors.Is(err, ErrInvalidHoldTTL) {
+		t.Errorf("long TTL: got %v, want ErrInvalidHoldTTL", err)
+	}
+	hold, err := s.Reserve(ctx, w.ID, money.MustParse("70"), time.Minute, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A minute later the hold no longer reserves anything, even before the
+	// expirer has marked it
+	later := time.Now().Add(2 * time.Minute)
+	s.now = func() time.Time { return later }
+	if got := availableOf(t, s, w); got != "100" {
+		t.Errorf("available after expiry = %s, want 100", got)
+	}
+	if _, err := s.Capture(ctx, hold.ID, money.Zero); !errors.Is(err, ErrHoldExpired) {
+		t.Errorf("capturing an expired hold: got %v, want ErrHoldExpired", err)
+	}
+
+	expirer := NewHoldExpirer(s.store.Holds, nil)
+	n, err := s.store.Holds.ExpireDue(ctx, later)
+	if err != nil || n != 1 {
+		t.Fatalf("ExpireDue = %d, %v; want 1", n, err)
+	}
+	if n, _ := expirer.Expire(ctx); n != 0 {
+		t.Errorf("second sweep expired %d holds", n)
+	}
+	if _, err := s.Release(ctx, hold.ID); !errors.Is(err, ErrHoldExpired) {
+		t.Errorf("releasing an expired hold: got %v, want ErrHoldExpired", err)
+	}
+}
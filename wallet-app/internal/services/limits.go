@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/internal/domain/repository"
+	"wallet-app/internal/risk"
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDailyLimitExceeded       = errors.New("daily spending limit exceeded")
+	ErrMonthlyLimitExceeded     = errors.New("monthly spending limit exceeded")
+	ErrTransactionLimitExceeded = errors.New("daily transaction limit exceeded")
+	ErrRiskDeclined             = errors.New("declined by risk checks")
+)
+
+// Limits caps the money leaving a wallet through withdrawals, transfers
+// and holds. Zero means no limit. A wallet's own DailyLimit and
+// MonthlyLimit take precedence over Daily and Monthly.
+type Limits struct {
+	Daily   money.Amount
+	Monthly money.Amount
+	// DailyTransactions caps how many outgoing payments and holds a wallet
+	// may make per UTC day.
+	DailyTransactions int
+}
+
+// RiskDeclinedError is returned when the risk engine declines an attempt.
+// It matches ErrRiskDeclined with errors.Is.
+type RiskDeclinedError struct {
+	Decision *models.RiskDecision
+}
+
+func (e *RiskDeclinedError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrRiskDeclined, e.Decision.Reason)
+}
+
+func (e *RiskDeclinedError) Unwrap() error {
+	return ErrRiskDeclined
+}
+
+// SetLimits sets a wallet's own daily and monthly limits. Nil falls back to
+// the service-wide limit.
+func (s *WalletService) SetLimits(ctx context.Context, walletID uuid.UUID, daily, monthly *money.Amount) (*models.Wallet, error) {
+	for _, limit := range []*money.Amount{daily, monthly} {
+		if limit != nil && limit.Sign() < 0 {
+			return nil, ErrInvalidAmount
+		}
+	}
+	if err := s.store.Wallets.UpdateLimits(ctx, walletID, daily, monthly); err != nil {
+		return nil, err
+	}
+	return s.store.Wallets.GetByID(ctx, walletID)
+}
+
+// Available returns the part of a wallet's balance that no active hold
+// reserves.
+func (s *WalletService) Available(ctx context.Context, walletID uuid.UUID) (money.Amount, error) {
+	wallet, err := s.store.Wallets.GetByID(ctx, walletID)
+	if err != nil {
+		return money.Zero, err
+	}
+	return available(ctx, s.store, wallet, s.now())
+}
+
+// RiskDecisions returns a page of recorded risk decisions, newest first,
+// optionally only those with the given outcomes.
+func (s *WalletService) RiskDecisions(ctx context.Context, outcomes []string, limit, offset int) ([]models.RiskDecision, error) {
+	return s.store.Risk.List(ctx, outcomes, limit, offset)
+}
+
+func available(ctx context.Context, tx *repository.Store, wallet *models.Wallet, now time.Time) (money.Amount, error) {
+	holds, err := tx.Holds.Active(ctx, wallet.ID, now)
+	if err != nil {
+		return money.Zero, err
+	}
+	free := wallet.Balance
+	for _, h := range holds {
+		free = free.Sub(h.Amount)
+	}
+	return free, nil
+}
+
+// authorize checks that amount may leave wallet: the funds are available,
+// the wallet's limits allow it and the risk engine does not decline it.
+// The wallet must be locked by the caller's database transaction.
+func (s *WalletService) authorize(ctx context.Context, tx *repository.Store, wallet *models.Wallet, op risk.Operation, amount money.Amount) error {
+	now := s.now()
+	free, err := available(ctx, tx, wallet, now)
+	if err != nil {
+		return err
+	}
+	if free.Cmp(amount) < 0 {
+		return repository.ErrInsufficientFunds
+	}
+
+	history := outgoing{tx: tx, now: now}
+	if err := s.checkLimits(ctx, history, wallet, amount, now); err != nil {
+		return err
+	}
+	return s.assess(ctx, tx, history, wallet, op, amount, now)
+}
+
+func (s *WalletService) checkLimits(ctx context.Context, history outgoing, wallet *models.Wallet, amount money.Amount, now time.Time) error {
+	daily, monthly := s.Limits.Daily, s.Limits.Monthly
+	if wallet.DailyLimit != nil {
+		daily = *wallet.DailyLimit
+	}
+	if wallet.MonthlyLimit != nil {
+		monthly = *wallet.MonthlyLimit
+	}
+	if daily.IsZero() && monthly.IsZero() && s.Limits.DailyTransactions <= 0 {
+		return nil
+	}
+
+	now = now.UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	outflows, err := history.Outflows(ctx, wallet.ID, monthStart)
+	if err != nil {
+		return err
+	}
+
+	today, month := amount, amount
+	count := 1
+	for _, o := range outflows {
+		month = month.Add(o.Amount)
+		if !o.At.Before(dayStart) {
+			today = today.Add(o.Amount)
+			count++
+		}
+	}
+	switch {
+	case s.Limits.DailyTransactions > 0 && count > s.Limits.DailyTransactions:
+		return ErrTransactionLimitExceeded
+	case !daily.IsZero() && today.Cmp(daily) > 0:
+		return fmt.Errorf("%w: %s of %s %s used", ErrDailyLimitExceeded, today.Sub(amount), daily, wallet.Currency)
+	case !monthly.IsZero() && month.Cmp(monthly) > 0:
+		return fmt.Errorf("%w: %s of %s %s used", ErrMonthlyLimitExceeded, month.Sub(amount), monthly, wallet.Currency)
+	}
+	return nil
+}
+
+// assess runs the risk engine and records its decision in tx. A decline is
+// returned as a RiskDeclinedError, which rolls tx back; the caller records
+// it afterwards with recordDeclined.
+func (s *WalletService) assess(ctx context.Context, tx *repository.Store, history risk.History, wallet *models.Wallet, op risk.Operation, amount money.Amount, now time.Time) error {
+	if s.Risk == nil {
+		return nil
+	}
+	decision, err := s.Risk.Evaluate(ctx, history, risk.Attempt{
+		WalletID:  wallet.ID,
+		Operation: op,
+		Amount:    amount,
+		Currency:  wallet.Currency,
+		At:        now,
+	})
+	if err != nil {
+		return err
+	}
+
+	record := &models.RiskDecision{
+		ID:        uuid.New(),
+		WalletID:  wallet.ID,
+		Operation: string(op),
+		Amount:    amount,
+		Currency:  wallet.Currency,
+		Outcome:   string(decision.Outcome),
+		Rule:      decision.Rule,
+		Reason:    decision.Reason,
+		CreatedAt: now,
+	}
+	if decision.Outcome == risk.Decline {
+		return &RiskDeclinedError{Decision: record}
+	}
+	return tx.Risk.Record(ctx, record)
+}
+
+// recordDeclined stores the decision behind a RiskDeclinedError once the
+// declined attempt's transaction has rolled back.
+func (s *WalletService) recordDeclined(ctx context.Context, err error) {
+	var declined *RiskDeclinedError
+	if !errors.As(err, &declined) {
+		return
+	}
+	if err := s.store.Risk.Record(ctx, declined.Decision); err != nil {
+		log.Printf("failed to record declined %s on wallet %s: %v", declined.Decision.Operation, declined.Decision.WalletID, err)
+	}
+}
+
+// outgoing is a wallet's posted outflows together with the funds its
+// active holds reserve, which count against limits and risk rules as soon
+// as they are held.
+type outgoing struct {
+	tx  *repository.Store
+	now time.Time
+}
+
+func (o outgoing) Outflows(ctx context.Context, walletID uuid.UUID, since time.Time) ([]models.Outflow, error) {
+	outflows, err := o.tx.Ledger.Outflows(ctx, walletID, since)
+	if err != nil {
+		return nil, err
+	}
+	holds, err := o.tx.Holds.Active(ctx, walletID, o.now)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range holds {
+		if !h.CreatedAt.Before(since) {
+			outflows = append(outflows, models.Outflow{ID: h.ID, Amount: h.Amount, At: h.CreatedAt})
+		}
+	}
+	sort.Slice(outflows, func(i, j int) bool { return outflows[i].At.Before(outflows[j].At) })
+	return outflows, nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/internal/risk"
+	"wallet-app/pkg/money"
+)
+
+func withdraw(s *WalletService, w *models.Wallet, amount string) error {
+	_, err := s.ProcessTransaction(context.Background(), w.ID, money.MustParse(amount), models.Withdraw)
+	return err
+}
+
+func TestDailyTransactionLimit(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	s.Limits = Limits{DailyTransactions: 3}
+	w := fundedWallet(t, s, "USD", "100")
+	other := fundedWallet(t, s, "USD", "0")
+
+	if err := withdraw(s, w, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Reserve(ctx, w.ID, money.MustParse("1"), 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Transfer(ctx, TransferRequest{FromWalletID: w.ID, ToWalletID: other.ID, Amount: money.MustParse("1")}, Idempotency{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := withdraw(s, w, "1"); !errors.Is(err, ErrTransactionLimitExceeded) {
+		t.Errorf("fourth payment: got %v, want ErrTransactionLimitExceeded", err)
+	}
+
+	// Deposits are never limited
+	if _, err := s.ProcessTransaction(ctx, w.ID, money.MustParse("5"), models.Deposit); err != nil {
+		t.Errorf("deposit: %v", err)
+	}
+}
+
+func TestSpendingLimits(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	s.Limits = Limits{Daily: money.MustParse("100"), Monthly: money.MustParse("150")}
+	w := fundedWallet(t, s, "USD", "1000")
+
+	if err := withdraw(s, w, "60"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Reserve(ctx, w.ID, money.MustParse("30"), 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := withdraw(s, w, "10.01"); !errors.Is(err, ErrDailyLimitExceeded) {
+		t.Errorf("over the daily limit: got %v, want ErrDailyLimitExceeded", err)
+	}
+	if err := withdraw(s, w, "10"); err != nil {
+		t.Errorf("up to the daily limit: %v", err)
+	}
+
+	// The wallet's own limits replace the defaults
+	daily := money.MustParse("500")
+	updated, err := s.SetLimits(ctx, w.ID, &daily, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.DailyLimit == nil || *updated.DailyLimit != daily || updated.MonthlyLimit != nil {
+		t.Errorf("limits = %v, %v", updated.DailyLimit, updated.MonthlyLimit)
+	}
+	if err := withdraw(s, w, "50.01"); !errors.Is(err, ErrMonthlyLimitExceeded) {
+		t.Errorf("over the monthly limit: got %v, want ErrMonthlyLimitExceeded", err)
+	}
+	if err := withdraw(s, w, "50"); err != nil {
+		t.Errorf("up to the monthly limit: %v", err)
+	}
+
+	negative := money.MustParse("-1")
+	if _, err := s.SetLimits(ctx, w.ID, &negative, nil); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("negative limit: got %v, want ErrInvalidAmount", err)
+	}
+}
+
+func TestRiskDecisionsArePersisted(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	s.Risk = risk.NewEngine(
+		risk.Velocity{Max: 6, Window: 10 * time.Minute},
+		risk.AmountSpike{Factor: money.MustParseRate("3"), Lookback: 24 * time.Hour, MinHistory: 3},
+	)
+	w := fundedWallet(t, s, "USD", "1000")
+
+	for i := 0; i < 5; i++ {
+		if err := withdraw(s, w, "10"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 40 is four times the average of 10, so it goes through for review
+	if err := withdraw(s, w, "40"); err != nil {
+		t.Fatalf("spike: %v", err)
+	}
+	// and a seventh withdrawal in ten minutes is declined
+	err := withdraw(s, w, "10")
+	var declined *RiskDeclinedError
+	if !errors.Is(err, ErrRiskDeclined) || !errors.As(err, &declined) || declined.Decision.Rule != "velocity" {
+		t.Fatalf("seventh withdrawal: got %v, want a velocity decline", err)
+	}
+	if got := balanceOf(t, s, w.ID); got != "910" {
+		t.Errorf("balance = %s, want 910", got)
+	}
+
+	flagged, err := s.RiskDecisions(ctx, []string{"review", "decline"}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flagged) != 2 {
+		t.Fatalf("flagged decisions = %+v, want 2", flagged)
+	}
+	outcomes := map[string]models.RiskDecision{}
+	for _, d := range flagged {
+		outcomes[d.Outcome] = d
+	}
+	if d := outcomes["review"]; d.Rule != "amount_spike" || d.Amount.String() != "40" || d.Reason == "" {
+		t.Errorf("review decision = %+v", d)
+	}
+	if d := outcomes["decline"]; d.Rule != "velocity" || d.WalletID != w.ID || d.Operation != "withdraw" {
+		t.Errorf("decline decision = %+v", d)
+	}
+
+	all, err := s.RiskDecisions(ctx, nil, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 7 {
+		t.Errorf("%d decisions recorded, want one per withdrawal", len(all))
+	}
+}
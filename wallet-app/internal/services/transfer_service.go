@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/internal/domain/repository"
+	"wallet-app/internal/risk"
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSameWallet       = errors.New("cannot transfer to the same wallet")
+	ErrFXQuoteRequired  = errors.New("wallets have different currencies; an FX quote is required")
+	ErrFXQuoteInvalid   = errors.New("FX quote is expired or invalid")
+	ErrFXQuoteNotNeeded = errors.New("FX quote given for a same-currency transfer")
+	// ErrIdempotencyKeyReused means the key was already used for a
+	// different request.
+	ErrIdempotencyKeyReused = errors.New("Idempotency-Key was already used for a different request")
+	// ErrRequestInProgress means a request with the same key has not
+	// finished yet.
+	ErrRequestInProgress = errors.New("a request with this Idempotency-Key is still in progress")
+)
+
+type TransferRequest struct {
+	FromWalletID uuid.UUID `json:"from_wallet_id"`
+	ToWalletID   uuid.UUID `json:"to_wallet_id"`
+	// Amount is in the source wallet's currency.
+	Amount      money.Amount `json:"amount"`
+	Description string       `json:"description"`
+	// FXQuoteID is a quote from QuoteFX, which converts the amount into
+	// the destination currency.
+	FXQuoteID *uuid.UUID `json:"fx_quote_id,omitempty"`
+}
+
+// Idempotency identifies a request that must run at most once.
+type Idempotency struct {
+	Scope string
+	Key   string
+}
+
+// Transfer moves money between two wallets in one journal entry. With a
+// non-empty idem.Key, repeating the same request returns the original
+// transaction and replayed is true; nothing is posted again. Only
+// successful transfers are remembered, so a failed one can be retried.
+func (s *WalletService) Transfer(ctx context.Context, req TransferRequest, idem Idempotency) (txn *models.Transaction, replayed bool, err error) {
+	if req.FromWalletID == req.ToWalletID {
+		return nil, false, ErrSameWallet
+	}
+	if req.Amount.Sign() <= 0 {
+		return nil, false, ErrInvalidAmount
+	}
+
+	err = s.store.Transaction(ctx, func(tx *repository.Store) error {
+		var key *models.IdempotencyKey
+		if idem.Key != "" {
+			hash, err := requestHash(req)
+			if err != nil {
+				return err
+			}
+			key = &models.IdempotencyKey{Scope: idem.Scope, Key: idem.Key, RequestHash: hash}
+			existing, err := tx.Idempotency.Reserve(ctx, key)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				txn, err = replay(existing, hash)
+				replayed = err == nil
+				return err
+			}
+		}
+
+		txn, err = s.transfer(ctx, tx, req)
+		if err != nil || key == nil {
+			return err
+		}
+		body, err := json.Marshal(txn)
+		if err != nil {
+			return err
+		}
+		return tx.Idempotency.Complete(ctx, key.Scope, key.Key, http.StatusCreated, body)
+	})
+	if err != nil {
+		s.recordDeclined(ctx, err)
+		return nil, false, err
+	}
+	return txn, replayed, nil
+}
+
+func (s *WalletService) transfer(ctx context.Context, tx *repository.Store, req TransferRequest) (*models.Transaction, error) {
+	wallets, err := tx.Wallets.Lock(ctx, req.FromWalletID, req.ToWalletID)
+	if err != nil {
+		return nil, err
+	}
+	from, to := &wallets[0], &wallets[1]
+	if from.ID != req.FromWalletID {
+		from, to = to, from
+	}
+	// System wallets only move money through deposits, withdrawals and
+	// conversions
+	if from.Kind != models.UserWallet || to.Kind != models.UserWallet {
+		return nil, repository.ErrWalletNotFound
+	}
+	if err := checkPrecision(req.Amount, from.Currency); err != nil {
+		return nil, err
+	}
+
+	txn := &models.Transaction{
+		Type:        models.Transfer,
+		Description: req.Description,
+		Postings: []models.Posting{
+			{WalletID: from.ID, Direction: models.Debit, Amount: req.Amount},
+		},
+	}
+	if txn.Description == "" {
+		txn.Description = "transfer"
+	}
+
+	if from.Currency == to.Currency {
+		if req.FXQuoteID != nil {
+			return nil, ErrFXQuoteNotNeeded
+		}
+		txn.Postings = append(txn.Postings, models.Posting{WalletID: to.ID, Direction: models.Credit, Amount: req.Amount})
+	} else if err := s.addConversion(ctx, tx, txn, req, from, to); err != nil {
+		return nil, err
+	}
+
+	if err := s.authorize(ctx, tx, from, risk.Transfer, req.Amount); err != nil {
+		return nil, err
+	}
+	if err := post(ctx, tx, txn); err != nil {
+		return nil, err
+	}
+
+	received := txn.Postings[len(txn.Postings)-1]
+	err = publish(ctx, tx, models.EventTransferCompleted, txn.ID, TransferCompleted{
+		TransactionID:    txn.ID,
+		FromWalletID:     from.ID,
+		ToWalletID:       to.ID,
+		Amount:           req.Amount,
+		Currency:         from.Currency,
+		ReceivedAmount:   received.Amount,
+		ReceivedCurrency: received.Currency,
+		ExchangeRate:     txn.ExchangeRate,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return txn, nil
+}
+
+// addConversion completes a cross-currency transfer with the FX quote's
+// legs, using up the quote. Only an unused, unexpired quote issued to the
+// source wallet's owner for this currency pair will do.
+func (s *WalletService) addConversion(ctx context.Context, tx *repository.Store, txn *models.Transaction, req TransferRequest, from, to *models.Wallet) error {
+	if req.FXQuoteID == nil {
+		return ErrFXQuoteRequired
+	}
+	q, err := tx.FXQuotes.Lock(ctx, *req.FXQuoteID)
+	if errors.Is(err, repository.ErrFXQuoteNotFound) {
+		return fmt.Errorf("%w: unknown quote", ErrFXQuoteInvalid)
+	}
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	switch {
+	case q.UserID != from.UserID:
+		// Someone else's quote is as good as unknown
+		return fmt.Errorf("%w: unknown quote", ErrFXQuoteInvalid)
+	case q.FromCurrency != from.Currency || q.ToCurrency != to.Currency:
+		return fmt.Errorf("%w: quote is for %s/%s", ErrFXQuoteInvalid, q.FromCurrency, q.ToCurrency)
+	case q.UsedAt != nil:
+		return fmt.Errorf("%w: quote was already used", ErrFXQuoteInvalid)
+	case !now.Before(q.ExpiresAt):
+		return fmt.Errorf("%w: quote expired", ErrFXQuoteInvalid)
+	}
+	q.UsedAt = &now
+	if err := tx.FXQuotes.Save(ctx, q); err != nil {
+		return err
+	}
+
+	// The converted amount is rounded once, half to even, to the
+	// destination currency's minor unit
+	exp, err := money.Exponent(to.Currency)
+	if err != nil {
+		return err
+	}
+	converted, err := req.Amount.Convert(q.Rate, exp, money.HalfEven)
+	if err != nil {
+		return err
+	}
+	if converted.Sign() <= 0 {
+		return fmt.Errorf("%w: converted amount rounds to zero", ErrInvalidAmount)
+	}
+
+	// Each currency balances through its system wallet, which takes the
+	// source money in and pays the converted amount out
+	fromSystem, err := tx.Wallets.SystemWallet(ctx, from.Currency)
+	if err != nil {
+		return err
+	}
+	toSystem, err := tx.Wallets.SystemWallet(ctx, to.Currency)
+	if err != nil {
+		return err
+	}
+	txn.ExchangeRate, txn.QuoteID = &q.Rate, q.ID.String()
+	txn.Postings = append(txn.Postings,
+		models.Posting{WalletID: fromSystem.ID, Direction: models.Credit, Amount: req.Amount},
+		models.Posting{WalletID: toSystem.ID, Direction: models.Debit, Amount: converted},
+		models.Posting{WalletID: to.ID, Direction: models.Credit, Amount: converted},
+	)
+	return nil
+}
+
+func requestHash(req TransferRequest) (string, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func replay(existing *models.IdempotencyKey, hash string) (*models.Transaction, error) {
+	if existing.RequestHash != hash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if len(existing.Response) == 0 {
+		return nil, ErrRequestInProgress
+	}
+	var txn models.Transaction
+	if err := json.Unmarshal(existing.Response, &txn); err != nil {
+		return nil, err
+	}
+	return &txn, nil
+}
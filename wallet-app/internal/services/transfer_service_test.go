@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/internal/domain/repository"
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestService(t *testing.T) *WalletService {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+uuid.NewString()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(&models.Wallet{}, &models.Transaction{}, &models.Posting{}, &models.IdempotencyKey{}, &models.Hold{}, &models.RiskDecision{},
+		&models.OutboxEvent{}, &models.WebhookEndpoint{}, &models.WebhookDelivery{}, &models.FXQuote{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	return NewWalletService(repository.NewStore(db))
+}
+
+// fundedWallet creates a wallet holding amount.
+func fundedWallet(t *testing.T, s *WalletService, currency string, amount string) *models.Wallet {
+	t.Helper()
+	ctx := context.Background()
+	w, err := s.CreateWallet(ctx, uuid.New(), currency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amount != "0" {
+		if _, err := s.ProcessTransaction(ctx, w.ID, money.MustParse(amount), models.Deposit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w
+}
+
+// quote issues owner an FX quote at rate.
+func quote(t *testing.T, s *WalletService, owner uuid.UUID, from, to, rate string) *uuid.UUID {
+	t.Helper()
+	s.Rates = StaticRates{from + "/" + to: money.MustParseRate(rate)}
+	q, err := s.QuoteFX(context.Background(), owner, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &q.ID
+}
+
+func balanceOf(t *testing.T, s *WalletService, id uuid.UUID) string {
+	t.Helper()
+	w, err := s.GetWallet(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Balance.String()
+}
+
+func TestTransfer(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	from := fundedWallet(t, s, "USD", "100")
+	to := fundedWallet(t, s, "USD", "0")
+
+	txn, replayed, err := s.Transfer(ctx, TransferRequest{FromWalletID: from.ID, ToWalletID: to.ID, Amount: money.MustParse("40")}, Idempotency{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed || txn.Type != models.Transfer || len(txn.Postings) != 2 {
+		t.Errorf("transfer = %+v, replayed %v", txn, replayed)
+	}
+	if got := balanceOf(t, s, from.ID); got != "60" {
+		t.Errorf("source balance = %v, want 60", got)
+	}
+	if got := balanceOf(t, s, to.ID); got != "40" {
+		t.Errorf("destination balance = %v, want 40", got)
+	}
+
+	history, err := s.GetTransactions(ctx, to.ID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].TransactionID != txn.ID || history[0].Amount.String() != "40" {
+		t.Errorf("destination history = %+v", history)
+	}
+}
+
+func TestTransferRejections(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	usd := fundedWallet(t, s, "USD", "50")
+	other := fundedWallet(t, s, "USD", "0")
+	eur := fundedWallet(t, s, "EUR", "0")
+	valid := quote(t, s, usd.UserID, "USD", "EUR", "0.9")
+
+	tests := []struct {
+		name string
+		req  TransferRequest
+		want error
+	}{
+		{"same wallet", TransferRequest{FromWalletID: usd.ID, ToWalletID: usd.ID, Amount: money.MustParse("1")}, ErrSameWallet},
+		{"zero amount", TransferRequest{FromWalletID: usd.ID, ToWalletID: other.ID}, ErrInvalidAmount},
+		{"fraction of a cent", TransferRequest{FromWalletID: usd.ID, ToWalletID: other.ID, Amount: money.MustParse("0.001")}, ErrAmountPrecision},
+		{"overdraft", TransferRequest{FromWalletID: usd.ID, ToWalletID: other.ID, Amount: money.MustParse("50.5")}, repository.ErrInsufficientFunds},
+		{"unknown wallet", TransferRequest{FromWalletID: usd.ID, ToWalletID: uuid.New(), Amount: money.MustParse("1")}, repository.ErrWalletNotFound},
+		{"cross currency without quote", TransferRequest{FromWalletID: usd.ID, ToWalletID: eur.ID, Amount: money.MustParse("1")}, ErrFXQuoteRequired},
+		{"quote for same currency", TransferRequest{FromWalletID: usd.ID, ToWalletID: other.ID, Amount: money.MustParse("1"), FXQuoteID: valid}, ErrFXQuoteNotNeeded},
+	}
+	for _, tt := range tests {
+		if _, _, err := s.Transfer(ctx, tt.req, Idempotency{}); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if got := balanceOf(t, s, usd.ID); got != "50" {
+		t.Errorf("balance after rejected transfers = %v, want 50", got)
+	}
+
+	if _, err := s.CreateWallet(ctx, uuid.New(), "XYZ"); !errors.Is(err, ErrUnsupportedCurrency) {
+		t.Errorf("unknown currency: got %v, want ErrUnsupportedCurrency", err)
+	}
+}
+
+func TestTransferWithFXQuote(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	usd := fundedWallet(t, s, "USD", "100")
+	eur := fundedWallet(t, s, "EUR", "0")
+
+	q := quote(t, s, usd.UserID, "USD", "EUR", "0.9123")
+	txn, _, err := s.Transfer(ctx, TransferRequest{FromWalletID: usd.ID, ToWalletID: eur.ID, Amount: money.MustParse("10"), FXQuoteID: q}, Idempotency{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txn.ExchangeRate.String() != "0.9123" || txn.QuoteID != q.String() || len(txn.Postings) != 4 {
+		t.Errorf("transfer = %+v", txn)
+	}
+	if got := balanceOf(t, s, usd.ID); got != "90" {
+		t.Errorf("USD balance = %v, want 90", got)
+	}
+	if got := balanceOf(t, s, eur.ID); got != "9.12" {
+		t.Errorf("EUR balance = %v, want 9.12", got)
+	}
+
+	// Yen have no minor unit, so 10.01 × 151.235 = 1513.86... lands on 1514
+	jpy := fundedWallet(t, s, "JPY", "0")
+	q = quote(t, s, usd.UserID, "USD", "JPY", "151.235")
+	if _, _, err := s.Transfer(ctx, TransferRequest{FromWalletID: usd.ID, ToWalletID: jpy.ID, Amount: money.MustParse("10.01"), FXQuoteID: q}, Idempotency{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(t, s, jpy.ID); got != "1514" {
+		t.Errorf("JPY balance = %v, want 1514", got)
+	}
+	if got := balanceOf(t, s, usd.ID); got != "79.99" {
+		t.Errorf("USD balance = %v, want 79.99", got)
+	}
+
+	report, err := s.store.Ledger.CheckConsistency(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("ledger inconsistent after FX transfer: %+v", report)
+	}
+}
+
+func TestTransferFXQuoteChecks(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	usd := fundedWallet(t, s, "USD", "100")
+	eur := fundedWallet(t, s, "EUR", "0")
+	mallory := fundedWallet(t, s, "USD", "100")
+
+	unknown := uuid.New()
+	expired := quote(t, s, usd.UserID, "USD", "EUR", "0.9")
+	s.now = func() time.Time { return time.Now().Add(DefaultQuoteTTL) }
+	fresh := quote(t, s, usd.UserID, "USD", "EUR", "0.9")
+	wrongPair := quote(t, s, usd.UserID, "USD", "JPY", "150")
+	othersQuote := quote(t, s, mallory.UserID, "USD", "EUR", "0.9")
+
+	transfer := func(from *models.Wallet, id *uuid.UUID) error {
+		_, _, err := s.Transfer(ctx, TransferRequest{FromWalletID: from.ID, ToWalletID: eur.ID, Amount: money.MustParse("10"), FXQuoteID: id}, Idempotency{})
+		return err
+	}
+	for name, err := range map[string]error{
+		"unknown quote":          transfer(usd, &unknown),
+		"expired quote":          transfer(usd, expired),
+		"quote for another pair": transfer(usd, wrongPair),
+		"another user's quote":   transfer(usd, othersQuote),
+		"quote used by another":  transfer(mallory, fresh),
+	} {
+		if !errors.Is(err, ErrFXQuoteInvalid) {
+			t.Errorf("%s: got %v, want ErrFXQuoteInvalid", name, err)
+		}
+	}
+
+	if err := transfer(usd, fresh); err != nil {
+		t.Fatal(err)
+	}
+	if err := transfer(usd, fresh); !errors.Is(err, ErrFXQuoteInvalid) {
+		t.Errorf("reused quote: got %v, want ErrFXQuoteInvalid", err)
+	}
+	if got := balanceOf(t, s, eur.ID); got != "9" {
+		t.Errorf("EUR balance = %v, want 9 from the one valid transfer", got)
+	}
+
+	s.Rates = StaticRates{}
+	if _, err := s.QuoteFX(ctx, usd.UserID, "USD", "GBP"); !errors.Is(err, ErrFXRateUnavailable) {
+		t.Errorf("quote without a rate: got %v, want ErrFXRateUnavailable", err)
+	}
+	if _, err := s.QuoteFX(ctx, usd.UserID, "USD", "usd"); !errors.Is(err, ErrFXQuoteNotNeeded) {
+		t.Errorf("same-currency quote: got %v, want ErrFXQuoteNotNeeded", err)
+	}
+}
+
+func TestParseRates(t *testing.T) {
+	rates, err := ParseRates(" usd/eur=0.92, EUR/USD=1.087 ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 2 || rates["USD/EUR"].String() != "0.92" || rates["EUR/USD"].String() != "1.087" {
+		t.Errorf("rates = %v", rates)
+	}
+	for _, bad := range []string{"USD=1", "USD/EUR", "USD/EUR=-1", "USD/EUR=abc"} {
+		if _, err := ParseRates(bad); err == nil {
+			t.Errorf("ParseRates(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestTransferIdempotency(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	from := fundedWallet(t, s, "USD", "100")
+	to := fundedWallet(t, s, "USD", "0")
+	req := TransferRequest{FromWalletID: from.ID, ToWalletID: to.ID, Amount: money.MustParse("25")}
+	key := Idempotency{Scope: "user-1", Key: "retry-me"}
+
+	first, replayed, err := s.Transfer(ctx, req, key)
+	if err != nil || replayed {
+		t.Fatalf("first attempt: %v, replayed %v", err, replayed)
+	}
+	second, replayed, err := s.Transfer(ctx, req, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !replayed || second.ID != first.ID || len(second.Postings) != 2 {
+		t.Errorf("retry = %+v, replayed %v; want the original transaction %s", second, replayed, first.ID)
+	}
+	if got := balanceOf(t, s, from.ID); got != "75" {
+		t.Errorf("retry charged again: balance %v, want 75", got)
+	}
+
+	changed := req
+	changed.Amount = money.MustParse("30")
+	if _, _, err := s.Transfer(ctx, changed, key); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("same key, different request: got %v, want ErrIdempotencyKeyReused", err)
+	}
+
+	// Keys are scoped, so another user may use the same one
+	if _, replayed, err := s.Transfer(ctx, req, Idempotency{Scope: "user-2", Key: "retry-me"}); err != nil || replayed {
+		t.Errorf("other scope: %v, replayed %v", err, replayed)
+	}
+
+	// A failed transfer is not remembered and can be retried
+	overdraft := TransferRequest{FromWalletID: from.ID, ToWalletID: to.ID, Amount: money.MustParse("1000")}
+	failKey := Idempotency{Scope: "user-1", Key: "fails"}
+	if _, _, err := s.Transfer(ctx, overdraft, failKey); !errors.Is(err, repository.ErrInsufficientFunds) {
+		t.Fatalf("overdraft: got %v", err)
+	}
+	if _, err := s.ProcessTransaction(ctx, from.ID, money.MustParse("1000"), models.Deposit); err != nil {
+		t.Fatal(err)
+	}
+	if _, replayed, err := s.Transfer(ctx, overdraft, failKey); err != nil || replayed {
+		t.Errorf("retry after failure: %v, replayed %v", err, replayed)
+	}
+}
@@ -1,43 +1,147 @@
 package services
 
 import (
-    "context"
-    "wallet-app/internal/domain/models"
-    "wallet-app/internal/domain/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
-    "github.com/google/uuid"
+	"wallet-app/internal/domain/models"
+	"wallet-app/internal/domain/repository"
+	"wallet-app/internal/risk"
+	"wallet-app/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidAmount       = errors.New("amount must be positive")
+	ErrAmountPrecision     = errors.New("amount has more decimal places than the currency allows")
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
 )
 
 type WalletService struct {
-    repo *repository.WalletRepository
+	store *repository.Store
+	// Limits applies to every wallet that does not set its own.
+	Limits Limits
+	// Risk, when set, assesses every withdrawal, transfer and hold.
+	Risk *risk.Engine
+	// Rates prices FX quotes; without it no quotes are issued.
+	Rates RateSource
+	// QuoteTTL is how long an FX quote lasts; zero means DefaultQuoteTTL.
+	QuoteTTL time.Duration
+	now      func() time.Time
 }
 
-func NewWalletService(repo *repository.WalletRepository) *WalletService {
-    return &WalletService{repo: repo}
+func NewWalletService(store *repository.Store) *WalletService {
+	return &WalletService{store: store, now: time.Now}
 }
 
 func (s *WalletService) CreateWallet(ctx context.Context, userID uuid.UUID, currency string) (*models.Wallet, error) {
-    wallet := &models.Wallet{
-        ID:       uuid.New(),
-        UserID:   userID,
-        Currency: currency,
-        Balance:  0,
-    }
-
-    if err := s.repo.Create(ctx, wallet); err != nil {
-        return nil, err
-    }
-
-    return wallet, nil
-}
-
-func (s *WalletService) ProcessTransaction(ctx context.Context, walletID uuid.UUID, amount float64, txType models.TransactionType) error {
-    var finalAmount float64
-    if txType == models.Withdraw {
-        finalAmount = -amount
-    } else {
-        finalAmount = amount
-    }
-
-    return s.repo.UpdateBalance(ctx, walletID, finalAmount)
-}
\ No newline at end of file
+	if _, err := money.Exponent(currency); err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedCurrency, currency)
+	}
+	wallet := &models.Wallet{
+		ID:       uuid.New(),
+		UserID:   userID,
+		Kind:     models.UserWallet,
+		Currency: strings.ToUpper(currency),
+	}
+
+	err := s.store.Transaction(ctx, func(tx *repository.Store) error {
+		if err := tx.Wallets.Create(ctx, wallet); err != nil {
+			return err
+		}
+		return publish(ctx, tx, models.EventWalletCreated, wallet.ID, wallet)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet, nil
+}
+
+func (s *WalletService) GetWallet(ctx context.Context, walletID uuid.UUID) (*models.Wallet, error) {
+	return s.store.Wallets.GetByID(ctx, walletID)
+}
+
+// OwnedWallet returns the wallet if it belongs to userID. Anyone else's
+// wallet, system wallets included, is reported as not found, so that
+// wallet IDs cannot be probed.
+func (s *WalletService) OwnedWallet(ctx context.Context, walletID, userID uuid.UUID) (*models.Wallet, error) {
+	wallet, err := s.store.Wallets.GetByID(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if wallet.Kind != models.UserWallet || wallet.UserID != userID {
+		return nil, repository.ErrWalletNotFound
+	}
+	return wallet, nil
+}
+
+// ProcessTransaction posts a deposit or withdrawal. The other side of the
+// entry is the system wallet of the wallet's currency. Withdrawals must fit
+// the available balance and the wallet's limits and pass the risk checks.
+func (s *WalletService) ProcessTransaction(ctx context.Context, walletID uuid.UUID, amount money.Amount, txType models.TransactionType) (*models.Transaction, error) {
+	if amount.Sign() <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	var txn *models.Transaction
+	err := s.store.Transaction(ctx, func(tx *repository.Store) error {
+		wallets, err := tx.Wallets.Lock(ctx, walletID)
+		if err != nil {
+			return err
+		}
+		wallet := &wallets[0]
+		if err := checkPrecision(amount, wallet.Currency); err != nil {
+			return err
+		}
+		if txType == models.Withdraw {
+			if err := s.authorize(ctx, tx, wallet, risk.Withdraw, amount); err != nil {
+				return err
+			}
+		}
+		system, err := tx.Wallets.SystemWallet(ctx, wallet.Currency)
+		if err != nil {
+			return err
+		}
+
+		debit, credit := system.ID, wallet.ID
+		if txType == models.Withdraw {
+			debit, credit = wallet.ID, system.ID
+		}
+		txn = &models.Transaction{
+			Type:        txType,
+			Description: string(txType),
+			Postings: []models.Posting{
+				{WalletID: debit, Direction: models.Debit, Amount: amount},
+				{WalletID: credit, Direction: models.Credit, Amount: amount},
+			},
+		}
+		return post(ctx, tx, txn)
+	})
+	if err != nil {
+		s.recordDeclined(ctx, err)
+		return nil, err
+	}
+	return txn, nil
+}
+
+// GetTransactions returns a page of the wallet's history, newest first.
+func (s *WalletService) GetTransactions(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]models.WalletTransaction, error) {
+	if _, err := s.store.Wallets.GetByID(ctx, walletID); err != nil {
+		return nil, err
+	}
+	return s.store.Ledger.ListByWallet(ctx, walletID, limit, offset)
+}
+
+// checkPrecision rejects amounts finer than the currency's minor unit, such
+// as a tenth of a cent.
+func checkPrecision(amount money.Amount, currency string) error {
+	if err := money.Check(amount, currency); err != nil {
+		return fmt.Errorf("%w: %s %s", ErrAmountPrecision, amount, currency)
+	}
+	return nil
+}
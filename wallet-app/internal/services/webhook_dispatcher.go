@@ -0,0 +1,221 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/internal/domain/repository"
+	"wallet-app/pkg/webhook"
+
+	"github.com/google/uuid"
+)
+
+// WebhookDispatcher moves events from the outbox to webhook endpoints. Each
+// event becomes one delivery per subscribed endpoint; a delivery is retried
+// with exponential backoff until it succeeds or runs out of attempts, when
+// it is dead-lettered.
+type WebhookDispatcher struct {
+	store  *repository.Store
+	client *http.Client
+	// Interval is the time between polls of the outbox and the queue.
+	Interval time.Duration
+	// MaxAttempts is how many times a delivery is tried before it is
+	// dead-lettered.
+	MaxAttempts int
+	// BaseBackoff is the wait after the first failure; it doubles with
+	// every further failure up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// BatchSize caps the events and deliveries handled per poll.
+	BatchSize int
+	logger    *log.Logger
+	now       func() time.Time
+}
+
+// envelope is the body of a webhook request.
+type envelope struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// deliveryLease is how long a claimed delivery is hidden from other
+// dispatchers while it is being sent.
+const deliveryLease = time.Minute
+
+func NewWebhookDispatcher(store *repository.Store, logger *log.Logger) *WebhookDispatcher {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &WebhookDispatcher{
+		store:       store,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		Interval:    5 * time.Second,
+		MaxAttempts: 8,
+		BaseBackoff: 30 * time.Second,
+		MaxBackoff:  6 * time.Hour,
+		BatchSize:   100,
+		logger:      logger,
+		now:         time.Now,
+	}
+}
+
+// Run polls until ctx is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Dispatch(ctx); err != nil {
+				d.logger.Printf("webhook dispatch failed: %v", err)
+			}
+		}
+	}
+}
+
+// Dispatch fans out new outbox events and sends the deliveries that are
+// due.
+func (d *WebhookDispatcher) Dispatch(ctx context.Context) error {
+	if err := d.fanOut(ctx); err != nil {
+		return err
+	}
+	return d.deliverDue(ctx)
+}
+
+// fanOut queues a delivery of every undispatched event to every endpoint
+// subscribed to it, and marks the events dispatched in the same
+// transaction.
+func (d *WebhookDispatcher) fanOut(ctx context.Context) error {
+	return d.store.Transaction(ctx, func(tx *repository.Store) error {
+		events, err := tx.Outbox.Undispatched(ctx, d.BatchSize)
+		if err != nil || len(events) == 0 {
+			return err
+		}
+		endpoints, err := tx.Webhooks.ActiveEndpoints(ctx)
+		if err != nil {
+			return err
+		}
+
+		now := d.now().UTC()
+		var deliveries []models.WebhookDelivery
+		ids := make([]uuid.UUID, len(events))
+		for i, e := range events {
+			ids[i] = e.ID
+			for _, ep := range endpoints {
+				if ep.Events.Matches(e.Type) {
+					deliveries = append(deliveries, models.WebhookDelivery{
+						ID:            uuid.New(),
+						EventID:       e.ID,
+						EndpointID:    ep.ID,
+						Status:        models.DeliveryPending,
+						NextAttemptAt: now,
+					})
+				}
+			}
+		}
+		if err := tx.Webhooks.CreateDeliveries(ctx, deliveries); err != nil {
+			return err
+		}
+		return tx.Outbox.MarkDispatched(ctx, ids, now)
+	})
+}
+
+func (d *WebhookDispatcher) deliverDue(ctx context.Context) error {
+	due, err := d.store.Webhooks.ClaimDue(ctx, d.now().UTC(), deliveryLease, d.BatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range due {
+		if err := d.deliver(ctx, &due[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliver makes one attempt and records its outcome.
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *models.WebhookDelivery) error {
+	endpoint, err := d.store.Webhooks.GetEndpoint(ctx, delivery.EndpointID)
+	if err != nil {
+		return err
+	}
+	if !endpoint.Active {
+		delivery.Status = models.DeliveryDead
+		delivery.LastError = "endpoint removed"
+		return d.store.Webhooks.SaveDelivery(ctx, delivery)
+	}
+	event, err := d.store.Outbox.GetByID(ctx, delivery.EventID)
+	if err != nil {
+		return err
+	}
+
+	status, sendErr := d.send(ctx, endpoint, delivery, event)
+	now := d.now().UTC()
+	delivery.Attempts++
+	delivery.LastStatusCode = status
+	delivery.UpdatedAt = now
+	switch {
+	case sendErr == nil:
+		delivery.Status = models.DeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	case delivery.Attempts >= d.MaxAttempts:
+		delivery.Status = models.DeliveryDead
+		delivery.LastError = sendErr.Error()
+		d.logger.Printf("webhook delivery %s to %s dead-lettered after %d attempts: %v", delivery.ID, endpoint.URL, delivery.Attempts, sendErr)
+	default:
+		delivery.LastError = sendErr.Error()
+		delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+	}
+	return d.store.Webhooks.SaveDelivery(ctx, delivery)
+}
+
+// send posts the signed event. Any 2xx response counts as delivered.
+func (d *WebhookDispatcher) send(ctx context.Context, endpoint *models.WebhookEndpoint, delivery *models.WebhookDelivery, event *models.OutboxEvent) (int, error) {
+	body, err := json.Marshal(envelope{ID: event.ID, Type: event.Type, CreatedAt: event.CreatedAt, Data: event.Payload})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.EventHeader, event.Type)
+	req.Header.Set(webhook.DeliveryHeader, delivery.ID.String())
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(endpoint.Secret, d.now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff is the wait after the given number of failed attempts.
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	wait := d.BaseBackoff
+	for i := 1; i < attempts && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.MaxBackoff {
+		wait = d.MaxBackoff
+	}
+	return wait
+}
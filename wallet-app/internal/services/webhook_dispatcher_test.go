@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/internal/domain/repository"
+	"wallet-app/pkg/money"
+	"wallet-app/pkg/webhook"
+)
+
+// receiver is a webhook endpoint that records what it is sent and answers
+// with status.
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	bodies   [][]byte
+	requests []*http.Request
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodies = append(r.bodies, body)
+	r.requests = append(r.requests, req)
+	w.WriteHeader(r.status)
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.bodies)
+}
+
+func newTestDispatcher(t *testing.T, s *WalletService, events ...string) (*WebhookDispatcher, *models.WebhookEndpoint, *receiver) {
+	t.Helper()
+	rcv := &receiver{status: http.StatusOK}
+	srv := httptest.NewServer(rcv)
+	t.Cleanup(srv.Close)
+
+	endpoint, err := NewWebhookService(s.store).RegisterEndpoint(context.Background(), srv.URL, "", events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewWebhookDispatcher(s.store, nil)
+	d.client = srv.Client()
+	return d, endpoint, rcv
+}
+
+func deliveriesOf(t *testing.T, s *WalletService, status models.DeliveryStatus) []models.WebhookDelivery {
+	t.Helper()
+	deliveries, err := s.store.Webhooks.ListDeliveries(context.Background(), status, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return deliveries
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	d, endpoint, rcv := newTestDispatcher(t, s, models.EventTransferCompleted)
+
+	from := fundedWallet(t, s, "USD", "100")
+	to := fundedWallet(t, s, "USD", "0")
+	if _, _, err := s.Transfer(ctx, TransferRequest{FromWalletID: from.ID, ToWalletID: to.ID, Amount: money.MustParse("25")}, Idempotency{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Dispatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the transfer matches the endpoint's filter, not the wallet
+	// creations or the deposit
+	if rcv.count() != 1 {
+		t.Fatalf("endpoint received %d requests, want 1", rcv.count())
+	}
+	req, body := rcv.requests[0], rcv.bodies[0]
+	if err := webhook.Verify(endpoint.Secret, req.Header.Get(webhook.SignatureHeader), body, time.Minute, time.Now()); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+	if req.Header.Get(webhook.EventHeader) != models.EventTransferCompleted {
+		t.Errorf("event header %q", req.Header.Get(webhook.EventHeader))
+	}
+
+	var got struct {
+		Type string            `json:"type"`
+		Data TransferCompleted `json:"data"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != models.EventTransferCompleted || got.Data.FromWalletID != from.ID || got.Data.ToWalletID != to.ID || got.Data.Amount.String() != "25" {
+		t.Errorf("unexpected payload %s", body)
+	}
+
+	if delivered := deliveriesOf(t, s, models.DeliveryDelivered); len(delivered) != 1 || delivered[0].Attempts != 1 {
+		t.Errorf("delivered: %+v", delivered)
+	}
+
+	// Events are fanned out once; dispatching again sends nothing new
+	if err := d.Dispatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if rcv.count() != 1 {
+		t.Errorf("endpoint received %d requests after a second dispatch, want 1", rcv.count())
+	}
+}
+
+func TestWebhookRetriesThenDeadLetters(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	d, _, rcv := newTestDispatcher(t, s, models.EventWalletCreated)
+	rcv.status = http.StatusServiceUnavailable
+	d.MaxAttempts = 3
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+	fundedWallet(t, s, "USD", "0")
+
+	wantWaits := []time.Duration{30 * time.Second, time.Minute}
+	for attempt := 1; attempt <= 3; attempt++ {
+		if err := d.Dispatch(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if rcv.count() != attempt {
+			t.Fatalf("attempt %d: endpoint received %d requests", attempt, rcv.count())
+		}
+		// Nothing is retried before the backoff runs out
+		if err := d.Dispatch(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if rcv.count() != attempt {
+			t.Fatalf("attempt %d retried early", attempt)
+		}
+		if attempt < 3 {
+			pending := deliveriesOf(t, s, models.DeliveryPending)
+			if len(pending) != 1 || pending[0].Attempts != attempt || pending[0].LastStatusCode != http.StatusServiceUnavailable {
+				t.Fatalf("attempt %d: pending %+v", attempt, pending)
+			}
+			if wait := pending[0].NextAttemptAt.Sub(now); wait != wantWaits[attempt-1] {
+				t.Errorf("attempt %d: backoff %v, want %v", attempt, wait, wantWaits[attempt-1])
+			}
+			now = pending[0].NextAttemptAt
+		}
+	}
+
+	dead := deliveriesOf(t, s, models.DeliveryDead)
+	if len(dead) != 1 || dead[0].Attempts != 3 || dead[0].LastError == "" {
+		t.Fatalf("dead letters: %+v", dead)
+	}
+
+	// A dead letter is left alone until someone asks for it again
+	now = now.Add(24 * time.Hour)
+	if err := d.Dispatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if rcv.count() != 3 {
+		t.Errorf("dead letter was retried")
+	}
+}
+
+func TestWebhookBackoffIsCapped(t *testing.T) {
+	d := NewWebhookDispatcher(nil, nil)
+	tests := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		5:  8 * time.Minute,
+		10: 256 * time.Minute,
+		11: 6 * time.Hour,
+		50: 6 * time.Hour,
+	}
+	for attempts, want := range tests {
+		if got := d.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestWebhookRedeliver(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	d, _, rcv := newTestDispatcher(t, s, models.EventWalletCreated)
+	rcv.status = http.StatusInternalServerError
+	d.MaxAttempts = 1
+	fundedWallet(t, s, "USD", "0")
+
+	if err := d.Dispatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	dead := deliveriesOf(t, s, models.DeliveryDead)
+	if len(dead) != 1 {
+		t.Fatalf("dead letters: %+v", dead)
+	}
+
+	rcv.status = http.StatusNoContent
+	redelivered, err := NewWebhookService(s.store).Redeliver(ctx, dead[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redelivered.Status != models.DeliveryPending || redelivered.Attempts != 0 {
+		t.Errorf("redelivered: %+v", redelivered)
+	}
+	if err := d.Dispatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if rcv.count() != 2 {
+		t.Fatalf("endpoint received %d requests, want 2", rcv.count())
+	}
+	// Both attempts carry the same delivery ID, so receivers can dedupe
+	if a, b := rcv.requests[0].Header.Get(webhook.DeliveryHeader), rcv.requests[1].Header.Get(webhook.DeliveryHeader); a != b {
+		t.Errorf("delivery IDs differ: %s, %s", a, b)
+	}
+	if delivered := deliveriesOf(t, s, models.DeliveryDelivered); len(delivered) != 1 {
+		t.Errorf("delivered: %+v", delivered)
+	}
+
+	if _, err := NewWebhookService(s.store).Redeliver(ctx, dead[0].EventID); !errors.Is(err, repository.ErrDeliveryNotFound) {
+		t.Errorf("redelivering an unknown ID: got %v", err)
+	}
+}
+
+func TestNoEventsFromRolledBackTransactions(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	w := fundedWallet(t, s, "USD", "10")
+
+	before := outboxSize(t, s)
+	if _, err := s.ProcessTransaction(ctx, w.ID, money.MustParse("50"), models.Withdraw); !errors.Is(err, repository.ErrInsufficientFunds) {
+		t.Fatalf("overdraft: got %v", err)
+	}
+	other := fundedWallet(t, s, "EUR", "0")
+	if _, _, err := s.Transfer(ctx, TransferRequest{FromWalletID: w.ID, ToWalletID: other.ID, Amount: money.MustParse("5")}, Idempotency{}); !errors.Is(err, ErrFXQuoteRequired) {
+		t.Fatalf("transfer without a quote: got %v", err)
+	}
+	// Only the second wallet's creation made it into the outbox
+	if after := outboxSize(t, s); after != before+1 {
+		t.Errorf("outbox grew from %d to %d, want %d", before, after, before+1)
+	}
+}
+
+func outboxSize(t *testing.T, s *WalletService) int {
+	t.Helper()
+	var n int
+	err := s.store.Transaction(context.Background(), func(tx *repository.Store) error {
+		events, err := tx.Outbox.Undispatched(context.Background(), 1000)
+		n = len(events)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"wallet-app/internal/domain/models"
+	"wallet-app/internal/domain/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidWebhookURL = errors.New("webhook URL must be an absolute http or https URL")
+	ErrUnknownEventType  = errors.New("unknown event type")
+)
+
+// WebhookService manages webhook endpoints and their deliveries. Delivery
+// itself is the WebhookDispatcher's job.
+type WebhookService struct {
+	store *repository.Store
+}
+
+func NewWebhookService(store *repository.Store) *WebhookService {
+	return &WebhookService{store: store}
+}
+
+// RegisterEndpoint adds an endpoint for the given event types, or for every
+// event when none are given. Without a secret one is generated; either way
+// it is only returned here.
+func (s *WebhookService) RegisterEndpoint(ctx context.Context, rawURL, secret string, events []string) (*models.WebhookEndpoint, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+	for _, e := range events {
+		if !models.EventFilter(models.EventTypes).Matches(e) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownEventType, e)
+		}
+	}
+	if secret == "" {
+		b := make([]byte, 24)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		secret = "whsec_" + hex.EncodeToString(b)
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		ID:     uuid.New(),
+		URL:    u.String(),
+		Secret: secret,
+		Events: events,
+		Active: true,
+	}
+	if err := s.store.Webhooks.CreateEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (s *WebhookService) ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	return s.store.Webhooks.ActiveEndpoints(ctx)
+}
+
+// RemoveEndpoint stops an endpoint from receiving events. Deliveries
+// already queued for it are dropped by the dispatcher.
+func (s *WebhookService) RemoveEndpoint(ctx context.Context, id uuid.UUID) error {
+	return s.store.Webhooks.Deactivate(ctx, id)
+}
+
+func (s *WebhookService) ListDeliveries(ctx context.Context, status models.DeliveryStatus, limit, offset int) ([]models.WebhookDelivery, error) {
+	return s.store.Webhooks.ListDeliveries(ctx, status, limit, offset)
+}
+
+// Redeliver queues a delivery to be sent again straight away with a fresh
+// set of attempts, whether it was delivered or dead-lettered.
+func (s *WebhookService) Redeliver(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error) {
+	delivery, err := s.store.Webhooks.GetDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Status = models.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now().UTC()
+	delivery.LastError = ""
+	delivery.LastStatusCode = 0
+	if err := s.store.Webhooks.SaveDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
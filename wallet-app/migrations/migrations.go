@@ -0,0 +1,30 @@
+// Package migrations holds the versioned SQL schema, one directory per
+// database. Each file is named <version>_<name>.up.sql; the two directories
+// must define the same versions.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Postgres is the production schema.
+func Postgres() fs.FS {
+	return sub("postgres")
+}
+
+// SQLite is the same schema for SQLite, used by the integration tests.
+func SQLite() fs.FS {
+	return sub("sqlite")
+}
+
+func sub(dir string) fs.FS {
+	fsys, err := fs.Sub(files, dir)
+	if err != nil {
+		panic(err)
+	}
+	return fsys
+}
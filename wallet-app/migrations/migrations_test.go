@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"testing"
+
+	"wallet-app/pkg/database"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestDialectsDefineTheSameVersions(t *testing.T) {
+	pg, err := database.LoadMigrations(Postgres())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lite, err := database.LoadMigrations(SQLite())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pg) == 0 || len(pg) != len(lite) {
+		t.Fatalf("%d Postgres migrations, %d SQLite migrations", len(pg), len(lite))
+	}
+	for i := range pg {
+		if pg[i].Version != lite[i].Version || pg[i].Name != lite[i].Name {
+			t.Errorf("Postgres has %d_%s where SQLite has %d_%s", pg[i].Version, pg[i].Name, lite[i].Version, lite[i].Name)
+		}
+	}
+}
+
+func TestMigrateSQLite(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+uuid.NewString()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+
+	all, err := database.LoadMigrations(SQLite())
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := database.Migrate(db, SQLite())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(all) {
+		t.Errorf("applied %v, want all %d migrations", applied, len(all))
+	}
+	for _, table := range []string{"wallets", "transactions", "postings", "idempotency_keys", "holds",
+		"risk_decisions", "outbox_events", "webhook_endpoints", "webhook_deliveries", "fx_quotes"} {
+		if !db.Migrator().HasTable(table) {
+			t.Errorf("table %s missing", table)
+		}
+	}
+
+	// Running again is a no-op
+	applied, err = database.Migrate(db, SQLite())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("second run applied %v", applied)
+	}
+}
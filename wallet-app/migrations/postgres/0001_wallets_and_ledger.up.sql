@@ -0,0 +1,32 @@
+CREATE TABLE wallets (
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL,
+    kind       VARCHAR(16) NOT NULL DEFAULT 'user',
+    balance    NUMERIC(19,4) NOT NULL DEFAULT 0,
+    currency   VARCHAR(3) NOT NULL,
+    version    BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_wallets_user_id ON wallets (user_id);
+
+CREATE TABLE transactions (
+    id          UUID PRIMARY KEY,
+    type        VARCHAR(32) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE postings (
+    id             UUID PRIMARY KEY,
+    transaction_id UUID NOT NULL REFERENCES transactions (id),
+    wallet_id      UUID NOT NULL REFERENCES wallets (id),
+    direction      VARCHAR(6) NOT NULL CHECK (direction IN ('debit', 'credit')),
+    amount         NUMERIC(19,4) NOT NULL CHECK (amount > 0),
+    currency       VARCHAR(3) NOT NULL,
+    sequence       BIGINT NOT NULL,
+    balance_after  NUMERIC(19,4) NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_postings_transaction_id ON postings (transaction_id);
+CREATE UNIQUE INDEX idx_postings_wallet_sequence ON postings (wallet_id, sequence);
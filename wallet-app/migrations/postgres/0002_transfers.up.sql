@@ -0,0 +1,12 @@
+ALTER TABLE transactions ADD COLUMN exchange_rate NUMERIC(20,8);
+ALTER TABLE transactions ADD COLUMN quote_id VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE TABLE idempotency_keys (
+    scope        VARCHAR(64) NOT NULL,
+    "key"        VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    status_code  BIGINT NOT NULL DEFAULT 0,
+    response     BYTEA,
+    created_at   TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, "key")
+);
@@ -0,0 +1,39 @@
+CREATE TABLE outbox_events (
+    id            UUID PRIMARY KEY,
+    type          VARCHAR(64) NOT NULL,
+    aggregate_id  UUID NOT NULL,
+    payload       BYTEA NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL,
+    dispatched_at TIMESTAMPTZ
+);
+CREATE INDEX idx_outbox_events_aggregate_id ON outbox_events (aggregate_id);
+CREATE INDEX idx_outbox_events_created_at ON outbox_events (created_at);
+-- The dispatcher only ever looks for undispatched events
+CREATE INDEX idx_outbox_events_undispatched ON outbox_events (created_at) WHERE dispatched_at IS NULL;
+
+CREATE TABLE webhook_endpoints (
+    id         UUID PRIMARY KEY,
+    url        VARCHAR(2048) NOT NULL,
+    secret     VARCHAR(128) NOT NULL,
+    events     TEXT NOT NULL DEFAULT '',
+    active     BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_webhook_endpoints_active ON webhook_endpoints (active);
+
+CREATE TABLE webhook_deliveries (
+    id               UUID PRIMARY KEY,
+    event_id         UUID NOT NULL REFERENCES outbox_events (id),
+    endpoint_id      UUID NOT NULL REFERENCES webhook_endpoints (id),
+    status           VARCHAR(16) NOT NULL,
+    attempts         BIGINT NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMPTZ NOT NULL,
+    last_status_code BIGINT NOT NULL DEFAULT 0,
+    last_error       TEXT NOT NULL DEFAULT '',
+    delivered_at     TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ NOT NULL,
+    updated_at       TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX idx_deliveries_event_endpoint ON webhook_deliveries (event_id, endpoint_id);
+CREATE INDEX idx_deliveries_due ON webhook_deliveries (status, next_attempt_at);
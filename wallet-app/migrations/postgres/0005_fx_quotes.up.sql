@@ -0,0 +1,11 @@
+CREATE TABLE fx_quotes (
+    id            UUID PRIMARY KEY,
+    user_id       UUID NOT NULL,
+    from_currency VARCHAR(3) NOT NULL,
+    to_currency   VARCHAR(3) NOT NULL,
+    rate          NUMERIC(20,8) NOT NULL,
+    expires_at    TIMESTAMPTZ NOT NULL,
+    used_at       TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_fx_quotes_user_id ON fx_quotes (user_id);
@@ -0,0 +1,33 @@
+ALTER TABLE wallets ADD COLUMN daily_limit NUMERIC(19,4);
+ALTER TABLE wallets ADD COLUMN monthly_limit NUMERIC(19,4);
+
+CREATE TABLE holds (
+    id              TEXT PRIMARY KEY,
+    wallet_id       TEXT NOT NULL REFERENCES wallets (id),
+    amount          NUMERIC(19,4) NOT NULL,
+    currency        VARCHAR(3) NOT NULL,
+    status          VARCHAR(16) NOT NULL,
+    captured_amount NUMERIC(19,4),
+    transaction_id  TEXT REFERENCES transactions (id),
+    description     TEXT NOT NULL DEFAULT '',
+    expires_at      DATETIME NOT NULL,
+    created_at      DATETIME NOT NULL,
+    updated_at      DATETIME NOT NULL
+);
+CREATE INDEX idx_holds_wallet_status ON holds (wallet_id, status);
+CREATE INDEX idx_holds_expires_at ON holds (expires_at);
+
+CREATE TABLE risk_decisions (
+    id         TEXT PRIMARY KEY,
+    wallet_id  TEXT NOT NULL,
+    operation  VARCHAR(16) NOT NULL,
+    amount     NUMERIC(19,4) NOT NULL,
+    currency   VARCHAR(3) NOT NULL,
+    outcome    VARCHAR(16) NOT NULL,
+    rule       VARCHAR(64) NOT NULL DEFAULT '',
+    reason     TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX idx_risk_decisions_wallet_id ON risk_decisions (wallet_id);
+CREATE INDEX idx_risk_decisions_outcome ON risk_decisions (outcome);
+CREATE INDEX idx_risk_decisions_created_at ON risk_decisions (created_at);
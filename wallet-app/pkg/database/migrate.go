@@ -0,0 +1,113 @@
+package database
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationLock is the Postgres advisory lock key that serialises
+// instances migrating at the same time.
+const migrationLock = 0x77616c6c6574 // "wallet"
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	SQL     string
+}
+
+// LoadMigrations reads the <version>_<name>.up.sql files in fsys, in
+// version order.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := make(map[int64]string)
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".up.sql")
+		prefix, label, ok := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must be <version>_<name>.up.sql", name)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, name, version)
+		}
+		seen[version] = name
+
+		sql, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: label, SQL: string(sql)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrate applies the migrations in fsys that the database has not seen
+// yet and records them in schema_migrations. Everything runs in one
+// transaction, so a failing migration leaves the schema as it was. It
+// returns the versions it applied.
+func Migrate(db *gorm.DB, fsys fs.FS) ([]int64, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLock).Error; err != nil {
+				return err
+			}
+		}
+		err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT PRIMARY KEY,
+			name       VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)`).Error
+		if err != nil {
+			return err
+		}
+
+		var done []int64
+		if err := tx.Table("schema_migrations").Pluck("version", &done).Error; err != nil {
+			return err
+		}
+		isDone := make(map[int64]bool, len(done))
+		for _, v := range done {
+			isDone[v] = true
+		}
+
+		for _, m := range migrations {
+			if isDone[m.Version] {
+				continue
+			}
+			if err := tx.Exec(m.SQL).Error; err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+				m.Version, m.Name, time.Now().UTC()).Error
+			if err != nil {
+				return err
+			}
+			applied = append(applied, m.Version)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
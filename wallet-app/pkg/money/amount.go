@@ -0,0 +1,328 @@
+// Package money represents monetary amounts exactly. Amounts are fixed-point
+// decimals held as integers, so adding and subtracting them never drifts the
+// way float64 does; rounding only happens where a caller asks for it.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of decimal places an Amount holds. It covers the
+// largest minor unit in ISO 4217 (four places, e.g. CLF).
+const Scale = 4
+
+// scaleFactor is 10^Scale, the number of stored units in 1.
+const scaleFactor = 10000
+
+// maxUnits bounds stored amounts to ±10^14, so adding two in-range amounts
+// cannot overflow an int64.
+const maxUnits = 1e14 * scaleFactor
+
+var (
+	ErrInvalidAmount = errors.New("money: invalid amount")
+	ErrOutOfRange    = errors.New("money: amount out of range")
+)
+
+// Amount is an exact decimal with Scale decimal places. The zero value is
+// zero. Amounts encode to JSON as strings, e.g. "10.5", and are stored in
+// NUMERIC columns.
+type Amount struct {
+	units int64
+}
+
+// Zero is the zero Amount.
+var Zero Amount
+
+// New returns coefficient × 10^-exp, so New(1050, 2) is 10.50. It panics if
+// exp is outside [0, Scale] or the result is out of range; it is meant for
+// constants.
+func New(coefficient int64, exp int) Amount {
+	if exp < 0 || exp > Scale {
+		panic(fmt.Sprintf("money: exponent %d outside [0, %d]", exp, Scale))
+	}
+	a, ok := fromUnits(coefficient, pow10(Scale-exp))
+	if !ok {
+		panic(ErrOutOfRange)
+	}
+	return a
+}
+
+// MustParse is like Parse but panics on error.
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// Parse reads a plain decimal such as "12", "-0.5" or "1000.1234". Exponent
+// notation and more than Scale decimal places are rejected rather than
+// rounded.
+func Parse(s string) (Amount, error) {
+	units, err := parseFixed(s, Scale)
+	if err != nil {
+		return Zero, err
+	}
+	if units > maxUnits || units < -maxUnits {
+		return Zero, fmt.Errorf("%w: %q", ErrOutOfRange, s)
+	}
+	return Amount{units: units}, nil
+}
+
+func fromUnits(n, factor int64) (Amount, bool) {
+	if n > maxUnits/factor || n < -maxUnits/factor {
+		return Zero, false
+	}
+	return Amount{units: n * factor}, true
+}
+
+// Add returns a+b. It panics on int64 overflow, which in-range operands
+// cannot cause; callers accumulating many amounts check InRange.
+func (a Amount) Add(b Amount) Amount {
+	sum := a.units + b.units
+	if (sum > a.units) != (b.units > 0) {
+		panic("money: overflow")
+	}
+	return Amount{units: sum}
+}
+
+// Sub returns a-b.
+func (a Amount) Sub(b Amount) Amount {
+	return a.Add(b.Neg())
+}
+
+func (a Amount) Neg() Amount {
+	return Amount{units: -a.units}
+}
+
+func (a Amount) Abs() Amount {
+	if a.units < 0 {
+		return a.Neg()
+	}
+	return a
+}
+
+// Sign returns -1, 0 or +1.
+func (a Amount) Sign() int {
+	switch {
+	case a.units < 0:
+		return -1
+	case a.units > 0:
+		return 1
+	}
+	return 0
+}
+
+func (a Amount) IsZero() bool {
+	return a.units == 0
+}
+
+// Cmp returns -1, 0 or +1 as a is less than, equal to or greater than b.
+func (a Amount) Cmp(b Amount) int {
+	switch {
+	case a.units < b.units:
+		return -1
+	case a.units > b.units:
+		return 1
+	}
+	return 0
+}
+
+// InRange reports whether a is within the range Parse accepts.
+func (a Amount) InRange() bool {
+	return a.units <= maxUnits && a.units >= -maxUnits
+}
+
+// Sum adds amounts.
+func Sum(amounts ...Amount) Amount {
+	var total Amount
+	for _, a := range amounts {
+		total = total.Add(a)
+	}
+	return total
+}
+
+// Div returns a/n rounded with mode to Scale decimal places. It panics if n
+// is not positive.
+func (a Amount) Div(n int64, mode RoundingMode) Amount {
+	if n <= 0 {
+		panic("money: division by a non-positive count")
+	}
+	return Amount{units: roundQuo(a.units, n, mode)}
+}
+
+// Round rounds a to exp decimal places.
+func (a Amount) Round(exp int, mode RoundingMode) Amount {
+	if exp >= Scale {
+		return a
+	}
+	if exp < 0 {
+		exp = 0
+	}
+	factor := pow10(Scale - exp)
+	return Amount{units: roundQuo(a.units, factor, mode) * factor}
+}
+
+// FitsExponent reports whether a has no more than exp decimal places, i.e.
+// whether it is a whole number of minor units of a currency with that
+// exponent.
+func (a Amount) FitsExponent(exp int) bool {
+	return a.Round(exp, Down) == a
+}
+
+// String formats a with as few decimal places as represent it exactly.
+func (a Amount) String() string {
+	return formatFixed(a.units, Scale)
+}
+
+// StringFixed formats a with exactly exp decimal places, rounding half to
+// even if it has more.
+func (a Amount) StringFixed(exp int) string {
+	if exp > Scale {
+		exp = Scale
+	}
+	s := a.Round(exp, HalfEven).String()
+	if exp <= 0 {
+		return s
+	}
+	dot := strings.IndexByte(s, '.')
+	if dot < 0 {
+		return s + "." + strings.Repeat("0", exp)
+	}
+	return s + strings.Repeat("0", exp-(len(s)-dot-1))
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON accepts a decimal string or a JSON number. Numbers are read
+// from their literal text, never through float64.
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Value stores a as a decimal string, which NUMERIC columns take exactly.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan reads a NUMERIC column. Postgres and MySQL return decimal text.
+// SQLite keeps NUMERIC values as integers or REALs; a REAL is rounded to
+// Scale, which recovers the stored value as long as it has fewer than 15
+// significant digits.
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = Zero
+		return nil
+	case string:
+		return a.scanString(v)
+	case []byte:
+		return a.scanString(string(v))
+	case int64:
+		n, ok := fromUnits(v, scaleFactor)
+		if !ok {
+			return fmt.Errorf("%w: %d", ErrOutOfRange, v)
+		}
+		*a = n
+		return nil
+	case float64:
+		units := math.Round(v * scaleFactor)
+		if math.IsNaN(units) || units > maxUnits || units < -maxUnits {
+			return fmt.Errorf("%w: %v", ErrOutOfRange, v)
+		}
+		*a = Amount{units: int64(units)}
+		return nil
+	}
+	return fmt.Errorf("money: cannot scan %T into Amount", src)
+}
+
+func (a *Amount) scanString(s string) error {
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// parseFixed parses s as a decimal with at most scale decimal places and
+// returns it multiplied by 10^scale.
+func parseFixed(s string, scale int) (int64, error) {
+	invalid := fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	digits := s
+	neg := false
+	if digits != "" && (digits[0] == '-' || digits[0] == '+') {
+		neg = digits[0] == '-'
+		digits = digits[1:]
+	}
+	whole, frac, hasDot := strings.Cut(digits, ".")
+	if whole == "" && frac == "" || hasDot && frac == "" {
+		return 0, invalid
+	}
+	if len(frac) > scale {
+		return 0, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidAmount, s, scale)
+	}
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return 0, invalid
+		}
+	}
+	n, err := strconv.ParseInt(whole+frac+strings.Repeat("0", scale-len(frac)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrOutOfRange, s)
+	}
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
+
+// formatFixed formats n × 10^-scale, trimming trailing zeros.
+func formatFixed(n int64, scale int) string {
+	sign := ""
+	u := uint64(n)
+	if n < 0 {
+		sign = "-"
+		u = uint64(-n)
+	}
+	factor := uint64(pow10(scale))
+	whole := strconv.FormatUint(u/factor, 10)
+	frac := u % factor
+	if frac == 0 {
+		return sign + whole
+	}
+	f := strconv.FormatUint(frac, 10)
+	f = strings.Repeat("0", scale-len(f)) + f
+	return sign + whole + "." + strings.TrimRight(f, "0")
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
@@ -0,0 +1,58 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnknownCurrency = errors.New("money: unknown currency")
+
+// exponents lists the ISO 4217 currencies whose minor unit is not two
+// decimal places.
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// twoDecimal lists the remaining active ISO 4217 currencies.
+const twoDecimal = "AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN " +
+	"BMD BND BOB BOV BRL BSD BTN BWP BYN BZD CAD CDF CHE CHF CHW CNY COP COU " +
+	"CRC CUP CVE CZK DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD " +
+	"GTQ GYD HKD HNL HTG HUF IDR ILS INR IRR JMD KES KGS KHR KPW KYD KZT LAK " +
+	"LBP LKR LRD LSL MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR " +
+	"MZN NAD NGN NIO NOK NPR NZD PAB PEN PGK PHP PKR PLN QAR RON RSD RUB SAR " +
+	"SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP STN SVC SYP SZL THB TJS TMT TOP " +
+	"TRY TTD TWD TZS UAH USD USN UYU UZS VES VED WST XCD YER ZAR ZMW ZWL"
+
+func init() {
+	for _, code := range strings.Fields(twoDecimal) {
+		exponents[code] = 2
+	}
+}
+
+// Exponent returns the number of decimal places in currency's minor unit,
+// e.g. 2 for USD and 0 for JPY.
+func Exponent(currency string) (int, error) {
+	exp, ok := exponents[strings.ToUpper(currency)]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	return exp, nil
+}
+
+// Check reports an error unless a is a whole number of currency's minor
+// units, so "10.005" is rejected for USD and "1.5" for JPY.
+func Check(a Amount, currency string) error {
+	exp, err := Exponent(currency)
+	if err != nil {
+		return err
+	}
+	if !a.FitsExponent(exp) {
+		return fmt.Errorf("%w: %s has more than %d decimal places for %s", ErrInvalidAmount, a, exp, strings.ToUpper(currency))
+	}
+	return nil
+}
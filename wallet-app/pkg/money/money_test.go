@@ -0,0 +1,355 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// Generate gives testing/quick in-range amounts, mostly small and with
+// every number of decimal places.
+func (Amount) Generate(r *rand.Rand, size int) reflect.Value {
+	bound := []int64{1000, 100000000, maxUnits}[r.Intn(3)]
+	step := pow10(r.Intn(Scale + 1))
+	units := r.Int63n(bound/step+1) * step
+	if r.Intn(2) == 0 {
+		units = -units
+	}
+	return reflect.ValueOf(Amount{units: units})
+}
+
+func TestParseAndString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"0", "0"},
+		{"10", "10"},
+		{"10.50", "10.5"},
+		{"-0.0001", "-0.0001"},
+		{"+3.25", "3.25"},
+		{".5", "0.5"},
+		{"007.10", "7.1"},
+		{"-0", "0"},
+		{"100000000000000", "100000000000000"},
+	}
+	for _, tt := range tests {
+		a, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got := a.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "-", ".", "1.", "1e3", "1.00001", "abc", "1,5", "--1", "NaN", " 1"} {
+		if _, err := Parse(in); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Parse(%q): got %v, want ErrInvalidAmount", in, err)
+		}
+	}
+	for _, in := range []string{"100000000000000.0001", "999999999999999", "-1000000000000000000000"} {
+		if _, err := Parse(in); !errors.Is(err, ErrOutOfRange) {
+			t.Errorf("Parse(%q): got %v, want ErrOutOfRange", in, err)
+		}
+	}
+}
+
+func TestStringFixed(t *testing.T) {
+	tests := []struct {
+		in   string
+		exp  int
+		want string
+	}{
+		{"10.5", 2, "10.50"},
+		{"10", 2, "10.00"},
+		{"-0.1", 3, "-0.100"},
+		{"1234.5", 0, "1234"},
+		{"1235.5", 0, "1236"},
+		{"0.0001", 4, "0.0001"},
+	}
+	for _, tt := range tests {
+		if got := MustParse(tt.in).StringFixed(tt.exp); got != tt.want {
+			t.Errorf("%s.StringFixed(%d) = %s, want %s", tt.in, tt.exp, got, tt.want)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		in   string
+		exp  int
+		mode RoundingMode
+		want string
+	}{
+		{"2.345", 2, HalfEven, "2.34"},
+		{"2.355", 2, HalfEven, "2.36"},
+		{"2.345", 2, HalfUp, "2.35"},
+		{"-2.345", 2, HalfUp, "-2.35"},
+		{"-2.345", 2, HalfEven, "-2.34"},
+		{"2.3451", 2, HalfEven, "2.35"},
+		{"2.349", 2, Down, "2.34"},
+		{"-2.349", 2, Down, "-2.34"},
+		{"0.5", 0, HalfEven, "0"},
+		{"1.5", 0, HalfEven, "2"},
+		{"-0.5", 0, HalfUp, "-1"},
+		{"7.1234", 4, HalfUp, "7.1234"},
+	}
+	for _, tt := range tests {
+		if got := MustParse(tt.in).Round(tt.exp, tt.mode).String(); got != tt.want {
+			t.Errorf("Round(%s, %d, %d) = %s, want %s", tt.in, tt.exp, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestDiv(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int64
+		want string
+	}{
+		{"10", 4, "2.5"},
+		{"10", 3, "3.3333"},
+		{"20", 3, "6.6667"},
+		{"-20", 3, "-6.6667"},
+		{"0.0005", 2, "0.0002"},
+		{"0.0015", 2, "0.0008"},
+	}
+	for _, tt := range tests {
+		if got := MustParse(tt.in).Div(tt.n, HalfEven).String(); got != tt.want {
+			t.Errorf("%s / %d = %s, want %s", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		amount, rate string
+		exp          int
+		want         string
+	}{
+		{"10", "0.9123", 2, "9.12"},
+		{"100", "151.234", 0, "15123"},
+		{"0.05", "0.5", 2, "0.02"},
+		{"0.15", "0.5", 2, "0.08"},
+		{"1", "0.00000001", 4, "0"},
+		{"12345678.99", "1.23456789", 2, "15241578.86"},
+		{"1.234", "3.25", 3, "4.01"},
+	}
+	for _, tt := range tests {
+		got, err := MustParse(tt.amount).Convert(MustParseRate(tt.rate), tt.exp, HalfEven)
+		if err != nil {
+			t.Errorf("%s × %s: %v", tt.amount, tt.rate, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s × %s = %s, want %s", tt.amount, tt.rate, got, tt.want)
+		}
+	}
+
+	if _, err := MustParse("100000000000000").Convert(MustParseRate("2"), 2, HalfEven); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("overflowing conversion: got %v, want ErrOutOfRange", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		amount, currency string
+		want             error
+	}{
+		{"10.05", "USD", nil},
+		{"10.005", "usd", ErrInvalidAmount},
+		{"1500", "JPY", nil},
+		{"1.5", "JPY", ErrInvalidAmount},
+		{"1.005", "KWD", nil},
+		{"1.0001", "CLF", nil},
+		{"1", "XYZ", ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		if err := Check(MustParse(tt.amount), tt.currency); !errors.Is(err, tt.want) {
+			t.Errorf("Check(%s, %s) = %v, want %v", tt.amount, tt.currency, err, tt.want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		Amount Amount `json:"amount"`
+		Rate   Rate   `json:"rate"`
+	}
+	if err := json.Unmarshal([]byte(`{"amount": 0.1, "rate": "1.10000001"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Amount != New(1, 1) || v.Rate.String() != "1.10000001" {
+		t.Errorf("decoded %+v", v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"amount":"0.1","rate":"1.10000001"}` {
+		t.Errorf("encoded %s", b)
+	}
+	for _, bad := range []string{`{"amount": 1e2}`, `{"amount": "0.00001"}`, `{"amount": true}`} {
+		if err := json.Unmarshal([]byte(bad), &v); err == nil {
+			t.Errorf("decoding %s succeeded", bad)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want string
+	}{
+		{nil, "0"},
+		{"10.5000", "10.5"},
+		{[]byte("-3.1400"), "-3.14"},
+		{int64(42), "42"},
+		// SQLite hands back REALs, including sums that drifted in floating
+		// point
+		{0.1 + 0.2, "0.3"},
+		{float64(70), "70"},
+	}
+	for _, tt := range tests {
+		var a Amount
+		if err := a.Scan(tt.src); err != nil {
+			t.Errorf("Scan(%v): %v", tt.src, err)
+			continue
+		}
+		if a.String() != tt.want {
+			t.Errorf("Scan(%v) = %s, want %s", tt.src, a, tt.want)
+		}
+	}
+	var a Amount
+	if err := a.Scan(true); err == nil {
+		t.Error("scanning a bool succeeded")
+	}
+}
+
+// The properties below are what moving off float64 buys: every amount
+// survives a round trip, and sums are exact whatever the order.
+
+func TestPropertyRoundTrip(t *testing.T) {
+	property := func(a Amount) bool {
+		parsed, err := Parse(a.String())
+		if err != nil || parsed != a {
+			return false
+		}
+		b, err := json.Marshal(a)
+		if err != nil {
+			return false
+		}
+		var decoded Amount
+		if err := json.Unmarshal(b, &decoded); err != nil || decoded != a {
+			return false
+		}
+		v, _ := a.Value()
+		var scanned Amount
+		return scanned.Scan(v) == nil && scanned == a
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPropertySumsNeverDrift(t *testing.T) {
+	property := func(amounts []Amount, seed int64) bool {
+		if len(amounts) > 9 {
+			// Nine in-range amounts cannot overflow an int64 in any order
+			amounts = amounts[:9]
+		}
+		exact := new(big.Int)
+		for _, a := range amounts {
+			exact.Add(exact, big.NewInt(a.units))
+		}
+		total := Sum(amounts...)
+		if total.units != exact.Int64() {
+			return false
+		}
+
+		// Any order gives the same total, and taking every amount back
+		// out leaves exactly zero
+		shuffled := append([]Amount(nil), amounts...)
+		rand.New(rand.NewSource(seed)).Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		if Sum(shuffled...) != total {
+			return false
+		}
+		for _, a := range shuffled {
+			total = total.Sub(a)
+		}
+		return total.IsZero()
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPropertyTenthsAddUp(t *testing.T) {
+	// float64 famously gets 0.1 × n wrong; Amount never does
+	property := func(n uint8) bool {
+		var total Amount
+		for i := 0; i < int(n); i++ {
+			total = total.Add(MustParse("0.1"))
+		}
+		return total == New(int64(n), 1)
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPropertyRound(t *testing.T) {
+	property := func(a Amount, e uint8, m uint8) bool {
+		exp, mode := int(e%(Scale+1)), RoundingMode(m%3)
+		rounded := a.Round(exp, mode)
+		// Rounding lands on the exponent, is idempotent, and moves by less
+		// than one minor unit
+		step := New(1, exp)
+		return rounded.FitsExponent(exp) &&
+			rounded.Round(exp, mode) == rounded &&
+			rounded.Sub(a).Abs().Cmp(step) < 0 &&
+			(mode != Down || rounded.Abs().Cmp(a.Abs()) <= 0)
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPropertyHalfEvenIsUnbiased(t *testing.T) {
+	// Rounding every tie from 0.005 to 99.995 to cents with HalfEven loses
+	// nothing overall; HalfUp would gain half a cent per pair
+	var raw, rounded Amount
+	for cents := int64(0); cents < 10000; cents++ {
+		tie := New(cents*10+5, 3)
+		raw = raw.Add(tie)
+		rounded = rounded.Add(tie.Round(2, HalfEven))
+	}
+	if raw != rounded {
+		t.Errorf("HalfEven drifted: %s vs %s", rounded, raw)
+	}
+}
+
+func TestPropertyConvertMatchesExactProduct(t *testing.T) {
+	property := func(cents uint32, rateUnits uint32) bool {
+		a := New(int64(cents), 2)
+		r := Rate{units: int64(rateUnits) + 1}
+		got, err := a.Convert(r, 2, HalfEven)
+		if err != nil {
+			return false
+		}
+		exact := new(big.Rat).Mul(new(big.Rat).SetFrac64(int64(cents), 100), new(big.Rat).SetFrac64(r.units, rateFactor))
+		diff := new(big.Rat).Sub(exact, new(big.Rat).SetFrac64(got.units, scaleFactor))
+		// Within half a cent of the exact product
+		return diff.Abs(diff).Cmp(big.NewRat(1, 200)) <= 0
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
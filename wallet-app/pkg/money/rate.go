@@ -0,0 +1,134 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// RateScale is the number of decimal places a Rate holds.
+const RateScale = 8
+
+const rateFactor = 100000000
+
+// Rate is an exact exchange rate: how many units of one currency buy one
+// unit of another. Like Amount it encodes to JSON as a string.
+type Rate struct {
+	units int64
+}
+
+// ParseRate reads a plain decimal with at most RateScale decimal places.
+func ParseRate(s string) (Rate, error) {
+	units, err := parseFixed(s, RateScale)
+	if err != nil {
+		return Rate{}, err
+	}
+	return Rate{units: units}, nil
+}
+
+// MustParseRate is like ParseRate but panics on error.
+func MustParseRate(s string) Rate {
+	r, err := ParseRate(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Sign returns -1, 0 or +1.
+func (r Rate) Sign() int {
+	return Amount{units: r.units}.Sign()
+}
+
+func (r Rate) String() string {
+	return formatFixed(r.units, RateScale)
+}
+
+// Convert returns a×r rounded to exp decimal places. The product is exact
+// before the single rounding step.
+func (a Amount) Convert(r Rate, exp int, mode RoundingMode) (Amount, error) {
+	if exp > Scale {
+		exp = Scale
+	}
+	if exp < 0 {
+		exp = 0
+	}
+	product := new(big.Int).Mul(big.NewInt(a.units), big.NewInt(r.units))
+	divisor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(RateScale+Scale-exp)), nil)
+	q := roundQuoBig(product, divisor, mode)
+	factor := pow10(Scale - exp)
+	if !q.IsInt64() {
+		return Zero, ErrOutOfRange
+	}
+	converted, ok := fromUnits(q.Int64(), factor)
+	if !ok {
+		return Zero, ErrOutOfRange
+	}
+	return converted, nil
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON accepts a decimal string or a JSON number.
+func (r *Rate) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+	}
+	v, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
+
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+// Scan reads a NUMERIC column the way Amount.Scan does.
+func (r *Rate) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*r = Rate{}
+		return nil
+	case string:
+		return r.scanString(v)
+	case []byte:
+		return r.scanString(string(v))
+	case int64:
+		if v > math.MaxInt64/rateFactor || v < math.MinInt64/rateFactor {
+			return fmt.Errorf("%w: %d", ErrOutOfRange, v)
+		}
+		*r = Rate{units: v * rateFactor}
+		return nil
+	case float64:
+		units := math.Round(v * rateFactor)
+		if math.IsNaN(units) || math.Abs(units) >= math.MaxInt64 {
+			return fmt.Errorf("%w: %v", ErrOutOfRange, v)
+		}
+		*r = Rate{units: int64(units)}
+		return nil
+	}
+	return fmt.Errorf("money: cannot scan %T into Rate", src)
+}
+
+func (r *Rate) scanString(s string) error {
+	v, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
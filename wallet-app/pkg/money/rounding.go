@@ -0,0 +1,70 @@
+package money
+
+import "math/big"
+
+// RoundingMode says how to round a value that falls between two
+// representable ones.
+type RoundingMode int
+
+const (
+	// HalfEven rounds to the nearest value and ties to the even one
+	// (banker's rounding). Ties fall either way equally often, so it does
+	// not bias totals. It is the default for conversions.
+	HalfEven RoundingMode = iota
+	// HalfUp rounds to the nearest value and ties away from zero.
+	HalfUp
+	// Down truncates toward zero.
+	Down
+)
+
+// roundQuo returns n/d rounded with mode. d must be positive.
+func roundQuo(n, d int64, mode RoundingMode) int64 {
+	q, r := n/d, n%d
+	if r < 0 {
+		r = -r
+	}
+	if roundAway(q%2 != 0, cmpHalf(r, d), mode) {
+		if n < 0 {
+			return q - 1
+		}
+		return q + 1
+	}
+	return q
+}
+
+// roundQuoBig is roundQuo for products too large for an int64.
+func roundQuoBig(n, d *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	r.Abs(r)
+	twice := r.Lsh(r, 1)
+	if roundAway(q.Bit(0) != 0, twice.Cmp(d), mode) {
+		if n.Sign() < 0 {
+			return q.Sub(q, big.NewInt(1))
+		}
+		return q.Add(q, big.NewInt(1))
+	}
+	return q
+}
+
+// cmpHalf compares the remainder r with d/2 without losing the odd half.
+func cmpHalf(r, d int64) int {
+	switch twice := 2 * r; {
+	case twice < d:
+		return -1
+	case twice > d:
+		return 1
+	}
+	return 0
+}
+
+// roundAway decides whether a truncated quotient moves one step away from
+// zero, given its parity and how the remainder compares with one half.
+func roundAway(odd bool, half int, mode RoundingMode) bool {
+	switch mode {
+	case HalfUp:
+		return half >= 0
+	case HalfEven:
+		return half > 0 || half == 0 && odd
+	}
+	return false
+}
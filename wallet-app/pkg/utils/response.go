@@ -2,10 +2,10 @@ package utils
 
 import "github.com/gin-gonic/gin"
 
-type ErrorResponse struct {
-    Message string `json:"message"`
+type Error struct {
+	Message string `json:"message"`
 }
 
 func ErrorResponse(c *gin.Context, status int, message string) {
-    c.JSON(status, ErrorResponse{Message: message})
-}
\ No newline at end of file
+	c.JSON(status, Error{Message: message})
+}
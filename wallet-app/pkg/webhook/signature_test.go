@@ -0,0 +1,44 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"wallet.created"}`)
+	header := Sign("whsec_test", now, body)
+
+	if err := Verify("whsec_test", header, body, 5*time.Minute, now.Add(time.Minute)); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   string
+		now    time.Time
+		want   error
+	}{
+		{"wrong secret", "other", header, string(body), now, ErrInvalidSignature},
+		{"tampered body", "whsec_test", header, `{"type":"wallet.deleted"}`, now, ErrInvalidSignature},
+		{"tampered timestamp", "whsec_test", "t=1700000001," + header[len("t=1700000000,"):], string(body), now, ErrInvalidSignature},
+		{"too old", "whsec_test", header, string(body), now.Add(6 * time.Minute), ErrExpiredSignature},
+		{"malformed", "whsec_test", "garbage", string(body), now, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		if err := Verify(tt.secret, tt.header, []byte(tt.body), 5*time.Minute, tt.now); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// Receivers accept any of several v1 signatures, which allows rotating
+	// secrets
+	rotated := header + ",v1=" + Sign("new", now, body)[len("t=1700000000,v1="):]
+	if err := Verify("new", rotated, body, time.Minute, now); err != nil {
+		t.Errorf("rotated secret rejected: %v", err)
+	}
+}